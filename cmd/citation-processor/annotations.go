@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"unicode/utf8"
+)
+
+// annotationsFile receives the resolved citations as Web Annotations with
+// -format annotations
+const annotationsFile = "annotations.jsonl"
+
+// annotationContext is the JSON-LD context of the W3C Web Annotation model
+const annotationContext = "http://www.w3.org/ns/anno.jsonld"
+
+// byteSpan is the extent of a citation in the preprocessed document, in
+// bytes
+type byteSpan struct {
+	start, end int
+}
+
+// textSelector locates a citation in the text of its document, markup left
+// out, by the code points before its start and end and the text between
+type textSelector struct {
+	start, end int
+	exact      string
+}
+
+// setTextSelectors finds where the citations lie in the text of the
+// document, taking the offsets in order so it is read only once
+func setTextSelectors(xmlContent string, citations []Citation) {
+	var offsets []int
+	for _, citation := range citations {
+		if citation.span.end > citation.span.start {
+			offsets = append(offsets, citation.span.start, citation.span.end)
+		}
+	}
+	if len(offsets) == 0 {
+		return
+	}
+	sort.Ints(offsets)
+
+	positions := make(map[int]int, len(offsets))
+	previous, position := 0, 0
+	for _, offset := range offsets {
+		position += utf8.RuneCountInString(stripContextTags(xmlContent[previous:offset]))
+		positions[offset] = position
+		previous = offset
+	}
+	for i := range citations {
+		span := citations[i].span
+		if span.end <= span.start {
+			continue
+		}
+		citations[i].selector = &textSelector{
+			start: positions[span.start],
+			end:   positions[span.end],
+			exact: stripContextTags(xmlContent[span.start:span.end]),
+		}
+	}
+}
+
+// webAnnotation is a resolved citation as a W3C Web Annotation linking the
+// cited passage to the place in the document that cites it
+type webAnnotation struct {
+	Context    string           `json:"@context"`
+	ID         string           `json:"id"`
+	Type       string           `json:"type"`
+	Motivation string           `json:"motivation"`
+	Body       annotationBody   `json:"body"`
+	Target     annotationTarget `json:"target"`
+}
+
+type annotationBody struct {
+	ID string `json:"id"` // the CTS URN cited
+}
+
+type annotationTarget struct {
+	Source   string        `json:"source"` // the document's CTS URN, else its file URL
+	Selector []interface{} `json:"selector,omitempty"`
+}
+
+type textPositionSelector struct {
+	Type  string `json:"type"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+type textQuoteSelector struct {
+	Type  string `json:"type"`
+	Exact string `json:"exact"`
+}
+
+// newWebAnnotation returns the annotation of a resolved citation
+func newWebAnnotation(citation Citation) webAnnotation {
+	annotation := webAnnotation{
+		Context:    annotationContext,
+		ID:         citation.DocCitURN,
+		Type:       "Annotation",
+		Motivation: "linking",
+		Body:       annotationBody{ID: citation.URN},
+		Target:     annotationTarget{Source: annotationSource(citation)},
+	}
+	if selector := citation.selector; selector != nil {
+		annotation.Target.Selector = []interface{}{
+			textPositionSelector{Type: "TextPositionSelector", Start: selector.start, End: selector.end},
+			textQuoteSelector{Type: "TextQuoteSelector", Exact: selector.exact},
+		}
+	}
+	return annotation
+}
+
+// annotationSource identifies the document a citation was found in
+func annotationSource(citation Citation) string {
+	if citation.Document != nil && citation.Document.URN != "" {
+		return citation.Document.URN
+	}
+	path, err := filepath.Abs(citation.Filename)
+	if err != nil {
+		path = citation.Filename
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// annotationWriter writes each resolved citation as a Web Annotation, one
+// JSON-LD document per line of annotations.jsonl, or to standard output with
+// -output -. Output is flushed after every input file.
+type annotationWriter struct {
+	path    string
+	io      IOOptions
+	limiter *rateLimiter
+
+	file *outputFile
+	out  *bufio.Writer // standard output, instead of file
+}
+
+func (w *annotationWriter) Write(citations []Citation) error {
+	if w.file == nil && w.out == nil {
+		var err error
+		if w.file, err = openOutputFile(w.path, os.O_CREATE|os.O_TRUNC, w.io, w.limiter); err != nil {
+			return err
+		}
+	}
+
+	for _, citation := range citations {
+		if citation.URN == "" || citation.Ref == "" {
+			continue
+		}
+		jsonData, err := json.Marshal(newWebAnnotation(citation))
+		if err != nil {
+			continue
+		}
+		if _, err := w.write(append(jsonData, '\n')); err != nil {
+			return err
+		}
+	}
+	if w.out != nil {
+		return w.out.Flush()
+	}
+	return w.file.Checkpoint()
+}
+
+func (w *annotationWriter) write(p []byte) (int, error) {
+	if w.out != nil {
+		return w.out.Write(p)
+	}
+	return w.file.Write(p)
+}
+
+func (w *annotationWriter) Close() error {
+	if w.out != nil {
+		return w.out.Flush()
+	}
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
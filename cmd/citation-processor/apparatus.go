@@ -0,0 +1,47 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// appRegex matches an apparatus criticus entry
+	appRegex = regexp.MustCompile(`(?s)<app\b[^>]*>.*?</app>`)
+	// lemRegex matches the lemma of an apparatus entry
+	lemRegex = regexp.MustCompile(`(?s)<lem\b[^>]*>(.*?)</lem>`)
+)
+
+// apparatusEntry is the span of an <app> element and the text of its lemma
+type apparatusEntry struct {
+	start, end int
+	lemma      string
+}
+
+// apparatusEntries finds the apparatus entries of a document, in document
+// order
+func apparatusEntries(xmlContent string) []apparatusEntry {
+	if !strings.Contains(xmlContent, "<app") {
+		return nil
+	}
+	var entries []apparatusEntry
+	for _, loc := range appRegex.FindAllStringIndex(xmlContent, -1) {
+		entry := apparatusEntry{start: loc[0], end: loc[1]}
+		if match := lemRegex.FindStringSubmatch(xmlContent[loc[0]:loc[1]]); match != nil {
+			entry.lemma = strings.Join(strings.Fields(stripContextTags(match[1])), " ")
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// apparatusAt returns the lemma of the apparatus entry containing the byte
+// offset, and whether there is one
+func (cp *CitationProcessor) apparatusAt(offset int) (lemma string, inApparatus bool) {
+	i := sort.Search(len(cp.apparatus), func(i int) bool { return cp.apparatus[i].end > offset })
+	if i < len(cp.apparatus) && cp.apparatus[i].start <= offset {
+		return cp.apparatus[i].lemma, true
+	}
+	return "", false
+}
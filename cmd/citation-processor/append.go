@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// citationNumber splits a doc_cit_urn such as
+// "campbell-sophlanguage-2:citations-57" into the document's ID and the
+// citation's number
+func citationNumber(docCitURN string) (docID string, number int, ok bool) {
+	i := strings.LastIndex(docCitURN, ":citations-")
+	if i < 0 {
+		return "", 0, false
+	}
+	number, err := strconv.Atoi(docCitURN[i+len(":citations-"):])
+	if err != nil {
+		return "", 0, false
+	}
+	return docCitURN[:i], number, true
+}
+
+// existingCitationNumbers returns the number of the last citation of each
+// document in the output directory, which a run with Config.Append continues
+// from so that the combined output never repeats a doc_cit_urn. The numbers
+// are read from the documents.jsonl manifest, or, when it is missing or
+// predates the last_citation field, from the citations themselves.
+func (cp *CitationProcessor) existingCitationNumbers() (map[string]int, error) {
+	numbers := make(map[string]int)
+	complete := false
+	for _, path := range outputVariants(filepath.Join(cp.Config.OutputDir, documentsFile)) {
+		found, err := readManifestNumbers(path, numbers)
+		if err != nil {
+			return numbers, err
+		}
+		complete = complete || found
+	}
+	if complete {
+		return numbers, nil
+	}
+
+	resolvedPath := filepath.Join(cp.Config.OutputDir, cp.Config.ResolvedFile)
+	files := []string{
+		resolvedPath,
+		filepath.Join(cp.Config.OutputDir, cp.Config.UnresolvedFile),
+		filepath.Join(cp.Config.OutputDir, provisionalFile),
+		filepath.Join(cp.Config.OutputDir, proseFile),
+		filepath.Join(cp.Config.OutputDir, secondaryFile),
+		filepath.Join(cp.Config.OutputDir, annotationsFile),
+	}
+	// a previous run may have been partitioned, or compressed, even if this
+	// one is not
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, outputVariants(file)...)
+	}
+	for _, file := range outputVariants(resolvedPath) {
+		partitions, _ := filepath.Glob(partitionPath(file, "*"))
+		paths = append(paths, partitions...)
+	}
+	for _, path := range paths {
+		if err := scanCitationNumbers(path, numbers); err != nil {
+			return nil, err
+		}
+	}
+	return numbers, nil
+}
+
+// readManifestNumbers records the last citation number of each document
+// listed in documents.jsonl, reporting whether the manifest exists and
+// every record has one
+func readManifestNumbers(path string, numbers map[string]int) (bool, error) {
+	complete := true
+	err := scanJSONLines(path, func(line []byte) error {
+		var record documentRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return err
+		}
+		if record.DocID == "" {
+			complete = false
+		}
+		numbers[record.DocID] = max(numbers[record.DocID], record.LastCitation)
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return complete, err
+}
+
+// scanCitationNumbers records the highest citation number of each document
+// among the citation records, or Web Annotations, of a file
+func scanCitationNumbers(path string, numbers map[string]int) error {
+	err := scanJSONLines(path, func(line []byte) error {
+		var record struct {
+			DocCitURN string `json:"doc_cit_urn"`
+			ID        string `json:"id"` // doc_cit_urn of an annotation
+		}
+		if err := json.Unmarshal(line, &record); err != nil {
+			return err
+		}
+		for _, id := range []string{record.DocCitURN, record.ID} {
+			if docID, number, ok := citationNumber(id); ok {
+				numbers[docID] = max(numbers[docID], number)
+			}
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// scanJSONLines calls fn with each non-empty line of a JSONL file, which
+// may be compressed
+func scanJSONLines(path string, fn func(line []byte) error) error {
+	file, err := openJSONL(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 10*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := fn(scanner.Bytes()); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// benchCitations are the citation forms the synthetic corpus is made of, one
+// for each path through extraction and resolution; %d is replaced by a
+// passage number so that the references differ from file to file
+var benchCitations = []string{
+	`<bibl>Soph. OT %d</bibl>`,
+	`<bibl n="Soph. OC %d">O. C. %d</bibl>`,
+	`<cit><bibl>Hom. Il. 1.%d</bibl><quote>μῆνιν ἄειδε θεὰ</quote></cit>`,
+	`<cit><quote>Arma virumque cano</quote><bibl>Verg. A. 1.%d</bibl></cit>`,
+	`<bibl>Thuc. ii. %d</bibl>`,
+	`<bibl>Plat. Rep. %da</bibl>`,
+	`<bibl>Hdt. 2.%d</bibl>`,
+	`<bibl>Cic. Amic. %d</bibl>`,
+	`<bibl>Dem. 18.%d</bibl>`,
+	`<bibl>FGrH 115 F %d</bibl>`,
+	`<ref target="urn:cts:greekLit:tlg0011.tlg002.perseus-grc2:%d">Ant. %d</ref>`,
+	`<bibl>Xyz. Abc. %d</bibl>`,
+}
+
+// benchDocument returns file i of a synthetic corpus with the given number
+// of citations, each in a sentence of running text
+func benchDocument(i, citations int) string {
+	var doc strings.Builder
+	doc.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	doc.WriteString(`<TEI xmlns="http://www.tei-c.org/ns/1.0"><teiHeader><fileDesc><titleStmt>`)
+	fmt.Fprintf(&doc, `<title>Synthetic commentary %d</title><author>Benchmark</author>`, i)
+	doc.WriteString(`</titleStmt></fileDesc></teiHeader><text><body><div type="commentary">`)
+	for j := 0; j < citations; j++ {
+		if j%10 == 0 {
+			if j > 0 {
+				doc.WriteString(`</p>`)
+			}
+			fmt.Fprintf(&doc, `<head>Section %d</head><p>`, j/10+1)
+		}
+		form := benchCitations[(i+j)%len(benchCitations)]
+		passage := (i*citations+j)%900 + 1
+		citation := strings.ReplaceAll(form, "%d", fmt.Sprint(passage))
+		fmt.Fprintf(&doc, `The commentator compares the usage &amp; sense of the word at %s, `, citation)
+		doc.WriteString(`as the scholiast had noted before him. `)
+	}
+	doc.WriteString(`</p></div></body></text></TEI>`)
+	return doc.String()
+}
+
+// benchResult is what one benchmark run measured
+type benchResult struct {
+	Files     int
+	Citations int
+	Bytes     int
+	Load      time.Duration // creating the processor and loading the data
+	Elapsed   time.Duration // extracting and resolving the corpus
+	Allocs    uint64        // heap allocations while processing
+	AllocSize uint64        // bytes allocated while processing
+}
+
+// runBenchmark generates the synthetic corpus and extracts the citations of
+// every file, timing the processing and counting its allocations. Nothing is
+// written, so the figures measure extraction and resolution alone.
+func runBenchmark(config Config, files, citations int) (benchResult, error) {
+	result := benchResult{Files: files}
+	documents := make([]string, files)
+	for i := range documents {
+		documents[i] = benchDocument(i, citations)
+		result.Bytes += len(documents[i])
+	}
+
+	start := time.Now()
+	processor, err := NewCitationProcessor(config)
+	if err != nil {
+		return result, err
+	}
+	result.Load = time.Since(start)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start = time.Now()
+	for i, document := range documents {
+		extracted, err := processor.ExtractFromString(document, fmt.Sprintf("synthetic%04d.xml", i))
+		if err != nil {
+			return result, err
+		}
+		result.Citations += len(extracted)
+	}
+	result.Elapsed = time.Since(start)
+	runtime.ReadMemStats(&after)
+	result.Allocs = after.Mallocs - before.Mallocs
+	result.AllocSize = after.TotalAlloc - before.TotalAlloc
+	return result, nil
+}
+
+// report writes the benchmark figures, per second and per file
+func (r benchResult) report(out io.Writer) {
+	seconds := r.Elapsed.Seconds()
+	fmt.Fprintf(out, "data load:      %v\n", r.Load.Round(time.Millisecond))
+	fmt.Fprintf(out, "files:          %d (%.1f MB)\n", r.Files, float64(r.Bytes)/1e6)
+	fmt.Fprintf(out, "citations:      %d\n", r.Citations)
+	fmt.Fprintf(out, "elapsed:        %v\n", r.Elapsed.Round(time.Millisecond))
+	if seconds > 0 {
+		fmt.Fprintf(out, "files/sec:      %.1f\n", float64(r.Files)/seconds)
+		fmt.Fprintf(out, "citations/sec:  %.1f\n", float64(r.Citations)/seconds)
+	}
+	if r.Files > 0 {
+		fmt.Fprintf(out, "allocs/file:    %d\n", r.Allocs/uint64(r.Files))
+		fmt.Fprintf(out, "bytes/file:     %d\n", r.AllocSize/uint64(r.Files))
+	}
+}
+
+// runBench implements the bench subcommand, which processes a synthetic
+// corpus generated in memory and reports throughput and allocations, so that
+// releases can be compared for performance regressions on the same input
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	files := fs.Int("files", 50, "Files in the synthetic corpus")
+	citations := fs.Int("citations", 40, "Citations in each file")
+	noCitTags := fs.Bool("nocit", false, "Extract <bibl> elements only, as for processing runs")
+	strict := fs.Bool("strict", false, "Only extract TEI P5 citation elements under whitelisted parents")
+	aggressive := fs.Bool("aggressive", false, "Also match citation patterns in running text")
+	cpuProfile := fs.String("cpuprofile", "", "Write a CPU profile of the benchmark to this file")
+	memProfile := fs.String("memprofile", "", "Write an allocation profile of the benchmark to this file")
+	fs.Parse(args)
+
+	if *files <= 0 || *citations <= 0 {
+		return fmt.Errorf("-files and -citations must be positive")
+	}
+	if *strict && *aggressive {
+		return fmt.Errorf("-strict and -aggressive cannot be combined")
+	}
+	config := Config{UseCitTags: !*noCitTags, Strict: *strict, Aggressive: *aggressive}
+
+	profile, err := startProfiling(*cpuProfile, *memProfile)
+	if err != nil {
+		return err
+	}
+	result, err := runBenchmark(config, *files, *citations)
+	if stopErr := profile.Stop(); err == nil {
+		err = stopErr
+	}
+	if err != nil {
+		return err
+	}
+	result.report(stdout)
+	return nil
+}
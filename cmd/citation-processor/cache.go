@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"perseus_citation_linker/pkg/loader"
+	"perseus_citation_linker/pkg/resolver"
+)
+
+// resolutionCache keeps the resolution of each reference for Config.CacheFile,
+// saved at the end of a run and loaded at the start of the next, so that
+// reprocessing a mostly unchanged corpus resolves only the references it has
+// not seen. The cache applies only to the build, data files and resolver
+// options it was made with, recorded as a fingerprint; a cache with another
+// fingerprint is discarded.
+type resolutionCache struct {
+	mu          sync.Mutex
+	path        string
+	fingerprint string
+	entries     map[string]resolver.Resolution // reference -> resolution
+	hits        int
+	misses      int
+}
+
+// cacheFile is the on-disk form of a resolutionCache
+type cacheFile struct {
+	Fingerprint string                         `json:"fingerprint"`
+	Entries     map[string]resolver.Resolution `json:"entries"`
+}
+
+// loadResolutionCache reads the cache at path, starting an empty one if
+// there is none yet or it was made with a different fingerprint
+func loadResolutionCache(path, fingerprint string) (*resolutionCache, error) {
+	cache := &resolutionCache{
+		path:        path,
+		fingerprint: fingerprint,
+		entries:     make(map[string]resolver.Resolution),
+	}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache %s: %w", path, err)
+	}
+	var saved cacheFile
+	if err := json.Unmarshal(content, &saved); err != nil {
+		return nil, fmt.Errorf("failed to parse cache %s: %w", path, err)
+	}
+	if saved.Fingerprint != fingerprint {
+		slog.Info("resolution cache is stale, starting afresh", "file", path)
+		return cache, nil
+	}
+	if saved.Entries != nil {
+		cache.entries = saved.Entries
+	}
+	slog.Debug("loaded resolution cache", "file", path, "entries", len(cache.entries))
+	return cache, nil
+}
+
+// lookup returns the cached resolution of a reference. With a prior, a
+// resolution with candidates may come out differently in another document,
+// so it is not taken from the cache.
+func (c *resolutionCache) lookup(ref string, withPrior bool) (resolver.Resolution, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res, exists := c.entries[ref]
+	if !exists || (withPrior && len(res.Candidates) > 0) {
+		c.misses++
+		return resolver.Resolution{}, false
+	}
+	c.hits++
+	return res, true
+}
+
+// store caches the resolution of a reference, unless a prior chose its work
+// or might have
+func (c *resolutionCache) store(ref string, res resolver.Resolution, withPrior bool) {
+	if res.Prior || (withPrior && len(res.Candidates) > 0) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[ref] = res
+}
+
+// save writes the cache, replacing the previous file only once the new one
+// is complete. The new file has a name of its own until then, so that runs
+// sharing a cache don't write over each other's.
+func (c *resolutionCache) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	content, err := json.Marshal(cacheFile{Fingerprint: c.fingerprint, Entries: c.entries})
+	if err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cache %s: %w", c.path, err)
+	}
+	if err = temp.Chmod(0644); err == nil {
+		_, err = temp.Write(content)
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return fmt.Errorf("failed to write cache %s: %w", c.path, err)
+	}
+	if err := os.Rename(temp.Name(), c.path); err != nil {
+		os.Remove(temp.Name())
+		return fmt.Errorf("failed to write cache %s: %w", c.path, err)
+	}
+	slog.Info("saved resolution cache", "file", c.path, "entries", len(c.entries), "hits", c.hits, "misses", c.misses)
+	return nil
+}
+
+// cacheFingerprint identifies what resolutions depend on: the program, the
+// data files, the corrections table and the resolver's options
+func cacheFingerprint(config Config) (string, error) {
+	hash := sha256.New()
+	hashFile := func(path string) error {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		fmt.Fprintf(hash, "%s\n", filepath.Base(path))
+		_, err = io.Copy(hash, file)
+		return err
+	}
+
+	if executable, err := os.Executable(); err == nil {
+		if err := hashFile(executable); err != nil {
+			return "", fmt.Errorf("failed to fingerprint %s: %w", executable, err)
+		}
+	}
+	dataDir := loader.FindDataDir()
+	files, err := os.ReadDir(dataDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to fingerprint data files: %w", err)
+	}
+	for _, file := range files {
+		if file.Type().IsRegular() {
+			if err := hashFile(filepath.Join(dataDir, file.Name())); err != nil {
+				return "", fmt.Errorf("failed to fingerprint data files: %w", err)
+			}
+		}
+	}
+	if config.CorrectionsFile != "" {
+		if err := hashFile(config.CorrectionsFile); err != nil {
+			return "", fmt.Errorf("failed to fingerprint corrections: %w", err)
+		}
+	}
+	fmt.Fprintf(hash, "work fallback %q\nfuzzy authors %v\nprovenance %v\n", config.WorkFallback, config.FuzzyAuthors, config.Provenance)
+	fmt.Fprintf(hash, "passage format %q %d\n", config.PassageFormat, config.PassageWidth)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"perseus_citation_linker/pkg/httpclient"
+	"perseus_citation_linker/pkg/loader"
+)
+
+// ctsTextGroup is a text group of a CapiTainS text inventory, as returned by
+// the GetCapabilities request of a CTS API
+type ctsTextGroup struct {
+	URN        string     `xml:"urn,attr"`
+	GroupNames []ctsLabel `xml:"groupname"`
+	Works      []ctsWork  `xml:"work"`
+}
+
+type ctsWork struct {
+	URN      string       `xml:"urn,attr"`
+	Titles   []ctsLabel   `xml:"title"`
+	Editions []ctsEdition `xml:"edition"`
+}
+
+type ctsEdition struct {
+	URN       string        `xml:"urn,attr"`
+	Citations []ctsCitation `xml:"online>citationMapping>citation"`
+}
+
+// ctsCitation is a level of an edition's citation mapping, nesting the
+// levels below it, as book and then line
+type ctsCitation struct {
+	Label     string        `xml:"label,attr"`
+	Citations []ctsCitation `xml:"citation"`
+}
+
+type ctsLabel struct {
+	Lang string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Text string `xml:",chardata"`
+}
+
+// catalogTitleLangs are the languages of the titles taken as work keys; the
+// data files key works by their Latin or English titles, not their Greek
+var catalogTitleLangs = map[string]bool{"": true, "eng": true, "lat": true}
+
+// catalogNamespace is a CTS namespace synced into a data file
+type catalogNamespace struct {
+	file     string // data file of its authors
+	prefix   string // of the data file's keys, as in GREEK_AUTH_URNS
+	exemplar string // cited where the data files record no other
+}
+
+// catalogNamespaces are the namespaces sync-catalog keeps up to date; text
+// groups in others, such as the scholia, are left out
+var catalogNamespaces = map[string]catalogNamespace{
+	"greekLit": {file: "greek_data.json", prefix: "GREEK", exemplar: "perseus-grc2"},
+	"latinLit": {file: "latin_data.json", prefix: "LATIN", exemplar: "perseus-lat2"},
+}
+
+// catalogChange is an entry sync-catalog adds to a data file, or one of the
+// catalog's it leaves out as it conflicts with the data files
+type catalogChange struct {
+	File   string `json:"file"`
+	Kind   string `json:"kind"` // author, work, single-work author, exemplar, scheme or conflict
+	Author string `json:"author,omitempty"`
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+}
+
+// catalogSync is the outcome of syncing a text inventory into the data files
+type catalogSync struct {
+	TextGroups int             `json:"text_groups"`
+	Skipped    int             `json:"skipped"` // text groups in namespaces not synced
+	Changes    []catalogChange `json:"changes"`
+
+	files map[string]*jsonObject // by name, the data files changed
+}
+
+// runSyncCatalog implements the sync-catalog subcommand, which downloads the
+// CTS text inventory of the Scaife library and adds the authors, works and
+// editions it lists that the data files lack, reporting what it added
+func runSyncCatalog(args []string) error {
+	fs := flag.NewFlagSet("sync-catalog", flag.ExitOnError)
+	dataDir := fs.String("data", "", "Data directory (default: discovered as for processing runs)")
+	outDir := fs.String("out", "", "Directory to write the refreshed data files to (default: the data directory)")
+	endpoint := fs.String("endpoint", "https://scaife-cts.perseus.org/api/cts", "Base URL of the CTS API")
+	inventory := fs.String("inventory", "", "Read the text inventory from this file rather than the CTS API")
+	httpOptions := addHTTPFlags(fs)
+	dryRun := fs.Bool("dry-run", false, "Report the changes without writing them")
+	asJSON := fs.Bool("json", false, "Write the report as JSON")
+	fs.Parse(args)
+
+	if *dataDir == "" {
+		*dataDir = loader.FindDataDir()
+	}
+	if *outDir == "" {
+		*outDir = *dataDir
+	}
+
+	var content []byte
+	var err error
+	if *inventory != "" {
+		content, err = os.ReadFile(*inventory)
+	} else {
+		client := httpclient.New(*httpOptions)
+		requestURL := strings.TrimSuffix(*endpoint, "/") + "?request=GetCapabilities"
+		content, err = client.Get(context.Background(), requestURL)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read text inventory: %w", err)
+	}
+	groups, err := parseTextInventory(content)
+	if err != nil {
+		return err
+	}
+
+	report, err := syncCatalog(*dataDir, groups)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		report.writeText(stdout)
+	}
+	if *dryRun {
+		return nil
+	}
+
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(report.files))
+	for name := range report.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		inline := dataFileInline
+		if name == loader.CitationSchemesFile {
+			inline = 1
+		}
+		if err := writeDataObject(filepath.Join(*outDir, name), report.files[name], inline); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "wrote %s\n", filepath.Join(*outDir, name))
+	}
+	return nil
+}
+
+// parseTextInventory reads the text groups of a text inventory, whether
+// bare or wrapped in a GetCapabilities reply
+func parseTextInventory(content []byte) ([]ctsTextGroup, error) {
+	decoder := xml.NewDecoder(strings.NewReader(string(content)))
+	var groups []ctsTextGroup
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse text inventory: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "textgroup" {
+			continue
+		}
+		var group ctsTextGroup
+		if err := decoder.DecodeElement(&group, &start); err != nil {
+			return nil, fmt.Errorf("failed to parse text inventory: %w", err)
+		}
+		groups = append(groups, group)
+	}
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("no text groups in the text inventory")
+	}
+	return groups, nil
+}
+
+// syncCatalog adds to the data files of dataDir the text groups of an
+// inventory they lack as authors, named by their English group name, and
+// the works of known authors whose URNs they lack, keyed by title. Where the
+// catalog has no edition of a work with the usual exemplar of its namespace,
+// the Perseus edition it has is recorded in exemplars.json, and the
+// citation mapping of the edition cited is recorded in citation_schemes.json
+// for works without a scheme. Nothing known is changed: a title already
+// keying another work is reported as a conflict.
+func syncCatalog(dataDir string, groups []ctsTextGroup) (*catalogSync, error) {
+	report := &catalogSync{TextGroups: len(groups), files: make(map[string]*jsonObject)}
+	exemplars, err := readDataObject(filepath.Join(dataDir, loader.ExemplarsFile))
+	if err != nil {
+		return nil, err
+	}
+	exemplarsChanged := false
+	schemes, err := readDataObject(filepath.Join(dataDir, loader.CitationSchemesFile))
+	if err != nil {
+		return nil, err
+	}
+	schemesChanged := false
+
+	// the data files of the namespaces synced, with their tables
+	type dataFile struct {
+		namespace         catalogNamespace
+		object            *jsonObject
+		authURNs, works   *jsonObject
+		singleWorkAuthors []string
+		changed           bool
+	}
+	files := make(map[string]*dataFile)
+	for _, namespace := range catalogNamespaces {
+		object, err := readDataObject(filepath.Join(dataDir, namespace.file))
+		if err != nil {
+			return nil, err
+		}
+		file := &dataFile{namespace: namespace, object: object}
+		if file.authURNs, err = object.object(namespace.prefix + "_AUTH_URNS"); err != nil {
+			return nil, fmt.Errorf("%s: %w", namespace.file, err)
+		}
+		if file.works, err = object.object(namespace.prefix + "_WORK_URNS"); err != nil {
+			return nil, fmt.Errorf("%s: %w", namespace.file, err)
+		}
+		if value := object.get(namespace.prefix + "_SINGLE_WORK_AUTHORS"); value != nil {
+			if err := json.Unmarshal(value, &file.singleWorkAuthors); err != nil {
+				return nil, fmt.Errorf("%s: %w", namespace.file, err)
+			}
+		}
+		files[namespace.file] = file
+	}
+
+	sortedGroups := append([]ctsTextGroup(nil), groups...)
+	sort.Slice(sortedGroups, func(i, j int) bool { return sortedGroups[i].URN < sortedGroups[j].URN })
+	for _, group := range sortedGroups {
+		namespace, synced := catalogNamespaces[ctsNamespace(group.URN)]
+		if !synced {
+			report.Skipped++
+			continue
+		}
+		file := files[namespace.file]
+		change := func(kind, author, key, value string) {
+			report.Changes = append(report.Changes, catalogChange{File: namespace.file, Kind: kind, Author: author, Key: key, Value: value})
+		}
+
+		// the author with the text group's URN, else the group name
+		author := ""
+		for _, name := range file.authURNs.keys {
+			var urn string
+			if json.Unmarshal(file.authURNs.get(name), &urn) == nil && urn == group.URN {
+				author = name
+				break
+			}
+		}
+		newAuthor := author == ""
+		if newAuthor {
+			author = catalogKey(catalogLabel(group.GroupNames))
+			if author == "" {
+				change("conflict", "", group.URN, "no group name")
+				continue
+			}
+			if file.authURNs.get(author) != nil {
+				change("conflict", author, group.URN, "the author has another URN in the data files")
+				continue
+			}
+			change("author", author, author, group.URN)
+		}
+
+		works, err := file.works.object(author)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", namespace.file, err)
+		}
+		knownWorks := make(map[string]bool)
+		for _, key := range works.keys {
+			var workID string
+			if json.Unmarshal(works.get(key), &workID) == nil {
+				knownWorks[strings.TrimSpace(workID)] = true
+			}
+		}
+
+		authorSchemes, err := schemes.object(author)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", loader.CitationSchemesFile, err)
+		}
+		added, schemesAdded := 0, 0
+		for _, work := range group.Works {
+			workID := work.URN[strings.LastIndex(work.URN, ".")+1:]
+			if !strings.HasPrefix(work.URN, group.URN+".") {
+				continue
+			}
+			exemplar, unusual := catalogExemplar(work, namespace.exemplar)
+			if unusual && exemplars.get(work.URN) == nil && exemplars.get(group.URN) == nil {
+				if err := exemplars.setValue(work.URN, exemplar); err != nil {
+					return nil, err
+				}
+				exemplarsChanged = true
+				report.Changes = append(report.Changes, catalogChange{File: loader.ExemplarsFile, Kind: "exemplar", Author: author, Key: work.URN, Value: exemplar})
+			}
+			if !unusual {
+				exemplar = namespace.exemplar
+			}
+			if scheme := catalogScheme(work, exemplar); scheme != "" && authorSchemes.get(workID) == nil {
+				if err := authorSchemes.setValue(workID, scheme); err != nil {
+					return nil, err
+				}
+				schemesAdded++
+				report.Changes = append(report.Changes, catalogChange{File: loader.CitationSchemesFile, Kind: "scheme", Author: author, Key: workID, Value: scheme})
+			}
+			if knownWorks[workID] {
+				continue
+			}
+			title := ""
+			for _, label := range work.Titles {
+				if catalogTitleLangs[label.Lang] {
+					title = catalogKey(label.Text)
+					break
+				}
+			}
+			if title == "" {
+				change("conflict", author, work.URN, "no Latin or English title")
+				continue
+			}
+			if works.get(title) != nil {
+				change("conflict", author, title, fmt.Sprintf("keys another work in the data files, not %s", workID))
+				continue
+			}
+			if err := works.setValue(title, workID); err != nil {
+				return nil, err
+			}
+			knownWorks[workID] = true
+			added++
+			change("work", author, title, workID)
+		}
+
+		if newAuthor {
+			if err := file.authURNs.setValue(author, group.URN); err != nil {
+				return nil, err
+			}
+			if added == 1 {
+				file.singleWorkAuthors = append(file.singleWorkAuthors, author)
+				change("single-work author", author, author, "")
+			}
+		}
+		if newAuthor || added > 0 {
+			if err := file.works.setValue(author, works); err != nil {
+				return nil, err
+			}
+			file.changed = true
+		}
+		if schemesAdded > 0 {
+			if err := schemes.setValue(author, authorSchemes); err != nil {
+				return nil, err
+			}
+			schemesChanged = true
+		}
+	}
+
+	for name, file := range files {
+		if !file.changed {
+			continue
+		}
+		namespace := file.namespace
+		if err := file.object.setValue(namespace.prefix+"_AUTH_URNS", file.authURNs); err != nil {
+			return nil, err
+		}
+		if err := file.object.setValue(namespace.prefix+"_WORK_URNS", file.works); err != nil {
+			return nil, err
+		}
+		if file.object.get(namespace.prefix+"_SINGLE_WORK_AUTHORS") != nil || len(file.singleWorkAuthors) > 0 {
+			if err := file.object.setValue(namespace.prefix+"_SINGLE_WORK_AUTHORS", file.singleWorkAuthors); err != nil {
+				return nil, err
+			}
+		}
+		report.files[name] = file.object
+	}
+	if exemplarsChanged {
+		report.files[loader.ExemplarsFile] = exemplars
+	}
+	if schemesChanged {
+		report.files[loader.CitationSchemesFile] = schemes
+	}
+	return report, nil
+}
+
+// ctsNamespace returns the namespace of a CTS URN, as "greekLit"
+func ctsNamespace(urn string) string {
+	parts := strings.SplitN(urn, ":", 4)
+	if len(parts) < 4 || parts[0] != "urn" || parts[1] != "cts" {
+		return ""
+	}
+	return parts[2]
+}
+
+// catalogLabel returns the English label of a text group or work, else the
+// first
+func catalogLabel(labels []ctsLabel) string {
+	for _, label := range labels {
+		if label.Lang == "eng" {
+			return label.Text
+		}
+	}
+	if len(labels) > 0 {
+		return labels[0].Text
+	}
+	return ""
+}
+
+// catalogKey lower-cases a catalog label and collapses its spaces, as the
+// data files key authors and works
+func catalogKey(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+// catalogExemplar returns the exemplar to record for a work whose editions
+// in the catalog do not include the usual one, preferring the latest
+// Perseus edition
+func catalogExemplar(work ctsWork, usual string) (string, bool) {
+	var exemplars []string
+	for _, edition := range work.Editions {
+		exemplar := edition.URN[strings.LastIndex(edition.URN, ".")+1:]
+		if exemplar == usual {
+			return "", false
+		}
+		exemplars = append(exemplars, exemplar)
+	}
+	if len(exemplars) == 0 {
+		return "", false
+	}
+	best := exemplars[0]
+	for _, exemplar := range exemplars {
+		if strings.HasPrefix(exemplar, "perseus-") && (!strings.HasPrefix(best, "perseus-") || exemplar > best) {
+			best = exemplar
+		}
+	}
+	return best, true
+}
+
+// catalogScheme returns the citation scheme of a work's edition with the
+// given exemplar, its citation levels joined as "book.line", or "" if the
+// catalog gives none. Only the first citation at each level is followed, as
+// editions giving several list the usual one first.
+func catalogScheme(work ctsWork, exemplar string) string {
+	for _, edition := range work.Editions {
+		if !strings.HasSuffix(edition.URN, "."+exemplar) {
+			continue
+		}
+		var levels []string
+		for citations := edition.Citations; len(citations) > 0; citations = citations[0].Citations {
+			label := strings.ToLower(strings.TrimSpace(citations[0].Label))
+			if label == "" {
+				return ""
+			}
+			levels = append(levels, label)
+		}
+		return strings.Join(levels, ".")
+	}
+	return ""
+}
+
+func (s *catalogSync) writeText(out io.Writer) {
+	fmt.Fprintf(out, "Text groups: %d (%d in namespaces not synced)\n", s.TextGroups, s.Skipped)
+	counts := make(map[string]int)
+	for _, change := range s.Changes {
+		counts[change.Kind]++
+	}
+	fmt.Fprintf(out, "%d authors, %d works, %d exemplars and %d schemes added, %d conflicts\n",
+		counts["author"], counts["work"], counts["exemplar"], counts["scheme"], counts["conflict"])
+
+	byFile := make(map[string][]catalogChange)
+	var names []string
+	for _, change := range s.Changes {
+		if change.Kind == "conflict" {
+			continue
+		}
+		if byFile[change.File] == nil {
+			names = append(names, change.File)
+		}
+		byFile[change.File] = append(byFile[change.File], change)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "\n%s\n", name)
+		for _, change := range byFile[name] {
+			switch change.Kind {
+			case "author":
+				fmt.Fprintf(out, "  + author %q: %s\n", change.Key, change.Value)
+			case "work":
+				fmt.Fprintf(out, "  + work %s %q: %s\n", change.Author, change.Key, change.Value)
+			case "single-work author":
+				fmt.Fprintf(out, "  + single-work author %q\n", change.Key)
+			case "exemplar":
+				fmt.Fprintf(out, "  + exemplar %s: %s\n", change.Key, change.Value)
+			case "scheme":
+				fmt.Fprintf(out, "  + scheme %s %s: %s\n", change.Author, change.Key, change.Value)
+			}
+		}
+	}
+	if counts["conflict"] > 0 {
+		fmt.Fprintln(out, "\nconflicts (not added)")
+		for _, change := range s.Changes {
+			if change.Kind == "conflict" {
+				fmt.Fprintf(out, "  ! %s %q: %s\n", strings.TrimSpace(change.File+" "+change.Author), change.Key, change.Value)
+			}
+		}
+	}
+}
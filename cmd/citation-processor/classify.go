@@ -0,0 +1,83 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// The types of citation, in the citation_type field
+const (
+	typeQuotation      = "quotation"       // the words cited are given, in a <quote>
+	typeReference      = "reference"       // a bare reference
+	typeCrossReference = "cross-reference" // introduced by "cf.", "see", "vid." and the like
+	typeParallel       = "parallel"        // a parallel passage for the one commented on
+)
+
+const (
+	// typeMarkupWindow is the bytes before a citation searched for an
+	// enclosing element marked as a parallel
+	typeMarkupWindow = 500
+
+	// typeCueWindow is the bytes of text before a citation searched
+	// for the cue that introduces it
+	typeCueWindow = 32
+)
+
+var (
+	// parallelTagRegex matches the start tag of an element marked as giving
+	// parallel passages, as <cit type="parallel"> or <note type="comparandum">
+	parallelTagRegex = regexp.MustCompile(`(?i)<(\w+)\b[^>]*\btype\s*=\s*"(?:parallels?|comparand(?:um|a)|comparison)"[^>]*>`)
+
+	// parallelCueRegex matches the words that introduce a parallel passage
+	// in a commentary, at the end of the text before the citation, as in
+	// "so Ant. 5", "similarly Eur. Med. 12" or "= Il. 2.3"
+	parallelCueRegex = regexp.MustCompile(`(?i)(?:\b(?:so(?: also| too)?|similarly|likewise|imitated by|echoed (?:by|in)|parallel(?:ed)? (?:by|in|at)|the same (?:\w+ )?(?:in|at))|=)[\s,:]*$`)
+)
+
+// citationType classifies a citation for filtering by type, from its
+// markup and the text before it in the document. Markup marking it as a
+// parallel comes first, then a quotation, then the cue before it:
+// cross-references take those of proseCueRegex, parallels those of
+// parallelCueRegex; anything else is a bare reference.
+func citationType(text string, citation Citation) string {
+	start, end := citation.span.start, citation.span.end
+	located := 0 <= start && start < end && end <= len(text)
+	if located && inParallelMarkup(text, start, end) {
+		return typeParallel
+	}
+	if citation.Quote != "" {
+		return typeQuotation
+	}
+	if !located {
+		return typeReference
+	}
+	before := stripContextTags(text[max(0, start-typeMarkupWindow):start])
+	before = before[max(0, len(before)-typeCueWindow):]
+	switch {
+	case proseCueRegex.MatchString(before):
+		return typeCrossReference
+	case parallelCueRegex.MatchString(before):
+		return typeParallel
+	}
+	return typeReference
+}
+
+// inParallelMarkup reports whether the element at text[start:end] is, or is
+// inside, an element marked as giving parallel passages
+func inParallelMarkup(text string, start, end int) bool {
+	if loc := parallelTagRegex.FindStringIndex(text[start:end]); loc != nil && loc[0] == 0 {
+		return true
+	}
+	window := text[max(0, start-typeMarkupWindow):start]
+	matches := parallelTagRegex.FindAllStringSubmatchIndex(window, -1)
+	if len(matches) == 0 {
+		return false
+	}
+	last := matches[len(matches)-1]
+	if strings.HasSuffix(window[last[0]:last[1]], "/>") {
+		return false
+	}
+	// the element is still open if it has not been closed since
+	name := window[last[2]:last[3]]
+	return !strings.Contains(window[last[1]:], "</"+name+">")
+}
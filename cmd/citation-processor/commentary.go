@@ -0,0 +1,133 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"perseus_citation_linker/pkg/cts"
+)
+
+var (
+	// divTagRegex matches the opening and closing tags of <div> elements,
+	// numbered or not
+	divTagRegex = regexp.MustCompile(`<(/?)div\d?\b[^>]*?(/?)>`)
+
+	// lineRangeHeadRegex matches a heading giving only the lines a section
+	// comments on, such as "Lines 1–100", "On ll. 441-525" or "vv. 1-99"
+	lineRangeHeadRegex = regexp.MustCompile(`(?i)^(?:(?:notes\s+|commentary\s+)?on\s+)?(lines?|ll\.|vv?\.|verses?|vss?\.)?\s*(\d+[a-e]?(?:\.\d+[a-e]?)*)(?:\s*-\s*(\d+[a-e]?(?:\.\d+[a-e]?)*))?\.?$`)
+
+	// rangeDashes are the dashes written between the ends of a range
+	rangeDashes = strings.NewReplacer("–", "-", "—", "-", "‒", "-")
+)
+
+// commentedSpan is the extent of a <div> whose heading names the passage of
+// the base text it comments on, and the URN of that passage
+type commentedSpan struct {
+	start, end int
+	urn        string
+}
+
+// commentedSpans finds the sections of a commentary keyed to ranges of
+// its base text, in document order. A section's heading is the
+// first <head> directly in its <div>. The heading may cite the passage in
+// full ("Soph. Ant. 441–525", "Antigone 441–525"), or give only the lines
+// ("Lines 441–525"), which are then taken to be of the commented work:
+// Config.CommentedWork if set, else the work of the last heading that cited
+// one in full.
+func (cp *CitationProcessor) commentedSpans(xmlContent, filename string) []commentedSpan {
+	if len(cp.headings) == 0 || !strings.Contains(xmlContent, "<div") {
+		return nil
+	}
+	type openDiv struct {
+		start  int
+		headed bool   // its first heading has been seen
+		urn    string // of the passage its heading names
+	}
+	var sections []commentedSpan
+	var stack []openDiv
+	work := cp.Config.CommentedWork
+	headings := cp.headings
+	for _, match := range divTagRegex.FindAllStringSubmatchIndex(xmlContent, -1) {
+		// headings before this tag belong to the innermost open div, and are
+		// read in document order so that each sees the work named before it
+		for len(headings) > 0 && headings[0].start < match[0] {
+			if n := len(stack); n > 0 && !stack[n-1].headed {
+				stack[n-1].headed = true
+				stack[n-1].urn, work = cp.commentedRange(headings[0].text, work, filename)
+			}
+			headings = headings[1:]
+		}
+		closing := match[3] > match[2]
+		selfClosing := match[5] > match[4]
+		switch {
+		case selfClosing:
+		case !closing:
+			stack = append(stack, openDiv{start: match[0]})
+		case len(stack) > 0:
+			div := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if div.urn != "" {
+				sections = append(sections, commentedSpan{start: div.start, end: match[1], urn: div.urn})
+			}
+		}
+	}
+	// sections are found as they close, inner before outer
+	sort.Slice(sections, func(i, j int) bool { return sections[i].start < sections[j].start })
+	return sections
+}
+
+// commentedRange returns the URN of the passage a section heading names, if
+// any, and the commented work from then on
+func (cp *CitationProcessor) commentedRange(head, work, filename string) (urn, nextWork string) {
+	head = rangeDashes.Replace(head)
+	if match := lineRangeHeadRegex.FindStringSubmatch(head); match != nil {
+		// a bare number is a chapter or a page as often as a line
+		if work == "" || (match[1] == "" && match[3] == "") {
+			return "", work
+		}
+		passage := match[2]
+		if match[3] != "" {
+			passage += "-" + match[3]
+		}
+		return work + ":" + passage, work
+	}
+
+	refs := []string{head}
+	if cp.titleProvider == nil {
+		cp.titleProvider = newWorkTitleProvider(cp.Resolver.Data)
+	}
+	for _, match := range cp.titleProvider.FindMatches(head) {
+		// the provider's match ends with the range's start
+		refs = append(refs, match.Ref+strings.ToLower(head[match.End:]))
+	}
+	for _, ref := range refs {
+		if ref = cp.Resolver.GetRef("", ref); ref == "" {
+			continue
+		}
+		res := cp.Resolver.Resolve(ref, "", filename)
+		if res.URN == "" || res.Fallback {
+			continue
+		}
+		if parsed, err := cts.Parse(res.URN); err == nil && parsed.Passage.IsRange() {
+			parsed.Passage = cts.Passage{}
+			return res.URN, parsed.String()
+		}
+	}
+	return "", work
+}
+
+// commentedAt returns the URN of the passage commented on by the innermost
+// section containing the byte offset, or "" if there is none
+func (cp *CitationProcessor) commentedAt(offset int) string {
+	urn, start := "", -1
+	for _, section := range cp.commented {
+		if section.start > offset {
+			break
+		}
+		if offset < section.end && section.start > start {
+			urn, start = section.urn, section.start
+		}
+	}
+	return urn
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// compatRecord is a citation record from either pipeline, kept as generic JSON
+// so that fields only one implementation writes are compared too
+type compatRecord map[string]any
+
+// compatExample is one differing value, identified by its match key
+type compatExample struct {
+	Key    string `json:"key"`
+	Python any    `json:"python"`
+	Go     any    `json:"go"`
+}
+
+// compatFieldDiff counts the matched records on which a field differs
+type compatFieldDiff struct {
+	Field    string          `json:"field"`
+	Count    int             `json:"count"`
+	Examples []compatExample `json:"examples,omitempty"`
+}
+
+// compatReport summarizes where the Go output diverges from the Python output
+type compatReport struct {
+	PythonRecords      int               `json:"python_records"`
+	GoRecords          int               `json:"go_records"`
+	Matched            int               `json:"matched"`
+	Identical          int               `json:"identical"`
+	OnlyPython         []string          `json:"only_python,omitempty"`
+	OnlyGo             []string          `json:"only_go,omitempty"`
+	ResolvedOnlyPython int               `json:"resolved_only_python"`
+	ResolvedOnlyGo     int               `json:"resolved_only_go"`
+	Fields             []compatFieldDiff `json:"fields"`
+}
+
+// runCompatCheck implements the compat-check subcommand, which compares the
+// legacy Python pipeline's output for a corpus with this implementation's
+func runCompatCheck(args []string) error {
+	fs := flag.NewFlagSet("compat-check", flag.ExitOnError)
+	pythonPath := fs.String("python", "", "Python pipeline output: a JSONL or JSON array file, or a directory of them")
+	goPath := fs.String("go", "", "Go output: a JSONL file or an output directory")
+	ignore := fs.String("ignore", "doc_cit_urn", "Comma-separated fields to leave out of the comparison")
+	examples := fs.Int("examples", 5, "Differing values to show per field")
+	asJSON := fs.Bool("json", false, "Write the report as JSON")
+	fs.Parse(args)
+
+	if *pythonPath == "" || *goPath == "" {
+		return fmt.Errorf("both -python and -go are required")
+	}
+	pythonRecords, err := loadCompatRecords(*pythonPath)
+	if err != nil {
+		return err
+	}
+	goRecords, err := loadCompatRecords(*goPath)
+	if err != nil {
+		return err
+	}
+
+	ignored := make(map[string]bool)
+	for _, field := range strings.Split(*ignore, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			ignored[field] = true
+		}
+	}
+	report := compareCompatRecords(pythonRecords, goRecords, ignored, *examples)
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	report.writeText(os.Stdout, *examples)
+	return nil
+}
+
+// loadCompatRecords reads records from a file, or from every .jsonl and .json
+// file in a directory
+func loadCompatRecords(path string) ([]compatRecord, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		jsonl, _ := filepath.Glob(filepath.Join(path, "*.jsonl"))
+		jsonFiles, _ := filepath.Glob(filepath.Join(path, "*.json"))
+		files = append(jsonl, jsonFiles...)
+		sort.Strings(files)
+	}
+
+	var records []compatRecord
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		content = bytes.TrimSpace(content)
+		if len(content) > 0 && content[0] == '[' {
+			var array []compatRecord
+			if err := json.Unmarshal(content, &array); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+			}
+			records = append(records, array...)
+			continue
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		scanner.Buffer(make([]byte, 1024*1024), 10*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			var record compatRecord
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				return nil, fmt.Errorf("failed to parse %s line %d: %w", file, line, err)
+			}
+			records = append(records, record)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+	}
+	return records, nil
+}
+
+// compatKeys keys records by file, n attribute and bibl, numbering repeats so
+// that the nth occurrence on one side matches the nth on the other. The
+// doc_cit_urn counters of the two pipelines do not line up, so are not used.
+func compatKeys(records []compatRecord) map[string]compatRecord {
+	keyed := make(map[string]compatRecord, len(records))
+	seen := make(map[string]int)
+	for _, record := range records {
+		if filename, ok := record["filename"].(string); ok {
+			record["filename"] = filepath.Base(filename)
+		}
+		base := fmt.Sprintf("%v|%v|%v", record["filename"], record["n_attrib"], record["bibl"])
+		seen[base]++
+		keyed[fmt.Sprintf("%s#%d", base, seen[base])] = record
+	}
+	return keyed
+}
+
+func compareCompatRecords(pythonRecords, goRecords []compatRecord, ignored map[string]bool, maxExamples int) *compatReport {
+	report := &compatReport{PythonRecords: len(pythonRecords), GoRecords: len(goRecords)}
+	pythonKeyed := compatKeys(pythonRecords)
+	goKeyed := compatKeys(goRecords)
+
+	keys := make([]string, 0, len(pythonKeyed))
+	for key := range pythonKeyed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	diffs := make(map[string]*compatFieldDiff)
+	for _, key := range keys {
+		pythonRecord := pythonKeyed[key]
+		goRecord, exists := goKeyed[key]
+		if !exists {
+			report.OnlyPython = append(report.OnlyPython, key)
+			continue
+		}
+		report.Matched++
+
+		pythonURN, _ := pythonRecord["urn"].(string)
+		goURN, _ := goRecord["urn"].(string)
+		if pythonURN != "" && goURN == "" {
+			report.ResolvedOnlyPython++
+		} else if pythonURN == "" && goURN != "" {
+			report.ResolvedOnlyGo++
+		}
+
+		identical := true
+		for _, field := range unionFields(pythonRecord, goRecord) {
+			if ignored[field] || reflect.DeepEqual(pythonRecord[field], goRecord[field]) {
+				continue
+			}
+			identical = false
+			diff := diffs[field]
+			if diff == nil {
+				diff = &compatFieldDiff{Field: field}
+				diffs[field] = diff
+			}
+			diff.Count++
+			if len(diff.Examples) < maxExamples {
+				diff.Examples = append(diff.Examples, compatExample{Key: key, Python: pythonRecord[field], Go: goRecord[field]})
+			}
+		}
+		if identical {
+			report.Identical++
+		}
+	}
+	for key := range goKeyed {
+		if _, exists := pythonKeyed[key]; !exists {
+			report.OnlyGo = append(report.OnlyGo, key)
+		}
+	}
+	sort.Strings(report.OnlyGo)
+
+	report.Fields = make([]compatFieldDiff, 0, len(diffs))
+	for _, diff := range diffs {
+		report.Fields = append(report.Fields, *diff)
+	}
+	sort.Slice(report.Fields, func(i, j int) bool {
+		if report.Fields[i].Count != report.Fields[j].Count {
+			return report.Fields[i].Count > report.Fields[j].Count
+		}
+		return report.Fields[i].Field < report.Fields[j].Field
+	})
+	return report
+}
+
+// unionFields returns the fields present in either record, sorted
+func unionFields(a, b compatRecord) []string {
+	seen := make(map[string]bool)
+	var fields []string
+	for _, record := range []compatRecord{a, b} {
+		for field := range record {
+			if !seen[field] {
+				seen[field] = true
+				fields = append(fields, field)
+			}
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func (r *compatReport) writeText(out io.Writer, maxExamples int) {
+	fmt.Fprintf(out, "Python records: %d\nGo records:     %d\n", r.PythonRecords, r.GoRecords)
+	fmt.Fprintf(out, "Matched:        %d (%d identical)\n", r.Matched, r.Identical)
+	fmt.Fprintf(out, "Only in Python: %d\nOnly in Go:     %d\n", len(r.OnlyPython), len(r.OnlyGo))
+	fmt.Fprintf(out, "Resolved only by Python: %d\nResolved only by Go:     %d\n", r.ResolvedOnlyPython, r.ResolvedOnlyGo)
+
+	writeKeys := func(title string, keys []string) {
+		if len(keys) == 0 {
+			return
+		}
+		fmt.Fprintf(out, "\n%s:\n", title)
+		for i, key := range keys {
+			if i == maxExamples {
+				fmt.Fprintf(out, "  ... %d more\n", len(keys)-maxExamples)
+				break
+			}
+			fmt.Fprintf(out, "  %s\n", key)
+		}
+	}
+	writeKeys("Only in Python", r.OnlyPython)
+	writeKeys("Only in Go", r.OnlyGo)
+
+	if len(r.Fields) == 0 {
+		fmt.Fprintln(out, "\nNo field differences in matched records")
+		return
+	}
+	fmt.Fprintln(out, "\nField differences in matched records:")
+	for _, diff := range r.Fields {
+		fmt.Fprintf(out, "  %s: %d\n", diff.Field, diff.Count)
+		for _, example := range diff.Examples {
+			fmt.Fprintf(out, "    %s\n      python: %v\n      go:     %v\n", example.Key, example.Python, example.Go)
+		}
+	}
+}
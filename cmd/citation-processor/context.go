@@ -0,0 +1,262 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultContextSize is the number of characters of context kept either side
+// of a citation when ContextOptions.Size is not set
+const defaultContextSize = 500
+
+// truncatedContextSize and truncatedQuoteLength bound the context and quote
+// under the "truncate" context policy
+const (
+	truncatedContextSize = 50
+	truncatedQuoteLength = 100
+)
+
+// ContextOptions controls how the xml_context of a citation is built
+type ContextOptions struct {
+	Size      int    // characters either side of the citation, the maximum when Expand is set
+	StripTags bool   // remove markup and decode entities
+	Expand    string // "", "sentence" or "parent": cut the context at the enclosing boundary
+	Policy    string // "", "verbatim", "truncate", "hash" or "omit": how contexts and quotes are emitted
+}
+
+// validate checks the Expand mode and the Policy
+func (o ContextOptions) validate() error {
+	switch o.Expand {
+	case "", "none", "sentence", "parent":
+	default:
+		return fmt.Errorf("unknown context expansion %q (want none, sentence or parent)", o.Expand)
+	}
+	switch o.Policy {
+	case "", "verbatim", "truncate", "hash", "omit":
+	default:
+		return fmt.Errorf("unknown context policy %q (want verbatim, truncate, hash or omit)", o.Policy)
+	}
+	return nil
+}
+
+func (o ContextOptions) size() int {
+	size := defaultContextSize
+	if o.Size > 0 {
+		size = o.Size
+	}
+	if o.Policy == "truncate" {
+		size = min(size, truncatedContextSize)
+	}
+	return size
+}
+
+// redact applies the context policy to the xml_context and quote of a
+// citation, for corpora whose licences do not allow long verbatim extracts to
+// be redistributed. Truncated contexts were already cut by size; a hash lets
+// identical passages be matched without the text itself.
+func (o ContextOptions) redact(citation *Citation) {
+	switch o.Policy {
+	case "truncate":
+		citation.Quote = truncateText(citation.Quote, truncatedQuoteLength)
+	case "hash":
+		citation.XMLContext = hashText(citation.XMLContext)
+		citation.Quote = hashText(citation.Quote)
+	case "omit":
+		citation.XMLContext = ""
+		citation.Quote = ""
+	}
+}
+
+// truncateText cuts text to at most limit characters at a word boundary,
+// marking the cut with an ellipsis
+func truncateText(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	cut := string(runes[:limit])
+	if space := strings.LastIndex(cut, " "); space > 0 {
+		cut = cut[:space]
+	}
+	return strings.TrimSpace(cut) + "…"
+}
+
+// hashText replaces text by its SHA-256 digest, leaving empty text empty
+func hashText(text string) string {
+	if text == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(text))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+var (
+	whitespaceRegex = regexp.MustCompile(`\s+`)
+	// sentence-final punctuation followed, possibly across tags, by a capital
+	sentenceEndRegex = regexp.MustCompile(`[.!?·](?:\s|<[^>]*>)+\p{Lu}`)
+	// tags cut in half at the edges of the context window
+	partialTagStartRegex = regexp.MustCompile(`^[^<]*>`)
+	partialTagEndRegex   = regexp.MustCompile(`<[^>]*$`)
+)
+
+func (cp *CitationProcessor) extractContext(xmlContent, biblMatch string) string {
+	index := strings.Index(xmlContent, biblMatch)
+	if index == -1 {
+		return ""
+	}
+	return cp.contextAt(xmlContent, index, index+len(biblMatch))
+}
+
+// contextAt returns the context of the citation element at the byte offsets
+// [index, end), or "" if it was not located
+func (cp *CitationProcessor) contextAt(xmlContent string, index, end int) string {
+	if index < 0 {
+		return ""
+	}
+	opts := cp.Config.Context
+
+	start, stop := contextWindow(xmlContent, index, end, opts.size())
+	switch opts.Expand {
+	case "sentence":
+		start, stop = sentenceBounds(xmlContent, index, end, start, stop)
+	case "parent":
+		start, stop = parentBounds(xmlContent, index, end, start, stop)
+	}
+
+	context := xmlContent[start:stop]
+	if opts.StripTags {
+		context = stripContextTags(context)
+	}
+	// Clean up whitespace
+	context = whitespaceRegex.ReplaceAllString(context, " ")
+	return strings.TrimSpace(context)
+}
+
+// maxEntityLength is the longest character entity, such as "&thetasym;",
+// looked for at the edges of a context window
+const maxEntityLength = 10
+
+// contextWindow returns the window of size characters either side of the
+// citation at [start, end). The window is counted in runes, so it never cuts
+// a multi-byte character such as a Greek letter in half, and is then
+// narrowed so that it does not begin or end inside a tag or an entity.
+func contextWindow(xmlContent string, start, end, size int) (int, int) {
+	windowStart := start
+	for n := 0; n < size && windowStart > 0; n++ {
+		_, width := utf8.DecodeLastRuneInString(xmlContent[:windowStart])
+		windowStart -= width
+	}
+	windowEnd := end
+	for n := 0; n < size && windowEnd < len(xmlContent); n++ {
+		_, width := utf8.DecodeRuneInString(xmlContent[windowEnd:])
+		windowEnd += width
+	}
+
+	// a tag or entity cut at the start is dropped, up to its last byte
+	before := xmlContent[windowStart:start]
+	if close := strings.IndexByte(before, '>'); close >= 0 && !strings.Contains(before[:close], "<") {
+		windowStart += close + 1
+	} else if semicolon := strings.IndexByte(before, ';'); semicolon >= 0 && semicolon < maxEntityLength {
+		lead := xmlContent[max(0, windowStart-maxEntityLength):windowStart]
+		if amp := strings.LastIndexByte(lead, '&'); amp >= 0 && entityTailRegex.MatchString(lead[amp+1:]+before[:semicolon]) {
+			windowStart += semicolon + 1
+		}
+	}
+	// and one cut at the end from its first
+	after := xmlContent[end:windowEnd]
+	if open := strings.LastIndexByte(after, '<'); open >= 0 && !strings.Contains(after[open:], ">") {
+		windowEnd = end + open
+	} else if amp := strings.LastIndexByte(after, '&'); amp >= 0 && len(after)-amp < maxEntityLength &&
+		entityTailRegex.MatchString(after[amp+1:]) {
+		windowEnd = end + amp
+	}
+	return windowStart, windowEnd
+}
+
+// entityTailRegex matches the part of an entity between "&" and ";", or
+// what a window cut keeps of it
+var entityTailRegex = regexp.MustCompile(`^#?[A-Za-z0-9]*$`)
+
+// sentenceBounds narrows the window [windowStart, windowEnd) to the sentence
+// containing the citation at [start, end). Abbreviations followed by a capital,
+// as in "Soph. El.", also end a sentence, so this is only a heuristic.
+func sentenceBounds(xmlContent string, start, end, windowStart, windowEnd int) (int, int) {
+	before := sentenceEndRegex.FindAllStringIndex(xmlContent[windowStart:start], -1)
+	if len(before) > 0 {
+		windowStart += before[len(before)-1][0] + 1
+	}
+	if after := sentenceEndRegex.FindStringIndex(xmlContent[end:windowEnd]); after != nil {
+		windowEnd = end + after[0] + 1
+	}
+	return windowStart, windowEnd
+}
+
+// parentBounds narrows the window [windowStart, windowEnd) to the element
+// enclosing the citation at [start, end). Only tags inside the window are
+// considered, so a parent that opens or closes outside it is cut at the window.
+func parentBounds(xmlContent string, start, end, windowStart, windowEnd int) (int, int) {
+	type openTag struct {
+		name   string
+		offset int
+	}
+	window := xmlContent[windowStart:windowEnd]
+	locs := tagRegex.FindAllStringSubmatchIndex(window, -1)
+
+	var stack []openTag
+	i := 0
+	for ; i < len(locs) && windowStart+locs[i][0] < start; i++ {
+		loc := locs[i]
+		if loc[4] < 0 {
+			// comment or processing instruction
+			continue
+		}
+		name := window[loc[4]:loc[5]]
+		if loc[3] > loc[2] {
+			for len(stack) > 0 {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				if top.name == name {
+					break
+				}
+			}
+			continue
+		}
+		if loc[7] == loc[6] {
+			stack = append(stack, openTag{name: name, offset: windowStart + loc[0]})
+		}
+	}
+	if len(stack) == 0 {
+		return windowStart, windowEnd
+	}
+
+	parent := stack[len(stack)-1]
+	depth := 0
+	for ; i < len(locs); i++ {
+		loc := locs[i]
+		if windowStart+loc[0] < end || loc[4] < 0 || window[loc[4]:loc[5]] != parent.name {
+			continue
+		}
+		if loc[3] > loc[2] {
+			if depth == 0 {
+				return parent.offset, windowStart + loc[1]
+			}
+			depth--
+		} else if loc[7] == loc[6] {
+			depth++
+		}
+	}
+	return parent.offset, windowEnd
+}
+
+// stripContextTags removes markup, including tags cut off at either edge of
+// the context window, and decodes entities
+func stripContextTags(context string) string {
+	context = partialTagStartRegex.ReplaceAllString(context, "")
+	context = partialTagEndRegex.ReplaceAllString(context, "")
+	context = tagRegex.ReplaceAllString(context, "")
+	return decodeText(context)
+}
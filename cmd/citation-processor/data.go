@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"perseus_citation_linker/pkg/loader"
+)
+
+// expansionSnapshotFile is the snapshot of the expanded work tables in the
+// data directory, which `data expand -diff` compares against
+const expansionSnapshotFile = "work_expansion.tsv"
+
+// runData implements the data subcommand, whose own subcommands inspect the
+// data files as the processor loads them
+func runData(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing command (want expand or info)")
+	}
+	switch args[0] {
+	case "expand":
+		return runDataExpand(args[1:])
+	case "info":
+		return runDataInfo(args[1:])
+	}
+	return fmt.Errorf("unknown command %q (want expand or info)", args[0])
+}
+
+// runDataInfo prints what each data pack holds, as -print-data-summary does
+// before a run
+func runDataInfo(args []string) error {
+	fs := flag.NewFlagSet("data info", flag.ExitOnError)
+	dataDir := fs.String("data", "", "Data directory (default: discovered as for processing runs)")
+	fs.Parse(args)
+
+	if *dataDir == "" {
+		*dataDir = loader.FindDataDir()
+	}
+	data, err := loader.LoadComprehensiveDataDir(*dataDir)
+	if err != nil {
+		return err
+	}
+	writeDataSummary(stdout, *dataDir, data.Summary())
+	return nil
+}
+
+// writeDataSummary writes a line per data pack and abbreviation layer: its
+// authors, works, author abbreviations, schema version and checksum
+func writeDataSummary(out io.Writer, dataDir string, packs []loader.PackSummary) {
+	// the columns widen for a layer's longer name and file
+	packWidth, fileWidth := 6, 16
+	for _, pack := range packs {
+		packWidth = max(packWidth, len(pack.Pack))
+		fileWidth = max(fileWidth, len(pack.File))
+	}
+	fmt.Fprintf(out, "data directory: %s\n", dataDir)
+	fmt.Fprintf(out, "%-*s %-*s %7s %6s %13s %6s  %s\n", packWidth, "pack", fileWidth, "file", "authors", "works", "abbreviations", "schema", "checksum")
+	for _, pack := range packs {
+		fmt.Fprintf(out, "%-*s %-*s %7d %6d %13d %6d  %s\n",
+			packWidth, pack.Pack, fileWidth, pack.File, pack.Authors, pack.Works, pack.Abbreviations, pack.SchemaVersion, pack.Checksum)
+	}
+}
+
+// runDataExpand prints the work tables with their generated abbreviations,
+// or with -diff the changes from the snapshot, so that changes to the
+// abbreviation generation can be reviewed for new collisions before release
+func runDataExpand(args []string) error {
+	fs := flag.NewFlagSet("data expand", flag.ExitOnError)
+	dataDir := fs.String("data", "", "Data directory (default: discovered as for processing runs)")
+	snapshot := fs.String("snapshot", "", "Expansion snapshot (default: "+expansionSnapshotFile+" in the data directory)")
+	diff := fs.Bool("diff", false, "Print the changes from the snapshot rather than the full expansion")
+	update := fs.Bool("update", false, "Replace the snapshot with the current expansion")
+	fs.Parse(args)
+
+	if *dataDir == "" {
+		*dataDir = loader.FindDataDir()
+	}
+	if *snapshot == "" {
+		*snapshot = filepath.Join(*dataDir, expansionSnapshotFile)
+	}
+	data, err := loader.LoadComprehensiveDataDir(*dataDir)
+	if err != nil {
+		return err
+	}
+	current := expansionLines(data.WorkExpansion())
+
+	switch {
+	case *diff:
+		previous, err := readExpansionSnapshot(*snapshot)
+		if err != nil {
+			return err
+		}
+		writeExpansionDiff(stdout, previous, current)
+	case !*update:
+		for _, line := range current {
+			fmt.Fprintln(stdout, line)
+		}
+	}
+
+	if *update {
+		content := strings.Join(current, "\n") + "\n"
+		if err := os.WriteFile(*snapshot, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", *snapshot, err)
+		}
+		fmt.Fprintf(os.Stderr, "wrote %d entries to %s\n", len(current), *snapshot)
+	}
+	return nil
+}
+
+func expansionLines(entries []loader.ExpansionEntry) []string {
+	lines := make([]string, len(entries))
+	for i, entry := range entries {
+		lines[i] = entry.String()
+	}
+	return lines
+}
+
+// readExpansionSnapshot reads the lines of a snapshot; a missing snapshot is
+// empty, so the first diff lists the whole expansion as added
+func readExpansionSnapshot(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", path, err)
+	}
+	defer file.Close()
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", path, err)
+	}
+	return lines, nil
+}
+
+// expansionKey is the author and key that identify a line of the expansion
+func expansionKey(line string) string {
+	fields := strings.SplitN(line, "\t", 3)
+	if len(fields) < 2 {
+		return line
+	}
+	return fields[0] + "\t" + fields[1]
+}
+
+// expansionCollides reports whether a line of the expansion has several
+// candidate works
+func expansionCollides(line string) bool {
+	fields := strings.Split(line, "\t")
+	return len(fields) == 5 && fields[4] != ""
+}
+
+// writeExpansionDiff writes the lines removed from and added to the
+// expansion, "-" then "+" for each changed key, in key order, followed by a
+// summary and the keys that newly refer to several works
+func writeExpansionDiff(out io.Writer, previous, current []string) {
+	before := make(map[string]string, len(previous))
+	for _, line := range previous {
+		before[expansionKey(line)] = line
+	}
+	after := make(map[string]string, len(current))
+	for _, line := range current {
+		after[expansionKey(line)] = line
+	}
+
+	var keys []string
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		if _, exists := before[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var added, removed, changed int
+	var collisions []string
+	for _, key := range keys {
+		old, hadKey := before[key]
+		line, hasKey := after[key]
+		switch {
+		case !hasKey:
+			removed++
+			fmt.Fprintf(out, "-%s\n", old)
+		case !hadKey:
+			added++
+			fmt.Fprintf(out, "+%s\n", line)
+		case old != line:
+			changed++
+			fmt.Fprintf(out, "-%s\n+%s\n", old, line)
+		default:
+			continue
+		}
+		if hasKey && expansionCollides(line) && !(hadKey && expansionCollides(old)) {
+			collisions = append(collisions, line)
+		}
+	}
+
+	fmt.Fprintf(out, "\n%d added, %d removed, %d changed\n", added, removed, changed)
+	if len(collisions) > 0 {
+		fmt.Fprintf(out, "%d new collisions:\n", len(collisions))
+		for _, line := range collisions {
+			fmt.Fprintf(out, "  %s\n", line)
+		}
+	}
+}
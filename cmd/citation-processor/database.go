@@ -0,0 +1,80 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+// DocumentRows is a cursor over documents held in a database rather than in
+// files, as *sql.Rows is for a query selecting an id and an XML column, e.g.
+// "SELECT id, tei FROM documents"
+type DocumentRows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Err() error
+}
+
+// ProcessRows processes each row of rows as ProcessReader does a document,
+// taking the first column as the document's id and the second as its XML.
+// The id stands for the file name in the output and is attached to each
+// citation as document_id, so that results can be written back against the
+// row they came from, e.g. with an SQLWriter. A row that fails is logged
+// and skipped, as a file is by ProcessAllXMLFiles; the error returned is
+// the cursor's.
+func (cp *CitationProcessor) ProcessRows(rows DocumentRows) error {
+	defer func() { cp.rowID = "" }()
+	for rows.Next() {
+		var id string
+		var content []byte
+		if err := rows.Scan(&id, &content); err != nil {
+			return fmt.Errorf("failed to read document row: %w", err)
+		}
+		slog.Info("processing row", "id", id)
+		cp.rowID = id
+		if err := cp.processDocument(string(content), id); err != nil {
+			slog.Error("error processing row", "id", id, "error", err)
+		}
+	}
+	return rows.Err()
+}
+
+// Execer runs a statement, as *sql.DB, *sql.Tx and *sql.Conn do
+type Execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// SQLWriter is a CitationWriter that inserts each citation into a database
+// with the id of the document it came from. As placeholders differ between
+// drivers, the statement is the caller's; it is given the document id, the
+// doc_cit_urn, the URN ("" if unresolved) and the citation as JSON, e.g.
+// "INSERT INTO citations (document_id, doc_cit_urn, urn, citation) VALUES
+// ($1, $2, $3, $4)".
+type SQLWriter struct {
+	db     Execer
+	insert string
+}
+
+func NewSQLWriter(db Execer, insert string) *SQLWriter {
+	return &SQLWriter{db: db, insert: insert}
+}
+
+func (w *SQLWriter) Write(citations []Citation) error {
+	for _, citation := range citations {
+		jsonData, err := json.Marshal(citation)
+		if err != nil {
+			return fmt.Errorf("failed to marshal citation: %w", err)
+		}
+		if _, err := w.db.Exec(w.insert, citation.DocumentID, citation.DocCitURN, citation.URN, string(jsonData)); err != nil {
+			return fmt.Errorf("failed to insert citation %s: %w", citation.DocCitURN, err)
+		}
+	}
+	return nil
+}
+
+// Close does nothing: each citation is inserted as it is written, and the
+// database is the caller's to close
+func (w *SQLWriter) Close() error {
+	return nil
+}
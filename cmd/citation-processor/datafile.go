@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// jsonObject is a JSON object that keeps the order of its keys, so that a
+// data file rewritten by a subcommand differs from the original only in the
+// entries changed
+type jsonObject struct {
+	keys   []string
+	values map[string]json.RawMessage
+}
+
+func newJSONObject() *jsonObject {
+	return &jsonObject{values: make(map[string]json.RawMessage)}
+}
+
+func (o *jsonObject) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return fmt.Errorf("not a JSON object")
+	}
+	o.keys, o.values = nil, make(map[string]json.RawMessage)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		key := token.(string)
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return err
+		}
+		o.set(key, value)
+	}
+	_, err := decoder.Token()
+	return err
+}
+
+// get returns the value of key, nil if it is missing
+func (o *jsonObject) get(key string) json.RawMessage {
+	return o.values[key]
+}
+
+// set replaces the value of key, or adds it after the others
+func (o *jsonObject) set(key string, value json.RawMessage) {
+	if _, exists := o.values[key]; !exists {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// object returns the value of key as an object, an empty one if it is
+// missing
+func (o *jsonObject) object(key string) (*jsonObject, error) {
+	object := newJSONObject()
+	if value := o.get(key); value != nil {
+		if err := json.Unmarshal(value, object); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return object, nil
+}
+
+// setValue replaces the value of key with value encoded as JSON
+func (o *jsonObject) setValue(key string, value any) error {
+	encoded, err := marshalDataValue(value)
+	if err != nil {
+		return err
+	}
+	o.set(key, encoded)
+	return nil
+}
+
+// marshalDataValue encodes a value as the data files write it, with no
+// escaping of HTML characters
+func marshalDataValue(value any) (json.RawMessage, error) {
+	if object, ok := value.(*jsonObject); ok {
+		var buf bytes.Buffer
+		if err := object.write(&buf, 0, dataFileInline); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(buf.Bytes()), nil
+}
+
+// dataFileInline is the depth below which greek_data.json and
+// latin_data.json write their values on one line: the arrays of work tables,
+// such as the ranges of Demosthenes' orations
+const dataFileInline = 2
+
+// write writes the object indented by two spaces a level, as the data files
+// are, with the values nested more than inline levels deep on one line
+func (o *jsonObject) write(buf *bytes.Buffer, depth, inline int) error {
+	if len(o.keys) == 0 {
+		buf.WriteString("{}")
+		return nil
+	}
+	if depth > inline {
+		buf.WriteByte('{')
+		for i, key := range o.keys {
+			encodedKey, err := marshalDataValue(key)
+			if err != nil {
+				return err
+			}
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.Write(encodedKey)
+			buf.WriteString(": ")
+			if err := writeDataValue(buf, o.values[key], depth+1, inline); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	}
+	buf.WriteString("{\n")
+	for i, key := range o.keys {
+		encodedKey, err := marshalDataValue(key)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(strings.Repeat("  ", depth+1))
+		buf.Write(encodedKey)
+		buf.WriteString(": ")
+		if err := writeDataValue(buf, o.values[key], depth+1, inline); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		if i < len(o.keys)-1 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('\n')
+	}
+	buf.WriteString(strings.Repeat("  ", depth) + "}")
+	return nil
+}
+
+func writeDataValue(buf *bytes.Buffer, value json.RawMessage, depth, inline int) error {
+	value = bytes.TrimSpace(value)
+	switch {
+	case len(value) > 0 && value[0] == '{':
+		var object jsonObject
+		if err := json.Unmarshal(value, &object); err != nil {
+			return err
+		}
+		return object.write(buf, depth, inline)
+	case len(value) > 0 && value[0] == '[':
+		var elements []json.RawMessage
+		if err := json.Unmarshal(value, &elements); err != nil {
+			return err
+		}
+		if len(elements) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		if depth > inline {
+			buf.WriteByte('[')
+			for i, element := range elements {
+				if i > 0 {
+					buf.WriteString(", ")
+				}
+				if err := writeDataValue(buf, element, depth+1, inline); err != nil {
+					return err
+				}
+			}
+			buf.WriteByte(']')
+			return nil
+		}
+		buf.WriteString("[\n")
+		for i, element := range elements {
+			buf.WriteString(strings.Repeat("  ", depth+1))
+			if err := writeDataValue(buf, element, depth+1, inline); err != nil {
+				return err
+			}
+			if i < len(elements)-1 {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(strings.Repeat("  ", depth) + "]")
+		return nil
+	}
+	buf.Write(value)
+	return nil
+}
+
+// readDataObject reads a data file as an ordered object, an empty one if the
+// file does not exist
+func readDataObject(path string) (*jsonObject, error) {
+	object := newJSONObject()
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return object, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, object); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return object, nil
+}
+
+// writeDataObject writes a data file indented as greek_data.json and
+// latin_data.json are, with the values nested more than inline levels deep
+// on one line: dataFileInline for those files, 1 for citation_schemes.json.
+// Files laid out by hand otherwise, such as incipits.json, would be
+// reformatted.
+func writeDataObject(path string, object *jsonObject, inline int) error {
+	var buf bytes.Buffer
+	if err := object.write(&buf, 0, inline); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	buf.WriteByte('\n')
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
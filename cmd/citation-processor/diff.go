@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// diffCitation is a citation added, removed or resolved differently between
+// two runs
+type diffCitation struct {
+	Bibl      string `json:"bibl"`
+	NAttrib   string `json:"n_attrib,omitempty"`
+	DocCitURN string `json:"doc_cit_urn,omitempty"` // in the new run, or the old for removed citations
+	OldURN    string `json:"old_urn,omitempty"`
+	NewURN    string `json:"new_urn,omitempty"`
+}
+
+// fileDiff is how the citations of one input file differ between two runs
+type fileDiff struct {
+	Filename string         `json:"filename"`
+	Added    []diffCitation `json:"added,omitempty"`
+	Removed  []diffCitation `json:"removed,omitempty"`
+	Changed  []diffCitation `json:"changed,omitempty"` // URN changed, including resolved or unresolved only in one run
+}
+
+// corpusDiff compares the output of two runs over a corpus
+type corpusDiff struct {
+	OldRecords int        `json:"old_records"`
+	NewRecords int        `json:"new_records"`
+	Added      int        `json:"added"`
+	Removed    int        `json:"removed"`
+	Changed    int        `json:"changed"`
+	Files      []fileDiff `json:"files"`
+}
+
+// runDiff implements the diff subcommand, which reports the citations
+// added, removed and resolved to another URN between two runs, by file, for
+// reviewing the effect of resolver or data changes on a corpus
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Write the differences as JSON")
+	limit := fs.Int("limit", 0, "Citations to show per file and kind of change (0: all)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: citation-processor diff [flags] old new")
+		fmt.Fprintln(fs.Output(), "old and new are JSONL files or output directories of two runs")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("want an old and a new run, got %d arguments", fs.NArg())
+	}
+
+	oldRecords, err := loadDiffRecords(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	newRecords, err := loadDiffRecords(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	diff := diffRuns(oldRecords, newRecords)
+
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diff)
+	}
+	diff.writeText(stdout, *limit)
+	return nil
+}
+
+// diffOutputFiles are the files of an output directory that hold citations
+var diffOutputFiles = []string{"resolved.jsonl", "unresolved.jsonl", provisionalFile, secondaryFile}
+
+// loadDiffRecords reads the citations of a run from a JSONL or JSON array
+// file, or from the resolved, unresolved, provisional and secondary
+// citations of an output directory
+func loadDiffRecords(path string) ([]compatRecord, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return loadCompatRecords(path)
+	}
+	var records []compatRecord
+	found := false
+	for _, name := range diffOutputFiles {
+		file := filepath.Join(path, name)
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			continue
+		}
+		found = true
+		fileRecords, err := loadCompatRecords(file)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, fileRecords...)
+	}
+	if !found {
+		return nil, fmt.Errorf("no citation output in %s", path)
+	}
+	return records, nil
+}
+
+// diffKey identifies a citation across runs by file, n attribute and bibl,
+// numbering repeats so that the nth occurrence in one run matches the nth in
+// the other. doc_cit_urn is not used, as a citation added early in a file
+// renumbers every one after it.
+type diffKey struct {
+	filename, nAttrib, bibl string
+	occurrence              int
+}
+
+// keyedRecord is a citation of a run with its key and place in the run
+type keyedRecord struct {
+	key    diffKey
+	record compatRecord
+}
+
+func keyDiffRecords(records []compatRecord) ([]keyedRecord, map[diffKey]compatRecord) {
+	keyed := make([]keyedRecord, 0, len(records))
+	byKey := make(map[diffKey]compatRecord, len(records))
+	seen := make(map[diffKey]int)
+	for _, record := range records {
+		base := diffKey{filename: filepath.Base(recordString(record, "filename")), nAttrib: recordString(record, "n_attrib"), bibl: recordString(record, "bibl")}
+		seen[base]++
+		key := base
+		key.occurrence = seen[base]
+		keyed = append(keyed, keyedRecord{key: key, record: record})
+		byKey[key] = record
+	}
+	return keyed, byKey
+}
+
+// recordString returns a string field of a record, "" if it is missing
+func recordString(record compatRecord, field string) string {
+	value, _ := record[field].(string)
+	return value
+}
+
+// diffRuns matches the citations of two runs and groups those that differ
+// by file, in file name order and within a file in the order of the new run
+// (of the old for removed citations)
+func diffRuns(oldRecords, newRecords []compatRecord) *corpusDiff {
+	diff := &corpusDiff{OldRecords: len(oldRecords), NewRecords: len(newRecords)}
+	oldKeyed, oldByKey := keyDiffRecords(oldRecords)
+	newKeyed, newByKey := keyDiffRecords(newRecords)
+
+	files := make(map[string]*fileDiff)
+	fileOf := func(filename string) *fileDiff {
+		if files[filename] == nil {
+			files[filename] = &fileDiff{Filename: filename}
+		}
+		return files[filename]
+	}
+	citation := func(key diffKey, record compatRecord) diffCitation {
+		return diffCitation{Bibl: key.bibl, NAttrib: key.nAttrib, DocCitURN: recordString(record, "doc_cit_urn")}
+	}
+
+	for _, entry := range newKeyed {
+		newURN := recordString(entry.record, "urn")
+		old, exists := oldByKey[entry.key]
+		if !exists {
+			added := citation(entry.key, entry.record)
+			added.NewURN = newURN
+			file := fileOf(entry.key.filename)
+			file.Added = append(file.Added, added)
+			diff.Added++
+			continue
+		}
+		if oldURN := recordString(old, "urn"); oldURN != newURN {
+			changed := citation(entry.key, entry.record)
+			changed.OldURN, changed.NewURN = oldURN, newURN
+			file := fileOf(entry.key.filename)
+			file.Changed = append(file.Changed, changed)
+			diff.Changed++
+		}
+	}
+	for _, entry := range oldKeyed {
+		if _, exists := newByKey[entry.key]; exists {
+			continue
+		}
+		removed := citation(entry.key, entry.record)
+		removed.OldURN = recordString(entry.record, "urn")
+		file := fileOf(entry.key.filename)
+		file.Removed = append(file.Removed, removed)
+		diff.Removed++
+	}
+
+	diff.Files = make([]fileDiff, 0, len(files))
+	for _, file := range files {
+		diff.Files = append(diff.Files, *file)
+	}
+	sort.Slice(diff.Files, func(i, j int) bool { return diff.Files[i].Filename < diff.Files[j].Filename })
+	return diff
+}
+
+func (d *corpusDiff) writeText(out io.Writer, limit int) {
+	fmt.Fprintf(out, "Old citations: %d\nNew citations: %d\n", d.OldRecords, d.NewRecords)
+	fmt.Fprintf(out, "%d added, %d removed, %d changed in %d files\n", d.Added, d.Removed, d.Changed, len(d.Files))
+
+	orNone := func(urn string) string {
+		if urn == "" {
+			return "(unresolved)"
+		}
+		return urn
+	}
+	writeCitations := func(title string, citations []diffCitation, line func(diffCitation) string) {
+		if len(citations) == 0 {
+			return
+		}
+		fmt.Fprintf(out, "  %s: %d\n", title, len(citations))
+		for i, c := range citations {
+			if limit > 0 && i == limit {
+				fmt.Fprintf(out, "    ... %d more\n", len(citations)-limit)
+				break
+			}
+			ref := c.Bibl
+			if c.NAttrib != "" && c.NAttrib != c.Bibl {
+				ref = fmt.Sprintf("%s [n=%s]", c.Bibl, c.NAttrib)
+			}
+			fmt.Fprintf(out, "    %s: %s\n", ref, line(c))
+		}
+	}
+	for _, file := range d.Files {
+		fmt.Fprintf(out, "\n%s\n", file.Filename)
+		writeCitations("added", file.Added, func(c diffCitation) string { return orNone(c.NewURN) })
+		writeCitations("removed", file.Removed, func(c diffCitation) string { return orNone(c.OldURN) })
+		writeCitations("changed", file.Changed, func(c diffCitation) string {
+			return orNone(c.OldURN) + " -> " + orNone(c.NewURN)
+		})
+	}
+}
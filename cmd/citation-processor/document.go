@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"perseus_citation_linker/pkg/resolver"
+)
+
+var (
+	// <idno type="URN">urn:cts:greekLit:tlg0011.tlg004.perseus-grc2</idno>
+	idnoURNRegex = regexp.MustCompile(`(?i)<idno\b[^>]*\btype\s*=\s*"(?:urn|cts)"[^>]*>\s*(urn:cts:[^<\s]+)\s*</idno>`)
+	// <body n="urn:cts:..."> or <div type="edition" n="urn:cts:...">
+	textURNRegex = regexp.MustCompile(`<(?:body|div)\b[^>]*\bn\s*=\s*"(urn:cts:[^"]+)"`)
+
+	teiHeaderRegex   = regexp.MustCompile(`(?s)<teiHeader\b[^>]*>.*?</teiHeader>`)
+	titleStmtRegex   = regexp.MustCompile(`(?s)<titleStmt\b[^>]*>.*?</titleStmt>`)
+	sourceDescRegex  = regexp.MustCompile(`(?s)<sourceDesc\b[^>]*>.*?</sourceDesc>`)
+	publicationRegex = regexp.MustCompile(`(?s)<publicationStmt\b[^>]*>.*?</publicationStmt>`)
+	imprintRegex     = regexp.MustCompile(`(?s)<imprint\b[^>]*>.*?</imprint>`)
+	// the date of an element such as <date when="1883">
+	whenAttributeRegex = regexp.MustCompile(`\bwhen\s*=\s*"([^"]*)"`)
+)
+
+// DocumentMetadata describes the source document of a citation, taken from
+// its TEI header
+type DocumentMetadata struct {
+	URN    string `json:"urn,omitempty"`    // the document's own CTS URN
+	Title  string `json:"title,omitempty"`  // first title in the titleStmt
+	Author string `json:"author,omitempty"` // first author in the titleStmt or source
+	Editor string `json:"editor,omitempty"` // first editor in the titleStmt or source
+	Date   string `json:"date,omitempty"`   // publication date of the source, else of the edition
+}
+
+// documentRecord is a line of documents.jsonl
+type documentRecord struct {
+	Filename string `json:"filename"`
+	DocID    string `json:"doc_id"` // the document's doc_cit_urn values begin with it
+	DocumentMetadata
+	Citations    int `json:"citations"`
+	LastCitation int `json:"last_citation"` // number of the last doc_cit_urn issued, continued from with -append
+}
+
+// documentMetadata parses the TEI header of a preprocessed document,
+// returning nil if it yields nothing
+func documentMetadata(xmlContent, docID string) *DocumentMetadata {
+	var metadata DocumentMetadata
+	if strings.HasPrefix(docID, "urn:cts:") {
+		metadata.URN = docID
+	}
+	if header := teiHeaderRegex.FindString(xmlContent); header != "" {
+		titleStmt := titleStmtRegex.FindString(header)
+		sourceDesc := sourceDescRegex.FindString(header)
+
+		metadata.Title = headerElementText(titleStmt, "title")
+		metadata.Author = firstNonEmpty(headerElementText(titleStmt, "author"), headerElementText(sourceDesc, "author"))
+		metadata.Editor = firstNonEmpty(headerElementText(titleStmt, "editor"), headerElementText(sourceDesc, "editor"))
+		metadata.Date = firstNonEmpty(
+			headerElementText(imprintRegex.FindString(sourceDesc), "date"),
+			headerElementText(publicationRegex.FindString(header), "date"),
+		)
+	}
+	if metadata == (DocumentMetadata{}) {
+		return nil
+	}
+	return &metadata
+}
+
+// headerElementText returns the text of the first element named name in
+// fragment, or its when attribute if it has no text
+func headerElementText(fragment, name string) string {
+	if fragment == "" {
+		return ""
+	}
+	match := compiledRegex(`(?s)<` + name + `\b([^>]*)>(.*?)</` + name + `>`).FindStringSubmatch(fragment)
+	if match == nil {
+		return ""
+	}
+	text := strings.TrimSpace(whitespaceRegex.ReplaceAllString(stripContextTags(match[2]), " "))
+	if text == "" {
+		if when := whenAttributeRegex.FindStringSubmatch(match[1]); when != nil {
+			text = when[1]
+		}
+	}
+	return text
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// documentID identifies a document for its citations' doc_cit_urn values.
+// It is the document's own CTS URN, taken from an <idno type="URN"> in the
+// TEI header or the n attribute of the <body> or top-level edition <div>,
+// and otherwise the file's base name without extension.
+func documentID(xmlContent, filename string) string {
+	if match := idnoURNRegex.FindStringSubmatch(xmlContent); match != nil {
+		return match[1]
+	}
+	for _, match := range textURNRegex.FindAllStringSubmatch(xmlContent, -1) {
+		// urn:cts:namespace:work has no passage; deeper divs cite one
+		if strings.Count(match[1], ":") == 3 {
+			return match[1]
+		}
+	}
+	base := filepath.Base(filename)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// beginDocument resets the citation sequence for a new document, or with
+// Config.Append continues it from the existing output, reads its metadata
+// and forgets the structure of the previous document, which a format that
+// does not find its own has none of
+func (cp *CitationProcessor) beginDocument(xmlContent, filename string) {
+	cp.CounterMux.Lock()
+	defer cp.CounterMux.Unlock()
+	cp.docID = documentID(xmlContent, filename)
+	cp.document = documentMetadata(xmlContent, cp.docID)
+	cp.Counter = cp.continuation[cp.docID]
+	cp.extracted, cp.groups = 0, make(map[string]string)
+	cp.apparatus, cp.headings, cp.commented, cp.quotes = nil, nil, nil, nil
+	cp.ellipsis = nil
+}
+
+// nextCitURN returns a provisional doc_cit_urn for the next citation
+// extracted from the current document, by which the other citations of a
+// bibl listing several references name the first as their group_id until
+// numberCitations replaces it
+func (cp *CitationProcessor) nextCitURN() string {
+	cp.CounterMux.Lock()
+	defer cp.CounterMux.Unlock()
+	cp.extracted++
+	return fmt.Sprintf("%s:extracted-%d", cp.docID, cp.extracted)
+}
+
+// numberCitations gives a batch of the current document's citations, in
+// document order, their doc_cit_urn, numbering them on from the batches
+// before. Citations are numbered in document order within each document, so
+// the numbers follow the document and re-running on the same file yields
+// the same values. A list's group_id becomes the number of its first
+// citation, which comes before the others. A correction pinned to a
+// doc_cit_urn is applied once the number is known, over any URN the
+// citation was resolved to, a CTS link's included.
+func (cp *CitationProcessor) numberCitations(citations []Citation) {
+	cp.CounterMux.Lock()
+	defer cp.CounterMux.Unlock()
+	for i := range citations {
+		citation := &citations[i]
+		cp.Counter++
+		numbered := fmt.Sprintf("%s:citations-%d", cp.docID, cp.Counter)
+		if cp.selfCheck != nil {
+			cp.selfCheck.renumber(citation.DocCitURN, numbered)
+		}
+		if citation.Group == citation.DocCitURN {
+			cp.groups[citation.Group] = numbered
+		}
+		if citation.Group != "" {
+			citation.Group = cp.groups[citation.Group]
+		}
+		citation.DocCitURN = numbered
+
+		if urn, exists := cp.Resolver.Corrections.ForDocCitURN(numbered); exists {
+			setResolution(citation, resolver.Resolution{URN: urn, Corrected: true})
+			citation.PreResolved = false
+			citation.Secondary = nil
+		}
+	}
+}
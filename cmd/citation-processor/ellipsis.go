@@ -0,0 +1,189 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"perseus_citation_linker/pkg/cts"
+	"perseus_citation_linker/pkg/resolver"
+)
+
+// The kinds of elliptical reference, in the ellipsis field
+const (
+	ellipsisIbid   = "ibid"    // "ibid.", "ib.": the place cited last, or a passage of its work
+	ellipsisIdem   = "idem"    // "id. Ant. 12": a work of the author cited last
+	ellipsisLocCit = "loc-cit" // "l.c.", "Soph. loc. cit.": the place cited last, of the author if named
+	ellipsisOpCit  = "op-cit"  // "op. cit. 45", "Soph. op. cit. 45": a passage of the work cited last
+	ellipsisLemma  = "lemma"   // "v. 233": a passage of the work the commentary is on
+)
+
+var (
+	// ibidRegex matches "ibid." and "ib.", with the passage it may give
+	ibidRegex = regexp.MustCompile(`(?i)^ib(?:id(?:em)?)?\.?(?:\s*,?\s+(.+))?$`)
+	// idemRegex matches "id." or "idem" followed by a work and passage
+	idemRegex = regexp.MustCompile(`(?i)^(?:id|idem)\.?\s*,?\s+(.+)$`)
+	// locCitRegex matches "l.c." and "loc. cit.", with the author that may
+	// come before it
+	locCitRegex = regexp.MustCompile(`(?i)^(?:(.*?)\s*,?\s+)?(?:l\.\s*c|loc\.\s*cit)\.?$`)
+	// opCitRegex matches "op. cit." with the author that may come before it
+	// and the passage after it
+	opCitRegex = regexp.MustCompile(`(?i)^(?:(.*?)\s*,?\s+)?op\.\s*cit\.?\s*,?\s*(.+)$`)
+	// lemmaPassageRegex matches a passage given by line or verse alone, as
+	// "v. 233", "vv. 233-5" or "l. 12", the passage in the first group
+	lemmaPassageRegex = regexp.MustCompile(`(?i)^(?:(?:cf|see|above|below|supra|infra|on)\.?\s+)?(?:vv?|vss?|ll?|lines?|verses?)\.?\s*(\d+[a-e]?(?:\.\d+[a-e]?)*(?:\s*-\s*\d+[a-e]?(?:\.\d+[a-e]?)*)?)(?:\s*(?:ff?|sqq?)\.)?\.?$`)
+)
+
+// ellipsisState is what a document has cited so far, in document order,
+// for resolving elliptical references against. It is reset with each
+// document.
+type ellipsisState struct {
+	ref  string   // reference of the last citation resolved, as "soph. el. 626"
+	urn  string   // and its URN
+	refs []string // references resolved, latest last, for "op. cit." and "l.c." after an author
+}
+
+// resolveElliptical resolves the citations that failed, or fell back on a
+// guessed work, because their reference leaves out what the commentary takes as read: "ibid." and "l.c."
+// the place cited last, "ibid. 233" and "op. cit. 233" a passage of its
+// work, "id. Ant. 12" a work of its author, and "v. 233" a passage of the
+// work the enclosing section comments on (see commentedAt), or of
+// Config.CommentedWork. Citations are taken in document order, each resolved
+// one becoming the last cited; a document spilled under Config.MemoryLimit
+// is taken a batch at a time, in the order of the batches.
+func (cp *CitationProcessor) resolveElliptical(filename string, citations []Citation) {
+	if cp.ellipsis == nil {
+		cp.ellipsis = &ellipsisState{}
+	}
+	state := cp.ellipsis
+	order := make([]int, len(citations))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return citations[order[a]].span.start < citations[order[b]].span.start
+	})
+
+	for _, i := range order {
+		citation := &citations[i]
+		// "Soph. l.c." takes a guessed work of Sophocles with -work-fallback
+		if (citation.URN == "" || citation.Fallback) && !citation.Corrected && !citation.PreResolved {
+			cp.resolveEllipsis(state, citation, filename)
+		}
+		// a guessed work or one of several is no antecedent
+		if citation.URN == "" || citation.Fallback || len(citation.Candidates) > 0 {
+			continue
+		}
+		state.urn = citation.URN
+		state.ref = citation.Ref
+		if _, _, ok := resolver.SplitLocus(citation.Ref); ok {
+			state.refs = append(state.refs, citation.Ref)
+		}
+	}
+}
+
+// resolveEllipsis resolves a citation whose reference is elliptical, left
+// unresolved or with a guessed work, recording the kind of ellipsis
+func (cp *CitationProcessor) resolveEllipsis(state *ellipsisState, citation *Citation, filename string) {
+	text := strings.Join(strings.Fields(rangeDashes.Replace(firstNonEmpty(citation.Bibl, citation.NAttrib))), " ")
+	if text == "" {
+		return
+	}
+	head, locus, hasLocus := resolver.SplitLocus(state.ref)
+
+	switch {
+	case ibidRegex.MatchString(text):
+		passage := ibidRegex.FindStringSubmatch(text)[1]
+		if passage == "" {
+			cp.resolveAs(citation, ellipsisIbid, state.ref, state.urn)
+			return
+		}
+		if hasLocus && resolver.IsPassage(passage) {
+			cp.resolveRef(citation, ellipsisIbid, head+" "+resolver.ContinuePassage(locus, passage), filename)
+		}
+
+	case idemRegex.MatchString(text):
+		if author := cp.Resolver.ReferenceAuthor(head); author != "" {
+			cp.resolveRef(citation, ellipsisIdem, author+" "+idemRegex.FindStringSubmatch(text)[1], filename)
+		}
+
+	case locCitRegex.MatchString(text):
+		if author := locCitRegex.FindStringSubmatch(text)[1]; author != "" {
+			if ref := state.lastOf(cp.Resolver.GetRef("", author)); ref != "" {
+				cp.resolveRef(citation, ellipsisLocCit, ref, filename)
+			}
+			return
+		}
+		cp.resolveAs(citation, ellipsisLocCit, state.ref, state.urn)
+
+	case opCitRegex.MatchString(text):
+		match := opCitRegex.FindStringSubmatch(text)
+		ref := state.ref
+		if match[1] != "" {
+			ref = state.lastOf(cp.Resolver.GetRef("", match[1]))
+		}
+		head, locus, ok := resolver.SplitLocus(ref)
+		if ok && resolver.IsPassage(match[2]) {
+			cp.resolveRef(citation, ellipsisOpCit, head+" "+resolver.ContinuePassage(locus, match[2]), filename)
+		}
+
+	case lemmaPassageRegex.MatchString(text):
+		work := commentedWork(cp.commentedAt(citation.span.start))
+		if work == "" {
+			work = cp.Config.CommentedWork
+		}
+		if work != "" {
+			passage := strings.ReplaceAll(lemmaPassageRegex.FindStringSubmatch(text)[1], " ", "")
+			cp.resolveAs(citation, ellipsisLemma, citation.Ref, work+":"+passage)
+		}
+	}
+}
+
+// lastOf returns the latest reference resolved that is of the author named,
+// as the normalized reference GetRef gives
+func (state *ellipsisState) lastOf(author string) string {
+	if author == "" {
+		return ""
+	}
+	for i := len(state.refs) - 1; i >= 0; i-- {
+		if strings.HasPrefix(state.refs[i], author+" ") {
+			return state.refs[i]
+		}
+	}
+	return ""
+}
+
+// resolveRef resolves the reference an elliptical citation stands for
+func (cp *CitationProcessor) resolveRef(citation *Citation, ellipsis, ref, filename string) {
+	ref = cp.Resolver.GetRef("", ref)
+	res := cp.resolve(ref, citation.DocCitURN, citation.XMLContext, filename)
+	if res.URN == "" || res.Fallback {
+		return
+	}
+	citation.Ref = ref
+	citation.Ellipsis = ellipsis
+	setResolution(citation, res)
+}
+
+// resolveAs gives an elliptical citation the URN of the place it stands for
+func (cp *CitationProcessor) resolveAs(citation *Citation, ellipsis, ref, urn string) {
+	if urn == "" {
+		return
+	}
+	citation.Ref = ref
+	citation.Ellipsis = ellipsis
+	setResolution(citation, resolver.Resolution{URN: urn})
+}
+
+// commentedWork returns the work of the URN of a commented passage
+func commentedWork(urn string) string {
+	if urn == "" {
+		return ""
+	}
+	parsed, err := cts.Parse(urn)
+	if err != nil {
+		return ""
+	}
+	parsed.Passage = cts.Passage{}
+	return parsed.String()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrMalformedXML reports an input file that is not well-formed XML. Such
+// files are skipped rather than mined for partial matches.
+type ErrMalformedXML struct {
+	File string
+	Line int // line of the first error, if known
+	Err  error
+}
+
+func (e *ErrMalformedXML) Error() string {
+	return fmt.Sprintf("malformed XML in %s: %v", e.File, e.Err)
+}
+
+func (e *ErrMalformedXML) Unwrap() error {
+	return e.Err
+}
+
+// checkWellFormed parses the document to check that its elements nest
+// properly. Entities are not checked, since TEI files often use entities
+// declared in a DTD; every entity referenced is treated as defined.
+func checkWellFormed(xmlContent, filename string) error {
+	decoder := xml.NewDecoder(strings.NewReader(xmlContent))
+	decoder.Entity = make(map[string]string)
+	for _, match := range entityRegex.FindAllStringSubmatch(xmlContent, -1) {
+		if !strings.HasPrefix(match[1], "#") {
+			decoder.Entity[match[1]] = ""
+		}
+	}
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			malformed := &ErrMalformedXML{File: filename, Err: err}
+			if syntaxErr, ok := err.(*xml.SyntaxError); ok {
+				malformed.Line = syntaxErr.Line
+			}
+			return malformed
+		}
+	}
+}
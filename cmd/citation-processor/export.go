@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"perseus_citation_linker/pkg/loader"
+)
+
+// workEntry aggregates the resolved citations that point at a single work
+type workEntry struct {
+	WorkURN string
+	Author  string
+	Title   string
+	Count   int
+}
+
+// workExportWriter collects resolved citations across all files and writes
+// one bibliographic entry per cited work when the run finishes
+type workExportWriter struct {
+	path    string
+	stdout  bool // write to standard output instead of path
+	data    *loader.ComprehensiveData
+	works   map[string]*workEntry
+	write   func(io.Writer, []workEntry) error
+	io      IOOptions
+	limiter *rateLimiter
+}
+
+func (w *workExportWriter) Write(citations []Citation) error {
+	if w.works == nil {
+		w.works = make(map[string]*workEntry)
+	}
+	for _, citation := range citations {
+		if citation.URN == "" || citation.Ref == "" {
+			continue
+		}
+		workURN, authURN, workID := splitWorkURN(citation.URN)
+		if workURN == "" {
+			continue
+		}
+		entry, exists := w.works[workURN]
+		if !exists && citation.Fragment != nil {
+			// fragments are grouped by collection and author
+			_, collection, _ := w.data.FragmentCollection(citation.Fragment.Collection)
+			entry = &workEntry{
+				WorkURN: workURN,
+				Author:  displayName(citation.Fragment.Name),
+				Title:   fmt.Sprintf("%s (ed. %s)", collection.Title, collection.Editor),
+			}
+			w.works[workURN] = entry
+		} else if !exists && citation.Documentary != nil {
+			// inscriptions and papyri are grouped by corpus and volume
+			_, corpus, _ := w.data.DocumentaryCorpus(citation.Documentary.Corpus)
+			title := corpus.Title
+			if volume := citation.Documentary.Volume; volume != "" {
+				title += ", vol. " + volume
+			}
+			entry = &workEntry{WorkURN: workURN, Title: title}
+			w.works[workURN] = entry
+		} else if !exists {
+			author := w.data.AuthorForURN(authURN)
+			entry = &workEntry{
+				WorkURN: workURN,
+				Author:  displayName(author),
+				Title:   displayName(w.data.WorkTitle(author, workID)),
+			}
+			w.works[workURN] = entry
+		}
+		entry.Count++
+	}
+	return nil
+}
+
+func (w *workExportWriter) Close() error {
+	entries := make([]workEntry, 0, len(w.works))
+	for _, entry := range w.works {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].WorkURN < entries[j].WorkURN
+	})
+
+	if w.stdout {
+		return w.write(stdout, entries)
+	}
+	file, err := openOutputFile(w.path, os.O_CREATE|os.O_TRUNC, w.io, w.limiter)
+	if err != nil {
+		return err
+	}
+	if err := w.write(file, entries); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// splitWorkURN strips the passage from a CTS URN, returning the work-level URN
+// along with the author URN and the work component, e.g.
+// urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:151 gives
+// urn:cts:greekLit:tlg0011.tlg004.perseus-grc2, urn:cts:greekLit:tlg0011 and tlg004
+func splitWorkURN(urn string) (workURN, authURN, workID string) {
+	parts := strings.SplitN(urn, ":", 5)
+	if len(parts) < 4 {
+		return "", "", ""
+	}
+	workURN = strings.Join(parts[:4], ":")
+	components := strings.Split(parts[3], ".")
+	authURN = strings.Join(parts[:3], ":") + ":" + components[0]
+	if len(components) > 1 {
+		workID = components[1]
+	}
+	return workURN, authURN, workID
+}
+
+// displayName turns a data file key such as "pliny_senior" or
+// "oedipus tyrannus" into a capitalized display form
+func displayName(key string) string {
+	words := strings.Fields(strings.ReplaceAll(key, "_", " "))
+	for i, word := range words {
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		words[i] = string(runes)
+	}
+	return strings.Join(words, " ")
+}
+
+// bibKey builds a BibTeX citation key from the work URN
+func bibKey(workURN string) string {
+	parts := strings.SplitN(workURN, ":", 4)
+	key := parts[len(parts)-1]
+	return strings.NewReplacer(".", "_", "-", "_").Replace(key)
+}
+
+// citedNote describes how often a work was cited
+func citedNote(count int) string {
+	if count == 1 {
+		return "Cited 1 time"
+	}
+	return fmt.Sprintf("Cited %d times", count)
+}
+
+// bibtexEscaper escapes the characters special to BibTeX and LaTeX in a
+// field value. A backslash comes first, and the replacements are not
+// themselves escaped, as the replacer makes one pass.
+var bibtexEscaper = strings.NewReplacer(
+	"\\", "\\textbackslash{}",
+	"{", "\\{", "}", "\\}",
+	"&", "\\&", "%", "\\%", "#", "\\#", "$", "\\$", "_", "\\_",
+	"~", "\\textasciitilde{}", "^", "\\textasciicircum{}",
+)
+
+func writeBibTeX(out io.Writer, entries []workEntry) error {
+	for _, entry := range entries {
+		var b strings.Builder
+		fmt.Fprintf(&b, "@book{%s,\n", bibKey(entry.WorkURN))
+		if entry.Author != "" {
+			fmt.Fprintf(&b, "  author = {%s},\n", bibtexEscaper.Replace(entry.Author))
+		}
+		if entry.Title != "" {
+			fmt.Fprintf(&b, "  title = {%s},\n", bibtexEscaper.Replace(entry.Title))
+		}
+		fmt.Fprintf(&b, "  url = {%s},\n  note = {%s}\n}\n\n", entry.WorkURN, citedNote(entry.Count))
+		if _, err := io.WriteString(out, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cslItem is the subset of a CSL-JSON item emitted for each cited work
+type cslItem struct {
+	ID     string      `json:"id"`
+	Type   string      `json:"type"`
+	Author []cslAuthor `json:"author,omitempty"`
+	Title  string      `json:"title,omitempty"`
+	URL    string      `json:"URL"`
+	Note   string      `json:"note"`
+}
+
+type cslAuthor struct {
+	Literal string `json:"literal"`
+}
+
+func writeCSLJSON(out io.Writer, entries []workEntry) error {
+	items := make([]cslItem, 0, len(entries))
+	for _, entry := range entries {
+		item := cslItem{
+			ID:    bibKey(entry.WorkURN),
+			Type:  "book",
+			Title: entry.Title,
+			URL:   entry.WorkURN,
+			Note:  citedNote(entry.Count),
+		}
+		if entry.Author != "" {
+			item.Author = []cslAuthor{{Literal: entry.Author}}
+		}
+		items = append(items, item)
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(items)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"perseus_citation_linker/pkg/cts"
+)
+
+// Confidence lost for what a resolution had to guess, for -min-confidence
+const (
+	fallbackConfidence = 0.3 // the work was guessed by the -work-fallback policy
+	priorConfidence    = 0.8 // the work was chosen among equally likely ones by -prior
+	ellipsisConfidence = 0.8 // the reference was elliptical, as "ibid.", and taken from what was cited before
+)
+
+// citationFilter selects the citations a run writes, by Config.Authors,
+// Config.URNPrefixes and Config.MinConfidence; a citation is written if it
+// passes every filter given
+type citationFilter struct {
+	prefixes      []string // of URNs, from Config.URNPrefixes
+	textGroups    []string // URNs of the text groups of Config.Authors
+	minConfidence float64
+}
+
+// newCitationFilter builds the filter of a config, or returns nil if the
+// config filters nothing. Authors are given by name or abbreviation, as
+// "sophocles" or "soph.", and taken for their text groups.
+func (cp *CitationProcessor) newCitationFilter(config Config) (*citationFilter, error) {
+	if config.MinConfidence < 0 || config.MinConfidence > 1 {
+		return nil, fmt.Errorf("minimum confidence %v out of range (want 0 to 1)", config.MinConfidence)
+	}
+	filter := &citationFilter{prefixes: splitList(config.URNPrefixes), minConfidence: config.MinConfidence}
+	authURNs := cp.Resolver.Data.GetAllAuthURNs()
+	authAbb := cp.Resolver.Data.GetAllAuthAbb()
+	for _, name := range splitList(strings.ToLower(config.Authors)) {
+		author := name
+		if value, exists := authAbb[name]; exists {
+			author = cp.Resolver.Data.AuthorFor(value, "")
+		}
+		urn, exists := authURNs[author]
+		if !exists {
+			return nil, fmt.Errorf("unknown author %q in -authors", name)
+		}
+		filter.textGroups = append(filter.textGroups, urn)
+	}
+	if len(filter.prefixes) == 0 && len(filter.textGroups) == 0 && filter.minConfidence == 0 {
+		return nil, nil
+	}
+	return filter, nil
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// keep reports whether a citation passes the filter. A citation is taken to
+// cite the URN it resolved to or, failing that, its provisional URN.
+func (f *citationFilter) keep(citation Citation) bool {
+	urn := firstNonEmpty(citation.URN, citation.Provisional)
+	if len(f.prefixes) > 0 && !matchesAnyPrefix(urn, f.prefixes) {
+		return false
+	}
+	if len(f.textGroups) > 0 && !matchesAnyPrefix(urn, f.textGroups) {
+		return false
+	}
+	return citationConfidence(citation) >= f.minConfidence
+}
+
+// matchesAnyPrefix reports whether a URN begins with one of prefixes,
+// ending where a component of the URN does, so that "tlg0011" does not
+// match "tlg00110". A prefix without the "urn:" scheme is matched against
+// the URN's work component, as "tlg0011" or "tlg0011.tlg004".
+func matchesAnyPrefix(urn string, prefixes []string) bool {
+	if urn == "" {
+		return false
+	}
+	work := ""
+	if parsed, err := cts.Parse(urn); err == nil {
+		work = strings.TrimRight(strings.Join([]string{parsed.TextGroup, parsed.Work, parsed.Version}, "."), ".")
+	}
+	for _, prefix := range prefixes {
+		target := work
+		if strings.HasPrefix(prefix, "urn:") {
+			target = urn
+		}
+		if !strings.HasPrefix(target, prefix) {
+			continue
+		}
+		if rest := target[len(prefix):]; rest == "" || rest[0] == '.' || rest[0] == ':' {
+			return true
+		}
+	}
+	return false
+}
+
+// citationConfidence returns how sure the resolution of a citation is,
+// between 0 and 1: 0 unresolved, 1 pinned by the corrections table or a CTS
+// link, and a registered step's own confidence. Otherwise it is 1, lowered
+// for what the resolution had to guess: the share of the first candidate's
+// score of a reference matching several works, the similarity of a
+// misspelled author, and the fallbackConfidence, priorConfidence and
+// ellipsisConfidence factors.
+func citationConfidence(citation Citation) float64 {
+	switch {
+	case citation.URN == "":
+		return 0
+	case citation.Corrected || citation.PreResolved:
+		return 1
+	case citation.Step != "":
+		return citation.Confidence
+	}
+	confidence := 1.0
+	if citation.Fallback {
+		confidence *= fallbackConfidence
+	}
+	if len(citation.Candidates) > 1 {
+		total := 0.0
+		for _, candidate := range citation.Candidates {
+			total += candidate.Score
+		}
+		share := 1 / float64(len(citation.Candidates))
+		if total > 0 {
+			share = citation.Candidates[0].Score / total
+		}
+		confidence *= share
+	}
+	if citation.PriorUsed {
+		confidence *= priorConfidence
+	}
+	if citation.FuzzyAuthor != nil {
+		confidence *= citation.FuzzyAuthor.Score
+	}
+	if citation.Ellipsis != "" {
+		confidence *= ellipsisConfidence
+	}
+	return confidence
+}
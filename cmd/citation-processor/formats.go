@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+)
+
+// Format is a kind of input document the processor reads citations from,
+// such as TEI XML. Formats for other containers (EpiDoc, HTML, plain text,
+// JATS) are added with RegisterFormat, each in a file of its own, rather than
+// by growing the TEI extraction.
+type Format interface {
+	// Name identifies the format, as given to -input-format
+	Name() string
+	// Detect reports whether a document, by its file name and content, is
+	// in the format
+	Detect(filename, content string) bool
+	// Extract adds the citations of a document to set, numbering and
+	// resolving them with cp, and returns the text their byte spans are
+	// offsets into. It begins with cp.beginDocument, which numbers the
+	// document's citations afresh and reads its metadata.
+	Extract(cp *CitationProcessor, set *citationSet, content, filename string) (text string)
+}
+
+// formatValidator is implemented by formats that reject a malformed
+// document before extracting from it, as TEI does XML that is not
+// well-formed
+type formatValidator interface {
+	Validate(content, filename string) error
+}
+
+// formats are the registered input formats in the order they are detected:
+// those registered with RegisterFormat, latest first, then TEI, which takes
+// whatever no other format claims
+var formats = []Format{teiFormat{}}
+
+// RegisterFormat adds an input format, detected before those registered
+// earlier and before TEI, replacing any format already registered under the
+// same name
+func RegisterFormat(format Format) {
+	for i, registered := range formats {
+		if registered.Name() == format.Name() {
+			formats[i] = format
+			return
+		}
+	}
+	formats = append([]Format{format}, formats...)
+}
+
+// lookupFormat returns the registered format of the name
+func lookupFormat(name string) (Format, error) {
+	var names []string
+	for _, format := range formats {
+		if format.Name() == name {
+			return format, nil
+		}
+		names = append(names, format.Name())
+	}
+	return nil, fmt.Errorf("unknown input format %q (expected %s)", name, strings.Join(names, ", "))
+}
+
+// formatFor returns the format a document is read as: Config.InputFormat if
+// set, else the first registered format to detect it, else TEI
+func (cp *CitationProcessor) formatFor(filename, content string) Format {
+	if cp.inputFormat != nil {
+		return cp.inputFormat
+	}
+	for _, format := range formats {
+		if format.Detect(filename, content) {
+			return format
+		}
+	}
+	return teiFormat{}
+}
+
+// validateDocument checks a document for the format it is read as, if the
+// format checks documents at all
+func (cp *CitationProcessor) validateDocument(content, filename string) error {
+	if validator, ok := cp.formatFor(filename, content).(formatValidator); ok {
+		return validator.Validate(content, filename)
+	}
+	return nil
+}
+
+// teiFormat reads TEI XML, the format of the Perseus commentaries, with
+// the extraction modes of the Config: <cit> mode, <bibl> mode, strict,
+// aggressive and prose
+type teiFormat struct{}
+
+func (teiFormat) Name() string { return "tei" }
+
+// Detect takes files named .xml and content that opens with markup
+func (teiFormat) Detect(filename, content string) bool {
+	if strings.EqualFold(filepath.Ext(filename), ".xml") {
+		return true
+	}
+	return strings.HasPrefix(strings.TrimLeft(strings.TrimPrefix(content, "\ufeff"), " \t\r\n"), "<")
+}
+
+// Validate rejects XML that is not well-formed
+func (teiFormat) Validate(content, filename string) error {
+	return checkWellFormed(content, filename)
+}
+
+func (teiFormat) Extract(cp *CitationProcessor, set *citationSet, xmlContent, filename string) string {
+	// Resolve namespace prefixes and character entities before any pattern matching
+	xmlContent = preprocessXML(xmlContent, cp.entities)
+	cp.beginDocument(xmlContent, filename)
+
+	// Blank out elements editors marked as known false positives
+	text := xmlContent
+	xmlContent, suppressed := suppressIgnored(xmlContent)
+	if suppressed > 0 {
+		slog.Debug("suppressed marked elements", "file", filename, "elements", suppressed)
+	}
+	// Comments and excluded subtrees such as the teiHeader cite nothing
+	xmlContent, excluded := excludeRegions(xmlContent, cp.excluded)
+	if excluded > 0 {
+		slog.Debug("skipped comments and excluded elements", "file", filename, "regions", excluded)
+	}
+	cp.apparatus = apparatusEntries(xmlContent)
+	cp.headings = sectionHeadings(xmlContent)
+	cp.commented = cp.commentedSpans(xmlContent, filename)
+	cp.quotes = quoteSpans(xmlContent)
+	if cp.prior != nil && cp.document != nil {
+		cp.prior.setAuthor(filename, cp.document.Author)
+	}
+
+	if cp.Config.UseCitTags {
+		// Comprehensive extraction approach - find all citation patterns regardless of XML structure
+		cp.extractAllCitationPatterns(set, xmlContent, filename)
+	} else {
+		// Original behavior: only extract <bibl> tags
+		for _, citation := range cp.extractBiblTags(xmlContent, filename) {
+			set.add(citation)
+		}
+	}
+
+	// CTS links outside the citation elements need no resolution
+	for _, citation := range cp.extractCTSLinks(xmlContent, filename) {
+		set.add(citation)
+	}
+
+	var found []Citation
+	if cp.Config.Aggressive {
+		found = cp.extractAggressivePatterns(xmlContent, filename)
+		for _, citation := range found {
+			set.add(citation)
+		}
+	}
+	if cp.Config.Prose {
+		for _, citation := range cp.extractProse(xmlContent, filename, found) {
+			set.add(citation)
+		}
+	}
+	return text
+}
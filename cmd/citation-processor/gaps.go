@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"perseus_citation_linker/pkg/cts"
+	"perseus_citation_linker/pkg/resolver"
+)
+
+// gapReportRows is the most rows each issue of the gap report lists
+const gapReportRows = 25
+
+// gapExamples is the most example references shown per row
+const gapExamples = 3
+
+// gapGroup counts the citations sharing a data gap, such as an unknown
+// author abbreviation
+type gapGroup struct {
+	key      string
+	count    int
+	files    map[string]bool
+	examples []string
+	detail   map[string]bool // works an ambiguous abbreviation matches, or the reasons a pattern failed
+}
+
+func (g *gapGroup) add(citation Citation, detail ...string) {
+	g.count++
+	g.files[citation.Filename] = true
+	// the bibl as written, as the reference is normalized
+	example := citation.Bibl
+	if example == "" {
+		example = citation.Ref
+	}
+	if len(g.examples) < gapExamples && !containsString(g.examples, example) {
+		g.examples = append(g.examples, example)
+	}
+	for _, d := range detail {
+		g.detail[d] = true
+	}
+}
+
+// gapGroups collects the citations of one kind of gap by key
+type gapGroups map[string]*gapGroup
+
+func (gg gapGroups) add(key string, citation Citation, detail ...string) {
+	group, exists := gg[key]
+	if !exists {
+		group = &gapGroup{key: key, files: make(map[string]bool), detail: make(map[string]bool)}
+		gg[key] = group
+	}
+	group.add(citation, detail...)
+}
+
+// top returns the groups with the most citations first, then by key
+func (gg gapGroups) top() []*gapGroup {
+	groups := make([]*gapGroup, 0, len(gg))
+	for _, group := range gg {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].count != groups[j].count {
+			return groups[i].count > groups[j].count
+		}
+		return groups[i].key < groups[j].key
+	})
+	return groups
+}
+
+// gapReport gathers, over a run, what the data files lack: authors cited but
+// not recognized, works of known authors not found, abbreviations matching
+// several works and the shapes of references that failed to resolve. It is
+// written at the end of the run as Markdown issue bodies with -gap-report.
+type gapReport struct {
+	resolver   *resolver.URNResolver
+	files      map[string]bool
+	citations  int
+	unresolved int
+
+	authors   gapGroups // unrecognized author abbreviations
+	authorURN gapGroups // recognized authors with no URN
+	works     gapGroups // author and work as cited, work not found
+	ambiguous gapGroups // author and work as cited, several candidate works
+	patterns  gapGroups // unresolved references with digits folded
+}
+
+func newGapReport(urnResolver *resolver.URNResolver) *gapReport {
+	return &gapReport{
+		resolver:  urnResolver,
+		files:     make(map[string]bool),
+		authors:   make(gapGroups),
+		authorURN: make(gapGroups),
+		works:     make(gapGroups),
+		ambiguous: make(gapGroups),
+		patterns:  make(gapGroups),
+	}
+}
+
+// digitsRegex matches the numbers a reference pattern folds
+var digitsRegex = regexp.MustCompile(`\d+`)
+
+// observe counts the gaps shown by a file's citations
+func (gr *gapReport) observe(citations []Citation) {
+	for _, citation := range citations {
+		gr.files[citation.Filename] = true
+		gr.citations++
+		// modern scholarship is no gap in the data
+		if citation.URN != "" && !citation.Fallback && len(citation.Candidates) < 2 || citation.Secondary != nil {
+			continue
+		}
+		parts := gr.resolver.ParseReference(citation.Ref)
+		author := strings.ToLower(parts.Author)
+		cited := strings.TrimSpace(author + " " + strings.ToLower(parts.Work))
+
+		if citation.URN == "" {
+			gr.unresolved++
+			reason := string(citation.reason)
+			if reason == "" {
+				reason = string(resolver.ReasonUnresolvedPassage)
+			}
+			gr.patterns.add(digitsRegex.ReplaceAllString(strings.ToLower(citation.Ref), "N"), citation, reason)
+			switch citation.reason {
+			case resolver.ReasonUnknownAuthor:
+				gr.authors.add(author, citation)
+			case resolver.ReasonNoAuthorURN:
+				gr.authorURN.add(parts.ResolvedAuthor, citation)
+			case resolver.ReasonUnknownWork:
+				gr.works.add(cited, citation)
+			}
+			continue
+		}
+		if citation.Fallback {
+			gr.works.add(cited, citation)
+		}
+		if len(citation.Candidates) > 1 {
+			var works []string
+			for _, candidate := range citation.Candidates {
+				works = append(works, workURN(candidate.URN))
+			}
+			gr.ambiguous.add(cited, citation, works...)
+		}
+	}
+}
+
+// workURN returns the work-level URN of a passage URN
+func workURN(urn string) string {
+	parsed, err := cts.Parse(urn)
+	if err != nil {
+		return urn
+	}
+	parsed.Passage = cts.Passage{}
+	return parsed.String()
+}
+
+// write writes the report to path
+func (gr *gapReport) write(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to write gap report: %w", err)
+	}
+	gr.writeMarkdown(file)
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write gap report: %w", err)
+	}
+	return nil
+}
+
+// writeMarkdown writes one section per kind of gap found, each a title and
+// body that can be filed as an issue against the data files as it stands
+func (gr *gapReport) writeMarkdown(out io.Writer) {
+	fmt.Fprintf(out, "# Data gaps\n\n%d citations in %d files, %d unresolved.\n", gr.citations, len(gr.files), gr.unresolved)
+
+	issues := 0
+	issue := func(title, intro string, groups gapGroups, columns []string, row func(*gapGroup) []string) {
+		if len(groups) == 0 {
+			return
+		}
+		issues++
+		fmt.Fprintf(out, "\n---\n\n## %s\n\n%s\n\n", title, intro)
+		fmt.Fprintf(out, "| %s | Citations | Files | Examples |\n|%s\n", strings.Join(columns, " | "), strings.Repeat(" --- |", len(columns)+3))
+		top := groups.top()
+		for i, group := range top {
+			if i == gapReportRows {
+				fmt.Fprintf(out, "\n%d more not shown.\n", len(top)-gapReportRows)
+				break
+			}
+			cells := row(group)
+			var examples []string
+			for _, example := range group.examples {
+				examples = append(examples, markdownCode(example))
+			}
+			cells = append(cells, fmt.Sprint(group.count), fmt.Sprint(len(group.files)), strings.Join(examples, ", "))
+			fmt.Fprintf(out, "| %s |\n", strings.Join(cells, " | "))
+		}
+	}
+	key := func(g *gapGroup) []string { return []string{markdownCode(g.key)} }
+	details := func(g *gapGroup) string {
+		var values []string
+		for value := range g.detail {
+			values = append(values, markdownCode(value))
+		}
+		sort.Strings(values)
+		return strings.Join(values, ", ")
+	}
+
+	issue(fmt.Sprintf("Add %d missing authors", len(gr.authors)),
+		"These author abbreviations were cited but are not in the data files. Add each author, or the abbreviation to a known author's `AuthAbb`.",
+		gr.authors, []string{"Abbreviation"}, key)
+	issue(fmt.Sprintf("Add URNs for %d authors", len(gr.authorURN)),
+		"These authors are recognized but have no URN in the data files, so none of their citations resolve.",
+		gr.authorURN, []string{"Author"}, key)
+	issue(fmt.Sprintf("Add %d missing works", len(gr.works)),
+		"These works of known authors were not found in the work tables. Citations of them are unresolved, or resolved by the -work-fallback policy.",
+		gr.works, []string{"Author and work"}, key)
+	issue(fmt.Sprintf("Disambiguate %d abbreviations matching several works", len(gr.ambiguous)),
+		"These references match several works, and resolved to the best ranked. Add an abbreviation that tells the works apart, or a correction.",
+		gr.ambiguous, []string{"Author and work", "Works"}, func(g *gapGroup) []string {
+			return []string{markdownCode(g.key), details(g)}
+		})
+	issue(fmt.Sprintf("Top %d unresolved reference patterns", min(len(gr.patterns), gapReportRows)),
+		"The shapes of the references that failed to resolve, numbers written N, with the reasons they failed.",
+		gr.patterns, []string{"Pattern", "Reasons"}, func(g *gapGroup) []string {
+			return []string{markdownCode(g.key), details(g)}
+		})
+
+	if issues == 0 {
+		fmt.Fprintln(out, "\nNo gaps found.")
+	}
+}
+
+// markdownCode formats text as inline code in a table cell
+func markdownCode(text string) string {
+	text = strings.NewReplacer("`", "'", "|", `\|`, "\n", " ").Replace(text)
+	return "`" + text + "`"
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
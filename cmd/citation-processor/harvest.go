@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"perseus_citation_linker/pkg/httpclient"
+	"perseus_citation_linker/pkg/loader"
+)
+
+// catalogWork is the part of the Scaife library JSON for a work that carries titles
+type catalogWork struct {
+	Label string `json:"label"`
+	Texts []struct {
+		Label string `json:"label"`
+	} `json:"texts"`
+}
+
+// runHarvestAliases implements the harvest-aliases subcommand, which looks up
+// every work URN in the data files in the Scaife/Perseus catalog and merges
+// the alternative titles it finds into work_aliases.json
+func runHarvestAliases(args []string) error {
+	fs := flag.NewFlagSet("harvest-aliases", flag.ExitOnError)
+	dataDir := fs.String("data", "", "Data directory (default: discovered as for processing runs)")
+	endpoint := fs.String("endpoint", "https://scaife.perseus.org", "Base URL of the Scaife library")
+	httpOptions := addHTTPFlags(fs)
+	dryRun := fs.Bool("dry-run", false, "Print the aliases found without writing them")
+	fs.Parse(args)
+
+	if *dataDir == "" {
+		*dataDir = loader.FindDataDir()
+	}
+	data, err := loader.LoadComprehensiveDataDir(*dataDir)
+	if err != nil {
+		return err
+	}
+
+	client := httpclient.New(*httpOptions)
+	fetch := func(workURN string) (*catalogWork, error) {
+		return fetchCatalogWork(context.Background(), client, *endpoint, workURN)
+	}
+	found := harvestWorkAliases(data, fetch)
+
+	if *dryRun {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(found)
+	}
+
+	aliasPath := filepath.Join(*dataDir, loader.WorkAliasesFile)
+	existing := make(map[string]map[string]string)
+	if content, err := os.ReadFile(aliasPath); err == nil {
+		if err := json.Unmarshal(content, &existing); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", aliasPath, err)
+		}
+	}
+	added := 0
+	for author, aliases := range found {
+		if existing[author] == nil {
+			existing[author] = make(map[string]string)
+		}
+		for alias, workURN := range aliases {
+			if _, exists := existing[author][alias]; !exists {
+				existing[author][alias] = workURN
+				added++
+			}
+		}
+	}
+
+	content, err := json.MarshalIndent(existing, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(aliasPath, append(content, '\n'), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Added %d aliases to %s\n", added, aliasPath)
+	return nil
+}
+
+// harvestWorkAliases collects catalog titles that are not already known for
+// each work, keyed by author and lower-cased alias
+func harvestWorkAliases(data *loader.ComprehensiveData, fetch func(workURN string) (*catalogWork, error)) map[string]map[string]string {
+	authURNs := data.GetAllAuthURNs()
+	allWorks := data.GetAllWorkURNs()
+
+	authors := make([]string, 0, len(allWorks))
+	for author := range allWorks {
+		authors = append(authors, author)
+	}
+	sort.Strings(authors)
+
+	found := make(map[string]map[string]string)
+	for _, author := range authors {
+		authURN, exists := authURNs[author]
+		if !exists {
+			continue
+		}
+		titles := data.WorkTitles(author)
+		workIDs := make([]string, 0, len(titles))
+		for workID := range titles {
+			workIDs = append(workIDs, workID)
+		}
+		sort.Strings(workIDs)
+
+		for _, workID := range workIDs {
+			workURN := authURN + "." + workID
+			work, err := fetch(workURN)
+			if err != nil {
+				slog.Warn("catalog lookup failed", "urn", workURN, "error", err)
+				continue
+			}
+			labels := []string{work.Label}
+			for _, text := range work.Texts {
+				labels = append(labels, text.Label)
+			}
+			for _, label := range labels {
+				alias := strings.ToLower(strings.Join(strings.Fields(label), " "))
+				if alias == "" {
+					continue
+				}
+				if _, known := allWorks[author][alias]; known {
+					continue
+				}
+				if found[author] == nil {
+					found[author] = make(map[string]string)
+				}
+				found[author][alias] = workID
+			}
+		}
+	}
+	return found
+}
+
+// fetchCatalogWork requests the library JSON for a work-level URN
+func fetchCatalogWork(ctx context.Context, client *httpclient.Client, endpoint, workURN string) (*catalogWork, error) {
+	requestURL := strings.TrimSuffix(endpoint, "/") + "/library/" + url.PathEscape(workURN) + "/json/"
+	body, err := client.Get(ctx, requestURL)
+	if err != nil {
+		return nil, err
+	}
+	var work catalogWork
+	if err := json.Unmarshal(body, &work); err != nil {
+		return nil, fmt.Errorf("failed to decode catalog response for %s: %w", workURN, err)
+	}
+	return &work, nil
+}
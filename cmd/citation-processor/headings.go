@@ -0,0 +1,42 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// headRegex matches a section heading
+var headRegex = regexp.MustCompile(`(?s)<head\b[^>]*>(.*?)</head>`)
+
+// sectionHeading is the offset of a <head> element and its text
+type sectionHeading struct {
+	start int
+	text  string
+}
+
+// sectionHeadings finds the headings of a document, in document order,
+// skipping empty ones
+func sectionHeadings(xmlContent string) []sectionHeading {
+	if !strings.Contains(xmlContent, "<head") {
+		return nil
+	}
+	var headings []sectionHeading
+	for _, match := range headRegex.FindAllStringSubmatchIndex(xmlContent, -1) {
+		text := strings.Join(strings.Fields(stripContextTags(xmlContent[match[2]:match[3]])), " ")
+		if text != "" {
+			headings = append(headings, sectionHeading{start: match[0], text: text})
+		}
+	}
+	return headings
+}
+
+// headingAt returns the text of the nearest heading starting before the byte
+// offset, or "" if there is none
+func (cp *CitationProcessor) headingAt(offset int) string {
+	i := sort.Search(len(cp.headings), func(i int) bool { return cp.headings[i].start >= offset })
+	if i == 0 {
+		return ""
+	}
+	return cp.headings[i-1].text
+}
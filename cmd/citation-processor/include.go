@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const xincludeNamespace = "http://www.w3.org/2001/XInclude"
+
+// maxIncludeDepth bounds how deeply includes nest, beyond any cycle
+const maxIncludeDepth = 16
+
+var (
+	// <?xml version="1.0" encoding="UTF-8"?>
+	xmlDeclRegex = regexp.MustCompile(`^\s*<\?xml[^?]*\?>`)
+	// <!DOCTYPE TEI SYSTEM "tei.dtd" [ ... ]>
+	doctypeRegex = regexp.MustCompile(`(?s)<!DOCTYPE[^\[>]*(?:\[(.*?)\])?\s*>`)
+	// <!ENTITY book1 SYSTEM "book1.xml"> or PUBLIC "id" "book1.xml"
+	systemEntityRegex = regexp.MustCompile(`<!ENTITY\s+([A-Za-z_][\w.-]*)\s+(?:SYSTEM|PUBLIC\s+["'][^"']*["'])\s+["']([^"']*)["']\s*>`)
+	// href="part.xml"
+	includeAttrRegex = regexp.MustCompile(`([\w:-]+)\s*=\s*["']([^"']*)["']`)
+	// <xi:include href="part.xml"/>, by any prefix
+	includeTagRegex = regexp.MustCompile(`<[\w.-]+:include\b[^>]*>`)
+	// <xi:include href="part.xml"/> or <xi:include ...>...</xi:include>, by
+	// any prefix, the element's own checked after matching
+	includeElementRegex = regexp.MustCompile(`(?s)<([\w.-]+):include\b([^>]*?)(?:/>|>(.*?)</([\w.-]+):include\s*>)`)
+	// <xi:fallback>...</xi:fallback>, by any prefix
+	fallbackRegex = regexp.MustCompile(`(?s)<([\w.-]+):fallback\s*(?:/>|>(.*?)</[\w.-]+:fallback\s*>)`)
+)
+
+// errXPointer is the error of an XInclude with an xpointer, which is not
+// supported; like a missing file, it falls back to the <xi:fallback>
+var errXPointer = errors.New("xpointer is not supported")
+
+// includeResolver expands the XIncludes and external entities of a
+// document, with Config.ResolveIncludes. A file included twice is expanded
+// both times; one that includes itself, directly or not, is an error, as is
+// one outside the directory of the document.
+type includeResolver struct {
+	root string   // directory of the document, which no included file may be outside
+	open []string // files being expanded, outermost first
+}
+
+// resolveIncludes returns a document with its <xi:include> elements and its
+// references to SYSTEM entities replaced by the files they name, resolved
+// relative to filename's directory and expanded in turn. An XInclude whose
+// file is missing, or which has an xpointer, is replaced by its
+// <xi:fallback>, if it has one.
+func resolveIncludes(content, filename string) (string, error) {
+	filename = filepath.Clean(filename)
+	resolver := &includeResolver{root: filepath.Dir(filename)}
+	return resolver.expand(content, filename)
+}
+
+func (r *includeResolver) expand(content, filename string) (string, error) {
+	for _, open := range r.open {
+		if open == filename {
+			return "", fmt.Errorf("%s includes itself", filename)
+		}
+	}
+	if len(r.open) >= maxIncludeDepth {
+		return "", fmt.Errorf("includes of %s nest more than %d deep", filename, maxIncludeDepth)
+	}
+	r.open = append(r.open, filename)
+	defer func() { r.open = r.open[:len(r.open)-1] }()
+
+	content, err := r.expandEntities(content, filename)
+	if err != nil {
+		return "", err
+	}
+	return r.expandXIncludes(content, filename)
+}
+
+// expandEntities replaces the references to the SYSTEM entities the
+// document's DOCTYPE declares, after it, leaving the declarations
+func (r *includeResolver) expandEntities(content, filename string) (string, error) {
+	doctype := doctypeRegex.FindStringSubmatchIndex(content)
+	if doctype == nil || doctype[2] < 0 {
+		return content, nil
+	}
+	declared := systemEntityRegex.FindAllStringSubmatch(content[doctype[2]:doctype[3]], -1)
+	if len(declared) == 0 {
+		return content, nil
+	}
+	body := content[doctype[1]:]
+	for _, entity := range declared {
+		reference := "&" + entity[1] + ";"
+		if !strings.Contains(body, reference) {
+			continue
+		}
+		included, err := r.includeFile(entity[2], filename, "xml")
+		if err != nil {
+			return "", fmt.Errorf("entity %s: %w", entity[1], err)
+		}
+		body = strings.ReplaceAll(body, reference, included)
+	}
+	return content[:doctype[1]] + body, nil
+}
+
+// expandXIncludes replaces the document's <xi:include> elements, by
+// whichever prefixes it binds to the XInclude namespace
+func (r *includeResolver) expandXIncludes(content, filename string) (string, error) {
+	prefixes := map[string]bool{"xi": true}
+	for _, match := range namespaceDeclRegex.FindAllStringSubmatch(content, -1) {
+		if match[2] == xincludeNamespace {
+			prefixes[match[1]] = true
+		}
+	}
+	var expandErr error
+	content = includeElementRegex.ReplaceAllStringFunc(content, func(element string) string {
+		match := includeElementRegex.FindStringSubmatch(element)
+		if expandErr != nil || !prefixes[match[1]] || (match[4] != "" && match[4] != match[1]) {
+			return element
+		}
+		attrs := make(map[string]string)
+		for _, attr := range includeAttrRegex.FindAllStringSubmatch(match[2], -1) {
+			attrs[attr[1]] = attr[2]
+		}
+		if attrs["href"] == "" {
+			return element
+		}
+		var included string
+		var err error
+		if attrs["xpointer"] != "" {
+			err = errXPointer
+		} else {
+			included, err = r.includeFile(attrs["href"], filename, attrs["parse"])
+		}
+		if os.IsNotExist(err) || errors.Is(err, errXPointer) {
+			if fallback := fallbackRegex.FindStringSubmatch(match[3]); fallback != nil && prefixes[fallback[1]] {
+				return fallback[2]
+			}
+		}
+		if err != nil {
+			expandErr = fmt.Errorf("XInclude %s: %w", attrs["href"], err)
+			return element
+		}
+		return included
+	})
+	if expandErr != nil {
+		return "", expandErr
+	}
+	return content, nil
+}
+
+// includeFile returns the content of the file href names, relative to the
+// including file: expanded and without its XML declaration or DOCTYPE when
+// parse is "xml" (or empty), and escaped as character data when it is
+// "text"
+func (r *includeResolver) includeFile(href, including, parse string) (string, error) {
+	if strings.Contains(href, "://") {
+		return "", fmt.Errorf("only local files can be included, not %s", href)
+	}
+	path := filepath.Clean(filepath.Join(filepath.Dir(including), filepath.FromSlash(href)))
+	if rel, err := filepath.Rel(r.root, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the input directory %s", href, r.root)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	switch parse {
+	case "", "xml":
+		included, err := r.expand(string(content), path)
+		if err != nil {
+			return "", err
+		}
+		included = xmlDeclRegex.ReplaceAllString(included, "")
+		return strings.TrimSpace(doctypeRegex.ReplaceAllString(included, "")), nil
+	case "text":
+		var escaped bytes.Buffer
+		if err := xml.EscapeText(&escaped, content); err != nil {
+			return "", err
+		}
+		return escaped.String(), nil
+	default:
+		return "", fmt.Errorf("unknown parse value %q", parse)
+	}
+}
+
+// includedFiles returns the input files that another input file includes,
+// which with Config.ResolveIncludes are processed as part of it rather
+// than again on their own
+func includedFiles(files []string) map[string]bool {
+	inputs := make(map[string]bool, len(files))
+	for _, file := range files {
+		inputs[filepath.Clean(file)] = true
+	}
+	included := make(map[string]bool)
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue // reported when the file is processed
+		}
+		for _, href := range includeTargets(string(content)) {
+			path := filepath.Clean(filepath.Join(filepath.Dir(file), filepath.FromSlash(href)))
+			if inputs[path] && path != filepath.Clean(file) {
+				included[path] = true
+			}
+		}
+	}
+	return included
+}
+
+// includeTargets returns the hrefs of a document's XIncludes and the files
+// of the SYSTEM entities it declares, without expanding them
+func includeTargets(content string) []string {
+	var targets []string
+	if doctype := doctypeRegex.FindStringSubmatch(content); doctype != nil {
+		for _, entity := range systemEntityRegex.FindAllStringSubmatch(doctype[1], -1) {
+			targets = append(targets, entity[2])
+		}
+	}
+	for _, element := range includeTagRegex.FindAllString(content, -1) {
+		for _, attr := range includeAttrRegex.FindAllStringSubmatch(element, -1) {
+			if attr[1] == "href" && !strings.Contains(attr[2], "://") {
+				targets = append(targets, attr[2])
+			}
+		}
+	}
+	return targets
+}
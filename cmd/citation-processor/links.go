@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"perseus_citation_linker/pkg/resolver"
+)
+
+var (
+	// ctsLinkRegex matches <ref target="..."> and <ptr target="..."/> elements
+	// whose target holds a CTS URN, capturing the target and any ref text
+	ctsLinkRegex = regexp.MustCompile(`(?s)<(?:ref|ptr)\b[^>]*?\btarget\s*=\s*"([^"]*urn:cts:[^"]*)"[^>]*?(?:/>|>(.*?)</ref>|>\s*</ptr>)`)
+	// citedElementRegex matches the elements whose links are already taken
+	// up by <cit> and <bibl> extraction
+	citedElementRegex = regexp.MustCompile(`(?s)` + citElementPattern + `|` + biblElementPattern)
+	// ctsURNRegex finds the URN in a target, which may be a resolver URL
+	// such as http://data.perseus.org/citations/urn:cts:...
+	ctsURNRegex = regexp.MustCompile(`urn:cts:[^\s"#?]+`)
+)
+
+// normalizeCTSTarget returns the CTS URN in a link target, unescaped and
+// without a trailing slash, or "" if there is none
+func normalizeCTSTarget(target string) string {
+	target = decodeText(strings.TrimSpace(target))
+	if unescaped, err := url.PathUnescape(target); err == nil {
+		target = unescaped
+	}
+	urn := ctsURNRegex.FindString(target)
+	urn = strings.TrimRight(urn, "/")
+	if strings.Count(urn, ":") < 3 {
+		return ""
+	}
+	return urn
+}
+
+// ctsLinkTarget returns the URN of the first CTS link in a citation element,
+// or "" if it has none
+func ctsLinkTarget(element string) string {
+	for _, match := range ctsLinkRegex.FindAllStringSubmatch(element, -1) {
+		if urn := normalizeCTSTarget(match[1]); urn != "" {
+			return urn
+		}
+	}
+	return ""
+}
+
+// resolveElement resolves a <cit> or <bibl> element's reference. An element
+// carrying a CTS link is pre-resolved to the link's URN without running the
+// heuristics; a correction pinned to the citation still takes precedence
+// (see numberCitations).
+func (cp *CitationProcessor) resolveElement(element, ref, citURN, context, filename string) (res resolver.Resolution, preResolved bool) {
+	if urn := ctsLinkTarget(element); urn != "" {
+		res := resolver.Resolution{URN: urn}
+		cp.observeResolution(filename, res)
+		return res, true
+	}
+	return cp.resolve(ref, citURN, context, filename), false
+}
+
+// extractCTSLinks harvests <ref target="urn:cts:..."> and <ptr> links outside
+// <cit> and <bibl> elements as pre-resolved citations. The ref text, or the
+// target of a ptr, is kept as the citation's bibl.
+func (cp *CitationProcessor) extractCTSLinks(xmlContent, filename string) []Citation {
+	masked := citedElementRegex.ReplaceAllStringFunc(xmlContent, func(match string) string {
+		return strings.Repeat(" ", len(match))
+	})
+
+	var citations []Citation
+	for _, loc := range ctsLinkRegex.FindAllStringSubmatchIndex(masked, -1) {
+		target := xmlContent[loc[2]:loc[3]]
+		urn := normalizeCTSTarget(target)
+		if urn == "" {
+			continue
+		}
+		bibl := strings.TrimSpace(decodeText(target))
+		if loc[4] >= 0 {
+			if text := strings.TrimSpace(stripContextTags(xmlContent[loc[4]:loc[5]])); text != "" {
+				bibl = text
+			}
+		}
+
+		citURN := cp.nextCitURN()
+		lemma, inApparatus := cp.apparatusAt(loc[0])
+		res, preResolved := cp.resolveElement(xmlContent[loc[0]:loc[1]], urn, citURN, "", filename)
+		citations = append(citations, Citation{
+			Bibl:        bibl,
+			Ref:         urn,
+			URN:         res.URN,
+			XMLContext:  cp.contextAt(xmlContent, loc[0], loc[1]),
+			Filename:    filename,
+			DocCitURN:   citURN,
+			Corrected:   res.Corrected,
+			PreResolved: preResolved,
+			InApparatus: inApparatus,
+			Lemma:       lemma,
+			Heading:     cp.headingAt(loc[0]),
+			InQuote:     cp.quotedAt(loc[0], loc[1]),
+			span:        byteSpan{start: loc[0], end: loc[1]},
+		})
+	}
+	return citations
+}
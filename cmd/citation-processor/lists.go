@@ -0,0 +1,74 @@
+package main
+
+// referenceList returns the references a bibl lists, as "Il. 1.1, 2.3; Od.
+// 5.10" does three, each in full, or nil if it gives one. An n attribute
+// listing several is taken over the content; otherwise it stands for the
+// first reference the content lists. element, if given, is the element the
+// bibl was taken from.
+func (cp *CitationProcessor) referenceList(element, nAttr, biblContent string) []string {
+	// a CTS link gives the URN of the element as a whole
+	if ctsLinkTarget(element) != "" {
+		return nil
+	}
+	if refs := cp.Resolver.SplitReferences(nAttr, ""); refs != nil {
+		return refs
+	}
+	return cp.Resolver.SplitReferences(biblContent, nAttr)
+}
+
+// listGroup returns the group_id of a citation numbered citURN whose bibl
+// lists the references listed, and the references after its first
+func listGroup(citURN string, listed []string) (group string, rest []string) {
+	if len(listed) == 0 {
+		return "", nil
+	}
+	return citURN, listed[1:]
+}
+
+// listReference derives the reference to resolve for one reference of a list
+func (cp *CitationProcessor) listReference(listed string) (ref, commentator, note, edition string) {
+	return cp.reference("", listed)
+}
+
+// listMembers returns the citations of the references a citation's bibl
+// lists after its first: copies of it in the same place, each with the
+// reference as its bibl, resolved on its own and numbered next, sharing the
+// citation's group_id. Only the first keeps the quote and n attribute.
+func (cp *CitationProcessor) listMembers(citation Citation) []Citation {
+	if len(citation.listed) == 0 {
+		return nil
+	}
+	members := make([]Citation, 0, len(citation.listed))
+	for _, listed := range citation.listed {
+		citURN := cp.nextCitURN()
+		ref, commentator, note, edition := cp.listReference(listed)
+		res := cp.resolve(ref, citURN, citation.XMLContext, citation.Filename)
+
+		member := citation
+		member.listed = nil
+		member.NAttrib = ""
+		member.Bibl = listed
+		member.Ref = ref
+		member.Quote = ""
+		member.QuotePos = ""
+		member.DocCitURN = citURN
+		member.Commentator = commentator
+		member.Note = note
+		member.Edition = edition
+		member.PreResolved = false
+		setResolution(&member, res)
+		members = append(members, member)
+	}
+	return members
+}
+
+// addListed adds a citation to set unless it was added before, with the
+// citations of the other references its bibl lists
+func (cp *CitationProcessor) addListed(set *citationSet, citation Citation) {
+	if !set.addUnique(citation) {
+		return
+	}
+	for _, member := range cp.listMembers(citation) {
+		set.add(member)
+	}
+}
@@ -1,43 +1,167 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
+	"perseus_citation_linker/pkg/loader"
 	"perseus_citation_linker/pkg/resolver"
 )
 
 type Citation struct {
-	NAttrib    string `json:"n_attrib"`
-	Bibl       string `json:"bibl"`
-	Ref        string `json:"ref"`
-	URN        string `json:"urn"`
-	Quote      string `json:"quote"`
-	XMLContext string `json:"xml_context"`
-	Filename   string `json:"filename"`
-	DocCitURN  string `json:"doc_cit_urn"`
+	NAttrib    string   `json:"n_attrib"`
+	Bibl       string   `json:"bibl"`
+	Ref        string   `json:"ref"`
+	URN        string   `json:"urn"`
+	Quote      string   `json:"quote"`
+	XMLContext string   `json:"xml_context"`
+	Filename   string   `json:"filename"`
+	DocCitURN  string   `json:"doc_cit_urn"`
+	Pattern    string   `json:"pattern,omitempty"`          // aggressive-mode pattern provider that found the citation, or "prose"
+	Warnings   []string `json:"warnings,omitempty"`         // reasons resolution failed, if it did
+	Scheme     string   `json:"scheme,omitempty"`           // citation scheme of the cited work, e.g. "book.line"
+	Violation  string   `json:"scheme_violation,omitempty"` // URN rejected as beyond the citation scheme, as book 30 of the Iliad
+
+	Candidates  []resolver.Candidate  `json:"candidates,omitempty"`      // ranked alternatives for ambiguous references
+	Works       []string              `json:"works,omitempty"`           // every work a cross-work range such as "Dem. 18–19" spans
+	Qualifier   string                `json:"qualifier,omitempty"`       // "init" or "fin" for the beginning or end of the passage
+	Fallback    bool                  `json:"fallback_used,omitempty"`   // work not found; URN from the -work-fallback policy
+	Locus       []resolver.LocusLevel `json:"locus,omitempty"`           // passage levels given as key=value, e.g. book=1:line=10
+	Commentator string                `json:"commentator,omitempty"`     // modern commentator cited with the ancient locus
+	Note        string                `json:"note,omitempty"`            // trailing parenthetical, such as the speaker in "OT 924 (Messenger)"
+	Edition     string                `json:"edition,omitempty"`         // modern editor or edition series named after the locus, as in "Rep. 327a Burnet"
+	Corrected   bool                  `json:"corrected,omitempty"`       // URN taken from the corrections table
+	PreResolved bool                  `json:"pre_resolved,omitempty"`    // URN taken from a CTS link in the XML
+	Document    *DocumentMetadata     `json:"document,omitempty"`        // source document, from its TEI header
+	ScaifeURL   string                `json:"scaife_url,omitempty"`      // Scaife Viewer link for the URN, with Config.ScaifeURLs
+	Provisional string                `json:"provisional_urn,omitempty"` // placeholder URN for a known author's unknown work, with Config.Provisional
+	InApparatus bool                  `json:"in_apparatus,omitempty"`    // the citation is inside an <app> apparatus criticus entry
+	Lemma       string                `json:"lemma,omitempty"`           // text of the <lem> of that entry
+	InQuote     bool                  `json:"in_quote,omitempty"`        // the citation lies inside a <quote>, and is likely part of the quoted text
+	QuotePos    string                `json:"quote_position,omitempty"`  // "before" or "after": where the quote stands relative to the reference
+	Heading     string                `json:"section_heading,omitempty"` // text of the nearest <head> before the citation
+	Commented   string                `json:"commented_range,omitempty"` // URN of the base-text passage the enclosing commentary section is on
+	Type        string                `json:"citation_type,omitempty"`   // "quotation", "reference", "cross-reference" or "parallel"
+	PriorUsed   bool                  `json:"prior_used,omitempty"`      // the work was chosen among equally likely ones by the -prior co-occurrence counts
+	Fragment    *resolver.Fragment    `json:"fragment,omitempty"`        // the fragment cited, for references to a fragment collection such as "FGrH 115 F 30"
+	FuzzyAuthor *resolver.AuthorMatch `json:"fuzzy_author,omitempty"`    // the author as written was taken for a similar known one, with Config.FuzzyAuthors
+	Provenance  []loader.DataEntry    `json:"provenance,omitempty"`      // data file entries that mapped the author and work, with Config.Provenance
+	Step        string                `json:"resolution_step,omitempty"` // registered resolution step that gave the URN, see RegisterResolutionStep
+	Confidence  float64               `json:"step_confidence,omitempty"` // that step's confidence in the URN
+	DocumentID  string                `json:"document_id,omitempty"`     // id of the database row the document came from, with ProcessRows
+	Group       string                `json:"group_id,omitempty"`        // doc_cit_urn of the first citation of a bibl listing several references, as "Il. 1.1, 2.3"
+	Ellipsis    string                `json:"ellipsis,omitempty"`        // how an elliptical reference such as "ibid." or "v. 233" was resolved from what was cited before
+
+	// Documentary is the inscription or papyrus cited, for references to a
+	// corpus such as "IG II² 1234", and ExternalID its identifier elsewhere,
+	// as its papyri.info page, if its corpus gives one
+	Documentary *resolver.DocumentaryText `json:"documentary,omitempty"`
+	ExternalID  string                    `json:"external_id,omitempty"`
+
+	// Secondary is the modern scholarship a bibl cites instead of an ancient
+	// text, as "Wilamowitz 1889, 23", with Config.Secondary
+	Secondary *resolver.SecondaryReference `json:"secondary,omitempty"`
+
+	span     byteSpan               // extent in the preprocessed document
+	selector *textSelector          // place in the document's text, for -format annotations
+	reason   resolver.FailureReason // why resolution failed, for -gap-report
+	listed   []string               // references the bibl lists after the first, for listMembers
 }
 
 type Config struct {
-	InputDir       string
-	OutputDir      string
-	ResolvedFile   string
-	UnresolvedFile string
-	UseCitTags     bool
+	InputDir        string // directory, XML file, or comma-separated list of them
+	OutputDir       string
+	ResolvedFile    string
+	UnresolvedFile  string
+	UseCitTags      bool
+	Format          string // jsonl (default), bibtex, csl, annotations or parquet
+	Strict          bool   // only extract TEI P5 citation elements under whitelisted parents
+	Aggressive      bool   // also run the registered pattern providers over running text
+	Prose           bool   // also scan running prose for abbreviated citations, written to prose.jsonl
+	ProseCue        bool   // with Prose, only take citations after a cue such as "cf." or "see"
+	EntityFile      string // optional JSON table of extra character entities
+	Context         ContextOptions
+	AmbiguousOnly   bool   // only write citations with more than one candidate URN
+	Authors         string // comma-separated authors, by name or abbreviation, whose citations alone are written
+	URNPrefixes     string // comma-separated URN prefixes, as "urn:cts:greekLit:tlg0011" or "tlg0085", whose citations alone are written
+	CorrectionsFile string // optional CSV or JSON table of pinned resolutions
+	IO              IOOptions
+	PartitionBy     string  // split resolved output by "namespace", "author" or "file"
+	DryRun          bool    // extract and resolve, but only print a summary per file
+	DryRunExamples  int     // citations printed per file in a dry run
+	ScaifeURLs      bool    // add a Scaife Viewer link to each resolved citation
+	WorkFallback    string  // when no work matches: "guess" (default), "author" or "none"
+	PassageFormat   string  // how passage numbers are written in URNs: "preserve" (default), "plain" or "padded"
+	PassageWidth    int     // digits each passage level is padded to with "padded" (0: 3)
+	SelfCheck       int     // resolved citations re-resolved at the end of the run (0: no check)
+	SelfCheckSeed   int64   // seed for choosing them (0: from the clock)
+	Provisional     bool    // write citations of a known author's unknown work to provisional.jsonl
+	Secondary       bool    // write citations of modern scholarship, unresolved, to secondary.jsonl
+	Unique          bool    // also write distinct citations across all files to citations_unique.jsonl
+	FuzzyAuthors    float64 // Jaro-Winkler similarity for matching misspelled authors (0: exact matches only)
+	Prior           bool    // break ties between works by what each document and commentator cites
+	PriorFrom       string  // previous run's output directory to seed the prior from (implies Prior)
+	Deterministic   bool    // byte-identical output across runs of the same input and data: nothing written depends on the clock
+	SkipQuoted      bool    // drop citations lying inside a <quote> rather than flag them in_quote
+	CacheFile       string  // resolutions saved by a previous run, loaded at the start and saved at the end of this one
+	ExcludeElements string  // comma-separated elements whose subtrees yield no citations ("": teiHeader, "none": none)
+	Provenance      bool    // record the data file entries each resolution used
+	Append          bool    // add to the output directory's files, continuing each document's doc_cit_urn numbering
+	GapReport       string  // Markdown report of the data gaps the run shows, written at its end
+	MemoryLimit     int64   // bytes of a document's citations held in memory before spilling to disk (0: no limit)
+	SpillDir        string  // directory of spilled citations ("": the system's temporary directory)
+	CommentedWork   string  // work URN that section headings giving only lines ("Lines 1–100") refer to ("": the last work a heading named)
+	TimestampOutput bool    // add the time the run started to the output file names, so no run replaces another's
+	InputFormat     string  // registered format documents are read as, e.g. "tei" ("": detected from each document)
+	QuoteLookahead  int     // characters after a <bibl> outside a <cit> its quote may start (0: 200, negative: none)
+	QuoteLookbehind int     // characters before such a <bibl> its quote may end (0: 200, negative: none)
+	RunMetadata     bool    // write run.json, describing the run and the data it used
+	MinConfidence   float64 // least confidence in a resolution, between 0 and 1, for the citation to be written (see citationConfidence)
+	ResolveIncludes bool    // expand XIncludes and SYSTEM entities relative to each input file before extraction
+
+	// RunFlags are the flags set on the command line, recorded in run.json
+	RunFlags map[string]string
 }
 
 type CitationProcessor struct {
 	Config     Config
 	Resolver   *resolver.URNResolver
-	Counter    int
+	Writer     CitationWriter
+	Counter    int // citations numbered so far in the current document
 	CounterMux sync.Mutex
+
+	docID            string            // identifies the current document in doc_cit_urn values
+	extracted        int               // citations given a provisional doc_cit_urn so far in the current document
+	groups           map[string]string // doc_cit_urn of the first citation of each list, by its provisional one
+	document         *DocumentMetadata // metadata of the current document
+	patternProviders []PatternProvider
+	proseScanner     *proseScanner
+	entities         map[string]string
+	selfCheck        *selfChecker     // samples resolutions with -self-check
+	prior            *citationPrior   // co-occurrence counts with -prior
+	apparatus        []apparatusEntry // <app> entries of the current document
+	headings         []sectionHeading // <head> elements of the current document
+	commented        []commentedSpan  // sections of the current document keyed to base-text ranges
+	titleProvider    PatternProvider  // finds work titles in section headings
+	quotes           []quoteSpan      // <quote> elements of the current document
+	cache            *resolutionCache // resolutions by reference, with -cache-file
+	excluded         map[string]bool  // elements whose subtrees are not scanned
+	continuation     map[string]int   // last doc_cit_urn number of each document, with Config.Append
+	gaps             *gapReport       // data gaps shown by the run, with Config.GapReport
+	rowID            string           // id of the current document's database row, with ProcessRows
+	startedAt        time.Time        // when the run started, for Config.TimestampOutput
+	filter           *citationFilter  // selects the citations written, with Config.Authors, URNPrefixes or MinConfidence
+	inputFormat      Format           // Config.InputFormat, nil to detect each document's
+	ellipsis         *ellipsisState   // what the current document has cited, for elliptical references
 }
 
 func NewCitationProcessor(config Config) (*CitationProcessor, error) {
@@ -46,69 +170,436 @@ func NewCitationProcessor(config Config) (*CitationProcessor, error) {
 		return nil, fmt.Errorf("failed to create resolver: %w", err)
 	}
 
-	return &CitationProcessor{
-		Config:   config,
-		Resolver: urnResolver,
-		Counter:  0,
-	}, nil
+	if err := config.Context.validate(); err != nil {
+		return nil, err
+	}
+	if config.MemoryLimit < 0 {
+		return nil, fmt.Errorf("-memory-limit must not be negative")
+	}
+
+	cp := &CitationProcessor{
+		Config:    config,
+		Resolver:  urnResolver,
+		Counter:   0,
+		excluded:  parseExcludedElements(config.ExcludeElements),
+		startedAt: time.Now(),
+	}
+	if config.InputFormat != "" {
+		if cp.inputFormat, err = lookupFormat(config.InputFormat); err != nil {
+			return nil, err
+		}
+	}
+	if cp.Resolver.WorkFallback, err = resolver.ParseWorkFallback(config.WorkFallback); err != nil {
+		return nil, err
+	}
+	if cp.Resolver.PassageFormat, err = resolver.ParsePassageFormat(config.PassageFormat); err != nil {
+		return nil, err
+	}
+	if config.PassageWidth < 0 {
+		return nil, fmt.Errorf("passage width %d out of range (want 0 or more)", config.PassageWidth)
+	}
+	cp.Resolver.PassageWidth = config.PassageWidth
+	if config.FuzzyAuthors < 0 || config.FuzzyAuthors > 1 {
+		return nil, fmt.Errorf("fuzzy author threshold %v out of range (want 0 to 1)", config.FuzzyAuthors)
+	}
+	cp.Resolver.FuzzyAuthors = config.FuzzyAuthors
+	cp.Resolver.RecordProvenance = config.Provenance
+	cp.Resolver.Chain = registeredSteps()
+	if config.CorrectionsFile != "" {
+		cp.Resolver.Corrections, err = resolver.LoadCorrections(config.CorrectionsFile)
+		if err != nil {
+			return nil, err
+		}
+		slog.Debug("loaded corrections", "file", config.CorrectionsFile, "entries", cp.Resolver.Corrections.Len())
+	}
+	if config.EntityFile != "" {
+		cp.entities, err = loadEntityTable(config.EntityFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if config.Prior || config.PriorFrom != "" {
+		cp.prior = newCitationPrior()
+		if config.PriorFrom != "" {
+			if err := cp.prior.load(config.PriorFrom); err != nil {
+				return nil, fmt.Errorf("failed to load prior: %w", err)
+			}
+		}
+		cp.Resolver.Prior = cp.prior
+	}
+	if config.CacheFile != "" {
+		fingerprint, err := cacheFingerprint(config)
+		if err != nil {
+			return nil, err
+		}
+		if cp.cache, err = loadResolutionCache(config.CacheFile, fingerprint); err != nil {
+			return nil, err
+		}
+	}
+	if config.SelfCheck > 0 {
+		seed := config.SelfCheckSeed
+		if seed == 0 && config.Deterministic {
+			seed = deterministicSeed
+		}
+		cp.selfCheck = newSelfChecker(config.SelfCheck, seed)
+	}
+	if config.GapReport != "" {
+		cp.gaps = newGapReport(cp.Resolver)
+	}
+	if cp.filter, err = cp.newCitationFilter(config); err != nil {
+		return nil, err
+	}
+	cp.Writer, err = newCitationWriter(cp, config.OutputDir)
+	if err != nil {
+		return nil, err
+	}
+	return cp, nil
 }
 
 func main() {
+	if handled, err := runSubcommand(os.Args[1:]); handled {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Parse command line flags
 	noCitTags := flag.Bool("nocit", false, "Use <bibl> and <quote> tags to guide citation extraction (default: use <cit> tags)")
-	inputDir := flag.String("input", ".", "Input directory containing XML files")
-	outputDir := flag.String("output", "cit_data", "Output directory for JSONL files")
+	inputDir := flag.String("input", ".", "Input directory containing XML files, or comma-separated XML files and directories")
+	outputDir := flag.String("output", "cit_data", "Output directory for JSONL files, or - to write all citations to stdout")
+	strict := flag.Bool("strict", false, "Only extract <cit> and <bibl> elements whose TEI P5 parents guarantee a citation, skipping heuristic patterns")
+	aggressive := flag.Bool("aggressive", false, "Also scan running text with heuristic pattern providers (full author names, work titles) for unmarked citations")
+	prose := flag.Bool("prose", false, "Also scan running prose for citations by author abbreviation (e.g. \"cf. Thuc. 2.65\"), keeping only unambiguous resolutions, written to prose.jsonl")
+	proseCue := flag.Bool("prose-cue", false, "With -prose, only take citations introduced by a cue such as \"cf.\", \"comp.\" or \"see\"")
+	entityFile := flag.String("entities", "", "JSON file mapping extra character entity names to replacement text")
+	logLevel := flag.String("log-level", "info", "Log level: debug, info, warn or error (per-citation resolution warnings are logged at debug)")
+	logFormat := flag.String("log-format", "text", "Log format: text or json")
+	contextSize := flag.Int("context-size", defaultContextSize, "Characters of XML context kept either side of a citation (the maximum with -context-expand)")
+	contextStrip := flag.Bool("context-strip-tags", false, "Remove markup and decode entities in the XML context")
+	contextExpand := flag.String("context-expand", "none", "Cut the XML context at the enclosing boundary: none, sentence or parent (element)")
+	contextPolicy := flag.String("context-policy", "verbatim", "How contexts and quotes are emitted: verbatim, truncate (short context and quote), hash (SHA-256 digests) or omit")
+	corrections := flag.String("corrections", "", "CSV or JSON table pinning the URN for a bibl string or doc_cit_urn, consulted before resolution heuristics")
+	ambiguousOnly := flag.Bool("ambiguous-only", false, "Only write citations whose reference matches several works, with their ranked candidates, for manual review")
+	writeBuffer := flag.Int("write-buffer", defaultWriteBuffer, "Bytes of output buffered per file between writes")
+	fsync := flag.String("fsync", "none", "When to fsync output: none, file (after each input file) or interval")
+	fsyncInterval := flag.Duration("fsync-interval", 30*time.Second, "Minimum time between fsyncs with -fsync interval")
+	maxWriteRate := flag.Int64("max-write-rate", 0, "Maximum output write rate in bytes per second (0: unlimited)")
+	gzipOutput := flag.Bool("gzip-output", false, "Compress output files with gzip as they are written, e.g. resolved.jsonl.gz (not for parquet or -output -)")
+	timestampOutput := flag.Bool("timestamp-output", false, "Add the time the run started, in UTC, to the output file names, e.g. resolved-20240131T120000Z.jsonl, keeping previous runs' output rather than replacing it")
+	partitionBy := flag.String("partition-by", "", "Split resolved output into resolved.<key>.jsonl per CTS namespace, cited author or source file: namespace, author or file")
+	dryRun := flag.Bool("dry-run", false, "Extract and resolve without writing output, printing a summary and the first citations of each file")
+	dryRunExamples := flag.Int("dry-run-examples", 5, "Citations printed per file with -dry-run")
+	workFallback := flag.String("work-fallback", "guess", "When a known author's work is not found: guess (the author's first work), author (author-level URN) or none (unresolved)")
+	passageFormat := flag.String("passage-format", "preserve", "How passage numbers are written in URNs: preserve (as cited), plain (no leading zeros, e.g. 1.1) or padded (zero-padded to -passage-width digits per level, e.g. 001.001)")
+	passageWidth := flag.Int("passage-width", resolver.DefaultPassageWidth, "Digits each passage level is padded to with -passage-format padded")
+	scaifeURLs := flag.Bool("scaife-urls", false, "Add a scaife_url field linking each resolved citation to the Scaife Viewer")
+	provisional := flag.Bool("provisional", false, "Mint provisional URNs (e.g. urn:cts:greekLit:tlg0012.UNKNOWN:1.1) for citations of a known author's unknown work, written to provisional.jsonl instead of unresolved.jsonl")
+	secondary := flag.Bool("secondary", false, "Leave bibls citing modern scholarship, such as \"Jebb ad loc.\" or \"Wilamowitz 1889, 23\", unresolved and write them to secondary.jsonl, with the author, year and pages parsed, instead of unresolved.jsonl")
+	unique := flag.Bool("unique", false, "Also write citations_unique.jsonl, the distinct resolved citations of the whole run by URN and quote, each with its occurrences")
+	fuzzyAuthors := flag.Float64("fuzzy-authors", 0, "Take an unrecognized author for the known author or abbreviation at least this Jaro-Winkler similar, between 0 and 1 (e.g. 0.9 for \"Thukydides\"), recording the match in fuzzy_author (0: off)")
+	prior := flag.Bool("prior", false, "Break ties between works sharing an abbreviation by the works each document, then its author's other documents, cite unambiguously, marking such citations prior_used")
+	priorFrom := flag.String("prior-from", "", "Output directory of a previous run to seed the -prior counts from (implies -prior)")
+	deterministic := flag.Bool("deterministic", false, "Guarantee byte-identical output across runs of the same input: seed -self-check with a fixed seed rather than the clock, leave the run's times out of -run-metadata and reject -timestamp-output")
+	quoteLookahead := flag.Int("quote-lookahead", defaultQuoteDistance, "Characters after a <bibl> outside a <cit> within which a <quote> in the same paragraph is taken as its quotation (negative: none)")
+	quoteLookbehind := flag.Int("quote-lookbehind", defaultQuoteDistance, "Characters before a <bibl> outside a <cit> within which a <quote> in the same paragraph is taken as its quotation, for commentaries that quote before citing (negative: none)")
+	skipQuoted := flag.Bool("skip-quoted", false, "Drop citations lying inside a <quote> element, which are usually part of the quoted text, instead of marking them in_quote")
+	cacheFile := flag.String("cache-file", "", "Load reference resolutions from this file at the start of the run and save them to it at the end, so a rerun on a mostly unchanged corpus only resolves new references (ignored when the program, data files, corrections or resolver options change)")
+	excludeElements := flag.String("exclude-elements", defaultExcludedElements, "Comma-separated elements whose contents are not scanned for citations, or none; comments are always skipped")
+	provenance := flag.Bool("provenance", false, "Record on each citation the data file entries that mapped its author and work, e.g. latin_data.json: AuthAbb[\"plin.\"]")
+	printDataSummary := flag.Bool("print-data-summary", false, "Print each data pack's author, work and abbreviation counts, schema version and checksum to stderr before the run (see also the data info subcommand)")
+	cpuProfile := flag.String("cpuprofile", "", "Write a CPU profile of the run to this file, for go tool pprof")
+	memProfile := flag.String("memprofile", "", "Write an allocation profile of the run to this file, for go tool pprof")
+	selfCheck := flag.Int("self-check", 0, "Re-resolve this many randomly sampled resolved citations at the end of the run and fail on any difference (0: off)")
+	selfCheckSeed := flag.Int64("self-check-seed", 0, "Seed for -self-check sampling, to repeat a run's sample (0: from the clock; the seed used is logged)")
+	appendOutput := flag.Bool("append", false, "Add this run's citations to the files already in the output directory instead of replacing them, numbering each document's citations on from the last doc_cit_urn already written")
+	gapReport := flag.String("gap-report", "", "Write a Markdown report of the run's data gaps to this file: missing authors and works, abbreviations matching several works and the commonest unresolved reference patterns, as issue bodies")
+	memoryLimit := flag.Int64("memory-limit", 0, "Bytes of a document's citations held in memory before spilling them to disk, repeats then being told by a Bloom filter that may rarely drop a distinct citation (0: no limit)")
+	commentedWork := flag.String("commented-work", "", "Work URN (e.g. urn:cts:greekLit:tlg0011.tlg002.perseus-grc2) that commentary sections headed only by lines, such as \"Lines 1-100\", comment on (default: the last work a section heading named)")
+	spillDir := flag.String("spill-dir", "", "Directory of citations spilled under -memory-limit (default: the system's temporary directory)")
+	inputFormat := flag.String("input-format", "", "Format input documents are read as, by registered name, e.g. tei (default: detected from each document, TEI taking any that no other format claims)")
+	runMetadata := flag.Bool("run-metadata", false, "Write run.json beside the output: the output schema version, the processor's version, the flags set, the input documents, the data files' checksums and when the run started and finished")
+	authors := flag.String("authors", "", "Only write citations of these comma-separated authors, by name or abbreviation, e.g. sophocles,aesch.,eur.")
+	urnPrefixes := flag.String("urn-prefix", "", "Only write citations whose URN begins with one of these comma-separated prefixes, e.g. urn:cts:greekLit:tlg0011 or tlg0085 (the text group and work without the namespace)")
+	minConfidence := flag.Float64("min-confidence", 0, "Only write citations resolved with at least this confidence, between 0 and 1: 1 for an exact resolution, less for a guessed work, one of several works, a misspelled author or an elliptical reference (0: off)")
+	resolveIncludes := flag.Bool("resolve-includes", false, "Expand <xi:include> elements and SYSTEM entities, relative to the including file, before extraction, so a text split into several files is processed whole; input files another input includes are not processed on their own")
+	format := flag.String("format", "jsonl", "Output format: jsonl, bibtex, csl, annotations or parquet (bibtex and csl aggregate resolved citations per work; annotations writes each as a W3C Web Annotation to annotations.jsonl; parquet writes all citations to citations.parquet)")
 	flag.Parse()
 
+	logger, err := newLogger(os.Stderr, *logLevel, *logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	slog.SetDefault(logger)
+
 	config := Config{
-		InputDir:       *inputDir,
-		OutputDir:      *outputDir,
-		ResolvedFile:   "resolved.jsonl",
-		UnresolvedFile: "unresolved.jsonl",
-		UseCitTags:     !*noCitTags,
+		InputDir:        *inputDir,
+		InputFormat:     *inputFormat,
+		OutputDir:       *outputDir,
+		ResolvedFile:    "resolved.jsonl",
+		UnresolvedFile:  "unresolved.jsonl",
+		UseCitTags:      !*noCitTags,
+		Format:          *format,
+		Strict:          *strict,
+		Aggressive:      *aggressive,
+		Prose:           *prose || *proseCue,
+		ProseCue:        *proseCue,
+		EntityFile:      *entityFile,
+		AmbiguousOnly:   *ambiguousOnly,
+		Authors:         *authors,
+		URNPrefixes:     *urnPrefixes,
+		MinConfidence:   *minConfidence,
+		CorrectionsFile: *corrections,
+		PartitionBy:     *partitionBy,
+		DryRun:          *dryRun,
+		DryRunExamples:  *dryRunExamples,
+		ScaifeURLs:      *scaifeURLs,
+		WorkFallback:    *workFallback,
+		PassageFormat:   *passageFormat,
+		PassageWidth:    *passageWidth,
+		SelfCheck:       *selfCheck,
+		SelfCheckSeed:   *selfCheckSeed,
+		Provisional:     *provisional,
+		Secondary:       *secondary,
+		Unique:          *unique,
+		FuzzyAuthors:    *fuzzyAuthors,
+		Prior:           *prior,
+		PriorFrom:       *priorFrom,
+		Deterministic:   *deterministic,
+		SkipQuoted:      *skipQuoted,
+		CacheFile:       *cacheFile,
+		ExcludeElements: *excludeElements,
+		Provenance:      *provenance,
+		Append:          *appendOutput,
+		GapReport:       *gapReport,
+		MemoryLimit:     *memoryLimit,
+		SpillDir:        *spillDir,
+		CommentedWork:   *commentedWork,
+		TimestampOutput: *timestampOutput,
+		QuoteLookahead:  *quoteLookahead,
+		QuoteLookbehind: *quoteLookbehind,
+		RunMetadata:     *runMetadata,
+		ResolveIncludes: *resolveIncludes,
+		IO: IOOptions{
+			BufferSize:   *writeBuffer,
+			Fsync:        *fsync,
+			SyncInterval: *fsyncInterval,
+			MaxRate:      *maxWriteRate,
+			Gzip:         *gzipOutput,
+		},
+		Context: ContextOptions{
+			Size:      *contextSize,
+			StripTags: *contextStrip,
+			Expand:    *contextExpand,
+			Policy:    *contextPolicy,
+		},
 	}
 
-	processor, err := NewCitationProcessor(config)
+	if config.RunMetadata {
+		config.RunFlags = setFlags(flag.CommandLine)
+	}
+
+	if config.Strict && config.Aggressive {
+		slog.Error("-strict and -aggressive cannot be combined")
+		os.Exit(2)
+	}
+
+	profile, err := startProfiling(*cpuProfile, *memProfile)
 	if err != nil {
-		log.Fatalf("Error creating processor: %v", err)
+		slog.Error("error starting profiling", "error", err)
+		os.Exit(1)
 	}
 
-	if err := processor.ProcessAllXMLFiles(); err != nil {
-		log.Fatalf("Error processing files: %v", err)
+	// The profile covers loading the data as well as processing
+	processor, err := NewCitationProcessor(config)
+	if err != nil {
+		slog.Error("error creating processor", "error", err)
+	} else {
+		if *printDataSummary {
+			writeDataSummary(os.Stderr, loader.FindDataDir(), processor.Resolver.Data.Summary())
+		}
+		if err = processor.ProcessAllXMLFiles(); err != nil {
+			slog.Error("error processing files", "error", err)
+		}
+	}
+	if stopErr := profile.Stop(); stopErr != nil {
+		slog.Error("error writing profile", "error", stopErr)
+		os.Exit(1)
+	}
+	if err != nil {
+		os.Exit(1)
 	}
 
-	fmt.Println("Citation processing completed successfully")
+	if config.OutputDir != stdoutOutput && !config.DryRun {
+		fmt.Println("Citation processing completed successfully")
+	}
 }
 
 func (cp *CitationProcessor) ProcessAllXMLFiles() error {
+	// Find all XML files named by the input
+	xmlFiles, err := inputFiles(cp.Config.InputDir)
+	if err != nil {
+		return err
+	}
+	// Files are processed one at a time, so their order is the only
+	// scheduling that reaches the output
+	xmlFiles = sortedFiles(xmlFiles)
+	if cp.Config.ResolveIncludes {
+		// A part is processed within the file including it, not again
+		included := includedFiles(xmlFiles)
+		var whole []string
+		for _, xmlFile := range xmlFiles {
+			if included[filepath.Clean(xmlFile)] {
+				slog.Info("skipping file included by another input", "file", xmlFile)
+				continue
+			}
+			whole = append(whole, xmlFile)
+		}
+		xmlFiles = whole
+	}
 
-	// Create output directory
-	if err := os.MkdirAll(cp.Config.OutputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+	var workspace *runWorkspace
+	if cp.Config.OutputDir != stdoutOutput && !cp.Config.DryRun {
+		// Write to a workspace of this run's own, published when it ends
+		if workspace, err = newRunWorkspace(cp.Config.OutputDir); err != nil {
+			return err
+		}
+		defer workspace.discard()
+		if cp.Config.Append {
+			// hold the output directory's lock from reading the numbers
+			// continued from until the run's own are published
+			workspace.append = true
+			if err := workspace.lock(); err != nil {
+				return err
+			}
+			if cp.continuation, err = cp.existingCitationNumbers(); err != nil {
+				return fmt.Errorf("failed to read existing output: %w", err)
+			}
+		}
+		if cp.Writer, err = newCitationWriter(cp, workspace.dir); err != nil {
+			return err
+		}
 	}
 
-	// Clean existing output files
-	resolvedPath := filepath.Join(cp.Config.OutputDir, cp.Config.ResolvedFile)
-	unresolvedPath := filepath.Join(cp.Config.OutputDir, cp.Config.UnresolvedFile)
+	for _, xmlFile := range xmlFiles {
+		slog.Info("processing file", "file", xmlFile)
+		if err := cp.ProcessXMLFile(xmlFile); err != nil {
+			slog.Error("error processing file", "file", xmlFile, "error", err)
+			continue
+		}
+	}
 
-	os.Remove(resolvedPath)
-	os.Remove(unresolvedPath)
+	if err := cp.Writer.Close(); err != nil {
+		return err
+	}
+	if workspace != nil && cp.Config.RunMetadata {
+		if err := cp.writeRunMetadata(workspace.dir, xmlFiles); err != nil {
+			return err
+		}
+		// run.json describes the last run, even appending
+		workspace.replace = map[string]bool{cp.runFileName(): true}
+	}
+	if workspace != nil {
+		if err := workspace.publish(cp.previousOutput()); err != nil {
+			return err
+		}
+	}
+	if cp.cache != nil {
+		if err := cp.cache.save(); err != nil {
+			return err
+		}
+	}
+	if cp.gaps != nil {
+		if err := cp.gaps.write(cp.Config.GapReport); err != nil {
+			return err
+		}
+	}
+	return cp.runSelfCheck()
+}
 
-	// Find all XML files in the input directory
-	pattern := filepath.Join(cp.Config.InputDir, "*.xml")
-	xmlFiles, err := filepath.Glob(pattern)
-	if err != nil {
-		return fmt.Errorf("error finding XML files: %w", err)
+// previousOutput returns the files of the output directory that a run
+// replaces, so that none of a previous run's output is left beside its own,
+// whether or not it was compressed. With Config.TimestampOutput nothing is
+// replaced.
+func (cp *CitationProcessor) previousOutput() []string {
+	if cp.Config.TimestampOutput {
+		return nil
 	}
-	for _, xmlFile := range xmlFiles {
-		fmt.Printf("Processing %s...\n", xmlFile)
-		if err := cp.ProcessXMLFile(xmlFile); err != nil {
-			log.Printf("Error processing %s: %v", xmlFile, err)
+	resolvedPath := filepath.Join(cp.Config.OutputDir, cp.Config.ResolvedFile)
+	files := []string{
+		resolvedPath,
+		filepath.Join(cp.Config.OutputDir, cp.Config.UnresolvedFile),
+		filepath.Join(cp.Config.OutputDir, documentsFile),
+		filepath.Join(cp.Config.OutputDir, provisionalFile),
+		filepath.Join(cp.Config.OutputDir, proseFile),
+		filepath.Join(cp.Config.OutputDir, secondaryFile),
+		filepath.Join(cp.Config.OutputDir, uniqueFile),
+		filepath.Join(cp.Config.OutputDir, annotationsFile),
+		filepath.Join(cp.Config.OutputDir, parquetFile),
+		filepath.Join(cp.Config.OutputDir, runFile),
+	}
+	var previous []string
+	for _, file := range files {
+		previous = append(previous, outputVariants(file)...)
+	}
+	if cp.Config.PartitionBy != "" {
+		for _, file := range outputVariants(resolvedPath) {
+			partitions, _ := filepath.Glob(partitionPath(file, "*"))
+			previous = append(previous, partitions...)
+		}
+	}
+	return previous
+}
+
+// inputFiles expands the -input value, a comma-separated list of directories
+// (standing for the XML files directly in them), XML files and glob patterns
+func inputFiles(input string) ([]string, error) {
+	var files []string
+	for _, path := range strings.Split(input, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
 			continue
 		}
+		info, err := os.Stat(path)
+		switch {
+		case err == nil && info.IsDir():
+			matches, err := filepath.Glob(filepath.Join(path, "*.xml"))
+			if err != nil {
+				return nil, fmt.Errorf("error finding XML files: %w", err)
+			}
+			files = append(files, matches...)
+		case err == nil:
+			files = append(files, path)
+		case strings.ContainsAny(path, "*?["):
+			matches, err := filepath.Glob(path)
+			if err != nil {
+				return nil, fmt.Errorf("bad input pattern %s: %w", path, err)
+			}
+			files = append(files, matches...)
+		default:
+			return nil, fmt.Errorf("input %s: %w", path, err)
+		}
 	}
+	return files, nil
+}
 
-	return nil
+// sortedFiles sorts input files by path and drops any named twice, so the
+// order of -input entries does not change a run's output. Paths
+// are compared with forward slashes, so a run sorts them alike on every
+// platform.
+func sortedFiles(files []string) []string {
+	seen := make(map[string]bool, len(files))
+	var sorted []string
+	for _, file := range files {
+		if clean := filepath.Clean(file); !seen[clean] {
+			seen[clean] = true
+			sorted = append(sorted, clean)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return filepath.ToSlash(sorted[i]) < filepath.ToSlash(sorted[j]) })
+	return sorted
 }
 
 func (cp *CitationProcessor) ProcessXMLFile(filename string) error {
@@ -116,55 +607,200 @@ func (cp *CitationProcessor) ProcessXMLFile(filename string) error {
 	if err != nil {
 		return fmt.Errorf("failed to read file %s: %w", filename, err)
 	}
+	if cp.Config.ResolveIncludes {
+		expanded, err := resolveIncludes(string(content), filename)
+		if err != nil {
+			return fmt.Errorf("failed to resolve includes of %s: %w", filename, err)
+		}
+		return cp.processDocument(expanded, filename)
+	}
+	return cp.processDocument(string(content), filename)
+}
+
+// ProcessReader processes a document read from r as ProcessXMLFile does a
+// file, for documents that are not on disk. name stands for the file name in
+// the output, e.g. in the filename field and doc_cit_urn values.
+func (cp *CitationProcessor) ProcessReader(r io.Reader, name string) error {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return cp.processDocument(string(content), name)
+}
+
+// ExtractFromString extracts and resolves the citations of an in-memory
+// document without writing them, after the same checks as ProcessXMLFile:
+// it fails if the document is not well-formed, and keeps only ambiguous
+// citations with Config.AmbiguousOnly
+func (cp *CitationProcessor) ExtractFromString(xmlContent, name string) ([]Citation, error) {
+	if err := cp.validateDocument(xmlContent, name); err != nil {
+		return nil, err
+	}
+	return cp.selectCitations(cp.ExtractCitations(xmlContent, name)), nil
+}
 
-	// Extract citations from XML content
-	citations := cp.ExtractCitations(string(content), filename)
+// processDocument extracts the citations of a document and writes them, in
+// batches if they were spilled to disk under Config.MemoryLimit
+func (cp *CitationProcessor) processDocument(content, filename string) error {
+	if err := cp.validateDocument(content, filename); err != nil {
+		return err
+	}
+	set, text := cp.extractCitationSet(content, filename, cp.Config.MemoryLimit)
+	defer set.close()
 
 	// Write citations to appropriate output files
-	return cp.WriteCitations(citations)
+	written := 0
+	err := set.each(func(batch []Citation) error {
+		citations := cp.selectCitations(cp.finishCitations(text, filename, batch))
+		written += len(citations)
+		return cp.WriteCitations(citations)
+	})
+	if err != nil {
+		return err
+	}
+	if cp.continuation != nil {
+		cp.continuation[cp.docID] = cp.Counter
+	}
+	if writer, ok := cp.Writer.(documentWriter); ok {
+		record := documentRecord{Filename: filename, DocID: cp.docID, LastCitation: cp.Counter, Citations: written}
+		if cp.document != nil {
+			record.DocumentMetadata = *cp.document
+		}
+		return writer.WriteDocument(record)
+	}
+	return nil
+}
+
+// selectCitations keeps the citations a run writes: only the ambiguous ones
+// with Config.AmbiguousOnly, and those passing the filter of Config.Authors,
+// URNPrefixes and MinConfidence
+func (cp *CitationProcessor) selectCitations(citations []Citation) []Citation {
+	if cp.Config.AmbiguousOnly {
+		citations = ambiguousCitations(citations)
+	}
+	if cp.filter == nil {
+		return citations
+	}
+	var kept []Citation
+	for _, citation := range citations {
+		if cp.filter.keep(citation) {
+			kept = append(kept, citation)
+		}
+	}
+	return kept
+}
+
+// ambiguousCitations keeps only the citations that have candidate URNs
+func ambiguousCitations(citations []Citation) []Citation {
+	var ambiguous []Citation
+	for _, citation := range citations {
+		if len(citation.Candidates) > 0 {
+			ambiguous = append(ambiguous, citation)
+		}
+	}
+	return ambiguous
 }
 
 func (cp *CitationProcessor) ExtractCitations(xmlContent, filename string) []Citation {
+	set, text := cp.extractCitationSet(xmlContent, filename, 0)
 	var allCitations []Citation
+	// with no memory limit, everything is held and nothing can fail
+	set.each(func(citations []Citation) error {
+		allCitations = cp.finishCitations(text, filename, citations)
+		return nil
+	})
+	return allCitations
+}
 
-	if cp.Config.UseCitTags {
-		// Comprehensive extraction approach - find all citation patterns regardless of XML structure
-		allCitations = cp.extractAllCitationPatterns(xmlContent, filename)
-	} else {
-		// Original behavior: only extract <bibl> tags
-		allCitations = cp.extractBiblTags(xmlContent, filename)
+// extractCitationSet extracts the citations of a document into a set held
+// within memoryLimit bytes (0: no limit), returning with it the document's
+// text for finishCitations. The document is read as the format formatFor
+// gives.
+func (cp *CitationProcessor) extractCitationSet(content, filename string, memoryLimit int64) (*citationSet, string) {
+	set := newCitationSet(memoryLimit, cp.Config.SpillDir)
+	text := cp.formatFor(filename, content).Extract(cp, set, content, filename)
+	return set, text
+}
+
+// finishCitations completes extracted citations, all of a document's or a
+// batch of them in document order, numbering them, then with what does not
+// depend on the others: text selectors,
+// the document's metadata and Scaife links, dropping quoted citations with
+// Config.SkipQuoted. Elliptical references are resolved against what the
+// document cited before them, in earlier batches too.
+func (cp *CitationProcessor) finishCitations(text, filename string, citations []Citation) []Citation {
+	if cp.Config.Format == "annotations" {
+		setTextSelectors(text, citations)
 	}
 
-	return allCitations
+	if cp.Config.SkipQuoted {
+		var dropped int
+		if citations, dropped = dropQuoted(citations); dropped > 0 {
+			slog.Debug("dropped citations inside quotations", "file", filename, "citations", dropped)
+		}
+	}
+
+	cp.numberCitations(citations)
+	cp.resolveElliptical(filename, citations)
+
+	for i := range citations {
+		citations[i].Document = cp.document
+		citations[i].DocumentID = cp.rowID
+		citations[i].Commented = cp.commentedAt(citations[i].span.start)
+		citations[i].Type = citationType(text, citations[i])
+		cp.Config.Context.redact(&citations[i])
+		if cp.Config.ScaifeURLs {
+			citations[i].ScaifeURL = scaifeURL(citations[i].URN)
+		}
+	}
+	return citations
 }
 
+// biblElementPattern matches a <bibl> element, including a self-closing one
+// such as <bibl n="Hom. Il. 1.1"/>, whose reference is its n attribute
+// alone; without the first alternative, the start tag of a self-closing
+// element would run on to the end tag of the next <bibl>. The content may
+// run over a line where a page or line break interrupts it.
+const biblElementPattern = `<bibl\b[^>]*/>|<bibl\b(?:[^>]*[^/>])?>(?:\s*(?:` + milestonePattern + `)\s*|.)*?</bibl>`
+
+// citElementPattern matches a <cit> element, passing over self-closing ones
+// as biblElementPattern does
+const citElementPattern = `<cit\b[^>]*/>|<cit\b(?:[^>]*[^/>])?>.*?</cit>`
+
+var (
+	biblRegex = regexp.MustCompile(biblElementPattern)
+	// the quote of a <cit>, which may run over several lines
+	citQuoteRegex = regexp.MustCompile(`(?s)<quote[^>]*>(.*?)</quote>`)
+)
+
 // extractBiblTags extracts citations using <bibl> tags directly (original method)
 func (cp *CitationProcessor) extractBiblTags(xmlContent, filename string) []Citation {
-	// Regex to find <bibl> elements
-	biblRegex := regexp.MustCompile(`<bibl[^>]*>.*?</bibl>`)
-	matches := biblRegex.FindAllStringSubmatch(xmlContent, -1)
+	matches := biblRegex.FindAllStringIndex(xmlContent, -1)
+
+	var parents parentIndex
+	if cp.Config.Strict {
+		parents = buildParentIndex(xmlContent)
+	}
 
 	var citations []Citation
 
-	for _, match := range matches {
-		if len(match) > 0 {
-			citation := cp.ProcessCitation(match[0], xmlContent, filename)
-			citations = append(citations, citation)
+	for _, loc := range matches {
+		if cp.Config.Strict && !parents.allowsCitation("bibl", loc[0]) {
+			continue
 		}
+		citation := cp.processCitationAt(xmlContent[loc[0]:loc[1]], xmlContent, filename, loc[0])
+		citation.span = byteSpan{start: loc[0], end: loc[1]}
+		citations = append(citations, citation)
+		citations = append(citations, cp.listMembers(citation)...)
 	}
 
 	return citations
 }
 
-// processCitationTag processes a single <cit> element containing <bibl> and <quote>
-func (cp *CitationProcessor) processCitationTag(citMatch, xmlContent, filename string) Citation {
-	cp.CounterMux.Lock()
-	cp.Counter++
-	citURN := fmt.Sprintf(":citations-%d.%d", 1, cp.Counter)
-	cp.CounterMux.Unlock()
-
+// processCitationTag processes a single <cit> element containing <bibl> and
+// <quote>, found at the byte offset start
+func (cp *CitationProcessor) processCitationTag(citMatch, xmlContent, filename string, start int) Citation {
 	// Extract bibl element from within the cit tag
-	biblRegex := regexp.MustCompile(`<bibl[^>]*>.*?</bibl>`)
 	biblMatch := biblRegex.FindString(citMatch)
 
 	if biblMatch == "" {
@@ -172,49 +808,36 @@ func (cp *CitationProcessor) processCitationTag(citMatch, xmlContent, filename s
 		return Citation{}
 	}
 
-	// Extract quote element from within the cit tag
-	quoteRegex := regexp.MustCompile(`(?s)<quote[^>]*>(.*?)</quote>`)
-	quoteMatches := quoteRegex.FindStringSubmatch(citMatch)
-	var quote string
-	if len(quoteMatches) > 1 {
-		quote = strings.TrimSpace(quoteMatches[1])
-	}
-
 	// Extract n attribute from bibl tag
 	nAttr := cp.extractAttribute(biblMatch, "n")
 
 	// Extract bibl content (text between tags)
 	biblContent := cp.extractBiblContent(biblMatch)
 
-	// Get reference string for URN resolution
-	ref := cp.Resolver.GetRef(nAttr, biblContent)
+	citation := cp.newCitation(citMatch, nAttr, biblContent, citMatch, filename)
 
-	// Resolve to URN
-	var urn string
-	if ref != "" {
-		urn = cp.Resolver.GetURN(ref, citMatch, filename)
+	// Extract quote element from within the cit tag
+	if quoteMatches := citQuoteRegex.FindStringSubmatch(citMatch); len(quoteMatches) > 1 {
+		citation.Quote = normalizeQuote(quoteMatches[0])
+		citation.QuotePos = quotePosition(citMatch, biblMatch, quoteMatches[0])
 	}
 
 	// Extract context around the citation
-	context := cp.extractContext(xmlContent, citMatch, 500)
-
-	return Citation{
-		NAttrib:    nAttr,
-		Bibl:       biblContent,
-		Ref:        ref,
-		URN:        urn,
-		Quote:      quote,
-		XMLContext: context,
-		Filename:   filename,
-		DocCitURN:  citURN,
-	}
+	end := start + len(citMatch)
+	citation.XMLContext = cp.contextAt(xmlContent, start, end)
+	cp.setLocation(&citation, start, end)
+	return citation
 }
 
 func (cp *CitationProcessor) ProcessCitation(biblMatch, xmlContent, filename string) Citation {
-	cp.CounterMux.Lock()
-	cp.Counter++
-	citURN := fmt.Sprintf(":citations-%d.%d", 1, cp.Counter) // Simplified URN structure
-	cp.CounterMux.Unlock()
+	return cp.processCitationAt(biblMatch, xmlContent, filename, strings.Index(xmlContent, biblMatch))
+}
+
+// processCitationAt is ProcessCitation for the <bibl> element found at the
+// byte offset start, so that a bibl repeated in a document takes the quote
+// and context of each place it stands
+func (cp *CitationProcessor) processCitationAt(biblMatch, xmlContent, filename string, start int) Citation {
+	end := start + len(biblMatch)
 
 	// Extract n attribute
 	nAttr := cp.extractAttribute(biblMatch, "n")
@@ -222,119 +845,204 @@ func (cp *CitationProcessor) ProcessCitation(biblMatch, xmlContent, filename str
 	// Extract bibl content
 	biblContent := cp.extractBiblContent(biblMatch)
 
-	// Extract quote (look for quote element after bibl)
-	quote := cp.extractQuote(xmlContent, biblMatch)
+	// Extract context (500 chars before and after by default)
+	context := cp.contextAt(xmlContent, start, end)
 
-	// Extract context (500 chars before and after)
-	context := cp.extractContext(xmlContent, biblMatch, 500)
+	citation := cp.newCitation(biblMatch, nAttr, biblContent, context, filename)
+	citation.XMLContext = context
 
-	// Get standardized reference
-	ref := cp.Resolver.GetRef(nAttr, biblContent)
+	// Take the quote beside the bibl, before or after it
+	citation.Quote, citation.QuotePos = cp.quoteAt(xmlContent, start, end)
+	cp.setLocation(&citation, start, end)
+	return citation
+}
 
-	// Resolve to URN
-	urn := ""
-	if ref != "" {
-		urn = cp.Resolver.GetURN(ref, context, filename)
-	}
+// newCitation numbers and resolves the citation of a bibl, given by its n
+// attribute and content, found in element (or "" for one put together from
+// parts). A bibl listing several references is resolved as the first of
+// them, the rest left to listMembers. context is what the resolver reads to
+// tell authors apart. The citation's place in the document, its quote and
+// its XML context are left to the caller.
+func (cp *CitationProcessor) newCitation(element, nAttr, biblContent, context, filename string) Citation {
+	citURN := cp.nextCitURN()
 
-	return Citation{
-		NAttrib:    nAttr,
-		Bibl:       biblContent,
-		Ref:        ref,
-		URN:        urn,
-		Quote:      quote,
-		XMLContext: context,
-		Filename:   filename,
-		DocCitURN:  citURN,
+	// Get reference string for URN resolution
+	ref, commentator, note, edition := cp.reference(nAttr, biblContent)
+
+	// A bibl listing several references gives a citation for each
+	listed := cp.referenceList(element, nAttr, biblContent)
+	if listed != nil {
+		ref, commentator, note, edition = cp.listReference(listed[0])
+	}
+	group, listed := listGroup(citURN, listed)
+
+	// Resolve to URN, or take it from a CTS link in the element
+	res, secondary, preResolved := cp.resolveCitation(element, nAttr, biblContent, ref, citURN, context, filename, listed)
+
+	citation := Citation{
+		NAttrib:     nAttr,
+		Bibl:        biblContent,
+		Ref:         ref,
+		Filename:    filename,
+		DocCitURN:   citURN,
+		Secondary:   secondary,
+		Commentator: commentator,
+		Note:        note,
+		Edition:     edition,
+		PreResolved: preResolved,
+		Group:       group,
+		listed:      listed,
 	}
+	setResolution(&citation, res)
+	return citation
 }
 
-func (cp *CitationProcessor) extractAttribute(element, attrName string) string {
-	pattern := fmt.Sprintf(`%s="([^"]*)"`, attrName)
-	re := regexp.MustCompile(pattern)
-	match := re.FindStringSubmatch(element)
-	if len(match) > 1 {
-		return match[1]
-	}
-	return ""
+// setLocation sets the fields of a citation that its place in the document
+// gives, for an element between the byte offsets start and end
+func (cp *CitationProcessor) setLocation(citation *Citation, start, end int) {
+	citation.Lemma, citation.InApparatus = cp.apparatusAt(start)
+	citation.Heading = cp.headingAt(start)
+	citation.InQuote = cp.quotedAt(start, end)
 }
 
-func (cp *CitationProcessor) extractBiblContent(biblElement string) string {
-	re := regexp.MustCompile(`<bibl[^>]*>(.*?)</bibl>`)
-	match := re.FindStringSubmatch(biblElement)
-	if len(match) > 1 {
-		return strings.TrimSpace(match[1])
-	}
-	return ""
+// reference derives the reference to resolve from the n attribute and bibl
+// content, splitting off a modern commentator cited with the ancient locus
+// (as in "Jebb on Soph. OT 100"), a trailing editor or edition (as in "Pl.
+// Rep. 327a Burnet") and a trailing note such as a speaker (as in "Soph. OT
+// 924 (Messenger)")
+func (cp *CitationProcessor) reference(nAttr, biblContent string) (ref, commentator, note, edition string) {
+	return deriveReference(cp.Resolver, nAttr, biblContent)
 }
 
-func (cp *CitationProcessor) extractQuote(xmlContent, biblMatch string) string {
-	// Find position of bibl match in content
-	index := strings.Index(xmlContent, biblMatch)
-	if index == -1 {
-		return ""
+// deriveReference implements reference for any resolver
+func deriveReference(urnResolver *resolver.URNResolver, nAttr, biblContent string) (ref, commentator, note, edition string) {
+	nEdition, nAttr := urnResolver.SplitEdition(nAttr)
+	edition, biblContent = urnResolver.SplitEdition(biblContent)
+	if edition == "" {
+		edition = nEdition
 	}
-
-	// Look for quote element after bibl
-	afterBibl := xmlContent[index+len(biblMatch):]
-	quoteRegex := regexp.MustCompile(`<quote[^>]*>(.*?)</quote>`)
-	match := quoteRegex.FindStringSubmatch(afterBibl[:min(len(afterBibl), 200)])
-
-	if len(match) > 1 {
-		return strings.TrimSpace(match[1])
+	nNote, nAttr := resolver.SplitNote(nAttr)
+	note, biblContent = resolver.SplitNote(biblContent)
+	if note == "" {
+		note = nNote
 	}
-	return ""
+	nCommentator, nAttr := urnResolver.SplitCommentator(nAttr)
+	commentator, biblContent = urnResolver.SplitCommentator(biblContent)
+	if commentator == "" {
+		commentator = nCommentator
+	}
+	return urnResolver.GetRef(nAttr, biblContent), commentator, note, edition
 }
 
-func (cp *CitationProcessor) extractContext(xmlContent, biblMatch string, contextSize int) string {
-	index := strings.Index(xmlContent, biblMatch)
-	if index == -1 {
-		return ""
+// resolveCitation resolves a bibl's reference as resolveElement does,
+// unless with Config.Secondary the bibl, which lists no other references,
+// cites modern scholarship, as "Jebb ad loc." or "Wilamowitz 1889, 23". That
+// is left unresolved, with the scholarship it cites, unless a CTS link gives
+// a URN or numberCitations finds a correction pinned to it.
+func (cp *CitationProcessor) resolveCitation(element, nAttr, biblContent, ref, citURN, context, filename string, listed []string) (res resolver.Resolution, secondary *resolver.SecondaryReference, preResolved bool) {
+	if cp.Config.Secondary && listed == nil && ctsLinkTarget(element) == "" {
+		text := firstNonEmpty(stripContextTags(biblContent), nAttr)
+		if secondary = cp.Resolver.SecondaryLiterature(text); secondary != nil {
+			res = resolver.Resolution{
+				Warnings: []string{"modern scholarship, not resolved: " + text},
+				Reason:   resolver.ReasonSecondary,
+			}
+			cp.observeResolution(filename, res)
+			return res, secondary, false
+		}
 	}
+	res, preResolved = cp.resolveElement(element, ref, citURN, context, filename)
+	return res, nil, preResolved
+}
 
-	start := max(0, index-contextSize)
-	end := min(len(xmlContent), index+len(biblMatch)+contextSize)
-
-	context := xmlContent[start:end]
-	// Clean up whitespace
-	context = regexp.MustCompile(`\s+`).ReplaceAllString(context, " ")
-	return strings.TrimSpace(context)
+// resolve resolves ref, noting when no reference could be derived at all.
+// A correction pinned to the citation's doc_cit_urn is applied by
+// numberCitations, once the citation is numbered.
+func (cp *CitationProcessor) resolve(ref, citURN, context, filename string) resolver.Resolution {
+	if ref == "" {
+		return resolver.Resolution{
+			Warnings: []string{"no reference found in n attribute or bibl content"},
+			Reason:   resolver.ReasonEmptyRef,
+		}
+	}
+	res := cp.cachedResolve(ref, context, filename)
+	if !cp.Config.Provisional {
+		res.Provisional = ""
+	}
+	cp.observeResolution(filename, res)
+	// a work chosen by the prior depends on what the run had seen by then
+	if cp.selfCheck != nil && res.URN != "" && !res.Prior {
+		cp.selfCheck.observe(selfCheckSample{Ref: ref, Context: context, Filename: filename, DocCitURN: citURN, URN: res.URN})
+	}
+	return res
 }
 
-func (cp *CitationProcessor) WriteCitations(citations []Citation) error {
-	resolvedPath := filepath.Join(cp.Config.OutputDir, cp.Config.ResolvedFile)
-	unresolvedPath := filepath.Join(cp.Config.OutputDir, cp.Config.UnresolvedFile)
+// setResolution sets the fields of a citation that a resolution gives.
+// Every citation takes them from here, so a field added to Resolution is
+// set here alone.
+func setResolution(citation *Citation, res resolver.Resolution) {
+	citation.URN = res.URN
+	citation.Warnings = res.Warnings
+	citation.reason = res.Reason
+	citation.Scheme = res.Scheme
+	citation.Violation = res.SchemeViolation
+	citation.Candidates = res.Candidates
+	citation.Works = res.Works
+	citation.Qualifier = res.Qualifier
+	citation.Fallback = res.Fallback
+	citation.Locus = res.Locus
+	citation.Provisional = res.Provisional
+	citation.FuzzyAuthor = res.FuzzyAuthor
+	citation.Provenance = res.Provenance
+	citation.PriorUsed = res.Prior
+	citation.Step = res.Step
+	citation.Confidence = res.Confidence
+	citation.Fragment = res.Fragment
+	citation.Documentary = res.Documentary
+	citation.ExternalID = res.ExternalID
+	citation.Corrected = res.Corrected
+}
 
-	resolvedFile, err := os.OpenFile(resolvedPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return err
+// cachedResolve resolves a reference, taking the resolution from the cache
+// if it is there
+func (cp *CitationProcessor) cachedResolve(ref, context, filename string) resolver.Resolution {
+	// resolution steps may answer differently for the same reference in
+	// another document
+	if cp.cache == nil || cp.Resolver.Chain.Len() > 0 {
+		return cp.Resolver.Resolve(ref, context, filename)
 	}
-	defer resolvedFile.Close()
+	withPrior := cp.prior != nil
+	if res, exists := cp.cache.lookup(ref, withPrior); exists {
+		return res
+	}
+	res := cp.Resolver.Resolve(ref, context, filename)
+	cp.cache.store(ref, res, withPrior)
+	return res
+}
 
-	unresolvedFile, err := os.OpenFile(unresolvedPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return err
+func (cp *CitationProcessor) extractAttribute(element, attrName string) string {
+	match := attributeRegex(attrName).FindStringSubmatch(element)
+	if len(match) > 1 {
+		return decodeText(match[1])
 	}
-	defer unresolvedFile.Close()
+	return ""
+}
 
-	for _, citation := range citations {
-		jsonData, err := json.Marshal(citation)
-		if err != nil {
-			continue
-		}
+var biblContentRegex = regexp.MustCompile(`(?s)<bibl\b(?:[^>]*[^/>])?>(.*?)</bibl>`)
 
-		if citation.URN != "" && citation.Ref != "" {
-			// Successfully resolved
-			resolvedFile.Write(jsonData)
-			resolvedFile.WriteString("\n")
-		} else {
-			// Failed to resolve
-			unresolvedFile.Write(jsonData)
-			unresolvedFile.WriteString("\n")
-		}
+func (cp *CitationProcessor) extractBiblContent(biblElement string) string {
+	match := biblContentRegex.FindStringSubmatch(biblElement)
+	if len(match) > 1 {
+		return strings.TrimSpace(decodeText(stripMilestones(match[1])))
 	}
+	return ""
+}
 
-	return nil
+func (cp *CitationProcessor) WriteCitations(citations []Citation) error {
+	if cp.gaps != nil {
+		cp.gaps.observe(citations)
+	}
+	return cp.Writer.Write(citations)
 }
 
 func min(a, b int) int {
@@ -351,134 +1059,131 @@ func max(a, b int) int {
 	return b
 }
 
-// extractAllCitationPatterns finds all citation patterns in any XML structure
-// This is a comprehensive approach that doesn't depend on specific XML hierarchy
-func (cp *CitationProcessor) extractAllCitationPatterns(xmlContent, filename string) []Citation {
-	var allCitations []Citation
-	citationMap := make(map[string]bool) // To avoid duplicates
+var (
+	citRegex            = regexp.MustCompile(`(?s)` + citElementPattern)
+	standaloneBiblRegex = regexp.MustCompile(biblElementPattern)
+	biblWithNRegex      = regexp.MustCompile(`<bibl\b[^>]*\bn\s*=\s*"([^"]+)"[^>]*?(?:/>|>([^<]*)</bibl>)`)
+	refRegex            = regexp.MustCompile(`<ref\b[^>]*>([^<]+)</ref>`)
+	// author.work followed by a number, as in "Soph. El. 123"
+	refCitationRegex = regexp.MustCompile(`[A-Za-z]+\.\s*[A-Za-z]*\s*\d+`)
+)
 
+// extractAllCitationPatterns finds all citation patterns in any XML structure
+// This is a comprehensive approach that doesn't depend on specific XML hierarchy.
+// Citations found by several patterns are added to set once.
+func (cp *CitationProcessor) extractAllCitationPatterns(set *citationSet, xmlContent, filename string) {
 	// Pattern 1: Extract ALL <cit> elements anywhere in the document
-	citRegex := regexp.MustCompile(`(?s)<cit\b[^>]*>.*?</cit>`)
-	citMatches := citRegex.FindAllString(xmlContent, -1)
-
-	for _, citMatch := range citMatches {
-		citation := cp.processCitationTag(citMatch, xmlContent, filename)
-		if citation.Bibl != "" {
-			key := citation.Bibl + "|" + citation.NAttrib + "|" + citation.Quote
-			if !citationMap[key] {
-				allCitations = append(allCitations, citation)
-				citationMap[key] = true
-			}
+	citMatches := citRegex.FindAllStringIndex(xmlContent, -1)
+
+	// In strict mode, only elements under their TEI P5 parents are accepted
+	var parents parentIndex
+	if cp.Config.Strict {
+		parents = buildParentIndex(xmlContent)
+	}
+
+	for _, loc := range citMatches {
+		if cp.Config.Strict && !parents.allowsCitation("cit", loc[0]) {
+			continue
+		}
+		citMatch := xmlContent[loc[0]:loc[1]]
+		citation := cp.processCitationTag(citMatch, xmlContent, filename, loc[0])
+		citation.span = byteSpan{start: loc[0], end: loc[1]}
+		if hasReference(citation) {
+			cp.addListed(set, citation)
 		}
 	}
 
 	// Pattern 2: Extract ALL standalone <bibl> elements (not within <cit>)
-	// First remove all <cit> containers to avoid double-counting
-	contentWithoutCit := citRegex.ReplaceAllString(xmlContent, "")
-	biblRegex := regexp.MustCompile(`<bibl\b[^>]*>.*?</bibl>`)
-	biblMatches := biblRegex.FindAllString(contentWithoutCit, -1)
-
-	for _, biblMatch := range biblMatches {
-		citation := cp.ProcessCitation(biblMatch, xmlContent, filename)
-		if citation.Bibl != "" {
-			key := citation.Bibl + "|" + citation.NAttrib + "|" + citation.Quote
-			if !citationMap[key] {
-				allCitations = append(allCitations, citation)
-				citationMap[key] = true
-			}
+	// First blank out all <cit> containers to avoid double-counting, keeping
+	// the offsets of the rest
+	contentWithoutCit := citRegex.ReplaceAllStringFunc(xmlContent, func(match string) string {
+		return strings.Repeat(" ", len(match))
+	})
+	biblMatches := standaloneBiblRegex.FindAllStringIndex(contentWithoutCit, -1)
+
+	if cp.Config.Strict {
+		parents = buildParentIndex(contentWithoutCit)
+	}
+
+	for _, loc := range biblMatches {
+		if cp.Config.Strict && !parents.allowsCitation("bibl", loc[0]) {
+			continue
+		}
+		biblMatch := contentWithoutCit[loc[0]:loc[1]]
+		citation := cp.processCitationAt(biblMatch, xmlContent, filename, loc[0])
+		citation.span = byteSpan{start: loc[0], end: loc[1]}
+		if hasReference(citation) {
+			cp.addListed(set, citation)
 		}
 	}
 
+	// Patterns 3 and 4 are heuristic and are skipped in strict mode
+	if cp.Config.Strict {
+		return
+	}
+
 	// Pattern 3: Look for <bibl> elements with n attributes that might have quotes nearby
 	// This catches cases where bibl and quote might not be in a formal <cit> structure
-	biblWithNRegex := regexp.MustCompile(`<bibl\b[^>]*\bn\s*=\s*"([^"]+)"[^>]*>([^<]*)</bibl>`)
-	biblWithNMatches := biblWithNRegex.FindAllStringSubmatch(xmlContent, -1)
-
-	for _, match := range biblWithNMatches {
-		if len(match) >= 3 {
-			nAttr := match[1]
-			biblContent := strings.TrimSpace(match[2])
-
-			// Look for nearby quote elements (within 500 characters)
-			biblIndex := strings.Index(xmlContent, match[0])
-			if biblIndex >= 0 {
-				start := max(0, biblIndex-250)
-				end := min(len(xmlContent), biblIndex+len(match[0])+250)
-				context := xmlContent[start:end]
-
-				quoteRegex := regexp.MustCompile(`<quote[^>]*>([^<]+)</quote>`)
-				quoteMatches := quoteRegex.FindAllStringSubmatch(context, -1)
-
-				var quote string
-				if len(quoteMatches) > 0 && len(quoteMatches[0]) > 1 {
-					quote = strings.TrimSpace(quoteMatches[0][1])
-				}
-
-				citation := cp.createCitationFromParts(nAttr, biblContent, quote, xmlContent, filename)
-				if citation.Bibl != "" {
-					key := citation.Bibl + "|" + citation.NAttrib + "|" + citation.Quote
-					if !citationMap[key] {
-						allCitations = append(allCitations, citation)
-						citationMap[key] = true
-					}
-				}
-			}
+	biblWithNMatches := biblWithNRegex.FindAllStringSubmatchIndex(xmlContent, -1)
+
+	for _, loc := range biblWithNMatches {
+		nAttr := xmlContent[loc[2]:loc[3]]
+		var biblContent string
+		if loc[4] >= 0 {
+			biblContent = strings.TrimSpace(decodeText(xmlContent[loc[4]:loc[5]]))
+		}
+
+		// Take the quote beside the bibl, as for standalone bibls
+		quote, quotePos := cp.quoteAt(xmlContent, loc[0], loc[1])
+
+		citation := cp.createCitationFromParts(nAttr, biblContent, quote, xmlContent, filename)
+		citation.QuotePos = quotePos
+		citation.Lemma, citation.InApparatus = cp.apparatusAt(loc[0])
+		citation.Heading = cp.headingAt(loc[0])
+		citation.InQuote = cp.quotedAt(loc[0], loc[1])
+		citation.span = byteSpan{start: loc[0], end: loc[1]}
+		if hasReference(citation) {
+			cp.addListed(set, citation)
 		}
 	}
 
 	// Pattern 4: Look for <ref> elements that might contain citations
 	// Be more selective - only include if they resolve to valid URNs
-	refRegex := regexp.MustCompile(`<ref\b[^>]*>([^<]+)</ref>`)
-	refMatches := refRegex.FindAllStringSubmatch(xmlContent, -1)
-
-	for _, match := range refMatches {
-		if len(match) >= 2 {
-			refContent := strings.TrimSpace(match[1])
-			// Only consider ref content that looks like a real citation (has author.work pattern)
-			if refContent != "" && regexp.MustCompile(`[A-Za-z]+\.\s*[A-Za-z]*\s*\d+`).MatchString(refContent) {
-				citation := cp.createCitationFromParts("", refContent, "", xmlContent, filename)
-				if citation.Bibl != "" && citation.URN != "" {
-					key := citation.Bibl + "|" + citation.NAttrib + "|" + citation.Quote
-					if !citationMap[key] {
-						allCitations = append(allCitations, citation)
-						citationMap[key] = true
-					}
-				}
+	refMatches := refRegex.FindAllStringSubmatchIndex(xmlContent, -1)
+
+	for _, loc := range refMatches {
+		element := xmlContent[loc[0]:loc[1]]
+		// refs with a CTS target are taken up by extractCTSLinks
+		if ctsLinkTarget(element) != "" {
+			continue
+		}
+		refContent := strings.TrimSpace(xmlContent[loc[2]:loc[3]])
+		// Only consider ref content that looks like a real citation (has author.work pattern)
+		if refContent != "" && refCitationRegex.MatchString(refContent) {
+			citation := cp.createCitationFromParts("", refContent, "", xmlContent, filename)
+			citation.Lemma, citation.InApparatus = cp.apparatusAt(loc[0])
+			citation.Heading = cp.headingAt(loc[0])
+			citation.InQuote = cp.quotedAt(loc[0], loc[1])
+			citation.span = byteSpan{start: loc[0], end: loc[1]}
+			if citation.Bibl != "" && citation.URN != "" {
+				cp.addListed(set, citation)
 			}
 		}
 	}
+}
 
-	return allCitations
+// hasReference reports whether a citation has a reference to resolve: its
+// bibl content or, for an empty or self-closing <bibl>, its n attribute
+func hasReference(citation Citation) bool {
+	return citation.Bibl != "" || citation.NAttrib != ""
 }
 
 // createCitationFromParts creates a Citation from individual components
 func (cp *CitationProcessor) createCitationFromParts(nAttr, biblContent, quote, xmlContent, filename string) Citation {
-	cp.CounterMux.Lock()
-	cp.Counter++
-	citURN := fmt.Sprintf(":citations-%d.%d", 1, cp.Counter)
-	cp.CounterMux.Unlock()
-
-	// Get reference string for URN resolution
-	ref := cp.Resolver.GetRef(nAttr, biblContent)
-
-	// Get URN if ref is valid
-	var urn string
-	if ref != "" {
-		urn = cp.Resolver.GetURN(ref, "", filename)
-	}
+	citation := cp.newCitation("", nAttr, biblContent, "", filename)
+	citation.Quote = quote
 
 	// Extract context around the citation
-	context := cp.extractContext(biblContent, xmlContent, 200)
-
-	return Citation{
-		NAttrib:    nAttr,
-		Bibl:       biblContent,
-		Ref:        ref,
-		URN:        urn,
-		Quote:      quote,
-		XMLContext: context,
-		Filename:   filename,
-		DocCitURN:  citURN,
-	}
+	citation.XMLContext = cp.extractContext(biblContent, xmlContent)
+	return citation
 }
-
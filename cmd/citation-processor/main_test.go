@@ -1,16 +1,40 @@
 package main
 
 import (
+	"archive/zip"
 	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/parquet-go/parquet-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"perseus_citation_linker/pkg/citationpb"
+	"perseus_citation_linker/pkg/cts"
+	"perseus_citation_linker/pkg/httpclient"
 	"perseus_citation_linker/pkg/resolver"
+	"perseus_citation_linker/pkg/resolvertest"
 	"perseus_citation_linker/pkg/loader"
 )
 
@@ -187,6 +211,40 @@ func TestCitationResolver(t *testing.T) {
 	}
 }
 
+func TestAbbreviationOptions(t *testing.T) {
+	// the defaults are the work tables' abbreviations, in a fixed order
+	want := []string{"o", "o.", "oe", "oe.", "oed", "oed.", "oedi", "oedi.", "oedip", "oedip.", "oedipu", "oedipu.",
+		"ot", "o.t.", "o_t", "o._t.", "oedipus", "oedipus_tyrannus", "oedipus tyrannus"}
+	if got := loader.GenerateAbbreviations("Oedipus Tyrannus", loader.DefaultAbbreviationOptions); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if got := loader.GenerateWorkAbbreviations("oedipus tyrannus"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected GenerateWorkAbbreviations to use the defaults, got %q", got)
+	}
+
+	testCases := []struct {
+		title   string
+		options loader.AbbreviationOptions
+		want    []string
+	}{
+		{"on the crown", loader.DefaultAbbreviationOptions,
+			[]string{"o", "o.", "on", "on.", "otc", "o.t.c.", "o_t_c", "o._t._c.", "c", "c.", "on the", "on_the", "on_t.", "on_the_crown", "on the crown"}},
+		{"on the crown", loader.AbbreviationOptions{MaxLen: 1},
+			[]string{"o", "o.", "otc", "o.t.c.", "o_t_c", "o._t._c.", "c", "c.", "on", "on the", "on_the", "on_the_crown", "on the crown"}},
+		// Latin has no stopwords among these words, so no initials without them
+		{"on the crown", loader.AbbreviationOptions{Language: "latin", IncludeSuspensions: true},
+			[]string{"o", "o.", "on", "on.", "otc", "o.t.c.", "o_t_c", "o._t._c.", "on the", "on_the", "on_t.", "on_the_crown", "on the crown"}},
+		{"electra", loader.AbbreviationOptions{MaxLen: 3},
+			[]string{"electrs", "e", "e.", "el", "el.", "ele", "ele.", "electra"}},
+		{"12", loader.DefaultAbbreviationOptions, nil},
+	}
+	for _, tc := range testCases {
+		if got := loader.GenerateAbbreviations(tc.title, tc.options); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q with %+v: expected %q, got %q", tc.title, tc.options, tc.want, got)
+		}
+	}
+}
+
 // TestWorkAbbreviations tests work abbreviation generation
 func TestWorkAbbreviations(t *testing.T) {
 	testCases := []struct {
@@ -276,7 +334,7 @@ func compareCitations(t *testing.T, citationType string, expected, actual []Cita
 
 	// Compare each citation
 	for i := range expected {
-		if expected[i] != actual[i] {
+		if !reflect.DeepEqual(expected[i], actual[i]) {
 			t.Errorf("%s citation %d mismatch:\nExpected: %+v\nActual: %+v",
 				citationType, i, expected[i], actual[i])
 		}
@@ -306,12 +364,9 @@ func sortCitations(citations []Citation) {
 	})
 }
 
-// TestCustomDataDir tests using a custom data directory
-// This test creates a temporary data directory, copies the required JSON files,
-// tests the custom directory functionality, and cleans up automatically
-func TestCustomDataDir(t *testing.T) {
-	// Create temporary directory for custom data files
-	tempDataDir := t.TempDir()
+// copyDataFiles copies the required data files into dir
+func copyDataFiles(t *testing.T, dir string) {
+	t.Helper()
 
 	// Find the actual data directory to copy files from
 	var actualDataDir string
@@ -335,7 +390,7 @@ func TestCustomDataDir(t *testing.T) {
 
 	for _, filename := range requiredFiles {
 		sourcePath := filepath.Join(actualDataDir, filename)
-		destPath := filepath.Join(tempDataDir, filename)
+		destPath := filepath.Join(dir, filename)
 
 		// Read source file
 		data, err := os.ReadFile(sourcePath)
@@ -349,6 +404,16 @@ func TestCustomDataDir(t *testing.T) {
 			t.Fatalf("Failed to write %s: %v", destPath, err)
 		}
 	}
+}
+
+// TestCustomDataDir tests using a custom data directory
+// This test creates a temporary data directory, copies the required JSON files,
+// tests the custom directory functionality, and cleans up automatically
+func TestCustomDataDir(t *testing.T) {
+	// Create temporary directory for custom data files
+	tempDataDir := t.TempDir()
+
+	copyDataFiles(t, tempDataDir)
 
 	// Test 1: LoadComprehensiveDataDir with custom directory
 	t.Run("LoadComprehensiveDataDir", func(t *testing.T) {
@@ -456,4 +521,4878 @@ func BenchmarkCitationProcessing(b *testing.B) {
 			b.Fatalf("Failed to process XML file: %v", err)
 		}
 	}
-}
\ No newline at end of file
+}
+// TestWorkExport checks that the bibtex and csl formats aggregate resolved citations per work
+func TestWorkExport(t *testing.T) {
+	testDataDir := findTestDataDir()
+	xmlFile := filepath.Join(testDataDir, "xml/campbell-sophlanguage-2.xml")
+
+	tests := []struct {
+		format   string
+		output   string
+		expected []string
+	}{
+		{
+			format: "bibtex",
+			output: "citations.bib",
+			expected: []string{
+				"@book{tlg0011_tlg004_perseus_grc2,",
+				"author = {Sophocles},",
+				"url = {urn:cts:greekLit:tlg0011.tlg004.perseus-grc2},",
+			},
+		},
+		{
+			format: "csl",
+			output: "citations.csl.json",
+			expected: []string{
+				`"id": "tlg0011_tlg004_perseus_grc2"`,
+				`"literal": "Sophocles"`,
+				`"URL": "urn:cts:greekLit:tlg0011.tlg004.perseus-grc2"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			outputDir := t.TempDir()
+			processor, err := NewCitationProcessor(Config{
+				OutputDir:  outputDir,
+				UseCitTags: false,
+				Format:     tt.format,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create citation processor: %v", err)
+			}
+			if err := processor.ProcessXMLFile(xmlFile); err != nil {
+				t.Fatalf("Failed to process XML file: %v", err)
+			}
+			if err := processor.Writer.Close(); err != nil {
+				t.Fatalf("Failed to write %s output: %v", tt.format, err)
+			}
+
+			content, err := os.ReadFile(filepath.Join(outputDir, tt.output))
+			if err != nil {
+				t.Fatalf("Failed to read %s: %v", tt.output, err)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(string(content), expected) {
+					t.Errorf("Expected %q in %s output", expected, tt.format)
+				}
+			}
+		})
+	}
+
+	if _, err := NewCitationProcessor(Config{Format: "xml"}); err == nil {
+		t.Error("Expected an error for an unknown output format")
+	}
+
+	// the characters special to BibTeX are escaped, a backslash without its
+	// replacement's braces being escaped again
+	var bib bytes.Buffer
+	if err := writeBibTeX(&bib, []workEntry{{WorkURN: "urn:cts:latinLit:phi0001.phi001", Author: `A\B {C} & D`, Title: `50% #1 $2 a_b ~c ^d`, Count: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`author = {A\textbackslash{}B \{C\} \& D},`,
+		`title = {50\% \#1 \$2 a\_b \textasciitilde{}c \textasciicircum{}d},`,
+	} {
+		if !strings.Contains(bib.String(), expected) {
+			t.Errorf("Expected %q in the BibTeX output, got:\n%s", expected, bib.String())
+		}
+	}
+}
+
+// TestStrictMode checks that strict mode keeps whitelisted citation elements and drops heuristic matches
+func TestStrictMode(t *testing.T) {
+	xmlContent := `<TEI><text><body><div>
+<p>See <cit><quote>μῆνιν ἄειδε θεά</quote><bibl n="Hom. Il. 1.1">Il. 1.1</bibl></cit>.</p>
+<p>Compare <bibl n="Soph. El. 123">El. 123</bibl>.</p>
+<gloss>cf. <bibl n="Soph. OT 151">O. T. 151</bibl></gloss>
+<p>See also <ref>Soph. Ant. 1008</ref>.</p>
+</div></body></text></TEI>`
+
+	for _, strict := range []bool{false, true} {
+		processor, err := NewCitationProcessor(Config{UseCitTags: true, Strict: strict})
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		found := make(map[string]bool)
+		for _, citation := range processor.ExtractCitations(xmlContent, "test.xml") {
+			found[citation.Bibl] = true
+		}
+
+		for _, bibl := range []string{"Il. 1.1", "El. 123"} {
+			if !found[bibl] {
+				t.Errorf("strict=%v: expected citation %q to be extracted", strict, bibl)
+			}
+		}
+		for _, bibl := range []string{"O. T. 151", "Soph. Ant. 1008"} {
+			if found[bibl] == strict {
+				t.Errorf("strict=%v: unexpected extraction result for %q", strict, bibl)
+			}
+		}
+	}
+}
+
+// TestAggressiveMode checks that pattern providers find unmarked citations and tag them
+func TestAggressiveMode(t *testing.T) {
+	xmlContent := `<TEI><text><body><p>As in Plato Rep. 6, and compare Philoctetes 901;
+the <bibl n="Soph. El. 123">El. 123</bibl> case is already marked up.</p></body></text></TEI>`
+
+	processor, err := NewCitationProcessor(Config{UseCitTags: true, Aggressive: true})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+
+	patterns := make(map[string]string)
+	for _, citation := range processor.ExtractCitations(xmlContent, "test.xml") {
+		patterns[citation.Bibl] = citation.Pattern
+	}
+
+	expected := map[string]string{
+		"Plato Rep. 6":    "author-name",
+		"Philoctetes 901": "work-title",
+		"El. 123":         "",
+	}
+	for bibl, pattern := range expected {
+		got, found := patterns[bibl]
+		if !found {
+			t.Errorf("Expected citation %q to be extracted", bibl)
+			continue
+		}
+		if got != pattern {
+			t.Errorf("Expected %q to be tagged with pattern %q, got %q", bibl, pattern, got)
+		}
+	}
+}
+
+// TestProseScanning checks that abbreviated citations in running prose are
+// kept only when they resolve unambiguously, and with ProseCue only after a
+// cue, and that they are written to prose.jsonl
+func TestProseScanning(t *testing.T) {
+	xmlContent := `<TEI><text><body><p>The policy of Pericles (cf. Thuc. 2.65) is praised;
+Soph. Ant. 1008 is cited without a cue, and Il. 2.3 is too short to trust, as is Dem. 4.2nd.
+The <bibl n="Hdt. 1.1">Hdt. 1.1</bibl> case is already marked up.</p></body></text></TEI>`
+
+	for _, tc := range []struct {
+		cue      bool
+		expected []string
+	}{
+		{false, []string{"Thuc. 2.65", "Soph. Ant. 1008"}},
+		{true, []string{"Thuc. 2.65"}},
+	} {
+		processor, err := NewCitationProcessor(Config{UseCitTags: true, Prose: true, ProseCue: tc.cue})
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		var prose []string
+		for _, citation := range processor.ExtractCitations(xmlContent, "test.xml") {
+			if citation.Pattern != prosePattern {
+				continue
+			}
+			if citation.URN == "" {
+				t.Errorf("Expected prose citation %q to be resolved", citation.Bibl)
+			}
+			prose = append(prose, citation.Bibl)
+		}
+		if !reflect.DeepEqual(prose, tc.expected) {
+			t.Errorf("With cue %v, expected prose citations %v, got %v", tc.cue, tc.expected, prose)
+		}
+	}
+
+	outputDir := t.TempDir()
+	processor, err := NewCitationProcessor(Config{UseCitTags: true, Prose: true, OutputDir: outputDir,
+		ResolvedFile: "resolved.jsonl", UnresolvedFile: "unresolved.jsonl"})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	if err := processor.ProcessReader(strings.NewReader(xmlContent), "test.xml"); err != nil {
+		t.Fatalf("ProcessReader failed: %v", err)
+	}
+	if err := processor.Writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for file, count := range map[string]int{proseFile: 2, "resolved.jsonl": 1} {
+		data, err := os.ReadFile(filepath.Join(outputDir, file))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		if lines := strings.Count(string(data), "\n"); lines != count {
+			t.Errorf("Expected %d citations in %s, got %d", count, file, lines)
+		}
+	}
+}
+
+// TestSelfClosingBibl checks that self-closing and empty <bibl> elements are
+// resolved from their n attribute, in every extraction mode, without running
+// on into the next element
+func TestSelfClosingBibl(t *testing.T) {
+	xmlContent := `<p>As <bibl n="Hom. Il. 1.1"/> says, and <bibl n="Soph. OT 150"></bibl>;` +
+		` <cit><bibl n="Hdt. 1.1" /><quote>Ἡροδότου</quote></cit> <bibl>Soph. El. 1126</bibl></p>`
+	expected := []string{
+		"urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:1.1",
+		"urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:150",
+		"urn:cts:greekLit:tlg0016.tlg001.perseus-grc2:1.1",
+		"urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:1126",
+	}
+
+	for _, config := range []Config{{UseCitTags: true}, {UseCitTags: false}, {UseCitTags: true, Strict: true}} {
+		processor, err := NewCitationProcessor(config)
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		found := make(map[string]bool)
+		for _, citation := range processor.ExtractCitations(xmlContent, "test.xml") {
+			if strings.Contains(citation.Bibl, "<") {
+				t.Errorf("UseCitTags %v, Strict %v: bibl ran on into the markup: %q", config.UseCitTags, config.Strict, citation.Bibl)
+			}
+			found[citation.URN] = true
+		}
+		for _, urn := range expected {
+			if !found[urn] {
+				t.Errorf("UseCitTags %v, Strict %v: expected %s to be extracted, got %v", config.UseCitTags, config.Strict, urn, found)
+			}
+		}
+	}
+}
+
+// TestPageBreaks checks that a bibl interrupted by page and line breaks or a
+// running header is read without them, and keeps its span in the document
+func TestPageBreaks(t *testing.T) {
+	xmlContent := "<p>See <bibl>Soph. OT <pb n=\"12\"/>\n<fw type=\"header\">OEDIPUS TYRANNUS</fw>\n264</bibl>," +
+		" <bibl>Thuc. 2.<lb/>40</bibl> and <cit><quote>μῆνιν</quote><bibl>Hom. Il<lb break=\"no\"/>. 1.1</bibl></cit>.</p>"
+	expected := map[string]string{
+		"Soph. OT 264": "urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:264",
+		"Thuc. 2. 40":  "urn:cts:greekLit:tlg0003.tlg001.perseus-grc2:2.40",
+		"Hom. Il. 1.1": "urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:1.1",
+	}
+
+	for _, config := range []Config{{UseCitTags: true}, {UseCitTags: false}, {UseCitTags: true, Strict: true}} {
+		processor, err := NewCitationProcessor(config)
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		found := make(map[string]bool)
+		for _, citation := range processor.ExtractCitations(xmlContent, "test.xml") {
+			urn, exists := expected[citation.Bibl]
+			if !exists {
+				t.Errorf("UseCitTags %v, Strict %v: unexpected bibl %q", config.UseCitTags, config.Strict, citation.Bibl)
+				continue
+			}
+			found[citation.Bibl] = true
+			if citation.URN != urn {
+				t.Errorf("UseCitTags %v, Strict %v: %q resolved to %q, expected %q", config.UseCitTags, config.Strict, citation.Bibl, citation.URN, urn)
+			}
+			if element := xmlContent[citation.span.start:citation.span.end]; !strings.HasSuffix(element, "</bibl>") && !strings.HasSuffix(element, "</cit>") {
+				t.Errorf("UseCitTags %v, Strict %v: span of %q is %q", config.UseCitTags, config.Strict, citation.Bibl, element)
+			}
+		}
+		if len(found) != len(expected) {
+			t.Errorf("UseCitTags %v, Strict %v: expected %d citations, found %v", config.UseCitTags, config.Strict, len(expected), found)
+		}
+	}
+
+	if got := stripMilestones("Soph. OT 264"); got != "Soph. OT 264" {
+		t.Errorf("stripMilestones changed content without milestones: %q", got)
+	}
+}
+
+// TestCitationTypes checks that citations are classed as quotations, bare
+// references, cross-references or parallels
+func TestCitationTypes(t *testing.T) {
+	xmlContent := `<p><cit><quote>μῆνιν ἄειδε</quote><bibl>Hom. Il. 1.1</bibl></cit>; the usage is old,` +
+		` <bibl>Soph. OT 264</bibl>; cf. <bibl>Soph. El. 1126</bibl>, so <bibl>Soph. Ant. 441</bibl>.` +
+		` <cit type="parallel"><bibl>Eur. Med. 12</bibl></cit></p>`
+	expected := map[string]string{
+		"Hom. Il. 1.1":   typeQuotation,
+		"Soph. OT 264":   typeReference,
+		"Soph. El. 1126": typeCrossReference,
+		"Soph. Ant. 441": typeParallel,
+		"Eur. Med. 12":   typeParallel,
+	}
+
+	for _, config := range []Config{{UseCitTags: true}, {UseCitTags: false}} {
+		processor, err := NewCitationProcessor(config)
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		citations, err := processor.ExtractFromString(xmlContent, "test.xml")
+		if err != nil {
+			t.Fatalf("ExtractFromString: %v", err)
+		}
+		for _, citation := range citations {
+			want := expected[citation.Bibl]
+			if citation.Type != want {
+				t.Errorf("UseCitTags %v: %q classed as %q, expected %q", config.UseCitTags, citation.Bibl, citation.Type, want)
+			}
+		}
+		if len(citations) != len(expected) {
+			t.Errorf("UseCitTags %v: expected %d citations, got %d", config.UseCitTags, len(expected), len(citations))
+		}
+	}
+}
+
+// TestQuoteAssociation checks that a bibl outside a <cit> takes the nearest
+// quote of its paragraph on either side, and not another bibl's
+func TestQuoteAssociation(t *testing.T) {
+	xmlContent := `<p><quote>μῆνιν ἄειδε</quote> <bibl>Hom. Il. 1.1</bibl>; <bibl>Soph. OT 264</bibl>` +
+		` <quote>Θήβας</quote> <gloss>Thebes</gloss> <bibl>Soph. OT 153</bibl>` +
+		` <bibl>Soph. El. 1126</bibl> <quote>ὦ φιλτάτου</quote></p><p><quote>ἄλγος</quote></p>` +
+		`<p><bibl>Soph. El. 123</bibl> <cit><bibl>Soph. Ant. 441</bibl><quote>σὲ δή</quote></cit></p>`
+	type link struct{ quote, position string }
+	tests := []struct {
+		name     string
+		config   Config
+		expected map[string]link
+	}{
+		{"default", Config{UseCitTags: true}, map[string]link{
+			"Hom. Il. 1.1":   {"μῆνιν ἄειδε", quoteBefore},
+			"Soph. OT 264":   {"Θήβας", quoteAfter},
+			"Soph. OT 153":   {},
+			"Soph. El. 1126": {"ὦ φιλτάτου", quoteAfter},
+			"Soph. El. 123":  {},
+			"Soph. Ant. 441": {"σὲ δή", quoteAfter},
+		}},
+		{"no lookbehind", Config{UseCitTags: true, QuoteLookbehind: -1}, map[string]link{
+			"Hom. Il. 1.1":   {},
+			"Soph. OT 264":   {"Θήβας", quoteAfter},
+			"Soph. OT 153":   {},
+			"Soph. El. 1126": {"ὦ φιλτάτου", quoteAfter},
+			"Soph. El. 123":  {},
+			"Soph. Ant. 441": {"σὲ δή", quoteAfter},
+		}},
+		{"short lookahead", Config{UseCitTags: true, QuoteLookahead: 1, QuoteLookbehind: 1}, map[string]link{
+			"Hom. Il. 1.1":   {"μῆνιν ἄειδε", quoteBefore},
+			"Soph. OT 264":   {"Θήβας", quoteAfter},
+			"Soph. OT 153":   {},
+			"Soph. El. 1126": {"ὦ φιλτάτου", quoteAfter},
+			"Soph. El. 123":  {},
+			"Soph. Ant. 441": {"σὲ δή", quoteAfter},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor, err := NewCitationProcessor(tt.config)
+			if err != nil {
+				t.Fatalf("Failed to create citation processor: %v", err)
+			}
+			citations, err := processor.ExtractFromString(xmlContent, "test.xml")
+			if err != nil {
+				t.Fatalf("ExtractFromString: %v", err)
+			}
+			if len(citations) != len(tt.expected) {
+				t.Errorf("Expected %d citations, got %d", len(tt.expected), len(citations))
+			}
+			for _, citation := range citations {
+				if got := (link{citation.Quote, citation.QuotePos}); got != tt.expected[citation.Bibl] {
+					t.Errorf("%q: expected quote %v, got %v", citation.Bibl, tt.expected[citation.Bibl], got)
+				}
+			}
+		})
+	}
+}
+
+// TestReferenceLists checks that a bibl listing several references gives a
+// citation for each, sharing a group_id, and that one reference is kept whole
+func TestReferenceLists(t *testing.T) {
+	xmlContent := `<p><bibl>Hom. Il. 1.1, 2.3; Od. 5.10</bibl> <bibl>Thuc. 2, 40</bibl>` +
+		` <bibl n="Soph. El. 626">El. 626; ib. 630</bibl> <bibl>Soph. OC 5, 610</bibl> <bibl>Soph. El. 359, 379, 538</bibl></p>`
+	expected := []struct{ bibl, urn, group string }{
+		{"Hom. Il. 1.1, 2.3; Od. 5.10", "urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:1.1", "test:citations-1"},
+		{"Hom. Il. 2.3", "urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:2.3", "test:citations-1"},
+		{"Hom. Od. 5.10", "urn:cts:greekLit:tlg0012.tlg002.perseus-grc2:5.10", "test:citations-1"},
+		{"Thuc. 2, 40", "", ""},
+		{"El. 626; ib. 630", "urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:626", "test:citations-5"},
+		{"Soph. El. 630", "urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:630", "test:citations-5"},
+		// a play cited by line alone: the comma separates lines, not levels
+		{"Soph. OC 5, 610", "urn:cts:greekLit:tlg0011.tlg007.perseus-grc2:5", "test:citations-7"},
+		{"Soph. OC 610", "urn:cts:greekLit:tlg0011.tlg007.perseus-grc2:610", "test:citations-7"},
+		{"Soph. El. 359, 379, 538", "urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:359", "test:citations-9"},
+		{"Soph. El. 379", "urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:379", "test:citations-9"},
+		{"Soph. El. 538", "urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:538", "test:citations-9"},
+	}
+
+	for _, useCitTags := range []bool{false, true} {
+		processor, err := NewCitationProcessor(Config{UseCitTags: useCitTags})
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		citations, err := processor.ExtractFromString(xmlContent, "test.xml")
+		if err != nil {
+			t.Fatalf("ExtractFromString: %v", err)
+		}
+		if len(citations) != len(expected) {
+			t.Fatalf("cit tags %v: expected %d citations, got %d", useCitTags, len(expected), len(citations))
+		}
+		for i, want := range expected {
+			got := citations[i]
+			if got.Bibl != want.bibl || got.Group != want.group {
+				t.Errorf("cit tags %v, citation %d: expected %q in group %q, got %q in group %q", useCitTags, i, want.bibl, want.group, got.Bibl, got.Group)
+			}
+			if want.urn != "" && got.URN != want.urn {
+				t.Errorf("cit tags %v, %q: expected URN %s, got %s", useCitTags, want.bibl, want.urn, got.URN)
+			}
+		}
+		if citations[1].Quote != "" || citations[1].NAttrib != "" || citations[1].DocCitURN != "test:citations-2" {
+			t.Errorf("cit tags %v: listed citation should be numbered next with no quote or n attribute, got %+v", useCitTags, citations[1])
+		}
+	}
+}
+
+// TestEllipticalReferences checks that "ibid.", "id.", "l.c.", "op. cit."
+// and bare line references are resolved from what the document cited before
+// them and the passage its section comments on
+func TestEllipticalReferences(t *testing.T) {
+	xmlContent := `<div><head>Soph. Ant. 441-525</head><p><bibl>l.c.</bibl> <bibl>Soph. El. 626</bibl>` +
+		` <bibl>ibid.</bibl> <bibl>ib. 630</bibl> <bibl>id. Ant. 12</bibl> <bibl>Hom. Il. 1.1</bibl>` +
+		` <bibl>op. cit. 5</bibl> <bibl>Soph. l.c.</bibl> <bibl>Soph. op. cit. 700</bibl> <bibl>v. 450</bibl>` +
+		` <bibl>vv. 450–5</bibl></p></div>`
+	type resolved struct{ urn, ellipsis string }
+	expected := []resolved{
+		{"", ""},
+		{"urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:626", ""},
+		{"urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:626", ellipsisIbid},
+		{"urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:630", ellipsisIbid},
+		{"urn:cts:greekLit:tlg0011.tlg002.perseus-grc2:12", ellipsisIdem},
+		{"urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:1.1", ""},
+		{"urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:1.5", ellipsisOpCit},
+		{"urn:cts:greekLit:tlg0011.tlg002.perseus-grc2:12", ellipsisLocCit},
+		{"urn:cts:greekLit:tlg0011.tlg002.perseus-grc2:700", ellipsisOpCit},
+		{"urn:cts:greekLit:tlg0011.tlg002.perseus-grc2:450", ellipsisLemma},
+		{"urn:cts:greekLit:tlg0011.tlg002.perseus-grc2:450-5", ellipsisLemma},
+	}
+
+	for _, useCitTags := range []bool{false, true} {
+		processor, err := NewCitationProcessor(Config{UseCitTags: useCitTags})
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		citations, err := processor.ExtractFromString(xmlContent, "test.xml")
+		if err != nil {
+			t.Fatalf("ExtractFromString: %v", err)
+		}
+		if len(citations) != len(expected) {
+			t.Fatalf("cit tags %v: expected %d citations, got %d", useCitTags, len(expected), len(citations))
+		}
+		for i, want := range expected {
+			if got := (resolved{citations[i].URN, citations[i].Ellipsis}); got != want {
+				t.Errorf("cit tags %v, %q: expected %v, got %v", useCitTags, citations[i].Bibl, want, got)
+			}
+		}
+	}
+
+	// a document's last citation is not the next one's antecedent
+	processor, err := NewCitationProcessor(Config{})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	if _, err := processor.ExtractFromString(`<p><bibl>Soph. El. 626</bibl></p>`, "first.xml"); err != nil {
+		t.Fatalf("ExtractFromString: %v", err)
+	}
+	citations, err := processor.ExtractFromString(`<p><bibl>ibid.</bibl> <bibl>v. 450</bibl></p>`, "second.xml")
+	if err != nil {
+		t.Fatalf("ExtractFromString: %v", err)
+	}
+	for _, citation := range citations {
+		if citation.URN != "" || citation.Ellipsis != "" {
+			t.Errorf("%q: expected no resolution in a new document, got %s", citation.Bibl, citation.URN)
+		}
+	}
+}
+
+// TestResolutionWarnings checks that resolution failures are kept on the citation record
+func TestResolutionWarnings(t *testing.T) {
+	processor, err := NewCitationProcessor(Config{UseCitTags: false})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+
+	xmlContent := `<p><bibl n="Xyz. Abc. 12">Xyz. Abc. 12</bibl> and <bibl n="Soph. El. 123">El. 123</bibl></p>`
+	citations := processor.ExtractCitations(xmlContent, "test.xml")
+	if len(citations) != 2 {
+		t.Fatalf("Expected 2 citations, got %d", len(citations))
+	}
+
+	if citations[0].URN != "" || len(citations[0].Warnings) == 0 {
+		t.Errorf("Expected unresolved citation with warnings, got %+v", citations[0])
+	}
+	if citations[1].URN == "" || len(citations[1].Warnings) != 0 {
+		t.Errorf("Expected resolved citation without warnings, got %+v", citations[1])
+	}
+
+	res := processor.Resolver.Resolve("xyz. abc. 12", "", "test.xml")
+	if res.URN != "" || len(res.Warnings) != 1 || !strings.Contains(res.Warnings[0], "author not recognized") {
+		t.Errorf("Expected an author warning, got %+v", res)
+	}
+}
+
+// TestNewLogger checks log level and format parsing
+func TestNewLogger(t *testing.T) {
+	var buf strings.Builder
+	logger, err := newLogger(&buf, "warn", "json")
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown", "file", "test.xml")
+
+	output := buf.String()
+	if strings.Contains(output, "hidden") {
+		t.Errorf("Info message logged at warn level: %s", output)
+	}
+	if !strings.Contains(output, `"msg":"shown"`) || !strings.Contains(output, `"file":"test.xml"`) {
+		t.Errorf("Expected JSON warning record, got %s", output)
+	}
+
+	if _, err := newLogger(&buf, "loud", "text"); err == nil {
+		t.Error("Expected error for invalid log level")
+	}
+	if _, err := newLogger(&buf, "info", "xml"); err == nil {
+		t.Error("Expected error for invalid log format")
+	}
+}
+
+// TestPreprocessing checks namespace prefix stripping and entity decoding
+func TestPreprocessing(t *testing.T) {
+	entityFile := filepath.Join(t.TempDir(), "entities.json")
+	if err := os.WriteFile(entityFile, []byte(`{"grave": "̀", "sophocles": "Soph."}`), 0644); err != nil {
+		t.Fatalf("Failed to write entity table: %v", err)
+	}
+
+	processor, err := NewCitationProcessor(Config{UseCitTags: true, EntityFile: entityFile})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+
+	xmlContent := `<tei:TEI xmlns:tei="http://www.tei-c.org/ns/1.0"><tei:text><tei:body>
+<tei:p><tei:cit><tei:quote>τᾶς &#x3c0;ολυχρύσου &amp;mdash; &lt;x&gt;</tei:quote><tei:bibl n="&sophocles; OT 151">O. T. 151</tei:bibl></tei:cit></tei:p>
+</tei:body></tei:text></tei:TEI>`
+
+	citations := processor.ExtractCitations(xmlContent, "test.xml")
+	if len(citations) != 1 {
+		t.Fatalf("Expected 1 citation, got %d", len(citations))
+	}
+	citation := citations[0]
+	if citation.NAttrib != "Soph. OT 151" {
+		t.Errorf("Expected custom entity to be expanded in n attribute, got %q", citation.NAttrib)
+	}
+	if citation.Quote != "τᾶς πολυχρύσου — <x>" {
+		t.Errorf("Expected decoded quote, got %q", citation.Quote)
+	}
+	if citation.URN != "urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:151" {
+		t.Errorf("Unexpected URN %q", citation.URN)
+	}
+
+	if got := decodeEntities("a &lt;b&gt; &#60; &mdash; &unknownentity;", nil); got != "a &lt;b&gt; &#60; — &unknownentity;" {
+		t.Errorf("Unexpected entity decoding: %q", got)
+	}
+}
+
+// TestHarvestAliases checks that catalog titles are harvested into work_aliases.json and used for resolution
+func TestHarvestAliases(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "urn:cts:greekLit:tlg0011.tlg004") {
+			fmt.Fprint(w, `{"label": "Oedipus Tyrannus", "texts": [{"label": "King  Oedipus"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"label": ""}`)
+	}))
+	defer server.Close()
+
+	dataDir := t.TempDir()
+	copyDataFiles(t, dataDir)
+
+	if err := runHarvestAliases([]string{"-data", dataDir, "-endpoint", server.URL, "-rate", "0"}); err != nil {
+		t.Fatalf("harvest-aliases failed: %v", err)
+	}
+
+	var aliases map[string]map[string]string
+	content, err := os.ReadFile(filepath.Join(dataDir, loader.WorkAliasesFile))
+	if err != nil {
+		t.Fatalf("Failed to read aliases: %v", err)
+	}
+	if err := json.Unmarshal(content, &aliases); err != nil {
+		t.Fatalf("Failed to parse aliases: %v", err)
+	}
+	if aliases["sophocles"]["king oedipus"] != "tlg004" {
+		t.Errorf("Expected harvested alias for Oedipus Tyrannus, got %v", aliases)
+	}
+	if _, exists := aliases["sophocles"]["oedipus tyrannus"]; exists {
+		t.Error("Titles already in the data files should not be added as aliases")
+	}
+
+	urnResolver, err := resolver.NewURNResolverFromDir(dataDir)
+	if err != nil {
+		t.Fatalf("Failed to create resolver: %v", err)
+	}
+	if urn := urnResolver.GetURN("soph. king oedipus 151", "", "test"); urn != "urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:151" {
+		t.Errorf("Expected alias to resolve, got %s", urn)
+	}
+}
+
+func TestSyncCatalog(t *testing.T) {
+	inventory := `<GetCapabilities xmlns="http://chs.harvard.edu/xmlns/cts"><reply><TextInventory>
+<textgroup urn="urn:cts:greekLit:tlg0011"><groupname xml:lang="eng">Sophocles</groupname>
+  <work urn="urn:cts:greekLit:tlg0011.tlg002"><title xml:lang="lat">Antigone</title>
+    <edition urn="urn:cts:greekLit:tlg0011.tlg002.perseus-grc2"><online><citationMapping>
+      <citation label="book"><citation label="line"/></citation></citationMapping></online></edition></work>
+  <work urn="urn:cts:greekLit:tlg0011.tlg099"><title xml:lang="grc">Νέα</title><title xml:lang="lat">Fabula  Nova</title>
+    <edition urn="urn:cts:greekLit:tlg0011.tlg099.opp-grc1"><online><citationMapping>
+      <citation label="page"/></citationMapping></online></edition>
+    <edition urn="urn:cts:greekLit:tlg0011.tlg099.perseus-grc1"><online><citationMapping>
+      <citation label="Line"/></citationMapping></online></edition></work>
+  <work urn="urn:cts:greekLit:tlg0011.tlg098"><title xml:lang="lat">Antigone</title></work>
+</textgroup>
+<textgroup urn="urn:cts:greekLit:tlg9999"><groupname xml:lang="eng">Novus Auctor</groupname>
+  <work urn="urn:cts:greekLit:tlg9999.tlg001"><title xml:lang="eng">Opus</title>
+    <edition urn="urn:cts:greekLit:tlg9999.tlg001.perseus-grc2"/></work>
+</textgroup>
+<textgroup urn="urn:cts:englishLit:shak"><groupname xml:lang="eng">Shakespeare</groupname></textgroup>
+</TextInventory></reply></GetCapabilities>`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("request") != "GetCapabilities" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, inventory)
+	}))
+	defer server.Close()
+
+	dataDir := t.TempDir()
+	copyDataFiles(t, dataDir)
+	schemes, err := os.ReadFile("../../data/citation_schemes.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "citation_schemes.json"), schemes, 0644); err != nil {
+		t.Fatal(err)
+	}
+	original, err := os.ReadFile(filepath.Join(dataDir, "greek_data.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+	if err := runSyncCatalog([]string{"-data", dataDir, "-endpoint", server.URL, "-rate", "0", "-dry-run"}); err != nil {
+		t.Fatalf("sync-catalog failed: %v", err)
+	}
+	for _, want := range []string{
+		"1 authors, 2 works, 1 exemplars and 1 schemes added, 1 conflicts",
+		"+ scheme sophocles tlg099: line",
+		`+ work sophocles "fabula nova": tlg099`,
+		`+ author "novus auctor": urn:cts:greekLit:tlg9999`,
+		`+ single-work author "novus auctor"`,
+		"+ exemplar urn:cts:greekLit:tlg0011.tlg099: perseus-grc1",
+		`! greek_data.json sophocles "antigone"`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in the report, got:\n%s", want, out.String())
+		}
+	}
+	if content, _ := os.ReadFile(filepath.Join(dataDir, "greek_data.json")); !bytes.Equal(content, original) {
+		t.Error("-dry-run should not change the data files")
+	}
+
+	out.Reset()
+	if err := runSyncCatalog([]string{"-data", dataDir, "-endpoint", server.URL, "-rate", "0"}); err != nil {
+		t.Fatalf("sync-catalog failed: %v", err)
+	}
+	// entries are only added, the rest of the file kept as it was
+	content, err := os.ReadFile(filepath.Join(dataDir, "greek_data.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept := 0
+	for _, line := range strings.Split(string(original), "\n") {
+		if strings.Contains(string(content), strings.TrimSuffix(line, ",")) {
+			kept++
+		}
+	}
+	if lines := strings.Count(string(original), "\n") + 1; kept != lines || len(content) <= len(original) {
+		t.Errorf("Expected every line of greek_data.json kept, kept %d of %d", kept, lines)
+	}
+	// the scheme taken from the Perseus edition's citation mapping, the
+	// others' left as they were
+	content, err = os.ReadFile(filepath.Join(dataDir, "citation_schemes.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"tlg099": "line"`, `"tlg001": {"scheme": "book.line", "max": 24}`, `"tlg002": "line"`} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("Expected %s in citation_schemes.json, got:\n%s", want, content)
+		}
+	}
+
+	urnResolver, err := resolver.NewURNResolverFromDir(dataDir)
+	if err != nil {
+		t.Fatalf("Failed to create resolver: %v", err)
+	}
+	for ref, want := range map[string]string{
+		"soph. fabula nova 12": "urn:cts:greekLit:tlg0011.tlg099.perseus-grc1:12",
+		"novus auctor 5":       "urn:cts:greekLit:tlg9999.tlg001.perseus-grc2:5",
+		"soph. ant. 10":        "urn:cts:greekLit:tlg0011.tlg002.perseus-grc2:10",
+	} {
+		if urn := urnResolver.GetURN(ref, "", "test"); urn != want {
+			t.Errorf("%s: expected %s, got %s", ref, want, urn)
+		}
+	}
+
+	// a second sync finds nothing more to add
+	out.Reset()
+	if err := runSyncCatalog([]string{"-data", dataDir, "-endpoint", server.URL, "-rate", "0", "-dry-run"}); err != nil {
+		t.Fatalf("sync-catalog failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "0 authors, 0 works, 0 exemplars and 0 schemes added") {
+		t.Errorf("Expected nothing added on a second sync, got:\n%s", out.String())
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	requests := map[string]int{}
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		n := requests[r.URL.Path]
+		mu.Unlock()
+		switch {
+		case r.URL.Path == "/missing":
+			http.NotFound(w, r)
+		case r.URL.Path == "/flaky" && n < 3:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			fmt.Fprintf(w, "body of %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	cacheDir := t.TempDir()
+	client := httpclient.New(httpclient.Options{Retries: 2, Backoff: time.Millisecond, CacheDir: cacheDir, Rate: 1000})
+	ctx := context.Background()
+
+	// retried until it succeeds, then answered from the cache
+	for i := 0; i < 2; i++ {
+		body, err := client.Get(ctx, server.URL+"/flaky")
+		if err != nil || string(body) != "body of /flaky" {
+			t.Fatalf("Expected the flaky response after retries, got %q, %v", body, err)
+		}
+	}
+	if requests["/flaky"] != 3 {
+		t.Errorf("Expected 3 requests, the last one cached, got %d", requests["/flaky"])
+	}
+
+	// client errors are not retried or cached
+	var statusErr *httpclient.StatusError
+	if _, err := client.Get(ctx, server.URL+"/missing"); !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a 404 status error, got %v", err)
+	}
+	if requests["/missing"] != 1 {
+		t.Errorf("Expected a 404 not to be retried, got %d requests", requests["/missing"])
+	}
+
+	offline := httpclient.New(httpclient.Options{CacheDir: cacheDir, Offline: true})
+	if body, err := offline.Get(ctx, server.URL+"/flaky"); err != nil || string(body) != "body of /flaky" {
+		t.Errorf("Expected the cached response offline, got %q, %v", body, err)
+	}
+	if _, err := offline.Get(ctx, server.URL+"/other"); !errors.Is(err, httpclient.ErrOffline) {
+		t.Errorf("Expected ErrOffline for an uncached request, got %v", err)
+	}
+	if requests["/other"] != 0 {
+		t.Error("Expected no request in offline mode")
+	}
+}
+
+func TestCitationSchemes(t *testing.T) {
+	res, err := resolver.NewURNResolver()
+	if err != nil {
+		t.Fatalf("Failed to create resolver: %v", err)
+	}
+
+	testCases := []struct {
+		ref    string
+		urn    string
+		scheme string
+		warned bool
+	}{
+		// line-numbered play: extra levels are a scheme violation, never
+		// cut to the first line
+		{"Soph. El. 993, 1257", "", "line", true},
+		{"Soph. OC 5, 610", "", "line", true},
+		// an abbreviated range end is not reversed
+		{"Soph. El. 226-8", "urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:226-8", "line", false},
+		// book.chapter work: the comma separates levels
+		{"Hdt. 2, 40", "urn:cts:greekLit:tlg0016.tlg001.perseus-grc2:2.40", "book.chapter", false},
+		// Roman numeral book restored for a single-work author
+		{"Thuc. ii. 62.", "urn:cts:greekLit:tlg0003.tlg001.perseus-grc2:2.62", "book.chapter", false},
+		{"Hom. Il. 1.1", "urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:1.1", "book.line", false},
+		// no scheme recorded: passage left as cited
+		{"Plat. Rep. 338d", "urn:cts:greekLit:tlg0059.tlg030.perseus-grc2:338d", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.ref, func(t *testing.T) {
+			r := res.Resolve(res.GetRef("", tc.ref), "", "test.xml")
+			if r.URN != tc.urn || r.Scheme != tc.scheme {
+				t.Errorf("Expected %s (%q), got %s (%q)", tc.urn, tc.scheme, r.URN, r.Scheme)
+			}
+			if warned := len(r.Warnings) > 0; warned != tc.warned {
+				t.Errorf("Expected a warning %v, got %v", tc.warned, r.Warnings)
+			}
+		})
+	}
+}
+
+func TestContextOptions(t *testing.T) {
+	xmlContent := `<div><p>Unrelated text. The phrase recurs, see <cit><bibl n="Soph. El. 123">El. 123</bibl> <quote>ἄλγος</quote></cit> for it. Later &amp; more.</p><p>Other.</p></div>`
+
+	testCases := []struct {
+		name     string
+		opts     ContextOptions
+		expected string
+	}{
+		{"sentence", ContextOptions{StripTags: true, Expand: "sentence"}, "The phrase recurs, see El. 123 ἄλγος for it."},
+		{"parent", ContextOptions{StripTags: true, Expand: "parent"}, "Unrelated text. The phrase recurs, see El. 123 ἄλγος for it. Later & more."},
+		{"size", ContextOptions{Size: 9}, `urs, see <cit><bibl n="Soph. El. 123">El. 123</bibl> <quote>ἄλγος</quote></cit> for it.`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			processor, err := NewCitationProcessor(Config{UseCitTags: true, Context: tc.opts})
+			if err != nil {
+				t.Fatalf("Failed to create citation processor: %v", err)
+			}
+			citations := processor.ExtractCitations(xmlContent, "test.xml")
+			if len(citations) != 1 {
+				t.Fatalf("Expected 1 citation, got %d", len(citations))
+			}
+			if citations[0].XMLContext != tc.expected {
+				t.Errorf("Expected context %q, got %q", tc.expected, citations[0].XMLContext)
+			}
+		})
+	}
+
+	if _, err := NewCitationProcessor(Config{Context: ContextOptions{Expand: "paragraph"}}); err == nil {
+		t.Error("Expected an error for an unknown context expansion")
+	}
+}
+
+// TestContextWindow checks that the context window counts characters, so
+// that Greek is never cut inside a letter, and does not start or end inside
+// a tag or an entity
+func TestContextWindow(t *testing.T) {
+	greek := strings.Repeat("ἄλγος ", 20)
+	xmlContent := `<p>` + greek + `<hi rend="italic">x</hi> <bibl>Soph. El. 123</bibl> &amp;` + greek + `</p>`
+	cite := strings.Index(xmlContent, "<bibl>")
+	end := cite + len("<bibl>Soph. El. 123</bibl>")
+
+	for size := 1; size <= 140; size++ {
+		start, stop := contextWindow(xmlContent, cite, end, size)
+		context := xmlContent[start:stop]
+		if !utf8.ValidString(context) {
+			t.Fatalf("size %d: invalid UTF-8 in %q", size, context)
+		}
+		if utf8.RuneCountInString(xmlContent[start:cite]) > size || utf8.RuneCountInString(xmlContent[end:stop]) > size {
+			t.Errorf("size %d: window %q is wider than %d characters either side", size, context, size)
+		}
+		if partialTagStartRegex.MatchString(context) || partialTagEndRegex.MatchString(context) {
+			t.Errorf("size %d: window %q cuts a tag", size, context)
+		}
+		if amp := strings.LastIndex(context, "&"); amp >= 0 && !strings.Contains(context[amp:], ";") {
+			t.Errorf("size %d: window %q cuts an entity", size, context)
+		}
+		if strings.HasPrefix(context, "amp;") || strings.HasPrefix(context, "mp;") || strings.HasPrefix(context, "p;") {
+			t.Errorf("size %d: window %q starts inside an entity", size, context)
+		}
+	}
+
+	// an entity cut at the start of the window
+	start, _ := contextWindow("x &amp; <bibl>Il. 1.1</bibl>", 8, 28, 4)
+	if start != 7 {
+		t.Errorf("Expected the window to start after the cut entity, at 7, got %d", start)
+	}
+}
+
+func TestContextPolicy(t *testing.T) {
+	long := strings.Repeat("μῆνιν ἄειδε θεὰ ", 10)
+	xmlContent := `<p>` + strings.Repeat("Preceding commentary. ", 10) + `<cit><bibl>Hom. Il. 1.1</bibl><quote>` + long + `</quote></cit>` + strings.Repeat(" Following commentary.", 10) + `</p>`
+
+	extract := func(policy string) Citation {
+		t.Helper()
+		processor, err := NewCitationProcessor(Config{UseCitTags: true, Context: ContextOptions{Policy: policy}})
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		citations := processor.ExtractCitations(xmlContent, "test.xml")
+		if len(citations) != 1 {
+			t.Fatalf("Expected 1 citation, got %d", len(citations))
+		}
+		return citations[0]
+	}
+
+	verbatim := extract("verbatim")
+
+	truncated := extract("truncate")
+	if len(truncated.XMLContext) >= len(verbatim.XMLContext) || !strings.Contains(truncated.XMLContext, "<bibl>Hom. Il. 1.1</bibl>") {
+		t.Errorf("Expected a shorter context around the citation, got %q", truncated.XMLContext)
+	}
+	if !strings.HasSuffix(truncated.Quote, "…") || utf8.RuneCountInString(truncated.Quote) > truncatedQuoteLength+1 || !strings.HasPrefix(verbatim.Quote, strings.TrimSuffix(truncated.Quote, "…")) {
+		t.Errorf("Expected the quote cut to %d characters, got %q", truncatedQuoteLength, truncated.Quote)
+	}
+
+	hashed := extract("hash")
+	if hashed.XMLContext != hashText(verbatim.XMLContext) || hashed.Quote != hashText(verbatim.Quote) || !strings.HasPrefix(hashed.Quote, "sha256:") {
+		t.Errorf("Expected hashed context and quote, got %q and %q", hashed.XMLContext, hashed.Quote)
+	}
+	if hashed.URN != verbatim.URN {
+		t.Errorf("Expected the policy not to affect resolution, got %s and %s", hashed.URN, verbatim.URN)
+	}
+
+	omitted := extract("omit")
+	if omitted.XMLContext != "" || omitted.Quote != "" {
+		t.Errorf("Expected context and quote omitted, got %q and %q", omitted.XMLContext, omitted.Quote)
+	}
+
+	if _, err := NewCitationProcessor(Config{Context: ContextOptions{Policy: "redact"}}); err == nil {
+		t.Error("Expected an error for an unknown context policy")
+	}
+}
+
+func TestResolverFixtures(t *testing.T) {
+	resolvertest.RunFixtures(t, filepath.Join(findTestDataDir(), "resolver", "*.yaml"))
+}
+
+func TestAmbiguousCandidates(t *testing.T) {
+	processor, err := NewCitationProcessor(Config{UseCitTags: false, AmbiguousOnly: true})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+
+	// "her." is Heracles, but also abbreviates Heraclidae
+	res := processor.Resolver.Resolve("eur. her. 124", "", "test.xml")
+	if len(res.Candidates) != 2 || res.Candidates[0].URN != res.URN {
+		t.Fatalf("Expected 2 candidates led by %s, got %+v", res.URN, res.Candidates)
+	}
+	if res.Candidates[1].URN != "urn:cts:greekLit:tlg0006.tlg004.perseus-grc2:124" ||
+		res.Candidates[0].Score <= res.Candidates[1].Score {
+		t.Errorf("Unexpected candidate ranking: %+v", res.Candidates)
+	}
+
+	// a work cited without its author is unresolved, but gets candidates
+	res = processor.Resolver.Resolve("el. 123", "", "test.xml")
+	found := map[string]bool{}
+	for _, candidate := range res.Candidates {
+		found[candidate.URN] = true
+	}
+	if res.URN != "" || !found["urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:123"] || !found["urn:cts:greekLit:tlg0006.tlg012.perseus-grc2:123"] {
+		t.Errorf("Expected Sophocles and Euripides Electra among candidates, got %+v", res)
+	}
+
+	xmlContent := `<p><bibl n="Eur. Her. 124">Her. 124</bibl> <bibl n="Soph. OT 151">OT 151</bibl></p>`
+	citations := ambiguousCitations(processor.ExtractCitations(xmlContent, "test.xml"))
+	if len(citations) != 1 || citations[0].NAttrib != "Eur. Her. 124" {
+		t.Errorf("Expected only the ambiguous citation, got %+v", citations)
+	}
+}
+
+func TestCompatCheck(t *testing.T) {
+	dir := t.TempDir()
+	pythonFile := filepath.Join(dir, "python.json")
+	goFile := filepath.Join(dir, "go.jsonl")
+
+	python := `[
+{"n_attrib":"Soph. El. 123","bibl":"El. 123","urn":"urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:123","filename":"/old/a.xml","doc_cit_urn":":citations-1.1"},
+{"n_attrib":"","bibl":"Hdt. iv. 142.","urn":"urn:cts:greekLit:tlg0016.tlg001.perseus-grc2:142","filename":"/old/a.xml","doc_cit_urn":":citations-1.2"},
+{"n_attrib":"","bibl":"Xyz. 1","urn":"urn:cts:greekLit:tlg9999.tlg001.perseus-grc2:1","filename":"/old/a.xml","doc_cit_urn":":citations-1.3"}
+]`
+	goOutput := `{"n_attrib":"Soph. El. 123","bibl":"El. 123","urn":"urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:123","filename":"testdata/a.xml","doc_cit_urn":":citations-1.7"}
+{"n_attrib":"","bibl":"Hdt. iv. 142.","urn":"urn:cts:greekLit:tlg0016.tlg001.perseus-grc2:4.142","filename":"testdata/a.xml","doc_cit_urn":":citations-1.8","scheme":"book.chapter"}
+{"n_attrib":"","bibl":"Xyz. 1","urn":"","filename":"testdata/a.xml","doc_cit_urn":":citations-1.9"}
+{"n_attrib":"","bibl":"Thuc. 2.40","urn":"urn:cts:greekLit:tlg0003.tlg001.perseus-grc2:2.40","filename":"testdata/a.xml","doc_cit_urn":":citations-1.10"}
+`
+	if err := os.WriteFile(pythonFile, []byte(python), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(goFile, []byte(goOutput), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pythonRecords, err := loadCompatRecords(pythonFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	goRecords, err := loadCompatRecords(goFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := compareCompatRecords(pythonRecords, goRecords, map[string]bool{"doc_cit_urn": true}, 5)
+
+	if report.Matched != 3 || report.Identical != 1 || len(report.OnlyPython) != 0 || len(report.OnlyGo) != 1 {
+		t.Errorf("Unexpected record counts: %+v", report)
+	}
+	if report.ResolvedOnlyPython != 1 || report.ResolvedOnlyGo != 0 {
+		t.Errorf("Unexpected resolution counts: %+v", report)
+	}
+	counts := map[string]int{}
+	for _, diff := range report.Fields {
+		counts[diff.Field] = diff.Count
+	}
+	if counts["urn"] != 2 || counts["scheme"] != 1 || len(counts) != 2 {
+		t.Errorf("Unexpected field differences: %+v", counts)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	oldDir, newDir := t.TempDir(), t.TempDir()
+	files := map[string]string{
+		filepath.Join(oldDir, "resolved.jsonl"): `{"n_attrib":"","bibl":"Thuc. 2.40","urn":"urn:cts:greekLit:tlg0003.tlg001.perseus-grc2:2.40","filename":"a.xml","doc_cit_urn":":citations-1.1"}
+{"n_attrib":"","bibl":"Hdt. iv. 142.","urn":"urn:cts:greekLit:tlg0016.tlg001.perseus-grc2:142","filename":"a.xml","doc_cit_urn":":citations-1.2"}
+{"n_attrib":"","bibl":"Il. 1.1","urn":"urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:1.1","filename":"b.xml","doc_cit_urn":":citations-1.1"}
+`,
+		filepath.Join(oldDir, "unresolved.jsonl"): `{"n_attrib":"","bibl":"Xyz. 1","urn":"","filename":"a.xml","doc_cit_urn":":citations-1.3"}
+`,
+		filepath.Join(newDir, "resolved.jsonl"): `{"n_attrib":"","bibl":"Soph. El. 123","urn":"urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:123","filename":"a.xml","doc_cit_urn":":citations-1.1"}
+{"n_attrib":"","bibl":"Thuc. 2.40","urn":"urn:cts:greekLit:tlg0003.tlg001.perseus-grc2:2.40","filename":"a.xml","doc_cit_urn":":citations-1.2"}
+{"n_attrib":"","bibl":"Hdt. iv. 142.","urn":"urn:cts:greekLit:tlg0016.tlg001.perseus-grc2:4.142","filename":"a.xml","doc_cit_urn":":citations-1.3"}
+{"n_attrib":"","bibl":"Il. 1.1","urn":"urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:1.1","filename":"b.xml","doc_cit_urn":":citations-1.1"}
+`,
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+	if err := runDiff([]string{"-json", oldDir, newDir}); err != nil {
+		t.Fatal(err)
+	}
+	var diff corpusDiff
+	if err := json.Unmarshal(out.Bytes(), &diff); err != nil {
+		t.Fatal(err)
+	}
+	if diff.OldRecords != 4 || diff.NewRecords != 4 || diff.Added != 1 || diff.Removed != 1 || diff.Changed != 1 {
+		t.Errorf("Unexpected counts: %+v", diff)
+	}
+	if len(diff.Files) != 1 || diff.Files[0].Filename != "a.xml" {
+		t.Fatalf("Expected differences in a.xml only, got %+v", diff.Files)
+	}
+	file := diff.Files[0]
+	if len(file.Added) != 1 || file.Added[0].Bibl != "Soph. El. 123" {
+		t.Errorf("Unexpected added citations: %+v", file.Added)
+	}
+	if len(file.Removed) != 1 || file.Removed[0].Bibl != "Xyz. 1" {
+		t.Errorf("Unexpected removed citations: %+v", file.Removed)
+	}
+	if len(file.Changed) != 1 || file.Changed[0].NewURN != "urn:cts:greekLit:tlg0016.tlg001.perseus-grc2:4.142" {
+		t.Errorf("Unexpected changed citations: %+v", file.Changed)
+	}
+
+	out.Reset()
+	if err := runDiff([]string{oldDir, newDir}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Hdt. iv. 142.: urn:cts:greekLit:tlg0016.tlg001.perseus-grc2:142 -> urn:cts:greekLit:tlg0016.tlg001.perseus-grc2:4.142") {
+		t.Errorf("Expected the changed URN in the report, got:\n%s", out.String())
+	}
+}
+
+// TestPublish checks that publish bundles a run's resolved citations with
+// their schema, provenance and datasheet, as a zip and as a BagIt bag
+func TestPublish(t *testing.T) {
+	dir := t.TempDir()
+	resolved := `{"n_attrib":"","bibl":"Thuc. 2.40","urn":"urn:cts:greekLit:tlg0003.tlg001.perseus-grc2:2.40","filename":"a.xml","doc_cit_urn":"a:citations-1"}
+{"n_attrib":"","bibl":"Thuc. 2.41","urn":"urn:cts:greekLit:tlg0003.tlg001.perseus-grc2:2.41","filename":"a.xml","doc_cit_urn":"a:citations-2"}
+{"n_attrib":"","bibl":"Il. 1.1","urn":"urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:1.1","filename":"b.xml","doc_cit_urn":"b:citations-1"}
+`
+	if err := os.WriteFile(filepath.Join(dir, "resolved.jsonl"), []byte(resolved), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+	readArchive := func(path string) map[string][]byte {
+		t.Helper()
+		reader, err := zip.OpenReader(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer reader.Close()
+		files := make(map[string][]byte)
+		for _, file := range reader.File {
+			entry, err := file.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			content, err := io.ReadAll(entry)
+			entry.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[file.Name] = content
+		}
+		return files
+	}
+
+	if err := runPublish([]string{dir}); err == nil || !strings.Contains(err.Error(), "-version") {
+		t.Errorf("Expected publishing without -version to fail, got %v", err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "dataset.zip")
+	if err := runPublish([]string{"-version", "1.2.0", "-name", "test-citations", "-o", archive, dir}); err != nil {
+		t.Fatal(err)
+	}
+	files := readArchive(archive)
+	for _, name := range []string{"resolved.jsonl", citationSchemaFile, provenanceFile, "README.md", "SHA256SUMS"} {
+		if _, exists := files["test-citations-1.2.0/"+name]; !exists {
+			t.Errorf("Expected %s in the archive, got %d files", name, len(files))
+		}
+	}
+	if string(files["test-citations-1.2.0/resolved.jsonl"]) != resolved {
+		t.Errorf("Expected resolved.jsonl unchanged")
+	}
+	var provenance publishProvenance
+	if err := json.Unmarshal(files["test-citations-1.2.0/"+provenanceFile], &provenance); err != nil {
+		t.Fatal(err)
+	}
+	if provenance.Version != "1.2.0" || provenance.Records != 3 || provenance.Documents != 2 || provenance.Works != 2 {
+		t.Errorf("Unexpected provenance: %+v", provenance)
+	}
+	if len(provenance.Data) != 5 || !strings.HasPrefix(provenance.Data[0].Checksum, "sha256:") || provenance.Data[4].File != loader.ScholarlyAbbreviationsFile {
+		t.Errorf("Expected the checksums of the four data packs and the abbreviation layer, got %+v", provenance.Data)
+	}
+	var schema struct {
+		Properties map[string]any `json:"properties"`
+		Required   []string       `json:"required"`
+	}
+	if err := json.Unmarshal(files["test-citations-1.2.0/"+citationSchemaFile], &schema); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := schema.Properties["group_id"]; !exists || !reflect.DeepEqual(schema.Required[:3], []string{"bibl", "doc_cit_urn", "filename"}) {
+		t.Errorf("Unexpected schema: required %v", schema.Required)
+	}
+	if !strings.Contains(string(files["test-citations-1.2.0/README.md"]), "3 citations from 2 documents") {
+		t.Errorf("Expected the counts in the datasheet, got:\n%s", files["test-citations-1.2.0/README.md"])
+	}
+
+	bag := filepath.Join(t.TempDir(), "bag.zip")
+	if err := runPublish([]string{"-version", "1.2.0", "-format", "bagit", "-o", bag, dir}); err != nil {
+		t.Fatal(err)
+	}
+	files = readArchive(bag)
+	manifest := string(files["perseus-citations-1.2.0/manifest-sha256.txt"])
+	for _, line := range strings.Split(strings.TrimSpace(manifest), "\n") {
+		sum, name, _ := strings.Cut(line, "  ")
+		content, exists := files["perseus-citations-1.2.0/"+name]
+		if !exists || sha256Hex(content) != sum {
+			t.Errorf("Manifest line %q does not match the bag", line)
+		}
+	}
+	if !strings.Contains(manifest, "data/resolved.jsonl") || files["perseus-citations-1.2.0/bagit.txt"] == nil {
+		t.Errorf("Expected a BagIt bag, got manifest:\n%s", manifest)
+	}
+
+	// output written with -timestamp-output and -partition-by is found by the
+	// names the run gave it, and of several timestamped runs the last is
+	// published
+	stamped := t.TempDir()
+	input := filepath.Join(t.TempDir(), "a.xml")
+	if err := os.WriteFile(input, []byte(`<p><bibl>Soph. OT 151</bibl> <bibl>Verg. A. 1.1</bibl></p>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(stamped, "resolved-20000101T000000Z.jsonl"), []byte(resolved), 0644); err != nil {
+		t.Fatal(err)
+	}
+	processor, err := NewCitationProcessor(Config{
+		InputDir:        input,
+		OutputDir:       stamped,
+		ResolvedFile:    "resolved.jsonl",
+		UnresolvedFile:  "unresolved.jsonl",
+		TimestampOutput: true,
+		PartitionBy:     "namespace",
+		RunMetadata:     true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	if err := processor.ProcessAllXMLFiles(); err != nil {
+		t.Fatal(err)
+	}
+	if err := runPublish([]string{"-version", "1.3.0", "-o", archive, stamped}); err != nil {
+		t.Fatal(err)
+	}
+	files = readArchive(archive)
+	var names []string
+	for name := range files {
+		names = append(names, strings.TrimPrefix(name, "perseus-citations-1.3.0/"))
+	}
+	sort.Strings(names)
+	partitions := []string{
+		processor.stampedName("resolved.greekLit.jsonl"),
+		processor.stampedName("resolved.latinLit.jsonl"),
+	}
+	for _, name := range append(partitions, processor.stampedName(documentsFile), processor.runFileName()) {
+		if files["perseus-citations-1.3.0/"+name] == nil {
+			t.Errorf("Expected %s in the archive, got %v", name, names)
+		}
+	}
+	if files["perseus-citations-1.3.0/resolved-20000101T000000Z.jsonl"] != nil {
+		t.Errorf("Expected only the last run's output in the archive, got %v", names)
+	}
+	datasheet := string(files["perseus-citations-1.3.0/README.md"])
+	if !strings.Contains(datasheet, "2 citations from 1 documents") || !strings.Contains(datasheet, "`"+partitions[0]+"`, `"+partitions[1]+"`") {
+		t.Errorf("Expected the run's counts and files in the datasheet, got:\n%s", datasheet)
+	}
+
+	if err := runPublish([]string{"-version", "1.3.0", "-o", archive, t.TempDir()}); err == nil {
+		t.Error("Expected publishing a directory without resolved output to fail")
+	}
+}
+
+func TestGapReport(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.xml": `<p><bibl>Xyz. 1</bibl> <bibl>Xyz. 2.3</bibl> <bibl>Eur. Her. 124</bibl> <bibl>Soph. Foo 12</bibl> <bibl>Soph. OT 151</bibl></p>`,
+		"b.xml": `<p><bibl>Xyz. 4</bibl></p>`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reportPath := filepath.Join(dir, "gaps.md")
+	processor, err := NewCitationProcessor(Config{
+		InputDir:       dir,
+		OutputDir:      filepath.Join(dir, "out"),
+		ResolvedFile:   "resolved.jsonl",
+		UnresolvedFile: "unresolved.jsonl",
+		GapReport:      reportPath,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	if err := processor.ProcessAllXMLFiles(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := string(content)
+
+	for _, expected := range []string{
+		"6 citations in 2 files, 3 unresolved.",
+		"## Add 1 missing authors",
+		"| `xyz.` | 3 | 2 | `Xyz. 1`, `Xyz. 2.3`, `Xyz. 4` |",
+		"## Add 1 missing works",
+		"| `soph. foo` | 1 | 1 | `Soph. Foo 12` |",
+		"## Disambiguate 1 abbreviations matching several works",
+		"`urn:cts:greekLit:tlg0006.tlg004.perseus-grc2`",
+		"| `xyz. N.N` | `unknown-author` | 1 | 1 | `Xyz. 2.3` |",
+	} {
+		if !strings.Contains(report, expected) {
+			t.Errorf("Expected %q in the gap report, got:\n%s", expected, report)
+		}
+	}
+	if strings.Contains(report, "OT 151") {
+		t.Errorf("Expected no resolved citations in the gap report, got:\n%s", report)
+	}
+}
+
+func TestCommentatorSplit(t *testing.T) {
+	processor, err := NewCitationProcessor(Config{UseCitTags: false})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+
+	xmlContent := `<p><bibl>Jebb on Soph. OT 100</bibl> <bibl>Schneidewin ad Soph. El. 123</bibl> <bibl>Soph. OT 151</bibl></p>`
+	citations := processor.ExtractCitations(xmlContent, "test.xml")
+	if len(citations) != 3 {
+		t.Fatalf("Expected 3 citations, got %d", len(citations))
+	}
+
+	expected := []struct{ urn, commentator string }{
+		{"urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:100", "Jebb"},
+		{"urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:123", "Schneidewin"},
+		{"urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:151", ""},
+	}
+	for i, exp := range expected {
+		if citations[i].URN != exp.urn || citations[i].Commentator != exp.commentator {
+			t.Errorf("Citation %d: expected %s by %q, got %s by %q",
+				i, exp.urn, exp.commentator, citations[i].URN, citations[i].Commentator)
+		}
+	}
+
+	// an ancient author before "on" is not a modern commentator
+	if commentator, ancient := processor.Resolver.SplitCommentator("Eustath. on Hom. Il. 1.1"); commentator != "" || ancient != "Eustath. on Hom. Il. 1.1" {
+		t.Errorf("Expected no split, got %q / %q", commentator, ancient)
+	}
+}
+
+func TestCorrections(t *testing.T) {
+	dir := t.TempDir()
+	jsonFile := filepath.Join(dir, "corrections.json")
+	csvFile := filepath.Join(dir, "corrections.csv")
+	pinned := "urn:cts:greekLit:tlg0006.tlg012.perseus-grc2:123"
+
+	if err := os.WriteFile(jsonFile, []byte(`{"bibl": {"Soph.  El. 123": "`+pinned+`"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(csvFile, []byte("kind,key,urn\ndoc_cit_urn,test:citations-2,"+pinned+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	xmlContent := `<p><bibl>Soph. El. 123</bibl> <bibl>Soph. OT 151</bibl></p>`
+	testCases := []struct {
+		file     string
+		expected []string
+	}{
+		{jsonFile, []string{pinned, "urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:151"}},
+		{csvFile, []string{"urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:123", pinned}},
+	}
+	for _, tc := range testCases {
+		t.Run(filepath.Base(tc.file), func(t *testing.T) {
+			processor, err := NewCitationProcessor(Config{UseCitTags: false, CorrectionsFile: tc.file})
+			if err != nil {
+				t.Fatalf("Failed to create citation processor: %v", err)
+			}
+			citations := processor.ExtractCitations(xmlContent, "test.xml")
+			if len(citations) != 2 {
+				t.Fatalf("Expected 2 citations, got %d", len(citations))
+			}
+			for i, urn := range tc.expected {
+				if citations[i].URN != urn || citations[i].Corrected != (urn == pinned) {
+					t.Errorf("Citation %d: expected %s, got %s (corrected: %v)", i, urn, citations[i].URN, citations[i].Corrected)
+				}
+			}
+		})
+	}
+
+	if err := os.WriteFile(csvFile, []byte("title,Soph. El. 123,"+pinned+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resolver.LoadCorrections(csvFile); err == nil {
+		t.Error("Expected an error for an unknown correction kind")
+	}
+}
+
+func TestOutputIOOptions(t *testing.T) {
+	var slept time.Duration
+	limiter := newRateLimiter(100)
+	limiter.sleep = func(d time.Duration) { slept = d }
+	limiter.wait(50)
+	limiter.wait(150)
+	if slept < 1900*time.Millisecond || slept > 2*time.Second {
+		t.Errorf("Expected writes to be held back until 2s for 200 bytes at 100 B/s, got %v", slept)
+	}
+
+	if err := (IOOptions{Fsync: "interval"}).validate(); err == nil {
+		t.Error("Expected an error for -fsync interval without an interval")
+	}
+	if err := (IOOptions{Fsync: "always"}).validate(); err == nil {
+		t.Error("Expected an error for an unknown fsync policy")
+	}
+
+	outputDir := t.TempDir()
+	processor, err := NewCitationProcessor(Config{
+		OutputDir:      outputDir,
+		ResolvedFile:   "resolved.jsonl",
+		UnresolvedFile: "unresolved.jsonl",
+		IO:             IOOptions{BufferSize: 16, Fsync: "file", MaxRate: 1 << 30},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		citations := processor.ExtractCitations(`<p><bibl>Soph. El. 123</bibl> <bibl>Xyz. 1</bibl></p>`, "test.xml")
+		if err := processor.WriteCitations(citations); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := processor.Writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	resolved, err := loadCitations(filepath.Join(outputDir, "resolved.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unresolved, err := loadCitations(filepath.Join(outputDir, "unresolved.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resolved) != 2 || len(unresolved) != 2 {
+		t.Errorf("Expected 2 resolved and 2 unresolved citations, got %d and %d", len(resolved), len(unresolved))
+	}
+}
+
+func TestPreview(t *testing.T) {
+	processor, err := NewCitationProcessor(Config{UseCitTags: true})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	xmlContent := `<p>See <bibl>Soph. OT 151</bibl> &amp; <bibl>Xyz. 12</bibl>, and again <bibl>Soph. OT 151</bibl>.</p>`
+
+	var out strings.Builder
+	if err := processor.RenderPreview(&out, xmlContent, "test.xml"); err != nil {
+		t.Fatal(err)
+	}
+	page := out.String()
+
+	expected := []string{
+		`<span class="cit resolved" title="urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:151`,
+		`>&lt;bibl&gt;Soph. OT 151&lt;/bibl&gt;</span>`,
+		`<span class="cit unresolved" title="ref: `,
+		`&amp;amp;`,
+		`resolved: 1</span>`,
+		`unresolved: 1</span>`,
+	}
+	for _, fragment := range expected {
+		if !strings.Contains(page, fragment) {
+			t.Errorf("Preview does not contain %q:\n%s", fragment, page)
+		}
+	}
+	// the deduplicated repeat is highlighted with the first occurrence's citation
+	if count := strings.Count(page, `<span class="cit resolved" title=`); count != 2 {
+		t.Errorf("Expected 2 resolved spans, got %d", count)
+	}
+}
+
+func TestDocumentCitURNs(t *testing.T) {
+	testCases := []struct {
+		name       string
+		xmlContent string
+		filename   string
+		expected   string
+	}{
+		{"idno", `<teiHeader><idno type="URN">urn:cts:greekLit:tlg0011.tlg004.jebb-eng1</idno></teiHeader><body n="urn:cts:greekLit:other.work">`, "a.xml", "urn:cts:greekLit:tlg0011.tlg004.jebb-eng1"},
+		{"body", `<body n="urn:cts:greekLit:viaf2603144.viaf001.perseus-eng1"><div n="urn:cts:greekLit:tlg0011.tlg004:1-150">`, "b.xml", "urn:cts:greekLit:viaf2603144.viaf001.perseus-eng1"},
+		{"passage only", `<div n="urn:cts:greekLit:tlg0011.tlg004:1-150">`, "dir/c.xml", "c"},
+		{"filename", `<p/>`, "dir/campbell-sophlanguage-2.xml", "campbell-sophlanguage-2"},
+	}
+	for _, tc := range testCases {
+		if id := documentID(tc.xmlContent, tc.filename); id != tc.expected {
+			t.Errorf("%s: expected document ID %q, got %q", tc.name, tc.expected, id)
+		}
+	}
+
+	processor, err := NewCitationProcessor(Config{UseCitTags: false})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	xmlContent := `<p><bibl>Soph. El. 123</bibl> <bibl>Soph. OT 151</bibl></p>`
+	first := processor.ExtractCitations(xmlContent, "one.xml")
+	processor.ExtractCitations(xmlContent, "two.xml")
+	again := processor.ExtractCitations(xmlContent, "one.xml")
+	for i, expected := range []string{"one:citations-1", "one:citations-2"} {
+		if first[i].DocCitURN != expected || again[i].DocCitURN != expected {
+			t.Errorf("Citation %d: expected %s on both runs, got %s and %s", i, expected, first[i].DocCitURN, again[i].DocCitURN)
+		}
+	}
+}
+
+func TestPartitionBy(t *testing.T) {
+	xmlContent := `<p><bibl>Soph. El. 123</bibl> <bibl>Verg. A. 1.1</bibl> <bibl>Soph. OT 151</bibl> <bibl>Xyz. 1</bibl></p>`
+	testCases := []struct {
+		partitionBy string
+		expected    map[string]int
+	}{
+		{"namespace", map[string]int{"greekLit": 2, "latinLit": 1}},
+		{"author", map[string]int{"tlg0011": 2, "phi0690": 1}},
+		{"file", map[string]int{"test": 3}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.partitionBy, func(t *testing.T) {
+			outputDir := t.TempDir()
+			processor, err := NewCitationProcessor(Config{
+				OutputDir:      outputDir,
+				ResolvedFile:   "resolved.jsonl",
+				UnresolvedFile: "unresolved.jsonl",
+				PartitionBy:    tc.partitionBy,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create citation processor: %v", err)
+			}
+			if err := processor.WriteCitations(processor.ExtractCitations(xmlContent, "dir/test.xml")); err != nil {
+				t.Fatal(err)
+			}
+			if err := processor.Writer.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			partitions, _ := filepath.Glob(filepath.Join(outputDir, "resolved.*.jsonl"))
+			if len(partitions) != len(tc.expected) {
+				t.Errorf("Expected %d partitions, got %v", len(tc.expected), partitions)
+			}
+			for key, count := range tc.expected {
+				citations, err := loadCitations(filepath.Join(outputDir, "resolved."+key+".jsonl"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(citations) != count {
+					t.Errorf("Expected %d citations in partition %s, got %d", count, key, len(citations))
+				}
+			}
+			if _, err := os.Stat(filepath.Join(outputDir, "resolved.jsonl")); !os.IsNotExist(err) {
+				t.Error("Expected no combined resolved.jsonl when partitioning")
+			}
+			if unresolved, err := loadCitations(filepath.Join(outputDir, "unresolved.jsonl")); err != nil || len(unresolved) != 1 {
+				t.Errorf("Expected 1 unresolved citation, got %d (%v)", len(unresolved), err)
+			}
+		})
+	}
+
+	if _, err := NewCitationProcessor(Config{PartitionBy: "work"}); err == nil {
+		t.Error("Expected an error for an unknown partition")
+	}
+}
+
+func TestFileInputAndStdoutOutput(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.xml": `<p><bibl>Soph. El. 123</bibl></p>`,
+		"b.xml": `<p><bibl>Xyz. 1</bibl></p>`,
+		"c.txt": `<p><bibl>Soph. OT 151</bibl></p>`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		input    string
+		expected int
+	}{
+		{dir, 2},
+		{filepath.Join(dir, "a.xml"), 1},
+		{filepath.Join(dir, "a.xml") + "," + filepath.Join(dir, "c.txt"), 2},
+		{filepath.Join(dir, "[ab].xml"), 2},
+	}
+	for _, tc := range testCases {
+		files, err := inputFiles(tc.input)
+		if err != nil || len(files) != tc.expected {
+			t.Errorf("Input %s: expected %d files, got %v (%v)", tc.input, tc.expected, files, err)
+		}
+	}
+	if _, err := inputFiles(filepath.Join(dir, "missing.xml")); err == nil {
+		t.Error("Expected an error for a missing input file")
+	}
+
+	var out strings.Builder
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+
+	processor, err := NewCitationProcessor(Config{
+		InputDir:  filepath.Join(dir, "a.xml") + "," + filepath.Join(dir, "b.xml"),
+		OutputDir: "-",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	if err := processor.ProcessAllXMLFiles(); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 citations on stdout, got:\n%s", out.String())
+	}
+	var citation Citation
+	if err := json.Unmarshal([]byte(lines[0]), &citation); err != nil || citation.URN != "urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:123" {
+		t.Errorf("Unexpected first citation %s (%v)", lines[0], err)
+	}
+	if _, err := os.Stat("-"); !os.IsNotExist(err) {
+		t.Error("Expected no output directory named -")
+	}
+}
+
+func TestDocumentMetadata(t *testing.T) {
+	dir := t.TempDir()
+	header := `<TEI><teiHeader><fileDesc><titleStmt><title>Commentary on
+  Sophocles: Electra</title><author>Richard C. Jebb</author><editor role="editor">A. Editor</editor></titleStmt>
+<publicationStmt><date type="release" when="2000-08-16"/></publicationStmt>
+<sourceDesc><biblStruct><monogr><imprint><date>1894</date></imprint></monogr></biblStruct></sourceDesc></fileDesc></teiHeader>
+<text><body n="urn:cts:greekLit:viaf2603144.viaf005.perseus-eng1"><p><bibl>Soph. OT 151</bibl></p></body></text></TEI>`
+	files := map[string]string{
+		"jebb.xml":  header,
+		"plain.xml": `<p><bibl>Soph. El. 123</bibl> <bibl>Xyz. 1</bibl></p>`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outputDir := filepath.Join(dir, "out")
+	processor, err := NewCitationProcessor(Config{
+		InputDir:       dir,
+		OutputDir:      outputDir,
+		ResolvedFile:   "resolved.jsonl",
+		UnresolvedFile: "unresolved.jsonl",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	if err := processor.ProcessAllXMLFiles(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := DocumentMetadata{
+		URN:    "urn:cts:greekLit:viaf2603144.viaf005.perseus-eng1",
+		Title:  "Commentary on Sophocles: Electra",
+		Author: "Richard C. Jebb",
+		Editor: "A. Editor",
+		Date:   "1894",
+	}
+	resolved, err := loadCitations(filepath.Join(outputDir, "resolved.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, citation := range resolved {
+		switch filepath.Base(citation.Filename) {
+		case "jebb.xml":
+			if citation.Document == nil || *citation.Document != expected {
+				t.Errorf("Expected document %+v, got %+v", expected, citation.Document)
+			}
+		case "plain.xml":
+			if citation.Document != nil {
+				t.Errorf("Expected no document metadata without a header, got %+v", citation.Document)
+			}
+		}
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, documentsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []documentRecord
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		var record documentRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 || records[0].DocumentMetadata != expected || records[0].Citations != 1 ||
+		records[1].Citations != 2 || records[1].DocumentMetadata != (DocumentMetadata{}) {
+		t.Errorf("Unexpected documents.jsonl:\n%s", content)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.xml"), []byte(`<p><bibl>Soph. El. 123</bibl> <bibl>Xyz. 1</bibl> <bibl>Soph. OT 151</bibl></p>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+
+	outputDir := filepath.Join(dir, "out")
+	processor, err := NewCitationProcessor(Config{
+		InputDir:       dir,
+		OutputDir:      outputDir,
+		ResolvedFile:   "resolved.jsonl",
+		UnresolvedFile: "unresolved.jsonl",
+		DryRun:         true,
+		DryRunExamples: 2,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	if err := processor.ProcessAllXMLFiles(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fragment := range []string{
+		"a.xml: 3 citations (2 resolved, 0 ambiguous, 1 unresolved)",
+		"  Soph. El. 123 -> urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:123\n",
+		"  Xyz. 1 -> unresolved (",
+		"  ... 1 more\n",
+		"Dry run, nothing written: 1 files, 3 citations",
+	} {
+		if !strings.Contains(out.String(), fragment) {
+			t.Errorf("Dry run output does not contain %q:\n%s", fragment, out.String())
+		}
+	}
+	if _, err := os.Stat(outputDir); !os.IsNotExist(err) {
+		t.Error("Expected a dry run not to create the output directory")
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	var notFound *loader.ErrDataNotFound
+	if _, err := resolver.NewURNResolverFromDir(t.TempDir()); !errors.As(err, &notFound) {
+		t.Errorf("Expected ErrDataNotFound for an empty data directory, got %v", err)
+	}
+	if _, err := resolver.NewURNResolverFromDir(t.TempDir()); !errors.Is(err, loader.ErrDataFileMissing) {
+		t.Errorf("Expected ErrDataFileMissing for an empty data directory, got %v", err)
+	}
+
+	urnResolver, err := resolver.NewURNResolver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		ref      string
+		reason   resolver.FailureReason
+		category error
+	}{
+		{"", resolver.ReasonEmptyRef, resolver.ErrMalformedRef},
+		{"xyz. abc. 12", resolver.ReasonUnknownAuthor, resolver.ErrAuthorUnknown},
+		{"soph. xyz. 12", resolver.ReasonUnknownWork, resolver.ErrWorkUnknown},
+		{"perseus:text:1999.01.9999", resolver.ReasonUnknownLegacyID, resolver.ErrWorkUnknown},
+		{"Apollonius 1.100", resolver.ReasonAmbiguousAuthor, nil},
+		{"hom. il. 30.1", resolver.ReasonSchemeViolation, nil},
+		{"soph. oc 5 610", resolver.ReasonSchemeViolation, nil},
+		{"hdt. i. 216-211.", resolver.ReasonSchemeViolation, nil},
+	}
+	categories := []error{resolver.ErrAuthorUnknown, resolver.ErrWorkUnknown, resolver.ErrMalformedRef}
+	// without a fallback, an unknown work is left unresolved
+	urnResolver.WorkFallback = resolver.FallbackNone
+	for _, tc := range testCases {
+		var failed *resolver.ResolutionError
+		if err := urnResolver.Resolve(tc.ref, "", "test.xml").Err(); !errors.As(err, &failed) || failed.Reason != tc.reason {
+			t.Errorf("Ref %q: expected reason %s, got %v", tc.ref, tc.reason, err)
+		}
+		urn, err := urnResolver.ResolveURN(tc.ref, "", "test.xml")
+		if urn != "" || !errors.As(err, &failed) || failed.Ref != tc.ref {
+			t.Errorf("Ref %q: expected a ResolutionError naming the reference, got %q, %v", tc.ref, urn, err)
+		}
+		for _, category := range categories {
+			if errors.Is(err, category) != (category == tc.category) {
+				t.Errorf("Ref %q: errors.Is(%v) = %v", tc.ref, category, errors.Is(err, category))
+			}
+		}
+	}
+	for ref, violation := range map[string]string{
+		"hom. il. 30.1":    "urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:30.1",
+		"soph. oc 5 610":   "urn:cts:greekLit:tlg0011.tlg007.perseus-grc2:5.610",
+		"hdt. i. 216-211.": "urn:cts:greekLit:tlg0016.tlg001.perseus-grc2:1.216-1.211",
+	} {
+		if res := urnResolver.Resolve(ref, "", "test.xml"); res.SchemeViolation != violation {
+			t.Errorf("Ref %q: expected the rejected URN kept as the scheme violation, got %q", ref, res.SchemeViolation)
+		}
+	}
+	if err := urnResolver.Resolve("soph. el. 123", "", "test.xml").Err(); err != nil {
+		t.Errorf("Expected no error for a resolved reference, got %v", err)
+	}
+
+	dir := t.TempDir()
+	malformedFile := filepath.Join(dir, "bad.xml")
+	if err := os.WriteFile(malformedFile, []byte("<TEI>\n<p><bibl>Soph. El. 123</bibl>\n</div></TEI>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	processor, err := NewCitationProcessor(Config{OutputDir: dir, ResolvedFile: "resolved.jsonl", UnresolvedFile: "unresolved.jsonl"})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	var malformed *ErrMalformedXML
+	if err := processor.ProcessXMLFile(malformedFile); !errors.As(err, &malformed) || malformed.Line != 3 {
+		t.Errorf("Expected ErrMalformedXML at line 3, got %v", err)
+	}
+
+	entityFile := filepath.Join(dir, "entities.xml")
+	if err := os.WriteFile(entityFile, []byte("<p>&mdash; &dtdonly; <bibl>Soph. El. 123</bibl></p>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := processor.ProcessXMLFile(entityFile); err != nil {
+		t.Errorf("Expected undeclared entities to be accepted, got %v", err)
+	}
+}
+
+// TestConcurrentResolve checks that one resolver gives the same resolutions
+// from many goroutines as from one; run with -race to check for data races
+func TestConcurrentResolve(t *testing.T) {
+	urnResolver, err := resolver.NewURNResolver()
+	if err != nil {
+		t.Fatalf("Failed to create resolver: %v", err)
+	}
+	urnResolver.FuzzyAuthors = 0.9
+	refs := []string{
+		"soph. el. 123", "hom. il. 1.1", "thuc. 2.65", "hdt. 1.1", "pl. rep. 327a",
+		"cic. clu. 35.96", "sophocels ant. 1008", "xyz. abc. 12", "hom. il. 30.1", "dem. 18.1",
+	}
+	expected := make([]string, len(refs))
+	for i, ref := range refs {
+		expected[i] = urnResolver.Resolve(ref, "", "test.xml").URN
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan string, 8*len(refs))
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := range refs {
+				ref := refs[(i+g)%len(refs)]
+				if urn := urnResolver.Resolve(ref, "", "test.xml").URN; urn != expected[(i+g)%len(refs)] {
+					errs <- fmt.Sprintf("goroutine %d: %q resolved to %q, expected %q", g, ref, urn, expected[(i+g)%len(refs)])
+				}
+				urnResolver.GetRef("", ref)
+				urnResolver.FindWorks("oedipus")
+				urnResolver.Data.GetAllAuthAbb()
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestCrossWorkRanges(t *testing.T) {
+	res, err := resolver.NewURNResolver()
+	if err != nil {
+		t.Fatalf("Failed to create resolver: %v", err)
+	}
+
+	testCases := []struct {
+		ref   string
+		urn   string
+		works []string
+	}{
+		{"Dem. or. 18–19", "urn:cts:greekLit:tlg0014.tlg018.perseus-grc2", []string{
+			"urn:cts:greekLit:tlg0014.tlg018.perseus-grc2",
+			"urn:cts:greekLit:tlg0014.tlg019.perseus-grc2",
+		}},
+		{"Dem. 18-20", "urn:cts:greekLit:tlg0014.tlg018.perseus-grc2", []string{
+			"urn:cts:greekLit:tlg0014.tlg018.perseus-grc2",
+			"urn:cts:greekLit:tlg0014.tlg019.perseus-grc2",
+			"urn:cts:greekLit:tlg0014.tlg020.perseus-grc2",
+		}},
+		// a single oration has no works list
+		{"Dem. or. 18.5", "urn:cts:greekLit:tlg0014.tlg018.perseus-grc2:5", nil},
+		// Catullus's poems are passages of one work, so this is a passage range
+		{"Cat. 64–66", "urn:cts:latinLit:phi0472.phi001.perseus-lat2:64-66", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.ref, func(t *testing.T) {
+			r := res.Resolve(res.GetRef("", tc.ref), "", "test.xml")
+			if r.URN != tc.urn || !reflect.DeepEqual(r.Works, tc.works) {
+				t.Errorf("Expected %s %v, got %s %v", tc.urn, tc.works, r.URN, r.Works)
+			}
+		})
+	}
+}
+
+func TestScaifeURLs(t *testing.T) {
+	testCases := []struct {
+		urn string
+		url string
+	}{
+		{"urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:1.1", "https://scaife.perseus.org/reader/urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:1.1/"},
+		// an abbreviated range end gets the start's leading levels
+		{"urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:1.1-10", "https://scaife.perseus.org/reader/urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:1.1-1.10/"},
+		{"urn:cts:latinLit:phi0472.phi001.perseus-lat2:64-66", "https://scaife.perseus.org/reader/urn:cts:latinLit:phi0472.phi001.perseus-lat2:64-66/"},
+		{"urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:123ff", "https://scaife.perseus.org/reader/urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:123/"},
+		{"urn:cts:greekLit:tlg0014.tlg018.perseus-grc2", "https://scaife.perseus.org/reader/urn:cts:greekLit:tlg0014.tlg018.perseus-grc2/"},
+		// no edition: the library page lists the work's editions
+		{"urn:cts:greekLit:tlg0012.tlg001", "https://scaife.perseus.org/library/urn:cts:greekLit:tlg0012.tlg001/"},
+		{"", ""},
+		{"not a urn", ""},
+	}
+	for _, tc := range testCases {
+		if got := scaifeURL(tc.urn); got != tc.url {
+			t.Errorf("scaifeURL(%q) = %q, want %q", tc.urn, got, tc.url)
+		}
+	}
+
+	processor, err := NewCitationProcessor(Config{UseCitTags: true, ScaifeURLs: true})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	citations := processor.ExtractCitations(`<div><bibl n="Soph. El. 123">El. 123</bibl> <bibl>Xyz. Abc. 12</bibl></div>`, "test.xml")
+	if len(citations) != 2 {
+		t.Fatalf("Expected 2 citations, got %d", len(citations))
+	}
+	if want := "https://scaife.perseus.org/reader/urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:123/"; citations[0].ScaifeURL != want {
+		t.Errorf("Expected scaife_url %s, got %q", want, citations[0].ScaifeURL)
+	}
+	if citations[1].ScaifeURL != "" {
+		t.Errorf("Expected no scaife_url for an unresolved citation, got %q", citations[1].ScaifeURL)
+	}
+}
+
+func TestSpeakerNotes(t *testing.T) {
+	processor, err := NewCitationProcessor(Config{UseCitTags: false})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+
+	xmlContent := `<p><bibl>Soph. OT 924 (Messenger)</bibl> <bibl>Aristoph. Frogs 1119 ff. (chorus)</bibl> <bibl>Soph. OT 100</bibl></p>`
+	citations := processor.ExtractCitations(xmlContent, "test.xml")
+	if len(citations) != 3 {
+		t.Fatalf("Expected 3 citations, got %d", len(citations))
+	}
+
+	expected := []struct{ urn, note string }{
+		{"urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:924", "Messenger"},
+		{"urn:cts:greekLit:tlg0019.tlg009.perseus-grc2:1119ff", "chorus"},
+		{"urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:100", ""},
+	}
+	for i, exp := range expected {
+		if citations[i].URN != exp.urn || citations[i].Note != exp.note {
+			t.Errorf("Citation %d: expected %s with note %q, got %s with note %q",
+				i, exp.urn, exp.note, citations[i].URN, citations[i].Note)
+		}
+	}
+
+	// a parenthetical holding numbers is part of the locus
+	if note, locus := resolver.SplitNote("Soph. OT 924 (925)"); note != "" || locus != "Soph. OT 924 (925)" {
+		t.Errorf("Expected no split, got %q / %q", note, locus)
+	}
+}
+
+func TestEditions(t *testing.T) {
+	processor, err := NewCitationProcessor(Config{UseCitTags: false})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+
+	xmlContent := `<p><bibl>Plat. Rep. 327a Burnet</bibl> <bibl>Soph. OT 100 (Lloyd-Jones)</bibl> ` +
+		`<bibl>Soph. OT 924 (Messenger)</bibl> <bibl n="Eur. Med. 5 ed. Diggle">Med. 5</bibl></p>`
+	citations := processor.ExtractCitations(xmlContent, "test.xml")
+	expected := []struct{ urn, edition, note string }{
+		{"urn:cts:greekLit:tlg0059.tlg030.perseus-grc2:327a", "Burnet", ""},
+		{"urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:100", "Lloyd-Jones", ""},
+		{"urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:924", "", "Messenger"},
+		{"urn:cts:greekLit:tlg0006.tlg003.perseus-grc2:5", "ed. Diggle", ""},
+	}
+	if len(citations) != len(expected) {
+		t.Fatalf("Expected %d citations, got %d", len(expected), len(citations))
+	}
+	for i, exp := range expected {
+		if citations[i].URN != exp.urn || citations[i].Edition != exp.edition || citations[i].Note != exp.note {
+			t.Errorf("Citation %d: expected %s with edition %q and note %q, got %s with edition %q and note %q",
+				i, exp.urn, exp.edition, exp.note, citations[i].URN, citations[i].Edition, citations[i].Note)
+		}
+	}
+
+	// the editor of a fragment collection is part of the reference
+	if edition, locus := processor.Resolver.SplitEdition("Sappho fr. 31 Voigt"); edition != "" || locus != "Sappho fr. 31 Voigt" {
+		t.Errorf("Expected no split, got %q / %q", edition, locus)
+	}
+}
+
+func TestIncipits(t *testing.T) {
+	processor, err := NewCitationProcessor(Config{UseCitTags: false})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+
+	// hymns 2 and 13 open alike: the first listed wins, both are candidates
+	res := processor.Resolver.Resolve("h.h. 'demetr' eucomon'", "", "test.xml")
+	if res.URN != "urn:cts:greekLit:tlg0013.tlg002.perseus-grc2" || len(res.Candidates) != 2 {
+		t.Errorf("Expected hymn 2 with 2 candidates, got %s with %v", res.URN, res.Candidates)
+	}
+
+	// an author with incipits, none of them quoted
+	res = processor.Resolver.Resolve("hor. 'arma virumque cano'", "", "test.xml")
+	if res.URN != "" || res.Reason != resolver.ReasonUnknownWork {
+		t.Errorf("Expected unknown work, got %s with reason %q", res.URN, res.Reason)
+	}
+
+	// a quotation after an ordinary locus is left to the usual parsing
+	res = processor.Resolver.Resolve("hor. odes 1.22 'integer vitae'", "", "test.xml")
+	if res.URN == "" {
+		t.Errorf("Expected the locus to resolve, got warnings %v", res.Warnings)
+	}
+}
+
+func TestCTSLinks(t *testing.T) {
+	xmlContent := `<div><p>As in <ref target="urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:100">OT 100</ref>, ` +
+		`cf. <ptr target="http://data.perseus.org/citations/urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:1.1"/> and ` +
+		`<cit><bibl n="Soph. El. 1"><ref target="urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:123/">El. 123</ref></bibl><quote>ἄλγος</quote></cit>; ` +
+		`<ref target="#note1">note 1</ref></p></div>`
+
+	for _, useCitTags := range []bool{true, false} {
+		processor, err := NewCitationProcessor(Config{UseCitTags: useCitTags})
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		citations := processor.ExtractCitations(xmlContent, "test.xml")
+
+		expected := map[string]string{
+			"OT 100": "urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:100",
+			"http://data.perseus.org/citations/urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:1.1": "urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:1.1",
+		}
+		var linked int
+		for _, citation := range citations {
+			if !citation.PreResolved {
+				continue
+			}
+			linked++
+			if urn, exists := expected[citation.Bibl]; exists && citation.URN != urn {
+				t.Errorf("cit=%v: expected %s for %q, got %s", useCitTags, urn, citation.Bibl, citation.URN)
+			}
+			// the link in the bibl wins over resolving its n attribute
+			if citation.NAttrib == "Soph. El. 1" && citation.URN != "urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:123" {
+				t.Errorf("cit=%v: expected the bibl's link target, got %s", useCitTags, citation.URN)
+			}
+		}
+		if linked != 3 || len(citations) != 3 {
+			t.Errorf("cit=%v: expected 3 citations, all pre-resolved, got %d of %d: %+v", useCitTags, linked, len(citations), citations)
+		}
+	}
+}
+
+func TestPassageQualifiers(t *testing.T) {
+	res, err := resolver.NewURNResolver()
+	if err != nil {
+		t.Fatalf("Failed to create resolver: %v", err)
+	}
+
+	testCases := []struct {
+		ref       string
+		urn       string
+		qualifier string
+	}{
+		{"Thuc. 3.2 init.", "urn:cts:greekLit:tlg0003.tlg001.perseus-grc2:3.2", "init"},
+		{"Soph. OT 100 fin.", "urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:100", "fin"},
+		{"Hdt. 1.5 in fin.", "urn:cts:greekLit:tlg0016.tlg001.perseus-grc2:1.5", "fin"},
+		{"Soph. OT 100", "urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:100", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.ref, func(t *testing.T) {
+			r := res.Resolve(res.GetRef("", tc.ref), "", "test.xml")
+			if r.URN != tc.urn || r.Qualifier != tc.qualifier {
+				t.Errorf("Expected %s (%q), got %s (%q)", tc.urn, tc.qualifier, r.URN, r.Qualifier)
+			}
+		})
+	}
+}
+
+func TestRepl(t *testing.T) {
+	loads := 0
+	load := func() (*resolver.URNResolver, error) {
+		loads++
+		return resolver.NewURNResolver()
+	}
+	input := strings.NewReader("Jebb on Soph. OT 924 (Messenger)\nXyz. Abc. 12\n:works antigone\n:reload\n:quit\nSoph. El. 123\n")
+	var out bytes.Buffer
+	if err := runReplLoop(input, &out, load); err != nil {
+		t.Fatalf("repl failed: %v", err)
+	}
+
+	output := out.String()
+	for _, want := range []string{
+		`ref:         "soph. ot 924"`,
+		"commentator: Jebb",
+		"note:        Messenger",
+		"author:      soph. -> sophocles",
+		"urn:         urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:924",
+		"author:      xyz. -> (not recognized)",
+		"urn:         (unresolved)",
+		"warning:     author not recognized: xyz.",
+		`urn:cts:greekLit:tlg0011.tlg002          sophocles, antigone ("antigone", 1.00)`,
+		"reloaded",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "tlg0011.tlg005") {
+		t.Errorf("Expected input after :quit to be ignored:\n%s", output)
+	}
+	if loads != 2 {
+		t.Errorf("Expected the resolver to be loaded twice, got %d", loads)
+	}
+}
+
+func TestWorkFallback(t *testing.T) {
+	res, err := resolver.NewURNResolver()
+	if err != nil {
+		t.Fatalf("Failed to create resolver: %v", err)
+	}
+	ref := res.GetRef("", "Cic. Clu. 35.96")
+
+	testCases := []struct {
+		policy   resolver.WorkFallback
+		urn      string
+		fallback bool
+	}{
+		{resolver.FallbackGuess, "urn:cts:latinLit:phi0474.phi001.perseus-lat2:35.96", true},
+		{resolver.FallbackAuthor, "urn:cts:latinLit:phi0474", true},
+		{resolver.FallbackNone, "", false},
+	}
+	for _, tc := range testCases {
+		res.WorkFallback = tc.policy
+		r := res.Resolve(ref, "", "test.xml")
+		if r.URN != tc.urn || r.Fallback != tc.fallback {
+			t.Errorf("Policy %s: expected %q (fallback %v), got %q (fallback %v)", tc.policy, tc.urn, tc.fallback, r.URN, r.Fallback)
+		}
+	}
+	if r := res.Resolve(res.GetRef("", "Soph. OT 151"), "", "test.xml"); r.Fallback {
+		t.Errorf("Expected no fallback for a known work, got %+v", r)
+	}
+
+	if _, err := NewCitationProcessor(Config{WorkFallback: "maybe"}); err == nil {
+		t.Error("Expected an error for an unknown work fallback policy")
+	}
+}
+
+func TestPassageFormat(t *testing.T) {
+	res, err := resolver.NewURNResolver()
+	if err != nil {
+		t.Fatalf("Failed to create resolver: %v", err)
+	}
+
+	testCases := []struct {
+		format resolver.PassageFormat
+		width  int
+		bibl   string
+		urn    string
+	}{
+		{resolver.PassagePreserve, 0, "Thuc. 02.040", "urn:cts:greekLit:tlg0003.tlg001.perseus-grc2:02.040"},
+		{resolver.PassagePlain, 0, "Thuc. 02.040", "urn:cts:greekLit:tlg0003.tlg001.perseus-grc2:2.40"},
+		{resolver.PassagePadded, 0, "Thuc. 2.40", "urn:cts:greekLit:tlg0003.tlg001.perseus-grc2:002.040"},
+		{resolver.PassagePadded, 4, "Hom. Il. 1.1-10", "urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:0001.0001-0010"},
+		{resolver.PassagePadded, 0, "Hdt. iv. 142.", "urn:cts:greekLit:tlg0016.tlg001.perseus-grc2:004.142"},
+		{resolver.PassagePadded, 0, "Plat. Rep. 327a", "urn:cts:greekLit:tlg0059.tlg030.perseus-grc2:327a"},
+		{resolver.PassagePadded, 0, "Aristot. Pol. 7.1331a", "urn:cts:greekLit:tlg0086.tlg035.perseus-grc2:007.1331a"},
+	}
+	for _, tc := range testCases {
+		res.PassageFormat, res.PassageWidth = tc.format, tc.width
+		if r := res.Resolve(res.GetRef("", tc.bibl), "", "test.xml"); r.URN != tc.urn {
+			t.Errorf("%s %s: expected %q, got %q", tc.format, tc.bibl, tc.urn, r.URN)
+		}
+	}
+
+	if _, err := NewCitationProcessor(Config{PassageFormat: "roman"}); err == nil {
+		t.Error("Expected an error for an unknown passage format")
+	}
+}
+
+func TestNamedLocusLevels(t *testing.T) {
+	processor, err := NewCitationProcessor(Config{UseCitTags: true})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+
+	xmlContent := `<div><bibl n="Perseus:text:1999.01.0199:chapter=40:book=2">Thuc. 2.40</bibl> ` +
+		`<bibl n="Perseus:abo:tlg,0012,001:1:1">Il. 1.1</bibl></div>`
+	citations := processor.ExtractCitations(xmlContent, "test.xml")
+	if len(citations) != 2 {
+		t.Fatalf("Expected 2 citations, got %d", len(citations))
+	}
+
+	named := citations[0]
+	wantLocus := []resolver.LocusLevel{{Name: "book", Value: "2"}, {Name: "chapter", Value: "40"}}
+	if named.URN != "urn:cts:greekLit:tlg0003.tlg001.perseus-grc2:2.40" || !reflect.DeepEqual(named.Locus, wantLocus) {
+		t.Errorf("Expected 2.40 with locus %v, got %s with %v", wantLocus, named.URN, named.Locus)
+	}
+	if named.Scheme != "book.chapter" {
+		t.Errorf("Expected scheme book.chapter, got %q", named.Scheme)
+	}
+
+	// positional levels give a passage but no locus
+	if positional := citations[1]; positional.URN != "urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:1.1" || positional.Locus != nil {
+		t.Errorf("Expected 1.1 without locus, got %s with %v", positional.URN, positional.Locus)
+	}
+}
+
+func TestGreekNormalization(t *testing.T) {
+	processor, err := NewCitationProcessor(Config{UseCitTags: true})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+
+	// a decomposed eta with acute and non-breaking spaces
+	xmlContent := `<p><cit><bibl>Ὅμηρος Il. 1.1</bibl><quote>μῆνιν ἄειδε θεά</quote></cit> ` +
+		`<cit><bibl>Αἰσχύλος Ag. 1</bibl><quote>θεοὺς μὲν αἰτῶ</quote></cit> ` +
+		`<cit><bibl>Soph.` + "\u00a0" + `OT 100</bibl><quote>Θ` + "\u03b7\u0301" + `βας` + "\u00a0" + `πόλιν</quote></cit> ` +
+		`<cit><bibl>Πλάτων, Rep. 338d</bibl><quote>ἀκούω</quote></cit></p>`
+	citations := processor.ExtractCitations(xmlContent, "test.xml")
+	if len(citations) != 4 {
+		t.Fatalf("Expected 4 citations, got %d", len(citations))
+	}
+
+	expected := []struct{ ref, urn string }{
+		{"homer il. 1.1", "urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:1.1"},
+		{"aeschylus ag. 1", "urn:cts:greekLit:tlg0085.tlg005.perseus-grc2:1"},
+		{"soph. ot 100", "urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:100"},
+		{"plato rep. 338d", "urn:cts:greekLit:tlg0059.tlg030.perseus-grc2:338d"},
+	}
+	for i, exp := range expected {
+		if citations[i].Ref != exp.ref || citations[i].URN != exp.urn {
+			t.Errorf("Citation %d: expected %q -> %s, got %q -> %s",
+				i, exp.ref, exp.urn, citations[i].Ref, citations[i].URN)
+		}
+	}
+
+	if quote := citations[2].Quote; quote != "Θήβας πόλιν" {
+		t.Errorf("Expected NFC quote with a plain space, got %q", quote)
+	}
+}
+
+func TestSelfCheck(t *testing.T) {
+	testDataDir := findTestDataDir()
+	content, err := os.ReadFile(filepath.Join(testDataDir, "xml/campbell-sophlanguage-2.xml"))
+	if err != nil {
+		t.Fatalf("Failed to read test XML: %v", err)
+	}
+
+	sampled := func() (*CitationProcessor, []string) {
+		processor, err := NewCitationProcessor(Config{UseCitTags: false, SelfCheck: 10, SelfCheckSeed: 42})
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		processor.ExtractCitations(string(content), "campbell-sophlanguage-2.xml")
+		var urns []string
+		for _, sample := range processor.selfCheck.samples {
+			urns = append(urns, sample.DocCitURN)
+		}
+		return processor, urns
+	}
+
+	processor, first := sampled()
+	if len(first) != 10 {
+		t.Fatalf("Expected 10 sampled citations, got %d", len(first))
+	}
+	if _, second := sampled(); strings.Join(first, " ") != strings.Join(second, " ") {
+		t.Errorf("Expected the same seed to sample the same citations, got %v and %v", first, second)
+	}
+	if err := processor.runSelfCheck(); err != nil {
+		t.Errorf("Expected the self-check to pass, got %v", err)
+	}
+
+	// a resolution that changed after being written is reported
+	processor.selfCheck.samples[3].URN = "urn:cts:greekLit:tlg0000.tlg000:1"
+	if err := processor.runSelfCheck(); err == nil || !strings.Contains(err.Error(), "1 of 10") {
+		t.Errorf("Expected one mismatch, got %v", err)
+	}
+}
+
+func TestProvisionalURNs(t *testing.T) {
+	dir := t.TempDir()
+	content := `<p><bibl>Cic. Clu. 35.96</bibl> <bibl>Soph. OT 151</bibl> <bibl>Xyz. 1</bibl></p>`
+	if err := os.WriteFile(filepath.Join(dir, "a.xml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outputDir := filepath.Join(dir, "out")
+	processor, err := NewCitationProcessor(Config{
+		InputDir:       dir,
+		OutputDir:      outputDir,
+		ResolvedFile:   "resolved.jsonl",
+		UnresolvedFile: "unresolved.jsonl",
+		WorkFallback:   "none",
+		Provisional:    true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	if err := processor.ProcessAllXMLFiles(); err != nil {
+		t.Fatal(err)
+	}
+
+	provisional, err := loadCitations(filepath.Join(outputDir, provisionalFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(provisional) != 1 || provisional[0].Provisional != "urn:cts:latinLit:phi0474.UNKNOWN:35.96" || provisional[0].URN != "" {
+		t.Fatalf("Expected Cic. Clu. 35.96 with a provisional URN, got %+v", provisional)
+	}
+	// an unknown author gets no provisional URN
+	unresolved, err := loadCitations(filepath.Join(outputDir, "unresolved.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unresolved) != 1 || unresolved[0].Bibl != "Xyz. 1" || unresolved[0].Provisional != "" {
+		t.Errorf("Expected only Xyz. 1 in unresolved.jsonl, got %+v", unresolved)
+	}
+
+	// without -provisional the citation stays in unresolved.jsonl
+	processor.Config.Provisional = false
+	citations := processor.ExtractCitations(content, "a.xml")
+	if citations[0].Provisional != "" {
+		t.Errorf("Expected no provisional URN without Config.Provisional, got %q", citations[0].Provisional)
+	}
+}
+
+// TestSecondaryLiterature checks that with -secondary, bibls citing modern
+// scholarship are parsed and written to secondary.jsonl rather than resolved
+func TestSecondaryLiterature(t *testing.T) {
+	dir := t.TempDir()
+	content := `<p><bibl>Jebb ad loc.</bibl> <bibl>Wilamowitz 1889, 23</bibl> <bibl>cf. M. L. West (1966) 145</bibl>` +
+		` <bibl>Soph. OT 151</bibl> <bibl>Homer 1900</bibl> <bibl>Xyz. 1</bibl></p>`
+	if err := os.WriteFile(filepath.Join(dir, "a.xml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outputDir := filepath.Join(dir, "out")
+	processor, err := NewCitationProcessor(Config{
+		InputDir:       dir,
+		OutputDir:      outputDir,
+		ResolvedFile:   "resolved.jsonl",
+		UnresolvedFile: "unresolved.jsonl",
+		Secondary:      true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	if err := processor.ProcessAllXMLFiles(); err != nil {
+		t.Fatal(err)
+	}
+
+	secondary, err := loadCitations(filepath.Join(outputDir, secondaryFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []resolver.SecondaryReference{
+		{Author: "Jebb", AdLoc: true},
+		{Author: "Wilamowitz", Year: "1889", Pages: "23"},
+		{Author: "M. L. West", Year: "1966", Pages: "145"},
+	}
+	if len(secondary) != len(expected) {
+		t.Fatalf("Expected %d citations in secondary.jsonl, got %+v", len(expected), secondary)
+	}
+	for i, citation := range secondary {
+		if citation.Secondary == nil || *citation.Secondary != expected[i] {
+			t.Errorf("%s: expected %+v, got %+v", citation.Bibl, expected[i], citation.Secondary)
+		}
+		if citation.URN != "" {
+			t.Errorf("%s: expected no URN, got %q", citation.Bibl, citation.URN)
+		}
+	}
+	// an ancient author with a number like a year is no modern scholar
+	unresolved, err := loadCitations(filepath.Join(outputDir, "unresolved.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, citation := range unresolved {
+		if citation.Secondary != nil || citation.Bibl != "Homer 1900" && citation.Bibl != "Xyz. 1" {
+			t.Errorf("Expected only Homer 1900 and Xyz. 1 unresolved, got %s (%+v)", citation.Bibl, citation.Secondary)
+		}
+	}
+
+	// without -secondary the bibls are resolved as any other
+	processor.Config.Secondary = false
+	citations := processor.ExtractCitations(content, "a.xml")
+	if citations[0].Secondary != nil || citations[0].reason != resolver.ReasonUnknownAuthor {
+		t.Errorf("Expected Jebb ad loc. unresolved as an unknown author without Config.Secondary, got %+v (%s)", citations[0].Secondary, citations[0].reason)
+	}
+}
+
+func TestFuzzyAuthors(t *testing.T) {
+	xmlContent := `<p><bibl>Thukydides 1.22</bibl> <bibl>Aeschyl. Ag. 100</bibl> <bibl>Thuc. 1.22</bibl> <bibl>Jebb 100</bibl></p>`
+
+	processor, err := NewCitationProcessor(Config{FuzzyAuthors: 0.9})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	citations := processor.ExtractCitations(xmlContent, "test.xml")
+	if len(citations) != 4 {
+		t.Fatalf("Expected 4 citations, got %d", len(citations))
+	}
+
+	tests := []struct {
+		urn     string
+		matched string
+		dist    int
+	}{
+		{"urn:cts:greekLit:tlg0003.tlg001.perseus-grc2:1.22", "thucydides", 1},
+		{"urn:cts:greekLit:tlg0085.tlg005.perseus-grc2:100", "aesch.", 2},
+		{"urn:cts:greekLit:tlg0003.tlg001.perseus-grc2:1.22", "", 0},
+		{"", "", 0},
+	}
+	for i, tt := range tests {
+		citation := citations[i]
+		if citation.URN != tt.urn {
+			t.Errorf("%s: expected URN %q, got %q", citation.Bibl, tt.urn, citation.URN)
+		}
+		if tt.matched == "" {
+			if citation.FuzzyAuthor != nil {
+				t.Errorf("%s: expected no fuzzy match, got %+v", citation.Bibl, citation.FuzzyAuthor)
+			}
+			continue
+		}
+		if citation.FuzzyAuthor == nil || citation.FuzzyAuthor.Matched != tt.matched || citation.FuzzyAuthor.Distance != tt.dist {
+			t.Errorf("%s: expected fuzzy match to %q at distance %d, got %+v", citation.Bibl, tt.matched, tt.dist, citation.FuzzyAuthor)
+		}
+	}
+
+	// off by default
+	processor.Resolver.FuzzyAuthors = 0
+	if citations := processor.ExtractCitations(xmlContent, "test.xml"); citations[0].URN != "" || citations[0].FuzzyAuthor != nil {
+		t.Errorf("Expected Thukydides unresolved without fuzzy matching, got %+v", citations[0])
+	}
+
+	if _, err := NewCitationProcessor(Config{FuzzyAuthors: 1.5}); err == nil {
+		t.Error("Expected an error for a threshold above 1")
+	}
+}
+
+func TestSuppressionComments(t *testing.T) {
+	xmlContent := `<div><p>Cf. <!-- citation-processor:ignore --> <bibl>Soph. OT 151</bibl> and <bibl>Soph. OT 152</bibl>.</p>` +
+		`<p><?citation-processor ignore-block?>See <cit><bibl>Hom. Il. 1.1</bibl><quote>μῆνιν</quote></cit>, <bibl>Hom. Il. 1.2</bibl></p>` +
+		`<p><!--citation-processor:ignore--><ref target="urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:100"/> <bibl>Soph. OT 153</bibl></p></div>`
+
+	for _, useCitTags := range []bool{true, false} {
+		processor, err := NewCitationProcessor(Config{UseCitTags: useCitTags})
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		citations := processor.ExtractCitations(xmlContent, "test.xml")
+
+		var bibls []string
+		for _, citation := range citations {
+			bibls = append(bibls, citation.Bibl)
+		}
+		if got := strings.Join(bibls, ", "); got != "Soph. OT 152, Soph. OT 153" {
+			t.Errorf("UseCitTags %v: expected only the unmarked citations, got %q", useCitTags, got)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	cp, err := NewCitationProcessor(Config{UseCitTags: true})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	citationpb.RegisterResolverServer(server, &resolverServer{cp: cp})
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := citationpb.NewResolverClient(conn)
+	ctx := context.Background()
+
+	resp, err := client.Resolve(ctx, &citationpb.ResolveRequest{NAttrib: "Soph. OT 151", Bibl: "O. T. 151"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Urn != "urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:151" || resp.Ref != "soph. ot 151" {
+		t.Errorf("Resolve: got %q -> %q", resp.Ref, resp.Urn)
+	}
+	resp, err = client.Resolve(ctx, &citationpb.ResolveRequest{Bibl: "IG I³ 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Documentary.GetCorpus() != "ig" || resp.ExternalId != "https://epigraphy.packhum.org/text/1" {
+		t.Errorf("Resolve: expected the inscription and its external id, got %v", resp)
+	}
+
+	stream, err := client.ResolveBatch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs := map[string]string{
+		"a": "urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:1.1",
+		"b": "",
+	}
+	for id, bibl := range map[string]string{"a": "Hom. Il. 1.1", "b": "Xyz. 1"} {
+		if err := stream.Send(&citationpb.ResolveRequest{Id: id, Bibl: bibl}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.CloseSend()
+	for range refs {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Urn != refs[resp.Id] {
+			t.Errorf("ResolveBatch %s: expected %q, got %q", resp.Id, refs[resp.Id], resp.Urn)
+		}
+		if resp.Id == "b" && resp.Reason != string(resolver.ReasonUnknownAuthor) {
+			t.Errorf("ResolveBatch b: expected reason %s, got %q", resolver.ReasonUnknownAuthor, resp.Reason)
+		}
+	}
+
+	extracted, err := client.Extract(ctx, &citationpb.ExtractRequest{
+		Filename: "test.xml",
+		Xml:      `<p><cit><bibl n="Soph. OT 151">O. T. 151</bibl><quote>Θήβας</quote></cit></p>`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(extracted.Citations) != 1 || extracted.Citations[0].Quote != "Θήβας" ||
+		extracted.Citations[0].Urn != "urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:151" {
+		t.Errorf("Extract: got %v", extracted.Citations)
+	}
+}
+
+// TestCitationMessageFields checks that every field of a citation record has
+// its counterpart in the Citation message, and that serve fills it in
+func TestCitationMessageFields(t *testing.T) {
+	var check func(record reflect.Type, message protoreflect.MessageDescriptor)
+	check = func(record reflect.Type, message protoreflect.MessageDescriptor) {
+		for i := 0; i < record.NumField(); i++ {
+			field := record.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if !field.IsExported() || name == "" || name == "-" {
+				continue
+			}
+			counterpart := message.Fields().ByName(protoreflect.Name(name))
+			if counterpart == nil {
+				t.Errorf("%s.%s (%q) has no field in the %s message", record.Name(), field.Name, name, message.Name())
+				continue
+			}
+			inner := field.Type
+			for inner.Kind() == reflect.Pointer || inner.Kind() == reflect.Slice {
+				inner = inner.Elem()
+			}
+			if inner.Kind() == reflect.Struct {
+				if counterpart.Message() == nil {
+					t.Errorf("%s.%s (%q) is a record, but not a message in %s", record.Name(), field.Name, name, message.Name())
+					continue
+				}
+				check(inner, counterpart.Message())
+			}
+		}
+	}
+	check(reflect.TypeOf(Citation{}), (&citationpb.Citation{}).ProtoReflect().Descriptor())
+
+	// a citation with every field set converts to a message with every
+	// field set
+	var fill func(value reflect.Value)
+	fill = func(value reflect.Value) {
+		switch value.Kind() {
+		case reflect.String:
+			value.SetString("x")
+		case reflect.Bool:
+			value.SetBool(true)
+		case reflect.Int, reflect.Int64:
+			value.SetInt(1)
+		case reflect.Float64:
+			value.SetFloat(0.5)
+		case reflect.Pointer:
+			value.Set(reflect.New(value.Type().Elem()))
+			fill(value.Elem())
+		case reflect.Slice:
+			value.Set(reflect.MakeSlice(value.Type(), 1, 1))
+			fill(value.Index(0))
+		case reflect.Struct:
+			for i := 0; i < value.NumField(); i++ {
+				if value.Type().Field(i).IsExported() {
+					fill(value.Field(i))
+				}
+			}
+		}
+	}
+	var citation Citation
+	fill(reflect.ValueOf(&citation).Elem())
+	message := citationMessage(citation).ProtoReflect()
+	fields := message.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		if !message.Has(fields.Get(i)) {
+			t.Errorf("citationMessage leaves %s unset", fields.Get(i).Name())
+		}
+	}
+}
+
+func TestApparatusCitations(t *testing.T) {
+	xmlContent := `<div><p><app><lem>ἔβας</lem><rdg wit="#L">ἔβης: cf. <bibl>Soph. OT 152</bibl></rdg></app> ` +
+		`As in <cit><bibl>Soph. OT 151</bibl><quote>ἔβας</quote></cit>.</p>` +
+		`<app><lem>Θήβας <hi>sic</hi></lem><note><ref target="urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:153"/></note></app></div>`
+
+	for _, useCitTags := range []bool{true, false} {
+		processor, err := NewCitationProcessor(Config{UseCitTags: useCitTags})
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		citations := processor.ExtractCitations(xmlContent, "test.xml")
+
+		expected := map[string]string{
+			"urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:152": "ἔβας",
+			"urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:151": "",
+			"urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:153": "Θήβας sic",
+		}
+		if len(citations) != len(expected) {
+			t.Fatalf("UseCitTags %v: expected %d citations, got %d", useCitTags, len(expected), len(citations))
+		}
+		for _, citation := range citations {
+			lemma, exists := expected[citation.URN]
+			if !exists {
+				t.Errorf("UseCitTags %v: unexpected citation %s", useCitTags, citation.URN)
+				continue
+			}
+			if citation.Lemma != lemma || citation.InApparatus != (lemma != "") {
+				t.Errorf("UseCitTags %v: %s: expected lemma %q, got %q (in apparatus %v)",
+					useCitTags, citation.URN, lemma, citation.Lemma, citation.InApparatus)
+			}
+		}
+	}
+}
+
+func TestSectionHeadings(t *testing.T) {
+	xmlContent := `<div><p>See <bibl>Soph. OT 150</bibl>.</p>` +
+		`<div><head>Commentary on <hi>line</hi>
+  350</head><p><cit><bibl>Soph. El. 1126</bibl><quote>ὦ φιλτάτου</quote></cit></p>` +
+		`<head></head><p><ref target="urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:153"/></p></div>` +
+		`<div><head>Particles</head><p><bibl>Soph. OT 151</bibl></p></div></div>`
+
+	for _, useCitTags := range []bool{true, false} {
+		processor, err := NewCitationProcessor(Config{UseCitTags: useCitTags})
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		citations := processor.ExtractCitations(xmlContent, "test.xml")
+
+		expected := map[string]string{
+			"urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:150": "",
+			"urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:1126": "Commentary on line 350",
+			"urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:153": "Commentary on line 350",
+			"urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:151": "Particles",
+		}
+		if len(citations) != len(expected) {
+			t.Fatalf("UseCitTags %v: expected %d citations, got %d", useCitTags, len(expected), len(citations))
+		}
+		for _, citation := range citations {
+			heading, exists := expected[citation.URN]
+			if !exists {
+				t.Errorf("UseCitTags %v: unexpected citation %s", useCitTags, citation.URN)
+				continue
+			}
+			if citation.Heading != heading {
+				t.Errorf("UseCitTags %v: %s: expected heading %q, got %q", useCitTags, citation.URN, heading, citation.Heading)
+			}
+		}
+	}
+}
+
+// TestCommentedRanges checks that citations inside commentary sections
+// headed by a range of the base text carry that range's URN
+func TestCommentedRanges(t *testing.T) {
+	const antigone = "urn:cts:greekLit:tlg0011.tlg002.perseus-grc2"
+	xmlContent := `<body><div><head>Antigone 1–99</head><p><bibl>Hom. Il. 1.1</bibl></p>` +
+		`<div><head>Lines 1–50</head><p><bibl>Hdt. 1.1</bibl></p></div></div>` +
+		`<div type="commentary"><head>On ll. 441-525</head><p><bibl>Thuc. 2.65</bibl></p></div>` +
+		`<div><head>Appendix</head><p><bibl>Soph. OT 150</bibl></p></div></body>`
+
+	processor, err := NewCitationProcessor(Config{UseCitTags: false})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	expected := []string{antigone + ":1-99", antigone + ":1-50", antigone + ":441-525", ""}
+	var got []string
+	for _, citation := range processor.ExtractCitations(xmlContent, "test.xml") {
+		got = append(got, citation.Commented)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected commented ranges %q, got %q", expected, got)
+	}
+
+	// sections headed only by lines need the commented work
+	xmlContent = `<body><div><head>Lines 1-50</head><p><bibl>Hdt. 1.1</bibl></p></div></body>`
+	for work, expected := range map[string]string{"": "", antigone: antigone + ":1-50"} {
+		processor, err := NewCitationProcessor(Config{UseCitTags: false, CommentedWork: work})
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		citations := processor.ExtractCitations(xmlContent, "test.xml")
+		if len(citations) != 1 || citations[0].Commented != expected {
+			t.Errorf("With commented work %q, expected commented range %q, got %+v", work, expected, citations)
+		}
+	}
+}
+
+func TestCooccurrencePrior(t *testing.T) {
+	const (
+		hippiasMajor = "urn:cts:greekLit:tlg0059.tlg025.perseus-grc2:286a"
+		hippiasMinor = "urn:cts:greekLit:tlg0059.tlg026.perseus-grc2:364a"
+	)
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.xml": `<p><bibl>Plat. Hipp. Maj. 285b</bibl> <bibl>Plat. Hipp. 286a</bibl></p>`,
+		"b.xml": `<p><bibl>Plat. Hipp. Min. 363a</bibl> <bibl>Plat. Hipp. 364a</bibl></p>`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// "Plat. Hipp." fits the Hipparchus and both Hippias dialogues equally
+	// well; each document's earlier citation settles it
+	processor, err := NewCitationProcessor(Config{UseCitTags: true, Prior: true})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	for name, urn := range map[string]string{"a.xml": hippiasMajor, "b.xml": hippiasMinor} {
+		citations := processor.ExtractCitations(files[name], name)
+		if len(citations) != 2 {
+			t.Fatalf("%s: expected 2 citations, got %d", name, len(citations))
+		}
+		if citations[0].PriorUsed {
+			t.Errorf("%s: expected the unambiguous citation not to use the prior", name)
+		}
+		if citations[1].URN != urn || !citations[1].PriorUsed {
+			t.Errorf("%s: expected %s chosen by the prior, got %s (prior used %v)", name, urn, citations[1].URN, citations[1].PriorUsed)
+		}
+	}
+
+	// seeded from a previous run's output, a document's bare reference is
+	// settled by what it cited there
+	previous := filepath.Join(dir, "previous")
+	if err := os.MkdirAll(previous, 0755); err != nil {
+		t.Fatal(err)
+	}
+	record := `{"bibl":"Plat. Hipp. Maj. 285b","urn":"urn:cts:greekLit:tlg0059.tlg025.perseus-grc2:285b","filename":"/old/corpus/c.xml"}` + "\n"
+	if err := os.WriteFile(filepath.Join(previous, "resolved.jsonl"), []byte(record), 0644); err != nil {
+		t.Fatal(err)
+	}
+	processor, err = NewCitationProcessor(Config{UseCitTags: true, PriorFrom: previous})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	citations := processor.ExtractCitations(`<p><bibl>Plat. Hipp. 286a</bibl></p>`, filepath.Join(dir, "c.xml"))
+	if len(citations) != 1 || citations[0].URN != hippiasMajor || !citations[0].PriorUsed {
+		t.Errorf("Expected %s chosen by the seeded prior, got %+v", hippiasMajor, citations)
+	}
+
+	// off by default
+	processor, err = NewCitationProcessor(Config{UseCitTags: true})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	for _, citation := range processor.ExtractCitations(files["a.xml"], "a.xml") {
+		if citation.PriorUsed {
+			t.Errorf("Expected no prior without Config.Prior, got %+v", citation)
+		}
+	}
+
+	if _, err := NewCitationProcessor(Config{PriorFrom: filepath.Join(dir, "missing")}); err == nil {
+		t.Error("Expected an error for a prior directory without resolved citations")
+	}
+}
+
+func TestFragmentCitations(t *testing.T) {
+	processor, err := NewCitationProcessor(Config{UseCitTags: true})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+
+	tests := []struct {
+		bibl     string
+		urn      string
+		fragment *resolver.Fragment
+	}{
+		{"FGrH 115 F 30", "urn:fragment:fgrh:115:F30",
+			&resolver.Fragment{Collection: "fgrh", Author: "115", Name: "Theopompus", Kind: "F", Number: "30"}},
+		{"Theopomp. FGrHist 115 T 2", "urn:fragment:fgrh:115:T2",
+			&resolver.Fragment{Collection: "fgrh", Author: "115", Name: "Theopompus", Kind: "T", Number: "2"}},
+		{"DK 22 B 30", "urn:fragment:dk:22:B30",
+			&resolver.Fragment{Collection: "dk", Author: "22", Name: "Heraclitus", Kind: "B", Number: "30"}},
+		{"Sappho fr. 31 L.-P.", "urn:fragment:plf:tlg0009:F31",
+			&resolver.Fragment{Collection: "plf", Author: "tlg0009", Name: "sappho", Kind: "F", Number: "31"}},
+		{"Sappho fr. 31a Voigt", "urn:fragment:voigt:tlg0009:F31a",
+			&resolver.Fragment{Collection: "voigt", Author: "tlg0009", Name: "sappho", Kind: "F", Number: "31a"}},
+		{"Soph. F 941 Radt", "urn:fragment:trgf:tlg0011:F941",
+			&resolver.Fragment{Collection: "trgf", Author: "tlg0011", Name: "sophocles", Kind: "F", Number: "941"}},
+		{"Callim. frr. 1–2 Pf.", "urn:fragment:pfeiffer:tlg0533:F1-2",
+			&resolver.Fragment{Collection: "pfeiffer", Author: "tlg0533", Name: "callimachus", Kind: "F", Number: "1-2"}},
+		// no collection named: resolved as before
+		{"Soph. OT 151", "urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:151", nil},
+		// no author to number the fragment by
+		{"fr. 12 West", "", nil},
+	}
+	for _, tt := range tests {
+		citations := processor.ExtractCitations("<p><bibl>"+tt.bibl+"</bibl></p>", "test.xml")
+		if len(citations) != 1 {
+			t.Errorf("%s: expected 1 citation, got %d", tt.bibl, len(citations))
+			continue
+		}
+		citation := citations[0]
+		if citation.URN != tt.urn {
+			t.Errorf("%s: expected URN %q, got %q (warnings %v)", tt.bibl, tt.urn, citation.URN, citation.Warnings)
+		}
+		if !reflect.DeepEqual(citation.Fragment, tt.fragment) {
+			t.Errorf("%s: expected fragment %+v, got %+v", tt.bibl, tt.fragment, citation.Fragment)
+		}
+	}
+}
+
+// TestDocumentaryCitations checks that inscriptions and papyri resolve to
+// identifiers of their own, with an external ID where the corpus gives one
+func TestDocumentaryCitations(t *testing.T) {
+	processor, err := NewCitationProcessor(Config{UseCitTags: true})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+
+	tests := []struct {
+		bibl        string
+		urn         string
+		externalID  string
+		documentary *resolver.DocumentaryText
+	}{
+		{"IG II² 1234", "urn:inscription:ig:2ed2:1234", "",
+			&resolver.DocumentaryText{Corpus: "ig", Kind: "inscription", Volume: "2", Edition: "2", Number: "1234"}},
+		// a PHI text number from the ids table
+		{"IG I³ 1", "urn:inscription:ig:1ed3:1", "https://epigraphy.packhum.org/text/1",
+			&resolver.DocumentaryText{Corpus: "ig", Kind: "inscription", Volume: "1", Edition: "3", Number: "1"}},
+		{"SEG 30.82", "urn:inscription:seg:30:82", "https://doi.org/10.1163/1874-6772_seg_a30_82",
+			&resolver.DocumentaryText{Corpus: "seg", Kind: "inscription", Volume: "30", Number: "82"}},
+		{"SEG XXX 82", "urn:inscription:seg:30:82", "https://doi.org/10.1163/1874-6772_seg_a30_82",
+			&resolver.DocumentaryText{Corpus: "seg", Kind: "inscription", Volume: "30", Number: "82"}},
+		// a volume in a malformed Roman numeral is not read
+		{"SEG IIV 82", "", "", nil},
+		{"SEG XXXX 82", "", "", nil},
+		{"OGIS 90", "urn:inscription:ogis:90", "",
+			&resolver.DocumentaryText{Corpus: "ogis", Kind: "inscription", Number: "90"}},
+		{"P.Oxy. IV 744", "urn:papyrus:p.oxy:4:744", "https://papyri.info/ddbdp/p.oxy;4;744",
+			&resolver.DocumentaryText{Corpus: "p.oxy", Kind: "papyrus", Volume: "4", Number: "744"}},
+		{"P. Oxy. 4.744", "urn:papyrus:p.oxy:4:744", "https://papyri.info/ddbdp/p.oxy;4;744",
+			&resolver.DocumentaryText{Corpus: "p.oxy", Kind: "papyrus", Volume: "4", Number: "744"}},
+		// no volume, so no papyri.info page
+		{"P.Oxy. 1234", "urn:papyrus:p.oxy:1234", "",
+			&resolver.DocumentaryText{Corpus: "p.oxy", Kind: "papyrus", Number: "1234"}},
+		// IG numbers its texts afresh in each volume
+		{"IG 1234", "", "", nil},
+		{"Soph. OT 151", "urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:151", "", nil},
+	}
+	for _, tt := range tests {
+		citations := processor.ExtractCitations("<p><bibl>"+tt.bibl+"</bibl></p>", "test.xml")
+		if len(citations) != 1 {
+			t.Errorf("%s: expected 1 citation, got %d", tt.bibl, len(citations))
+			continue
+		}
+		citation := citations[0]
+		if citation.URN != tt.urn {
+			t.Errorf("%s: expected URN %q, got %q (warnings %v)", tt.bibl, tt.urn, citation.URN, citation.Warnings)
+		}
+		if citation.ExternalID != tt.externalID {
+			t.Errorf("%s: expected external ID %q, got %q", tt.bibl, tt.externalID, citation.ExternalID)
+		}
+		if !reflect.DeepEqual(citation.Documentary, tt.documentary) {
+			t.Errorf("%s: expected documentary text %+v, got %+v", tt.bibl, tt.documentary, citation.Documentary)
+		}
+	}
+}
+
+func TestUniqueCitations(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.xml": `<p><cit><bibl>Soph. OT 151</bibl><quote>τᾶς πολυχρύσου</quote></cit> <cit><bibl>Soph. OT 151</bibl><quote>Θήβας</quote></cit></p>`,
+		"b.xml": `<p><cit><bibl n="Soph. OT 151">O. T. 151</bibl><quote>τᾶς
+  πολυχρύσου</quote></cit> <bibl>Xyz. 1</bibl></p>`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outputDir := filepath.Join(dir, "out")
+	processor, err := NewCitationProcessor(Config{
+		InputDir:       dir,
+		OutputDir:      outputDir,
+		ResolvedFile:   "resolved.jsonl",
+		UnresolvedFile: "unresolved.jsonl",
+		UseCitTags:     true,
+		Unique:         true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	if err := processor.ProcessAllXMLFiles(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, uniqueFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unique []uniqueCitation
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		var entry uniqueCitation
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		unique = append(unique, entry)
+	}
+	if len(unique) != 2 {
+		t.Fatalf("Expected 2 distinct citations, got %d: %s", len(unique), content)
+	}
+	// the quote broken across lines in b.xml counts as the same quote
+	first := unique[0]
+	if first.Quote != "τᾶς πολυχρύσου" || first.Count != 2 || len(first.Occurrences) != 2 ||
+		first.Occurrences[1].Filename != filepath.Join(dir, "b.xml") {
+		t.Errorf("Expected τᾶς πολυχρύσου twice across both files, got %+v", first)
+	}
+	if unique[1].Quote != "Θήβας" || unique[1].Count != 1 {
+		t.Errorf("Expected Θήβας once, got %+v", unique[1])
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "resolved.jsonl")); err != nil {
+		t.Errorf("Expected resolved.jsonl to be written as usual: %v", err)
+	}
+
+	if _, err := NewCitationProcessor(Config{OutputDir: stdoutOutput, Unique: true}); err == nil {
+		t.Error("Expected an error for -unique with -output -")
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.xml": `<p><bibl>Plat. Hipp. 285b</bibl> <bibl>Plat. A. 103a</bibl></p>`,
+		"b.xml": `<p><bibl>Soph. OT 1</bibl></p>`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// "Plat. Hipp." fits three dialogues equally well; every load of the data
+	// gives it to the first candidate
+	var first string
+	for i := 0; i < 20; i++ {
+		processor, err := NewCitationProcessor(Config{UseCitTags: true, Deterministic: true})
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		citations := processor.ExtractCitations(files["a.xml"], "a.xml")
+		if len(citations) != 2 {
+			t.Fatalf("Expected 2 citations, got %d", len(citations))
+		}
+		if i == 0 {
+			first = citations[0].URN
+			if len(citations[0].Candidates) == 0 || citations[0].Candidates[0].URN != first {
+				t.Errorf("Expected the first candidate of %v, got %s", citations[0].Candidates, first)
+			}
+		} else if citations[0].URN != first {
+			t.Fatalf("Run %d resolved %s, the first run %s", i, citations[0].URN, first)
+		}
+	}
+
+	// the order of -input entries, and repeating one, does not change the
+	// output, with or without -deterministic
+	var outputs []string
+	for _, input := range []string{"a.xml,b.xml", "b.xml,a.xml,a.xml"} {
+		var paths []string
+		for _, name := range strings.Split(input, ",") {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+		outputDir := filepath.Join(dir, "out")
+		processor, err := NewCitationProcessor(Config{
+			InputDir:       strings.Join(paths, ","),
+			OutputDir:      outputDir,
+			ResolvedFile:   "resolved.jsonl",
+			UnresolvedFile: "unresolved.jsonl",
+			UseCitTags:     true,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		if err := processor.ProcessAllXMLFiles(); err != nil {
+			t.Fatal(err)
+		}
+		output, err := os.ReadFile(filepath.Join(outputDir, "resolved.jsonl"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, string(output))
+	}
+	if outputs[0] != outputs[1] {
+		t.Errorf("Expected identical output for reordered input, got:\n%s\nand:\n%s", outputs[0], outputs[1])
+	}
+
+	// nothing a deterministic run writes depends on the clock: two runs
+	// started an hour apart write the same files, run.json included
+	config := Config{
+		InputDir:       filepath.Join(dir, "a.xml") + "," + filepath.Join(dir, "b.xml"),
+		ResolvedFile:   "resolved.jsonl",
+		UnresolvedFile: "unresolved.jsonl",
+		UseCitTags:     true,
+		Deterministic:  true,
+		RunMetadata:    true,
+		RunFlags:       map[string]string{"deterministic": "true", "run-metadata": "true"},
+	}
+	var runs []map[string]string
+	for i := 0; i < 2; i++ {
+		config.OutputDir = t.TempDir()
+		processor, err := NewCitationProcessor(config)
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		processor.startedAt = processor.startedAt.Add(-time.Duration(i) * time.Hour)
+		if err := processor.ProcessAllXMLFiles(); err != nil {
+			t.Fatal(err)
+		}
+		entries, err := os.ReadDir(config.OutputDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files := make(map[string]string)
+		for _, entry := range entries {
+			content, err := os.ReadFile(filepath.Join(config.OutputDir, entry.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[entry.Name()] = string(content)
+		}
+		runs = append(runs, files)
+	}
+	if _, ok := runs[0][runFile]; !ok {
+		t.Errorf("Expected %s written, got %v", runFile, runs[0])
+	}
+	if !reflect.DeepEqual(runs[0], runs[1]) {
+		t.Errorf("Expected byte-identical deterministic runs, got:\n%v\nand:\n%v", runs[0], runs[1])
+	}
+
+	config.TimestampOutput = true
+	if _, err := NewCitationProcessor(config); err == nil {
+		t.Error("Expected -deterministic with -timestamp-output to be rejected")
+	}
+}
+
+func TestQuotedCitations(t *testing.T) {
+	xmlContent := `<p><cit><bibl>Soph. El. 1126</bibl><quote>ὦ φιλτάτου</quote></cit> ` +
+		`<quote>as the chorus says, <bibl>Soph. OT 150</bibl>, <quote>and <ref target="urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:153"/></quote> so</quote> ` +
+		`<quote/><bibl>Soph. OT 151</bibl></p>`
+
+	for _, useCitTags := range []bool{true, false} {
+		processor, err := NewCitationProcessor(Config{UseCitTags: useCitTags})
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		citations := processor.ExtractCitations(xmlContent, "test.xml")
+
+		expected := map[string]bool{
+			"urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:1126": false,
+			"urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:150":  true,
+			"urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:153":  true,
+			"urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:151":  false,
+		}
+		if len(citations) != len(expected) {
+			t.Fatalf("UseCitTags %v: expected %d citations, got %d", useCitTags, len(expected), len(citations))
+		}
+		for _, citation := range citations {
+			inQuote, exists := expected[citation.URN]
+			if !exists {
+				t.Errorf("UseCitTags %v: unexpected citation %s", useCitTags, citation.URN)
+				continue
+			}
+			if citation.InQuote != inQuote {
+				t.Errorf("UseCitTags %v: %s: expected in_quote %v, got %v", useCitTags, citation.URN, inQuote, citation.InQuote)
+			}
+		}
+	}
+
+	// with SkipQuoted they are dropped
+	processor, err := NewCitationProcessor(Config{UseCitTags: true, SkipQuoted: true})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	citations := processor.ExtractCitations(xmlContent, "test.xml")
+	if len(citations) != 2 {
+		t.Fatalf("Expected 2 citations outside quotations, got %d", len(citations))
+	}
+	for _, citation := range citations {
+		if citation.InQuote {
+			t.Errorf("Expected %s to be dropped", citation.URN)
+		}
+	}
+}
+
+func TestWebAnnotations(t *testing.T) {
+	dir := t.TempDir()
+	xmlFile := filepath.Join(dir, "notes.xml")
+	content := `<TEI><teiHeader><fileDesc><titleStmt><title>Notes</title></titleStmt></fileDesc></teiHeader>` +
+		`<text><body><p>On &amp; after <hi>ὦ</hi> see <bibl n="Soph. OT 150">Soph. <title>OT</title> 150</bibl> ` +
+		`and <bibl>Unknown 12</bibl>.</p></body></text></TEI>`
+	if err := os.WriteFile(xmlFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outputDir := filepath.Join(dir, "out")
+	processor, err := NewCitationProcessor(Config{
+		InputDir:       xmlFile,
+		OutputDir:      outputDir,
+		ResolvedFile:   "resolved.jsonl",
+		UnresolvedFile: "unresolved.jsonl",
+		UseCitTags:     false,
+		Format:         "annotations",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	if err := processor.ProcessAllXMLFiles(); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := os.ReadFile(filepath.Join(outputDir, annotationsFile))
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", annotationsFile, err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 annotation for the resolved citation, got:\n%s", output)
+	}
+	var annotation struct {
+		Context    string `json:"@context"`
+		ID         string `json:"id"`
+		Type       string `json:"type"`
+		Motivation string `json:"motivation"`
+		Body       struct {
+			ID string `json:"id"`
+		} `json:"body"`
+		Target struct {
+			Source   string `json:"source"`
+			Selector []struct {
+				Type  string `json:"type"`
+				Start int    `json:"start"`
+				End   int    `json:"end"`
+				Exact string `json:"exact"`
+			} `json:"selector"`
+		} `json:"target"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &annotation); err != nil {
+		t.Fatal(err)
+	}
+	if annotation.Context != "http://www.w3.org/ns/anno.jsonld" || annotation.Type != "Annotation" || annotation.ID != "notes:citations-1" {
+		t.Errorf("Unexpected annotation %s", lines[0])
+	}
+	if annotation.Body.ID != "urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:150" {
+		t.Errorf("Expected the CTS URN as body, got %s", annotation.Body.ID)
+	}
+	if !strings.HasPrefix(annotation.Target.Source, "file:///") || !strings.HasSuffix(annotation.Target.Source, "/notes.xml") {
+		t.Errorf("Expected the file URL as target source, got %s", annotation.Target.Source)
+	}
+
+	// positions count the characters of the document's text, with markup left
+	// out and entities as one character
+	text := "NotesOn & after ὦ see Soph. OT 150 and Unknown 12."
+	start := strings.Index(text, "Soph.")
+	start = len([]rune(text[:start]))
+	if len(annotation.Target.Selector) != 2 {
+		t.Fatalf("Expected position and quote selectors, got %s", lines[0])
+	}
+	position, quote := annotation.Target.Selector[0], annotation.Target.Selector[1]
+	if position.Type != "TextPositionSelector" || position.Start != start || position.End != start+len("Soph. OT 150") {
+		t.Errorf("Expected text position %d-%d, got %+v", start, start+len("Soph. OT 150"), position)
+	}
+	if quote.Type != "TextQuoteSelector" || quote.Exact != "Soph. OT 150" {
+		t.Errorf("Expected the citation's text quoted, got %+v", quote)
+	}
+}
+
+func TestParquetOutput(t *testing.T) {
+	dir := t.TempDir()
+	xmlFile := filepath.Join(dir, "notes.xml")
+	content := `<TEI><teiHeader><fileDesc><titleStmt><title>Notes</title></titleStmt></fileDesc></teiHeader>` +
+		`<text><body><p><bibl n="Soph. OT 150">Soph. OT 150</bibl> <bibl>Xyz. Abc. 12</bibl> <bibl>Hom. Il. 30.1</bibl></p></body></text></TEI>`
+	if err := os.WriteFile(xmlFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outputDir := filepath.Join(dir, "out")
+	processor, err := NewCitationProcessor(Config{
+		InputDir:       xmlFile,
+		OutputDir:      outputDir,
+		ResolvedFile:   "resolved.jsonl",
+		UnresolvedFile: "unresolved.jsonl",
+		Format:         "parquet",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	if err := processor.ProcessAllXMLFiles(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(filepath.Join(outputDir, parquetFile))
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", parquetFile, err)
+	}
+	defer file.Close()
+	reader := parquet.NewReader(file)
+	defer reader.Close()
+	columns := make(map[string]bool)
+	for _, field := range reader.Schema().Fields() {
+		columns[field.Name()] = true
+	}
+	for _, column := range []string{"urn", "resolved", "document_title", "warnings", "reason", "scheme_violation", "candidates", "step_confidence"} {
+		if !columns[column] {
+			t.Errorf("Expected a %s column in the schema:\n%s", column, reader.Schema())
+		}
+	}
+	if reader.Schema().Name() != "citation" {
+		t.Errorf("Expected the schema named citation, got %s", reader.Schema().Name())
+	}
+	if reader.NumRows() != 3 {
+		t.Fatalf("Expected 3 rows, resolved and unresolved, got %d", reader.NumRows())
+	}
+	var rows []parquetCitation
+	for {
+		var row parquetCitation
+		if err := reader.Read(&row); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("Expected 3 rows, got %d", len(rows))
+	}
+	resolved, unknown, violation := rows[0], rows[1], rows[2]
+	if !resolved.Resolved || resolved.URN != "urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:150" || resolved.DocCitURN != "notes:citations-1" ||
+		resolved.DocumentTitle != "Notes" || resolved.Scheme != "line" {
+		t.Errorf("Unexpected row for the resolved citation: %+v", resolved)
+	}
+	if unknown.Resolved || unknown.URN != "" || unknown.Reason != "unknown-author" || len(unknown.Warnings) == 0 {
+		t.Errorf("Unexpected row for the unknown author: %+v", unknown)
+	}
+	if violation.Reason != "scheme-violation" || violation.SchemeViolation != "urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:30.1" {
+		t.Errorf("Unexpected row for the scheme violation: %+v", violation)
+	}
+
+	if _, err := NewCitationProcessor(Config{OutputDir: "-", Format: "parquet"}); err == nil {
+		t.Error("Expected an error for parquet output to stdout")
+	}
+}
+
+func TestProcessReader(t *testing.T) {
+	xmlContent := `<p><bibl>Soph. OT 150</bibl> <bibl>Soph. El. 1126</bibl></p>`
+
+	var out strings.Builder
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+
+	processor, err := NewCitationProcessor(Config{OutputDir: "-"})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	if err := processor.ProcessReader(strings.NewReader(xmlContent), "notes.xml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := processor.Writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 citations on stdout, got:\n%s", out.String())
+	}
+	var citation Citation
+	if err := json.Unmarshal([]byte(lines[0]), &citation); err != nil {
+		t.Fatal(err)
+	}
+	if citation.Filename != "notes.xml" || citation.DocCitURN != "notes:citations-1" || citation.URN != "urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:150" {
+		t.Errorf("Unexpected citation %s", lines[0])
+	}
+
+	citations, err := processor.ExtractFromString(xmlContent, "notes.xml")
+	if err != nil || len(citations) != 2 {
+		t.Errorf("Expected 2 citations from the string, got %d (%v)", len(citations), err)
+	}
+	if _, err := processor.ExtractFromString(`<p><bibl>Soph. OT 150</p>`, "broken.xml"); err == nil {
+		t.Error("Expected an error for a malformed document")
+	}
+}
+
+// documentRows is a DocumentRows over rows held in memory
+type documentRows struct {
+	rows [][2]any
+	next int
+}
+
+func (r *documentRows) Next() bool {
+	r.next++
+	return r.next <= len(r.rows)
+}
+
+func (r *documentRows) Scan(dest ...any) error {
+	row := r.rows[r.next-1]
+	*dest[0].(*string) = fmt.Sprint(row[0])
+	*dest[1].(*[]byte) = []byte(row[1].(string))
+	return nil
+}
+
+func (r *documentRows) Err() error { return nil }
+
+// insertRecorder is an Execer recording the arguments of each statement
+type insertRecorder struct {
+	query string
+	rows  [][]any
+}
+
+func (r *insertRecorder) Exec(query string, args ...any) (sql.Result, error) {
+	r.query = query
+	r.rows = append(r.rows, args)
+	return nil, nil
+}
+
+func TestProcessRows(t *testing.T) {
+	processor, err := NewCitationProcessor(Config{OutputDir: "-"})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	db := &insertRecorder{}
+	insert := "INSERT INTO citations (document_id, doc_cit_urn, urn, citation) VALUES ($1, $2, $3, $4)"
+	processor.Writer = NewSQLWriter(db, insert)
+	rows := &documentRows{rows: [][2]any{
+		{17, `<p><bibl>Soph. OT 150</bibl> <bibl>Xyz. Abc. 12</bibl></p>`},
+		{18, `<p><bibl>Soph. OT 150</p>`},
+		{"19", `<p><bibl>Soph. El. 1126</bibl></p>`},
+	}}
+	if err := processor.ProcessRows(rows); err != nil {
+		t.Fatal(err)
+	}
+	if err := processor.Writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the malformed row is skipped, the others written against their ids
+	if db.query != insert || len(db.rows) != 3 {
+		t.Fatalf("Expected 3 citations inserted, got %d: %v", len(db.rows), db.rows)
+	}
+	want := [][3]any{
+		{"17", "17:citations-1", "urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:150"},
+		{"17", "17:citations-2", ""},
+		{"19", "19:citations-1", "urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:1126"},
+	}
+	for i, args := range db.rows {
+		if args[0] != want[i][0] || args[1] != want[i][1] || args[2] != want[i][2] {
+			t.Errorf("Row %d: expected %v, got %v", i, want[i], args[:3])
+		}
+		var citation Citation
+		if err := json.Unmarshal([]byte(args[3].(string)), &citation); err != nil || citation.DocumentID != want[i][0] {
+			t.Errorf("Row %d: expected the citation as JSON with its document id, got %v (%v)", i, args[3], err)
+		}
+	}
+
+	// documents processed otherwise have no document id
+	citations, err := processor.ExtractFromString(`<p><bibl>Soph. OT 150</bibl></p>`, "notes.xml")
+	if err != nil || len(citations) != 1 || citations[0].DocumentID != "" {
+		t.Errorf("Expected a citation without a document id, got %+v (%v)", citations, err)
+	}
+}
+
+func TestWorkTitleStopwords(t *testing.T) {
+	contains := func(abbreviations []string, abbreviation string) bool {
+		for _, a := range abbreviations {
+			if a == abbreviation {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Latin "de" counts in the initials, English function words do not
+	testCases := []struct {
+		title    string
+		expected []string
+		excluded []string
+	}{
+		{title: "de amicitia", expected: []string{"da", "d.a."}, excluded: []string{"a", "a."}},
+		{title: "on the crown", expected: []string{"c", "c."}},
+		{title: "de finibus bonorum et malorum", expected: []string{"dfbm", "d.f.b.m."}, excluded: []string{"fbm"}},
+	}
+	for _, tc := range testCases {
+		abbreviations := loader.GenerateWorkAbbreviations(tc.title)
+		for _, expected := range tc.expected {
+			if !contains(abbreviations, expected) {
+				t.Errorf("%s: expected %q in %v", tc.title, expected, abbreviations)
+			}
+		}
+		for _, excluded := range tc.excluded {
+			if contains(abbreviations, excluded) {
+				t.Errorf("%s: expected no %q in %v", tc.title, excluded, abbreviations)
+			}
+		}
+	}
+
+	// the data files can change a language's stopwords
+	dataDir := t.TempDir()
+	copyDataFiles(t, dataDir)
+	if err := os.WriteFile(filepath.Join(dataDir, loader.StopwordsFile), []byte(`{"latin": ["de", "et"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data, err := loader.LoadComprehensiveDataDir(dataDir)
+	if err != nil {
+		t.Fatalf("Failed to load data: %v", err)
+	}
+	if abbreviations := data.WorkAbbreviations("de amicitia"); !contains(abbreviations, "a.") {
+		t.Errorf("Expected %q with \"de\" a Latin stopword, got %v", "a.", abbreviations)
+	}
+	if abbreviations := data.WorkAbbreviations("on the crown"); !contains(abbreviations, "c.") {
+		t.Errorf("Expected the default English stopwords to remain, got %v", abbreviations)
+	}
+}
+
+func TestResolutionCache(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "a.xml")
+	if err := os.WriteFile(input, []byte(`<p><bibl>Soph. OT 1</bibl> <bibl>Hom. Il. 1.1</bibl> <bibl>Soph. OT 1</bibl></p>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cachePath := filepath.Join(dir, "cache.json")
+
+	run := func() (*CitationProcessor, string) {
+		outputDir := filepath.Join(dir, "out")
+		processor, err := NewCitationProcessor(Config{
+			InputDir:       input,
+			OutputDir:      outputDir,
+			ResolvedFile:   "resolved.jsonl",
+			UnresolvedFile: "unresolved.jsonl",
+			UseCitTags:     true,
+			CacheFile:      cachePath,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		if err := processor.ProcessAllXMLFiles(); err != nil {
+			t.Fatal(err)
+		}
+		output, err := os.ReadFile(filepath.Join(outputDir, "resolved.jsonl"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return processor, string(output)
+	}
+
+	first, output := run()
+	if first.cache.misses != 2 || first.cache.hits != 1 {
+		t.Errorf("Expected 2 misses and 1 hit on the first run, got %d and %d", first.cache.misses, first.cache.hits)
+	}
+	second, cachedOutput := run()
+	if second.cache.misses != 0 || second.cache.hits != 3 {
+		t.Errorf("Expected 3 hits on the second run, got %d misses and %d hits", second.cache.misses, second.cache.hits)
+	}
+	if cachedOutput != output {
+		t.Errorf("Expected identical output from the cache, got:\n%s\nand:\n%s", output, cachedOutput)
+	}
+
+	// a cache made with other data or options is discarded
+	content, err := os.ReadFile(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved cacheFile
+	if err := json.Unmarshal(content, &saved); err != nil {
+		t.Fatal(err)
+	}
+	saved.Fingerprint = "stale"
+	if content, err = json.Marshal(saved); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cachePath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if third, _ := run(); third.cache.hits != 1 {
+		t.Errorf("Expected a stale cache to be discarded, got %d hits", third.cache.hits)
+	}
+}
+
+func TestExcludedRegions(t *testing.T) {
+	xmlContent := `<TEI><teiHeader><fileDesc><sourceDesc><bibl>Soph. OT 1</bibl></sourceDesc></fileDesc></teiHeader>` +
+		`<text><body><p>Cf. <!-- <bibl>Soph. OT 2</bibl> --> <bibl>Soph. OT 3</bibl>` +
+		`<!-- <ref target="urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:4"/> --></p>` +
+		`<note><bibl>Soph. OT 5</bibl></note></body></text></TEI>`
+
+	tests := []struct {
+		exclude  string
+		expected string
+	}{
+		{"", "Soph. OT 3, Soph. OT 5"},
+		{"none", "Soph. OT 1, Soph. OT 3, Soph. OT 5"},
+		{"teiHeader, note", "Soph. OT 3"},
+	}
+	for _, test := range tests {
+		for _, useCitTags := range []bool{true, false} {
+			processor, err := NewCitationProcessor(Config{UseCitTags: useCitTags, ExcludeElements: test.exclude})
+			if err != nil {
+				t.Fatalf("Failed to create citation processor: %v", err)
+			}
+			var bibls []string
+			for _, citation := range processor.ExtractCitations(xmlContent, "test.xml") {
+				bibls = append(bibls, citation.Bibl)
+			}
+			if got := strings.Join(bibls, ", "); got != test.expected {
+				t.Errorf("Exclude %q, UseCitTags %v: expected %q, got %q", test.exclude, useCitTags, test.expected, got)
+			}
+		}
+	}
+}
+
+func TestExemplars(t *testing.T) {
+	dataDir := t.TempDir()
+	copyDataFiles(t, dataDir)
+	exemplars := `{"urn:cts:greekLit:tlg0059": "perseus-grc1", "urn:cts:greekLit:tlg0059.tlg001": "perseus-grc3"}`
+	if err := os.WriteFile(filepath.Join(dataDir, loader.ExemplarsFile), []byte(exemplars), 0644); err != nil {
+		t.Fatal(err)
+	}
+	urnResolver, err := resolver.NewURNResolverFromDir(dataDir)
+	if err != nil {
+		t.Fatalf("Failed to create resolver: %v", err)
+	}
+
+	// a work's exemplar comes before its author's, and either before the
+	// namespace's
+	testCases := map[string]string{
+		"Plat. Crito 50a":    "urn:cts:greekLit:tlg0059.tlg003.perseus-grc1:50a",
+		"Plat. Euthyph. 2a":  "urn:cts:greekLit:tlg0059.tlg001.perseus-grc3:2a",
+		"Soph. OT 1":         "urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:1",
+		"tlg0059.tlg003 50a": "urn:cts:greekLit:tlg0059.tlg003.perseus-grc1:50a",
+	}
+	for ref, expected := range testCases {
+		if res := urnResolver.Resolve(ref, "", ""); res.URN != expected {
+			t.Errorf("%s: expected %s, got %s", ref, expected, res.URN)
+		}
+	}
+}
+
+func TestFindWorks(t *testing.T) {
+	urnResolver, err := resolver.NewURNResolver()
+	if err != nil {
+		t.Fatalf("Failed to create resolver: %v", err)
+	}
+
+	// exact titles first, then titles starting with or containing the query,
+	// then similar ones, ignoring case and diacritics
+	testCases := []struct {
+		query    string
+		expected []string // the first matches, in order
+	}{
+		{"Oedipus", []string{"urn:cts:greekLit:tlg0011.tlg004", "urn:cts:greekLit:tlg0011.tlg007"}},
+		{"oedipus col", []string{"urn:cts:greekLit:tlg0011.tlg007"}},
+		{"colonus", []string{"urn:cts:greekLit:tlg0011.tlg007"}},
+		{"Odysey", []string{"urn:cts:greekLit:tlg0012.tlg002"}},
+		{"Antígone", []string{"urn:cts:greekLit:tlg0011.tlg002"}},
+	}
+	for _, tc := range testCases {
+		matches := urnResolver.FindWorks(tc.query)
+		if len(matches) < len(tc.expected) {
+			t.Fatalf("%q: expected at least %d matches, got %v", tc.query, len(tc.expected), matches)
+		}
+		for i, urn := range tc.expected {
+			if matches[i].URN != urn {
+				t.Errorf("%q: expected %s at %d, got %v", tc.query, urn, i, matches[:len(tc.expected)])
+			}
+		}
+	}
+
+	if matches := urnResolver.FindWorks("  "); len(matches) != 0 {
+		t.Errorf("Expected no matches for an empty query, got %v", matches)
+	}
+	matches := urnResolver.FindWorks("antigone")
+	if len(matches) == 0 || matches[0] != (resolver.WorkMatch{
+		Author: "sophocles", Title: "antigone", URN: "urn:cts:greekLit:tlg0011.tlg002", Matched: "antigone", Score: 1,
+	}) {
+		t.Errorf("Unexpected best match for antigone: %v", matches)
+	}
+}
+
+func TestBench(t *testing.T) {
+	result, err := runBenchmark(Config{UseCitTags: true}, 3, len(benchCitations))
+	if err != nil {
+		t.Fatalf("benchmark failed: %v", err)
+	}
+	// every citation form is extracted from every file
+	if result.Files != 3 || result.Citations < 3*len(benchCitations) {
+		t.Errorf("Expected at least %d citations from 3 files, got %+v", 3*len(benchCitations), result)
+	}
+	if result.Allocs == 0 || result.Elapsed <= 0 {
+		t.Errorf("Expected the processing to be measured, got %+v", result)
+	}
+
+	var out bytes.Buffer
+	result.report(&out)
+	for _, want := range []string{"files/sec:", "citations/sec:", "allocs/file:"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in report:\n%s", want, out.String())
+		}
+	}
+}
+
+// BenchmarkSyntheticCorpus extracts and resolves the citations of one file
+// of the bench subcommand's corpus per iteration
+func BenchmarkSyntheticCorpus(b *testing.B) {
+	processor, err := NewCitationProcessor(Config{UseCitTags: true})
+	if err != nil {
+		b.Fatalf("Failed to create citation processor: %v", err)
+	}
+	document := benchDocument(0, 40)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := processor.ExtractFromString(document, "synthetic.xml"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkLargeCommentary extracts and resolves the citations of the
+// largest commentary in testdata, about 1 MB of XML
+func BenchmarkLargeCommentary(b *testing.B) {
+	content, err := os.ReadFile(filepath.Join(findTestDataDir(), "xml", "viaf2603144.viaf001.perseus-eng1.xml"))
+	if err != nil {
+		b.Fatalf("Failed to read commentary: %v", err)
+	}
+	processor, err := NewCitationProcessor(Config{UseCitTags: true})
+	if err != nil {
+		b.Fatalf("Failed to create citation processor: %v", err)
+	}
+	b.SetBytes(int64(len(content)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := processor.ExtractFromString(string(content), "viaf2603144.viaf001.perseus-eng1.xml"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkResolve resolves references taking each path through the resolver
+func BenchmarkResolve(b *testing.B) {
+	processor, err := NewCitationProcessor(Config{})
+	if err != nil {
+		b.Fatalf("Failed to create citation processor: %v", err)
+	}
+	refs := []string{"soph. ot 264", "hom. il. 1.1", "thuc. ii. 40", "plat. rep. 338d", "cic. amic. 12", "verg. a. 1.1", "xyz. abc. 12", "tlg0011.tlg004:264"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, ref := range refs {
+			processor.Resolver.Resolve(ref, "", "test.xml")
+		}
+	}
+}
+
+// BenchmarkGetRef picks the reference of bibl elements, which looks for a
+// recognized author at the start of each candidate
+func BenchmarkGetRef(b *testing.B) {
+	processor, err := NewCitationProcessor(Config{})
+	if err != nil {
+		b.Fatalf("Failed to create citation processor: %v", err)
+	}
+	pairs := [][2]string{
+		{"Soph. OT 264", "soph. ot 264"},
+		{"", "ap. rhod. 1.1"},
+		{"hom. il. 1.1", "Il. 1.1"},
+		{"", "xyz. abc. 12"},
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, pair := range pairs {
+			processor.Resolver.GetRef(pair[0], pair[1])
+		}
+	}
+}
+
+// BenchmarkFuzzyAuthor resolves references whose authors are misspelled,
+// each compared against every author name and abbreviation
+func BenchmarkFuzzyAuthor(b *testing.B) {
+	processor, err := NewCitationProcessor(Config{})
+	if err != nil {
+		b.Fatalf("Failed to create citation processor: %v", err)
+	}
+	processor.Resolver.FuzzyAuthors = 0.9
+	refs := []string{"sophoc. ot 264", "thucyd. 2.40", "herodt. 1.1"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, ref := range refs {
+			processor.Resolver.Resolve(ref, "", "test.xml")
+		}
+	}
+}
+
+// BenchmarkLoadData loads the data packs and builds their indexes
+func BenchmarkLoadData(b *testing.B) {
+	dataDir := loader.FindDataDir()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := loader.LoadComprehensiveDataDir(dataDir); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestProvenance(t *testing.T) {
+	processor, err := NewCitationProcessor(Config{UseCitTags: true, Provenance: true})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	citations := processor.ExtractCitations(`<p><bibl>Plin. Nat. 2.3</bibl> <bibl>Thuc. 2.40</bibl></p>`, "test.xml")
+	if len(citations) != 2 {
+		t.Fatalf("Expected 2 citations, got %d", len(citations))
+	}
+	expected := [][]string{
+		{
+			`latin_data.json: AuthAbb["plin."]`,
+			`latin_data.json: AuthURNs["pliny_senior"]`,
+			`latin_data.json: WorkURNs["pliny_senior"]["natural history"] (abbreviated "nat.")`,
+		},
+		{
+			`greek_data.json: AuthAbb["thuc."]`,
+			`greek_data.json: AuthURNs["thucydides"]`,
+			`greek_data.json: SingleWorkAuthors["thucydides"]`,
+		},
+	}
+	for i, citation := range citations {
+		var got []string
+		for _, entry := range citation.Provenance {
+			got = append(got, entry.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(expected[i], "\n") {
+			t.Errorf("%s: expected provenance %q, got %q", citation.Bibl, expected[i], got)
+		}
+	}
+
+	// an alias is traced to work_aliases.json
+	dataDir := t.TempDir()
+	copyDataFiles(t, dataDir)
+	if err := os.WriteFile(filepath.Join(dataDir, loader.WorkAliasesFile), []byte(`{"sophocles": {"tyrannos": "tlg004"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	urnResolver, err := resolver.NewURNResolverFromDir(dataDir)
+	if err != nil {
+		t.Fatalf("Failed to create resolver: %v", err)
+	}
+	urnResolver.RecordProvenance = true
+	res := urnResolver.Resolve("Soph. tyrannos 3", "", "")
+	want := loader.DataEntry{File: loader.WorkAliasesFile, Entry: `["sophocles"]["tyrannos"]`}
+	if len(res.Provenance) != 3 || res.Provenance[2] != want {
+		t.Errorf("Expected %v last, got %v", want, res.Provenance)
+	}
+
+	// without -provenance, nothing is recorded
+	urnResolver.RecordProvenance = false
+	if res := urnResolver.Resolve("Soph. OT 1", "", ""); res.Provenance != nil {
+		t.Errorf("Expected no provenance, got %v", res.Provenance)
+	}
+}
+
+// TestAbbreviationLayer checks that the OCD and LSJ sigla of
+// scholarly_abbreviations.json resolve, without overriding the packs'
+// abbreviations or the reference read from a bibl, and are traced to the
+// layer
+func TestAbbreviationLayer(t *testing.T) {
+	urnResolver, err := resolver.NewURNResolver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	urnResolver.RecordProvenance = true
+	tests := []struct {
+		ref, urn string
+	}{
+		{"A. Ag. 100", "urn:cts:greekLit:tlg0085.tlg005.perseus-grc2:100"},
+		{"E. Med. 12", "urn:cts:greekLit:tlg0006.tlg003.perseus-grc2:12"},
+		{"X. An. 1.1", "urn:cts:greekLit:tlg0032.tlg006.perseus-grc2:1.1"},
+		{"Arist. Pol. 1253a", "urn:cts:greekLit:tlg0086.tlg035.perseus-grc2:1253a"},
+		{"Th. 2.40", "urn:cts:greekLit:tlg0003.tlg001.perseus-grc2:2.40"},
+		{"Virg. Aen. 1.1", "urn:cts:latinLit:phi0690.phi003.perseus-lat2:1.1"},
+	}
+	for _, tt := range tests {
+		res := urnResolver.Resolve(tt.ref, "", "")
+		if res.URN != tt.urn {
+			t.Errorf("Resolve(%q) = %q, expected %q", tt.ref, res.URN, tt.urn)
+		}
+		if len(res.Provenance) == 0 || res.Provenance[0].File != loader.ScholarlyAbbreviationsFile {
+			t.Errorf("Expected %q traced to %s, got %v", tt.ref, loader.ScholarlyAbbreviationsFile, res.Provenance)
+		}
+	}
+	// "Ar." is Aristophanes, "Arist." Aristotle
+	if res := urnResolver.Resolve("Ar. Lys. 100", "", ""); !strings.HasPrefix(res.URN, "urn:cts:greekLit:tlg0019.") {
+		t.Errorf("Expected Ar. to be Aristophanes, got %q", res.URN)
+	}
+	// the packs' own abbreviations keep their entries
+	res := urnResolver.Resolve("Soph. OT 1", "", "")
+	if len(res.Provenance) == 0 || res.Provenance[0].File != "greek_data.json" {
+		t.Errorf("Expected Soph. traced to greek_data.json, got %v", res.Provenance)
+	}
+	// the layer does not change which of the n attribute and the bibl is
+	// read: an author the packs know wins over one only the layer knows
+	for _, tt := range []struct{ n, bibl, ref string }{
+		{"Call. Ap. 47", "Callim. Apoll. 47", "callim. apoll. 47"},
+		{"Thphr. Char. 30", "Theophr. Char. 30", "theophr. char. 30"},
+		{"Call. Ap. 47", "", "call. ap. 47"},
+	} {
+		if ref := urnResolver.GetRef(tt.n, tt.bibl); ref != tt.ref {
+			t.Errorf("GetRef(%q, %q) = %q, expected %q", tt.n, tt.bibl, ref, tt.ref)
+		}
+	}
+
+	packs := urnResolver.Data.Summary()
+	layer := packs[len(packs)-1]
+	if layer.Pack != "ocd-lsj" || layer.File != loader.ScholarlyAbbreviationsFile || layer.Abbreviations == 0 || layer.Works != 0 {
+		t.Errorf("Expected the layer summarized after the packs, got %+v", layer)
+	}
+}
+
+// TestDisambiguators checks that abbreviations standing for several authors
+// are resolved by their disambiguators, and that registered ones are run
+func TestDisambiguators(t *testing.T) {
+	urnResolver, err := resolver.NewURNResolver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ref, urn string
+	}{
+		{"Xen. Eph. 1.1", "urn:cts:greekLit:tlg0641.tlg001.perseus-grc2:1.1"},
+		{"Xen. An. 1.1", "urn:cts:greekLit:tlg0032.tlg006.perseus-grc2:1.1"},
+		{"Plin. Ep. 1.1", "urn:cts:latinLit:phi1318.phi001.perseus-lat2:1.1"},
+		{"Sen. Contr. 1.1", "urn:cts:latinLit:phi1014.phi001.perseus-lat2:1.1"},
+	}
+	for _, tt := range tests {
+		if urn := urnResolver.GetURN(tt.ref, "", ""); urn != tt.urn {
+			t.Errorf("GetURN(%q) = %q, expected %q", tt.ref, urn, tt.urn)
+		}
+	}
+
+	data := urnResolver.Data
+	if author := data.AuthorFor("_which_dionysius", "orbis descriptio"); author != "dionysius periegetes" {
+		t.Errorf("Expected Dionysius Periegetes for the Orbis Descriptio, got %q", author)
+	}
+	if author := data.AuthorFor("_which_apollonius", "arg."); author != "apollonius, rhodius" {
+		t.Errorf("Expected Apollonius of Rhodes for the Argonautica, got %q", author)
+	}
+	if author := data.AuthorFor("_which_unregistered", "el."); author != "" {
+		t.Errorf("Expected no author from an unregistered disambiguator, got %q", author)
+	}
+	loader.RegisterDisambiguator("tragedian", func(cd *loader.ComprehensiveData, work string) string {
+		if work == "el." {
+			return "sophocles"
+		}
+		return "euripides"
+	})
+	if author := data.AuthorFor("_which_tragedian", "el."); author != "sophocles" {
+		t.Errorf("Expected the registered disambiguator to pick Sophocles, got %q", author)
+	}
+}
+
+// TestRunMetadata checks run.json: written with the schema version, flags,
+// inputs and data checksums, replaced rather than appended to, and removed
+// by a run that does not write it
+func TestRunMetadata(t *testing.T) {
+	inputs := t.TempDir()
+	input := filepath.Join(inputs, "a.xml")
+	if err := os.WriteFile(input, []byte(`<p><bibl>Soph. OT 1</bibl></p>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := t.TempDir()
+	config := Config{
+		InputDir:       input,
+		OutputDir:      output,
+		ResolvedFile:   "resolved.jsonl",
+		UnresolvedFile: "unresolved.jsonl",
+		RunMetadata:    true,
+		RunFlags:       map[string]string{"nocit": "true"},
+	}
+	run := func(config Config) {
+		t.Helper()
+		processor, err := NewCitationProcessor(config)
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		if err := processor.ProcessAllXMLFiles(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run(config)
+	config.Append = true
+	run(config)
+
+	content, err := os.ReadFile(filepath.Join(output, runFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var metadata runMetadata
+	if err := json.Unmarshal(content, &metadata); err != nil {
+		t.Fatalf("Expected run.json replaced by the appending run, not added to: %v", err)
+	}
+	if metadata.SchemaVersion != outputSchemaVersion || metadata.Flags["nocit"] != "true" || metadata.Tool == "" {
+		t.Errorf("Unexpected run metadata %+v", metadata)
+	}
+	if !reflect.DeepEqual(metadata.Inputs, []string{input}) {
+		t.Errorf("Expected the input %s, got %v", input, metadata.Inputs)
+	}
+	if len(metadata.Data) == 0 || !strings.HasPrefix(metadata.Data[0].Checksum, "sha256:") {
+		t.Errorf("Expected the data checksums, got %+v", metadata.Data)
+	}
+	if _, err := time.Parse(time.RFC3339, metadata.Started); err != nil {
+		t.Errorf("Expected the start time in RFC 3339, got %q", metadata.Started)
+	}
+
+	config.Append = false
+	config.RunMetadata = false
+	run(config)
+	if _, err := os.Stat(filepath.Join(output, runFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected the previous run.json removed, got %v", err)
+	}
+
+	config.RunMetadata = true
+	config.OutputDir = stdoutOutput
+	if _, err := NewCitationProcessor(config); err == nil {
+		t.Error("Expected -run-metadata with -output - to be rejected")
+	}
+}
+
+// TestCitationFilters checks that -authors, -urn-prefix and -min-confidence
+// select the citations written
+func TestCitationFilters(t *testing.T) {
+	document := `<p><bibl>Soph. OT 1</bibl> <bibl>Aesch. Ag. 100</bibl> <bibl>Hom. Il. 1.1</bibl>
+		<bibl>Soph. Nonesuch 5</bibl> <bibl>Foo. 12</bibl></p>`
+	extract := func(config Config) []string {
+		t.Helper()
+		config.UseCitTags = true
+		config.WorkFallback = "guess"
+		processor, err := NewCitationProcessor(config)
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		citations, err := processor.ExtractFromString(document, "comm.xml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var bibls []string
+		for _, citation := range citations {
+			bibls = append(bibls, citation.Bibl)
+		}
+		return bibls
+	}
+
+	tests := []struct {
+		name     string
+		config   Config
+		expected []string
+	}{
+		{"authors", Config{Authors: "sophocles, aesch."}, []string{"Soph. OT 1", "Aesch. Ag. 100", "Soph. Nonesuch 5"}},
+		{"urn prefix", Config{URNPrefixes: "urn:cts:greekLit:tlg0012,tlg0085.tlg005"}, []string{"Aesch. Ag. 100", "Hom. Il. 1.1"}},
+		{"text group boundary", Config{URNPrefixes: "tlg001"}, nil},
+		{"min confidence", Config{MinConfidence: 0.5}, []string{"Soph. OT 1", "Aesch. Ag. 100", "Hom. Il. 1.1"}},
+		{"combined", Config{Authors: "soph.", MinConfidence: 0.5}, []string{"Soph. OT 1"}},
+	}
+	for _, tt := range tests {
+		if bibls := extract(tt.config); !reflect.DeepEqual(bibls, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, bibls)
+		}
+	}
+
+	for _, config := range []Config{{Authors: "nobody"}, {MinConfidence: 1.5}} {
+		if _, err := NewCitationProcessor(config); err == nil {
+			t.Errorf("Expected %+v to be rejected", config)
+		}
+	}
+	if confidence := citationConfidence(Citation{URN: "urn:cts:greekLit:tlg0011.tlg004:1", Fallback: true}); confidence != fallbackConfidence {
+		t.Errorf("Expected a guessed work to lower the confidence to %v, got %v", fallbackConfidence, confidence)
+	}
+}
+
+// TestResolveIncludes checks that -resolve-includes processes a text split
+// by XInclude and SYSTEM entities as one document
+func TestResolveIncludes(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.xml": `<?xml version="1.0"?>
+<!DOCTYPE TEI [
+  <!ENTITY book2 SYSTEM "parts/book2.xml">
+]>
+<TEI xmlns:xi="http://www.w3.org/2001/XInclude"><text><body>
+<xi:include href="book1.xml"/>
+&book2;
+<xi:include href="missing.xml"><xi:fallback><p><bibl>Aesch. Ag. 100</bibl></p></xi:fallback></xi:include>
+</body></text></TEI>`,
+		"book1.xml":       `<?xml version="1.0"?><div><p><bibl>Soph. OT 1</bibl></p></div>`,
+		"parts/book2.xml": `<div><p><bibl>Hom. Il. 1.1</bibl> <xi:include href="note.txt" parse="text"/></p></div>`,
+		"parts/note.txt":  `Thuc. 2.65 & more`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expanded, err := resolveIncludes(files["main.xml"], filepath.Join(dir, "main.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Soph. OT 1", "Hom. Il. 1.1", "Aesch. Ag. 100", "Thuc. 2.65 &amp; more"} {
+		if !strings.Contains(expanded, want) {
+			t.Errorf("Expected %q in the expanded document, got %s", want, expanded)
+		}
+	}
+	if strings.Contains(expanded, "xi:include") || strings.Contains(expanded, "&book2;") || strings.Count(expanded, "<?xml") != 1 {
+		t.Errorf("Expected the includes replaced, got %s", expanded)
+	}
+
+	output := t.TempDir()
+	processor, err := NewCitationProcessor(Config{
+		InputDir:        dir,
+		OutputDir:       output,
+		ResolvedFile:    "resolved.jsonl",
+		UnresolvedFile:  "unresolved.jsonl",
+		UseCitTags:      false,
+		ResolveIncludes: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	if err := processor.ProcessAllXMLFiles(); err != nil {
+		t.Fatal(err)
+	}
+	citations, err := loadCitations(filepath.Join(output, "resolved.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(citations) != 3 {
+		t.Fatalf("Expected the 3 citations of main.xml and its parts, got %d: %+v", len(citations), citations)
+	}
+	for _, citation := range citations {
+		if filepath.Base(citation.Filename) != "main.xml" {
+			t.Errorf("Expected book1.xml processed only within main.xml, got a citation of %s", citation.Filename)
+		}
+	}
+
+	cycle := filepath.Join(dir, "cycle.xml")
+	if err := os.WriteFile(cycle, []byte(`<div><xi:include href="cycle.xml"/></div>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resolveIncludes(`<div><xi:include href="cycle.xml"/></div>`, cycle); err == nil {
+		t.Error("Expected a file including itself to be rejected")
+	}
+	// named as "./cycle.xml", the cycle is still caught at once
+	_, err = resolveIncludes(`<div><xi:include href="cycle.xml"/></div>`, dir+string(filepath.Separator)+"."+string(filepath.Separator)+"cycle.xml")
+	if err == nil || strings.Count(err.Error(), "XInclude") != 1 {
+		t.Errorf("Expected the cycle caught at the first include, got %v", err)
+	}
+
+	// any prefix bound to the XInclude namespace
+	expanded, err = resolveIncludes(`<div xmlns:inc="http://www.w3.org/2001/XInclude"><inc:include href="book1.xml"/><foo:include href="book1.xml"/></div>`, filepath.Join(dir, "main.xml"))
+	if err != nil || !strings.Contains(expanded, "Soph. OT 1") || !strings.Contains(expanded, "<foo:include") {
+		t.Errorf("Expected only the inc: include expanded, got %s (%v)", expanded, err)
+	}
+
+	// an xpointer is not supported: the fallback is taken, or it is an error
+	// rather than the whole file included
+	expanded, err = resolveIncludes(`<div><xi:include href="book1.xml" xpointer="b1"><xi:fallback><p>fallback</p></xi:fallback></xi:include></div>`, filepath.Join(dir, "main.xml"))
+	if err != nil || expanded != "<div><p>fallback</p></div>" {
+		t.Errorf("Expected the xpointer's fallback, got %s (%v)", expanded, err)
+	}
+	if _, err := resolveIncludes(`<div><xi:include href="book1.xml" xpointer="b1"/></div>`, filepath.Join(dir, "main.xml")); !errors.Is(err, errXPointer) {
+		t.Errorf("Expected an xpointer without a fallback to be rejected, got %v", err)
+	}
+
+	// an include may not leave the directory of the document
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	_, err = resolveIncludes(`<div><xi:include href="../book1.xml"/></div>`, filepath.Join(dir, "sub", "main.xml"))
+	if err == nil || !strings.Contains(err.Error(), "outside") {
+		t.Errorf("Expected an include outside the input directory to be rejected, got %v", err)
+	}
+}
+
+// TestReproducibleOutput checks that citations are written in document
+// order, whatever the order of the extraction patterns finding them, and that
+// a deterministic run over the test corpus writes byte-identical output
+// whatever the order of its input
+func TestReproducibleOutput(t *testing.T) {
+	processor, err := NewCitationProcessor(Config{UseCitTags: true})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	// the <cit> pattern runs before the standalone <bibl> one
+	citations := processor.ExtractCitations(`<p><bibl>Soph. OT 1</bibl> <cit><quote>x</quote><bibl>Aesch. Ag. 1</bibl></cit> <bibl>Hom. Il. 1.1</bibl></p>`, "order.xml")
+	var refs []string
+	for _, citation := range citations {
+		refs = append(refs, citation.Bibl)
+	}
+	if want := []string{"Soph. OT 1", "Aesch. Ag. 1", "Hom. Il. 1.1"}; !reflect.DeepEqual(refs, want) {
+		t.Errorf("Expected citations in document order %v, got %v", want, refs)
+	}
+	for i, citation := range citations {
+		if want := fmt.Sprintf("%s:citations-%d", processor.docID, i+1); citation.DocCitURN != want {
+			t.Errorf("Expected citation %d numbered %s, got %s", i, want, citation.DocCitURN)
+		}
+	}
+
+	// a bibl given twice is located at each of its places, taking the quote
+	// beside each, not twice at the first
+	citations = processor.ExtractCitations(`<p><bibl>Soph. OT 1</bibl> <quote>alpha</quote></p><p><bibl>Soph. OT 1</bibl> <quote>beta</quote></p>`, "repeated.xml")
+	if len(citations) != 2 {
+		t.Fatalf("Expected 2 citations, got %d", len(citations))
+	}
+	if citations[0].Quote != "alpha" || citations[1].Quote != "beta" {
+		t.Errorf("Expected the quotes alpha and beta, got %q and %q", citations[0].Quote, citations[1].Quote)
+	}
+	if first, second := citations[0].span, citations[1].span; first.start >= second.start {
+		t.Errorf("Expected the repeats at distinct places, got %v and %v", first, second)
+	}
+
+	corpus := filepath.Join(findTestDataDir(), "xml")
+	files, err := filepath.Glob(filepath.Join(corpus, "*.xml"))
+	if err != nil || len(files) < 2 {
+		t.Fatalf("Expected the test corpus in %s, got %v (%v)", corpus, files, err)
+	}
+	reversed := []string{files[0]}
+	for i := len(files) - 1; i >= 0; i-- {
+		reversed = append(reversed, files[i])
+	}
+	var first map[string][]byte
+	for _, input := range []string{corpus, strings.Join(reversed, ",")} {
+		output := t.TempDir()
+		processor, err := NewCitationProcessor(Config{
+			InputDir:       input,
+			OutputDir:      output,
+			ResolvedFile:   "resolved.jsonl",
+			UnresolvedFile: "unresolved.jsonl",
+			UseCitTags:     true,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		if err := processor.ProcessAllXMLFiles(); err != nil {
+			t.Fatal(err)
+		}
+		entries, err := os.ReadDir(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		written := make(map[string][]byte)
+		for _, entry := range entries {
+			if written[entry.Name()], err = os.ReadFile(filepath.Join(output, entry.Name())); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if first == nil {
+			first = written
+			continue
+		}
+		if len(written) != len(first) {
+			t.Fatalf("Expected the same output files, got %d and %d", len(first), len(written))
+		}
+		for name, content := range first {
+			if !bytes.Equal(written[name], content) {
+				t.Errorf("Expected %s byte-identical for input %s", name, input)
+			}
+		}
+	}
+}
+
+// TestTriage checks that the triage subcommand clusters unresolved bibls by
+// pattern, most frequent first, with why they fail and what would fix them
+func TestTriage(t *testing.T) {
+	if got := triagePattern("Schol.  Ar. Eq. 101–3."); got != "schol. ar. eq. N" {
+		t.Errorf("Expected the numbers and range folded, got %q", got)
+	}
+	if got := triagePattern("Plat. Rep. 327a"); got != "plat. rep. N" {
+		t.Errorf("Expected a Stephanus page folded, got %q", got)
+	}
+
+	dir := t.TempDir()
+	var lines []string
+	for i, citation := range []Citation{
+		{Bibl: "Schol. Ar. Eq. 42", Ref: "schol. ar. eq. 42", Filename: "a.xml"},
+		{Bibl: "Schol. Ar. Eq. 101–3", Ref: "schol. ar. eq. 101-3", Filename: "b.xml"},
+		{Bibl: "Schol. Ar. Eq. 42", Ref: "schol. ar. eq. 42", Filename: "b.xml"},
+		{Bibl: "Soph. Nonesuch 5", Ref: "soph. nonesuch 5", Filename: "a.xml"},
+		{Bibl: "Soph. Nonesuch 6", Ref: "soph. nonesuch 6", Filename: "a.xml"},
+		{Bibl: "Soph. OT 1", Ref: "soph. ot 1", Filename: "a.xml"},
+	} {
+		citation.DocCitURN = fmt.Sprintf("a:citations-%d", i+1)
+		line, err := json.Marshal(citation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(line))
+	}
+	if err := os.WriteFile(filepath.Join(dir, "unresolved.jsonl"), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+	if err := runTriage([]string{"-json", dir}); err != nil {
+		t.Fatalf("triage failed: %v", err)
+	}
+	var report triageReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to parse the worklist: %v\n%s", err, out.String())
+	}
+	if report.Unresolved != 6 || len(report.Clusters) != 3 {
+		t.Fatalf("Expected 6 citations in 3 clusters, got %+v", report)
+	}
+	first := report.Clusters[0]
+	if first.Pattern != "schol. ar. eq. N" || first.Count != 3 || first.Files != 2 || first.Reason != string(resolver.ReasonUnknownAuthor) {
+		t.Errorf("Expected the scholia first, got %+v", first)
+	}
+	if !reflect.DeepEqual(first.Examples, []string{"Schol. Ar. Eq. 42", "Schol. Ar. Eq. 101–3"}) {
+		t.Errorf("Expected distinct examples, got %v", first.Examples)
+	}
+	if second := report.Clusters[1]; second.Reason != string(resolver.ReasonUnknownWork) || !strings.Contains(second.Action, `"nonesuch"`) || second.Cumulative != 5.0/6 {
+		t.Errorf("Expected the unknown work of Sophocles second, got %+v", second)
+	}
+	if third := report.Clusters[2]; third.Reason != "resolved" {
+		t.Errorf("Expected Soph. OT 1 to resolve with the current data, got %+v", third)
+	}
+
+	out.Reset()
+	if err := runTriage([]string{"-top", "1", filepath.Join(dir, "unresolved.jsonl")}); err != nil {
+		t.Fatalf("triage failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "1. schol. ar. eq. N") || strings.Contains(out.String(), "2. ") {
+		t.Errorf("Expected only the first cluster with -top 1, got:\n%s", out.String())
+	}
+}
+
+// TestDataInfo checks the data pack summary of the data info subcommand
+func TestDataInfo(t *testing.T) {
+	dataDir := t.TempDir()
+	for _, name := range []string{"greek_data.json", "latin_data.json", "schol_data.json", "other_data.json"} {
+		content, err := os.ReadFile(filepath.Join(loader.FindDataDir(), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name == "latin_data.json" {
+			content = append([]byte(`{"SCHEMA_VERSION": 2,`), bytes.TrimPrefix(bytes.TrimSpace(content), []byte("{"))...)
+		}
+		if err := os.WriteFile(filepath.Join(dataDir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+	if err := runData([]string{"info", "-data", dataDir}); err != nil {
+		t.Fatalf("data info failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("Expected the data directory, a header and 4 packs, got:\n%s", out.String())
+	}
+
+	data, err := loader.LoadComprehensiveDataDir(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packs := data.Summary()
+	if len(packs) != 4 || packs[0].Pack != "greek" || packs[1].File != "latin_data.json" {
+		t.Fatalf("Unexpected packs %+v", packs)
+	}
+	for i, pack := range packs {
+		if pack.Authors == 0 || pack.Works == 0 || pack.Abbreviations == 0 || !strings.HasPrefix(pack.Checksum, "sha256:") {
+			t.Errorf("Expected counts and a checksum for %s, got %+v", pack.Pack, pack)
+		}
+		if !strings.Contains(lines[i+2], pack.Checksum) {
+			t.Errorf("Expected %s's checksum in %q", pack.Pack, lines[i+2])
+		}
+		expected := 1
+		if pack.Pack == "latin" {
+			expected = 2
+		}
+		if pack.SchemaVersion != expected {
+			t.Errorf("Expected schema version %d for %s, got %d", expected, pack.Pack, pack.SchemaVersion)
+		}
+	}
+	// works are counted by URN, not by the abbreviations mapped to them
+	entries := 0
+	for _, works := range data.Greek.WorkURNs {
+		entries += len(works)
+	}
+	if packs[0].Works >= entries {
+		t.Errorf("Expected fewer works than the %d work table entries, got %d", entries, packs[0].Works)
+	}
+}
+
+func TestDataExpandDiff(t *testing.T) {
+	snapshot := filepath.Join(t.TempDir(), "expansion.tsv")
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+
+	if err := runData([]string{"expand", "-snapshot", snapshot, "-update"}); err != nil {
+		t.Fatalf("data expand -update failed: %v", err)
+	}
+	if err := runData([]string{"expand", "-snapshot", snapshot, "-diff"}); err != nil {
+		t.Fatalf("data expand -diff failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "0 added, 0 removed, 0 changed") {
+		t.Errorf("Expected no changes against a fresh snapshot, got:\n%s", out.String())
+	}
+
+	previous := []string{
+		"sophocles\tel.\ttlg005\telectra\t",
+		"sophocles\tant.\ttlg002\tantigone\t",
+		"sophocles\toed.\ttlg004\toedipus tyrannus\t",
+	}
+	current := []string{
+		"sophocles\tant.\ttlg002\tantigone\t",
+		"sophocles\tel.\ttlg005\telectra\ttlg005,tlg009",
+		"sophocles\toed.\ttlg004\toedipus tyrannus\ttlg004,tlg007",
+		"sophocles\toec\ttlg007\toedipus coloneus\t",
+	}
+	out.Reset()
+	writeExpansionDiff(&out, append(previous, "sophocles\taj.\ttlg003\tajax\t"), current)
+	expected := "-sophocles\taj.\ttlg003\tajax\t\n" +
+		"-sophocles\tel.\ttlg005\telectra\t\n+sophocles\tel.\ttlg005\telectra\ttlg005,tlg009\n" +
+		"+sophocles\toec\ttlg007\toedipus coloneus\t\n" +
+		"-sophocles\toed.\ttlg004\toedipus tyrannus\t\n+sophocles\toed.\ttlg004\toedipus tyrannus\ttlg004,tlg007\n" +
+		"\n1 added, 1 removed, 2 changed\n" +
+		"2 new collisions:\n" +
+		"  sophocles\tel.\ttlg005\telectra\ttlg005,tlg009\n" +
+		"  sophocles\toed.\ttlg004\toedipus tyrannus\ttlg004,tlg007\n"
+	if out.String() != expected {
+		t.Errorf("Unexpected diff:\n%s\nwant:\n%s", out.String(), expected)
+	}
+}
+
+func TestCTSURN(t *testing.T) {
+	valid := []string{
+		"urn:cts:greekLit:tlg0011",
+		"urn:cts:greekLit:tlg0011.tlg004",
+		"urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:151",
+		"urn:cts:latinLit:phi0472.phi001.perseus-lat2:64-66",
+		"urn:cts:englishLit:shak.cym.perseus-eng2:iv.2",
+		"urn:cts:greekLit:tlg0012.UNKNOWN:1.1",
+	}
+	for _, s := range valid {
+		urn, err := cts.Parse(s)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", s, err)
+			continue
+		}
+		if urn.String() != s {
+			t.Errorf("Expected %q to format as itself, got %q", s, urn.String())
+		}
+	}
+	urn, _ := cts.Parse("urn:cts:latinLit:phi0472.phi001.perseus-lat2:64-66")
+	if urn.Work != "phi001" || urn.Version != "perseus-lat2" || urn.Passage != (cts.Passage{Start: "64", End: "66"}) {
+		t.Errorf("Unexpected parts %+v", urn)
+	}
+
+	invalid := []string{
+		"urns:cts:latinLit:phi0959",
+		"urn:cts:greekLit",
+		"urn:cts:greekLit:tlg0011:151",
+		"urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:",
+		"urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:1 51",
+		"urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:1-2-3",
+		"urn:cts:greek Lit:tlg0011.tlg004",
+	}
+	for _, s := range invalid {
+		if _, err := cts.Parse(s); !errors.Is(err, cts.ErrInvalid) {
+			t.Errorf("Expected Parse(%q) to fail with ErrInvalid, got %v", s, err)
+		}
+	}
+	if err := (cts.URN{Namespace: "greekLit", TextGroup: "tlg0011", Version: "perseus-grc2"}).Validate(); err == nil {
+		t.Error("Expected a version without a work to be invalid")
+	}
+
+	// a malformed URN pinned by a corrections table is rejected on loading
+	corrections := filepath.Join(t.TempDir(), "corrections.json")
+	if err := os.WriteFile(corrections, []byte(`{"bibl": {"Soph. El. 123": "urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:1 23"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resolver.LoadCorrections(corrections); !errors.Is(err, cts.ErrInvalid) {
+		t.Errorf("Expected the malformed correction to be rejected, got %v", err)
+	}
+}
+
+func TestConcurrentRunsShareOutputDir(t *testing.T) {
+	inputs := t.TempDir()
+	for name, bibl := range map[string]string{"a.xml": "Soph. OT 151", "b.xml": "Soph. El. 123"} {
+		content := `<TEI><text><body><p><bibl>` + bibl + `</bibl></p></body></text></TEI>`
+		if err := os.WriteFile(filepath.Join(inputs, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	output := t.TempDir()
+	if err := os.WriteFile(filepath.Join(output, "resolved.jsonl"), []byte("stale\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var processors []*CitationProcessor
+	for _, name := range []string{"a.xml", "b.xml"} {
+		processor, err := NewCitationProcessor(Config{
+			InputDir:       filepath.Join(inputs, name),
+			OutputDir:      output,
+			ResolvedFile:   "resolved.jsonl",
+			UnresolvedFile: "unresolved.jsonl",
+		})
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		processors = append(processors, processor)
+	}
+	errs := make(chan error, len(processors))
+	for _, processor := range processors {
+		go func(processor *CitationProcessor) { errs <- processor.ProcessAllXMLFiles() }(processor)
+	}
+	for range processors {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// one run's output replaced the other's whole, rather than both being
+	// appended to the same files
+	content, err := os.ReadFile(filepath.Join(output, "resolved.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], `"bibl":"Soph.`) {
+		t.Errorf("Expected one run's single citation, got:\n%s", content)
+	}
+	documents, _ := os.ReadFile(filepath.Join(output, documentsFile))
+	var citation Citation
+	json.Unmarshal([]byte(lines[0]), &citation)
+	if strings.Count(string(documents), "\n") != 1 || !strings.Contains(string(documents), filepath.Base(citation.Filename)) {
+		t.Errorf("Expected documents.jsonl from the same run as resolved.jsonl, got:\n%s", documents)
+	}
+	entries, _ := os.ReadDir(output)
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), workspacePrefix) || entry.Name() == outputLockFile {
+			t.Errorf("Expected %s to be removed", entry.Name())
+		}
+	}
+
+	// a run can't publish while another holds the lock
+	defer func(timeout time.Duration) { lockTimeout = timeout }(lockTimeout)
+	lockTimeout = 200 * time.Millisecond
+	if err := os.WriteFile(filepath.Join(output, outputLockFile), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	processor, err := NewCitationProcessor(Config{
+		InputDir:       filepath.Join(inputs, "a.xml"),
+		OutputDir:      output,
+		ResolvedFile:   "resolved.jsonl",
+		UnresolvedFile: "unresolved.jsonl",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	if err := processor.ProcessAllXMLFiles(); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Errorf("Expected the locked output directory to fail the run, got %v", err)
+	}
+	if after, _ := os.ReadFile(filepath.Join(output, "resolved.jsonl")); !bytes.Equal(after, content) {
+		t.Errorf("Expected the output to be left as it was, got:\n%s", after)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(output, workspacePrefix+"*")); len(matches) != 0 {
+		t.Errorf("Expected the failed run's workspace to be removed, got %v", matches)
+	}
+
+	// but waits for as long as the run holding it touches it
+	defer func(heartbeat time.Duration) { lockHeartbeat = heartbeat }(lockHeartbeat)
+	lockHeartbeat = 20 * time.Millisecond
+	if err := os.Remove(filepath.Join(output, outputLockFile)); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := lockOutputDir(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(3*lockTimeout, unlock)
+	if err := processor.ProcessAllXMLFiles(); err != nil {
+		t.Errorf("Expected the run to wait for the lock, got %v", err)
+	}
+}
+
+func TestResolutionSteps(t *testing.T) {
+	defer func() { resolutionSteps = resolver.ResolverChain{} }()
+	// bare numbers in commentaries on Pindar cite the Olympians
+	RegisterResolutionStep("pindar-olympians", resolver.BeforeBuiltin, resolver.StepFunc(func(ref string, ctx resolver.StepContext) (cts.URN, float64) {
+		if !strings.Contains(ctx.Filename, "pindar") || strings.Trim(ref, "0123456789.") != "" {
+			return cts.URN{}, 0
+		}
+		return cts.URN{Namespace: "greekLit", TextGroup: "tlg0033", Work: "tlg001", Version: "perseus-grc2", Passage: cts.ParsePassage(ref)}, 0.9
+	}))
+	// a fallback for what the heuristics leave unresolved
+	RegisterResolutionStep("schol", resolver.AfterBuiltin, resolver.StepFunc(func(ref string, ctx resolver.StepContext) (cts.URN, float64) {
+		if ctx.Builtin.URN != "" || !strings.HasPrefix(ref, "schol. ") {
+			return cts.URN{}, 0
+		}
+		return cts.URN{Namespace: "greekSchol", TextGroup: "tlg5026", Work: "tlg001", Passage: cts.ParsePassage(strings.TrimPrefix(ref, "schol. "))}, 0.4
+	}))
+	RegisterResolutionStep("broken", resolver.BeforeBuiltin, resolver.StepFunc(func(ref string, ctx resolver.StepContext) (cts.URN, float64) {
+		return cts.URN{Namespace: "greekLit", TextGroup: "tlg0033", Passage: cts.ParsePassage("1")}, 1
+	}))
+
+	processor, err := NewCitationProcessor(Config{})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	xml := `<TEI><text><body><p><bibl>2.5</bibl> <bibl>Soph. OT 151</bibl> <bibl>schol. 1.1</bibl></p></body></text></TEI>`
+	citations, err := processor.ExtractFromString(xml, "pindar-comm.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		urn, step string
+	}{
+		{"urn:cts:greekLit:tlg0033.tlg001.perseus-grc2:2.5", "pindar-olympians"},
+		{"urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:151", ""},
+		{"urn:cts:greekSchol:tlg5026.tlg001:1.1", "schol"},
+	}
+	if len(citations) != len(expected) {
+		t.Fatalf("Expected %d citations, got %+v", len(expected), citations)
+	}
+	for i, want := range expected {
+		if citations[i].URN != want.urn || citations[i].Step != want.step {
+			t.Errorf("Citation %q: expected %s from step %q, got %s from %q", citations[i].Bibl, want.urn, want.step, citations[i].URN, citations[i].Step)
+		}
+	}
+	if citations[0].Confidence != 0.9 {
+		t.Errorf("Expected the step's confidence to be recorded, got %v", citations[0].Confidence)
+	}
+
+	// in another commentary the bare number is left to the heuristics
+	citations, _ = processor.ExtractFromString(xml, "sophocles-comm.xml")
+	if citations[0].Step != "" {
+		t.Errorf("Expected no step to resolve %q outside the Pindar commentary, got %q", citations[0].Bibl, citations[0].Step)
+	}
+}
+
+// lineFormat reads plain-text files of one reference per line
+type lineFormat struct{}
+
+func (lineFormat) Name() string { return "lines" }
+
+func (lineFormat) Detect(filename, content string) bool {
+	return filepath.Ext(filename) == ".txt"
+}
+
+func (lineFormat) Extract(cp *CitationProcessor, set *citationSet, content, filename string) string {
+	cp.beginDocument(content, filename)
+	offset := 0
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if bibl := strings.TrimSpace(line); bibl != "" {
+			citation := cp.createCitationFromParts("", bibl, "", content, filename)
+			citation.span = byteSpan{start: offset, end: offset + len(line)}
+			set.add(citation)
+		}
+		offset += len(line)
+	}
+	return content
+}
+
+func TestInputFormats(t *testing.T) {
+	defer func() { formats = []Format{teiFormat{}} }()
+	RegisterFormat(lineFormat{})
+
+	processor, err := NewCitationProcessor(Config{})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	citations, err := processor.ExtractFromString("Soph. OT 151\nHom. Il. 1.1\n", "notes.txt")
+	if err != nil {
+		t.Fatalf("Expected the plain-text document to be read without an XML check, got %v", err)
+	}
+	expected := []string{"urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:151", "urn:cts:greekLit:tlg0012.tlg001.perseus-grc2:1.1"}
+	if len(citations) != len(expected) {
+		t.Fatalf("Expected %d citations, got %+v", len(expected), citations)
+	}
+	for i, urn := range expected {
+		if citations[i].URN != urn || citations[i].DocCitURN != fmt.Sprintf("notes:citations-%d", i+1) {
+			t.Errorf("Citation %d: expected %s, got %s (%s)", i, urn, citations[i].URN, citations[i].DocCitURN)
+		}
+	}
+
+	// XML is still read as TEI, and checked
+	citations, err = processor.ExtractFromString(`<p><bibl>Soph. OT 151</bibl></p>`, "comm.xml")
+	if err != nil || len(citations) != 1 {
+		t.Errorf("Expected the TEI document's citation, got %+v, %v", citations, err)
+	}
+	if _, err := processor.ExtractFromString(`<p><bibl>Soph. OT 151</p>`, "comm.xml"); err == nil {
+		t.Error("Expected malformed XML to be rejected")
+	}
+
+	// -input-format overrides detection
+	processor, err = NewCitationProcessor(Config{InputFormat: "tei"})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	if citations, _ := processor.ExtractFromString(`<p><bibl>Soph. OT 151</bibl></p>`, "notes.txt"); len(citations) != 1 {
+		t.Errorf("Expected the document to be read as TEI, got %+v", citations)
+	}
+	if _, err := NewCitationProcessor(Config{InputFormat: "jats"}); err == nil {
+		t.Error("Expected an unknown input format to be rejected")
+	}
+}
+
+// dandaQuotes normalizes Sanskrit quotations, writing the danda as a full stop
+type dandaQuotes struct{}
+
+func (dandaQuotes) Normalize(quote string) string {
+	return strings.ReplaceAll(resolver.NormalizeQuote(quote), "।", ".")
+}
+
+func (dandaQuotes) Tokenize(quote string) []string {
+	return strings.Fields(strings.ReplaceAll(quote, ".", " "))
+}
+
+func TestQuoteNormalizers(t *testing.T) {
+	defer func() { quoteNormalizers = resolver.NewQuoteNormalizers() }()
+	RegisterQuoteNormalizer("san", unicode.Devanagari, dandaQuotes{})
+
+	processor, err := NewCitationProcessor(Config{})
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	// the script tells the language of a quotation without xml:lang
+	xml := `<TEI><text><body><p><bibl>Soph. OT 151</bibl> <quote>धर्मक्षेत्रे कुरुक्षेत्रे।</quote></p></body></text></TEI>`
+	citations, err := processor.ExtractFromString(xml, "test.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(citations) != 1 || citations[0].Quote != "धर्मक्षेत्रे कुरुक्षेत्रे." {
+		t.Fatalf("Expected the Sanskrit normalizer's quote, got %+v", citations)
+	}
+
+	for _, test := range []struct {
+		lang, quote string
+		tokens      []string
+	}{
+		{"grc", "Δήμητρ᾽ ἠΰκομον, σεμνὴν θεάν", []string{"δήμητρ’", "ἠΰκομον", "σεμνὴν", "θεάν"}},
+		{"", "Vivamus, mea Lesbia", []string{"uiuamus", "mea", "lesbia"}},
+		{"lat", "Iam ver egelidos refert tepores", []string{"iam", "uer", "egelidos", "refert", "tepores"}},
+		{"san-Latn", "dharmakṣetre kurukṣetre", []string{"dharmakṣetre", "kurukṣetre"}},
+		{"", "١٢ ٣", []string{"١٢", "٣"}},
+	} {
+		if tokens := quoteNormalizers.For(test.lang, test.quote).Tokenize(test.quote); !reflect.DeepEqual(tokens, test.tokens) {
+			t.Errorf("Tokenize(%q) in %q: expected %q, got %q", test.quote, test.lang, test.tokens, tokens)
+		}
+	}
+
+	// quotes differing in punctuation and case are one in citations_unique.jsonl
+	if quoteHash("Integer vitae, scelerisque purus") != quoteHash("integer vitae scelerisque\n purus.") {
+		t.Error("Expected quotes differing in punctuation and case to hash alike")
+	}
+}
+
+func TestAppendContinuesCitationNumbers(t *testing.T) {
+	inputs := t.TempDir()
+	content := `<TEI><text><body><p><bibl>Soph. OT 151</bibl> <bibl>Soph. El. 123</bibl></p></body></text></TEI>`
+	if err := os.WriteFile(filepath.Join(inputs, "comm.xml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := t.TempDir()
+	run := func(appendOutput bool) {
+		processor, err := NewCitationProcessor(Config{
+			InputDir:       filepath.Join(inputs, "comm.xml"),
+			OutputDir:      output,
+			ResolvedFile:   "resolved.jsonl",
+			UnresolvedFile: "unresolved.jsonl",
+			Append:         appendOutput,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		if err := processor.ProcessAllXMLFiles(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	docCitURNs := func() []string {
+		var ids []string
+		citations, err := loadCitations(filepath.Join(output, "resolved.jsonl"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, citation := range citations {
+			ids = append(ids, citation.DocCitURN)
+		}
+		return ids
+	}
+
+	run(false)
+	run(true)
+	expected := []string{"comm:citations-1", "comm:citations-2", "comm:citations-3", "comm:citations-4"}
+	if ids := docCitURNs(); !reflect.DeepEqual(ids, expected) {
+		t.Errorf("Expected the appended run to continue the numbering, got %v", ids)
+	}
+
+	// without the manifest, the numbers are read from the citations
+	if err := os.Remove(filepath.Join(output, documentsFile)); err != nil {
+		t.Fatal(err)
+	}
+	run(true)
+	if ids := docCitURNs(); len(ids) != 6 || ids[5] != "comm:citations-6" {
+		t.Errorf("Expected numbering to continue from the citations, got %v", ids)
+	}
+
+	// appending runs at once take turns, each continuing from the last
+	// published, rather than all from the same number
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run(true)
+		}()
+	}
+	wg.Wait()
+	ids := docCitURNs()
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if seen[id] {
+			t.Errorf("Expected no repeated doc_cit_urn, got %s twice in %v", id, ids)
+		}
+		seen[id] = true
+	}
+	if len(ids) != 14 {
+		t.Errorf("Expected each appending run's citations, got %v", ids)
+	}
+
+	// a run without -append starts afresh
+	run(false)
+	if ids := docCitURNs(); !reflect.DeepEqual(ids, expected[:2]) {
+		t.Errorf("Expected the output to be replaced, got %v", ids)
+	}
+
+	if _, err := NewCitationProcessor(Config{OutputDir: output, Format: "bibtex", Append: true}); err == nil {
+		t.Error("Expected -append with bibtex output to be rejected")
+	}
+}
+
+// TestGzipTimestampOutput checks that -gzip-output compresses the output files
+// and -timestamp-output keeps each run's files apart
+func TestGzipTimestampOutput(t *testing.T) {
+	inputs := t.TempDir()
+	content := `<TEI><text><body><p><bibl>Soph. OT 151</bibl> <bibl>Soph. El. 123</bibl></p></body></text></TEI>`
+	if err := os.WriteFile(filepath.Join(inputs, "comm.xml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := t.TempDir()
+	config := Config{
+		InputDir:        filepath.Join(inputs, "comm.xml"),
+		OutputDir:       output,
+		ResolvedFile:    "resolved.jsonl",
+		UnresolvedFile:  "unresolved.jsonl",
+		TimestampOutput: true,
+		IO:              IOOptions{Gzip: true},
+	}
+	processor, err := NewCitationProcessor(config)
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	processor.startedAt = time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	if err := processor.ProcessAllXMLFiles(); err != nil {
+		t.Fatal(err)
+	}
+	processor, err = NewCitationProcessor(config)
+	if err != nil {
+		t.Fatalf("Failed to create citation processor: %v", err)
+	}
+	processor.startedAt = time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC)
+	if err := processor.ProcessAllXMLFiles(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"resolved-20240131T120000Z.jsonl.gz", "resolved-20240201T120000Z.jsonl.gz"} {
+		var ids []string
+		err := scanJSONLines(filepath.Join(output, name), func(line []byte) error {
+			var citation Citation
+			if err := json.Unmarshal(line, &citation); err != nil {
+				return err
+			}
+			ids = append(ids, citation.DocCitURN)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Expected %s to be readable: %v", name, err)
+		}
+		if expected := []string{"comm:citations-1", "comm:citations-2"}; !reflect.DeepEqual(ids, expected) {
+			t.Errorf("Expected %v in %s, got %v", expected, name, ids)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(output, "documents-20240131T120000Z.jsonl.gz")); err != nil {
+		t.Errorf("Expected the first run's manifest to be kept: %v", err)
+	}
+
+	// the prior reads compressed output as well
+	prior := newCitationPrior()
+	if err := prior.load(output); err != nil {
+		t.Errorf("Expected the prior to load compressed output: %v", err)
+	}
+
+	config.Append = true
+	if _, err := NewCitationProcessor(config); err == nil {
+		t.Error("Expected -append with -timestamp-output to be rejected")
+	}
+	if _, err := NewCitationProcessor(Config{OutputDir: output, Format: "parquet", IO: IOOptions{Gzip: true}}); err == nil {
+		t.Error("Expected -gzip-output with parquet output to be rejected")
+	}
+}
+
+func TestMemoryLimitSpill(t *testing.T) {
+	inputs := t.TempDir()
+	var body strings.Builder
+	for i := 1; i <= 300; i++ {
+		fmt.Fprintf(&body, `<cit><quote>line %d</quote><bibl n="Soph. OT %d">OT %d</bibl></cit> `, i, i, i)
+		// found again as a standalone bibl with an n attribute, and dropped
+		fmt.Fprintf(&body, `<bibl n="Soph. El. %d">El. %d</bibl> `, i, i)
+	}
+	content := `<TEI><text><body><p>` + body.String() + `</p></body></text></TEI>`
+	if err := os.WriteFile(filepath.Join(inputs, "big.xml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	spillDir := t.TempDir()
+	run := func(memoryLimit int64) (resolved, documents []byte) {
+		output := t.TempDir()
+		processor, err := NewCitationProcessor(Config{
+			InputDir:       filepath.Join(inputs, "big.xml"),
+			OutputDir:      output,
+			ResolvedFile:   "resolved.jsonl",
+			UnresolvedFile: "unresolved.jsonl",
+			UseCitTags:     true,
+			MemoryLimit:    memoryLimit,
+			SpillDir:       spillDir,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create citation processor: %v", err)
+		}
+		if err := processor.ProcessAllXMLFiles(); err != nil {
+			t.Fatal(err)
+		}
+		if resolved, err = os.ReadFile(filepath.Join(output, "resolved.jsonl")); err != nil {
+			t.Fatal(err)
+		}
+		if documents, err = os.ReadFile(filepath.Join(output, documentsFile)); err != nil {
+			t.Fatal(err)
+		}
+		return resolved, documents
+	}
+
+	inMemory, inMemoryDocuments := run(0)
+	if lines := strings.Count(string(inMemory), "\n"); lines < 600 {
+		t.Fatalf("Expected at least 600 resolved citations, got %d", lines)
+	}
+	// a limit of 100 KB spills several times over the document
+	spilled, spilledDocuments := run(100 << 10)
+	if !bytes.Equal(spilled, inMemory) {
+		t.Error("Expected the same citations, in the same order, when spilling to disk")
+	}
+	if !bytes.Equal(spilledDocuments, inMemoryDocuments) {
+		t.Errorf("Expected the same document record when spilling to disk, got %s", spilledDocuments)
+	}
+	if entries, _ := os.ReadDir(spillDir); len(entries) != 0 {
+		t.Errorf("Expected the spill file to be removed, found %d files", len(entries))
+	}
+
+	if _, err := NewCitationProcessor(Config{MemoryLimit: -1}); err == nil {
+		t.Error("Expected a negative memory limit to be rejected")
+	}
+}
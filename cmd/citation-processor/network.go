@@ -0,0 +1,23 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	"perseus_citation_linker/pkg/httpclient"
+)
+
+// addHTTPFlags defines the flags of a subcommand that makes requests over
+// the network, filling in the options of its httpclient.Client as they are
+// parsed, so that every such subcommand is configured alike
+func addHTTPFlags(fs *flag.FlagSet) *httpclient.Options {
+	opts := &httpclient.Options{}
+	fs.DurationVar(&opts.Timeout, "timeout", httpclient.DefaultTimeout, "Timeout for each request")
+	fs.Float64Var(&opts.Rate, "rate", 5, "Requests per second at most (0: unlimited)")
+	fs.IntVar(&opts.Retries, "retries", 3, "Retries of a request failing with a network error, 429 or 5xx, with exponential backoff")
+	fs.DurationVar(&opts.Backoff, "backoff", httpclient.DefaultBackoff, "Wait before the first retry, doubled before each further one")
+	fs.StringVar(&opts.CacheDir, "http-cache", "", "Directory caching responses on disk, reused by later runs (default: no cache)")
+	fs.DurationVar(&opts.CacheTTL, "http-cache-ttl", 7*24*time.Hour, "Age after which a cached response is requested again (0: never)")
+	fs.BoolVar(&opts.Offline, "offline", false, "Make no requests, answering from -http-cache only")
+	return opts
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultWriteBuffer is the per-file write buffer used when IOOptions.BufferSize is not set
+const defaultWriteBuffer = 64 * 1024
+
+// IOOptions controls how output files are written, for runs on shared or
+// network filesystems
+type IOOptions struct {
+	BufferSize   int           // bytes buffered per output file between writes
+	Fsync        string        // "" or "none", "file" (after each input file) or "interval"
+	SyncInterval time.Duration // minimum time between fsyncs with Fsync "interval"
+	MaxRate      int64         // maximum bytes per second over all output, 0 for no limit
+	Gzip         bool          // compress each output file with gzip, adding ".gz" to its name
+}
+
+// gzipExtension ends the names of output files compressed with
+// IOOptions.Gzip
+const gzipExtension = ".gz"
+
+// timestampLayout is the time a run started, in UTC, as put in its output
+// file names with Config.TimestampOutput
+const timestampLayout = "20060102T150405Z"
+
+// outputName returns the name an output file is written under: with
+// Config.TimestampOutput the time the run started goes before the
+// extension, as in "resolved-20240131T120000Z.jsonl", so that a run never
+// replaces an earlier one's output, and with IOOptions.Gzip ".gz" is added
+func (cp *CitationProcessor) outputName(name string) string {
+	name = cp.stampedName(name)
+	if cp.Config.IO.Gzip {
+		name += gzipExtension
+	}
+	return name
+}
+
+// stampedName returns a file name with the time the run started before its
+// extension, with Config.TimestampOutput
+func (cp *CitationProcessor) stampedName(name string) string {
+	if !cp.Config.TimestampOutput {
+		return name
+	}
+	return withStamp(name, "-"+cp.startedAt.UTC().Format(timestampLayout))
+}
+
+// withStamp returns a file name with a run's stamp, such as
+// "-20240131T120000Z", before its extension
+func withStamp(name, stamp string) string {
+	if dot := strings.Index(name, "."); dot >= 0 {
+		return name[:dot] + stamp + name[dot:]
+	}
+	return name + stamp
+}
+
+// outputVariants returns the path of an output file and of its compressed
+// counterpart, either of which a previous run may have written
+func outputVariants(path string) []string {
+	path = strings.TrimSuffix(path, gzipExtension)
+	return []string{path, path + gzipExtension}
+}
+
+// openJSONL opens a JSONL file for reading, decompressing it if its name
+// ends in ".gz"
+func openJSONL(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil || filepath.Ext(path) != gzipExtension {
+		return file, err
+	}
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return gzipFile{Reader: reader, file: file}, nil
+}
+
+// gzipFile closes a gzip reader together with the file under it
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (f gzipFile) Close() error {
+	err := f.Reader.Close()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// validate checks the fsync policy and limits
+func (o IOOptions) validate() error {
+	switch o.Fsync {
+	case "", "none", "file":
+	case "interval":
+		if o.SyncInterval <= 0 {
+			return fmt.Errorf("-fsync interval needs a positive -fsync-interval")
+		}
+	default:
+		return fmt.Errorf("unknown fsync policy %q (want none, file or interval)", o.Fsync)
+	}
+	if o.BufferSize < 0 || o.MaxRate < 0 {
+		return fmt.Errorf("write buffer and rate must not be negative")
+	}
+	return nil
+}
+
+// rateLimiter spreads writes out so that on average no more than rate bytes
+// per second are written
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    int64
+	start   time.Time
+	written int64
+	sleep   func(time.Duration) // time.Sleep, replaceable in tests
+}
+
+func newRateLimiter(rate int64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{rate: rate, start: time.Now(), sleep: time.Sleep}
+}
+
+// wait records n bytes as written and blocks until the average rate since
+// the first write is back under the limit
+func (l *rateLimiter) wait(n int) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.written += int64(n)
+	due := time.Duration(float64(l.written) / float64(l.rate) * float64(time.Second))
+	if elapsed := time.Since(l.start); due > elapsed {
+		l.sleep(due - elapsed)
+	}
+}
+
+// limitedFile passes writes through the rate limiter
+type limitedFile struct {
+	file    *os.File
+	limiter *rateLimiter
+}
+
+func (f limitedFile) Write(p []byte) (int, error) {
+	f.limiter.wait(len(p))
+	return f.file.Write(p)
+}
+
+// outputFile is an output file written through a buffer and the shared rate
+// limiter, synced according to the fsync policy, and compressed with
+// IOOptions.Gzip. Appending to a compressed file adds a gzip member, which
+// gzip readers take as part of the same stream.
+type outputFile struct {
+	file     *os.File
+	buffer   *bufio.Writer
+	gzip     *gzip.Writer // nil unless compressed
+	opts     IOOptions
+	lastSync time.Time
+}
+
+func openOutputFile(path string, flag int, opts IOOptions, limiter *rateLimiter) (*outputFile, error) {
+	file, err := os.OpenFile(path, flag|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	size := opts.BufferSize
+	if size == 0 {
+		size = defaultWriteBuffer
+	}
+	output := &outputFile{
+		file:     file,
+		buffer:   bufio.NewWriterSize(limitedFile{file: file, limiter: limiter}, size),
+		opts:     opts,
+		lastSync: time.Now(),
+	}
+	if opts.Gzip {
+		output.gzip = gzip.NewWriter(output.buffer)
+	}
+	return output, nil
+}
+
+func (f *outputFile) Write(p []byte) (int, error) {
+	if f.gzip != nil {
+		return f.gzip.Write(p)
+	}
+	return f.buffer.Write(p)
+}
+
+// Checkpoint flushes buffered output at the end of an input file and syncs
+// it if the fsync policy calls for it
+func (f *outputFile) Checkpoint() error {
+	if f.gzip != nil {
+		if err := f.gzip.Flush(); err != nil {
+			return err
+		}
+	}
+	if err := f.buffer.Flush(); err != nil {
+		return err
+	}
+	switch f.opts.Fsync {
+	case "file":
+		return f.file.Sync()
+	case "interval":
+		if time.Since(f.lastSync) >= f.opts.SyncInterval {
+			f.lastSync = time.Now()
+			return f.file.Sync()
+		}
+	}
+	return nil
+}
+
+// Close flushes, syncs unless fsync is off, and closes the file
+func (f *outputFile) Close() error {
+	var err error
+	if f.gzip != nil {
+		err = f.gzip.Close()
+	}
+	if flushErr := f.buffer.Flush(); err == nil {
+		err = flushErr
+	}
+	if err == nil && f.opts.Fsync != "" && f.opts.Fsync != "none" {
+		err = f.file.Sync()
+	}
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
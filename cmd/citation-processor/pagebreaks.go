@@ -0,0 +1,48 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// milestonePattern matches the elements a page-transcribed edition puts in
+// the middle of running text, and so in the middle of a citation: page,
+// column and line breaks, other milestones, and forme work such as running
+// headers, catchwords and page numbers, as in
+//
+//	<bibl>Soph. OT <pb n="12"/><fw type="header">OEDIPUS TYRANNUS</fw> 264</bibl>
+const milestonePattern = `<(?:pb|cb|lb|milestone)\b[^>]*/>|<fw\b[^>]*>[^<]*</fw>`
+
+var (
+	// milestoneRegex matches a milestone with the whitespace around it
+	milestoneRegex = regexp.MustCompile(`\s*(?:` + milestonePattern + `)\s*`)
+	// noBreakRegex matches break="no", marking a break inside a word
+	noBreakRegex = regexp.MustCompile(`\bbreak\s*=\s*"no"`)
+	// fwRegex matches forme work, masked with the other markup so that a
+	// running header is not read as running text
+	fwRegex = regexp.MustCompile(`(?s)<fw\b[^>]*>.*?</fw>`)
+)
+
+// stripMilestones removes the milestones from the content of an element,
+// for assembling its reference. A break marked break="no" falls inside a
+// word and joins its parts; any other stands for a space. Content without
+// milestones comes back unchanged. The element's span in the document is
+// taken from the element as written, so offsets still count the
+// milestones.
+func stripMilestones(content string) string {
+	if !strings.Contains(content, "<") {
+		return content
+	}
+	found := false
+	stripped := milestoneRegex.ReplaceAllStringFunc(content, func(milestone string) string {
+		found = true
+		if noBreakRegex.MatchString(milestone) {
+			return ""
+		}
+		return " "
+	})
+	if !found {
+		return content
+	}
+	return strings.Join(strings.Fields(stripped), " ")
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress/snappy"
+)
+
+// parquetFile receives all citations, resolved and unresolved, with -format
+// parquet
+const parquetFile = "citations.parquet"
+
+// parquetCitation is the row of a citation in citations.parquet. Its columns
+// are kept apart from the JSON of Citation so that the schema analytics
+// jobs load stays the same as fields are added to the JSONL output: columns
+// are only ever added. Strings that the JSONL output omits when empty are
+// null, and the document's metadata is flattened into document_* columns.
+type parquetCitation struct {
+	NAttrib         string              `parquet:"n_attrib"`
+	Bibl            string              `parquet:"bibl"`
+	Ref             string              `parquet:"ref"`
+	URN             string              `parquet:"urn,optional"` // null if unresolved
+	Resolved        bool                `parquet:"resolved"`
+	Quote           string              `parquet:"quote"`
+	XMLContext      string              `parquet:"xml_context"`
+	Filename        string              `parquet:"filename"`
+	DocCitURN       string              `parquet:"doc_cit_urn"`
+	DocumentID      string              `parquet:"document_id,optional"`
+	DocumentURN     string              `parquet:"document_urn,optional"`
+	DocumentTitle   string              `parquet:"document_title,optional"`
+	DocumentAuthor  string              `parquet:"document_author,optional"`
+	DocumentEditor  string              `parquet:"document_editor,optional"`
+	DocumentDate    string              `parquet:"document_date,optional"`
+	Pattern         string              `parquet:"pattern,optional"`
+	Scheme          string              `parquet:"scheme,optional"`
+	Qualifier       string              `parquet:"qualifier,optional"`
+	Works           []string            `parquet:"works,list"`
+	Locus           []parquetLocusLevel `parquet:"locus,list"`
+	Commentator     string              `parquet:"commentator,optional"`
+	Note            string              `parquet:"note,optional"`
+	Edition         string              `parquet:"edition,optional"`
+	ScaifeURL       string              `parquet:"scaife_url,optional"`
+	InApparatus     bool                `parquet:"in_apparatus"`
+	Lemma           string              `parquet:"lemma,optional"`
+	InQuote         bool                `parquet:"in_quote"`
+	Heading         string              `parquet:"section_heading,optional"`
+	CommentedRange  string              `parquet:"commented_range,optional"`
+	CitationType    string              `parquet:"citation_type,optional"`
+	QuotePosition   string              `parquet:"quote_position,optional"`
+	GroupID         string              `parquet:"group_id,optional"`
+	Ellipsis        string              `parquet:"ellipsis,optional"`
+	Fragment        *parquetFragment    `parquet:"fragment,optional"`
+	Documentary     *parquetDocumentary `parquet:"documentary,optional"`
+	ExternalID      string              `parquet:"external_id,optional"`
+	Secondary       *parquetSecondary   `parquet:"secondary,optional"`
+	Warnings        []string            `parquet:"warnings,list"`
+	Reason          string              `parquet:"reason,optional"` // failure reason, e.g. "unknown-work"
+	SchemeViolation string              `parquet:"scheme_violation,optional"`
+	Candidates      []parquetCandidate  `parquet:"candidates,list"`
+	Fallback        bool                `parquet:"fallback_used"`
+	Corrected       bool                `parquet:"corrected"`
+	PreResolved     bool                `parquet:"pre_resolved"`
+	Provisional     string              `parquet:"provisional_urn,optional"`
+	PriorUsed       bool                `parquet:"prior_used"`
+	FuzzyAuthor     *parquetAuthorMatch `parquet:"fuzzy_author,optional"`
+	Provenance      []parquetDataEntry  `parquet:"provenance,list"`
+	Step            string              `parquet:"resolution_step,optional"`
+	Confidence      *float64            `parquet:"step_confidence,optional"`
+}
+
+// parquetSchema is the schema of citations.parquet, named "citation"
+var parquetSchema = parquet.NewSchema("citation", parquet.SchemaOf(new(parquetCitation)))
+
+type parquetLocusLevel struct {
+	Name  string `parquet:"name"`
+	Value string `parquet:"value"`
+}
+
+type parquetFragment struct {
+	Collection string `parquet:"collection"`
+	Author     string `parquet:"author"`
+	Name       string `parquet:"name,optional"`
+	Kind       string `parquet:"kind"`
+	Number     string `parquet:"number"`
+}
+
+type parquetDocumentary struct {
+	Corpus  string `parquet:"corpus"`
+	Kind    string `parquet:"kind"`
+	Volume  string `parquet:"volume,optional"`
+	Edition string `parquet:"edition,optional"`
+	Number  string `parquet:"number"`
+}
+
+type parquetSecondary struct {
+	Author string `parquet:"author"`
+	Year   string `parquet:"year,optional"`
+	Title  string `parquet:"title,optional"`
+	Pages  string `parquet:"pages,optional"`
+	AdLoc  bool   `parquet:"ad_loc"`
+}
+
+type parquetCandidate struct {
+	URN   string  `parquet:"urn"`
+	Score float64 `parquet:"score"`
+}
+
+type parquetAuthorMatch struct {
+	Input    string  `parquet:"input"`
+	Matched  string  `parquet:"matched"`
+	Distance int64   `parquet:"edit_distance"`
+	Score    float64 `parquet:"score"`
+}
+
+type parquetDataEntry struct {
+	File  string `parquet:"file"`
+	Entry string `parquet:"entry"`
+}
+
+// newParquetCitation converts a citation to its row
+func newParquetCitation(citation Citation) parquetCitation {
+	row := parquetCitation{
+		NAttrib:         citation.NAttrib,
+		Bibl:            citation.Bibl,
+		Ref:             citation.Ref,
+		URN:             citation.URN,
+		Resolved:        citation.URN != "",
+		Quote:           citation.Quote,
+		XMLContext:      citation.XMLContext,
+		Filename:        citation.Filename,
+		DocCitURN:       citation.DocCitURN,
+		DocumentID:      citation.DocumentID,
+		Pattern:         citation.Pattern,
+		Scheme:          citation.Scheme,
+		Qualifier:       citation.Qualifier,
+		Works:           citation.Works,
+		Commentator:     citation.Commentator,
+		Note:            citation.Note,
+		Edition:         citation.Edition,
+		ScaifeURL:       citation.ScaifeURL,
+		InApparatus:     citation.InApparatus,
+		Lemma:           citation.Lemma,
+		InQuote:         citation.InQuote,
+		Heading:         citation.Heading,
+		CommentedRange:  citation.Commented,
+		CitationType:    citation.Type,
+		QuotePosition:   citation.QuotePos,
+		GroupID:         citation.Group,
+		Ellipsis:        citation.Ellipsis,
+		Warnings:        citation.Warnings,
+		Reason:          string(citation.reason),
+		SchemeViolation: citation.Violation,
+		Fallback:        citation.Fallback,
+		Corrected:       citation.Corrected,
+		PreResolved:     citation.PreResolved,
+		Provisional:     citation.Provisional,
+		PriorUsed:       citation.PriorUsed,
+		Step:            citation.Step,
+		ExternalID:      citation.ExternalID,
+	}
+	if document := citation.Document; document != nil {
+		row.DocumentURN = document.URN
+		row.DocumentTitle = document.Title
+		row.DocumentAuthor = document.Author
+		row.DocumentEditor = document.Editor
+		row.DocumentDate = document.Date
+	}
+	for _, level := range citation.Locus {
+		row.Locus = append(row.Locus, parquetLocusLevel{Name: level.Name, Value: level.Value})
+	}
+	if fragment := citation.Fragment; fragment != nil {
+		row.Fragment = &parquetFragment{Collection: fragment.Collection, Author: fragment.Author, Name: fragment.Name, Kind: fragment.Kind, Number: fragment.Number}
+	}
+	if text := citation.Documentary; text != nil {
+		row.Documentary = &parquetDocumentary{Corpus: text.Corpus, Kind: text.Kind, Volume: text.Volume, Edition: text.Edition, Number: text.Number}
+	}
+	if secondary := citation.Secondary; secondary != nil {
+		row.Secondary = &parquetSecondary{Author: secondary.Author, Year: secondary.Year, Title: secondary.Title, Pages: secondary.Pages, AdLoc: secondary.AdLoc}
+	}
+	for _, candidate := range citation.Candidates {
+		row.Candidates = append(row.Candidates, parquetCandidate{URN: candidate.URN, Score: candidate.Score})
+	}
+	if match := citation.FuzzyAuthor; match != nil {
+		row.FuzzyAuthor = &parquetAuthorMatch{Input: match.Input, Matched: match.Matched, Distance: int64(match.Distance), Score: match.Score}
+	}
+	for _, entry := range citation.Provenance {
+		row.Provenance = append(row.Provenance, parquetDataEntry{File: entry.File, Entry: entry.Entry})
+	}
+	if citation.Step != "" {
+		confidence := citation.Confidence
+		row.Confidence = &confidence
+	}
+	return row
+}
+
+// parquetWriter writes resolved and unresolved citations alike as rows of
+// citations.parquet, snappy-compressed. The file's footer is written on
+// Close, so unlike the JSONL output it is only readable once the run ends.
+type parquetWriter struct {
+	path    string
+	io      IOOptions
+	limiter *rateLimiter
+
+	file   *outputFile
+	writer *parquet.Writer
+}
+
+func (w *parquetWriter) open() error {
+	file, err := openOutputFile(w.path, os.O_CREATE|os.O_TRUNC, w.io, w.limiter)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.writer = parquet.NewWriter(file, parquetSchema, parquet.Compression(&snappy.Codec{}))
+	return nil
+}
+
+func (w *parquetWriter) Write(citations []Citation) error {
+	if w.writer == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+	for _, citation := range citations {
+		if err := w.writer.Write(newParquetCitation(citation)); err != nil {
+			return fmt.Errorf("failed to write %s: %w", w.path, err)
+		}
+	}
+	return nil
+}
+
+// Close writes the file's footer, creating the file with no rows if no
+// citations were written
+func (w *parquetWriter) Close() error {
+	if w.writer == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+	err := w.writer.Close()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.writer, w.file = nil, nil
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", w.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"perseus_citation_linker/pkg/loader"
+)
+
+// PatternMatch is a candidate citation found by a PatternProvider
+type PatternMatch struct {
+	Start int    // byte offset of the match in the document
+	End   int    // byte offset just past the match
+	Text  string // matched text, reported as the citation's bibl
+	Ref   string // reference string passed to the resolver
+}
+
+// PatternProvider finds unmarked citation candidates for aggressive mode.
+// Providers are run over a copy of the document in which markup and existing
+// citation elements have been blanked out, so offsets match the original.
+type PatternProvider interface {
+	Name() string
+	FindMatches(text string) []PatternMatch
+}
+
+// PatternProviderFactory builds a provider from the loaded citation data
+type PatternProviderFactory func(data *loader.ComprehensiveData) PatternProvider
+
+var patternProviderFactories = map[string]PatternProviderFactory{
+	"author-name": newAuthorNameProvider,
+	"work-title":  newWorkTitleProvider,
+}
+
+// RegisterPatternProvider adds a provider to those run in aggressive mode,
+// replacing any provider already registered under the same name
+func RegisterPatternProvider(name string, factory PatternProviderFactory) {
+	patternProviderFactories[name] = factory
+}
+
+// newPatternProviders instantiates all registered providers in name order
+func newPatternProviders(data *loader.ComprehensiveData) []PatternProvider {
+	names := make([]string, 0, len(patternProviderFactories))
+	for name := range patternProviderFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	providers := make([]PatternProvider, 0, len(names))
+	for _, name := range names {
+		providers = append(providers, patternProviderFactories[name](data))
+	}
+	return providers
+}
+
+var maskedElementRegexes = []*regexp.Regexp{
+	regexp.MustCompile(`(?s)` + citElementPattern),
+	regexp.MustCompile(`(?s)` + biblElementPattern),
+	regexp.MustCompile(`(?s)<ref\b[^>]*>.*?</ref>`),
+	fwRegex,
+	tagRegex,
+}
+
+// maskMarkup blanks out existing citation elements and all remaining tags
+// with spaces, preserving byte offsets, so that only running text is scanned
+func maskMarkup(xmlContent string) string {
+	masked := xmlContent
+	for _, re := range maskedElementRegexes {
+		masked = re.ReplaceAllStringFunc(masked, func(match string) string {
+			return strings.Repeat(" ", len(match))
+		})
+	}
+	return masked
+}
+
+// passagePattern matches a numeric locus such as "151", "1.1", "2, 40" or "327a"
+const passagePattern = `\d+[a-e]?(?:(?:[.:]|,\s?)\d+[a-e]?)*`
+
+// namesAlternation builds a regex alternation, longest names first so that
+// "apollonius of perga" wins over "apollonius"
+func namesAlternation(names []string) string {
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) > len(names[j])
+		}
+		return names[i] < names[j]
+	})
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = strings.ReplaceAll(regexp.QuoteMeta(name), " ", `\s+`)
+	}
+	return strings.Join(quoted, "|")
+}
+
+// startsUpper reports whether text begins with an upper-case letter, since
+// names and titles in running prose are capitalized
+func startsUpper(text string) bool {
+	r, _ := utf8.DecodeRuneInString(text)
+	return unicode.IsUpper(r)
+}
+
+// authorNameProvider finds full author names followed by an optional work
+// title and a passage, e.g. "Sophocles Ant. 1008" or "Thucydides 2.65"
+type authorNameProvider struct {
+	re *regexp.Regexp
+}
+
+func newAuthorNameProvider(data *loader.ComprehensiveData) PatternProvider {
+	var names []string
+	for author := range data.GetAllAuthors() {
+		// keys such as pliny_senior never appear in running text
+		if !strings.Contains(author, "_") {
+			names = append(names, author)
+		}
+	}
+	if len(names) == 0 {
+		return &authorNameProvider{}
+	}
+	pattern := `(?i)\b(?:` + namesAlternation(names) + `)\.?\s+(?:[a-z]+\.?\s+){0,3}?` + passagePattern
+	return &authorNameProvider{re: regexp.MustCompile(pattern)}
+}
+
+func (p *authorNameProvider) Name() string {
+	return "author-name"
+}
+
+func (p *authorNameProvider) FindMatches(text string) []PatternMatch {
+	if p.re == nil {
+		return nil
+	}
+	var matches []PatternMatch
+	for _, loc := range p.re.FindAllStringIndex(text, -1) {
+		matched := text[loc[0]:loc[1]]
+		if !startsUpper(matched) {
+			continue
+		}
+		matched = strings.Join(strings.Fields(matched), " ")
+		matches = append(matches, PatternMatch{Start: loc[0], End: loc[1], Text: matched, Ref: matched})
+	}
+	return matches
+}
+
+// workTitleProvider finds capitalized work titles that belong to a single
+// author followed by a passage, e.g. "Oedipus Tyrannus 151"
+type workTitleProvider struct {
+	re      *regexp.Regexp
+	authors map[string]string // lower-case title -> author
+}
+
+func newWorkTitleProvider(data *loader.ComprehensiveData) PatternProvider {
+	authors := make(map[string]string)
+	ambiguous := make(map[string]bool)
+	for author := range data.GetAllWorkURNs() {
+		for _, title := range data.WorkTitles(author) {
+			// short titles are too likely to match ordinary words
+			if len(title) < 6 || strings.ContainsAny(title, "_.") {
+				continue
+			}
+			if other, exists := authors[title]; exists && other != author {
+				ambiguous[title] = true
+			}
+			authors[title] = author
+		}
+	}
+	var titles []string
+	for title := range authors {
+		if ambiguous[title] {
+			delete(authors, title)
+			continue
+		}
+		titles = append(titles, title)
+	}
+	if len(titles) == 0 {
+		return &workTitleProvider{}
+	}
+	pattern := `(?i)\b(` + namesAlternation(titles) + `)\s+` + passagePattern
+	return &workTitleProvider{re: regexp.MustCompile(pattern), authors: authors}
+}
+
+func (p *workTitleProvider) Name() string {
+	return "work-title"
+}
+
+func (p *workTitleProvider) FindMatches(text string) []PatternMatch {
+	if p.re == nil {
+		return nil
+	}
+	var matches []PatternMatch
+	for _, loc := range p.re.FindAllStringSubmatchIndex(text, -1) {
+		matched := text[loc[0]:loc[1]]
+		if !startsUpper(matched) {
+			continue
+		}
+		title := strings.ToLower(strings.Join(strings.Fields(text[loc[2]:loc[3]]), " "))
+		author, exists := p.authors[title]
+		if !exists {
+			continue
+		}
+		matched = strings.Join(strings.Fields(matched), " ")
+		matches = append(matches, PatternMatch{
+			Start: loc[0],
+			End:   loc[1],
+			Text:  matched,
+			Ref:   author + " " + strings.ToLower(matched),
+		})
+	}
+	return matches
+}
+
+// extractAggressivePatterns runs every pattern provider over the running
+// text of the document, keeping only candidates that resolve to a URN
+func (cp *CitationProcessor) extractAggressivePatterns(xmlContent, filename string) []Citation {
+	if cp.patternProviders == nil {
+		cp.patternProviders = newPatternProviders(cp.Resolver.Data)
+	}
+	masked := maskMarkup(xmlContent)
+
+	var citations []Citation
+	for _, provider := range cp.patternProviders {
+		for _, match := range provider.FindMatches(masked) {
+			ref := cp.Resolver.GetRef("", match.Ref)
+			if ref == "" {
+				continue
+			}
+			res := cp.Resolver.Resolve(ref, "", filename)
+			if res.URN == "" {
+				continue
+			}
+
+			citURN := cp.nextCitURN()
+			lemma, inApparatus := cp.apparatusAt(match.Start)
+
+			citations = append(citations, Citation{
+				Bibl:        match.Text,
+				Ref:         ref,
+				URN:         res.URN,
+				XMLContext:  cp.contextAt(xmlContent, match.Start, match.End),
+				Filename:    filename,
+				DocCitURN:   citURN,
+				Pattern:     provider.Name(),
+				Scheme:      res.Scheme,
+				Violation:   res.SchemeViolation,
+				Candidates:  res.Candidates,
+				Works:       res.Works,
+				Qualifier:   res.Qualifier,
+				Fallback:    res.Fallback,
+				Locus:       res.Locus,
+				InApparatus: inApparatus,
+				Lemma:       lemma,
+				Heading:     cp.headingAt(match.Start),
+				InQuote:     cp.quotedAt(match.Start, match.End),
+				span:        byteSpan{start: match.Start, end: match.End},
+			})
+		}
+	}
+	return citations
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const teiNamespace = "http://www.tei-c.org/ns/1.0"
+
+var (
+	// xmlns:tei="http://www.tei-c.org/ns/1.0"
+	namespaceDeclRegex = regexp.MustCompile(`xmlns:([A-Za-z_][\w.-]*)\s*=\s*["']([^"']*)["']`)
+	// &name; &#123; &#x1F;
+	entityRegex = regexp.MustCompile(`&(#[xX][0-9a-fA-F]+|#[0-9]+|[A-Za-z][A-Za-z0-9]*);`)
+)
+
+// xmlSpecialEntities must stay encoded during preprocessing, since decoding
+// them would change the document's markup
+var xmlSpecialEntities = map[string]bool{
+	"lt": true, "gt": true, "amp": true, "quot": true, "apos": true,
+}
+
+// loadEntityTable reads a JSON object mapping entity names (without & and ;)
+// to their replacement text
+func loadEntityTable(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read entity table %s: %w", path, err)
+	}
+	entities := make(map[string]string)
+	if err := json.Unmarshal(content, &entities); err != nil {
+		return nil, fmt.Errorf("failed to parse entity table %s: %w", path, err)
+	}
+	return entities, nil
+}
+
+// preprocessXML removes TEI namespace prefixes from element names (so that
+// <tei:bibl> is matched like <bibl>) and decodes character entities that do
+// not affect the markup, consulting the custom entity table first
+func preprocessXML(xmlContent string, entities map[string]string) string {
+	xmlContent = stripTEIPrefixes(xmlContent)
+	return decodeEntities(xmlContent, entities)
+}
+
+// stripTEIPrefixes rewrites <prefix:name and </prefix:name for every prefix
+// bound to the TEI namespace, plus the conventional "tei" prefix
+func stripTEIPrefixes(xmlContent string) string {
+	prefixes := map[string]bool{"tei": true}
+	for _, match := range namespaceDeclRegex.FindAllStringSubmatch(xmlContent, -1) {
+		if match[2] == teiNamespace {
+			prefixes[match[1]] = true
+		}
+	}
+	for prefix := range prefixes {
+		if !strings.Contains(xmlContent, prefix+":") {
+			continue
+		}
+		xmlContent = strings.ReplaceAll(xmlContent, "<"+prefix+":", "<")
+		xmlContent = strings.ReplaceAll(xmlContent, "</"+prefix+":", "</")
+	}
+	return xmlContent
+}
+
+// decodeEntities replaces named and numeric character references with their
+// characters, leaving the XML special entities and anything that would decode
+// to a markup character untouched
+func decodeEntities(xmlContent string, entities map[string]string) string {
+	if !strings.Contains(xmlContent, "&") {
+		return xmlContent
+	}
+	return entityRegex.ReplaceAllStringFunc(xmlContent, func(entity string) string {
+		name := entity[1 : len(entity)-1]
+		if xmlSpecialEntities[name] {
+			return entity
+		}
+		if replacement, exists := entities[name]; exists {
+			return replacement
+		}
+		decoded := html.UnescapeString(entity)
+		if strings.ContainsAny(decoded, "<>&\"'") {
+			return entity
+		}
+		return decoded
+	})
+}
+
+// decodeText decodes all entities in extracted text such as bibl or quote
+// content, including double-escaped ones like &amp;mdash;
+func decodeText(text string) string {
+	if !strings.Contains(text, "&") {
+		return text
+	}
+	text = html.UnescapeString(text)
+	if entityRegex.MatchString(text) {
+		text = html.UnescapeString(text)
+	}
+	return text
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// previewElementRegex finds the elements extracted citations come from: <bibl>
+// (including those inside <cit>), <ref> and <ptr>
+var previewElementRegex = regexp.MustCompile(biblElementPattern + `|<ref\b[^>]*>[^<]+</ref>|<ptr\b[^>]*>`)
+
+// previewSpan is the part of the document a citation was extracted from
+type previewSpan struct {
+	start, end int
+	citation   Citation
+}
+
+// runPreview implements the preview subcommand, which renders one input
+// document as HTML with its extracted citations highlighted, for spot-checking
+// extraction and resolution rules
+func runPreview(args []string) error {
+	fs := flag.NewFlagSet("preview", flag.ExitOnError)
+	output := fs.String("o", "", "HTML output file (default: stdout)")
+	noCitTags := fs.Bool("nocit", false, "Extract <bibl> tags only, as with the main -nocit flag")
+	strict := fs.Bool("strict", false, "Only extract citation elements under TEI P5 citation parents")
+	aggressive := fs.Bool("aggressive", false, "Also highlight unmarked citations found by the pattern providers")
+	entityFile := fs.String("entities", "", "JSON file mapping extra character entity names to replacement text")
+	corrections := fs.String("corrections", "", "CSV or JSON corrections table")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: citation-processor preview [flags] file.xml")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one input XML file")
+	}
+	if *strict && *aggressive {
+		return fmt.Errorf("-strict and -aggressive cannot be combined")
+	}
+	filename := fs.Arg(0)
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", filename, err)
+	}
+
+	processor, err := NewCitationProcessor(Config{
+		UseCitTags:      !*noCitTags,
+		Strict:          *strict,
+		Aggressive:      *aggressive,
+		EntityFile:      *entityFile,
+		CorrectionsFile: *corrections,
+	})
+	if err != nil {
+		return err
+	}
+
+	out := io.Writer(os.Stdout)
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	return processor.RenderPreview(out, string(content), filename)
+}
+
+// RenderPreview extracts the citations in a document and writes the document
+// source as an HTML page, with each citation's element highlighted by status
+// and its URN (or resolution warnings) in a tooltip
+func (cp *CitationProcessor) RenderPreview(out io.Writer, xmlContent, filename string) error {
+	citations := cp.ExtractCitations(xmlContent, filename)
+	xmlContent = preprocessXML(xmlContent, cp.entities)
+	spans := cp.locateCitations(xmlContent, citations)
+
+	counts := make(map[string]int)
+	for _, citation := range citations {
+		counts[citationStatus(citation)]++
+	}
+
+	var b strings.Builder
+	b.WriteString(previewHeader)
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", html.EscapeString(filename))
+	fmt.Fprintf(&b, "<p class=\"legend\"><span class=\"cit resolved\">resolved: %d</span> "+
+		"<span class=\"cit ambiguous\">ambiguous: %d</span> "+
+		"<span class=\"cit unresolved\">unresolved: %d</span></p>\n",
+		counts["resolved"], counts["ambiguous"], counts["unresolved"])
+	b.WriteString("<pre>")
+	offset := 0
+	for _, span := range spans {
+		b.WriteString(html.EscapeString(xmlContent[offset:span.start]))
+		fmt.Fprintf(&b, `<span class="cit %s" title="%s">`,
+			citationStatus(span.citation), html.EscapeString(previewTooltip(span.citation)))
+		b.WriteString(html.EscapeString(xmlContent[span.start:span.end]))
+		b.WriteString("</span>")
+		offset = span.end
+	}
+	b.WriteString(html.EscapeString(xmlContent[offset:]))
+	b.WriteString("</pre>\n</body>\n</html>\n")
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+const previewHeader = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Citation preview</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; }
+pre { white-space: pre-wrap; font-size: 0.9em; line-height: 1.4; }
+.cit { border-radius: 3px; padding: 0 2px; cursor: help; }
+.resolved { background: #c8f0c8; }
+.ambiguous { background: #ffe08a; }
+.unresolved { background: #f8c0c0; }
+</style>
+</head>
+<body>
+`
+
+// citationStatus classifies a citation as resolved, ambiguous (several
+// candidate works) or unresolved
+func citationStatus(citation Citation) string {
+	switch {
+	case len(citation.Candidates) > 0:
+		return "ambiguous"
+	case citation.URN != "":
+		return "resolved"
+	}
+	return "unresolved"
+}
+
+// previewTooltip describes a citation's resolution on one line per fact
+func previewTooltip(citation Citation) string {
+	var lines []string
+	if citation.URN != "" {
+		lines = append(lines, citation.URN)
+	}
+	lines = append(lines, "ref: "+citation.Ref)
+	for _, candidate := range citation.Candidates {
+		lines = append(lines, fmt.Sprintf("candidate: %s (%.2f)", candidate.URN, candidate.Score))
+	}
+	if citation.Commentator != "" {
+		lines = append(lines, "commentator: "+citation.Commentator)
+	}
+	if citation.Note != "" {
+		lines = append(lines, "note: "+citation.Note)
+	}
+	if citation.Corrected {
+		lines = append(lines, "corrected")
+	}
+	if citation.PreResolved {
+		lines = append(lines, "pre-resolved from a CTS link")
+	}
+	lines = append(lines, citation.Warnings...)
+	if citation.Pattern != "" {
+		lines = append(lines, "pattern: "+citation.Pattern)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// locateCitations finds the span of each citation in the preprocessed
+// document. Citations do not record their offsets, so elements are matched to
+// citations by n attribute and content in document order; an element repeating
+// one already matched (which extraction deduplicates) shares its citation.
+// Aggressive-mode matches are found again in the masked running text.
+func (cp *CitationProcessor) locateCitations(xmlContent string, citations []Citation) []previewSpan {
+	pending := make(map[string][]Citation)
+	var aggressive []Citation
+	for _, citation := range citations {
+		if citation.Pattern != "" {
+			aggressive = append(aggressive, citation)
+			continue
+		}
+		key := citation.NAttrib + "|" + citation.Bibl
+		pending[key] = append(pending[key], citation)
+	}
+
+	var spans []previewSpan
+	last := make(map[string]Citation)
+	for _, loc := range previewElementRegex.FindAllStringIndex(xmlContent, -1) {
+		element := xmlContent[loc[0]:loc[1]]
+		var key string
+		if strings.HasPrefix(element, "<ptr") {
+			key = "|" + strings.TrimSpace(cp.extractAttribute(element, "target"))
+		} else if strings.HasPrefix(element, "<ref") {
+			content := element[strings.Index(element, ">")+1 : strings.LastIndex(element, "<")]
+			key = "|" + strings.TrimSpace(content)
+		} else {
+			key = cp.extractAttribute(element, "n") + "|" + cp.extractBiblContent(element)
+		}
+
+		citation, matched := last[key]
+		if queue := pending[key]; len(queue) > 0 {
+			citation, matched = queue[0], true
+			pending[key] = queue[1:]
+			last[key] = citation
+		}
+		if matched {
+			spans = append(spans, previewSpan{start: loc[0], end: loc[1], citation: citation})
+		}
+	}
+
+	masked := maskMarkup(xmlContent)
+	searchFrom := make(map[string]int)
+	for _, citation := range aggressive {
+		words := strings.Fields(citation.Bibl)
+		for i, word := range words {
+			words[i] = regexp.QuoteMeta(word)
+		}
+		re := regexp.MustCompile(strings.Join(words, `\s+`))
+		from := searchFrom[citation.Bibl]
+		if loc := re.FindStringIndex(masked[from:]); loc != nil {
+			spans = append(spans, previewSpan{start: from + loc[0], end: from + loc[1], citation: citation})
+			searchFrom[citation.Bibl] = from + loc[1]
+		}
+	}
+
+	// keep the first of any overlapping spans, e.g. a <ref> inside a <bibl>
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	var kept []previewSpan
+	for _, span := range spans {
+		if len(kept) > 0 && span.start < kept[len(kept)-1].end {
+			continue
+		}
+		kept = append(kept, span)
+	}
+	return kept
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"perseus_citation_linker/pkg/resolver"
+)
+
+// citationPrior is the resolver's WorkPrior for a run with Config.Prior. It
+// counts the works each document, and each author of commentary, cites
+// unambiguously: from a previous run's output with Config.PriorFrom, and from
+// the run's own citations as they are resolved, so that a document citing
+// "Plat. Hipp. Maj." earlier settles a later bare "Plat. Hipp.". Documents
+// are keyed by file name without directory, so a previous run's output still
+// applies when the corpus has moved.
+type citationPrior struct {
+	mu        sync.Mutex
+	documents map[string]map[string]int // document -> work URN -> citations
+	authors   map[string]map[string]int // author of commentary -> work URN -> citations
+	docAuthor map[string]string         // document -> its author, from the TEI header
+}
+
+func newCitationPrior() *citationPrior {
+	return &citationPrior{
+		documents: make(map[string]map[string]int),
+		authors:   make(map[string]map[string]int),
+		docAuthor: make(map[string]string),
+	}
+}
+
+// setAuthor records the author of a document, whose other commentaries are
+// consulted when the document itself does not decide
+func (p *citationPrior) setAuthor(filename, author string) {
+	if author == "" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.docAuthor[filepath.Base(filename)] = author
+}
+
+// observe counts a citation of urn in a document
+func (p *citationPrior) observe(filename, urn string) {
+	_, authURN, workID := splitWorkURN(urn)
+	if workID == "" {
+		return
+	}
+	workURN := authURN + "." + workID
+	document := filepath.Base(filename)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.documents[document] == nil {
+		p.documents[document] = make(map[string]int)
+	}
+	p.documents[document][workURN]++
+	if author := p.docAuthor[document]; author != "" {
+		if p.authors[author] == nil {
+			p.authors[author] = make(map[string]int)
+		}
+		p.authors[author][workURN]++
+	}
+}
+
+// Weight counts the document's citations of the work, adding the share of
+// its author's citations that are of the work. The share is below 1, so the
+// document's own citations always count first.
+func (p *citationPrior) Weight(filename, workURN string) float64 {
+	document := filepath.Base(filename)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	weight := float64(p.documents[document][workURN])
+	if counts := p.authors[p.docAuthor[document]]; len(counts) > 0 {
+		total := 0
+		for _, count := range counts {
+			total += count
+		}
+		weight += float64(counts[workURN]) / float64(total+1)
+	}
+	return weight
+}
+
+// observeResolution counts a resolution if it is unambiguous, so that the
+// prior only learns from citations it did not have to guess
+func (cp *CitationProcessor) observeResolution(filename string, res resolver.Resolution) {
+	if cp.prior == nil || res.URN == "" || len(res.Candidates) > 0 || res.Fallback || res.Prior {
+		return
+	}
+	cp.prior.observe(filename, res.URN)
+}
+
+// load seeds the prior from a previous run's output directory: the
+// documents' authors from documents.jsonl, and the unambiguous citations in
+// resolved.jsonl and its partitions
+func (p *citationPrior) load(dir string) error {
+	for _, path := range outputVariants(filepath.Join(dir, documentsFile)) {
+		err := readJSONL(path, func(line []byte) error {
+			var record documentRecord
+			if err := json.Unmarshal(line, &record); err != nil {
+				return err
+			}
+			p.setAuthor(record.Filename, record.Author)
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "resolved*.jsonl"))
+	compressed, _ := filepath.Glob(filepath.Join(dir, "resolved*.jsonl"+gzipExtension))
+	files = append(files, compressed...)
+	if len(files) == 0 {
+		return fmt.Errorf("no resolved citations in %s", dir)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		err := readJSONL(file, func(line []byte) error {
+			var citation Citation
+			if err := json.Unmarshal(line, &citation); err != nil {
+				return err
+			}
+			if citation.URN != "" && len(citation.Candidates) == 0 && !citation.Fallback && !citation.PriorUsed {
+				p.observe(citation.Filename, citation.URN)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readJSONL calls each with every non-empty line of a JSONL file, which may
+// be compressed
+func readJSONL(path string, each func(line []byte) error) error {
+	file, err := openJSONL(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 10*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := each(scanner.Bytes()); err != nil {
+			return fmt.Errorf("failed to parse %s line %d: %w", path, line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return nil
+}
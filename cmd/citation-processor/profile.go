@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// profiler writes the CPU and allocation profiles asked for with -cpuprofile
+// and -memprofile, for reading with go tool pprof
+type profiler struct {
+	cpuFile *os.File
+	memPath string
+}
+
+// startProfiling starts CPU profiling to cpuPath and arranges for the
+// allocation profile to be written to memPath when the profiler stops;
+// either path may be empty
+func startProfiling(cpuPath, memPath string) (*profiler, error) {
+	p := &profiler{memPath: memPath}
+	if cpuPath != "" {
+		file, err := os.Create(cpuPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create CPU profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+		p.cpuFile = file
+	}
+	return p, nil
+}
+
+// Stop ends CPU profiling and writes the allocation profile
+func (p *profiler) Stop() error {
+	if p.cpuFile != nil {
+		pprof.StopCPUProfile()
+		if err := p.cpuFile.Close(); err != nil {
+			return fmt.Errorf("failed to write CPU profile: %w", err)
+		}
+		p.cpuFile = nil
+	}
+	if p.memPath == "" {
+		return nil
+	}
+	file, err := os.Create(p.memPath)
+	if err != nil {
+		return fmt.Errorf("failed to create memory profile: %w", err)
+	}
+	defer file.Close()
+	runtime.GC() // bring the heap statistics up to date
+	if err := pprof.Lookup("allocs").WriteTo(file, 0); err != nil {
+		return fmt.Errorf("failed to write memory profile: %w", err)
+	}
+	p.memPath = ""
+	return file.Close()
+}
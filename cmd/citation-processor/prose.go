@@ -0,0 +1,156 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"perseus_citation_linker/pkg/loader"
+)
+
+const (
+	// prosePattern marks citations found in running prose, in their pattern
+	// field; they are written to prose.jsonl
+	prosePattern = "prose"
+
+	// minProseAbbreviation is the fewest letters an author abbreviation needs
+	// to be looked for in prose, since "Ov." or "Il." are too easily
+	// something else
+	minProseAbbreviation = 3
+
+	// proseCueWindow is the bytes before a span searched for its cue
+	proseCueWindow = 16
+)
+
+// proseCueRegex matches the cue that introduces a citation in prose, such
+// as "cf." or "see", at the end of the text before the span
+var proseCueRegex = regexp.MustCompile(`(?i)\b(?:cf|comp|see|vid|v)\.?,?\s*$`)
+
+// proseScanner finds citations left unmarked in running prose, e.g. "cf.
+// Thuc. 2.65": an author abbreviation from the data's tables, up to two
+// capitalized words for the work, and a numeric passage. It is kept apart
+// from the aggressive-mode providers because it is tuned for precision
+// rather than recall:
+//
+//   - abbreviations must be capitalized, end in a full stop and have at
+//     least minProseAbbreviation letters; those standing for several
+//     authors, such as "Plin.", are left out
+//   - the passage must be numeric and end at a word boundary
+//   - with cue, only spans after "cf.", "comp.", "see", "vid." or "v." count
+//   - only references that resolve to a single URN, without a fallback work
+//     or a misspelled author, are kept (see extractProse)
+type proseScanner struct {
+	re  *regexp.Regexp
+	cue bool
+}
+
+func newProseScanner(data *loader.ComprehensiveData, cue bool) *proseScanner {
+	var abbreviations []string
+	for abbreviation, author := range data.GetAllAuthAbb() {
+		name, isName := author.(string)
+		if !isName || strings.HasPrefix(name, "_") || !strings.HasSuffix(abbreviation, ".") {
+			continue
+		}
+		letters := 0
+		for _, r := range abbreviation {
+			if unicode.IsLetter(r) {
+				letters++
+			}
+		}
+		if letters >= minProseAbbreviation {
+			abbreviations = append(abbreviations, abbreviation)
+		}
+	}
+	scanner := &proseScanner{cue: cue}
+	if len(abbreviations) == 0 {
+		return scanner
+	}
+	pattern := `\b(?i:` + namesAlternation(abbreviations) + `)\s*(?:[A-Z][A-Za-z]*\.?\s+){0,2}?` + passagePattern
+	scanner.re = regexp.MustCompile(pattern)
+	return scanner
+}
+
+// FindMatches returns the candidate spans of text, which has its markup
+// masked as for the pattern providers
+func (s *proseScanner) FindMatches(text string) []PatternMatch {
+	if s.re == nil {
+		return nil
+	}
+	var matches []PatternMatch
+	for _, loc := range s.re.FindAllStringIndex(text, -1) {
+		matched := text[loc[0]:loc[1]]
+		if !startsUpper(matched) {
+			continue
+		}
+		// "Thuc. 2.65" but not "Thuc. 2.65th" or "Thuc. 2.6510"
+		if next, _ := utf8.DecodeRuneInString(text[loc[1]:]); unicode.IsLetter(next) || unicode.IsDigit(next) {
+			continue
+		}
+		if s.cue && !proseCueRegex.MatchString(text[max(0, loc[0]-proseCueWindow):loc[0]]) {
+			continue
+		}
+		matched = strings.Join(strings.Fields(matched), " ")
+		matches = append(matches, PatternMatch{Start: loc[0], End: loc[1], Text: matched, Ref: matched})
+	}
+	return matches
+}
+
+// extractProse scans the running text of the document for unmarked
+// citations, keeping those that resolve unambiguously and do not overlap a
+// citation already found in the text by aggressive mode
+func (cp *CitationProcessor) extractProse(xmlContent, filename string, found []Citation) []Citation {
+	if cp.proseScanner == nil {
+		cp.proseScanner = newProseScanner(cp.Resolver.Data, cp.Config.ProseCue)
+	}
+	masked := maskMarkup(xmlContent)
+
+	var citations []Citation
+	for _, match := range cp.proseScanner.FindMatches(masked) {
+		if overlapsCitation(match, found) {
+			continue
+		}
+		ref := cp.Resolver.GetRef("", match.Ref)
+		if ref == "" {
+			continue
+		}
+		res := cp.Resolver.Resolve(ref, "", filename)
+		if res.URN == "" || res.Fallback || res.FuzzyAuthor != nil || len(res.Candidates) > 1 {
+			continue
+		}
+
+		citURN := cp.nextCitURN()
+		lemma, inApparatus := cp.apparatusAt(match.Start)
+
+		citations = append(citations, Citation{
+			Bibl:        match.Text,
+			Ref:         ref,
+			URN:         res.URN,
+			XMLContext:  cp.contextAt(xmlContent, match.Start, match.End),
+			Filename:    filename,
+			DocCitURN:   citURN,
+			Pattern:     prosePattern,
+			Scheme:      res.Scheme,
+			Works:       res.Works,
+			Qualifier:   res.Qualifier,
+			Locus:       res.Locus,
+			InApparatus: inApparatus,
+			Lemma:       lemma,
+			Heading:     cp.headingAt(match.Start),
+			InQuote:     cp.quotedAt(match.Start, match.End),
+			span:        byteSpan{start: match.Start, end: match.End},
+		})
+	}
+	return citations
+}
+
+// overlapsCitation reports whether a match overlaps the span of any of the
+// citations
+func overlapsCitation(match PatternMatch, citations []Citation) bool {
+	for _, citation := range citations {
+		if match.Start < citation.span.end && citation.span.start < match.End {
+			return true
+		}
+	}
+	return false
+}
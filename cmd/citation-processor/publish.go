@@ -0,0 +1,456 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"perseus_citation_linker/pkg/cts"
+	"perseus_citation_linker/pkg/loader"
+)
+
+// The archive layouts of the publish subcommand
+const (
+	publishZip   = "zip"   // the files in a directory named for the dataset and version
+	publishBagIt = "bagit" // a BagIt bag (RFC 8493), serialized as a zip
+)
+
+const (
+	// citationSchemaFile is the JSON Schema of the citation records
+	citationSchemaFile = "citation.schema.json"
+	// provenanceFile records the run and the data it used
+	provenanceFile = "provenance.json"
+)
+
+// publishPack is the checksum of a data pack a published run used
+type publishPack struct {
+	Pack          string `json:"pack"`
+	File          string `json:"file"`
+	Checksum      string `json:"checksum"`
+	SchemaVersion int    `json:"schema_version"`
+}
+
+// publishProvenance is provenance.json
+type publishProvenance struct {
+	SchemaVersion int           `json:"schema_version"` // of the citation records, outputSchemaVersion
+	Dataset       string        `json:"dataset"`
+	Version       string        `json:"version"`
+	Created       string        `json:"created"`            // when the archive was written, in UTC
+	Tool          string        `json:"tool"`               // module path and version of the processor
+	Revision      string        `json:"revision,omitempty"` // VCS revision the processor was built from
+	Records       int           `json:"records"`
+	Resolved      int           `json:"resolved"`
+	Documents     int           `json:"documents"`
+	Works         int           `json:"works"` // distinct works cited, by URN
+	Files         []publishFile `json:"files"` // the files of the dataset, with their checksums
+	Data          []publishPack `json:"data"`  // the data packs, as loaded for publishing
+}
+
+// publishFile is a file of a published dataset
+type publishFile struct {
+	Name     string `json:"name"`
+	Bytes    int    `json:"bytes"`
+	Checksum string `json:"checksum"` // "sha256:" and the digest
+}
+
+// publishStats counts the citations of the resolved output being published
+type publishStats struct {
+	records, resolved int
+	documents, works  map[string]bool
+}
+
+// runPublish implements the publish subcommand, which bundles a run's
+// resolved citations with their schema, the provenance of the run, the
+// checksums of the data packs and a datasheet into a versioned archive for
+// deposit in a repository such as Zenodo
+func runPublish(args []string) error {
+	fs := flag.NewFlagSet("publish", flag.ExitOnError)
+	version := fs.String("version", "", "Version of the dataset, e.g. 1.0.0 (required)")
+	name := fs.String("name", "perseus-citations", "Name of the dataset, which the archive is named after")
+	title := fs.String("title", "Citations in the Perseus commentaries", "Title of the dataset in its datasheet")
+	creator := fs.String("creator", "", "Creator of the dataset, for its datasheet")
+	license := fs.String("license", "CC-BY-SA-4.0", "SPDX identifier of the dataset's license")
+	format := fs.String("format", publishZip, "Archive layout: zip, or bagit for a BagIt bag serialized as a zip")
+	output := fs.String("o", "", "Archive to write (default: <name>-<version>.zip)")
+	dataDir := fs.String("data", "", "Data directory the run used, for its checksums (default: discovered as for processing runs)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: citation-processor publish -version <version> [flags] output-dir")
+		fmt.Fprintln(fs.Output(), "output-dir is the output directory of a processing run")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("want an output directory, got %d arguments", fs.NArg())
+	}
+	if *version == "" {
+		return fmt.Errorf("missing -version")
+	}
+	if *format != publishZip && *format != publishBagIt {
+		return fmt.Errorf("unknown -format %q (expected zip or bagit)", *format)
+	}
+	if *output == "" {
+		*output = *name + "-" + *version + ".zip"
+	}
+	if *dataDir == "" {
+		*dataDir = loader.FindDataDir()
+	}
+
+	dir := fs.Arg(0)
+	resolved, others, err := publishedOutput(dir)
+	if err != nil {
+		return err
+	}
+	stats, err := readPublishStats(resolved)
+	if err != nil {
+		return err
+	}
+	data, err := loader.LoadComprehensiveDataDir(*dataDir)
+	if err != nil {
+		return err
+	}
+
+	files := append(resolved, others...)
+	dataset := publishDataset{
+		name: *name, version: *version, title: *title, creator: *creator, license: *license,
+		created: time.Now().UTC(),
+	}
+	for _, path := range resolved {
+		dataset.resolved = append(dataset.resolved, filepath.Base(path))
+	}
+	archive, err := dataset.build(files, stats, data.Summary(), *format)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*output, archive, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "wrote %s: %d citations from %d documents\n", *output, stats.records, len(stats.documents))
+	return nil
+}
+
+// resolvedNameRegex matches the names the writer gives resolved output:
+// resolved.jsonl, with the time the run started under -timestamp-output, a
+// partition's key under -partition-by and ".gz" under -gzip-output, as in
+// "resolved-20240131T120000Z.greekLit.jsonl.gz"
+var resolvedNameRegex = regexp.MustCompile(`^resolved(-\d{8}T\d{6}Z)?(\.[A-Za-z0-9._-]+)?\.jsonl(\.gz)?$`)
+
+// publishedOutput returns the files of the run whose output directory is
+// published: its resolved output, whole or in partitions, and its
+// documents.jsonl and run.json, if present. Of timestamped runs, the last
+// one's are published.
+func publishedOutput(dir string) (resolved, others []string, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	names := make(map[string]bool)
+	stamp := ""
+	for _, entry := range entries {
+		match := resolvedNameRegex.FindStringSubmatch(entry.Name())
+		if match == nil || entry.IsDir() {
+			continue
+		}
+		names[entry.Name()] = true
+		if match[1] > stamp {
+			stamp = match[1]
+		}
+	}
+	for name := range names {
+		match := resolvedNameRegex.FindStringSubmatch(name)
+		// a file beside its compressed counterpart is published plain
+		if match[1] != stamp || (match[3] != "" && names[strings.TrimSuffix(name, gzipExtension)]) {
+			continue
+		}
+		resolved = append(resolved, filepath.Join(dir, name))
+	}
+	if len(resolved) == 0 {
+		return nil, nil, fmt.Errorf("no resolved output in %s", dir)
+	}
+	sort.Strings(resolved)
+
+	if documents := existingVariant(filepath.Join(dir, withStamp(documentsFile, stamp))); documents != "" {
+		others = append(others, documents)
+	}
+	run := filepath.Join(dir, withStamp(runFile, stamp))
+	if _, err := os.Stat(run); err == nil {
+		others = append(others, run)
+	}
+	return resolved, others, nil
+}
+
+// existingVariant returns the plain or gzipped variant of an output file
+// that exists, or "" if neither does
+func existingVariant(path string) string {
+	for _, variant := range outputVariants(path) {
+		if _, err := os.Stat(variant); err == nil {
+			return variant
+		}
+	}
+	return ""
+}
+
+// readPublishStats counts the citations of resolved output
+func readPublishStats(paths []string) (*publishStats, error) {
+	stats := &publishStats{documents: make(map[string]bool), works: make(map[string]bool)}
+	for _, path := range paths {
+		err := readJSONL(path, func(line []byte) error {
+			var record compatRecord
+			if err := json.Unmarshal(line, &record); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			stats.records++
+			stats.documents[recordString(record, "filename")] = true
+			urn := recordString(record, "urn")
+			if urn == "" {
+				return nil
+			}
+			stats.resolved++
+			if parsed, err := cts.Parse(urn); err == nil {
+				parsed.Passage = cts.Passage{}
+				stats.works[parsed.String()] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+// publishDataset describes the dataset being published
+type publishDataset struct {
+	name, version, title, creator, license string
+	created                                time.Time
+	resolved                               []string // names of the resolved output files archived
+}
+
+// archiveFile is a file to be written to the archive
+type archiveFile struct {
+	name    string
+	content []byte
+}
+
+// build writes the archive of the dataset: the output files, the schema,
+// provenance.json and README.md, with a SHA256SUMS file in the zip layout
+// and the BagIt tag files in the bagit layout
+func (d publishDataset) build(paths []string, stats *publishStats, packs []loader.PackSummary, format string) ([]byte, error) {
+	var payload []archiveFile
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		payload = append(payload, archiveFile{name: filepath.Base(path), content: content})
+	}
+	schema, err := json.MarshalIndent(citationSchema(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	payload = append(payload, archiveFile{name: citationSchemaFile, content: append(schema, '\n')})
+
+	provenance := d.provenance(payload, stats, packs)
+	content, err := json.MarshalIndent(provenance, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	payload = append(payload, archiveFile{name: provenanceFile, content: append(content, '\n')})
+	payload = append(payload, archiveFile{name: "README.md", content: d.datasheet(provenance)})
+
+	root := d.name + "-" + d.version + "/"
+	var files []archiveFile
+	if format == publishBagIt {
+		files = d.bag(payload)
+	} else {
+		files = append(payload, archiveFile{name: "SHA256SUMS", content: checksumManifest(payload, "")})
+	}
+
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for _, file := range files {
+		header := &zip.FileHeader{Name: root + file.name, Method: zip.Deflate, Modified: d.created}
+		entry, err := writer.CreateHeader(header)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := entry.Write(file.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// provenance describes the run and the data behind the dataset's files
+func (d publishDataset) provenance(payload []archiveFile, stats *publishStats, packs []loader.PackSummary) publishProvenance {
+	provenance := publishProvenance{
+		SchemaVersion: outputSchemaVersion,
+		Dataset:       d.name,
+		Version:       d.version,
+		Created:       d.created.Format(time.RFC3339),
+		Records:       stats.records,
+		Resolved:      stats.resolved,
+		Documents:     len(stats.documents),
+		Works:         len(stats.works),
+	}
+	provenance.Tool, provenance.Revision = toolVersion()
+	for _, file := range payload {
+		provenance.Files = append(provenance.Files, publishFile{Name: file.name, Bytes: len(file.content), Checksum: "sha256:" + sha256Hex(file.content)})
+	}
+	for _, pack := range packs {
+		provenance.Data = append(provenance.Data, publishPack{Pack: pack.Pack, File: pack.File, Checksum: pack.Checksum, SchemaVersion: pack.SchemaVersion})
+	}
+	return provenance
+}
+
+// datasheet writes README.md, describing the dataset for those who find it
+// in a repository
+func (d publishDataset) datasheet(provenance publishProvenance) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", d.title)
+	fmt.Fprintf(&b, "Version %s of %s, published %s.\n\n", d.version, d.name, d.created.Format("2006-01-02"))
+	if d.creator != "" {
+		fmt.Fprintf(&b, "Creator: %s\n\n", d.creator)
+	}
+	fmt.Fprintf(&b, "License: %s\n\n", d.license)
+
+	b.WriteString("## Contents\n\n")
+	fmt.Fprintf(&b, "%d citations from %d documents, %d of them resolved to a CTS URN, citing %d distinct works.\n\n",
+		provenance.Records, provenance.Documents, provenance.Resolved, provenance.Works)
+	b.WriteString("| File | Bytes | Checksum |\n|------|-------|----------|\n")
+	for _, file := range provenance.Files {
+		fmt.Fprintf(&b, "| `%s` | %d | `%s` |\n", file.Name, file.Bytes, file.Checksum)
+	}
+	var resolved []string
+	for _, name := range d.resolved {
+		resolved = append(resolved, "`"+name+"`")
+	}
+	fmt.Fprintf(&b, "\nEach line of %s is a citation, a JSON object described by `%s`.\n", strings.Join(resolved, ", "), citationSchemaFile)
+	b.WriteString("Citations are numbered within each document by `doc_cit_urn`.\n\n")
+
+	b.WriteString("## Provenance\n\n")
+	fmt.Fprintf(&b, "Extracted and resolved by %s", provenance.Tool)
+	if provenance.Revision != "" {
+		fmt.Fprintf(&b, " (revision %s)", provenance.Revision)
+	}
+	b.WriteString(" from the author and work tables below. `" + provenanceFile + "` records the same in JSON.\n\n")
+	b.WriteString("| Pack | File | Schema | Checksum |\n|------|------|--------|----------|\n")
+	for _, pack := range provenance.Data {
+		fmt.Fprintf(&b, "| %s | `%s` | %d | `%s` |\n", pack.Pack, pack.File, pack.SchemaVersion, pack.Checksum)
+	}
+	b.WriteString("\n## Limitations\n\n")
+	b.WriteString("References are resolved automatically. Unresolved citations are not included, and a resolved\n")
+	b.WriteString("citation with `candidates` or `fallback_used` was one of several possible readings.\n")
+	return []byte(b.String())
+}
+
+// bag lays the payload out as a BagIt bag: the payload under data/, with
+// bagit.txt, bag-info.txt and the payload and tag manifests
+func (d publishDataset) bag(payload []archiveFile) []archiveFile {
+	size := 0
+	for _, file := range payload {
+		size += len(file.content)
+	}
+	var info strings.Builder
+	fmt.Fprintf(&info, "Bagging-Date: %s\n", d.created.Format("2006-01-02"))
+	fmt.Fprintf(&info, "External-Identifier: %s-%s\n", d.name, d.version)
+	fmt.Fprintf(&info, "External-Description: %s\n", d.title)
+	if d.creator != "" {
+		fmt.Fprintf(&info, "Contact-Name: %s\n", d.creator)
+	}
+	fmt.Fprintf(&info, "Payload-Oxum: %d.%d\n", size, len(payload))
+	fmt.Fprintf(&info, "Version: %s\n", d.version)
+
+	var files []archiveFile
+	for _, file := range payload {
+		files = append(files, archiveFile{name: "data/" + file.name, content: file.content})
+	}
+	tags := []archiveFile{
+		{name: "bagit.txt", content: []byte("BagIt-Version: 1.0\nTag-File-Character-Encoding: UTF-8\n")},
+		{name: "bag-info.txt", content: []byte(info.String())},
+		{name: "manifest-sha256.txt", content: checksumManifest(payload, "data/")},
+	}
+	tags = append(tags, archiveFile{name: "tagmanifest-sha256.txt", content: checksumManifest(tags, "")})
+	return append(tags, files...)
+}
+
+// checksumManifest lists the SHA-256 digest of each file, in the format of
+// sha256sum and of BagIt manifests, its name prefixed with prefix
+func checksumManifest(files []archiveFile, prefix string) []byte {
+	var b strings.Builder
+	for _, file := range files {
+		fmt.Fprintf(&b, "%s  %s%s\n", sha256Hex(file.content), prefix, file.name)
+	}
+	return []byte(b.String())
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// citationSchema returns a JSON Schema of the citation records, derived
+// from Citation so that it follows the fields as they are added
+func citationSchema() map[string]any {
+	schema := jsonSchema(reflect.TypeOf(Citation{}))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "Citation"
+	return schema
+}
+
+// jsonSchema returns the JSON Schema of values of a type as encoding/json
+// writes them. The fields of a struct without omitempty are required.
+func jsonSchema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return jsonSchema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": jsonSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchema(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]any)
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := field.Tag.Get("json")
+			if !field.IsExported() || tag == "-" {
+				continue
+			}
+			name, options, _ := strings.Cut(tag, ",")
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = jsonSchema(field.Type)
+			if !strings.Contains(","+options+",", ",omitempty,") {
+				required = append(required, name)
+			}
+		}
+		sort.Strings(required)
+		return map[string]any{"type": "object", "properties": properties, "required": required}
+	}
+	return map[string]any{}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"perseus_citation_linker/pkg/resolver"
+)
+
+// quoteNormalizers normalize the quotations of every processor, chosen by
+// the language of each
+var quoteNormalizers = resolver.NewQuoteNormalizers()
+
+// RegisterQuoteNormalizer sets the normalizer of the quotations in a
+// language, by its xml:lang code such as "san", and with a script those in
+// that script whose language is not given, replacing the built-in Greek or
+// Latin one if need be
+func RegisterQuoteNormalizer(lang string, script *unicode.RangeTable, normalizer resolver.QuoteNormalizer) {
+	quoteNormalizers.Register(lang, script, normalizer)
+}
+
+// normalizeQuote returns the normalized content of a <quote> element, by
+// the normalizer of the language its xml:lang gives or else of its script
+func normalizeQuote(element string) string {
+	content := ""
+	if match := quoteContentRegex.FindStringSubmatch(element); match != nil {
+		content = strings.TrimSpace(decodeText(match[2]))
+	}
+	lang := ""
+	if match := quoteLangRegex.FindStringSubmatch(element); match != nil {
+		lang = match[1]
+	}
+	return quoteNormalizers.For(lang, content).Normalize(content)
+}
+
+var (
+	// the opening tag and content of a <quote> element
+	quoteContentRegex = regexp.MustCompile(`(?s)^<quote\b([^>]*)>(.*)</quote>$`)
+	// the language of a <quote> element, from its opening tag
+	quoteLangRegex = regexp.MustCompile(`^<quote\b[^>]*\bxml:lang\s*=\s*"([^"]*)"`)
+)
+
+// quoteTagRegex matches the opening and closing tags of <quote> elements
+var quoteTagRegex = regexp.MustCompile(`<(/?)quote\b[^>]*?(/?)>`)
+
+// quoteSpan is the content of a <quote> element, between its tags
+type quoteSpan struct {
+	start, end int
+}
+
+// quoteSpans finds the quotations of a document, in document order. Quotes
+// nested in quotes are part of the outermost one.
+func quoteSpans(xmlContent string) []quoteSpan {
+	if !strings.Contains(xmlContent, "<quote") {
+		return nil
+	}
+	var spans []quoteSpan
+	depth, start := 0, 0
+	for _, match := range quoteTagRegex.FindAllStringSubmatchIndex(xmlContent, -1) {
+		closing := match[3] > match[2]
+		selfClosing := match[5] > match[4]
+		switch {
+		case selfClosing:
+		case !closing:
+			if depth == 0 {
+				start = match[1]
+			}
+			depth++
+		case depth > 0:
+			depth--
+			if depth == 0 {
+				spans = append(spans, quoteSpan{start: start, end: match[0]})
+			}
+		}
+	}
+	return spans
+}
+
+// quotedAt reports whether the text between the byte offsets lies entirely
+// inside a <quote> element
+func (cp *CitationProcessor) quotedAt(start, end int) bool {
+	i := sort.Search(len(cp.quotes), func(i int) bool { return cp.quotes[i].end > start })
+	return i < len(cp.quotes) && cp.quotes[i].start <= start && end <= cp.quotes[i].end
+}
+
+// Where the quotation of a citation stands relative to its reference, in
+// the quote_position field
+const (
+	quoteBefore = "before"
+	quoteAfter  = "after"
+)
+
+// defaultQuoteDistance is the characters a <quote> may stand from a <bibl>
+// outside a <cit>, before or after it, to be taken as its quotation
+const defaultQuoteDistance = 200
+
+// quoteBoundaryRegex matches what may not stand between a <bibl> and the
+// <quote> taken with it: the tags of paragraphs and other blocks, and of
+// other citations and quotations
+var quoteBoundaryRegex = regexp.MustCompile(`</?(?:p|div\d?|ab|l|lg|item|list|note|head|sp|cit|bibl|quote)\b`)
+
+// quoteAt returns the normalized quotation of the <bibl> outside a <cit> at
+// the byte offsets [start, end), and whether it stands before or after it:
+// the nearest <quote> in the same paragraph with nothing but text and inline
+// markup between them, at most
+// Config.QuoteLookahead characters after the bibl or Config.QuoteLookbehind
+// before it. The link holds both ways: a quote nearer another bibl is that
+// bibl's, so a bare reference between two quoted ones takes neither, and
+// of two bibls equally near a quote, the one before it takes it.
+func (cp *CitationProcessor) quoteAt(xmlContent string, start, end int) (quote, position string) {
+	if len(cp.quotes) == 0 || start < 0 {
+		return "", ""
+	}
+	lookahead, lookbehind := quoteDistance(cp.Config.QuoteLookahead), quoteDistance(cp.Config.QuoteLookbehind)
+
+	// quotes are outermost and in document order, so ordered by their ends too
+	best, bestGap := "", -1
+	i := sort.Search(len(cp.quotes), func(i int) bool { return cp.quotes[i].start >= end })
+	if i < len(cp.quotes) {
+		open := end + strings.LastIndex(xmlContent[end:cp.quotes[i].start], "<quote")
+		closeEnd := quoteEnd(xmlContent, cp.quotes[i])
+		gap, ok := quoteGap(xmlContent[end:open], lookahead)
+		// the quote is the next bibl's if that is nearer
+		if next, nextOK := biblAfter(xmlContent, closeEnd, lookbehind); ok && (!nextOK || next >= gap) {
+			best, bestGap, position = xmlContent[open:closeEnd], gap, quoteAfter
+		}
+	}
+	if i > 0 {
+		before := cp.quotes[i-1]
+		if closeEnd := quoteEnd(xmlContent, before); closeEnd <= start {
+			open := strings.LastIndex(xmlContent[:before.start], "<quote")
+			gap, ok := quoteGap(xmlContent[closeEnd:start], lookbehind)
+			// the quote is the previous bibl's if that is as near
+			previous, previousOK := biblBefore(xmlContent, open, lookahead)
+			if ok && (!previousOK || previous > gap) && (bestGap < 0 || gap < bestGap) {
+				best, position = xmlContent[open:closeEnd], quoteBefore
+			}
+		}
+	}
+	if best == "" {
+		return "", ""
+	}
+	return normalizeQuote(best), position
+}
+
+var (
+	// biblEndRegex matches the end of a <bibl> element
+	biblEndRegex = regexp.MustCompile(`</bibl\s*>|<bibl\b[^>]*/>`)
+	// biblStartRegex matches the start of a <bibl> element
+	biblStartRegex = regexp.MustCompile(`<bibl\b`)
+)
+
+// biblBefore returns the characters between a quote starting at offset and
+// the end of the <bibl> before it, if one is near enough to take it
+func biblBefore(xmlContent string, offset, distance int) (int, bool) {
+	if distance < 0 {
+		return 0, false
+	}
+	window := xmlContent[max(0, offset-distance*utf8.UTFMax-len("</bibl>")):offset]
+	matches := biblEndRegex.FindAllStringIndex(window, -1)
+	if len(matches) == 0 {
+		return 0, false
+	}
+	return quoteGap(window[matches[len(matches)-1][1]:], distance)
+}
+
+// biblAfter returns the characters between a quote ending at offset and the
+// start of the <bibl> after it, if one is near enough to take it
+func biblAfter(xmlContent string, offset, distance int) (int, bool) {
+	if distance < 0 {
+		return 0, false
+	}
+	window := xmlContent[offset:min(len(xmlContent), offset+distance*utf8.UTFMax+len("<bibl"))]
+	loc := biblStartRegex.FindStringIndex(window)
+	if loc == nil {
+		return 0, false
+	}
+	return quoteGap(window[:loc[0]], distance)
+}
+
+// quoteDistance returns the characters a quote may stand from its bibl,
+// from Config.QuoteLookahead or Config.QuoteLookbehind
+func quoteDistance(configured int) int {
+	if configured == 0 {
+		return defaultQuoteDistance
+	}
+	return configured
+}
+
+// quoteGap returns the characters of the text between a bibl and a quote,
+// and whether it is short enough and crosses no boundary for them to be
+// taken together
+func quoteGap(between string, distance int) (int, bool) {
+	// a gap too many bytes long for distance characters need not be counted
+	if distance < 0 || len(between) > distance*utf8.UTFMax {
+		return 0, false
+	}
+	gap := utf8.RuneCountInString(between)
+	return gap, gap <= distance && !quoteBoundaryRegex.MatchString(between)
+}
+
+// quoteEnd returns the offset just past the closing tag of a quote
+func quoteEnd(xmlContent string, quote quoteSpan) int {
+	return quote.end + strings.IndexByte(xmlContent[quote.end:], '>') + 1
+}
+
+// quotePosition returns whether the quote of a <cit> stands before or after
+// its bibl
+func quotePosition(citMatch, biblMatch, quoteMatch string) string {
+	if strings.Index(citMatch, quoteMatch) < strings.Index(citMatch, biblMatch) {
+		return quoteBefore
+	}
+	return quoteAfter
+}
+
+// dropQuoted removes the citations found inside quotations, for
+// Config.SkipQuoted
+func dropQuoted(citations []Citation) (kept []Citation, dropped int) {
+	kept = citations[:0]
+	for _, citation := range citations {
+		if citation.InQuote {
+			dropped++
+			continue
+		}
+		kept = append(kept, citation)
+	}
+	return kept, dropped
+}
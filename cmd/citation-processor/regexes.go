@@ -0,0 +1,25 @@
+package main
+
+import (
+	"regexp"
+	"sync"
+)
+
+// compiledRegexes holds the patterns that are built at run time, such as
+// those of extractAttribute, so that each is compiled once rather than for
+// every element. Fixed patterns are package-level variables instead.
+var compiledRegexes sync.Map // pattern -> *regexp.Regexp
+
+// compiledRegex returns pattern compiled, compiling it on first use
+func compiledRegex(pattern string) *regexp.Regexp {
+	if re, exists := compiledRegexes.Load(pattern); exists {
+		return re.(*regexp.Regexp)
+	}
+	re, _ := compiledRegexes.LoadOrStore(pattern, regexp.MustCompile(pattern))
+	return re.(*regexp.Regexp)
+}
+
+// attributeRegex matches the value of the attribute attrName
+func attributeRegex(attrName string) *regexp.Regexp {
+	return compiledRegex(attrName + `="([^"]*)"`)
+}
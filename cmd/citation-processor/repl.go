@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"perseus_citation_linker/pkg/loader"
+	"perseus_citation_linker/pkg/resolver"
+)
+
+const replHelp = `Type a reference as it appears in a bibl, e.g. "Soph. OT 151", or an n
+attribute and bibl content separated by "|", e.g. "Soph. OT 151 | O. T. 151".
+Commands:
+  :works   find works by title or abbreviation, e.g. ":works oedipus"
+  :reload  reload the data directory and corrections
+  :help    show this help
+  :quit    exit (or end input)
+`
+
+// replWorkMatches limits the works :works lists
+const replWorkMatches = 10
+
+// runRepl implements the repl subcommand, an interactive prompt that shows
+// how each reference typed is normalized, parsed and resolved, for curating
+// the data files without round trips through XML test documents
+func runRepl(args []string) error {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	dataDir := fs.String("data", "", "Data directory (default: discovered as for processing runs)")
+	corrections := fs.String("corrections", "", "CSV or JSON corrections table")
+	workFallback := fs.String("work-fallback", "guess", "When a known author's work is not found: guess, author or none")
+	fuzzyAuthors := fs.Float64("fuzzy-authors", 0, "Jaro-Winkler similarity for matching misspelled authors (0: off)")
+	fs.Parse(args)
+
+	policy, err := resolver.ParseWorkFallback(*workFallback)
+	if err != nil {
+		return err
+	}
+
+	if *dataDir == "" {
+		*dataDir = loader.FindDataDir()
+	}
+	load := func() (*resolver.URNResolver, error) {
+		urnResolver, err := resolver.NewURNResolverFromDir(*dataDir)
+		if err != nil {
+			return nil, err
+		}
+		urnResolver.WorkFallback = policy
+		urnResolver.FuzzyAuthors = *fuzzyAuthors
+		urnResolver.RecordProvenance = true
+		urnResolver.Chain = registeredSteps()
+		if *corrections != "" {
+			if urnResolver.Corrections, err = resolver.LoadCorrections(*corrections); err != nil {
+				return nil, err
+			}
+		}
+		return urnResolver, nil
+	}
+	return runReplLoop(os.Stdin, os.Stdout, load)
+}
+
+// runReplLoop reads references from in until end of input or :quit, writing
+// a breakdown of each to out. load builds the resolver, and is called again
+// on :reload so edits to the data files can be tried without restarting.
+func runReplLoop(in io.Reader, out io.Writer, load func() (*resolver.URNResolver, error)) error {
+	urnResolver, err := load()
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(out, replHelp)
+
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		switch line {
+		case "":
+			continue
+		case ":quit", ":q":
+			return nil
+		case ":help", ":h":
+			fmt.Fprint(out, replHelp)
+			continue
+		case ":reload":
+			reloaded, err := load()
+			if err != nil {
+				// keep the working resolver so a bad edit can be fixed and retried
+				fmt.Fprintf(out, "reload failed: %v\n", err)
+				continue
+			}
+			urnResolver = reloaded
+			fmt.Fprintln(out, "reloaded")
+			continue
+		}
+		if query, found := strings.CutPrefix(line, ":works"); found {
+			listWorks(out, urnResolver, query)
+			continue
+		}
+		explainReference(out, urnResolver, line)
+	}
+}
+
+// explainReference writes each step of resolving one line of input: the
+// reference derived from it, its parts, and the resolution
+func explainReference(out io.Writer, urnResolver *resolver.URNResolver, line string) {
+	var nAttr, biblContent string
+	if n, bibl, found := strings.Cut(line, "|"); found {
+		nAttr, biblContent = strings.TrimSpace(n), strings.TrimSpace(bibl)
+	} else {
+		biblContent = line
+	}
+
+	ref, commentator, note, edition := deriveReference(urnResolver, nAttr, biblContent)
+
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(out, "  %-12s %s\n", name+":", value)
+		}
+	}
+	field("ref", fmt.Sprintf("%q", ref))
+	field("commentator", commentator)
+	field("note", note)
+	field("edition", edition)
+	// what -secondary would route to secondary.jsonl
+	if secondary := urnResolver.SecondaryLiterature(firstNonEmpty(biblContent, nAttr)); secondary != nil {
+		field("secondary", fmt.Sprintf("author %s, year %s, title %s, pages %s, ad loc. %t", secondary.Author, secondary.Year, secondary.Title, secondary.Pages, secondary.AdLoc))
+	}
+	if ref == "" {
+		fmt.Fprintln(out, "  no reference found")
+		return
+	}
+
+	parts := urnResolver.ParseReference(ref)
+	author := parts.Author
+	if author != "" {
+		resolved := parts.ResolvedAuthor
+		if resolved == "" {
+			resolved = "(not recognized)"
+		}
+		author += " -> " + resolved
+	}
+	field("author", author)
+	field("work", parts.Work)
+	field("passage", parts.Passage)
+
+	res := urnResolver.Resolve(ref, "", "")
+	urn := res.URN
+	if urn == "" {
+		urn = "(unresolved)"
+	}
+	field("urn", urn)
+	field("scheme", res.Scheme)
+	field("qualifier", res.Qualifier)
+	for _, level := range res.Locus {
+		field("locus", level.Name+"="+level.Value)
+	}
+	if fragment := res.Fragment; fragment != nil {
+		field("fragment", fmt.Sprintf("%s %s %s%s %s", fragment.Collection, fragment.Author, fragment.Kind, fragment.Number, fragment.Name))
+	}
+	if text := res.Documentary; text != nil {
+		field("documentary", fmt.Sprintf("%s %s, volume %s, edition %s, number %s", text.Kind, text.Corpus, text.Volume, text.Edition, text.Number))
+	}
+	field("external_id", res.ExternalID)
+	if res.FuzzyAuthor != nil {
+		field("fuzzy", fmt.Sprintf("%s -> %s (edit distance %d, similarity %.2f)", res.FuzzyAuthor.Input, res.FuzzyAuthor.Matched, res.FuzzyAuthor.Distance, res.FuzzyAuthor.Score))
+	}
+	if res.Corrected {
+		field("corrected", "from the corrections table")
+	}
+	if res.Step != "" {
+		field("step", fmt.Sprintf("%s (confidence %.2f)", res.Step, res.Confidence))
+	}
+	if res.Fallback {
+		field("fallback", fmt.Sprintf("work not found, URN from the %s policy", urnResolver.WorkFallback))
+	}
+	for _, work := range res.Works {
+		field("work range", work)
+	}
+	for _, candidate := range res.Candidates {
+		field("candidate", fmt.Sprintf("%s (%.2f)", candidate.URN, candidate.Score))
+	}
+	for _, entry := range res.Provenance {
+		field("data", entry.String())
+	}
+	for _, warning := range res.Warnings {
+		field("warning", warning)
+	}
+}
+
+// listWorks writes the works best matching a query, with the title or
+// abbreviation that matched
+func listWorks(out io.Writer, urnResolver *resolver.URNResolver, query string) {
+	matches := urnResolver.FindWorks(query)
+	if len(matches) == 0 {
+		fmt.Fprintln(out, "  no works found")
+		return
+	}
+	for i, match := range matches {
+		if i == replWorkMatches {
+			fmt.Fprintf(out, "  ... %d more\n", len(matches)-replWorkMatches)
+			break
+		}
+		fmt.Fprintf(out, "  %-40s %s, %s (%q, %.2f)\n", match.URN, match.Author, match.Title, match.Matched, match.Score)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+
+	"perseus_citation_linker/pkg/loader"
+)
+
+// outputSchemaVersion is the version of the format of the output records,
+// recorded in run.json and in the provenance of published datasets. It is
+// raised when a field is removed, renamed or changes meaning, so that
+// consumers can tell output they cannot read; a field added does not raise
+// it.
+const outputSchemaVersion = 1
+
+// runFile describes a run, with Config.RunMetadata
+const runFile = "run.json"
+
+// runMetadata is run.json: what a consumer needs to tell the format of the
+// output and to reproduce the run that wrote it
+type runMetadata struct {
+	SchemaVersion int               `json:"schema_version"`     // outputSchemaVersion
+	Tool          string            `json:"tool"`               // module path and version of the processor
+	Revision      string            `json:"revision,omitempty"` // VCS revision the processor was built from
+	Started       string            `json:"started,omitempty"`  // in UTC; omitted under Config.Deterministic
+	Finished      string            `json:"finished,omitempty"`
+	Flags         map[string]string `json:"flags,omitempty"` // the flags set on the command line
+	Inputs        []string          `json:"inputs"`          // the documents, in the order processed
+	DataDir       string            `json:"data_dir"`
+	Data          []publishPack     `json:"data"` // the checksums of the data packs and layers
+}
+
+// toolVersion returns the module path and version of the processor and the
+// VCS revision it was built from, as far as the build recorded them
+func toolVersion() (tool, revision string) {
+	tool = "perseus_citation_linker"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		tool = info.Main.Path + " " + info.Main.Version
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				revision = setting.Value
+			}
+		}
+	}
+	return tool, revision
+}
+
+// setFlags returns the flags of a flag set that were set, with their values
+func setFlags(fs *flag.FlagSet) map[string]string {
+	flags := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		flags[f.Name] = f.Value.String()
+	})
+	return flags
+}
+
+// runFileName returns the name run.json is written under, with the time
+// the run started in it under Config.TimestampOutput. It is never
+// compressed.
+func (cp *CitationProcessor) runFileName() string {
+	return cp.stampedName(runFile)
+}
+
+// writeRunMetadata writes run.json to dir, for a run that processed inputs.
+// A deterministic run leaves out the times it started and finished, so that
+// its run.json is the same as every other run's of the same input.
+func (cp *CitationProcessor) writeRunMetadata(dir string, inputs []string) error {
+	metadata := runMetadata{
+		SchemaVersion: outputSchemaVersion,
+		Flags:         cp.Config.RunFlags,
+		Inputs:        inputs,
+		DataDir:       loader.FindDataDir(),
+	}
+	if !cp.Config.Deterministic {
+		metadata.Started = cp.startedAt.UTC().Format(time.RFC3339)
+		metadata.Finished = time.Now().UTC().Format(time.RFC3339)
+	}
+	metadata.Tool, metadata.Revision = toolVersion()
+	if metadata.Inputs == nil {
+		metadata.Inputs = []string{}
+	}
+	for _, pack := range cp.Resolver.Data.Summary() {
+		metadata.Data = append(metadata.Data, publishPack{Pack: pack.Pack, File: pack.File, Checksum: pack.Checksum, SchemaVersion: pack.SchemaVersion})
+	}
+	content, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, cp.runFileName()), append(content, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", runFile, err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// scaifeBaseURL is the Scaife Viewer instance citations link to
+const scaifeBaseURL = "https://scaife.perseus.org"
+
+// scaifeURL returns a Scaife Viewer link for a CTS URN, or "" if urn is not
+// one. URNs naming an edition open in the reader, at the cited passage if
+// there is one; work- and group-level URNs open the work's library page,
+// which lists its editions.
+func scaifeURL(urn string) string {
+	parts := strings.SplitN(urn, ":", 5)
+	if len(parts) < 4 || parts[0] != "urn" || parts[1] != "cts" || parts[3] == "" {
+		return ""
+	}
+	work := strings.Join(parts[:4], ":")
+	if strings.Count(parts[3], ".") < 2 {
+		return scaifeBaseURL + "/library/" + url.PathEscape(work) + "/"
+	}
+	if len(parts) == 5 {
+		if passage := scaifePassage(parts[4]); passage != "" {
+			work += ":" + passage
+		}
+	}
+	return scaifeBaseURL + "/reader/" + url.PathEscape(work) + "/"
+}
+
+// scaifePassage fits a passage to what the reader accepts: "ff" is dropped,
+// since the reader cannot open an unbounded range, and the end of a range
+// that leaves out leading levels gets them from the start, so "1.1-10"
+// becomes "1.1-1.10"
+func scaifePassage(passage string) string {
+	passage = strings.TrimSuffix(passage, "ff")
+	start, end, isRange := strings.Cut(passage, "-")
+	if !isRange || end == "" {
+		return start
+	}
+	startLevels := strings.Split(start, ".")
+	endLevels := strings.Split(end, ".")
+	if missing := len(startLevels) - len(endLevels); missing > 0 {
+		end = strings.Join(append(startLevels[:missing:missing], endLevels...), ".")
+	}
+	return start + "-" + end
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"time"
+)
+
+// selfCheckSample is a resolved citation kept for re-resolution at the end
+// of a run, with the inputs its resolution depended on
+type selfCheckSample struct {
+	Ref       string
+	Context   string
+	Filename  string
+	DocCitURN string
+	URN       string
+}
+
+// selfChecker keeps a uniform random sample of the citations resolved in a
+// run by reservoir sampling, so memory stays bounded however long the run.
+// The seed is logged, and passing it back with -self-check-seed samples the
+// same citations of the same input.
+type selfChecker struct {
+	size    int
+	seed    int64
+	rng     *rand.Rand
+	seen    int
+	samples []selfCheckSample
+	sampled map[string]int // index of each sample by doc_cit_urn, to number it
+}
+
+// deterministicSeed seeds the self-check of a deterministic run given no seed
+const deterministicSeed = 1
+
+// newSelfChecker returns a checker sampling size citations, seeded from the
+// clock when seed is 0
+func newSelfChecker(size int, seed int64) *selfChecker {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &selfChecker{
+		size:    size,
+		seed:    seed,
+		rng:     rand.New(rand.NewSource(seed)),
+		sampled: make(map[string]int),
+	}
+}
+
+// observe offers a resolved citation to the sample
+func (sc *selfChecker) observe(sample selfCheckSample) {
+	sc.seen++
+	if len(sc.samples) < sc.size {
+		sc.sampled[sample.DocCitURN] = len(sc.samples)
+		sc.samples = append(sc.samples, sample)
+		return
+	}
+	if i := sc.rng.Intn(sc.seen); i < sc.size {
+		delete(sc.sampled, sc.samples[i].DocCitURN)
+		sc.sampled[sample.DocCitURN] = i
+		sc.samples[i] = sample
+	}
+}
+
+// renumber gives a sampled citation the doc_cit_urn numberCitations gave it
+// in place of the one it was extracted with
+func (sc *selfChecker) renumber(extracted, numbered string) {
+	if i, exists := sc.sampled[extracted]; exists {
+		delete(sc.sampled, extracted)
+		sc.samples[i].DocCitURN = numbered
+	}
+}
+
+// runSelfCheck re-resolves the sampled citations and compares the URNs with
+// those written during the run. Each mismatch is logged as an error, since
+// it means resolution depends on something other than its inputs, such as
+// shared state mutated during the run.
+func (cp *CitationProcessor) runSelfCheck() error {
+	sc := cp.selfCheck
+	if sc == nil {
+		return nil
+	}
+	// citations whose work the prior chose are not sampled, and by now the
+	// prior may decide ties it could not when the rest were first seen, so
+	// re-resolve without it
+	prior := cp.Resolver.Prior
+	cp.Resolver.Prior = nil
+	defer func() { cp.Resolver.Prior = prior }()
+
+	mismatches := 0
+	for _, sample := range sc.samples {
+		urn := cp.Resolver.Resolve(sample.Ref, sample.Context, sample.Filename).URN
+		if urn != sample.URN {
+			mismatches++
+			slog.Error("self-check mismatch", "doc_cit_urn", sample.DocCitURN, "ref", sample.Ref,
+				"urn", sample.URN, "re-resolved", urn)
+		}
+	}
+	if mismatches > 0 {
+		return fmt.Errorf("self-check: %d of %d sampled citations resolved differently on re-resolution (seed %d)",
+			mismatches, len(sc.samples), sc.seed)
+	}
+	slog.Info("self-check passed", "sampled", len(sc.samples), "resolved", sc.seen, "seed", sc.seed)
+	return nil
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+
+	"google.golang.org/grpc"
+
+	"perseus_citation_linker/pkg/citationpb"
+	"perseus_citation_linker/pkg/loader"
+	"perseus_citation_linker/pkg/resolver"
+)
+
+// runServe implements the serve subcommand, which runs the resolver as a
+// gRPC service (see pkg/citationpb/citation.proto) so that pipelines in other
+// languages can keep one loaded resolver and stream references to it
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:50051", "Address to listen on")
+	noCitTags := fs.Bool("nocit", false, "Extract with <bibl> tags only, as for processing runs")
+	aggressive := fs.Bool("aggressive", false, "Also scan running text in Extract, as for processing runs")
+	corrections := fs.String("corrections", "", "CSV or JSON corrections table")
+	workFallback := fs.String("work-fallback", "guess", "When a known author's work is not found: guess, author or none")
+	passageFormat := fs.String("passage-format", "preserve", "How passage numbers are written in URNs: preserve, plain or padded")
+	passageWidth := fs.Int("passage-width", resolver.DefaultPassageWidth, "Digits each passage level is padded to with -passage-format padded")
+	provisional := fs.Bool("provisional", false, "Mint provisional URNs for a known author's unknown work")
+	scaifeURLs := fs.Bool("scaife-urls", false, "Add Scaife Viewer links to extracted citations")
+	fuzzyAuthors := fs.Float64("fuzzy-authors", 0, "Jaro-Winkler similarity for matching misspelled authors (0: off)")
+	provenance := fs.Bool("provenance", false, "Return the data file entries that mapped each author and work")
+	fs.Parse(args)
+
+	cp, err := NewCitationProcessor(Config{
+		UseCitTags:      !*noCitTags,
+		Aggressive:      *aggressive,
+		CorrectionsFile: *corrections,
+		WorkFallback:    *workFallback,
+		PassageFormat:   *passageFormat,
+		PassageWidth:    *passageWidth,
+		Provisional:     *provisional,
+		ScaifeURLs:      *scaifeURLs,
+		FuzzyAuthors:    *fuzzyAuthors,
+		Provenance:      *provenance,
+	})
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	server := grpc.NewServer()
+	citationpb.RegisterResolverServer(server, &resolverServer{cp: cp})
+	slog.Info("serving", "addr", listener.Addr().String())
+	return server.Serve(listener)
+}
+
+// resolverServer implements the gRPC Resolver service. Resolution only reads
+// the loaded data, so Resolve and ResolveBatch calls run concurrently;
+// Extract calls are serialized, since extraction numbers citations on the
+// processor.
+type resolverServer struct {
+	citationpb.UnimplementedResolverServer
+	cp        *CitationProcessor
+	extractMu sync.Mutex
+}
+
+func (s *resolverServer) Resolve(ctx context.Context, req *citationpb.ResolveRequest) (*citationpb.ResolveResponse, error) {
+	return s.resolve(req), nil
+}
+
+func (s *resolverServer) ResolveBatch(stream citationpb.Resolver_ResolveBatchServer) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(s.resolve(req)); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *resolverServer) Extract(ctx context.Context, req *citationpb.ExtractRequest) (*citationpb.ExtractResponse, error) {
+	s.extractMu.Lock()
+	citations := s.cp.ExtractCitations(req.Xml, req.Filename)
+	s.extractMu.Unlock()
+
+	resp := &citationpb.ExtractResponse{Citations: make([]*citationpb.Citation, len(citations))}
+	for i, citation := range citations {
+		resp.Citations[i] = citationMessage(citation)
+	}
+	return resp, nil
+}
+
+// resolve derives the reference from a request's n attribute and bibl and
+// resolves it as a processing run would
+func (s *resolverServer) resolve(req *citationpb.ResolveRequest) *citationpb.ResolveResponse {
+	ref, commentator, note, edition := s.cp.reference(req.NAttrib, req.Bibl)
+	res := s.cp.resolve(ref, "", req.Context, req.Filename)
+	return &citationpb.ResolveResponse{
+		Id:              req.Id,
+		Ref:             ref,
+		Urn:             res.URN,
+		Warnings:        res.Warnings,
+		Reason:          string(res.Reason),
+		Scheme:          res.Scheme,
+		Candidates:      candidateMessages(res.Candidates),
+		Works:           res.Works,
+		Qualifier:       res.Qualifier,
+		FallbackUsed:    res.Fallback,
+		Locus:           locusMessages(res.Locus),
+		Commentator:     commentator,
+		Note:            note,
+		Edition:         edition,
+		Corrected:       res.Corrected,
+		ProvisionalUrn:  res.Provisional,
+		FuzzyAuthor:     authorMatchMessage(res.FuzzyAuthor),
+		Fragment:        fragmentMessage(res.Fragment),
+		Provenance:      dataEntryMessages(res.Provenance),
+		ResolutionStep:  res.Step,
+		StepConfidence:  res.Confidence,
+		SchemeViolation: res.SchemeViolation,
+		PriorUsed:       res.Prior,
+		Documentary:     documentaryMessage(res.Documentary),
+		ExternalId:      res.ExternalID,
+	}
+}
+
+// citationMessage converts a citation record to its message
+func citationMessage(citation Citation) *citationpb.Citation {
+	return &citationpb.Citation{
+		NAttrib:         citation.NAttrib,
+		Bibl:            citation.Bibl,
+		Ref:             citation.Ref,
+		Urn:             citation.URN,
+		Quote:           citation.Quote,
+		XmlContext:      citation.XMLContext,
+		Filename:        citation.Filename,
+		DocCitUrn:       citation.DocCitURN,
+		Pattern:         citation.Pattern,
+		Warnings:        citation.Warnings,
+		Scheme:          citation.Scheme,
+		Candidates:      candidateMessages(citation.Candidates),
+		Works:           citation.Works,
+		Qualifier:       citation.Qualifier,
+		FallbackUsed:    citation.Fallback,
+		Locus:           locusMessages(citation.Locus),
+		Commentator:     citation.Commentator,
+		Note:            citation.Note,
+		Edition:         citation.Edition,
+		Corrected:       citation.Corrected,
+		PreResolved:     citation.PreResolved,
+		ScaifeUrl:       citation.ScaifeURL,
+		ProvisionalUrn:  citation.Provisional,
+		InApparatus:     citation.InApparatus,
+		Lemma:           citation.Lemma,
+		FuzzyAuthor:     authorMatchMessage(citation.FuzzyAuthor),
+		SectionHeading:  citation.Heading,
+		PriorUsed:       citation.PriorUsed,
+		Fragment:        fragmentMessage(citation.Fragment),
+		InQuote:         citation.InQuote,
+		Provenance:      dataEntryMessages(citation.Provenance),
+		ResolutionStep:  citation.Step,
+		StepConfidence:  citation.Confidence,
+		SchemeViolation: citation.Violation,
+		Document:        documentMessage(citation.Document),
+		QuotePosition:   citation.QuotePos,
+		CommentedRange:  citation.Commented,
+		CitationType:    citation.Type,
+		DocumentId:      citation.DocumentID,
+		GroupId:         citation.Group,
+		Ellipsis:        citation.Ellipsis,
+		Documentary:     documentaryMessage(citation.Documentary),
+		ExternalId:      citation.ExternalID,
+		Secondary:       secondaryMessage(citation.Secondary),
+	}
+}
+
+func candidateMessages(candidates []resolver.Candidate) []*citationpb.Candidate {
+	var messages []*citationpb.Candidate
+	for _, candidate := range candidates {
+		messages = append(messages, &citationpb.Candidate{Urn: candidate.URN, Score: candidate.Score})
+	}
+	return messages
+}
+
+func authorMatchMessage(match *resolver.AuthorMatch) *citationpb.AuthorMatch {
+	if match == nil {
+		return nil
+	}
+	return &citationpb.AuthorMatch{
+		Input:        match.Input,
+		Matched:      match.Matched,
+		EditDistance: int32(match.Distance),
+		Score:        match.Score,
+	}
+}
+
+func fragmentMessage(fragment *resolver.Fragment) *citationpb.Fragment {
+	if fragment == nil {
+		return nil
+	}
+	return &citationpb.Fragment{
+		Collection: fragment.Collection,
+		Author:     fragment.Author,
+		Name:       fragment.Name,
+		Kind:       fragment.Kind,
+		Number:     fragment.Number,
+	}
+}
+
+func documentaryMessage(text *resolver.DocumentaryText) *citationpb.DocumentaryText {
+	if text == nil {
+		return nil
+	}
+	return &citationpb.DocumentaryText{
+		Corpus:  text.Corpus,
+		Kind:    text.Kind,
+		Volume:  text.Volume,
+		Edition: text.Edition,
+		Number:  text.Number,
+	}
+}
+
+func secondaryMessage(secondary *resolver.SecondaryReference) *citationpb.SecondaryReference {
+	if secondary == nil {
+		return nil
+	}
+	return &citationpb.SecondaryReference{
+		Author: secondary.Author,
+		Year:   secondary.Year,
+		Title:  secondary.Title,
+		Pages:  secondary.Pages,
+		AdLoc:  secondary.AdLoc,
+	}
+}
+
+func documentMessage(document *DocumentMetadata) *citationpb.DocumentMetadata {
+	if document == nil {
+		return nil
+	}
+	return &citationpb.DocumentMetadata{
+		Urn:    document.URN,
+		Title:  document.Title,
+		Author: document.Author,
+		Editor: document.Editor,
+		Date:   document.Date,
+	}
+}
+
+func dataEntryMessages(entries []loader.DataEntry) []*citationpb.DataEntry {
+	var messages []*citationpb.DataEntry
+	for _, entry := range entries {
+		messages = append(messages, &citationpb.DataEntry{File: entry.File, Entry: entry.Entry})
+	}
+	return messages
+}
+
+func locusMessages(locus []resolver.LocusLevel) []*citationpb.LocusLevel {
+	var messages []*citationpb.LocusLevel
+	for _, level := range locus {
+		messages = append(messages, &citationpb.LocusLevel{Name: level.Name, Value: level.Value})
+	}
+	return messages
+}
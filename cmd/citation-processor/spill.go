@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+
+	"perseus_citation_linker/pkg/resolver"
+)
+
+const (
+	// spillBatch is the citations read back from a spill file at a time
+	spillBatch = 1000
+
+	// citationOverhead is the bytes a citation is taken to use besides its
+	// strings, for the memory limit
+	citationOverhead = 512
+
+	// bloomHashes is the hashes a key sets in a Bloom filter; with a filter of
+	// 16 bits a key or more, 7 keeps false positives below 1 in 1000
+	bloomHashes = 7
+
+	// minBloomBytes is the least a Bloom filter is given, however low the
+	// memory limit
+	minBloomBytes = 64 << 10
+)
+
+// citationSet holds the citations of a document, dropping repeats, within a
+// memory limit, and passes them on in document order. Once the citations and their
+// keys pass the limit they are spilled to a temporary file, and from then on
+// repeats are told by a Bloom filter taking a quarter of the limit in place
+// of the keys, which may take a citation for a repeat it is not (rarely: see
+// falsePositiveRate). Without a limit everything is held in memory and
+// repeats are told exactly.
+type citationSet struct {
+	limit int64  // bytes of citations and keys held before spilling (0: no limit)
+	dir   string // of the spill file ("": the system's temporary directory)
+
+	held  []Citation
+	size  int64
+	keys  map[string]bool
+	bloom *bloomFilter
+
+	spill   *os.File
+	writer  *bufio.Writer
+	spilled int
+	runs    []spillRun // the batches spilled, each in document order
+	dropped int        // repeats dropped, some perhaps distinct once the Bloom filter is used
+	err     error
+}
+
+func newCitationSet(limit int64, dir string) *citationSet {
+	return &citationSet{limit: limit, dir: dir, keys: make(map[string]bool)}
+}
+
+// citationKey identifies the repeats of a citation found by several of the
+// comprehensive extraction patterns
+func citationKey(citation Citation) string {
+	return citation.Bibl + "|" + citation.NAttrib + "|" + citation.Quote
+}
+
+// add adds a citation, repeated or not
+func (s *citationSet) add(citation Citation) {
+	if s.err != nil {
+		return
+	}
+	s.held = append(s.held, citation)
+	if s.limit > 0 {
+		s.size += citationSize(citation)
+		s.checkLimit()
+	}
+}
+
+// addUnique adds a citation unless one with the same key was added before,
+// reporting whether it was
+func (s *citationSet) addUnique(citation Citation) bool {
+	key := citationKey(citation)
+	if s.bloom != nil {
+		if s.bloom.has(key) {
+			s.dropped++
+			return false
+		}
+		s.bloom.add(key)
+	} else {
+		if s.keys[key] {
+			s.dropped++
+			return false
+		}
+		s.keys[key] = true
+		if s.limit > 0 {
+			s.size += int64(len(key)) + 48
+		}
+	}
+	s.add(citation)
+	return true
+}
+
+// checkLimit spills the citations held once they pass the memory limit,
+// moving the keys to a Bloom filter the first time
+func (s *citationSet) checkLimit() {
+	if s.size <= s.limit {
+		return
+	}
+	if s.bloom == nil {
+		s.bloom = newBloomFilter(max64(s.limit/4, minBloomBytes))
+		for key := range s.keys {
+			s.bloom.add(key)
+		}
+		s.keys = nil
+		s.limit -= s.limit / 4
+	}
+	if err := s.spillHeld(); err != nil {
+		s.err = err
+	}
+}
+
+// spilledCitation is a citation as written to a spill file, with the fields
+// that are not part of the output
+type spilledCitation struct {
+	Citation
+	Span   [2]int                 `json:"span"`
+	Reason resolver.FailureReason `json:"reason,omitempty"`
+}
+
+// spillRun is a batch of citations written to a spill file
+type spillRun struct {
+	offset int64 // in the spill file
+	count  int
+}
+
+func (s *citationSet) spillHeld() error {
+	if s.spill == nil {
+		file, err := os.CreateTemp(s.dir, "citations-*.jsonl")
+		if err != nil {
+			return fmt.Errorf("failed to create spill file: %w", err)
+		}
+		s.spill, s.writer = file, bufio.NewWriter(file)
+		slog.Debug("spilling citations to disk", "file", file.Name(), "limit", s.limit)
+	}
+	if err := s.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to spill citations: %w", err)
+	}
+	offset, err := s.spill.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("failed to spill citations: %w", err)
+	}
+	sortByPosition(s.held)
+	encoder := json.NewEncoder(s.writer)
+	for _, citation := range s.held {
+		record := spilledCitation{Citation: citation, Span: [2]int{citation.span.start, citation.span.end}, Reason: citation.reason}
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("failed to spill citations: %w", err)
+		}
+	}
+	s.runs = append(s.runs, spillRun{offset: offset, count: len(s.held)})
+	s.spilled += len(s.held)
+	s.held, s.size = nil, 0
+	return nil
+}
+
+// sortByPosition puts citations in document order, by where they start and
+// then end, so that the order the extraction patterns found them in, which
+// is the order of the patterns, does not reach the output. Citations at the
+// same place, as the members of a list, keep the order they were found in.
+func sortByPosition(citations []Citation) {
+	sort.SliceStable(citations, func(i, j int) bool {
+		if citations[i].span.start != citations[j].span.start {
+			return citations[i].span.start < citations[j].span.start
+		}
+		return citations[i].span.end < citations[j].span.end
+	})
+}
+
+// each passes the citations to batch in document order (see
+// sortByPosition): the runs spilled, read back from the spill file, merged
+// with those held
+func (s *citationSet) each(batch func([]Citation) error) error {
+	if s.err != nil {
+		return s.err
+	}
+	sortByPosition(s.held)
+	if s.spill == nil {
+		if len(s.held) > 0 {
+			return batch(s.held)
+		}
+		return nil
+	}
+	if err := s.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to spill citations: %w", err)
+	}
+	runs := make([]*spillReader, 0, len(s.runs)+1)
+	for _, run := range s.runs {
+		reader := &spillReader{decoder: json.NewDecoder(bufio.NewReader(io.NewSectionReader(s.spill, run.offset, math.MaxInt64-run.offset))), left: run.count}
+		if err := reader.next(); err != nil {
+			return err
+		}
+		runs = append(runs, reader)
+	}
+	runs = append(runs, &spillReader{held: s.held})
+	if err := runs[len(runs)-1].next(); err != nil {
+		return err
+	}
+
+	citations := make([]Citation, 0, spillBatch)
+	for {
+		// the earliest head, an earlier run winning a tie
+		var first *spillReader
+		for _, run := range runs {
+			if run.done {
+				continue
+			}
+			if first == nil || run.head.span.start < first.head.span.start ||
+				run.head.span.start == first.head.span.start && run.head.span.end < first.head.span.end {
+				first = run
+			}
+		}
+		if first == nil {
+			break
+		}
+		citations = append(citations, first.head)
+		if err := first.next(); err != nil {
+			return err
+		}
+		if len(citations) == spillBatch {
+			if err := batch(citations); err != nil {
+				return err
+			}
+			citations = make([]Citation, 0, spillBatch)
+		}
+	}
+	if len(citations) > 0 {
+		return batch(citations)
+	}
+	return nil
+}
+
+// spillReader reads a spilled run, or the citations held, a citation at a
+// time for each to merge
+type spillReader struct {
+	decoder *json.Decoder // nil for the citations held
+	left    int           // citations of a spilled run not yet read
+	held    []Citation
+
+	head Citation
+	done bool
+}
+
+func (r *spillReader) next() error {
+	if r.decoder == nil {
+		if len(r.held) == 0 {
+			r.done = true
+			return nil
+		}
+		r.head, r.held = r.held[0], r.held[1:]
+		return nil
+	}
+	if r.left == 0 {
+		r.done = true
+		return nil
+	}
+	var record spilledCitation
+	if err := r.decoder.Decode(&record); err != nil {
+		return fmt.Errorf("failed to read spilled citations: %w", err)
+	}
+	r.left--
+	r.head = record.Citation
+	r.head.span = byteSpan{start: record.Span[0], end: record.Span[1]}
+	r.head.reason = record.Reason
+	return nil
+}
+
+// close removes the spill file, if there is one
+func (s *citationSet) close() {
+	if s.spill == nil {
+		return
+	}
+	if s.dropped > 0 {
+		slog.Debug("dropped repeated citations after spilling", "citations", s.dropped,
+			"false_positive_rate", s.bloom.falsePositiveRate())
+	}
+	s.spill.Close()
+	os.Remove(s.spill.Name())
+	s.spill = nil
+}
+
+// citationSize estimates the memory a citation takes
+func citationSize(citation Citation) int64 {
+	size := len(citation.NAttrib) + len(citation.Bibl) + len(citation.Ref) + len(citation.URN) +
+		len(citation.Quote) + len(citation.XMLContext) + len(citation.Filename) + len(citation.DocCitURN) +
+		len(citation.Lemma) + len(citation.Heading)
+	for _, warning := range citation.Warnings {
+		size += len(warning)
+	}
+	size += len(citation.Candidates) * 128
+	return int64(size + citationOverhead)
+}
+
+// bloomFilter is a set of strings in fixed memory that may report a string
+// it does not hold as held, but never the reverse
+type bloomFilter struct {
+	bits []uint64
+	n    int // strings added
+}
+
+func newBloomFilter(bytes int64) *bloomFilter {
+	return &bloomFilter{bits: make([]uint64, max64(bytes/8, 1))}
+}
+
+// positions returns the two hashes from which the bits of key are taken
+func (b *bloomFilter) positions(key string) (uint64, uint64) {
+	h := fnv.New128a()
+	h.Write([]byte(key))
+	sum := h.Sum(nil)
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:]) | 1
+}
+
+func (b *bloomFilter) add(key string) {
+	h1, h2 := b.positions(key)
+	m := uint64(len(b.bits)) * 64
+	for i := uint64(0); i < bloomHashes; i++ {
+		bit := (h1 + i*h2) % m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+	b.n++
+}
+
+func (b *bloomFilter) has(key string) bool {
+	h1, h2 := b.positions(key)
+	m := uint64(len(b.bits)) * 64
+	for i := uint64(0); i < bloomHashes; i++ {
+		bit := (h1 + i*h2) % m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// falsePositiveRate estimates the chance that has reports a string not
+// added as held, given the strings added so far
+func (b *bloomFilter) falsePositiveRate() float64 {
+	m := float64(len(b.bits) * 64)
+	return math.Pow(1-math.Exp(-bloomHashes*float64(b.n)/m), bloomHashes)
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
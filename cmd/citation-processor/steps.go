@@ -0,0 +1,25 @@
+package main
+
+import "perseus_citation_linker/pkg/resolver"
+
+// resolutionSteps are the custom resolution steps given to every processor's
+// resolver
+var resolutionSteps resolver.ResolverChain
+
+// RegisterResolutionStep adds a corpus-specific resolution step, run before
+// or after the built-in heuristics, to those of every processor created
+// afterwards. Citations it resolves record its name as their
+// resolution_step, with its confidence.
+func RegisterResolutionStep(name string, stage resolver.Stage, step resolver.ResolutionStep) {
+	resolutionSteps.Register(name, stage, step)
+}
+
+// registeredSteps returns the chain of registered steps, nil if there are
+// none
+func registeredSteps() *resolver.ResolverChain {
+	if resolutionSteps.Len() == 0 {
+		return nil
+	}
+	chain := resolutionSteps
+	return &chain
+}
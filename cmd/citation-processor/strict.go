@@ -0,0 +1,69 @@
+package main
+
+import (
+	"regexp"
+)
+
+// strictCitationParents lists, for each element that TEI P5 defines as a
+// citation, the parent elements under which it is accepted in strict mode
+var strictCitationParents = map[string]map[string]bool{
+	"cit": {
+		"p": true, "ab": true, "note": true, "div": true, "item": true,
+		"cell": true, "quote": true, "q": true, "l": true, "sp": true,
+	},
+	"bibl": {
+		"cit": true, "p": true, "ab": true, "note": true, "listBibl": true,
+		"item": true, "cell": true, "q": true,
+	},
+}
+
+// tagRegex matches comments, processing instructions and start/end/empty tags
+var tagRegex = regexp.MustCompile(`(?s)<!--.*?-->|<\?.*?\?>|<(/?)([A-Za-z_][\w:.-]*)[^>]*?(/?)>`)
+
+// parentIndex maps the byte offset of every start tag in a document to the
+// name of its parent element
+type parentIndex map[int]string
+
+// buildParentIndex scans the document once, tracking open elements on a stack
+func buildParentIndex(xmlContent string) parentIndex {
+	index := make(parentIndex)
+	var stack []string
+	for _, loc := range tagRegex.FindAllStringSubmatchIndex(xmlContent, -1) {
+		if loc[4] < 0 {
+			// comment or processing instruction
+			continue
+		}
+		name := xmlContent[loc[4]:loc[5]]
+		if loc[3] > loc[2] {
+			// end tag: pop up to and including the matching start tag
+			for len(stack) > 0 {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				if top == name {
+					break
+				}
+			}
+			continue
+		}
+		if len(stack) > 0 {
+			index[loc[0]] = stack[len(stack)-1]
+		} else {
+			index[loc[0]] = ""
+		}
+		if loc[7] == loc[6] {
+			// not self-closing
+			stack = append(stack, name)
+		}
+	}
+	return index
+}
+
+// allowsCitation reports whether the element starting at offset is a TEI
+// citation element sitting under one of its whitelisted parents
+func (pi parentIndex) allowsCitation(element string, offset int) bool {
+	parent, exists := pi[offset]
+	if !exists {
+		return false
+	}
+	return strictCitationParents[element][parent]
+}
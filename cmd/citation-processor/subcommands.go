@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// subcommands maps the first command-line argument to its handler. Any other
+// invocation is treated as flags for a normal processing run.
+var subcommands = map[string]func(args []string) error{
+	"bench":           runBench,
+	"compat-check":    runCompatCheck,
+	"data":            runData,
+	"diff":            runDiff,
+	"preview":         runPreview,
+	"publish":         runPublish,
+	"repl":            runRepl,
+	"harvest-aliases": runHarvestAliases,
+	"serve":           runServe,
+	"sync-catalog":    runSyncCatalog,
+	"triage":          runTriage,
+}
+
+// runSubcommand runs the subcommand named by args[0], if there is one, and
+// reports whether it did
+func runSubcommand(args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+	if args[0] == "help" {
+		printSubcommands()
+		return true, nil
+	}
+	run, exists := subcommands[args[0]]
+	if !exists {
+		return false, nil
+	}
+	if err := run(args[1:]); err != nil {
+		return true, fmt.Errorf("%s: %w", args[0], err)
+	}
+	return true, nil
+}
+
+func printSubcommands() {
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(os.Stderr, "Subcommands (run with -h for their flags):")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", name)
+	}
+}
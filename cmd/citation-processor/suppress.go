@@ -0,0 +1,166 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// suppressionRegex matches the marks editors put in the TEI for known false
+// positives, as a comment or a processing instruction: "ignore" suppresses
+// the element that follows the mark, "ignore-block" the element containing it
+//
+//	<!-- citation-processor:ignore --> <bibl>Ibid. 3</bibl>
+//	<p><?citation-processor ignore-block?> ... </p>
+var suppressionRegex = regexp.MustCompile(`^(?:<!--\s*citation-processor:(ignore(?:-block)?)\s*-->|<\?citation-processor\s+(ignore(?:-block)?)\s*\?>)$`)
+
+// openElement is an element on the stack of suppressIgnored's scan
+type openElement struct {
+	name       string
+	start      int  // offset of the start tag, or of an "ignore" mark before it
+	suppressed bool // the element is marked to be ignored
+}
+
+// suppressIgnored blanks out the elements marked with suppression comments
+// or processing instructions, keeping offsets unchanged as maskMarkup does,
+// so no citation is extracted from them. It returns the masked content and
+// the number of elements suppressed.
+func suppressIgnored(xmlContent string) (string, int) {
+	if !strings.Contains(xmlContent, "citation-processor") {
+		return xmlContent, 0
+	}
+
+	var stack []openElement
+	var ranges [][2]int
+	pending := -1 // offset of an "ignore" mark waiting for its element
+	for _, loc := range tagRegex.FindAllStringSubmatchIndex(xmlContent, -1) {
+		if loc[4] < 0 {
+			match := suppressionRegex.FindStringSubmatch(xmlContent[loc[0]:loc[1]])
+			if match == nil {
+				continue
+			}
+			switch match[1] + match[2] {
+			case "ignore":
+				pending = loc[0]
+			case "ignore-block":
+				if len(stack) > 0 {
+					stack[len(stack)-1].suppressed = true
+				}
+			}
+			continue
+		}
+
+		name := xmlContent[loc[4]:loc[5]]
+		if loc[3] > loc[2] {
+			// end tag: pop up to and including the matching start tag
+			pending = -1
+			for len(stack) > 0 {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				if top.suppressed {
+					ranges = append(ranges, [2]int{top.start, loc[1]})
+				}
+				if top.name == name {
+					break
+				}
+			}
+			continue
+		}
+
+		element := openElement{name: name, start: loc[0]}
+		if pending >= 0 {
+			element.start, element.suppressed = pending, true
+			pending = -1
+		}
+		if loc[7] > loc[6] {
+			// self-closing
+			if element.suppressed {
+				ranges = append(ranges, [2]int{element.start, loc[1]})
+			}
+			continue
+		}
+		stack = append(stack, element)
+	}
+	for _, element := range stack {
+		if element.suppressed {
+			// never closed: suppress to the end of the document
+			ranges = append(ranges, [2]int{element.start, len(xmlContent)})
+		}
+	}
+	return blankRanges(xmlContent, ranges), len(ranges)
+}
+
+// defaultExcludedElements are the elements whose subtrees are not scanned for
+// citations unless Config.ExcludeElements says otherwise: the bibliography of
+// the teiHeader's source description describes the edition, it does not cite
+// it
+const defaultExcludedElements = "teiHeader"
+
+// parseExcludedElements reads a comma-separated list of element names, ""
+// being the default and "none" no element at all
+func parseExcludedElements(spec string) map[string]bool {
+	if spec == "" {
+		spec = defaultExcludedElements
+	}
+	excluded := make(map[string]bool)
+	for _, name := range strings.Split(spec, ",") {
+		if name = strings.TrimSpace(name); name != "" && name != "none" {
+			excluded[name] = true
+		}
+	}
+	return excluded
+}
+
+// excludeRegions blanks out comments and the subtrees of the excluded
+// elements, as suppressIgnored blanks out marked elements, so that
+// commented-out markup and header bibliographies yield no citations. It
+// returns the masked content and the number of regions blanked out.
+func excludeRegions(xmlContent string, excluded map[string]bool) (string, int) {
+	var ranges [][2]int
+	var name string // the excluded element being skipped
+	start, depth := 0, 0
+	for _, loc := range tagRegex.FindAllStringSubmatchIndex(xmlContent, -1) {
+		if loc[4] < 0 {
+			if depth == 0 && strings.HasPrefix(xmlContent[loc[0]:loc[1]], "<!--") {
+				ranges = append(ranges, [2]int{loc[0], loc[1]})
+			}
+			continue
+		}
+
+		tagName := xmlContent[loc[4]:loc[5]]
+		switch {
+		case loc[3] > loc[2]:
+			if depth > 0 && tagName == name {
+				if depth--; depth == 0 {
+					ranges = append(ranges, [2]int{start, loc[1]})
+				}
+			}
+		case loc[7] > loc[6]:
+			// self-closing: nothing inside to skip
+		case depth > 0:
+			if tagName == name {
+				depth++
+			}
+		case excluded[tagName]:
+			name, start, depth = tagName, loc[0], 1
+		}
+	}
+	if depth > 0 {
+		// never closed: exclude to the end of the document
+		ranges = append(ranges, [2]int{start, len(xmlContent)})
+	}
+	return blankRanges(xmlContent, ranges), len(ranges)
+}
+
+// blankRanges replaces the bytes of each range with spaces
+func blankRanges(xmlContent string, ranges [][2]int) string {
+	if len(ranges) == 0 {
+		return xmlContent
+	}
+	masked := []byte(xmlContent)
+	for _, r := range ranges {
+		for i := r[0]; i < r[1]; i++ {
+			masked[i] = ' '
+		}
+	}
+	return string(masked)
+}
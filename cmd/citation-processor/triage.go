@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"perseus_citation_linker/pkg/loader"
+	"perseus_citation_linker/pkg/resolver"
+)
+
+// triageExamples is the default number of example bibls shown per cluster
+const triageExamples = 3
+
+var (
+	// numbers, with a Stephanus or Bekker letter, as "42", "285b" or "1447a"
+	triageNumberRegex = regexp.MustCompile(`\b\d+[a-e]?\b`)
+	// ranges of folded numbers, as "N-N" or "N–N"
+	triageRangeRegex = regexp.MustCompile(`N\s*[-–—]\s*N`)
+)
+
+// triageCluster is the unresolved citations whose bibls share a pattern
+type triageCluster struct {
+	Pattern    string         `json:"pattern"` // the bibl with its numbers written N
+	Count      int            `json:"count"`
+	Share      float64        `json:"share"`      // of the unresolved citations
+	Cumulative float64        `json:"cumulative"` // share of this cluster and those ranked above it
+	Files      int            `json:"files"`
+	Reason     string         `json:"reason"`  // the commonest reason its references fail with the current data, or "resolved"
+	Reasons    map[string]int `json:"reasons"` // citations by reason
+	Action     string         `json:"action"`  // what would resolve them
+	Examples   []string       `json:"examples"`
+
+	files map[string]bool
+	parts map[string]resolver.ReferenceParts // of the first reference failing for each reason
+}
+
+// triageReport is the clusters of a set of unresolved citations, most
+// frequent first
+type triageReport struct {
+	Unresolved int              `json:"unresolved"`
+	Clusters   []*triageCluster `json:"clusters"`
+}
+
+// runTriage implements the triage subcommand, which clusters the bibls of
+// unresolved citations by their pattern, with the numbers written N, and
+// lists the clusters most frequent first, each with why its references fail
+// with the current data and what to add to the data files, as a worklist
+// for curation
+func runTriage(args []string) error {
+	fs := flag.NewFlagSet("triage", flag.ExitOnError)
+	dataDir := fs.String("data", "", "Data directory the references are tried against (default: discovered as for processing runs)")
+	top := fs.Int("top", 50, "Clusters listed, most frequent first (0: all)")
+	minCount := fs.Int("min-count", 1, "Least citations a cluster must have to be listed")
+	examples := fs.Int("examples", triageExamples, "Distinct example bibls shown per cluster")
+	asJSON := fs.Bool("json", false, "Write the worklist as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: citation-processor triage [flags] unresolved...")
+		fmt.Fprintln(fs.Output(), "unresolved are unresolved.jsonl files, possibly compressed, or output directories")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("want unresolved citations to triage")
+	}
+
+	citations, err := loadUnresolved(fs.Args())
+	if err != nil {
+		return err
+	}
+	if *dataDir == "" {
+		*dataDir = loader.FindDataDir()
+	}
+	urnResolver, err := resolver.NewURNResolverFromDir(*dataDir)
+	if err != nil {
+		return err
+	}
+	// a work guessed for a known author is no resolution
+	urnResolver.WorkFallback = resolver.FallbackNone
+	urnResolver.Chain = registeredSteps()
+
+	report := triage(urnResolver, citations, *examples)
+	report.limit(*top, *minCount)
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	report.writeText(stdout)
+	return nil
+}
+
+// loadUnresolved reads the citations of unresolved.jsonl files, or of the
+// unresolved files of output directories, timestamped or compressed ones
+// included
+func loadUnresolved(paths []string) ([]Citation, error) {
+	var citations []Citation
+	for _, path := range paths {
+		files := []string{path}
+		if info, err := os.Stat(path); err != nil {
+			return nil, err
+		} else if info.IsDir() {
+			files, _ = filepath.Glob(filepath.Join(path, "unresolved*.jsonl"))
+			compressed, _ := filepath.Glob(filepath.Join(path, "unresolved*.jsonl"+gzipExtension))
+			files = append(files, compressed...)
+			if len(files) == 0 {
+				return nil, fmt.Errorf("no unresolved citations in %s", path)
+			}
+			sort.Strings(files)
+		}
+		for _, file := range files {
+			err := readJSONL(file, func(line []byte) error {
+				var citation Citation
+				if err := json.Unmarshal(line, &citation); err != nil {
+					return err
+				}
+				if citation.URN == "" {
+					citations = append(citations, citation)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return citations, nil
+}
+
+// triagePattern returns the pattern a bibl is clustered by: lower-cased,
+// with its spacing collapsed, its numbers written N and a range of them
+// taken for one, so that "Schol. Ar. Eq. 42" and "Schol. Ar. Eq. 101–3"
+// fall together
+func triagePattern(bibl string) string {
+	pattern := strings.ToLower(strings.TrimSpace(whitespaceRegex.ReplaceAllString(bibl, " ")))
+	pattern = triageNumberRegex.ReplaceAllString(pattern, "N")
+	for triageRangeRegex.MatchString(pattern) {
+		pattern = triageRangeRegex.ReplaceAllString(pattern, "N")
+	}
+	return strings.TrimRight(pattern, " .,;:")
+}
+
+// triage clusters unresolved citations, trying the reference of each against
+// urnResolver to tell why it fails
+func triage(urnResolver *resolver.URNResolver, citations []Citation, examples int) *triageReport {
+	report := &triageReport{Unresolved: len(citations)}
+	clusters := make(map[string]*triageCluster)
+	reasons := make(map[string]resolver.FailureReason) // by reference, each tried once
+	for _, citation := range citations {
+		bibl := firstNonEmpty(citation.Bibl, citation.NAttrib, citation.Ref)
+		key := triagePattern(bibl)
+		cluster, exists := clusters[key]
+		if !exists {
+			cluster = &triageCluster{Pattern: key, Reasons: make(map[string]int), files: make(map[string]bool), parts: make(map[string]resolver.ReferenceParts)}
+			clusters[key] = cluster
+		}
+		cluster.Count++
+		cluster.files[citation.Filename] = true
+		if len(cluster.Examples) < examples && !containsString(cluster.Examples, bibl) {
+			cluster.Examples = append(cluster.Examples, bibl)
+		}
+
+		ref := citation.Ref
+		if ref == "" {
+			ref = urnResolver.GetRef(citation.NAttrib, citation.Bibl)
+		}
+		reason, tried := reasons[ref]
+		if urnResolver.SecondaryLiterature(bibl) != nil {
+			reason = resolver.ReasonSecondary
+		} else if !tried {
+			resolution := urnResolver.Resolve(ref, "", citation.Filename)
+			reason = resolution.Reason
+			if resolution.URN != "" {
+				reason = "resolved"
+			} else if reason == "" {
+				reason = resolver.ReasonUnresolvedPassage
+			}
+			reasons[ref] = reason
+		}
+		cluster.Reasons[string(reason)]++
+		if _, exists := cluster.parts[string(reason)]; !exists {
+			cluster.parts[string(reason)] = urnResolver.ParseReference(ref)
+		}
+	}
+
+	for _, cluster := range clusters {
+		for reason, count := range cluster.Reasons {
+			if count > cluster.Reasons[cluster.Reason] || count == cluster.Reasons[cluster.Reason] && reason < cluster.Reason {
+				cluster.Reason = reason
+			}
+		}
+		cluster.Action = triageAction(resolver.FailureReason(cluster.Reason), cluster.parts[cluster.Reason])
+		cluster.Files = len(cluster.files)
+		report.Clusters = append(report.Clusters, cluster)
+	}
+	// most citations first, then those spread over the most files
+	sort.Slice(report.Clusters, func(i, j int) bool {
+		a, b := report.Clusters[i], report.Clusters[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Files != b.Files {
+			return a.Files > b.Files
+		}
+		return a.Pattern < b.Pattern
+	})
+	cumulative := 0
+	for _, cluster := range report.Clusters {
+		cumulative += cluster.Count
+		cluster.Share = float64(cluster.Count) / float64(report.Unresolved)
+		cluster.Cumulative = float64(cumulative) / float64(report.Unresolved)
+	}
+	return report
+}
+
+// triageAction says what to change to resolve references failing for a
+// reason, naming the author and work of one of them
+func triageAction(reason resolver.FailureReason, parts resolver.ReferenceParts) string {
+	author := strings.ToLower(parts.Author)
+	switch reason {
+	case "resolved":
+		return "resolves with the current data; rerun"
+	case resolver.ReasonUnknownAuthor:
+		return fmt.Sprintf("add the author abbreviation %q to the data files", author)
+	case resolver.ReasonNoAuthorURN:
+		return fmt.Sprintf("add a URN for the author %q", parts.ResolvedAuthor)
+	case resolver.ReasonAmbiguousAuthor:
+		return fmt.Sprintf("register a disambiguator for %q, or add an abbreviation telling its authors apart", author)
+	case resolver.ReasonUnknownWork:
+		return fmt.Sprintf("add the work %q to the work table of %q", strings.ToLower(parts.Work), parts.ResolvedAuthor)
+	case resolver.ReasonUnknownLegacyID:
+		return "add the Perseus text ID to the legacy ID table"
+	case resolver.ReasonSchemeViolation:
+		return "check the citation scheme of the work, or correct the references"
+	case resolver.ReasonSecondary:
+		return "modern scholarship, not a gap in the data: rerun with -secondary to route it to secondary.jsonl"
+	case resolver.ReasonEmptyRef, resolver.ReasonNoAuthor:
+		return "not a reference to an author: suppress the bibls, or add corrections"
+	}
+	return "review the reference pattern, or add corrections"
+}
+
+// limit keeps the first top clusters (0: all) with at least minCount
+// citations
+func (r *triageReport) limit(top, minCount int) {
+	var kept []*triageCluster
+	for _, cluster := range r.Clusters {
+		if cluster.Count < minCount || top > 0 && len(kept) == top {
+			break
+		}
+		kept = append(kept, cluster)
+	}
+	if kept == nil {
+		kept = []*triageCluster{}
+	}
+	r.Clusters = kept
+}
+
+// writeText writes the worklist, a numbered entry per cluster
+func (r *triageReport) writeText(out io.Writer) {
+	fmt.Fprintf(out, "%d unresolved citations\n", r.Unresolved)
+	for i, cluster := range r.Clusters {
+		fmt.Fprintf(out, "\n%d. %s\n", i+1, cluster.Pattern)
+		fmt.Fprintf(out, "   count %d, %.1f%% of the unresolved (%.1f%% with those above), files %d\n",
+			cluster.Count, 100*cluster.Share, 100*cluster.Cumulative, cluster.Files)
+		fmt.Fprintf(out, "   %s: %s\n", cluster.Reason, cluster.Action)
+		fmt.Fprintf(out, "   e.g. %s\n", strings.Join(cluster.Examples, "; "))
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+)
+
+// uniqueFile is the corpus-level index of distinct citations written with
+// Config.Unique
+const uniqueFile = "citations_unique.jsonl"
+
+// occurrence is one place a distinct citation was found
+type occurrence struct {
+	Filename  string `json:"filename"`
+	DocCitURN string `json:"doc_cit_urn"`
+}
+
+// uniqueCitation is a distinct (URN, quote) pair and everywhere it occurs
+type uniqueCitation struct {
+	URN         string       `json:"urn"`
+	QuoteHash   string       `json:"quote_hash"`
+	Quote       string       `json:"quote"`
+	Count       int          `json:"count"`
+	Occurrences []occurrence `json:"occurrences"`
+}
+
+// quoteHash identifies a quote by the words of its language's normalizer,
+// regardless of how it was broken across lines in the source, punctuated or
+// capitalized
+func quoteHash(quote string) string {
+	tokens := quoteNormalizers.For("", quote).Tokenize(quote)
+	sum := sha256.Sum256([]byte(strings.Join(tokens, " ")))
+	return hex.EncodeToString(sum[:])
+}
+
+// uniqueWriter passes citations on to the run's writer while aggregating
+// the resolved ones across all files by URN and quote. The aggregate is
+// written to citations_unique.jsonl when the run ends, in order of first
+// occurrence.
+type uniqueWriter struct {
+	CitationWriter
+	path    string
+	io      IOOptions
+	limiter *rateLimiter
+
+	index  map[string]int // URN and quote hash to position in unique
+	unique []*uniqueCitation
+}
+
+func newUniqueWriter(next CitationWriter, path string, io IOOptions, limiter *rateLimiter) *uniqueWriter {
+	return &uniqueWriter{CitationWriter: next, path: path, io: io, limiter: limiter, index: make(map[string]int)}
+}
+
+func (w *uniqueWriter) Write(citations []Citation) error {
+	for _, citation := range citations {
+		if citation.URN == "" || citation.Ref == "" {
+			continue
+		}
+		hash := quoteHash(citation.Quote)
+		key := citation.URN + "|" + hash
+		i, exists := w.index[key]
+		if !exists {
+			i = len(w.unique)
+			w.index[key] = i
+			w.unique = append(w.unique, &uniqueCitation{URN: citation.URN, QuoteHash: hash, Quote: citation.Quote})
+		}
+		entry := w.unique[i]
+		entry.Count++
+		entry.Occurrences = append(entry.Occurrences, occurrence{Filename: citation.Filename, DocCitURN: citation.DocCitURN})
+	}
+	return w.CitationWriter.Write(citations)
+}
+
+// WriteDocument passes document metadata on if the run's writer records it
+func (w *uniqueWriter) WriteDocument(record documentRecord) error {
+	if writer, ok := w.CitationWriter.(documentWriter); ok {
+		return writer.WriteDocument(record)
+	}
+	return nil
+}
+
+func (w *uniqueWriter) Close() error {
+	if err := w.CitationWriter.Close(); err != nil {
+		return err
+	}
+	file, err := openOutputFile(w.path, os.O_CREATE|os.O_TRUNC, w.io, w.limiter)
+	if err != nil {
+		return err
+	}
+	for _, entry := range w.unique {
+		jsonData, err := json.Marshal(entry)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		if _, err := file.Write(append(jsonData, '\n')); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return file.Close()
+}
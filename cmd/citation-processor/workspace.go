@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// workspacePrefix begins the names of the per-run workspaces in the output
+// directory
+const workspacePrefix = ".run-"
+
+// outputLockFile is held in the output directory while a run publishes its
+// output there
+const outputLockFile = ".citation-processor.lock"
+
+// Waiting for the output lock: its holder touches it every lockHeartbeat, so
+// a run waits for as long as the holder is alive and gives up once the lock
+// has not been touched for lockTimeout; a lock older than lockStaleAfter was
+// left by a run that died while holding it
+var (
+	lockTimeout    = 2 * time.Minute
+	lockRetry      = 100 * time.Millisecond
+	lockHeartbeat  = 30 * time.Second
+	lockStaleAfter = 10 * time.Minute
+)
+
+// runWorkspace is a directory of its own inside the output directory that a
+// run writes its output to, so that runs sharing an output directory neither
+// delete nor interleave with each other's files. Once the run has finished,
+// publish replaces the output directory's files with the workspace's, while
+// holding the directory's lock; the run that finishes last wins. An
+// appending run takes the lock earlier, before reading the output it
+// continues from (see lock). A run that is killed leaves its workspace
+// behind, to be deleted by hand.
+type runWorkspace struct {
+	dir       string // e.g. cit_data/.run-1234567
+	outputDir string
+	append    bool            // add the workspace's files to the output's rather than replace them
+	replace   map[string]bool // files that replace the output's even when appending
+	unlock    func()          // releases the output directory's lock, while the run holds it
+}
+
+func newRunWorkspace(outputDir string) (*runWorkspace, error) {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	dir, err := os.MkdirTemp(outputDir, workspacePrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create run workspace: %w", err)
+	}
+	return &runWorkspace{dir: dir, outputDir: outputDir}, nil
+}
+
+// lock takes the output directory's lock and holds it until the workspace
+// is published or discarded. An appending run takes it before reading the
+// doc_cit_urn numbers it continues from, so that no other run publishes
+// citations numbered from the same ones in the meantime.
+func (w *runWorkspace) lock() error {
+	unlock, err := lockOutputDir(w.outputDir)
+	if err != nil {
+		return err
+	}
+	w.unlock = unlock
+	return nil
+}
+
+// release releases the output directory's lock, if the run holds it
+func (w *runWorkspace) release() {
+	if w.unlock != nil {
+		w.unlock()
+		w.unlock = nil
+	}
+}
+
+// publish removes the previous run's output, then moves each file of the
+// workspace into the output directory, which replaces it atomically, and
+// removes the workspace. Appending, the previous output is kept and each
+// file is added to the end of the output file of the same name.
+func (w *runWorkspace) publish(previous []string) error {
+	if w.unlock == nil {
+		if err := w.lock(); err != nil {
+			return err
+		}
+	}
+	defer w.release()
+
+	if !w.append {
+		for _, path := range previous {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove previous output: %w", err)
+			}
+		}
+	}
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read run workspace: %w", err)
+	}
+	for _, entry := range entries {
+		from, to := filepath.Join(w.dir, entry.Name()), filepath.Join(w.outputDir, entry.Name())
+		if w.append && !w.replace[entry.Name()] {
+			err = appendFile(to, from)
+		} else {
+			err = os.Rename(from, to)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to publish output: %w", err)
+		}
+	}
+	return os.RemoveAll(w.dir)
+}
+
+// appendFile adds the contents of the file at from to the end of the file
+// at to, creating it if need be
+func appendFile(to, from string) error {
+	source, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	target, err := os.OpenFile(to, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(target, source)
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// discard releases the output directory's lock and removes the workspace
+// and whatever is left in it; after publish there is nothing to remove
+func (w *runWorkspace) discard() {
+	w.release()
+	if err := os.RemoveAll(w.dir); err != nil {
+		slog.Warn("failed to remove run workspace", "dir", w.dir, "error", err)
+	}
+}
+
+// lockOutputDir takes the output directory's lock, waiting for another run
+// holding it to finish, and returns the function that releases it. The lock
+// is touched every lockHeartbeat until released.
+func lockOutputDir(outputDir string) (func(), error) {
+	path := filepath.Join(outputDir, outputLockFile)
+	deadline := time.Now().Add(lockTimeout)
+	var touched time.Time
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintln(file, os.Getpid())
+			file.Close()
+			done := make(chan struct{})
+			go touchLock(path, done)
+			return func() {
+				close(done)
+				os.Remove(path)
+			}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to lock output directory: %w", err)
+		}
+		if info, err := os.Stat(path); err == nil {
+			if time.Since(info.ModTime()) > lockStaleAfter {
+				slog.Warn("removing stale output lock", "file", path, "age", time.Since(info.ModTime()).Round(time.Second))
+				os.Remove(path)
+				continue
+			}
+			// the run holding the lock is alive
+			if info.ModTime().After(touched) {
+				touched = info.ModTime()
+				deadline = time.Now().Add(lockTimeout)
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("output directory %s is locked by another run (remove %s if no run is publishing)", outputDir, path)
+		}
+		time.Sleep(lockRetry)
+	}
+}
+
+// touchLock keeps the lock at path from being taken for stale by touching it
+// every lockHeartbeat until done is closed
+func touchLock(path string, done chan struct{}) {
+	ticker := time.NewTicker(lockHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			os.Chtimes(path, now, now)
+		}
+	}
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// CitationWriter receives the citations extracted from each file and
+// finalizes its output once all files have been processed
+type CitationWriter interface {
+	Write(citations []Citation) error
+	Close() error
+}
+
+// newCitationWriter returns the writer for the output format named in the
+// config, writing to outputDir
+func newCitationWriter(cp *CitationProcessor, outputDir string) (CitationWriter, error) {
+	if err := cp.Config.IO.validate(); err != nil {
+		return nil, err
+	}
+	limiter := newRateLimiter(cp.Config.IO.MaxRate)
+
+	switch cp.Config.PartitionBy {
+	case "", "namespace", "author", "file":
+	default:
+		return nil, fmt.Errorf("unknown partition %q (expected namespace, author or file)", cp.Config.PartitionBy)
+	}
+	if cp.Config.PartitionBy != "" && cp.Config.Format != "" && cp.Config.Format != "jsonl" {
+		return nil, fmt.Errorf("-partition-by only applies to jsonl output")
+	}
+	if cp.Config.DryRun {
+		return &dryRunWriter{out: stdout, examples: cp.Config.DryRunExamples}, nil
+	}
+	toStdout := cp.Config.OutputDir == stdoutOutput
+	if toStdout && cp.Config.PartitionBy != "" {
+		return nil, fmt.Errorf("-partition-by needs an output directory, not -output -")
+	}
+	if cp.Config.Unique && (toStdout || (cp.Config.Format != "" && cp.Config.Format != "jsonl")) {
+		return nil, fmt.Errorf("-unique needs jsonl output to an output directory")
+	}
+	if cp.Config.Append {
+		switch {
+		case toStdout:
+			return nil, fmt.Errorf("-append needs an output directory, not -output -")
+		case cp.Config.Format == "bibtex" || cp.Config.Format == "csl":
+			return nil, fmt.Errorf("-append does not apply to %s output, which aggregates the whole run", cp.Config.Format)
+		case cp.Config.Format == "parquet":
+			return nil, fmt.Errorf("-append does not apply to parquet output, whose file is written whole")
+		case cp.Config.Unique:
+			return nil, fmt.Errorf("-append cannot be combined with -unique, which aggregates the whole run")
+		case cp.Config.TimestampOutput:
+			return nil, fmt.Errorf("-append cannot be combined with -timestamp-output, whose files are new each run")
+		}
+	}
+	if cp.Config.IO.Gzip {
+		switch {
+		case toStdout:
+			return nil, fmt.Errorf("-gzip-output needs an output directory, not -output -")
+		case cp.Config.Format == "parquet":
+			return nil, fmt.Errorf("-gzip-output does not apply to parquet output, which is compressed already")
+		}
+	}
+	if cp.Config.TimestampOutput && toStdout {
+		return nil, fmt.Errorf("-timestamp-output needs an output directory, not -output -")
+	}
+	if cp.Config.TimestampOutput && cp.Config.Deterministic {
+		return nil, fmt.Errorf("-deterministic cannot be combined with -timestamp-output, whose file names depend on the clock")
+	}
+	if cp.Config.RunMetadata && toStdout {
+		return nil, fmt.Errorf("-run-metadata needs an output directory, not -output -")
+	}
+	path := func(name string) string {
+		return filepath.Join(outputDir, cp.outputName(name))
+	}
+
+	switch cp.Config.Format {
+	case "", "jsonl":
+		if toStdout {
+			return &streamWriter{out: bufio.NewWriter(stdout)}, nil
+		}
+		var writer CitationWriter = &jsonlWriter{
+			resolvedPath:    path(cp.Config.ResolvedFile),
+			unresolvedPath:  path(cp.Config.UnresolvedFile),
+			documentsPath:   path(documentsFile),
+			provisionalPath: path(provisionalFile),
+			prosePath:       path(proseFile),
+			secondaryPath:   path(secondaryFile),
+			partitionBy:     cp.Config.PartitionBy,
+			io:              cp.Config.IO,
+			limiter:         limiter,
+		}
+		if cp.Config.Unique {
+			writer = newUniqueWriter(writer, path(uniqueFile), cp.Config.IO, limiter)
+		}
+		return writer, nil
+	case "bibtex":
+		return &workExportWriter{
+			path:    path("citations.bib"),
+			stdout:  toStdout,
+			data:    cp.Resolver.Data,
+			write:   writeBibTeX,
+			io:      cp.Config.IO,
+			limiter: limiter,
+		}, nil
+	case "csl":
+		return &workExportWriter{
+			path:    path("citations.csl.json"),
+			stdout:  toStdout,
+			data:    cp.Resolver.Data,
+			write:   writeCSLJSON,
+			io:      cp.Config.IO,
+			limiter: limiter,
+		}, nil
+	case "annotations":
+		if toStdout {
+			return &annotationWriter{out: bufio.NewWriter(stdout)}, nil
+		}
+		return &annotationWriter{
+			path:    path(annotationsFile),
+			io:      cp.Config.IO,
+			limiter: limiter,
+		}, nil
+	case "parquet":
+		if toStdout {
+			return nil, fmt.Errorf("-format parquet needs an output directory, not -output -")
+		}
+		return &parquetWriter{
+			path:    path(parquetFile),
+			io:      cp.Config.IO,
+			limiter: limiter,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q (expected jsonl, bibtex, csl, annotations or parquet)", cp.Config.Format)
+}
+
+// documentWriter is implemented by writers that also record each input
+// document's metadata
+type documentWriter interface {
+	WriteDocument(record documentRecord) error
+}
+
+// documentsFile is the sidecar listing the input documents and their metadata
+const documentsFile = "documents.jsonl"
+
+// provisionalFile receives unresolved citations that have a provisional URN
+const provisionalFile = "provisional.jsonl"
+
+// proseFile receives the citations found in running prose with -prose
+const proseFile = "prose.jsonl"
+
+// secondaryFile receives the citations of modern scholarship with -secondary
+const secondaryFile = "secondary.jsonl"
+
+// stdoutOutput as the output directory sends output to standard output
+const stdoutOutput = "-"
+
+// stdout receives output written with -output -, replaceable in tests
+var stdout io.Writer = os.Stdout
+
+// streamWriter writes resolved and unresolved citations alike as a single
+// JSONL stream, flushed after every input file
+type streamWriter struct {
+	out *bufio.Writer
+}
+
+func (w *streamWriter) Write(citations []Citation) error {
+	for _, citation := range citations {
+		jsonData, err := json.Marshal(citation)
+		if err != nil {
+			continue
+		}
+		if _, err := w.out.Write(append(jsonData, '\n')); err != nil {
+			return err
+		}
+	}
+	return w.out.Flush()
+}
+
+func (w *streamWriter) Close() error {
+	return w.out.Flush()
+}
+
+// dryRunWriter writes nothing, instead printing a summary of each file's
+// citations with the first few as examples, and totals when the run ends
+type dryRunWriter struct {
+	out      io.Writer
+	examples int
+
+	pending    []Citation // citations of the file being processed
+	files      int
+	resolved   int
+	unresolved int
+	ambiguous  int
+}
+
+func (w *dryRunWriter) Write(citations []Citation) error {
+	w.pending = append(w.pending, citations...)
+	return nil
+}
+
+// WriteDocument prints the summary of the file whose citations were just
+// written
+func (w *dryRunWriter) WriteDocument(record documentRecord) error {
+	counts := make(map[string]int)
+	for _, citation := range w.pending {
+		counts[citationStatus(citation)]++
+	}
+	w.files++
+	w.resolved += counts["resolved"]
+	w.unresolved += counts["unresolved"]
+	w.ambiguous += counts["ambiguous"]
+
+	fmt.Fprintf(w.out, "%s: %d citations (%d resolved, %d ambiguous, %d unresolved)\n", record.Filename,
+		len(w.pending), counts["resolved"], counts["ambiguous"], counts["unresolved"])
+	for i, citation := range w.pending {
+		if i == w.examples {
+			fmt.Fprintf(w.out, "  ... %d more\n", len(w.pending)-w.examples)
+			break
+		}
+		result := citation.URN
+		if result == "" {
+			result = "unresolved"
+			if len(citation.Warnings) > 0 {
+				result += " (" + citation.Warnings[0] + ")"
+			}
+		}
+		fmt.Fprintf(w.out, "  %s -> %s\n", citation.Bibl, result)
+	}
+	w.pending = nil
+	return nil
+}
+
+func (w *dryRunWriter) Close() error {
+	fmt.Fprintf(w.out, "Dry run, nothing written: %d files, %d citations (%d resolved, %d ambiguous, %d unresolved)\n",
+		w.files, w.resolved+w.ambiguous+w.unresolved, w.resolved, w.ambiguous, w.unresolved)
+	return nil
+}
+
+// jsonlWriter appends citations to resolved.jsonl and unresolved.jsonl as
+// each file is processed. The files are opened on the first write and kept
+// open, with buffered output flushed after every input file. With a
+// partition, resolved citations go to resolved.<key>.jsonl instead, one file
+// per CTS namespace, cited author or source file. Each input document's
+// metadata is appended to documents.jsonl. Unresolved citations with a
+// provisional URN go to provisional.jsonl rather than unresolved.jsonl, and
+// citations found in running prose to prose.jsonl rather than resolved.jsonl.
+// Citations of modern scholarship go to secondary.jsonl.
+type jsonlWriter struct {
+	resolvedPath    string
+	unresolvedPath  string
+	documentsPath   string
+	provisionalPath string
+	prosePath       string
+	secondaryPath   string
+	partitionBy     string // "", "namespace", "author" or "file"
+	io              IOOptions
+	limiter         *rateLimiter
+
+	resolvedFile    *outputFile
+	unresolvedFile  *outputFile
+	documentsFile   *outputFile
+	provisionalFile *outputFile
+	proseFile       *outputFile
+	secondaryFile   *outputFile
+	partitions      map[string]*outputFile
+}
+
+// partitionKeyRegex matches characters not kept in partition file names
+var partitionKeyRegex = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// partitionKey returns the key of the partition a resolved citation belongs
+// to: the namespace ("greekLit") or author ("tlg0011") of its URN, or the base
+// name of its source file
+func partitionKey(partitionBy string, citation Citation) string {
+	var key string
+	switch partitionBy {
+	case "namespace", "author":
+		parts := strings.SplitN(citation.URN, ":", 5)
+		if len(parts) >= 4 {
+			key = parts[2]
+			if partitionBy == "author" {
+				key, _, _ = strings.Cut(parts[3], ".")
+			}
+		}
+	case "file":
+		key = strings.TrimSuffix(filepath.Base(citation.Filename), filepath.Ext(citation.Filename))
+	}
+	if key = partitionKeyRegex.ReplaceAllString(key, "_"); key == "" {
+		key = "unknown"
+	}
+	return key
+}
+
+// partitionPath inserts the partition key before the extension of the
+// resolved file, e.g. resolved.latinLit.jsonl or resolved.latinLit.jsonl.gz
+func partitionPath(resolvedPath, key string) string {
+	path, compressed := strings.CutSuffix(resolvedPath, gzipExtension)
+	ext := filepath.Ext(path)
+	path = strings.TrimSuffix(path, ext) + "." + key + ext
+	if compressed {
+		path += gzipExtension
+	}
+	return path
+}
+
+// resolvedOutput returns the file a resolved citation is written to, opening
+// its partition on first use
+func (w *jsonlWriter) resolvedOutput(citation Citation) (*outputFile, error) {
+	if w.partitionBy == "" {
+		return w.resolvedFile, nil
+	}
+	key := partitionKey(w.partitionBy, citation)
+	if file, exists := w.partitions[key]; exists {
+		return file, nil
+	}
+	file, err := openOutputFile(partitionPath(w.resolvedPath, key), os.O_APPEND|os.O_CREATE, w.io, w.limiter)
+	if err != nil {
+		return nil, err
+	}
+	if w.partitions == nil {
+		w.partitions = make(map[string]*outputFile)
+	}
+	w.partitions[key] = file
+	return file, nil
+}
+
+func (w *jsonlWriter) open() error {
+	var err error
+	if w.resolvedFile == nil && w.partitionBy == "" {
+		if w.resolvedFile, err = openOutputFile(w.resolvedPath, os.O_APPEND|os.O_CREATE, w.io, w.limiter); err != nil {
+			return err
+		}
+	}
+	if w.unresolvedFile == nil {
+		if w.unresolvedFile, err = openOutputFile(w.unresolvedPath, os.O_APPEND|os.O_CREATE, w.io, w.limiter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *jsonlWriter) Write(citations []Citation) error {
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	for _, citation := range citations {
+		jsonData, err := json.Marshal(citation)
+		if err != nil {
+			continue
+		}
+
+		file := w.unresolvedFile
+		if citation.Pattern == prosePattern {
+			if file, err = w.proseOutput(); err != nil {
+				return err
+			}
+		} else if citation.Secondary != nil {
+			if file, err = w.secondaryOutput(); err != nil {
+				return err
+			}
+		} else if citation.URN != "" && citation.Ref != "" {
+			// Successfully resolved
+			if file, err = w.resolvedOutput(citation); err != nil {
+				return err
+			}
+		} else if citation.Provisional != "" {
+			if file, err = w.provisionalOutput(); err != nil {
+				return err
+			}
+		}
+		if _, err := file.Write(append(jsonData, '\n')); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range w.files() {
+		if err := file.Checkpoint(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// provisionalOutput returns provisional.jsonl, opening it on first use so
+// that runs without provisional URNs leave no empty file
+func (w *jsonlWriter) provisionalOutput() (*outputFile, error) {
+	if w.provisionalFile == nil {
+		var err error
+		if w.provisionalFile, err = openOutputFile(w.provisionalPath, os.O_APPEND|os.O_CREATE, w.io, w.limiter); err != nil {
+			return nil, err
+		}
+	}
+	return w.provisionalFile, nil
+}
+
+// proseOutput returns prose.jsonl, opened on first use like
+// provisional.jsonl
+func (w *jsonlWriter) proseOutput() (*outputFile, error) {
+	if w.proseFile == nil {
+		var err error
+		if w.proseFile, err = openOutputFile(w.prosePath, os.O_APPEND|os.O_CREATE, w.io, w.limiter); err != nil {
+			return nil, err
+		}
+	}
+	return w.proseFile, nil
+}
+
+// secondaryOutput returns secondary.jsonl, opened on first use like
+// provisional.jsonl
+func (w *jsonlWriter) secondaryOutput() (*outputFile, error) {
+	if w.secondaryFile == nil {
+		var err error
+		if w.secondaryFile, err = openOutputFile(w.secondaryPath, os.O_APPEND|os.O_CREATE, w.io, w.limiter); err != nil {
+			return nil, err
+		}
+	}
+	return w.secondaryFile, nil
+}
+
+// WriteDocument appends a document's metadata to documents.jsonl
+func (w *jsonlWriter) WriteDocument(record documentRecord) error {
+	if w.documentsFile == nil {
+		var err error
+		if w.documentsFile, err = openOutputFile(w.documentsPath, os.O_APPEND|os.O_CREATE, w.io, w.limiter); err != nil {
+			return err
+		}
+	}
+	jsonData, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := w.documentsFile.Write(append(jsonData, '\n')); err != nil {
+		return err
+	}
+	return w.documentsFile.Checkpoint()
+}
+
+// files returns the open output files
+func (w *jsonlWriter) files() []*outputFile {
+	var files []*outputFile
+	for _, file := range []*outputFile{w.resolvedFile, w.unresolvedFile, w.documentsFile, w.provisionalFile, w.proseFile, w.secondaryFile} {
+		if file != nil {
+			files = append(files, file)
+		}
+	}
+	for _, file := range w.partitions {
+		files = append(files, file)
+	}
+	return files
+}
+
+func (w *jsonlWriter) Close() error {
+	var err error
+	for _, file := range w.files() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	w.resolvedFile, w.unresolvedFile, w.documentsFile, w.provisionalFile, w.proseFile, w.secondaryFile, w.partitions = nil, nil, nil, nil, nil, nil, nil
+	return err
+}
@@ -0,0 +1,1904 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.31.0
+// 	protoc        v4.24.4
+// source: citationpb/citation.proto
+
+// The citation resolver as a long-lived service, for pipelines that resolve
+// references in bulk without starting a process per document.
+
+package citationpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// ResolveRequest is a reference as it appears in a bibl: the n attribute,
+// the bibl content, or both, from which the reference is derived as in a
+// processing run.
+type ResolveRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id       string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"` // echoed in the response, for matching batch responses
+	NAttrib  string `protobuf:"bytes,2,opt,name=n_attrib,json=nAttrib,proto3" json:"n_attrib,omitempty"`
+	Bibl     string `protobuf:"bytes,3,opt,name=bibl,proto3" json:"bibl,omitempty"`
+	Context  string `protobuf:"bytes,4,opt,name=context,proto3" json:"context,omitempty"`   // XML around the citation, used to disambiguate authors
+	Filename string `protobuf:"bytes,5,opt,name=filename,proto3" json:"filename,omitempty"` // source document, used to disambiguate authors
+}
+
+func (x *ResolveRequest) Reset() {
+	*x = ResolveRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_citationpb_citation_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ResolveRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResolveRequest) ProtoMessage() {}
+
+func (x *ResolveRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_citationpb_citation_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResolveRequest.ProtoReflect.Descriptor instead.
+func (*ResolveRequest) Descriptor() ([]byte, []int) {
+	return file_citationpb_citation_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ResolveRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ResolveRequest) GetNAttrib() string {
+	if x != nil {
+		return x.NAttrib
+	}
+	return ""
+}
+
+func (x *ResolveRequest) GetBibl() string {
+	if x != nil {
+		return x.Bibl
+	}
+	return ""
+}
+
+func (x *ResolveRequest) GetContext() string {
+	if x != nil {
+		return x.Context
+	}
+	return ""
+}
+
+func (x *ResolveRequest) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+type ResolveResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id              string           `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Ref             string           `protobuf:"bytes,2,opt,name=ref,proto3" json:"ref,omitempty"` // the reference derived from n_attrib and bibl
+	Urn             string           `protobuf:"bytes,3,opt,name=urn,proto3" json:"urn,omitempty"` // empty when the reference could not be resolved
+	Warnings        []string         `protobuf:"bytes,4,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	Reason          string           `protobuf:"bytes,5,opt,name=reason,proto3" json:"reason,omitempty"` // failure reason, e.g. "unknown-work"
+	Scheme          string           `protobuf:"bytes,6,opt,name=scheme,proto3" json:"scheme,omitempty"`
+	Candidates      []*Candidate     `protobuf:"bytes,7,rep,name=candidates,proto3" json:"candidates,omitempty"`
+	Works           []string         `protobuf:"bytes,8,rep,name=works,proto3" json:"works,omitempty"`
+	Qualifier       string           `protobuf:"bytes,9,opt,name=qualifier,proto3" json:"qualifier,omitempty"`
+	FallbackUsed    bool             `protobuf:"varint,10,opt,name=fallback_used,json=fallbackUsed,proto3" json:"fallback_used,omitempty"`
+	Locus           []*LocusLevel    `protobuf:"bytes,11,rep,name=locus,proto3" json:"locus,omitempty"`
+	Commentator     string           `protobuf:"bytes,12,opt,name=commentator,proto3" json:"commentator,omitempty"`
+	Note            string           `protobuf:"bytes,13,opt,name=note,proto3" json:"note,omitempty"`
+	Corrected       bool             `protobuf:"varint,14,opt,name=corrected,proto3" json:"corrected,omitempty"`
+	ProvisionalUrn  string           `protobuf:"bytes,15,opt,name=provisional_urn,json=provisionalUrn,proto3" json:"provisional_urn,omitempty"`
+	FuzzyAuthor     *AuthorMatch     `protobuf:"bytes,16,opt,name=fuzzy_author,json=fuzzyAuthor,proto3" json:"fuzzy_author,omitempty"`          // set when the author was matched fuzzily
+	Fragment        *Fragment        `protobuf:"bytes,17,opt,name=fragment,proto3" json:"fragment,omitempty"`                                   // set for references to a fragment collection
+	Provenance      []*DataEntry     `protobuf:"bytes,18,rep,name=provenance,proto3" json:"provenance,omitempty"`                               // data file entries used, with -provenance
+	ResolutionStep  string           `protobuf:"bytes,19,opt,name=resolution_step,json=resolutionStep,proto3" json:"resolution_step,omitempty"` // custom resolution step that gave the URN
+	StepConfidence  float64          `protobuf:"fixed64,20,opt,name=step_confidence,json=stepConfidence,proto3" json:"step_confidence,omitempty"`
+	Edition         string           `protobuf:"bytes,21,opt,name=edition,proto3" json:"edition,omitempty"`                                        // editor or edition series named after the locus, e.g. "Burnet"
+	SchemeViolation string           `protobuf:"bytes,22,opt,name=scheme_violation,json=schemeViolation,proto3" json:"scheme_violation,omitempty"` // URN rejected as beyond the work's citation scheme
+	PriorUsed       bool             `protobuf:"varint,23,opt,name=prior_used,json=priorUsed,proto3" json:"prior_used,omitempty"`                  // the work was chosen by the co-occurrence prior
+	Documentary     *DocumentaryText `protobuf:"bytes,24,opt,name=documentary,proto3" json:"documentary,omitempty"`                                // set for references to a corpus of inscriptions or papyri
+	ExternalId      string           `protobuf:"bytes,25,opt,name=external_id,json=externalId,proto3" json:"external_id,omitempty"`                // the documentary text's identifier elsewhere, e.g. its papyri.info page
+}
+
+func (x *ResolveResponse) Reset() {
+	*x = ResolveResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_citationpb_citation_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ResolveResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResolveResponse) ProtoMessage() {}
+
+func (x *ResolveResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_citationpb_citation_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResolveResponse.ProtoReflect.Descriptor instead.
+func (*ResolveResponse) Descriptor() ([]byte, []int) {
+	return file_citationpb_citation_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ResolveResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ResolveResponse) GetRef() string {
+	if x != nil {
+		return x.Ref
+	}
+	return ""
+}
+
+func (x *ResolveResponse) GetUrn() string {
+	if x != nil {
+		return x.Urn
+	}
+	return ""
+}
+
+func (x *ResolveResponse) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+func (x *ResolveResponse) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *ResolveResponse) GetScheme() string {
+	if x != nil {
+		return x.Scheme
+	}
+	return ""
+}
+
+func (x *ResolveResponse) GetCandidates() []*Candidate {
+	if x != nil {
+		return x.Candidates
+	}
+	return nil
+}
+
+func (x *ResolveResponse) GetWorks() []string {
+	if x != nil {
+		return x.Works
+	}
+	return nil
+}
+
+func (x *ResolveResponse) GetQualifier() string {
+	if x != nil {
+		return x.Qualifier
+	}
+	return ""
+}
+
+func (x *ResolveResponse) GetFallbackUsed() bool {
+	if x != nil {
+		return x.FallbackUsed
+	}
+	return false
+}
+
+func (x *ResolveResponse) GetLocus() []*LocusLevel {
+	if x != nil {
+		return x.Locus
+	}
+	return nil
+}
+
+func (x *ResolveResponse) GetCommentator() string {
+	if x != nil {
+		return x.Commentator
+	}
+	return ""
+}
+
+func (x *ResolveResponse) GetNote() string {
+	if x != nil {
+		return x.Note
+	}
+	return ""
+}
+
+func (x *ResolveResponse) GetCorrected() bool {
+	if x != nil {
+		return x.Corrected
+	}
+	return false
+}
+
+func (x *ResolveResponse) GetProvisionalUrn() string {
+	if x != nil {
+		return x.ProvisionalUrn
+	}
+	return ""
+}
+
+func (x *ResolveResponse) GetFuzzyAuthor() *AuthorMatch {
+	if x != nil {
+		return x.FuzzyAuthor
+	}
+	return nil
+}
+
+func (x *ResolveResponse) GetFragment() *Fragment {
+	if x != nil {
+		return x.Fragment
+	}
+	return nil
+}
+
+func (x *ResolveResponse) GetProvenance() []*DataEntry {
+	if x != nil {
+		return x.Provenance
+	}
+	return nil
+}
+
+func (x *ResolveResponse) GetResolutionStep() string {
+	if x != nil {
+		return x.ResolutionStep
+	}
+	return ""
+}
+
+func (x *ResolveResponse) GetStepConfidence() float64 {
+	if x != nil {
+		return x.StepConfidence
+	}
+	return 0
+}
+
+func (x *ResolveResponse) GetEdition() string {
+	if x != nil {
+		return x.Edition
+	}
+	return ""
+}
+
+func (x *ResolveResponse) GetSchemeViolation() string {
+	if x != nil {
+		return x.SchemeViolation
+	}
+	return ""
+}
+
+func (x *ResolveResponse) GetPriorUsed() bool {
+	if x != nil {
+		return x.PriorUsed
+	}
+	return false
+}
+
+func (x *ResolveResponse) GetDocumentary() *DocumentaryText {
+	if x != nil {
+		return x.Documentary
+	}
+	return nil
+}
+
+func (x *ResolveResponse) GetExternalId() string {
+	if x != nil {
+		return x.ExternalId
+	}
+	return ""
+}
+
+type Candidate struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Urn   string  `protobuf:"bytes,1,opt,name=urn,proto3" json:"urn,omitempty"`
+	Score float64 `protobuf:"fixed64,2,opt,name=score,proto3" json:"score,omitempty"`
+}
+
+func (x *Candidate) Reset() {
+	*x = Candidate{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_citationpb_citation_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Candidate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Candidate) ProtoMessage() {}
+
+func (x *Candidate) ProtoReflect() protoreflect.Message {
+	mi := &file_citationpb_citation_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Candidate.ProtoReflect.Descriptor instead.
+func (*Candidate) Descriptor() ([]byte, []int) {
+	return file_citationpb_citation_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Candidate) GetUrn() string {
+	if x != nil {
+		return x.Urn
+	}
+	return ""
+}
+
+func (x *Candidate) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+// AuthorMatch is an unrecognized author taken for the most similar known
+// author or abbreviation.
+type AuthorMatch struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Input        string  `protobuf:"bytes,1,opt,name=input,proto3" json:"input,omitempty"`
+	Matched      string  `protobuf:"bytes,2,opt,name=matched,proto3" json:"matched,omitempty"`
+	EditDistance int32   `protobuf:"varint,3,opt,name=edit_distance,json=editDistance,proto3" json:"edit_distance,omitempty"`
+	Score        float64 `protobuf:"fixed64,4,opt,name=score,proto3" json:"score,omitempty"` // Jaro-Winkler similarity
+}
+
+func (x *AuthorMatch) Reset() {
+	*x = AuthorMatch{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_citationpb_citation_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AuthorMatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuthorMatch) ProtoMessage() {}
+
+func (x *AuthorMatch) ProtoReflect() protoreflect.Message {
+	mi := &file_citationpb_citation_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AuthorMatch.ProtoReflect.Descriptor instead.
+func (*AuthorMatch) Descriptor() ([]byte, []int) {
+	return file_citationpb_citation_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *AuthorMatch) GetInput() string {
+	if x != nil {
+		return x.Input
+	}
+	return ""
+}
+
+func (x *AuthorMatch) GetMatched() string {
+	if x != nil {
+		return x.Matched
+	}
+	return ""
+}
+
+func (x *AuthorMatch) GetEditDistance() int32 {
+	if x != nil {
+		return x.EditDistance
+	}
+	return 0
+}
+
+func (x *AuthorMatch) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+// Fragment is a fragment or testimonium cited by its number in a standard
+// collection, such as "FGrH 115 F 30".
+type Fragment struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Collection string `protobuf:"bytes,1,opt,name=collection,proto3" json:"collection,omitempty"` // e.g. "fgrh"
+	Author     string `protobuf:"bytes,2,opt,name=author,proto3" json:"author,omitempty"`         // number in a numbered collection, else text group
+	Name       string `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Kind       string `protobuf:"bytes,4,opt,name=kind,proto3" json:"kind,omitempty"` // "F", "T", or "A", "B", "C" in Diels-Kranz
+	Number     string `protobuf:"bytes,5,opt,name=number,proto3" json:"number,omitempty"`
+}
+
+func (x *Fragment) Reset() {
+	*x = Fragment{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_citationpb_citation_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Fragment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Fragment) ProtoMessage() {}
+
+func (x *Fragment) ProtoReflect() protoreflect.Message {
+	mi := &file_citationpb_citation_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Fragment.ProtoReflect.Descriptor instead.
+func (*Fragment) Descriptor() ([]byte, []int) {
+	return file_citationpb_citation_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Fragment) GetCollection() string {
+	if x != nil {
+		return x.Collection
+	}
+	return ""
+}
+
+func (x *Fragment) GetAuthor() string {
+	if x != nil {
+		return x.Author
+	}
+	return ""
+}
+
+func (x *Fragment) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Fragment) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Fragment) GetNumber() string {
+	if x != nil {
+		return x.Number
+	}
+	return ""
+}
+
+// DataEntry locates the entry of a data file that mapped a citation's author
+// or work.
+type DataEntry struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	File  string `protobuf:"bytes,1,opt,name=file,proto3" json:"file,omitempty"`   // e.g. "latin_data.json"
+	Entry string `protobuf:"bytes,2,opt,name=entry,proto3" json:"entry,omitempty"` // e.g. "AuthAbb[\"plin.\"]"
+}
+
+func (x *DataEntry) Reset() {
+	*x = DataEntry{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_citationpb_citation_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DataEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DataEntry) ProtoMessage() {}
+
+func (x *DataEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_citationpb_citation_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DataEntry.ProtoReflect.Descriptor instead.
+func (*DataEntry) Descriptor() ([]byte, []int) {
+	return file_citationpb_citation_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DataEntry) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *DataEntry) GetEntry() string {
+	if x != nil {
+		return x.Entry
+	}
+	return ""
+}
+
+// DocumentaryText is an inscription or papyrus cited by its number in a
+// corpus, such as "IG II² 1234".
+type DocumentaryText struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Corpus  string `protobuf:"bytes,1,opt,name=corpus,proto3" json:"corpus,omitempty"` // e.g. "ig" or "p.oxy"
+	Kind    string `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`     // "inscription" or "papyrus"
+	Volume  string `protobuf:"bytes,3,opt,name=volume,proto3" json:"volume,omitempty"` // in Arabic numerals
+	Edition string `protobuf:"bytes,4,opt,name=edition,proto3" json:"edition,omitempty"`
+	Number  string `protobuf:"bytes,5,opt,name=number,proto3" json:"number,omitempty"`
+}
+
+func (x *DocumentaryText) Reset() {
+	*x = DocumentaryText{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_citationpb_citation_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DocumentaryText) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DocumentaryText) ProtoMessage() {}
+
+func (x *DocumentaryText) ProtoReflect() protoreflect.Message {
+	mi := &file_citationpb_citation_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DocumentaryText.ProtoReflect.Descriptor instead.
+func (*DocumentaryText) Descriptor() ([]byte, []int) {
+	return file_citationpb_citation_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DocumentaryText) GetCorpus() string {
+	if x != nil {
+		return x.Corpus
+	}
+	return ""
+}
+
+func (x *DocumentaryText) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *DocumentaryText) GetVolume() string {
+	if x != nil {
+		return x.Volume
+	}
+	return ""
+}
+
+func (x *DocumentaryText) GetEdition() string {
+	if x != nil {
+		return x.Edition
+	}
+	return ""
+}
+
+func (x *DocumentaryText) GetNumber() string {
+	if x != nil {
+		return x.Number
+	}
+	return ""
+}
+
+// SecondaryReference is the modern scholarship a bibl cites, such as
+// "Wilamowitz 1889, 23".
+type SecondaryReference struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Author string `protobuf:"bytes,1,opt,name=author,proto3" json:"author,omitempty"`
+	Year   string `protobuf:"bytes,2,opt,name=year,proto3" json:"year,omitempty"`
+	Title  string `protobuf:"bytes,3,opt,name=title,proto3" json:"title,omitempty"` // abbreviated journal or book cited before the year
+	Pages  string `protobuf:"bytes,4,opt,name=pages,proto3" json:"pages,omitempty"`
+	AdLoc  bool   `protobuf:"varint,5,opt,name=ad_loc,json=adLoc,proto3" json:"ad_loc,omitempty"`
+}
+
+func (x *SecondaryReference) Reset() {
+	*x = SecondaryReference{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_citationpb_citation_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SecondaryReference) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SecondaryReference) ProtoMessage() {}
+
+func (x *SecondaryReference) ProtoReflect() protoreflect.Message {
+	mi := &file_citationpb_citation_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SecondaryReference.ProtoReflect.Descriptor instead.
+func (*SecondaryReference) Descriptor() ([]byte, []int) {
+	return file_citationpb_citation_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SecondaryReference) GetAuthor() string {
+	if x != nil {
+		return x.Author
+	}
+	return ""
+}
+
+func (x *SecondaryReference) GetYear() string {
+	if x != nil {
+		return x.Year
+	}
+	return ""
+}
+
+func (x *SecondaryReference) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *SecondaryReference) GetPages() string {
+	if x != nil {
+		return x.Pages
+	}
+	return ""
+}
+
+func (x *SecondaryReference) GetAdLoc() bool {
+	if x != nil {
+		return x.AdLoc
+	}
+	return false
+}
+
+// DocumentMetadata describes a citation's source document, from its TEI
+// header.
+type DocumentMetadata struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Urn    string `protobuf:"bytes,1,opt,name=urn,proto3" json:"urn,omitempty"`
+	Title  string `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	Author string `protobuf:"bytes,3,opt,name=author,proto3" json:"author,omitempty"`
+	Editor string `protobuf:"bytes,4,opt,name=editor,proto3" json:"editor,omitempty"`
+	Date   string `protobuf:"bytes,5,opt,name=date,proto3" json:"date,omitempty"`
+}
+
+func (x *DocumentMetadata) Reset() {
+	*x = DocumentMetadata{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_citationpb_citation_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DocumentMetadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DocumentMetadata) ProtoMessage() {}
+
+func (x *DocumentMetadata) ProtoReflect() protoreflect.Message {
+	mi := &file_citationpb_citation_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DocumentMetadata.ProtoReflect.Descriptor instead.
+func (*DocumentMetadata) Descriptor() ([]byte, []int) {
+	return file_citationpb_citation_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *DocumentMetadata) GetUrn() string {
+	if x != nil {
+		return x.Urn
+	}
+	return ""
+}
+
+func (x *DocumentMetadata) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *DocumentMetadata) GetAuthor() string {
+	if x != nil {
+		return x.Author
+	}
+	return ""
+}
+
+func (x *DocumentMetadata) GetEditor() string {
+	if x != nil {
+		return x.Editor
+	}
+	return ""
+}
+
+func (x *DocumentMetadata) GetDate() string {
+	if x != nil {
+		return x.Date
+	}
+	return ""
+}
+
+type LocusLevel struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name  string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Value string `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (x *LocusLevel) Reset() {
+	*x = LocusLevel{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_citationpb_citation_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LocusLevel) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LocusLevel) ProtoMessage() {}
+
+func (x *LocusLevel) ProtoReflect() protoreflect.Message {
+	mi := &file_citationpb_citation_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LocusLevel.ProtoReflect.Descriptor instead.
+func (*LocusLevel) Descriptor() ([]byte, []int) {
+	return file_citationpb_citation_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *LocusLevel) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *LocusLevel) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+type ExtractRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Filename string `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"` // names the document in doc_cit_urn values
+	Xml      string `protobuf:"bytes,2,opt,name=xml,proto3" json:"xml,omitempty"`
+}
+
+func (x *ExtractRequest) Reset() {
+	*x = ExtractRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_citationpb_citation_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ExtractRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExtractRequest) ProtoMessage() {}
+
+func (x *ExtractRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_citationpb_citation_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExtractRequest.ProtoReflect.Descriptor instead.
+func (*ExtractRequest) Descriptor() ([]byte, []int) {
+	return file_citationpb_citation_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ExtractRequest) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *ExtractRequest) GetXml() string {
+	if x != nil {
+		return x.Xml
+	}
+	return ""
+}
+
+type ExtractResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Citations []*Citation `protobuf:"bytes,1,rep,name=citations,proto3" json:"citations,omitempty"`
+}
+
+func (x *ExtractResponse) Reset() {
+	*x = ExtractResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_citationpb_citation_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ExtractResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExtractResponse) ProtoMessage() {}
+
+func (x *ExtractResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_citationpb_citation_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExtractResponse.ProtoReflect.Descriptor instead.
+func (*ExtractResponse) Descriptor() ([]byte, []int) {
+	return file_citationpb_citation_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ExtractResponse) GetCitations() []*Citation {
+	if x != nil {
+		return x.Citations
+	}
+	return nil
+}
+
+// Citation carries the fields of a citation record in resolved.jsonl and
+// unresolved.jsonl.
+type Citation struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	NAttrib         string              `protobuf:"bytes,1,opt,name=n_attrib,json=nAttrib,proto3" json:"n_attrib,omitempty"`
+	Bibl            string              `protobuf:"bytes,2,opt,name=bibl,proto3" json:"bibl,omitempty"`
+	Ref             string              `protobuf:"bytes,3,opt,name=ref,proto3" json:"ref,omitempty"`
+	Urn             string              `protobuf:"bytes,4,opt,name=urn,proto3" json:"urn,omitempty"`
+	Quote           string              `protobuf:"bytes,5,opt,name=quote,proto3" json:"quote,omitempty"`
+	XmlContext      string              `protobuf:"bytes,6,opt,name=xml_context,json=xmlContext,proto3" json:"xml_context,omitempty"`
+	Filename        string              `protobuf:"bytes,7,opt,name=filename,proto3" json:"filename,omitempty"`
+	DocCitUrn       string              `protobuf:"bytes,8,opt,name=doc_cit_urn,json=docCitUrn,proto3" json:"doc_cit_urn,omitempty"`
+	Pattern         string              `protobuf:"bytes,9,opt,name=pattern,proto3" json:"pattern,omitempty"`
+	Warnings        []string            `protobuf:"bytes,10,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	Scheme          string              `protobuf:"bytes,11,opt,name=scheme,proto3" json:"scheme,omitempty"`
+	Candidates      []*Candidate        `protobuf:"bytes,12,rep,name=candidates,proto3" json:"candidates,omitempty"`
+	Works           []string            `protobuf:"bytes,13,rep,name=works,proto3" json:"works,omitempty"`
+	Qualifier       string              `protobuf:"bytes,14,opt,name=qualifier,proto3" json:"qualifier,omitempty"`
+	FallbackUsed    bool                `protobuf:"varint,15,opt,name=fallback_used,json=fallbackUsed,proto3" json:"fallback_used,omitempty"`
+	Locus           []*LocusLevel       `protobuf:"bytes,16,rep,name=locus,proto3" json:"locus,omitempty"`
+	Commentator     string              `protobuf:"bytes,17,opt,name=commentator,proto3" json:"commentator,omitempty"`
+	Note            string              `protobuf:"bytes,18,opt,name=note,proto3" json:"note,omitempty"`
+	Corrected       bool                `protobuf:"varint,19,opt,name=corrected,proto3" json:"corrected,omitempty"`
+	PreResolved     bool                `protobuf:"varint,20,opt,name=pre_resolved,json=preResolved,proto3" json:"pre_resolved,omitempty"`
+	ScaifeUrl       string              `protobuf:"bytes,21,opt,name=scaife_url,json=scaifeUrl,proto3" json:"scaife_url,omitempty"`
+	ProvisionalUrn  string              `protobuf:"bytes,22,opt,name=provisional_urn,json=provisionalUrn,proto3" json:"provisional_urn,omitempty"`
+	InApparatus     bool                `protobuf:"varint,23,opt,name=in_apparatus,json=inApparatus,proto3" json:"in_apparatus,omitempty"`
+	Lemma           string              `protobuf:"bytes,24,opt,name=lemma,proto3" json:"lemma,omitempty"`
+	FuzzyAuthor     *AuthorMatch        `protobuf:"bytes,25,opt,name=fuzzy_author,json=fuzzyAuthor,proto3" json:"fuzzy_author,omitempty"`
+	SectionHeading  string              `protobuf:"bytes,26,opt,name=section_heading,json=sectionHeading,proto3" json:"section_heading,omitempty"`
+	PriorUsed       bool                `protobuf:"varint,27,opt,name=prior_used,json=priorUsed,proto3" json:"prior_used,omitempty"`
+	Fragment        *Fragment           `protobuf:"bytes,28,opt,name=fragment,proto3" json:"fragment,omitempty"`
+	InQuote         bool                `protobuf:"varint,29,opt,name=in_quote,json=inQuote,proto3" json:"in_quote,omitempty"`
+	Provenance      []*DataEntry        `protobuf:"bytes,30,rep,name=provenance,proto3" json:"provenance,omitempty"`
+	ResolutionStep  string              `protobuf:"bytes,31,opt,name=resolution_step,json=resolutionStep,proto3" json:"resolution_step,omitempty"`
+	StepConfidence  float64             `protobuf:"fixed64,32,opt,name=step_confidence,json=stepConfidence,proto3" json:"step_confidence,omitempty"`
+	Edition         string              `protobuf:"bytes,33,opt,name=edition,proto3" json:"edition,omitempty"`
+	SchemeViolation string              `protobuf:"bytes,34,opt,name=scheme_violation,json=schemeViolation,proto3" json:"scheme_violation,omitempty"`
+	Document        *DocumentMetadata   `protobuf:"bytes,35,opt,name=document,proto3" json:"document,omitempty"`
+	QuotePosition   string              `protobuf:"bytes,36,opt,name=quote_position,json=quotePosition,proto3" json:"quote_position,omitempty"` // "before" or "after"
+	CommentedRange  string              `protobuf:"bytes,37,opt,name=commented_range,json=commentedRange,proto3" json:"commented_range,omitempty"`
+	CitationType    string              `protobuf:"bytes,38,opt,name=citation_type,json=citationType,proto3" json:"citation_type,omitempty"` // "quotation", "reference", "cross-reference" or "parallel"
+	DocumentId      string              `protobuf:"bytes,39,opt,name=document_id,json=documentId,proto3" json:"document_id,omitempty"`
+	GroupId         string              `protobuf:"bytes,40,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	Ellipsis        string              `protobuf:"bytes,41,opt,name=ellipsis,proto3" json:"ellipsis,omitempty"`
+	Documentary     *DocumentaryText    `protobuf:"bytes,42,opt,name=documentary,proto3" json:"documentary,omitempty"`
+	ExternalId      string              `protobuf:"bytes,43,opt,name=external_id,json=externalId,proto3" json:"external_id,omitempty"`
+	Secondary       *SecondaryReference `protobuf:"bytes,44,opt,name=secondary,proto3" json:"secondary,omitempty"`
+}
+
+func (x *Citation) Reset() {
+	*x = Citation{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_citationpb_citation_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Citation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Citation) ProtoMessage() {}
+
+func (x *Citation) ProtoReflect() protoreflect.Message {
+	mi := &file_citationpb_citation_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Citation.ProtoReflect.Descriptor instead.
+func (*Citation) Descriptor() ([]byte, []int) {
+	return file_citationpb_citation_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Citation) GetNAttrib() string {
+	if x != nil {
+		return x.NAttrib
+	}
+	return ""
+}
+
+func (x *Citation) GetBibl() string {
+	if x != nil {
+		return x.Bibl
+	}
+	return ""
+}
+
+func (x *Citation) GetRef() string {
+	if x != nil {
+		return x.Ref
+	}
+	return ""
+}
+
+func (x *Citation) GetUrn() string {
+	if x != nil {
+		return x.Urn
+	}
+	return ""
+}
+
+func (x *Citation) GetQuote() string {
+	if x != nil {
+		return x.Quote
+	}
+	return ""
+}
+
+func (x *Citation) GetXmlContext() string {
+	if x != nil {
+		return x.XmlContext
+	}
+	return ""
+}
+
+func (x *Citation) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *Citation) GetDocCitUrn() string {
+	if x != nil {
+		return x.DocCitUrn
+	}
+	return ""
+}
+
+func (x *Citation) GetPattern() string {
+	if x != nil {
+		return x.Pattern
+	}
+	return ""
+}
+
+func (x *Citation) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+func (x *Citation) GetScheme() string {
+	if x != nil {
+		return x.Scheme
+	}
+	return ""
+}
+
+func (x *Citation) GetCandidates() []*Candidate {
+	if x != nil {
+		return x.Candidates
+	}
+	return nil
+}
+
+func (x *Citation) GetWorks() []string {
+	if x != nil {
+		return x.Works
+	}
+	return nil
+}
+
+func (x *Citation) GetQualifier() string {
+	if x != nil {
+		return x.Qualifier
+	}
+	return ""
+}
+
+func (x *Citation) GetFallbackUsed() bool {
+	if x != nil {
+		return x.FallbackUsed
+	}
+	return false
+}
+
+func (x *Citation) GetLocus() []*LocusLevel {
+	if x != nil {
+		return x.Locus
+	}
+	return nil
+}
+
+func (x *Citation) GetCommentator() string {
+	if x != nil {
+		return x.Commentator
+	}
+	return ""
+}
+
+func (x *Citation) GetNote() string {
+	if x != nil {
+		return x.Note
+	}
+	return ""
+}
+
+func (x *Citation) GetCorrected() bool {
+	if x != nil {
+		return x.Corrected
+	}
+	return false
+}
+
+func (x *Citation) GetPreResolved() bool {
+	if x != nil {
+		return x.PreResolved
+	}
+	return false
+}
+
+func (x *Citation) GetScaifeUrl() string {
+	if x != nil {
+		return x.ScaifeUrl
+	}
+	return ""
+}
+
+func (x *Citation) GetProvisionalUrn() string {
+	if x != nil {
+		return x.ProvisionalUrn
+	}
+	return ""
+}
+
+func (x *Citation) GetInApparatus() bool {
+	if x != nil {
+		return x.InApparatus
+	}
+	return false
+}
+
+func (x *Citation) GetLemma() string {
+	if x != nil {
+		return x.Lemma
+	}
+	return ""
+}
+
+func (x *Citation) GetFuzzyAuthor() *AuthorMatch {
+	if x != nil {
+		return x.FuzzyAuthor
+	}
+	return nil
+}
+
+func (x *Citation) GetSectionHeading() string {
+	if x != nil {
+		return x.SectionHeading
+	}
+	return ""
+}
+
+func (x *Citation) GetPriorUsed() bool {
+	if x != nil {
+		return x.PriorUsed
+	}
+	return false
+}
+
+func (x *Citation) GetFragment() *Fragment {
+	if x != nil {
+		return x.Fragment
+	}
+	return nil
+}
+
+func (x *Citation) GetInQuote() bool {
+	if x != nil {
+		return x.InQuote
+	}
+	return false
+}
+
+func (x *Citation) GetProvenance() []*DataEntry {
+	if x != nil {
+		return x.Provenance
+	}
+	return nil
+}
+
+func (x *Citation) GetResolutionStep() string {
+	if x != nil {
+		return x.ResolutionStep
+	}
+	return ""
+}
+
+func (x *Citation) GetStepConfidence() float64 {
+	if x != nil {
+		return x.StepConfidence
+	}
+	return 0
+}
+
+func (x *Citation) GetEdition() string {
+	if x != nil {
+		return x.Edition
+	}
+	return ""
+}
+
+func (x *Citation) GetSchemeViolation() string {
+	if x != nil {
+		return x.SchemeViolation
+	}
+	return ""
+}
+
+func (x *Citation) GetDocument() *DocumentMetadata {
+	if x != nil {
+		return x.Document
+	}
+	return nil
+}
+
+func (x *Citation) GetQuotePosition() string {
+	if x != nil {
+		return x.QuotePosition
+	}
+	return ""
+}
+
+func (x *Citation) GetCommentedRange() string {
+	if x != nil {
+		return x.CommentedRange
+	}
+	return ""
+}
+
+func (x *Citation) GetCitationType() string {
+	if x != nil {
+		return x.CitationType
+	}
+	return ""
+}
+
+func (x *Citation) GetDocumentId() string {
+	if x != nil {
+		return x.DocumentId
+	}
+	return ""
+}
+
+func (x *Citation) GetGroupId() string {
+	if x != nil {
+		return x.GroupId
+	}
+	return ""
+}
+
+func (x *Citation) GetEllipsis() string {
+	if x != nil {
+		return x.Ellipsis
+	}
+	return ""
+}
+
+func (x *Citation) GetDocumentary() *DocumentaryText {
+	if x != nil {
+		return x.Documentary
+	}
+	return nil
+}
+
+func (x *Citation) GetExternalId() string {
+	if x != nil {
+		return x.ExternalId
+	}
+	return ""
+}
+
+func (x *Citation) GetSecondary() *SecondaryReference {
+	if x != nil {
+		return x.Secondary
+	}
+	return nil
+}
+
+var File_citationpb_citation_proto protoreflect.FileDescriptor
+
+var file_citationpb_citation_proto_rawDesc = []byte{
+	0x0a, 0x19, 0x63, 0x69, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x70, 0x62, 0x2f, 0x63, 0x69, 0x74,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x13, 0x70, 0x65, 0x72,
+	0x73, 0x65, 0x75, 0x73, 0x2e, 0x63, 0x69, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31,
+	0x22, 0x85, 0x01, 0x0a, 0x0e, 0x52, 0x65, 0x73, 0x6f, 0x6c, 0x76, 0x65, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x02, 0x69, 0x64, 0x12, 0x19, 0x0a, 0x08, 0x6e, 0x5f, 0x61, 0x74, 0x74, 0x72, 0x69, 0x62, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6e, 0x41, 0x74, 0x74, 0x72, 0x69, 0x62, 0x12, 0x12,
+	0x0a, 0x04, 0x62, 0x69, 0x62, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x62, 0x69,
+	0x62, 0x6c, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74, 0x12, 0x1a, 0x0a, 0x08,
+	0x66, 0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08,
+	0x66, 0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x22, 0xbd, 0x07, 0x0a, 0x0f, 0x52, 0x65, 0x73,
+	0x6f, 0x6c, 0x76, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x0e, 0x0a, 0x02,
+	0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x10, 0x0a, 0x03,
+	0x72, 0x65, 0x66, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x72, 0x65, 0x66, 0x12, 0x10,
+	0x0a, 0x03, 0x75, 0x72, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x75, 0x72, 0x6e,
+	0x12, 0x1a, 0x0a, 0x08, 0x77, 0x61, 0x72, 0x6e, 0x69, 0x6e, 0x67, 0x73, 0x18, 0x04, 0x20, 0x03,
+	0x28, 0x09, 0x52, 0x08, 0x77, 0x61, 0x72, 0x6e, 0x69, 0x6e, 0x67, 0x73, 0x12, 0x16, 0x0a, 0x06,
+	0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65,
+	0x61, 0x73, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x65, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x65, 0x12, 0x3e, 0x0a, 0x0a,
+	0x63, 0x61, 0x6e, 0x64, 0x69, 0x64, 0x61, 0x74, 0x65, 0x73, 0x18, 0x07, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x1e, 0x2e, 0x70, 0x65, 0x72, 0x73, 0x65, 0x75, 0x73, 0x2e, 0x63, 0x69, 0x74, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x61, 0x6e, 0x64, 0x69, 0x64, 0x61, 0x74, 0x65,
+	0x52, 0x0a, 0x63, 0x61, 0x6e, 0x64, 0x69, 0x64, 0x61, 0x74, 0x65, 0x73, 0x12, 0x14, 0x0a, 0x05,
+	0x77, 0x6f, 0x72, 0x6b, 0x73, 0x18, 0x08, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x77, 0x6f, 0x72,
+	0x6b, 0x73, 0x12, 0x1c, 0x0a, 0x09, 0x71, 0x75, 0x61, 0x6c, 0x69, 0x66, 0x69, 0x65, 0x72, 0x18,
+	0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x71, 0x75, 0x61, 0x6c, 0x69, 0x66, 0x69, 0x65, 0x72,
+	0x12, 0x23, 0x0a, 0x0d, 0x66, 0x61, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x5f, 0x75, 0x73, 0x65,
+	0x64, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0c, 0x66, 0x61, 0x6c, 0x6c, 0x62, 0x61, 0x63,
+	0x6b, 0x55, 0x73, 0x65, 0x64, 0x12, 0x35, 0x0a, 0x05, 0x6c, 0x6f, 0x63, 0x75, 0x73, 0x18, 0x0b,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x70, 0x65, 0x72, 0x73, 0x65, 0x75, 0x73, 0x2e, 0x63,
+	0x69, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x6f, 0x63, 0x75, 0x73,
+	0x4c, 0x65, 0x76, 0x65, 0x6c, 0x52, 0x05, 0x6c, 0x6f, 0x63, 0x75, 0x73, 0x12, 0x20, 0x0a, 0x0b,
+	0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x6e, 0x74, 0x61, 0x74, 0x6f, 0x72, 0x18, 0x0c, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x6e, 0x74, 0x61, 0x74, 0x6f, 0x72, 0x12, 0x12,
+	0x0a, 0x04, 0x6e, 0x6f, 0x74, 0x65, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x6f,
+	0x74, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x63, 0x6f, 0x72, 0x72, 0x65, 0x63, 0x74, 0x65, 0x64, 0x18,
+	0x0e, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x63, 0x6f, 0x72, 0x72, 0x65, 0x63, 0x74, 0x65, 0x64,
+	0x12, 0x27, 0x0a, 0x0f, 0x70, 0x72, 0x6f, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x61, 0x6c, 0x5f,
+	0x75, 0x72, 0x6e, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x70, 0x72, 0x6f, 0x76, 0x69,
+	0x73, 0x69, 0x6f, 0x6e, 0x61, 0x6c, 0x55, 0x72, 0x6e, 0x12, 0x43, 0x0a, 0x0c, 0x66, 0x75, 0x7a,
+	0x7a, 0x79, 0x5f, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x18, 0x10, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x20, 0x2e, 0x70, 0x65, 0x72, 0x73, 0x65, 0x75, 0x73, 0x2e, 0x63, 0x69, 0x74, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x4d, 0x61, 0x74, 0x63,
+	0x68, 0x52, 0x0b, 0x66, 0x75, 0x7a, 0x7a, 0x79, 0x41, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x12, 0x39,
+	0x0a, 0x08, 0x66, 0x72, 0x61, 0x67, 0x6d, 0x65, 0x6e, 0x74, 0x18, 0x11, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x1d, 0x2e, 0x70, 0x65, 0x72, 0x73, 0x65, 0x75, 0x73, 0x2e, 0x63, 0x69, 0x74, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x46, 0x72, 0x61, 0x67, 0x6d, 0x65, 0x6e, 0x74, 0x52,
+	0x08, 0x66, 0x72, 0x61, 0x67, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x3e, 0x0a, 0x0a, 0x70, 0x72, 0x6f,
+	0x76, 0x65, 0x6e, 0x61, 0x6e, 0x63, 0x65, 0x18, 0x12, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1e, 0x2e,
+	0x70, 0x65, 0x72, 0x73, 0x65, 0x75, 0x73, 0x2e, 0x63, 0x69, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x2e, 0x76, 0x31, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0a, 0x70,
+	0x72, 0x6f, 0x76, 0x65, 0x6e, 0x61, 0x6e, 0x63, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x72, 0x65, 0x73,
+	0x6f, 0x6c, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x73, 0x74, 0x65, 0x70, 0x18, 0x13, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0e, 0x72, 0x65, 0x73, 0x6f, 0x6c, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x74,
+	0x65, 0x70, 0x12, 0x27, 0x0a, 0x0f, 0x73, 0x74, 0x65, 0x70, 0x5f, 0x63, 0x6f, 0x6e, 0x66, 0x69,
+	0x64, 0x65, 0x6e, 0x63, 0x65, 0x18, 0x14, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0e, 0x73, 0x74, 0x65,
+	0x70, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x64, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x65,
+	0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x15, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x65, 0x64,
+	0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x29, 0x0a, 0x10, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x65, 0x5f,
+	0x76, 0x69, 0x6f, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x16, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0f, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x65, 0x56, 0x69, 0x6f, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x12, 0x1d, 0x0a, 0x0a, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x5f, 0x75, 0x73, 0x65, 0x64, 0x18, 0x17,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x55, 0x73, 0x65, 0x64, 0x12,
+	0x46, 0x0a, 0x0b, 0x64, 0x6f, 0x63, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x61, 0x72, 0x79, 0x18, 0x18,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x24, 0x2e, 0x70, 0x65, 0x72, 0x73, 0x65, 0x75, 0x73, 0x2e, 0x63,
+	0x69, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x6f, 0x63, 0x75, 0x6d,
+	0x65, 0x6e, 0x74, 0x61, 0x72, 0x79, 0x54, 0x65, 0x78, 0x74, 0x52, 0x0b, 0x64, 0x6f, 0x63, 0x75,
+	0x6d, 0x65, 0x6e, 0x74, 0x61, 0x72, 0x79, 0x12, 0x1f, 0x0a, 0x0b, 0x65, 0x78, 0x74, 0x65, 0x72,
+	0x6e, 0x61, 0x6c, 0x5f, 0x69, 0x64, 0x18, 0x19, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x65, 0x78,
+	0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x49, 0x64, 0x22, 0x33, 0x0a, 0x09, 0x43, 0x61, 0x6e, 0x64,
+	0x69, 0x64, 0x61, 0x74, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x75, 0x72, 0x6e, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x03, 0x75, 0x72, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x63, 0x6f, 0x72, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x05, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x22, 0x78, 0x0a,
+	0x0b, 0x41, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x12, 0x14, 0x0a, 0x05,
+	0x69, 0x6e, 0x70, 0x75, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x69, 0x6e, 0x70,
+	0x75, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x65, 0x64, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x65, 0x64, 0x12, 0x23, 0x0a, 0x0d,
+	0x65, 0x64, 0x69, 0x74, 0x5f, 0x64, 0x69, 0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x0c, 0x65, 0x64, 0x69, 0x74, 0x44, 0x69, 0x73, 0x74, 0x61, 0x6e, 0x63,
+	0x65, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x01,
+	0x52, 0x05, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x22, 0x82, 0x01, 0x0a, 0x08, 0x46, 0x72, 0x61, 0x67,
+	0x6d, 0x65, 0x6e, 0x74, 0x12, 0x1e, 0x0a, 0x0a, 0x63, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x69,
+	0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x63, 0x6f, 0x6c, 0x6c, 0x65, 0x63,
+	0x74, 0x69, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x12, 0x12, 0x0a, 0x04,
+	0x6e, 0x61, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65,
+	0x12, 0x12, 0x0a, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x6b, 0x69, 0x6e, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x22, 0x35, 0x0a, 0x09,
+	0x44, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x66, 0x69, 0x6c,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x66, 0x69, 0x6c, 0x65, 0x12, 0x14, 0x0a,
+	0x05, 0x65, 0x6e, 0x74, 0x72, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65, 0x6e,
+	0x74, 0x72, 0x79, 0x22, 0x87, 0x01, 0x0a, 0x0f, 0x44, 0x6f, 0x63, 0x75, 0x6d, 0x65, 0x6e, 0x74,
+	0x61, 0x72, 0x79, 0x54, 0x65, 0x78, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x6f, 0x72, 0x70, 0x75,
+	0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x63, 0x6f, 0x72, 0x70, 0x75, 0x73, 0x12,
+	0x12, 0x0a, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6b,
+	0x69, 0x6e, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x76, 0x6f, 0x6c, 0x75, 0x6d, 0x65, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x06, 0x76, 0x6f, 0x6c, 0x75, 0x6d, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x65,
+	0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x65, 0x64,
+	0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x22, 0x83, 0x01,
+	0x0a, 0x12, 0x53, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x61, 0x72, 0x79, 0x52, 0x65, 0x66, 0x65, 0x72,
+	0x65, 0x6e, 0x63, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x12, 0x12, 0x0a, 0x04,
+	0x79, 0x65, 0x61, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x79, 0x65, 0x61, 0x72,
+	0x12, 0x14, 0x0a, 0x05, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x05, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x70, 0x61, 0x67, 0x65, 0x73, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x70, 0x61, 0x67, 0x65, 0x73, 0x12, 0x15, 0x0a, 0x06,
+	0x61, 0x64, 0x5f, 0x6c, 0x6f, 0x63, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x61, 0x64,
+	0x4c, 0x6f, 0x63, 0x22, 0x7e, 0x0a, 0x10, 0x44, 0x6f, 0x63, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x4d,
+	0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x12, 0x10, 0x0a, 0x03, 0x75, 0x72, 0x6e, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x75, 0x72, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x69, 0x74,
+	0x6c, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x12,
+	0x16, 0x0a, 0x06, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x06, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x12, 0x16, 0x0a, 0x06, 0x65, 0x64, 0x69, 0x74, 0x6f,
+	0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x65, 0x64, 0x69, 0x74, 0x6f, 0x72, 0x12,
+	0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x64,
+	0x61, 0x74, 0x65, 0x22, 0x36, 0x0a, 0x0a, 0x4c, 0x6f, 0x63, 0x75, 0x73, 0x4c, 0x65, 0x76, 0x65,
+	0x6c, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x3e, 0x0a, 0x0e, 0x45,
+	0x78, 0x74, 0x72, 0x61, 0x63, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1a, 0x0a,
+	0x08, 0x66, 0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x08, 0x66, 0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x78, 0x6d, 0x6c,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x78, 0x6d, 0x6c, 0x22, 0x4e, 0x0a, 0x0f, 0x45,
+	0x78, 0x74, 0x72, 0x61, 0x63, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3b,
+	0x0a, 0x09, 0x63, 0x69, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x1d, 0x2e, 0x70, 0x65, 0x72, 0x73, 0x65, 0x75, 0x73, 0x2e, 0x63, 0x69, 0x74, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x69, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x52, 0x09, 0x63, 0x69, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x22, 0xe0, 0x0c, 0x0a, 0x08,
+	0x43, 0x69, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x19, 0x0a, 0x08, 0x6e, 0x5f, 0x61, 0x74,
+	0x74, 0x72, 0x69, 0x62, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6e, 0x41, 0x74, 0x74,
+	0x72, 0x69, 0x62, 0x12, 0x12, 0x0a, 0x04, 0x62, 0x69, 0x62, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x62, 0x69, 0x62, 0x6c, 0x12, 0x10, 0x0a, 0x03, 0x72, 0x65, 0x66, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x72, 0x65, 0x66, 0x12, 0x10, 0x0a, 0x03, 0x75, 0x72, 0x6e,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x75, 0x72, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x71,
+	0x75, 0x6f, 0x74, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x71, 0x75, 0x6f, 0x74,
+	0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x78, 0x6d, 0x6c, 0x5f, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x78, 0x74,
+	0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x78, 0x6d, 0x6c, 0x43, 0x6f, 0x6e, 0x74, 0x65,
+	0x78, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x66, 0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x07,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x66, 0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x1e,
+	0x0a, 0x0b, 0x64, 0x6f, 0x63, 0x5f, 0x63, 0x69, 0x74, 0x5f, 0x75, 0x72, 0x6e, 0x18, 0x08, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x09, 0x64, 0x6f, 0x63, 0x43, 0x69, 0x74, 0x55, 0x72, 0x6e, 0x12, 0x18,
+	0x0a, 0x07, 0x70, 0x61, 0x74, 0x74, 0x65, 0x72, 0x6e, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x07, 0x70, 0x61, 0x74, 0x74, 0x65, 0x72, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x77, 0x61, 0x72, 0x6e,
+	0x69, 0x6e, 0x67, 0x73, 0x18, 0x0a, 0x20, 0x03, 0x28, 0x09, 0x52, 0x08, 0x77, 0x61, 0x72, 0x6e,
+	0x69, 0x6e, 0x67, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x65, 0x18, 0x0b,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x65, 0x12, 0x3e, 0x0a, 0x0a,
+	0x63, 0x61, 0x6e, 0x64, 0x69, 0x64, 0x61, 0x74, 0x65, 0x73, 0x18, 0x0c, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x1e, 0x2e, 0x70, 0x65, 0x72, 0x73, 0x65, 0x75, 0x73, 0x2e, 0x63, 0x69, 0x74, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x61, 0x6e, 0x64, 0x69, 0x64, 0x61, 0x74, 0x65,
+	0x52, 0x0a, 0x63, 0x61, 0x6e, 0x64, 0x69, 0x64, 0x61, 0x74, 0x65, 0x73, 0x12, 0x14, 0x0a, 0x05,
+	0x77, 0x6f, 0x72, 0x6b, 0x73, 0x18, 0x0d, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x77, 0x6f, 0x72,
+	0x6b, 0x73, 0x12, 0x1c, 0x0a, 0x09, 0x71, 0x75, 0x61, 0x6c, 0x69, 0x66, 0x69, 0x65, 0x72, 0x18,
+	0x0e, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x71, 0x75, 0x61, 0x6c, 0x69, 0x66, 0x69, 0x65, 0x72,
+	0x12, 0x23, 0x0a, 0x0d, 0x66, 0x61, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x5f, 0x75, 0x73, 0x65,
+	0x64, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0c, 0x66, 0x61, 0x6c, 0x6c, 0x62, 0x61, 0x63,
+	0x6b, 0x55, 0x73, 0x65, 0x64, 0x12, 0x35, 0x0a, 0x05, 0x6c, 0x6f, 0x63, 0x75, 0x73, 0x18, 0x10,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x70, 0x65, 0x72, 0x73, 0x65, 0x75, 0x73, 0x2e, 0x63,
+	0x69, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x6f, 0x63, 0x75, 0x73,
+	0x4c, 0x65, 0x76, 0x65, 0x6c, 0x52, 0x05, 0x6c, 0x6f, 0x63, 0x75, 0x73, 0x12, 0x20, 0x0a, 0x0b,
+	0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x6e, 0x74, 0x61, 0x74, 0x6f, 0x72, 0x18, 0x11, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x6e, 0x74, 0x61, 0x74, 0x6f, 0x72, 0x12, 0x12,
+	0x0a, 0x04, 0x6e, 0x6f, 0x74, 0x65, 0x18, 0x12, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x6f,
+	0x74, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x63, 0x6f, 0x72, 0x72, 0x65, 0x63, 0x74, 0x65, 0x64, 0x18,
+	0x13, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x63, 0x6f, 0x72, 0x72, 0x65, 0x63, 0x74, 0x65, 0x64,
+	0x12, 0x21, 0x0a, 0x0c, 0x70, 0x72, 0x65, 0x5f, 0x72, 0x65, 0x73, 0x6f, 0x6c, 0x76, 0x65, 0x64,
+	0x18, 0x14, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0b, 0x70, 0x72, 0x65, 0x52, 0x65, 0x73, 0x6f, 0x6c,
+	0x76, 0x65, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x73, 0x63, 0x61, 0x69, 0x66, 0x65, 0x5f, 0x75, 0x72,
+	0x6c, 0x18, 0x15, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x73, 0x63, 0x61, 0x69, 0x66, 0x65, 0x55,
+	0x72, 0x6c, 0x12, 0x27, 0x0a, 0x0f, 0x70, 0x72, 0x6f, 0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x61,
+	0x6c, 0x5f, 0x75, 0x72, 0x6e, 0x18, 0x16, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x70, 0x72, 0x6f,
+	0x76, 0x69, 0x73, 0x69, 0x6f, 0x6e, 0x61, 0x6c, 0x55, 0x72, 0x6e, 0x12, 0x21, 0x0a, 0x0c, 0x69,
+	0x6e, 0x5f, 0x61, 0x70, 0x70, 0x61, 0x72, 0x61, 0x74, 0x75, 0x73, 0x18, 0x17, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x0b, 0x69, 0x6e, 0x41, 0x70, 0x70, 0x61, 0x72, 0x61, 0x74, 0x75, 0x73, 0x12, 0x14,
+	0x0a, 0x05, 0x6c, 0x65, 0x6d, 0x6d, 0x61, 0x18, 0x18, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x6c,
+	0x65, 0x6d, 0x6d, 0x61, 0x12, 0x43, 0x0a, 0x0c, 0x66, 0x75, 0x7a, 0x7a, 0x79, 0x5f, 0x61, 0x75,
+	0x74, 0x68, 0x6f, 0x72, 0x18, 0x19, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x70, 0x65, 0x72,
+	0x73, 0x65, 0x75, 0x73, 0x2e, 0x63, 0x69, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31,
+	0x2e, 0x41, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x4d, 0x61, 0x74, 0x63, 0x68, 0x52, 0x0b, 0x66, 0x75,
+	0x7a, 0x7a, 0x79, 0x41, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x12, 0x27, 0x0a, 0x0f, 0x73, 0x65, 0x63,
+	0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x68, 0x65, 0x61, 0x64, 0x69, 0x6e, 0x67, 0x18, 0x1a, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0e, 0x73, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x65, 0x61, 0x64, 0x69,
+	0x6e, 0x67, 0x12, 0x1d, 0x0a, 0x0a, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x5f, 0x75, 0x73, 0x65, 0x64,
+	0x18, 0x1b, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x70, 0x72, 0x69, 0x6f, 0x72, 0x55, 0x73, 0x65,
+	0x64, 0x12, 0x39, 0x0a, 0x08, 0x66, 0x72, 0x61, 0x67, 0x6d, 0x65, 0x6e, 0x74, 0x18, 0x1c, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x70, 0x65, 0x72, 0x73, 0x65, 0x75, 0x73, 0x2e, 0x63, 0x69,
+	0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x46, 0x72, 0x61, 0x67, 0x6d, 0x65,
+	0x6e, 0x74, 0x52, 0x08, 0x66, 0x72, 0x61, 0x67, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x19, 0x0a, 0x08,
+	0x69, 0x6e, 0x5f, 0x71, 0x75, 0x6f, 0x74, 0x65, 0x18, 0x1d, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07,
+	0x69, 0x6e, 0x51, 0x75, 0x6f, 0x74, 0x65, 0x12, 0x3e, 0x0a, 0x0a, 0x70, 0x72, 0x6f, 0x76, 0x65,
+	0x6e, 0x61, 0x6e, 0x63, 0x65, 0x18, 0x1e, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x70, 0x65,
+	0x72, 0x73, 0x65, 0x75, 0x73, 0x2e, 0x63, 0x69, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x76,
+	0x31, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0a, 0x70, 0x72, 0x6f,
+	0x76, 0x65, 0x6e, 0x61, 0x6e, 0x63, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x72, 0x65, 0x73, 0x6f, 0x6c,
+	0x75, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x73, 0x74, 0x65, 0x70, 0x18, 0x1f, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0e, 0x72, 0x65, 0x73, 0x6f, 0x6c, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x74, 0x65, 0x70,
+	0x12, 0x27, 0x0a, 0x0f, 0x73, 0x74, 0x65, 0x70, 0x5f, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x64, 0x65,
+	0x6e, 0x63, 0x65, 0x18, 0x20, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0e, 0x73, 0x74, 0x65, 0x70, 0x43,
+	0x6f, 0x6e, 0x66, 0x69, 0x64, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x65, 0x64, 0x69,
+	0x74, 0x69, 0x6f, 0x6e, 0x18, 0x21, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x65, 0x64, 0x69, 0x74,
+	0x69, 0x6f, 0x6e, 0x12, 0x29, 0x0a, 0x10, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x65, 0x5f, 0x76, 0x69,
+	0x6f, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x22, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0f, 0x73,
+	0x63, 0x68, 0x65, 0x6d, 0x65, 0x56, 0x69, 0x6f, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x41,
+	0x0a, 0x08, 0x64, 0x6f, 0x63, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x18, 0x23, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x25, 0x2e, 0x70, 0x65, 0x72, 0x73, 0x65, 0x75, 0x73, 0x2e, 0x63, 0x69, 0x74, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x6f, 0x63, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x4d,
+	0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x52, 0x08, 0x64, 0x6f, 0x63, 0x75, 0x6d, 0x65, 0x6e,
+	0x74, 0x12, 0x25, 0x0a, 0x0e, 0x71, 0x75, 0x6f, 0x74, 0x65, 0x5f, 0x70, 0x6f, 0x73, 0x69, 0x74,
+	0x69, 0x6f, 0x6e, 0x18, 0x24, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x71, 0x75, 0x6f, 0x74, 0x65,
+	0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x27, 0x0a, 0x0f, 0x63, 0x6f, 0x6d, 0x6d,
+	0x65, 0x6e, 0x74, 0x65, 0x64, 0x5f, 0x72, 0x61, 0x6e, 0x67, 0x65, 0x18, 0x25, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0e, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x6e, 0x74, 0x65, 0x64, 0x52, 0x61, 0x6e, 0x67,
+	0x65, 0x12, 0x23, 0x0a, 0x0d, 0x63, 0x69, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x74, 0x79,
+	0x70, 0x65, 0x18, 0x26, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x63, 0x69, 0x74, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x54, 0x79, 0x70, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x64, 0x6f, 0x63, 0x75, 0x6d, 0x65,
+	0x6e, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x27, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x64, 0x6f, 0x63,
+	0x75, 0x6d, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x12, 0x19, 0x0a, 0x08, 0x67, 0x72, 0x6f, 0x75, 0x70,
+	0x5f, 0x69, 0x64, 0x18, 0x28, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x67, 0x72, 0x6f, 0x75, 0x70,
+	0x49, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x65, 0x6c, 0x6c, 0x69, 0x70, 0x73, 0x69, 0x73, 0x18, 0x29,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x65, 0x6c, 0x6c, 0x69, 0x70, 0x73, 0x69, 0x73, 0x12, 0x46,
+	0x0a, 0x0b, 0x64, 0x6f, 0x63, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x61, 0x72, 0x79, 0x18, 0x2a, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x24, 0x2e, 0x70, 0x65, 0x72, 0x73, 0x65, 0x75, 0x73, 0x2e, 0x63, 0x69,
+	0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x6f, 0x63, 0x75, 0x6d, 0x65,
+	0x6e, 0x74, 0x61, 0x72, 0x79, 0x54, 0x65, 0x78, 0x74, 0x52, 0x0b, 0x64, 0x6f, 0x63, 0x75, 0x6d,
+	0x65, 0x6e, 0x74, 0x61, 0x72, 0x79, 0x12, 0x1f, 0x0a, 0x0b, 0x65, 0x78, 0x74, 0x65, 0x72, 0x6e,
+	0x61, 0x6c, 0x5f, 0x69, 0x64, 0x18, 0x2b, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x65, 0x78, 0x74,
+	0x65, 0x72, 0x6e, 0x61, 0x6c, 0x49, 0x64, 0x12, 0x45, 0x0a, 0x09, 0x73, 0x65, 0x63, 0x6f, 0x6e,
+	0x64, 0x61, 0x72, 0x79, 0x18, 0x2c, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x27, 0x2e, 0x70, 0x65, 0x72,
+	0x73, 0x65, 0x75, 0x73, 0x2e, 0x63, 0x69, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31,
+	0x2e, 0x53, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x61, 0x72, 0x79, 0x52, 0x65, 0x66, 0x65, 0x72, 0x65,
+	0x6e, 0x63, 0x65, 0x52, 0x09, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x61, 0x72, 0x79, 0x32, 0x95,
+	0x02, 0x0a, 0x08, 0x52, 0x65, 0x73, 0x6f, 0x6c, 0x76, 0x65, 0x72, 0x12, 0x54, 0x0a, 0x07, 0x52,
+	0x65, 0x73, 0x6f, 0x6c, 0x76, 0x65, 0x12, 0x23, 0x2e, 0x70, 0x65, 0x72, 0x73, 0x65, 0x75, 0x73,
+	0x2e, 0x63, 0x69, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x73,
+	0x6f, 0x6c, 0x76, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x24, 0x2e, 0x70, 0x65,
+	0x72, 0x73, 0x65, 0x75, 0x73, 0x2e, 0x63, 0x69, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x76,
+	0x31, 0x2e, 0x52, 0x65, 0x73, 0x6f, 0x6c, 0x76, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x5d, 0x0a, 0x0c, 0x52, 0x65, 0x73, 0x6f, 0x6c, 0x76, 0x65, 0x42, 0x61, 0x74, 0x63,
+	0x68, 0x12, 0x23, 0x2e, 0x70, 0x65, 0x72, 0x73, 0x65, 0x75, 0x73, 0x2e, 0x63, 0x69, 0x74, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x73, 0x6f, 0x6c, 0x76, 0x65, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x24, 0x2e, 0x70, 0x65, 0x72, 0x73, 0x65, 0x75, 0x73,
+	0x2e, 0x63, 0x69, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x73,
+	0x6f, 0x6c, 0x76, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x28, 0x01, 0x30, 0x01,
+	0x12, 0x54, 0x0a, 0x07, 0x45, 0x78, 0x74, 0x72, 0x61, 0x63, 0x74, 0x12, 0x23, 0x2e, 0x70, 0x65,
+	0x72, 0x73, 0x65, 0x75, 0x73, 0x2e, 0x63, 0x69, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x76,
+	0x31, 0x2e, 0x45, 0x78, 0x74, 0x72, 0x61, 0x63, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x24, 0x2e, 0x70, 0x65, 0x72, 0x73, 0x65, 0x75, 0x73, 0x2e, 0x63, 0x69, 0x74, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x78, 0x74, 0x72, 0x61, 0x63, 0x74, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x28, 0x5a, 0x26, 0x70, 0x65, 0x72, 0x73, 0x65, 0x75,
+	0x73, 0x5f, 0x63, 0x69, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x6c, 0x69, 0x6e, 0x6b, 0x65,
+	0x72, 0x2f, 0x70, 0x6b, 0x67, 0x2f, 0x63, 0x69, 0x74, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x70, 0x62,
+	0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_citationpb_citation_proto_rawDescOnce sync.Once
+	file_citationpb_citation_proto_rawDescData = file_citationpb_citation_proto_rawDesc
+)
+
+func file_citationpb_citation_proto_rawDescGZIP() []byte {
+	file_citationpb_citation_proto_rawDescOnce.Do(func() {
+		file_citationpb_citation_proto_rawDescData = protoimpl.X.CompressGZIP(file_citationpb_citation_proto_rawDescData)
+	})
+	return file_citationpb_citation_proto_rawDescData
+}
+
+var file_citationpb_citation_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_citationpb_citation_proto_goTypes = []interface{}{
+	(*ResolveRequest)(nil),     // 0: perseus.citation.v1.ResolveRequest
+	(*ResolveResponse)(nil),    // 1: perseus.citation.v1.ResolveResponse
+	(*Candidate)(nil),          // 2: perseus.citation.v1.Candidate
+	(*AuthorMatch)(nil),        // 3: perseus.citation.v1.AuthorMatch
+	(*Fragment)(nil),           // 4: perseus.citation.v1.Fragment
+	(*DataEntry)(nil),          // 5: perseus.citation.v1.DataEntry
+	(*DocumentaryText)(nil),    // 6: perseus.citation.v1.DocumentaryText
+	(*SecondaryReference)(nil), // 7: perseus.citation.v1.SecondaryReference
+	(*DocumentMetadata)(nil),   // 8: perseus.citation.v1.DocumentMetadata
+	(*LocusLevel)(nil),         // 9: perseus.citation.v1.LocusLevel
+	(*ExtractRequest)(nil),     // 10: perseus.citation.v1.ExtractRequest
+	(*ExtractResponse)(nil),    // 11: perseus.citation.v1.ExtractResponse
+	(*Citation)(nil),           // 12: perseus.citation.v1.Citation
+}
+var file_citationpb_citation_proto_depIdxs = []int32{
+	2,  // 0: perseus.citation.v1.ResolveResponse.candidates:type_name -> perseus.citation.v1.Candidate
+	9,  // 1: perseus.citation.v1.ResolveResponse.locus:type_name -> perseus.citation.v1.LocusLevel
+	3,  // 2: perseus.citation.v1.ResolveResponse.fuzzy_author:type_name -> perseus.citation.v1.AuthorMatch
+	4,  // 3: perseus.citation.v1.ResolveResponse.fragment:type_name -> perseus.citation.v1.Fragment
+	5,  // 4: perseus.citation.v1.ResolveResponse.provenance:type_name -> perseus.citation.v1.DataEntry
+	6,  // 5: perseus.citation.v1.ResolveResponse.documentary:type_name -> perseus.citation.v1.DocumentaryText
+	12, // 6: perseus.citation.v1.ExtractResponse.citations:type_name -> perseus.citation.v1.Citation
+	2,  // 7: perseus.citation.v1.Citation.candidates:type_name -> perseus.citation.v1.Candidate
+	9,  // 8: perseus.citation.v1.Citation.locus:type_name -> perseus.citation.v1.LocusLevel
+	3,  // 9: perseus.citation.v1.Citation.fuzzy_author:type_name -> perseus.citation.v1.AuthorMatch
+	4,  // 10: perseus.citation.v1.Citation.fragment:type_name -> perseus.citation.v1.Fragment
+	5,  // 11: perseus.citation.v1.Citation.provenance:type_name -> perseus.citation.v1.DataEntry
+	8,  // 12: perseus.citation.v1.Citation.document:type_name -> perseus.citation.v1.DocumentMetadata
+	6,  // 13: perseus.citation.v1.Citation.documentary:type_name -> perseus.citation.v1.DocumentaryText
+	7,  // 14: perseus.citation.v1.Citation.secondary:type_name -> perseus.citation.v1.SecondaryReference
+	0,  // 15: perseus.citation.v1.Resolver.Resolve:input_type -> perseus.citation.v1.ResolveRequest
+	0,  // 16: perseus.citation.v1.Resolver.ResolveBatch:input_type -> perseus.citation.v1.ResolveRequest
+	10, // 17: perseus.citation.v1.Resolver.Extract:input_type -> perseus.citation.v1.ExtractRequest
+	1,  // 18: perseus.citation.v1.Resolver.Resolve:output_type -> perseus.citation.v1.ResolveResponse
+	1,  // 19: perseus.citation.v1.Resolver.ResolveBatch:output_type -> perseus.citation.v1.ResolveResponse
+	11, // 20: perseus.citation.v1.Resolver.Extract:output_type -> perseus.citation.v1.ExtractResponse
+	18, // [18:21] is the sub-list for method output_type
+	15, // [15:18] is the sub-list for method input_type
+	15, // [15:15] is the sub-list for extension type_name
+	15, // [15:15] is the sub-list for extension extendee
+	0,  // [0:15] is the sub-list for field type_name
+}
+
+func init() { file_citationpb_citation_proto_init() }
+func file_citationpb_citation_proto_init() {
+	if File_citationpb_citation_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_citationpb_citation_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ResolveRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_citationpb_citation_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ResolveResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_citationpb_citation_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Candidate); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_citationpb_citation_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AuthorMatch); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_citationpb_citation_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Fragment); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_citationpb_citation_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DataEntry); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_citationpb_citation_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DocumentaryText); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_citationpb_citation_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SecondaryReference); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_citationpb_citation_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DocumentMetadata); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_citationpb_citation_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LocusLevel); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_citationpb_citation_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ExtractRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_citationpb_citation_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ExtractResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_citationpb_citation_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Citation); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_citationpb_citation_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   13,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_citationpb_citation_proto_goTypes,
+		DependencyIndexes: file_citationpb_citation_proto_depIdxs,
+		MessageInfos:      file_citationpb_citation_proto_msgTypes,
+	}.Build()
+	File_citationpb_citation_proto = out.File
+	file_citationpb_citation_proto_rawDesc = nil
+	file_citationpb_citation_proto_goTypes = nil
+	file_citationpb_citation_proto_depIdxs = nil
+}
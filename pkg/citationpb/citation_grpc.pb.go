@@ -0,0 +1,227 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.24.4
+// source: citationpb/citation.proto
+
+// The citation resolver as a long-lived service, for pipelines that resolve
+// references in bulk without starting a process per document.
+
+package citationpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Resolver_Resolve_FullMethodName      = "/perseus.citation.v1.Resolver/Resolve"
+	Resolver_ResolveBatch_FullMethodName = "/perseus.citation.v1.Resolver/ResolveBatch"
+	Resolver_Extract_FullMethodName      = "/perseus.citation.v1.Resolver/Extract"
+)
+
+// ResolverClient is the client API for Resolver service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ResolverClient interface {
+	// Resolve resolves one reference.
+	Resolve(ctx context.Context, in *ResolveRequest, opts ...grpc.CallOption) (*ResolveResponse, error)
+	// ResolveBatch resolves a stream of references, answering each in the
+	// order received. Responses carry the id of their request.
+	ResolveBatch(ctx context.Context, opts ...grpc.CallOption) (Resolver_ResolveBatchClient, error)
+	// Extract extracts and resolves the citations of a TEI XML document.
+	Extract(ctx context.Context, in *ExtractRequest, opts ...grpc.CallOption) (*ExtractResponse, error)
+}
+
+type resolverClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewResolverClient(cc grpc.ClientConnInterface) ResolverClient {
+	return &resolverClient{cc}
+}
+
+func (c *resolverClient) Resolve(ctx context.Context, in *ResolveRequest, opts ...grpc.CallOption) (*ResolveResponse, error) {
+	out := new(ResolveResponse)
+	err := c.cc.Invoke(ctx, Resolver_Resolve_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *resolverClient) ResolveBatch(ctx context.Context, opts ...grpc.CallOption) (Resolver_ResolveBatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Resolver_ServiceDesc.Streams[0], Resolver_ResolveBatch_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &resolverResolveBatchClient{stream}
+	return x, nil
+}
+
+type Resolver_ResolveBatchClient interface {
+	Send(*ResolveRequest) error
+	Recv() (*ResolveResponse, error)
+	grpc.ClientStream
+}
+
+type resolverResolveBatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *resolverResolveBatchClient) Send(m *ResolveRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *resolverResolveBatchClient) Recv() (*ResolveResponse, error) {
+	m := new(ResolveResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *resolverClient) Extract(ctx context.Context, in *ExtractRequest, opts ...grpc.CallOption) (*ExtractResponse, error) {
+	out := new(ExtractResponse)
+	err := c.cc.Invoke(ctx, Resolver_Extract_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ResolverServer is the server API for Resolver service.
+// All implementations must embed UnimplementedResolverServer
+// for forward compatibility
+type ResolverServer interface {
+	// Resolve resolves one reference.
+	Resolve(context.Context, *ResolveRequest) (*ResolveResponse, error)
+	// ResolveBatch resolves a stream of references, answering each in the
+	// order received. Responses carry the id of their request.
+	ResolveBatch(Resolver_ResolveBatchServer) error
+	// Extract extracts and resolves the citations of a TEI XML document.
+	Extract(context.Context, *ExtractRequest) (*ExtractResponse, error)
+	mustEmbedUnimplementedResolverServer()
+}
+
+// UnimplementedResolverServer must be embedded to have forward compatible implementations.
+type UnimplementedResolverServer struct {
+}
+
+func (UnimplementedResolverServer) Resolve(context.Context, *ResolveRequest) (*ResolveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Resolve not implemented")
+}
+func (UnimplementedResolverServer) ResolveBatch(Resolver_ResolveBatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method ResolveBatch not implemented")
+}
+func (UnimplementedResolverServer) Extract(context.Context, *ExtractRequest) (*ExtractResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Extract not implemented")
+}
+func (UnimplementedResolverServer) mustEmbedUnimplementedResolverServer() {}
+
+// UnsafeResolverServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ResolverServer will
+// result in compilation errors.
+type UnsafeResolverServer interface {
+	mustEmbedUnimplementedResolverServer()
+}
+
+func RegisterResolverServer(s grpc.ServiceRegistrar, srv ResolverServer) {
+	s.RegisterService(&Resolver_ServiceDesc, srv)
+}
+
+func _Resolver_Resolve_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResolveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ResolverServer).Resolve(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Resolver_Resolve_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ResolverServer).Resolve(ctx, req.(*ResolveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Resolver_ResolveBatch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ResolverServer).ResolveBatch(&resolverResolveBatchServer{stream})
+}
+
+type Resolver_ResolveBatchServer interface {
+	Send(*ResolveResponse) error
+	Recv() (*ResolveRequest, error)
+	grpc.ServerStream
+}
+
+type resolverResolveBatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *resolverResolveBatchServer) Send(m *ResolveResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *resolverResolveBatchServer) Recv() (*ResolveRequest, error) {
+	m := new(ResolveRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Resolver_Extract_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExtractRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ResolverServer).Extract(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Resolver_Extract_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ResolverServer).Extract(ctx, req.(*ExtractRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Resolver_ServiceDesc is the grpc.ServiceDesc for Resolver service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Resolver_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "perseus.citation.v1.Resolver",
+	HandlerType: (*ResolverServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Resolve",
+			Handler:    _Resolver_Resolve_Handler,
+		},
+		{
+			MethodName: "Extract",
+			Handler:    _Resolver_Extract_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ResolveBatch",
+			Handler:       _Resolver_ResolveBatch_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "citationpb/citation.proto",
+}
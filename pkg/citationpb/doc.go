@@ -0,0 +1,6 @@
+// Package citationpb holds the protocol buffer messages and gRPC service of
+// the citation resolver, generated from citation.proto. The server is the
+// serve subcommand of citation-processor.
+package citationpb
+
+//go:generate protoc -I .. --go_out=.. --go_opt=paths=source_relative --go-grpc_out=.. --go-grpc_opt=paths=source_relative citationpb/citation.proto
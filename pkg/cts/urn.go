@@ -0,0 +1,159 @@
+// Package cts parses, validates and formats CTS URNs of the form
+//
+//	urn:cts:{namespace}:{text group}.{work}.{version}:{passage}
+//
+// such as urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:151, so that URNs are
+// built from their parts rather than by joining strings.
+package cts
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Prefix begins every CTS URN
+const Prefix = "urn:cts:"
+
+// ErrInvalid is wrapped by the errors of Parse and Validate
+var ErrInvalid = errors.New("invalid CTS URN")
+
+// URN is a CTS URN. Only the namespace and text group are required; a version
+// needs a work, and so does a passage.
+type URN struct {
+	Namespace string  // e.g. "greekLit"
+	TextGroup string  // e.g. "tlg0011"
+	Work      string  // e.g. "tlg004"
+	Version   string  // edition or translation, e.g. "perseus-grc2", possibly followed by an exemplar
+	Passage   Passage // e.g. 151, or 64-66
+}
+
+// Passage is the passage component of a URN, a single reference or a range
+type Passage struct {
+	Start string // e.g. "1.1" or "338d"
+	End   string // end of a range, "" for a single reference
+}
+
+var (
+	namespaceRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+	// text groups and works, e.g. "tlg0011", "viaf2603144" or "shak"
+	componentRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	versionRegex   = regexp.MustCompile(`^[A-Za-z0-9_-]+(?:\.[A-Za-z0-9_-]+)?$`)
+	// a reference within a work: anything but whitespace, ":" and "-"
+	referenceRegex = regexp.MustCompile(`^[^\s:-]+$`)
+)
+
+// ParsePassage splits a passage such as "64-66" into the ends of its range.
+// A range left open, as in "457-" for 457 and following, cites its start.
+func ParsePassage(passage string) Passage {
+	start, end, _ := strings.Cut(passage, "-")
+	return Passage{Start: start, End: end}
+}
+
+// IsZero reports whether there is no passage
+func (p Passage) IsZero() bool {
+	return p.Start == "" && p.End == ""
+}
+
+// IsRange reports whether the passage is a range
+func (p Passage) IsRange() bool {
+	return p.End != ""
+}
+
+func (p Passage) String() string {
+	if p.End == "" {
+		return p.Start
+	}
+	return p.Start + "-" + p.End
+}
+
+// Parse reads a URN, which must be valid
+func Parse(s string) (URN, error) {
+	rest, found := strings.CutPrefix(s, Prefix)
+	if !found {
+		return URN{}, fmt.Errorf("%w %q: no %s prefix", ErrInvalid, s, Prefix)
+	}
+	namespace, rest, found := strings.Cut(rest, ":")
+	if !found {
+		return URN{}, fmt.Errorf("%w %q: no work component", ErrInvalid, s)
+	}
+	work, passage, hasPassage := strings.Cut(rest, ":")
+	if hasPassage && passage == "" {
+		return URN{}, fmt.Errorf("%w %q: empty passage", ErrInvalid, s)
+	}
+
+	urn := URN{Namespace: namespace, Passage: ParsePassage(passage)}
+	parts := strings.SplitN(work, ".", 3)
+	urn.TextGroup = parts[0]
+	if len(parts) > 1 {
+		urn.Work = parts[1]
+	}
+	if len(parts) > 2 {
+		urn.Version = parts[2]
+	}
+	if err := urn.Validate(); err != nil {
+		return URN{}, err
+	}
+	return urn, nil
+}
+
+// String formats the URN. It does not check it; see Validate.
+func (u URN) String() string {
+	var s strings.Builder
+	s.WriteString(Prefix)
+	s.WriteString(u.Namespace)
+	s.WriteString(":")
+	s.WriteString(u.TextGroup)
+	if u.Work != "" {
+		s.WriteString(".")
+		s.WriteString(u.Work)
+		if u.Version != "" {
+			s.WriteString(".")
+			s.WriteString(u.Version)
+		}
+	}
+	if !u.Passage.IsZero() {
+		s.WriteString(":")
+		s.WriteString(u.Passage.String())
+	}
+	return s.String()
+}
+
+// Validate checks that every component is well formed and that the
+// components a version or passage depends on are present
+func (u URN) Validate() error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w %q: %s", ErrInvalid, u.String(), fmt.Sprintf(format, args...))
+	}
+	if !namespaceRegex.MatchString(u.Namespace) {
+		return invalid("bad namespace %q", u.Namespace)
+	}
+	if !componentRegex.MatchString(u.TextGroup) {
+		return invalid("bad text group %q", u.TextGroup)
+	}
+	if u.Work != "" && !componentRegex.MatchString(u.Work) {
+		return invalid("bad work %q", u.Work)
+	}
+	if u.Version != "" {
+		if u.Work == "" {
+			return invalid("version without a work")
+		}
+		if !versionRegex.MatchString(u.Version) {
+			return invalid("bad version %q", u.Version)
+		}
+	}
+	if u.Passage.IsZero() {
+		return nil
+	}
+	if u.Work == "" {
+		return invalid("passage without a work")
+	}
+	if !referenceRegex.MatchString(u.Passage.Start) {
+		return invalid("bad passage %q", u.Passage.String())
+	}
+	if u.Passage.IsRange() && !referenceRegex.MatchString(u.Passage.End) {
+		return invalid("bad passage %q", u.Passage.String())
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+// Package httpclient is the HTTP client of the features that look things up
+// over the network, such as catalog harvesting, so that they all limit their
+// request rate, retry failures, cache responses on disk and run offline in
+// the same way.
+package httpclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Options configures a Client. The zero value makes requests with the
+// default timeout, no rate limit, no retries and no cache.
+type Options struct {
+	Timeout   time.Duration // for each attempt at a request (0: DefaultTimeout)
+	Rate      float64       // requests per second at most (0: unlimited)
+	Retries   int           // further attempts at a request that failed with a network error, 429 or 5xx
+	Backoff   time.Duration // wait before the first retry, doubled before each further one (0: DefaultBackoff)
+	CacheDir  string        // directory of cached responses ("": no cache)
+	CacheTTL  time.Duration // age after which a cached response is fetched again (0: never)
+	Offline   bool          // answer from the cache only, failing with ErrOffline otherwise
+	UserAgent string        // sent with every request ("": DefaultUserAgent)
+}
+
+const (
+	DefaultTimeout   = 30 * time.Second
+	DefaultBackoff   = time.Second
+	DefaultUserAgent = "perseus-citation-processor"
+)
+
+// ErrOffline is returned in offline mode for a request with no cached
+// response
+var ErrOffline = errors.New("no cached response in offline mode")
+
+// StatusError is returned for a response whose status is not 200 OK
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %s from %s", e.Status, e.URL)
+}
+
+// Client makes GET requests by its Options. It is safe for concurrent use,
+// its rate limit applying to all requests together.
+type Client struct {
+	opts Options
+	http *http.Client
+
+	mu   sync.Mutex
+	next time.Time // earliest start of the next request under the rate limit
+}
+
+// New returns a client with the given options
+func New(opts Options) *Client {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = DefaultBackoff
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = DefaultUserAgent
+	}
+	return &Client{opts: opts, http: &http.Client{Timeout: opts.Timeout}}
+}
+
+// Get returns the body of a 200 response to a GET request for url, from the
+// cache if it holds a fresh copy. Other statuses give a *StatusError.
+func (c *Client) Get(ctx context.Context, url string) ([]byte, error) {
+	path := c.cachePath(url)
+	if path != "" {
+		if body, fresh, err := c.readCache(path); err == nil && (fresh || c.opts.Offline) {
+			return body, nil
+		}
+	}
+	if c.opts.Offline {
+		return nil, fmt.Errorf("%s: %w", url, ErrOffline)
+	}
+
+	body, err := c.fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		if err := writeCache(path, body); err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+// fetch requests url, retrying with backoff failures that may pass
+func (c *Client) fetch(ctx context.Context, url string) ([]byte, error) {
+	backoff := c.opts.Backoff
+	for attempt := 0; ; attempt++ {
+		if err := c.wait(ctx); err != nil {
+			return nil, err
+		}
+		body, retryAfter, err := c.attempt(ctx, url)
+		if err == nil || retryAfter < 0 || attempt >= c.opts.Retries {
+			return body, err
+		}
+		delay := backoff
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+		backoff *= 2
+	}
+}
+
+// attempt makes a single request. retryAfter is negative if the failure
+// will not pass on a retry, and otherwise the wait the server asked for, if
+// any.
+func (c *Client) attempt(ctx context.Context, url string) (body []byte, retryAfter time.Duration, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, -1, err
+	}
+	req.Header.Set("User-Agent", c.opts.UserAgent)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, -1, err
+		}
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		err := &StatusError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			return nil, -1, err
+		}
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return nil, time.Duration(seconds) * time.Second, err
+	}
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read response from %s: %w", url, err)
+	}
+	return body, 0, nil
+}
+
+// wait blocks until the rate limit allows another request
+func (c *Client) wait(ctx context.Context) error {
+	if c.opts.Rate <= 0 {
+		return nil
+	}
+	c.mu.Lock()
+	now := time.Now()
+	start := c.next
+	if start.Before(now) {
+		start = now
+	}
+	c.next = start.Add(time.Duration(float64(time.Second) / c.opts.Rate))
+	c.mu.Unlock()
+	if delay := start.Sub(now); delay > 0 {
+		return sleepContext(ctx, delay)
+	}
+	return nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// cachePath returns the file caching the response to url, "" without a
+// cache
+func (c *Client) cachePath(url string) string {
+	if c.opts.CacheDir == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.opts.CacheDir, hex.EncodeToString(sum[:]))
+}
+
+// readCache reads a cached response, fresh if younger than the cache TTL
+func (c *Client) readCache(path string) (body []byte, fresh bool, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false, err
+	}
+	body, err = os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	fresh = c.opts.CacheTTL <= 0 || time.Since(info.ModTime()) < c.opts.CacheTTL
+	return body, fresh, nil
+}
+
+// writeCache caches a response, writing it under another name first so that
+// concurrent readers never see part of it
+func writeCache(path string, body []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create response cache: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".response-*")
+	if err != nil {
+		return fmt.Errorf("failed to cache response: %w", err)
+	}
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to cache response: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to cache response: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to cache response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package loader
+
+// DefaultAbbreviationMaxLen is the longest prefix of a title's first word
+// generated as an abbreviation by default, as "oedipu." for "oedipus
+// tyrannus"
+const DefaultAbbreviationMaxLen = 6
+
+// AbbreviationOptions controls GenerateAbbreviations. The zero value is valid
+// and differs from DefaultAbbreviationOptions only in leaving suspensions
+// out.
+type AbbreviationOptions struct {
+	// MaxLen is the longest prefix of the first word generated, with and
+	// without a full stop: 3 gives "oe", "oe.", "oed" and "oed." besides
+	// the initial. 0 means DefaultAbbreviationMaxLen; 1 generates no
+	// prefixes beyond the initial.
+	MaxLen int
+
+	// IncludeSuspensions adds the title cut after its first syllable and
+	// the consonants closing it, its words joined by underscores, as
+	// "on_t." for "on the crown"
+	IncludeSuspensions bool
+
+	// Language names the stopwords left out of the title's initials, a key
+	// of DefaultStopwords such as "english" or "latin". "" takes the
+	// language most of the title's words are stopwords of, as the work
+	// tables do; a language DefaultStopwords does not list has none.
+	Language string
+}
+
+// DefaultAbbreviationOptions are the options the work tables are expanded
+// with and GenerateWorkAbbreviations uses
+var DefaultAbbreviationOptions = AbbreviationOptions{
+	MaxLen:             DefaultAbbreviationMaxLen,
+	IncludeSuspensions: true,
+}
+
+// GenerateAbbreviations returns the abbreviations a title may be cited by,
+// lower-cased, without repeats, ending with the title itself. Numeric titles
+// have none. They are generated in this order, which is kept from release
+// to release so that callers can rely on it; new forms are only added
+// behind new options:
+//
+//   - for a one-word title, the title with a final "s" made "a" or a final
+//     "a" made "s" ("electrs")
+//   - the initial, with and without a full stop ("e", "e.")
+//   - prefixes of the first word up to MaxLen letters, each with and
+//     without a full stop ("el", "el.", "ele", "ele.")
+//   - for titles of several words, their initials as "ot", "o.t.", "o_t"
+//     and "o._t.", then the same without stopwords if the title has any,
+//     then the first word alone
+//   - the first two and the first three words, with spaces and with
+//     underscores, for titles longer than that
+//   - with IncludeSuspensions, the suspension
+//   - the title with underscores for spaces, then the title
+func GenerateAbbreviations(title string, options AbbreviationOptions) []string {
+	return generateWorkAbbreviations(title, defaultStopwordSets, options)
+}
+
+// stopwordsFor returns the stopwords to leave out of a title's initials
+func (o AbbreviationOptions) stopwordsFor(words []string, stopwords stopwordSets) map[string]bool {
+	if o.Language != "" {
+		return stopwords[o.Language]
+	}
+	return stopwords.forTitle(words)
+}
+
+// maxLen returns MaxLen, or its default for 0
+func (o AbbreviationOptions) maxLen() int {
+	if o.MaxLen <= 0 {
+		return DefaultAbbreviationMaxLen
+	}
+	return o.MaxLen
+}
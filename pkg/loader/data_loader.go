@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -50,16 +51,85 @@ type OtherData struct {
 	AuthURNs map[string]string             `json:"OTHER_AUTH_URNS"`
 }
 
-// ComprehensiveData holds all citation data
+// ComprehensiveData holds all citation data. Data loaded by
+// LoadComprehensiveDataDir is read-only from then on and may be shared
+// between goroutines: the packs' tables are combined once, so they must not
+// be modified afterwards, except by MergeWorkAliases before the data is
+// shared.
 type ComprehensiveData struct {
 	Greek GreekData
 	Latin LatinData
 	Schol ScholData
 	Other OtherData
+
+	// workTitles maps author -> work URN -> title as given in the data files,
+	// recorded before expandWorkTitles mixes in generated abbreviations
+	workTitles map[string]map[string]string
+
+	// citationSchemes maps author -> work URN -> citation scheme
+	citationSchemes map[string]map[string]WorkScheme
+
+	// perseusTextIDs maps legacy Perseus text IDs (e.g. "1999.01.0133") to
+	// CTS work URNs
+	perseusTextIDs map[string]string
+
+	// exemplars maps text group and work URNs to their preferred exemplar
+	exemplars map[string]string
+
+	// fragmentCollections maps a collection key (e.g. "fgrh") to the
+	// collection, and fragmentAbbrevs each way of citing one to its key
+	fragmentCollections map[string]FragmentCollection
+	fragmentAbbrevs     map[string]string
+
+	// documentaryCorpora maps a corpus key (e.g. "ig") to the corpus of
+	// inscriptions or papyri, and documentaryAbbrevs each way of citing one
+	// to its key
+	documentaryCorpora map[string]DocumentaryCorpus
+	documentaryAbbrevs map[string]string
+
+	// workKeys maps author -> title or generated abbreviation -> work URN ->
+	// how specific the key is for that work, for ranking ambiguous abbreviations
+	workKeys map[string]map[string]map[string]float64
+
+	// stopwords are left out of the initials of titles, by language
+	stopwords stopwordSets
+
+	// editions holds the lower-cased editors and edition series of
+	// EditionsFile
+	editions map[string]bool
+
+	// incipits maps an author to the poems of IncipitsFile
+	incipits map[string][]IncipitWork
+
+	// paginations maps author -> work URN or "*" -> page-based citation
+	// system, from PaginationFile
+	paginations map[string]map[string]Pagination
+
+	// homonyms maps a name several authors share to the group of them, from
+	// HomonymsFile, and homonymNames each of its names to the name
+	homonyms     map[string]HomonymGroup
+	homonymNames map[string]string
+
+	// workAliases records the titles merged in from work_aliases.json, and
+	// generatedFrom the title each generated abbreviation was taken from,
+	// both by author, for WorkEntry
+	workAliases   map[string]map[string]bool
+	generatedFrom map[string]map[string]string
+
+	// packs holds the checksum and schema version of each pack file, by
+	// file name, for Summary
+	packs map[string]packFile
+
+	// layers are the abbreviation layers loaded, such as
+	// ScholarlyAbbreviationsFile, in the order they are laid over the packs
+	layers []abbreviationLayer
+
+	// index combines the packs' tables, once they are loaded
+	index *dataIndex
 }
 
-// findDataDir attempts to find the data directory relative to the current working directory
-func findDataDir() string {
+// FindDataDir attempts to find the data directory relative to the current working directory
+func FindDataDir() string {
 	// Try current directory first
 	if _, err := os.Stat("data"); err == nil {
 		return "data"
@@ -81,47 +151,324 @@ func findDataDir() string {
 func LoadComprehensiveDataDir(dataDir string) (*ComprehensiveData, error) {
 	data := &ComprehensiveData{}
 	// load Greek data
-	greekBytes, err := os.ReadFile(filepath.Join(dataDir, "greek_data.json"))
+	greekBytes, err := readDataFile(dataDir, "greek_data.json")
 	if err != nil {
-		return nil, fmt.Errorf("failed to read %s/greek_data.json: %w", dataDir, err)
+		return nil, err
 	}
+	data.recordPack("greek_data.json", greekBytes)
 	if err := json.Unmarshal(greekBytes, &data.Greek); err != nil {
 		return nil, fmt.Errorf("failed to parse greek_data.json: %w", err)
 	}
 
 	// load Latin data
-	latinBytes, err := os.ReadFile(filepath.Join(dataDir, "latin_data.json"))
+	latinBytes, err := readDataFile(dataDir, "latin_data.json")
 	if err != nil {
-		return nil, fmt.Errorf("failed to read %s/latin_data.json: %w", dataDir, err)
+		return nil, err
 	}
+	data.recordPack("latin_data.json", latinBytes)
 	if err := json.Unmarshal(latinBytes, &data.Latin); err != nil {
 		return nil, fmt.Errorf("failed to parse latin_data.json: %w", err)
 	}
 
 	// load Schol data
-	scholBytes, err := os.ReadFile(filepath.Join(dataDir, "schol_data.json"))
+	scholBytes, err := readDataFile(dataDir, "schol_data.json")
 	if err != nil {
-		return nil, fmt.Errorf("failed to read %s/schol_data.json: %w", dataDir, err)
+		return nil, err
 	}
+	data.recordPack("schol_data.json", scholBytes)
 	if err := json.Unmarshal(scholBytes, &data.Schol); err != nil {
 		return nil, fmt.Errorf("failed to parse schol_data.json: %w", err)
 	}
 
 	// Load other data
-	otherBytes, err := os.ReadFile(filepath.Join(dataDir, "other_data.json"))
+	otherBytes, err := readDataFile(dataDir, "other_data.json")
 	if err != nil {
-		return nil, fmt.Errorf("failed to read %s/other_data.json: %w", dataDir, err)
+		return nil, err
 	}
+	data.recordPack("other_data.json", otherBytes)
 	if err := json.Unmarshal(otherBytes, &data.Other); err != nil {
 		return nil, fmt.Errorf("failed to parse other_data.json: %w", err)
 	}
 
+	// Load optional work aliases, e.g. harvested from the Perseus catalog
+	aliasBytes, err := os.ReadFile(filepath.Join(dataDir, WorkAliasesFile))
+	if err == nil {
+		var aliases map[string]map[string]string
+		if err := json.Unmarshal(aliasBytes, &aliases); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", WorkAliasesFile, err)
+		}
+		data.MergeWorkAliases(aliases)
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s/%s: %w", dataDir, WorkAliasesFile, err)
+	}
+
+	// Load optional citation schemes, e.g. "book.line" for the Iliad
+	schemeBytes, err := os.ReadFile(filepath.Join(dataDir, CitationSchemesFile))
+	if err == nil {
+		if err := json.Unmarshal(schemeBytes, &data.citationSchemes); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", CitationSchemesFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s/%s: %w", dataDir, CitationSchemesFile, err)
+	}
+
+	// Load optional legacy Perseus text IDs, e.g. "1999.01.0133" for the Iliad
+	textIDBytes, err := os.ReadFile(filepath.Join(dataDir, PerseusTextIDsFile))
+	if err == nil {
+		if err := json.Unmarshal(textIDBytes, &data.perseusTextIDs); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", PerseusTextIDsFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s/%s: %w", dataDir, PerseusTextIDsFile, err)
+	}
+
+	// Load optional preferred exemplars, e.g. "perseus-grc1" for Plato
+	exemplarBytes, err := os.ReadFile(filepath.Join(dataDir, ExemplarsFile))
+	if err == nil {
+		if err := json.Unmarshal(exemplarBytes, &data.exemplars); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", ExemplarsFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s/%s: %w", dataDir, ExemplarsFile, err)
+	}
+
+	// Load optional fragment collections, e.g. Jacoby's FGrH
+	fragmentBytes, err := os.ReadFile(filepath.Join(dataDir, FragmentCollectionsFile))
+	if err == nil {
+		if err := json.Unmarshal(fragmentBytes, &data.fragmentCollections); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", FragmentCollectionsFile, err)
+		}
+		data.indexFragmentCollections()
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s/%s: %w", dataDir, FragmentCollectionsFile, err)
+	}
+
+	// Load optional corpora of inscriptions and papyri, e.g. IG and P.Oxy.
+	documentaryBytes, err := os.ReadFile(filepath.Join(dataDir, DocumentaryCorporaFile))
+	if err == nil {
+		if err := json.Unmarshal(documentaryBytes, &data.documentaryCorpora); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", DocumentaryCorporaFile, err)
+		}
+		data.indexDocumentaryCorpora()
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s/%s: %w", dataDir, DocumentaryCorporaFile, err)
+	}
+
+	// Load optional stopwords, by the language of the titles they apply to
+	var stopwords map[string][]string
+	stopwordBytes, err := os.ReadFile(filepath.Join(dataDir, StopwordsFile))
+	if err == nil {
+		if err := json.Unmarshal(stopwordBytes, &stopwords); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", StopwordsFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s/%s: %w", dataDir, StopwordsFile, err)
+	}
+	data.stopwords = newStopwordSets(stopwords)
+
+	// Load optional editors and edition series, e.g. "Burnet" and "OCT"
+	var editions Editions
+	editionBytes, err := os.ReadFile(filepath.Join(dataDir, EditionsFile))
+	if err == nil {
+		if err := json.Unmarshal(editionBytes, &editions); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", EditionsFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s/%s: %w", dataDir, EditionsFile, err)
+	}
+	data.editions = editions.index()
+
+	// Load optional incipits, e.g. of the Homeric Hymns
+	incipitBytes, err := os.ReadFile(filepath.Join(dataDir, IncipitsFile))
+	if err == nil {
+		if err := json.Unmarshal(incipitBytes, &data.incipits); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", IncipitsFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s/%s: %w", dataDir, IncipitsFile, err)
+	}
+
+	// Load optional paginations, e.g. Stephanus's for Plato
+	paginationBytes, err := os.ReadFile(filepath.Join(dataDir, PaginationFile))
+	if err == nil {
+		if err := json.Unmarshal(paginationBytes, &data.paginations); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", PaginationFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s/%s: %w", dataDir, PaginationFile, err)
+	}
+
+	// Load optional homonyms, e.g. the Apollonii of Rhodes and of Perga
+	homonymBytes, err := os.ReadFile(filepath.Join(dataDir, HomonymsFile))
+	if err == nil {
+		if err := json.Unmarshal(homonymBytes, &data.homonyms); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", HomonymsFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s/%s: %w", dataDir, HomonymsFile, err)
+	}
+	data.indexHomonyms()
+
+	// Load the optional layer of the OCD's and LSJ's author sigla, e.g. "A."
+	// for Aeschylus
+	if err := data.loadAbbreviationLayer(dataDir, ScholarlyAbbreviationsFile); err != nil {
+		return nil, err
+	}
+
 	data.expandWorkTitles()
+	data.buildIndex()
 	return data, nil
 }
 
+// readDataFile reads a required data file, reporting a missing file as
+// *ErrDataNotFound
+func readDataFile(dataDir, name string) ([]byte, error) {
+	path := filepath.Join(dataDir, name)
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, &ErrDataNotFound{Path: path, Err: err}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return content, nil
+}
+
+// WorkAliasesFile is the optional data file of alternative work titles,
+// mapping author -> alias -> work URN component (e.g. "tlg004")
+const WorkAliasesFile = "work_aliases.json"
+
+// CitationSchemesFile is the optional data file of citation schemes, mapping
+// author -> work URN component -> dot-separated level labels (e.g.
+// "book.line"), or a WorkScheme object giving the last number of the top
+// level as well
+const CitationSchemesFile = "citation_schemes.json"
+
+// CitationScheme returns the citation scheme of an author's work given its
+// work URN component (e.g. "tlg001"), or "" if none is recorded
+func (cd *ComprehensiveData) CitationScheme(author, workURN string) string {
+	return cd.citationSchemes[author][workURN].Scheme
+}
+
+// PerseusTextIDsFile is the optional data file mapping legacy Perseus text
+// IDs (e.g. "1999.01.0133") to CTS work URNs without an exemplar
+const PerseusTextIDsFile = "perseus_text_ids.json"
+
+// PerseusTextWork returns the CTS work URN for a legacy Perseus text ID, or ""
+// if it is not in the table
+func (cd *ComprehensiveData) PerseusTextWork(textID string) string {
+	return cd.perseusTextIDs[textID]
+}
+
+// ExemplarsFile is the optional data file of the exemplars to cite where
+// Perseus has no perseus-grc2 or perseus-lat2 edition, mapping a work URN
+// (e.g. "urn:cts:latinLit:phi0474.phi013") or a text group URN, for all of
+// the author's works, to the exemplar (e.g. "perseus-lat1")
+const ExemplarsFile = "exemplars.json"
+
+// Exemplar returns the preferred exemplar of a work given its work URN, else
+// that of its text group, or "" if neither is recorded. A text group URN
+// gives the text group's exemplar.
+func (cd *ComprehensiveData) Exemplar(urn string) string {
+	if exemplar, exists := cd.exemplars[urn]; exists {
+		return exemplar
+	}
+	// urn:cts:greekLit:tlg0059.tlg030 -> urn:cts:greekLit:tlg0059
+	if colon := strings.LastIndex(urn, ":"); colon >= 0 {
+		if dot := strings.Index(urn[colon:], "."); dot >= 0 {
+			return cd.exemplars[urn[:colon+dot]]
+		}
+	}
+	return ""
+}
+
+// FragmentCollectionsFile is the optional data file of standard fragment
+// collections, mapping a collection key (e.g. "fgrh") to a FragmentCollection
+const FragmentCollectionsFile = "fragment_collections.json"
+
+// FragmentCollection is a standard edition of fragments, such as Jacoby's
+// Die Fragmente der griechischen Historiker
+type FragmentCollection struct {
+	Title         string   `json:"title"`
+	Editor        string   `json:"editor"`
+	Abbreviations []string `json:"abbreviations"` // how citations name the collection or its editor
+	// Numbered collections number their authors, and are cited by author
+	// number, as in "FGrH 115 F 30"; the rest are cited after the author, as
+	// in "Archil. fr. 5 West"
+	Numbered bool              `json:"numbered,omitempty"`
+	Authors  map[string]string `json:"authors,omitempty"` // author number -> name, for numbered collections
+}
+
+// indexFragmentCollections maps the abbreviations of each collection to its
+// key, ignoring case
+func (cd *ComprehensiveData) indexFragmentCollections() {
+	cd.fragmentAbbrevs = make(map[string]string)
+	for key, collection := range cd.fragmentCollections {
+		cd.fragmentAbbrevs[strings.ToLower(key)] = key
+		for _, abbreviation := range collection.Abbreviations {
+			cd.fragmentAbbrevs[strings.ToLower(abbreviation)] = key
+		}
+	}
+}
+
+// FragmentCollection returns the key of the fragment collection an
+// abbreviation or editor's name cites, such as "fgrh" for "FGrHist" or "ieg"
+// for "W.", and the collection
+func (cd *ComprehensiveData) FragmentCollection(abbreviation string) (string, FragmentCollection, bool) {
+	key, exists := cd.fragmentAbbrevs[strings.ToLower(strings.TrimSpace(abbreviation))]
+	if !exists {
+		return "", FragmentCollection{}, false
+	}
+	return key, cd.fragmentCollections[key], true
+}
+
+// workURNsFor returns the work map of whichever namespace lists the author
+func (cd *ComprehensiveData) workURNsFor(author string) map[string]WorkURN {
+	for _, workURNs := range []map[string]map[string]WorkURN{
+		cd.Greek.WorkURNs, cd.Latin.WorkURNs, cd.Schol.WorkURNs, cd.Other.WorkURNs,
+	} {
+		if works, exists := workURNs[author]; exists {
+			return works
+		}
+	}
+	return nil
+}
+
+// MergeWorkAliases adds alternative titles for works that are already in the
+// data files, never overriding an existing title. Aliases for unknown authors
+// are skipped. It returns the number of aliases added.
+func (cd *ComprehensiveData) MergeWorkAliases(aliases map[string]map[string]string) int {
+	added := 0
+	for author, titles := range aliases {
+		works := cd.workURNsFor(author)
+		if works == nil {
+			continue
+		}
+		for alias, workURN := range titles {
+			alias = strings.ToLower(strings.TrimSpace(alias))
+			if _, exists := works[alias]; exists || alias == "" {
+				continue
+			}
+			works[alias] = WorkURN{Simple: workURN}
+			if cd.index != nil {
+				delete(cd.index.derived[author], alias)
+				cd.addDerived(cd.index.derived[author], works, alias, works[alias])
+			}
+			if cd.workAliases == nil {
+				cd.workAliases = make(map[string]map[string]bool)
+			}
+			if cd.workAliases[author] == nil {
+				cd.workAliases[author] = make(map[string]bool)
+			}
+			cd.workAliases[author][alias] = true
+			added++
+		}
+	}
+	return added
+}
+
 func LoadComprehensiveData() (*ComprehensiveData, error) {
-	dataDir := findDataDir()
+	dataDir := FindDataDir()
 	return LoadComprehensiveDataDir(dataDir)
 }
 
@@ -172,69 +519,262 @@ func (w *WorkURN) UnmarshalJSON(data []byte) error {
 
 // expandWorkTitles generates additional abbreviations for work titles
 func (cd *ComprehensiveData) expandWorkTitles() {
-	// Expand Greek works
-	for author, works := range cd.Greek.WorkURNs {
-		expanded := make(map[string]WorkURN)
-		for work, urn := range works {
-			expanded[work] = urn
-			// Add abbreviations
-			for _, abbrev := range GenerateWorkAbbreviations(work) {
-				if _, exists := expanded[abbrev]; !exists {
-					expanded[abbrev] = urn
-				}
-			}
+	cd.recordWorkTitles()
+	cd.generatedFrom = make(map[string]map[string]string)
+
+	for _, authorWorks := range []map[string]map[string]WorkURN{
+		cd.Greek.WorkURNs, cd.Latin.WorkURNs, cd.Schol.WorkURNs, cd.Other.WorkURNs,
+	} {
+		for author, works := range authorWorks {
+			authorWorks[author] = cd.expandWorks(author, works)
 		}
-		cd.Greek.WorkURNs[author] = expanded
 	}
+}
 
-	// Expand Latin works
-	for author, works := range cd.Latin.WorkURNs {
-		expanded := make(map[string]WorkURN)
-		for work, urn := range works {
-			expanded[work] = urn
-			// Add abbreviations
-			for _, abbrev := range GenerateWorkAbbreviations(work) {
-				if _, exists := expanded[abbrev]; !exists {
-					expanded[abbrev] = urn
-				}
+// expandWorks adds the generated abbreviations of an author's titles. An
+// abbreviation that several titles generate goes to the work WorkCandidates
+// ranks first, or between equally ranked works the one whose title sorts
+// first, so the choice does not depend on map iteration order.
+func (cd *ComprehensiveData) expandWorks(author string, works map[string]WorkURN) map[string]WorkURN {
+	expanded := make(map[string]WorkURN, len(works))
+	for work, urn := range works {
+		expanded[work] = urn
+	}
+	generatedFrom := make(map[string]string)
+	for work, urn := range works {
+		for _, abbrev := range cd.WorkAbbreviations(work) {
+			if _, isTitle := works[abbrev]; isTitle {
+				continue
+			}
+			current, exists := expanded[abbrev]
+			if !exists || cd.ranksBefore(author, abbrev, urn, current) ||
+				(!cd.ranksBefore(author, abbrev, current, urn) && work < generatedFrom[abbrev]) {
+				expanded[abbrev] = urn
+				generatedFrom[abbrev] = work
 			}
 		}
-		cd.Latin.WorkURNs[author] = expanded
 	}
+	cd.generatedFrom[author] = generatedFrom
+	return expanded
+}
 
-	// Expand Schol works
-	for author, works := range cd.Schol.WorkURNs {
-		expanded := make(map[string]WorkURN)
-		for work, urn := range works {
-			expanded[work] = urn
-			// Add abbreviations
-			for _, abbrev := range GenerateWorkAbbreviations(work) {
-				if _, exists := expanded[abbrev]; !exists {
-					expanded[abbrev] = urn
-				}
+// ranksBefore reports whether key refers to a before b in the order of
+// WorkCandidates: by weight, then work URN
+func (cd *ComprehensiveData) ranksBefore(author, key string, a, b WorkURN) bool {
+	weights := cd.workKeys[author][key]
+	if weights[a.Simple] != weights[b.Simple] {
+		return weights[a.Simple] > weights[b.Simple]
+	}
+	if a.Simple != b.Simple {
+		return a.Simple < b.Simple
+	}
+	// works given as ranges have no URN, and go by where the range starts
+	if a.Range == nil || b.Range == nil {
+		return a.Range == nil && b.Range != nil
+	}
+	if a.Range.Prefix != b.Range.Prefix {
+		return a.Range.Prefix < b.Range.Prefix
+	}
+	return a.Range.Start < b.Range.Start
+}
+
+// recordWorkTitles keeps the longest title for each work URN so that titles
+// can still be looked up after abbreviations have been merged in
+func (cd *ComprehensiveData) recordWorkTitles() {
+	cd.workTitles = make(map[string]map[string]string)
+	cd.workKeys = make(map[string]map[string]map[string]float64)
+	for author, works := range cd.GetAllWorkURNs() {
+		titles := make(map[string]string)
+		keys := make(map[string]map[string]float64)
+		for title, urn := range works {
+			if urn.Simple == "" {
+				continue
+			}
+			current, exists := titles[urn.Simple]
+			if !exists || len(title) > len(current) || (len(title) == len(current) && title < current) {
+				titles[urn.Simple] = title
+			}
+			addWorkKey(keys, title, urn.Simple, 1)
+			for _, abbrev := range cd.WorkAbbreviations(title) {
+				addWorkKey(keys, abbrev, urn.Simple, float64(len(abbrev))/float64(len(title)))
 			}
 		}
-		cd.Schol.WorkURNs[author] = expanded
+		cd.workTitles[author] = titles
+		cd.workKeys[author] = keys
 	}
+}
 
-	// Expand Other works
-	for author, works := range cd.Other.WorkURNs {
-		expanded := make(map[string]WorkURN)
-		for work, urn := range works {
-			expanded[work] = urn
-			// Add abbreviations
-			for _, abbrev := range GenerateWorkAbbreviations(work) {
-				if _, exists := expanded[abbrev]; !exists {
-					expanded[abbrev] = urn
-				}
+// addWorkKey records that key may refer to workURN, keeping the highest weight
+func addWorkKey(keys map[string]map[string]float64, key, workURN string, weight float64) {
+	if keys[key] == nil {
+		keys[key] = make(map[string]float64)
+	}
+	if weight > keys[key][workURN] {
+		keys[key][workURN] = weight
+	}
+}
+
+// WorkCandidate is a work that a title or abbreviation may refer to
+type WorkCandidate struct {
+	Author  string
+	WorkURN string  // work URN component, e.g. "tlg005"
+	Weight  float64 // 1 for a full title, else the abbreviation's share of the title
+}
+
+// WorkCandidates returns every work of the author whose title or generated
+// abbreviations match key, best first
+func (cd *ComprehensiveData) WorkCandidates(author, key string) []WorkCandidate {
+	var candidates []WorkCandidate
+	for workURN, weight := range cd.workKeys[author][strings.ToLower(key)] {
+		candidates = append(candidates, WorkCandidate{Author: author, WorkURN: workURN, Weight: weight})
+	}
+	sortWorkCandidates(candidates)
+	return candidates
+}
+
+// WorkCandidatesAnyAuthor is like WorkCandidates but searches the works of
+// all authors, for references that name a work without its author
+func (cd *ComprehensiveData) WorkCandidatesAnyAuthor(key string) []WorkCandidate {
+	var candidates []WorkCandidate
+	key = strings.ToLower(key)
+	for author, keys := range cd.workKeys {
+		for workURN, weight := range keys[key] {
+			candidates = append(candidates, WorkCandidate{Author: author, WorkURN: workURN, Weight: weight})
+		}
+	}
+	sortWorkCandidates(candidates)
+	return candidates
+}
+
+func sortWorkCandidates(candidates []WorkCandidate) {
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Weight != candidates[j].Weight {
+			return candidates[i].Weight > candidates[j].Weight
+		}
+		if candidates[i].Author != candidates[j].Author {
+			return candidates[i].Author < candidates[j].Author
+		}
+		return candidates[i].WorkURN < candidates[j].WorkURN
+	})
+}
+
+// WorkKeys returns, for every author, each title and generated abbreviation
+// of their works with the work URN components it may refer to, in order
+func (cd *ComprehensiveData) WorkKeys() map[string]map[string][]string {
+	all := make(map[string]map[string][]string, len(cd.workKeys))
+	for author, keys := range cd.workKeys {
+		authorKeys := make(map[string][]string, len(keys))
+		for key, works := range keys {
+			workURNs := make([]string, 0, len(works))
+			for workURN := range works {
+				workURNs = append(workURNs, workURN)
 			}
+			sort.Strings(workURNs)
+			authorKeys[key] = workURNs
+		}
+		all[author] = authorKeys
+	}
+	return all
+}
+
+// WorkTitle returns the title of an author's work given its work URN
+// component (e.g. "tlg004"), or "" if the work is not in the data files
+func (cd *ComprehensiveData) WorkTitle(author, workURN string) string {
+	return cd.workTitles[author][workURN]
+}
+
+// WorkTitles returns a copy of the work URN -> title map for an author
+func (cd *ComprehensiveData) WorkTitles(author string) map[string]string {
+	titles := make(map[string]string, len(cd.workTitles[author]))
+	for workURN, title := range cd.workTitles[author] {
+		titles[workURN] = title
+	}
+	return titles
+}
+
+// AuthorForURN returns the author key whose URN is authURN
+// (e.g. "sophocles" for "urn:cts:greekLit:tlg0011"), or "" if there is none
+func (cd *ComprehensiveData) AuthorForURN(authURN string) string {
+	var matches []string
+	for author, urn := range cd.GetAllAuthURNs() {
+		if urn == authURN {
+			matches = append(matches, author)
 		}
-		cd.Other.WorkURNs[author] = expanded
 	}
+	if len(matches) == 0 {
+		return ""
+	}
+	sort.Strings(matches)
+	return matches[0]
+}
+
+// dataIndex holds the tables of the four packs combined, built once the
+// data is loaded rather than on every lookup
+type dataIndex struct {
+	authors  map[string]bool
+	authAbb  map[string]interface{}
+	workURNs map[string]map[string]WorkURN
+	authURNs map[string]string
+	prefixes *nameNode // word trie over author names and abbreviations
+	names    []string  // author names and abbreviations, sorted
+	derived  map[string]map[string]derivedWork
 }
 
-// GetAllAuthors returns a set of all known authors
+// buildIndex combines the packs' tables, interning their strings, and
+// indexes the author names and abbreviations. It runs last in
+// LoadComprehensiveDataDir, after the work tables are expanded: from then on
+// the data is only read, and may be shared between goroutines.
+func (cd *ComprehensiveData) buildIndex() {
+	cd.internStrings()
+	index := &dataIndex{
+		authors:  cd.combineAuthors(),
+		authAbb:  cd.combineAuthAbb(),
+		workURNs: cd.combineWorkURNs(),
+		authURNs: cd.combineAuthURNs(),
+	}
+	index.prefixes = buildPrefixes(index.authors, index.authAbb)
+	index.names = sortedNames(index.authors, index.authAbb)
+	index.derived = cd.buildDerived(index.workURNs)
+	cd.index = index
+}
+
+// GetAllAuthors returns a set of all known authors. Like the other GetAll
+// methods, it returns a map shared by all callers, which must not be
+// modified.
 func (cd *ComprehensiveData) GetAllAuthors() map[string]bool {
+	if cd.index != nil {
+		return cd.index.authors
+	}
+	return cd.combineAuthors()
+}
+
+// GetAllAuthAbb returns a combined map of all author abbreviations
+func (cd *ComprehensiveData) GetAllAuthAbb() map[string]interface{} {
+	if cd.index != nil {
+		return cd.index.authAbb
+	}
+	return cd.combineAuthAbb()
+}
+
+// GetAllWorkURNs returns a combined map of all work URNs
+func (cd *ComprehensiveData) GetAllWorkURNs() map[string]map[string]WorkURN {
+	if cd.index != nil {
+		return cd.index.workURNs
+	}
+	return cd.combineWorkURNs()
+}
+
+// GetAllAuthURNs returns a combined map of all author URNs
+func (cd *ComprehensiveData) GetAllAuthURNs() map[string]string {
+	if cd.index != nil {
+		return cd.index.authURNs
+	}
+	return cd.combineAuthURNs()
+}
+
+// combineAuthors builds the set of GetAllAuthors, for data not loaded by
+// LoadComprehensiveDataDir
+func (cd *ComprehensiveData) combineAuthors() map[string]bool {
 	authors := make(map[string]bool)
 
 	for author := range cd.Greek.AuthURNs {
@@ -253,8 +793,7 @@ func (cd *ComprehensiveData) GetAllAuthors() map[string]bool {
 	return authors
 }
 
-// GetAllAuthAbb returns a combined map of all author abbreviations
-func (cd *ComprehensiveData) GetAllAuthAbb() map[string]interface{} {
+func (cd *ComprehensiveData) combineAuthAbb() map[string]interface{} {
 	combined := make(map[string]interface{})
 
 	// Add Greek abbreviations
@@ -277,11 +816,15 @@ func (cd *ComprehensiveData) GetAllAuthAbb() map[string]interface{} {
 		combined[abbrev] = author
 	}
 
+	// Add the layers' abbreviations the packs lack
+	if len(cd.layers) > 0 {
+		cd.layerAbbreviations(combined, cd.combineAuthURNs())
+	}
+
 	return combined
 }
 
-// GetAllWorkURNs returns a combined map of all work URNs
-func (cd *ComprehensiveData) GetAllWorkURNs() map[string]map[string]WorkURN {
+func (cd *ComprehensiveData) combineWorkURNs() map[string]map[string]WorkURN {
 	combined := make(map[string]map[string]WorkURN)
 
 	// Add Greek works
@@ -307,8 +850,7 @@ func (cd *ComprehensiveData) GetAllWorkURNs() map[string]map[string]WorkURN {
 	return combined
 }
 
-// GetAllAuthURNs returns a combined map of all author URNs
-func (cd *ComprehensiveData) GetAllAuthURNs() map[string]string {
+func (cd *ComprehensiveData) combineAuthURNs() map[string]string {
 	combined := make(map[string]string)
 
 	// Add Greek URNs
@@ -353,110 +895,33 @@ func (cd *ComprehensiveData) IsSingleWorkAuthor(author string) bool {
 	return false
 }
 
-// ResolveLatinAuthorFunction handles special Latin author disambiguation
+// ResolveLatinAuthorFunction returns the author a Latin author abbreviation
+// stands for when work is cited, running its disambiguator if it names one,
+// as "plin." does Pliny's. See AuthorFor, which takes the value of an
+// abbreviation of any pack.
 func (cd *ComprehensiveData) ResolveLatinAuthorFunction(abbrev, work string) string {
 	value, exists := cd.Latin.AuthAbb[abbrev]
 	if !exists {
 		return ""
 	}
-
-	// Handle function references for Pliny and Seneca
-	if valueStr, ok := value.(string); ok {
-		// If it's a function reference (like "_which_pliny"), don't return it directly
-		// but fall through to the switch statement below
-		if valueStr != "_which_pliny" && valueStr != "_which_seneca" {
-			return valueStr
-		}
-	}
-
-	// Handle function cases based on work title
-	switch abbrev {
-	case "plin.", "pliny":
-		// Check which Pliny based on work
-		work = strings.ToLower(work)
-
-		// Check pliny_senior works (exact match and abbreviations)
-		if cd.Latin.WorkURNs["pliny_senior"] != nil {
-			if _, exists := cd.Latin.WorkURNs["pliny_senior"][work]; exists {
-				return "pliny_senior"
-			}
-			// Check abbreviations
-			for title := range cd.Latin.WorkURNs["pliny_senior"] {
-				abbrevs := GenerateWorkAbbreviations(title)
-				for _, abbrev := range abbrevs {
-					if abbrev == work {
-						return "pliny_senior"
-					}
-				}
-			}
-		}
-
-		// Check pliny_junior works (exact match and abbreviations)
-		if cd.Latin.WorkURNs["pliny_junior"] != nil {
-			if _, exists := cd.Latin.WorkURNs["pliny_junior"][work]; exists {
-				return "pliny_junior"
-			}
-			// Check abbreviations
-			for title := range cd.Latin.WorkURNs["pliny_junior"] {
-				abbrevs := GenerateWorkAbbreviations(title)
-				for _, abbrev := range abbrevs {
-					if abbrev == work {
-						return "pliny_junior"
-					}
-				}
-			}
-		}
-
-		return "pliny_senior" // default
-
-	case "sen.", "seneca":
-		// Check which Seneca based on work
-		work = strings.ToLower(work)
-
-		// Check seneca_senior works (exact match and abbreviations)
-		if cd.Latin.WorkURNs["seneca_senior"] != nil {
-			if _, exists := cd.Latin.WorkURNs["seneca_senior"][work]; exists {
-				return "seneca_senior"
-			}
-			// Check abbreviations
-			for title := range cd.Latin.WorkURNs["seneca_senior"] {
-				abbrevs := GenerateWorkAbbreviations(title)
-				for _, abbrev := range abbrevs {
-					if abbrev == work {
-						return "seneca_senior"
-					}
-				}
-			}
-		}
-
-		// Check seneca_junior works (exact match and abbreviations)
-		if cd.Latin.WorkURNs["seneca_junior"] != nil {
-			if _, exists := cd.Latin.WorkURNs["seneca_junior"][work]; exists {
-				return "seneca_junior"
-			}
-			// Check abbreviations
-			for title := range cd.Latin.WorkURNs["seneca_junior"] {
-				abbrevs := GenerateWorkAbbreviations(title)
-				for _, abbrev := range abbrevs {
-					if abbrev == work {
-						return "seneca_junior"
-					}
-				}
-			}
-		}
-
-		return "seneca_junior" // default
-	}
-
-	return ""
+	return cd.AuthorFor(value, work)
 }
 
+// GenerateWorkAbbreviations generates the abbreviations of a work title with
+// DefaultStopwords, as GenerateAbbreviations does with
+// DefaultAbbreviationOptions
 func GenerateWorkAbbreviations(title string) []string {
+	return GenerateAbbreviations(title, DefaultAbbreviationOptions)
+}
+
+var numericTitleRegex = regexp.MustCompile(`^\d+$`)
+
+func generateWorkAbbreviations(title string, stopwords stopwordSets, options AbbreviationOptions) []string {
 	var abbreviations []string
 	title = strings.ToLower(title)
 
 	// Skip if numeric
-	if regexp.MustCompile(`^\d+$`).MatchString(title) {
+	if numericTitleRegex.MatchString(title) {
 		return abbreviations
 	}
 
@@ -464,6 +929,8 @@ func GenerateWorkAbbreviations(title string) []string {
 	if len(words) == 0 {
 		return abbreviations
 	}
+	// Function words to skip, in the title's language
+	funcWords := options.stopwordsFor(words, stopwords)
 
 	// Track existing abbreviations to avoid duplicates
 	existing := make(map[string]bool)
@@ -489,8 +956,8 @@ func GenerateWorkAbbreviations(title string) []string {
 	addAbbrev(string(title[0]))
 	addAbbrev(string(title[0]) + ".")
 
-	// First N letters (2-6) from first word
-	for i := 2; i <= 6 && i <= len(words[0]); i++ {
+	// First N letters (2 to MaxLen) from first word
+	for i := 2; i <= options.maxLen() && i <= len(words[0]); i++ {
 		abbrev := words[0][:i]
 		addAbbrev(abbrev)
 		addAbbrev(abbrev + ".")
@@ -518,12 +985,6 @@ func GenerateWorkAbbreviations(title string) []string {
 		addAbbrev(strings.TrimSuffix(underInitials, "_"))
 		addAbbrev(strings.TrimSuffix(dotUnderInitials, "._") + ".")
 
-		// Function words to skip (from Python)
-		funcWords := map[string]bool{
-			"the": true, "a": true, "an": true, "of": true, "in": true,
-			"by": true, "for": true, "on": true, "and": true, "de": true, "ad": true,
-		}
-
 		// Initials without function words
 		hasFuncWords := false
 		for _, word := range words {
@@ -572,11 +1033,13 @@ func GenerateWorkAbbreviations(title string) []string {
 	}
 
 	// Smart suspension (simplified version of Python's linguistic rules)
-	smartSusp := generateSmartSuspension(title, true) // skip "de"
-	addAbbrev(smartSusp)
+	if options.IncludeSuspensions {
+		smartSusp := generateSmartSuspension(title, true, funcWords) // skip "de"
+		addAbbrev(smartSusp)
 
-	smartSuspNoDe := generateSmartSuspension(title, false) // don't skip "de"
-	addAbbrev(smartSuspNoDe)
+		smartSuspNoDe := generateSmartSuspension(title, false, funcWords) // don't skip "de"
+		addAbbrev(smartSuspNoDe)
+	}
 
 	// Underscored version
 	if len(words) > 1 {
@@ -590,12 +1053,11 @@ func GenerateWorkAbbreviations(title string) []string {
 	return abbreviations
 }
 
-// generateSmartSuspension implements a simplified version of Python's smart suspension logic
-func generateSmartSuspension(title string, skipDe bool) string {
-	funcWords := map[string]bool{
-		"the": true, "a": true, "an": true, "of": true, "in": true,
-		"by": true, "for": true, "on": true, "and": true, "de": true, "ad": true,
-	}
+// generateSmartSuspension implements a simplified version of Python's smart
+// suspension logic. Citations of Latin titles leave out a leading "de" or
+// "ad" ("Amic." for "De Amicitia"), so skipDe skips those with "on" whether
+// or not they are stopwords.
+func generateSmartSuspension(title string, skipDe bool, funcWords map[string]bool) string {
 	vowels := map[rune]bool{'a': true, 'e': true, 'i': true, 'o': true, 'u': true, 'y': true}
 	plosives := map[rune]bool{'t': true, 'p': true, 'd': true, 'g': true, 'k': true, 'x': true, 'c': true, 'b': true}
 
@@ -603,7 +1065,7 @@ func generateSmartSuspension(title string, skipDe bool) string {
 	var suspensions []string
 
 	for _, word := range words {
-		if word == "de" || word == "on" {
+		if word == "de" || word == "ad" || word == "on" {
 			if skipDe {
 				continue
 			}
@@ -0,0 +1,80 @@
+package loader
+
+import "strings"
+
+// DisambiguatorPrefix opens the value of an author abbreviation that names
+// a disambiguator rather than an author, as "_which_pliny" in
+// latin_data.json names "pliny"
+const DisambiguatorPrefix = "_which_"
+
+// Disambiguator picks which of the authors an abbreviation stands for is
+// cited, as Pliny the Elder or the Younger for "Plin.", from the work cited
+// (lower-cased, as "nat." or "ep."), returning the author's canonical name,
+// or "" for none of them
+type Disambiguator func(cd *ComprehensiveData, work string) string
+
+// disambiguators are the registered disambiguators by name
+var disambiguators = map[string]Disambiguator{
+	"pliny":      ByWork("pliny_senior", "pliny_senior", "pliny_junior"),
+	"seneca":     ByWork("seneca_junior", "seneca_senior", "seneca_junior"),
+	"dionysius":  ByWork("dionysius", "dionysius", "dionysius periegetes"),
+	"apollonius": ByWork("apollonius, rhodius", "apollonius, rhodius", "apollonius dyscolus", "apollonius of perga"),
+	"xenophon":   ByWork("xenophon", "xenophon", "xenophon ephesius"),
+}
+
+// RegisterDisambiguator adds a disambiguator, which the data files name as
+// DisambiguatorPrefix followed by name, replacing any registered under the
+// same name. Like the other registries it must be called before the data is
+// shared between goroutines.
+func RegisterDisambiguator(name string, disambiguator Disambiguator) {
+	disambiguators[name] = disambiguator
+}
+
+// ByWork returns a Disambiguator that picks the first of authors with a
+// title, or an abbreviation generated from one, that is the work cited, or
+// else fallback
+func ByWork(fallback string, authors ...string) Disambiguator {
+	return func(cd *ComprehensiveData, work string) string {
+		work = strings.ToLower(work)
+		allWorkURNs := cd.GetAllWorkURNs()
+		for _, author := range authors {
+			works := allWorkURNs[author]
+			if _, exists := works[work]; exists {
+				return author
+			}
+			for title := range works {
+				for _, abbrev := range cd.WorkAbbreviations(title) {
+					if abbrev == work {
+						return author
+					}
+				}
+			}
+		}
+		return fallback
+	}
+}
+
+// DisambiguatorName returns the name of the disambiguator the value of an
+// author abbreviation names, if it names one
+func DisambiguatorName(value any) (string, bool) {
+	name, isName := value.(string)
+	if !isName || !strings.HasPrefix(name, DisambiguatorPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(name, DisambiguatorPrefix), true
+}
+
+// AuthorFor returns the author the value of an author abbreviation stands
+// for when work is cited: the value itself, or the author its disambiguator
+// picks. A disambiguator not registered picks no author.
+func (cd *ComprehensiveData) AuthorFor(value any, work string) string {
+	if name, named := DisambiguatorName(value); named {
+		disambiguator, exists := disambiguators[name]
+		if !exists {
+			return ""
+		}
+		return disambiguator(cd, work)
+	}
+	author, _ := value.(string)
+	return author
+}
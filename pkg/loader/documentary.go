@@ -0,0 +1,54 @@
+package loader
+
+import "strings"
+
+// DocumentaryCorporaFile is the optional data file of the corpora of
+// inscriptions and papyri, cited by volume and number rather than author and
+// work, as in "IG II² 1234" or "P.Oxy. 1234", mapping a corpus key (e.g.
+// "ig" or "p.oxy") to a DocumentaryCorpus
+const DocumentaryCorporaFile = "documentary_corpora.json"
+
+// DocumentaryCorpus is a corpus of inscriptions or papyri, such as the
+// Inscriptiones Graecae or the Oxyrhynchus Papyri
+type DocumentaryCorpus struct {
+	Title         string   `json:"title"`
+	Kind          string   `json:"kind"`          // "inscription" or "papyrus"
+	Abbreviations []string `json:"abbreviations"` // how citations name the corpus, lower-cased
+	// RequiresVolume is set for corpora numbering their texts afresh in
+	// each volume, which a citation must then give, as in "IG II² 1234" or
+	// "SEG 30.82"; in the others a volume is optional, as in "P.Oxy. 1234"
+	// beside "P.Oxy. IV 744"
+	RequiresVolume bool `json:"requires_volume,omitempty"`
+	// URL is the template of a text's external identifier, with the
+	// placeholders {volume} (in Arabic numerals), {edition} and {number},
+	// as "https://papyri.info/ddbdp/p.oxy;{volume};{number}". A text cited
+	// without a value the template needs has no external identifier.
+	URL string `json:"url,omitempty"`
+	// IDs are external identifiers that cannot be derived from the citation,
+	// such as PHI's numbers for inscriptions, by the volume and number of
+	// the text as in its identifier, e.g. "2ed2:1234" for IG II² 1234. They
+	// take precedence over URL.
+	IDs map[string]string `json:"ids,omitempty"`
+}
+
+// indexDocumentaryCorpora maps the abbreviations of each corpus to its key,
+// ignoring case
+func (cd *ComprehensiveData) indexDocumentaryCorpora() {
+	cd.documentaryAbbrevs = make(map[string]string)
+	for key, corpus := range cd.documentaryCorpora {
+		cd.documentaryAbbrevs[strings.ToLower(key)] = key
+		for _, abbreviation := range corpus.Abbreviations {
+			cd.documentaryAbbrevs[strings.ToLower(abbreviation)] = key
+		}
+	}
+}
+
+// DocumentaryCorpus returns the key of the corpus of inscriptions or papyri
+// an abbreviation cites, such as "p.oxy" for "P. Oxy.", and the corpus
+func (cd *ComprehensiveData) DocumentaryCorpus(abbreviation string) (string, DocumentaryCorpus, bool) {
+	key, exists := cd.documentaryAbbrevs[strings.ToLower(strings.TrimSpace(abbreviation))]
+	if !exists {
+		return "", DocumentaryCorpus{}, false
+	}
+	return key, cd.documentaryCorpora[key], true
+}
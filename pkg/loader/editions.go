@@ -0,0 +1,31 @@
+package loader
+
+import "strings"
+
+// EditionsFile is the optional data file of the modern editors and edition
+// series that references name after the passage, as in "Pl. Rep. 327a
+// Burnet" or "Soph. OT 100 (Lloyd-Jones)"
+const EditionsFile = "editions.json"
+
+// Editions lists the editors and series of EditionsFile
+type Editions struct {
+	Editors []string `json:"editors"` // e.g. "Burnet", "Lloyd-Jones"
+	Series  []string `json:"series"`  // e.g. "OCT", "Teubner"
+}
+
+// index returns the editors and series as a set of lower-cased names
+func (e Editions) index() map[string]bool {
+	names := make(map[string]bool, len(e.Editors)+len(e.Series))
+	for _, list := range [][]string{e.Editors, e.Series} {
+		for _, name := range list {
+			names[strings.ToLower(strings.TrimSpace(name))] = true
+		}
+	}
+	return names
+}
+
+// IsEdition reports whether a name, ignoring case, is an editor or edition
+// series of the data files
+func (cd *ComprehensiveData) IsEdition(name string) bool {
+	return cd.editions[strings.ToLower(strings.TrimSpace(name))]
+}
@@ -0,0 +1,30 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrDataFileMissing is matched by errors.Is for any error reporting a
+// missing required data file, however it is wrapped
+var ErrDataFileMissing = errors.New("citation data file missing")
+
+// ErrDataNotFound reports that a required data file is missing, typically
+// because the data directory was not found
+type ErrDataNotFound struct {
+	Path string
+	Err  error
+}
+
+func (e *ErrDataNotFound) Error() string {
+	return fmt.Sprintf("citation data not found: %s", e.Path)
+}
+
+func (e *ErrDataNotFound) Unwrap() error {
+	return e.Err
+}
+
+// Is reports ErrDataNotFound as ErrDataFileMissing
+func (e *ErrDataNotFound) Is(target error) bool {
+	return target == ErrDataFileMissing
+}
@@ -0,0 +1,57 @@
+package loader
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ExpansionEntry is one key of the work tables after expandWorkTitles has
+// added the generated abbreviations: a title, an alias or an abbreviation
+type ExpansionEntry struct {
+	Author     string   // author as named in the work tables, e.g. "sophocles"
+	Key        string   // lower-cased title, alias or abbreviation
+	Work       string   // work URN component the key resolves to, or the range of numbered works, e.g. "tlg001-tlg061"
+	Source     string   // title the abbreviation was generated from, "" for titles and aliases
+	Candidates []string // every work the key may refer to, when there are several
+}
+
+// String returns the entry as a tab-separated line: author, key, work, the
+// source title and the candidates, comma-separated
+func (e ExpansionEntry) String() string {
+	return strings.Join([]string{e.Author, e.Key, e.Work, e.Source, strings.Join(e.Candidates, ",")}, "\t")
+}
+
+// WorkExpansion returns every key of the expanded work tables, sorted by
+// author and key, so that changes to the abbreviation generation can be
+// reviewed as a diff of its output
+func (cd *ComprehensiveData) WorkExpansion() []ExpansionEntry {
+	var entries []ExpansionEntry
+	for author, works := range cd.GetAllWorkURNs() {
+		for key, urn := range works {
+			entry := ExpansionEntry{
+				Author: author,
+				Key:    key,
+				Work:   urn.Simple,
+				Source: cd.generatedFrom[author][key],
+			}
+			if urn.Range != nil {
+				entry.Work = fmt.Sprintf("%s%03d-%s%03d", urn.Range.Prefix, urn.Range.Start, urn.Range.Prefix, urn.Range.End)
+			}
+			if candidates := cd.workKeys[author][key]; len(candidates) > 1 {
+				for workURN := range candidates {
+					entry.Candidates = append(entry.Candidates, workURN)
+				}
+				sort.Strings(entry.Candidates)
+			}
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Author != entries[j].Author {
+			return entries[i].Author < entries[j].Author
+		}
+		return entries[i].Key < entries[j].Key
+	})
+	return entries
+}
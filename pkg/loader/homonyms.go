@@ -0,0 +1,54 @@
+package loader
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HomonymsFile is the optional data file of authors sharing a name, such as
+// the several Apollonii, mapping the shared name to the group of authors
+const HomonymsFile = "homonyms.json"
+
+// HomonymGroup is the authors that HomonymsFile lists under a shared name
+type HomonymGroup struct {
+	// Names are the abbreviations of the shared name, such as "apoll.",
+	// besides the name itself
+	Names   []string  `json:"names,omitempty"`
+	Members []Homonym `json:"members"`
+}
+
+// Homonym is an author of a HomonymGroup
+type Homonym struct {
+	Author string `json:"author"` // canonical name, e.g. "apollonius, rhodius"
+	// Epithets are the words written after the shared name that pick out
+	// the author, as "rhodius" or "rh." in "Apollonius Rhodius"
+	Epithets []string `json:"epithets"`
+}
+
+// HomonymGroup returns the group of authors sharing a name as cited, such
+// as "apollonius" or "apoll.", with the name it is listed under
+func (cd *ComprehensiveData) HomonymGroup(name string) (string, HomonymGroup, bool) {
+	key, exists := cd.homonymNames[strings.ToLower(name)]
+	if !exists {
+		return "", HomonymGroup{}, false
+	}
+	return key, cd.homonyms[key], true
+}
+
+// indexHomonyms maps every name of the homonym groups to the name the group
+// is listed under
+func (cd *ComprehensiveData) indexHomonyms() {
+	cd.homonymNames = make(map[string]string)
+	for key, group := range cd.homonyms {
+		cd.homonymNames[strings.ToLower(key)] = key
+		for _, name := range group.Names {
+			cd.homonymNames[strings.ToLower(name)] = key
+		}
+	}
+}
+
+// HomonymEntry returns the entry of the i-th author of a group in
+// HomonymsFile
+func HomonymEntry(group string, i int) DataEntry {
+	return DataEntry{File: HomonymsFile, Entry: fmt.Sprintf("[%q].members[%d]", group, i)}
+}
@@ -0,0 +1,34 @@
+package loader
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IncipitsFile is the optional data file of the poems and hymns cited by
+// their opening words rather than a number, as in "Hom. Hymn 'Εἰς
+// Ἀφροδίτην'" or "Hor. 'Integer vitae'", mapping an author to the poems
+const IncipitsFile = "incipits.json"
+
+// IncipitWork is a poem or hymn of an author that IncipitsFile lists
+type IncipitWork struct {
+	Work string `json:"work"` // work URN component, e.g. "tlg005"
+	// Passage locates the poem within the work, e.g. "1.22" for an ode of
+	// Horace; "" when the poem is a work of its own
+	Passage string `json:"passage,omitempty"`
+	// Incipits are the opening words the poem is cited by, and any title,
+	// as written in the edition
+	Incipits []string `json:"incipits"`
+}
+
+// Incipits returns the poems of an author, by canonical name, that are
+// cited by incipit, in the order of IncipitsFile
+func (cd *ComprehensiveData) Incipits(author string) []IncipitWork {
+	return cd.incipits[strings.ToLower(author)]
+}
+
+// IncipitEntry returns the entry of the i-th poem of an author in
+// IncipitsFile
+func IncipitEntry(author string, i int) DataEntry {
+	return DataEntry{File: IncipitsFile, Entry: fmt.Sprintf("[%q][%d]", author, i)}
+}
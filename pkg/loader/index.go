@@ -0,0 +1,207 @@
+package loader
+
+import (
+	"sort"
+	"strings"
+)
+
+// nameNode is a node of the word trie over author names and abbreviations:
+// the path from the root spells the leading words of a name, and key is set
+// where they make up a whole one
+type nameNode struct {
+	key      string
+	children map[string]*nameNode
+}
+
+// NamePrefix is an author name or abbreviation that a reference starts with
+type NamePrefix struct {
+	Key   string // as in GetAllAuthors or GetAllAuthAbb, e.g. "ap. rhod."
+	Words int    // how many words of the reference it takes up
+}
+
+// buildPrefixes builds the word trie over the keys of authors and authAbb.
+// Keys that strings.Fields would not give back unchanged, such as those with
+// doubled spaces, are left out, as no reference split into words spells them.
+func buildPrefixes(authors map[string]bool, authAbb map[string]interface{}) *nameNode {
+	root := &nameNode{}
+	add := func(key string) {
+		words := strings.Fields(key)
+		if len(words) == 0 || strings.Join(words, " ") != key {
+			return
+		}
+		node := root
+		for _, word := range words {
+			child := node.children[word]
+			if child == nil {
+				if node.children == nil {
+					node.children = make(map[string]*nameNode)
+				}
+				child = &nameNode{}
+				node.children[word] = child
+			}
+			node = child
+		}
+		node.key = key
+	}
+	for author := range authors {
+		add(author)
+	}
+	for abbreviation := range authAbb {
+		add(abbreviation)
+	}
+	return root
+}
+
+// AuthorPrefixes appends to dst the author names and abbreviations spelled
+// by the leading words of words, of at most maxWords words, shortest first.
+// Lookups walk the words once rather than joining every n-gram to try it
+// against the tables, and with a dst of enough capacity do not allocate.
+func (cd *ComprehensiveData) AuthorPrefixes(dst []NamePrefix, words []string, maxWords int) []NamePrefix {
+	var root *nameNode
+	if cd.index != nil {
+		root = cd.index.prefixes
+	} else {
+		root = buildPrefixes(cd.combineAuthors(), cd.combineAuthAbb())
+	}
+	prefixes := dst
+	node := root
+	for i := 0; i < len(words) && i < maxWords; i++ {
+		if node = node.children[words[i]]; node == nil {
+			break
+		}
+		if node.key != "" {
+			prefixes = append(prefixes, NamePrefix{Key: node.key, Words: i + 1})
+		}
+	}
+	return prefixes
+}
+
+// AuthorNames returns every author name and abbreviation, sorted. The slice
+// is shared by all callers and must not be modified.
+func (cd *ComprehensiveData) AuthorNames() []string {
+	if cd.index != nil {
+		return cd.index.names
+	}
+	return sortedNames(cd.combineAuthors(), cd.combineAuthAbb())
+}
+
+// sortedNames lists the keys of authors and authAbb, sorted and without
+// duplicates
+func sortedNames(authors map[string]bool, authAbb map[string]interface{}) []string {
+	names := make([]string, 0, len(authors)+len(authAbb))
+	for author := range authors {
+		names = append(names, author)
+	}
+	for abbreviation := range authAbb {
+		if !authors[abbreviation] {
+			names = append(names, abbreviation)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// internStrings makes the URNs and author names repeated across the
+// tables share one copy each. The JSON decoder allocates every occurrence
+// of a string afresh, and an author's URN or a work's is repeated under
+// each of its abbreviations, more so once expandWorkTitles has added
+// generated ones.
+func (cd *ComprehensiveData) internStrings() {
+	interned := make(map[string]string)
+	intern := func(s string) string {
+		if existing, ok := interned[s]; ok {
+			return existing
+		}
+		interned[s] = s
+		return s
+	}
+	internWorks := func(workURNs map[string]map[string]WorkURN) {
+		for _, works := range workURNs {
+			for key, urn := range works {
+				if urn.Range != nil {
+					urn.Range.Prefix = intern(urn.Range.Prefix)
+				} else {
+					urn.Simple = intern(urn.Simple)
+				}
+				works[key] = urn
+			}
+		}
+	}
+	internNames := func(names map[string]string) {
+		for key, name := range names {
+			names[key] = intern(name)
+		}
+	}
+
+	internNames(cd.Greek.AuthAbb)
+	internNames(cd.Greek.AuthURNs)
+	internWorks(cd.Greek.WorkURNs)
+	for key, value := range cd.Latin.AuthAbb {
+		if name, ok := value.(string); ok {
+			cd.Latin.AuthAbb[key] = intern(name)
+		}
+	}
+	internNames(cd.Latin.AuthURNs)
+	internWorks(cd.Latin.WorkURNs)
+	internNames(cd.Schol.AuthAbb)
+	internNames(cd.Schol.AuthURNs)
+	internWorks(cd.Schol.WorkURNs)
+	internNames(cd.Other.AuthAbb)
+	internNames(cd.Other.AuthURNs)
+	internWorks(cd.Other.WorkURNs)
+}
+
+// derivedWork is the work an abbreviation of a work key refers to, and the
+// key it was generated from
+type derivedWork struct {
+	urn, from string
+}
+
+// buildDerived maps, for each author, the abbreviations generated from the
+// keys of their work table, titles and generated abbreviations alike, that
+// are not keys themselves, to the work whose key generated them. Between
+// several works it is the one whose key sorts first.
+func (cd *ComprehensiveData) buildDerived(workURNs map[string]map[string]WorkURN) map[string]map[string]derivedWork {
+	derived := make(map[string]map[string]derivedWork, len(workURNs))
+	for author, works := range workURNs {
+		byAbbrev := make(map[string]derivedWork)
+		for key, urn := range works {
+			cd.addDerived(byAbbrev, works, key, urn)
+		}
+		derived[author] = byAbbrev
+	}
+	return derived
+}
+
+// addDerived records the abbreviations generated from a work key
+func (cd *ComprehensiveData) addDerived(byAbbrev map[string]derivedWork, works map[string]WorkURN, key string, urn WorkURN) {
+	if urn.Simple == "" {
+		return
+	}
+	for _, abbrev := range cd.WorkAbbreviations(key) {
+		if _, isKey := works[abbrev]; isKey {
+			continue
+		}
+		if current, exists := byAbbrev[abbrev]; !exists || key < current.from {
+			byAbbrev[abbrev] = derivedWork{urn: urn.Simple, from: key}
+		}
+	}
+}
+
+// DerivedWork returns the work URN that work, which is not itself a key of
+// the author's work table, abbreviates by being generated from one of its
+// keys. Without the index, built at load, every key of the table has its
+// abbreviations generated on each call.
+func (cd *ComprehensiveData) DerivedWork(author, work string) (string, bool) {
+	if cd.index != nil {
+		derived, exists := cd.index.derived[author][work]
+		return derived.urn, exists
+	}
+	works := cd.GetAllWorkURNs()[author]
+	byAbbrev := make(map[string]derivedWork)
+	for key, urn := range works {
+		cd.addDerived(byAbbrev, works, key, urn)
+	}
+	derived, exists := byAbbrev[work]
+	return derived.urn, exists
+}
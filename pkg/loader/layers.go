@@ -0,0 +1,115 @@
+package loader
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ScholarlyAbbreviationsFile is the optional abbreviation layer of the
+// author sigla of the Oxford Classical Dictionary and of Liddell-Scott-Jones,
+// such as "A." for Aeschylus and "Ar." for Aristophanes beside "Arist." for
+// Aristotle, that the packs lack
+const ScholarlyAbbreviationsFile = "scholarly_abbreviations.json"
+
+// AbbreviationLayer is a file of author abbreviations laid over the packs'
+// own. Its abbreviations never override those of a pack, and one of an
+// author no pack gives a URN for is ignored.
+type AbbreviationLayer struct {
+	Name          string                       `json:"name"` // e.g. "ocd-lsj"
+	Description   string                       `json:"description,omitempty"`
+	Abbreviations map[string]LayerAbbreviation `json:"abbreviations"`
+}
+
+// LayerAbbreviation is an abbreviation of an AbbreviationLayer
+type LayerAbbreviation struct {
+	Author string `json:"author"` // canonical name, e.g. "aeschylus"
+	Source string `json:"source"` // the convention it follows, e.g. "LSJ" or "LSJ, OCD"
+}
+
+// abbreviationLayer is a layer as loaded, with the file it was read from
+type abbreviationLayer struct {
+	file string
+	AbbreviationLayer
+}
+
+// loadAbbreviationLayer loads the abbreviation layer of a data file if the
+// file is present, keeping its checksum for Summary
+func (cd *ComprehensiveData) loadAbbreviationLayer(dataDir, name string) error {
+	content, err := os.ReadFile(filepath.Join(dataDir, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s/%s: %w", dataDir, name, err)
+	}
+	layer := abbreviationLayer{file: name}
+	if err := json.Unmarshal(content, &layer.AbbreviationLayer); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	if layer.Name == "" {
+		return fmt.Errorf("%s: abbreviation layer has no name", name)
+	}
+	cd.recordPack(name, content)
+	cd.layers = append(cd.layers, layer)
+	return nil
+}
+
+// layerAbbreviations adds to combined, the packs' abbreviations, those of
+// the layers that neither override one of a pack nor name an author without
+// a URN, earlier layers winning
+func (cd *ComprehensiveData) layerAbbreviations(combined map[string]interface{}, authURNs map[string]string) {
+	for _, layer := range cd.layers {
+		for abbrev, entry := range layer.Abbreviations {
+			if _, exists := combined[abbrev]; exists {
+				continue
+			}
+			if _, known := authURNs[entry.Author]; known {
+				combined[abbrev] = entry.Author
+			}
+		}
+	}
+}
+
+// layerEntry returns the entry of the layer an abbreviation is taken from,
+// for an abbreviation no pack has
+func (cd *ComprehensiveData) layerEntry(abbreviation string) (DataEntry, bool) {
+	for _, layer := range cd.layers {
+		if entry, exists := layer.Abbreviations[abbreviation]; exists {
+			return DataEntry{File: layer.file, Entry: fmt.Sprintf("abbreviations[%q] (%s)", abbreviation, entry.Source)}, true
+		}
+	}
+	return DataEntry{}, false
+}
+
+// LayerOnly reports whether an author name or abbreviation, as a key that
+// AuthorPrefixes gives, is known only from an abbreviation layer, no pack
+// giving it
+func (cd *ComprehensiveData) LayerOnly(key string) bool {
+	if _, exists := cd.layerEntry(key); !exists {
+		return false
+	}
+	_, greek := cd.Greek.AuthAbb[key]
+	_, latin := cd.Latin.AuthAbb[key]
+	_, schol := cd.Schol.AuthAbb[key]
+	_, other := cd.Other.AuthAbb[key]
+	return !greek && !latin && !schol && !other && !cd.combineAuthors()[key]
+}
+
+// layerSummary describes a layer as Summary does a pack, counting the
+// distinct authors its abbreviations name
+func (cd *ComprehensiveData) layerSummary(layer abbreviationLayer) PackSummary {
+	authors := make(map[string]bool)
+	for _, entry := range layer.Abbreviations {
+		authors[entry.Author] = true
+	}
+	return PackSummary{
+		Pack:          layer.Name,
+		File:          layer.file,
+		Authors:       len(authors),
+		Abbreviations: len(layer.Abbreviations),
+		Checksum:      cd.packs[layer.file].checksum,
+		SchemaVersion: cd.packs[layer.file].schemaVersion,
+	}
+}
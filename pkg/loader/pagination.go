@@ -0,0 +1,44 @@
+package loader
+
+// PaginationFile is the optional data file of the works cited by the pages
+// of an early printed edition, such as Plato by Stephanus's, mapping author
+// -> work URN component, or "*" for all of the author's works, to the
+// Pagination
+const PaginationFile = "pagination.json"
+
+// Page-based citation systems of PaginationFile
+const (
+	// Stephanus, as in "Rep. 327a5"; Perseus cites the page and section,
+	// "327a"
+	Stephanus = "stephanus"
+	// Bekker, as in "EN 1094b10"; Perseus cites the page and column, then
+	// the line, "1094b.10"
+	Bekker = "bekker"
+	// Casaubon, as in "Strab. C213"; Perseus cites book, chapter and
+	// section, found from the page by the Pagination's Pages
+	Casaubon = "casaubon"
+)
+
+// Pagination is how a work's references give its pages
+type Pagination struct {
+	System string `json:"system"` // Stephanus, Bekker or Casaubon
+	// Pages maps passages of the Perseus edition, such as the book "5" or
+	// chapter "5.1", to the first and last pages they span, for systems
+	// whose pages do not themselves fix the passage. For Stephanus and
+	// Bekker pages they give the book, e.g. of the Republic.
+	Pages map[string][2]int `json:"pages,omitempty"`
+	// Lines is set if the edition numbers lines within a Stephanus section
+	// or Bekker column, which are otherwise dropped
+	Lines bool `json:"lines,omitempty"`
+}
+
+// Pagination returns the page-based citation system of an author's work,
+// given its work URN component, or of all the author's works
+func (cd *ComprehensiveData) Pagination(author, workURN string) (Pagination, bool) {
+	works := cd.paginations[author]
+	if pagination, exists := works[workURN]; exists {
+		return pagination, true
+	}
+	pagination, exists := works["*"]
+	return pagination, exists
+}
@@ -0,0 +1,163 @@
+package loader
+
+import "fmt"
+
+// DataEntry locates an entry of a data file, such as the abbreviation
+// "plin." in latin_data.json, so that a wrong resolution can be traced to
+// the entry that produced it
+type DataEntry struct {
+	File  string `json:"file"`  // e.g. "latin_data.json"
+	Entry string `json:"entry"` // e.g. `AuthAbb["plin."]`
+}
+
+func (e DataEntry) String() string {
+	return e.File + ": " + e.Entry
+}
+
+// namespaceFile is one of the required data files with the tables it holds
+type namespaceFile struct {
+	name              string
+	hasAbbreviation   func(abbreviation string) bool
+	authURNs          map[string]string
+	workURNs          map[string]map[string]WorkURN
+	singleWorkAuthors []string
+}
+
+// namespaceFiles returns the required data files in the order the GetAll
+// methods merge them, later files winning as they do there
+func (cd *ComprehensiveData) namespaceFiles() []namespaceFile {
+	has := func(abbreviations map[string]string) func(string) bool {
+		return func(abbreviation string) bool {
+			_, exists := abbreviations[abbreviation]
+			return exists
+		}
+	}
+	return []namespaceFile{
+		{"greek_data.json", has(cd.Greek.AuthAbb), cd.Greek.AuthURNs, cd.Greek.WorkURNs, cd.Greek.SingleWorkAuthors},
+		{"latin_data.json", func(abbreviation string) bool {
+			_, exists := cd.Latin.AuthAbb[abbreviation]
+			return exists
+		}, cd.Latin.AuthURNs, cd.Latin.WorkURNs, cd.Latin.SingleWorkAuthors},
+		{"schol_data.json", has(cd.Schol.AuthAbb), cd.Schol.AuthURNs, cd.Schol.WorkURNs, nil},
+		{"other_data.json", has(cd.Other.AuthAbb), cd.Other.AuthURNs, cd.Other.WorkURNs, nil},
+	}
+}
+
+// AuthorEntries returns the entries that map an author as cited to the
+// author's URN: the abbreviation, of a pack or else of the abbreviation
+// layer it is taken from, unless the author was cited by name, and the
+// author's URN
+func (cd *ComprehensiveData) AuthorEntries(cited, author string) []DataEntry {
+	var entries []DataEntry
+	files := cd.namespaceFiles()
+	if cited != author {
+		found := false
+		for i := len(files) - 1; i >= 0 && !found; i-- {
+			if files[i].hasAbbreviation(cited) {
+				entries = append(entries, DataEntry{File: files[i].name, Entry: fmt.Sprintf("AuthAbb[%q]", cited)})
+				found = true
+			}
+		}
+		if entry, exists := cd.layerEntry(cited); exists && !found {
+			entries = append(entries, entry)
+		}
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		if _, exists := files[i].authURNs[author]; exists {
+			entries = append(entries, DataEntry{File: files[i].name, Entry: fmt.Sprintf("AuthURNs[%q]", author)})
+			break
+		}
+	}
+	return entries
+}
+
+// SingleWorkEntry returns the entry listing a single-work author
+func (cd *ComprehensiveData) SingleWorkEntry(author string) (DataEntry, bool) {
+	for _, file := range cd.namespaceFiles() {
+		for _, single := range file.singleWorkAuthors {
+			if single == author {
+				return DataEntry{File: file.name, Entry: fmt.Sprintf("SingleWorkAuthors[%q]", author)}, true
+			}
+		}
+	}
+	return DataEntry{}, false
+}
+
+// WorkEntry returns the entry that maps a work as cited (lower-cased) to the
+// work URN component it resolved to: the title, the alias from
+// work_aliases.json, or the title whose generated abbreviation it is. For a
+// range of numbered works, workURN is "" and key the range's title.
+func (cd *ComprehensiveData) WorkEntry(author, key, workURN string) (DataEntry, bool) {
+	files := cd.namespaceFiles()
+	file := ""
+	for i := len(files) - 1; i >= 0; i-- {
+		if _, exists := files[i].workURNs[author]; exists {
+			file = files[i].name
+			break
+		}
+	}
+	if file == "" {
+		return DataEntry{}, false
+	}
+
+	title := key
+	if workURN != "" {
+		title = cd.titleFor(author, key, workURN)
+		if title == "" {
+			return DataEntry{}, false
+		}
+	}
+
+	entry := DataEntry{File: file, Entry: fmt.Sprintf("WorkURNs[%q][%q]", author, title)}
+	if cd.workAliases[author][title] {
+		entry = DataEntry{File: WorkAliasesFile, Entry: fmt.Sprintf("[%q][%q]", author, title)}
+	}
+	if title != key {
+		entry.Entry += fmt.Sprintf(" (abbreviated %q)", key)
+	}
+	return entry, true
+}
+
+// titleFor returns the title in the data files through which key refers to
+// workURN: key itself, or the title it was generated from or else, when the
+// abbreviation went to another work, the first title of this work that
+// generates it
+func (cd *ComprehensiveData) titleFor(author, key, workURN string) string {
+	generatedFrom := cd.generatedFrom[author]
+	source, generated := generatedFrom[key]
+	if !generated && cd.workKeys[author][key][workURN] == 1 {
+		return key
+	}
+	if generated && cd.workKeys[author][source][workURN] == 1 {
+		return source
+	}
+	title := ""
+	for candidate, works := range cd.workKeys[author] {
+		if _, isGenerated := generatedFrom[candidate]; isGenerated || works[workURN] != 1 {
+			continue
+		}
+		if (title == "" || candidate < title) && cd.generates(candidate, key) {
+			title = candidate
+		}
+	}
+	return title
+}
+
+// generates reports whether abbreviation is among a title's generated
+// abbreviations
+func (cd *ComprehensiveData) generates(title, abbreviation string) bool {
+	for _, generated := range cd.WorkAbbreviations(title) {
+		if generated == abbreviation {
+			return true
+		}
+	}
+	return false
+}
+
+// PerseusTextEntry returns the entry of a legacy Perseus text ID
+func (cd *ComprehensiveData) PerseusTextEntry(textID string) (DataEntry, bool) {
+	if _, exists := cd.perseusTextIDs[textID]; !exists {
+		return DataEntry{}, false
+	}
+	return DataEntry{File: PerseusTextIDsFile, Entry: fmt.Sprintf("[%q]", textID)}, true
+}
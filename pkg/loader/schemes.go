@@ -0,0 +1,55 @@
+package loader
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// WorkScheme is how a work is cited, from CitationSchemesFile: its
+// citation scheme, whose levels give the depth of its passages, and the last
+// number of its top level if known, such as the 24 books of the Iliad or the
+// 1530 lines of the Oedipus Tyrannus. In the file it is either the scheme
+// alone, "book.line", or an object, {"scheme": "book.line", "max": 24}.
+type WorkScheme struct {
+	Scheme string `json:"scheme"`
+	Max    int    `json:"max,omitempty"` // 0 if not recorded
+}
+
+// Depth returns the levels of the scheme's passages, 0 without a scheme
+func (s WorkScheme) Depth() int {
+	if s.Scheme == "" {
+		return 0
+	}
+	depth := 1
+	for _, r := range s.Scheme {
+		if r == '.' {
+			depth++
+		}
+	}
+	return depth
+}
+
+func (s *WorkScheme) UnmarshalJSON(data []byte) error {
+	var scheme string
+	if err := json.Unmarshal(data, &scheme); err == nil {
+		*s = WorkScheme{Scheme: scheme}
+		return nil
+	}
+	type plain WorkScheme
+	var entry plain
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return fmt.Errorf("citation scheme must be a string or an object with scheme and max: %w", err)
+	}
+	if entry.Scheme == "" || entry.Max < 0 {
+		return fmt.Errorf("citation scheme object needs a scheme and a max of at least 0")
+	}
+	*s = WorkScheme(entry)
+	return nil
+}
+
+// WorkScheme returns how an author's work is cited given its work URN
+// component (e.g. "tlg001"), and whether it is recorded
+func (cd *ComprehensiveData) WorkScheme(author, workURN string) (WorkScheme, bool) {
+	scheme, exists := cd.citationSchemes[author][workURN]
+	return scheme, exists
+}
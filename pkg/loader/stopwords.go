@@ -0,0 +1,78 @@
+package loader
+
+import (
+	"sort"
+	"strings"
+)
+
+// StopwordsFile is the optional data file of the function words left out of
+// the initials of work titles, mapping language -> words. Titles are in
+// English or Latin whatever the author's language ("on the crown", "de
+// corona"), so each title takes the stopwords of the language whose words it
+// uses most. The file replaces DefaultStopwords language by language.
+const StopwordsFile = "stopwords.json"
+
+// DefaultStopwords are the stopwords used for languages the data files do
+// not list. Latin "de" and "ad" are not among them: they carry meaning in
+// the initials of titles such as "De Amicitia".
+var DefaultStopwords = map[string][]string{
+	"english": {"the", "a", "an", "of", "in", "by", "for", "on", "and"},
+	"latin":   {"et", "ac", "atque", "in"},
+}
+
+// stopwordSets holds the stopwords of each language as sets
+type stopwordSets map[string]map[string]bool
+
+// newStopwordSets builds the sets for the defaults with the given languages
+// replacing theirs
+func newStopwordSets(languages map[string][]string) stopwordSets {
+	sets := make(stopwordSets)
+	for _, source := range []map[string][]string{DefaultStopwords, languages} {
+		for language, words := range source {
+			set := make(map[string]bool, len(words))
+			for _, word := range words {
+				set[strings.ToLower(strings.TrimSpace(word))] = true
+			}
+			sets[language] = set
+		}
+	}
+	return sets
+}
+
+// forTitle returns the stopwords of the language most of the title's words
+// are stopwords of, the first by name on a tie, or nil if it has none
+func (s stopwordSets) forTitle(words []string) map[string]bool {
+	languages := make([]string, 0, len(s))
+	for language := range s {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+
+	var best map[string]bool
+	bestCount := 0
+	for _, language := range languages {
+		count := 0
+		for _, word := range words {
+			if s[language][word] {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = s[language], count
+		}
+	}
+	return best
+}
+
+// defaultStopwordSets are the sets of DefaultStopwords, for
+// GenerateAbbreviations
+var defaultStopwordSets = newStopwordSets(nil)
+
+// WorkAbbreviations generates the abbreviations of a work title with the
+// stopwords of the data files
+func (cd *ComprehensiveData) WorkAbbreviations(title string) []string {
+	if cd.stopwords == nil {
+		return GenerateWorkAbbreviations(title)
+	}
+	return generateWorkAbbreviations(title, cd.stopwords, DefaultAbbreviationOptions)
+}
@@ -0,0 +1,101 @@
+package loader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// DataSchemaVersion is the schema version of the pack files this loader
+// reads. Files without a SCHEMA_VERSION key predate it and are version 1.
+const DataSchemaVersion = 1
+
+// PackSummary describes one of the data packs loaded, for checking which
+// data a run will use
+type PackSummary struct {
+	Pack          string // "greek", "latin", "schol" or "other", or a layer's name
+	File          string // e.g. "greek_data.json"
+	Authors       int    // authors with a URN
+	Works         int    // distinct works, each of a range of works counted
+	Abbreviations int    // author abbreviations
+	Checksum      string // "sha256:" and the digest of the file as loaded
+	SchemaVersion int
+}
+
+// packFile is what is kept of a pack file's bytes once parsed
+type packFile struct {
+	checksum      string
+	schemaVersion int
+}
+
+// recordPack keeps the checksum and schema version of a pack file
+func (cd *ComprehensiveData) recordPack(name string, content []byte) {
+	if cd.packs == nil {
+		cd.packs = make(map[string]packFile)
+	}
+	sum := sha256.Sum256(content)
+	var header struct {
+		SchemaVersion int `json:"SCHEMA_VERSION"`
+	}
+	json.Unmarshal(content, &header)
+	if header.SchemaVersion == 0 {
+		header.SchemaVersion = 1
+	}
+	cd.packs[name] = packFile{checksum: "sha256:" + hex.EncodeToString(sum[:]), schemaVersion: header.SchemaVersion}
+}
+
+// Summary describes the data packs, in the order they are loaded, then the
+// abbreviation layers laid over them, which give no works. Works
+// are counted after the work tables are expanded, by the URNs they map to,
+// so generated abbreviations do not inflate the count.
+func (cd *ComprehensiveData) Summary() []PackSummary {
+	packs := []struct {
+		pack, file    string
+		authURNs      map[string]string
+		workURNs      map[string]map[string]WorkURN
+		abbreviations int
+	}{
+		{"greek", "greek_data.json", cd.Greek.AuthURNs, cd.Greek.WorkURNs, len(cd.Greek.AuthAbb)},
+		{"latin", "latin_data.json", cd.Latin.AuthURNs, cd.Latin.WorkURNs, len(cd.Latin.AuthAbb)},
+		{"schol", "schol_data.json", cd.Schol.AuthURNs, cd.Schol.WorkURNs, len(cd.Schol.AuthAbb)},
+		{"other", "other_data.json", cd.Other.AuthURNs, cd.Other.WorkURNs, len(cd.Other.AuthAbb)},
+	}
+	summaries := make([]PackSummary, 0, len(packs))
+	for _, pack := range packs {
+		summary := PackSummary{
+			Pack:          pack.pack,
+			File:          pack.file,
+			Authors:       len(pack.authURNs),
+			Works:         countWorks(pack.workURNs),
+			Abbreviations: pack.abbreviations,
+			Checksum:      cd.packs[pack.file].checksum,
+			SchemaVersion: cd.packs[pack.file].schemaVersion,
+		}
+		summaries = append(summaries, summary)
+	}
+	for _, layer := range cd.layers {
+		summaries = append(summaries, cd.layerSummary(layer))
+	}
+	return summaries
+}
+
+// countWorks counts the distinct works of a work table
+func countWorks(workURNs map[string]map[string]WorkURN) int {
+	count := 0
+	for _, works := range workURNs {
+		seen := make(map[string]bool)
+		for _, urn := range works {
+			switch {
+			case urn.Range != nil:
+				for n := urn.Range.Start; n <= urn.Range.End; n++ {
+					seen[fmt.Sprintf("%s%03d", urn.Range.Prefix, n)] = true
+				}
+			case urn.Simple != "":
+				seen[urn.Simple] = true
+			}
+		}
+		count += len(seen)
+	}
+	return count
+}
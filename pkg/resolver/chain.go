@@ -0,0 +1,102 @@
+package resolver
+
+import (
+	"math"
+
+	"perseus_citation_linker/pkg/cts"
+)
+
+// Stage places a ResolutionStep relative to the built-in heuristics
+type Stage int
+
+const (
+	// BeforeBuiltin steps run first, after the corrections table; an answer
+	// is taken without running the heuristics
+	BeforeBuiltin Stage = iota
+	// AfterBuiltin steps see the heuristics' resolution, and an answer
+	// replaces it
+	AfterBuiltin
+)
+
+// StepContext is what a ResolutionStep knows of a reference besides its text
+type StepContext struct {
+	Context  string // text around the citation
+	Filename string // citing document
+	// Builtin is the resolution by the built-in heuristics, for steps after
+	// them; nil for steps before
+	Builtin *Resolution
+}
+
+// ResolutionStep is a corpus-specific resolution rule, such as "bare numbers
+// in this commentary cite Pindar's Olympians". It returns the URN a
+// reference cites and its confidence, between 0 and 1, or the zero URN if
+// the rule does not apply.
+type ResolutionStep interface {
+	Resolve(ref string, ctx StepContext) (cts.URN, float64)
+}
+
+// StepFunc lets a function serve as a ResolutionStep
+type StepFunc func(ref string, ctx StepContext) (cts.URN, float64)
+
+func (f StepFunc) Resolve(ref string, ctx StepContext) (cts.URN, float64) {
+	return f(ref, ctx)
+}
+
+// ResolverChain holds the custom steps run around the built-in heuristics.
+// Of the steps of a stage that answer, the most confident wins, ties going
+// to the step registered first.
+type ResolverChain struct {
+	steps []chainStep
+}
+
+type chainStep struct {
+	name  string
+	stage Stage
+	step  ResolutionStep
+}
+
+// Register adds a step to the chain under a name, which resolutions it gives
+// record as their Step
+func (c *ResolverChain) Register(name string, stage Stage, step ResolutionStep) {
+	c.steps = append(c.steps, chainStep{name: name, stage: stage, step: step})
+}
+
+// Len returns the number of steps registered; a nil chain has none
+func (c *ResolverChain) Len() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.steps)
+}
+
+// applyChain runs the steps of a stage and, if one answers with a valid URN,
+// sets res to its resolution. A step answering with a malformed URN is a bug
+// in the step rather than in the reference, so it is logged and ignored.
+func (ur *URNResolver) applyChain(res *Resolution, stage Stage, ref string, ctx StepContext) bool {
+	if ur.Chain == nil {
+		return false
+	}
+	var best Resolution
+	for _, s := range ur.Chain.steps {
+		if s.stage != stage {
+			continue
+		}
+		urn, confidence := s.step.Resolve(ref, ctx)
+		if urn == (cts.URN{}) {
+			continue
+		}
+		if err := urn.Validate(); err != nil {
+			ur.logger().Warn("resolution step gave a malformed URN", "step", s.name, "ref", ref, "error", err)
+			continue
+		}
+		confidence = math.Max(0, math.Min(1, confidence))
+		if best.URN == "" || confidence > best.Confidence {
+			best = Resolution{URN: urn.String(), Step: s.name, Confidence: confidence}
+		}
+	}
+	if best.URN == "" {
+		return false
+	}
+	*res = best
+	return true
+}
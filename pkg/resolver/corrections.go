@@ -0,0 +1,157 @@
+package resolver
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"perseus_citation_linker/pkg/cts"
+)
+
+// Corrections pins the resolution of known-bad references. Entries are keyed
+// either by bibl text (or n attribute), normalized as references are, or by
+// the doc_cit_urn of a single citation.
+//
+// JSON files have the form
+//
+//	{"bibl": {"Soph. OT 100": "urn:..."}, "doc_cit_urn": {"campbell-sophlanguage-2:citations-5": "urn:..."}}
+//
+// and CSV files have rows of kind (bibl or doc_cit_urn), key and URN, with an
+// optional "kind,key,urn" header.
+type Corrections struct {
+	refs       map[string]string
+	docCitURNs map[string]string
+}
+
+// correctionsFile is the JSON form of a corrections table
+type correctionsFile struct {
+	Bibl      map[string]string `json:"bibl"`
+	DocCitURN map[string]string `json:"doc_cit_urn"`
+}
+
+// NewCorrections returns an empty corrections table
+func NewCorrections() *Corrections {
+	return &Corrections{refs: make(map[string]string), docCitURNs: make(map[string]string)}
+}
+
+// LoadCorrections reads a corrections table, choosing CSV or JSON by extension
+func LoadCorrections(path string) (*Corrections, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read corrections %s: %w", path, err)
+	}
+	defer file.Close()
+
+	corrections := NewCorrections()
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		err = corrections.readCSV(file)
+	} else {
+		err = corrections.readJSON(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse corrections %s: %w", path, err)
+	}
+	return corrections, nil
+}
+
+func (c *Corrections) readJSON(r io.Reader) error {
+	var table correctionsFile
+	if err := json.NewDecoder(r).Decode(&table); err != nil {
+		return err
+	}
+	for bibl, urn := range table.Bibl {
+		if err := validateCorrection(urn); err != nil {
+			return fmt.Errorf("bibl %q: %w", bibl, err)
+		}
+		c.AddBibl(bibl, urn)
+	}
+	for docCitURN, urn := range table.DocCitURN {
+		if err := validateCorrection(urn); err != nil {
+			return fmt.Errorf("doc_cit_urn %q: %w", docCitURN, err)
+		}
+		c.AddDocCitURN(docCitURN, urn)
+	}
+	return nil
+}
+
+// validateCorrection checks a pinned URN that is a CTS URN; others, such as
+// fragment identifiers, are taken as they are
+func validateCorrection(urn string) error {
+	if !strings.HasPrefix(urn, cts.Prefix) {
+		return nil
+	}
+	_, err := cts.Parse(urn)
+	return err
+}
+
+func (c *Corrections) readCSV(r io.Reader) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		kind, key, urn := strings.TrimSpace(record[0]), record[1], strings.TrimSpace(record[2])
+		if err := validateCorrection(urn); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		switch kind {
+		case "bibl":
+			c.AddBibl(key, urn)
+		case "doc_cit_urn":
+			c.AddDocCitURN(key, urn)
+		case "kind":
+			if line == 1 {
+				continue
+			}
+			fallthrough
+		default:
+			return fmt.Errorf("line %d: unknown kind %q (want bibl or doc_cit_urn)", line, kind)
+		}
+	}
+}
+
+// AddBibl pins the URN for a bibl text or n attribute
+func (c *Corrections) AddBibl(bibl, urn string) {
+	c.refs[cleanRef(strings.ToLower(strings.TrimSpace(bibl)))] = urn
+}
+
+// AddDocCitURN pins the URN for a single citation
+func (c *Corrections) AddDocCitURN(docCitURN, urn string) {
+	c.docCitURNs[strings.TrimSpace(docCitURN)] = urn
+}
+
+// ForRef returns the pinned URN for a reference as produced by GetRef
+func (c *Corrections) ForRef(ref string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	urn, exists := c.refs[ref]
+	return urn, exists
+}
+
+// ForDocCitURN returns the pinned URN for a citation
+func (c *Corrections) ForDocCitURN(docCitURN string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	urn, exists := c.docCitURNs[docCitURN]
+	return urn, exists
+}
+
+// Len returns the number of entries
+func (c *Corrections) Len() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.refs) + len(c.docCitURNs)
+}
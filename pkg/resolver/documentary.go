@@ -0,0 +1,132 @@
+package resolver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ii2 1234, i3 1, 30.82, 30, 82, xxx 82, iv 744, 1234, 5-7: the volume, in
+// Roman numerals with the edition after them as NormalizeRef leaves "II²",
+// or in Arabic ones, then the text's number or a range of them
+var documentaryNumberRegex = regexp.MustCompile(`^(?:([ivxlc]+)(\d?)\s+|(\d+)\s*[.,]\s*|(\d+)\s+)?(\d+[a-z]?(?:\s*[-–]\s*\d+[a-z]?)?)$`)
+
+// DocumentaryText is a citation of an inscription or papyrus by its volume
+// and number in a corpus, such as "IG II² 1234" or "P.Oxy. IV 744". Such
+// texts have no CTS URNs, so they get identifiers of their own: their kind,
+// the corpus, the volume and the number, as in
+// "urn:inscription:ig:2ed2:1234" or "urn:papyrus:p.oxy:4:744".
+type DocumentaryText struct {
+	Corpus  string `json:"corpus"`            // key in documentary_corpora.json, e.g. "ig" or "p.oxy"
+	Kind    string `json:"kind"`              // "inscription" or "papyrus"
+	Volume  string `json:"volume,omitempty"`  // in Arabic numerals, e.g. "2" for IG II²
+	Edition string `json:"edition,omitempty"` // of the volume, e.g. "2" for IG II²
+	Number  string `json:"number"`            // e.g. "1234", "82" or "5-7"
+}
+
+// URN returns the text's identifier
+func (d *DocumentaryText) URN() string {
+	return fmt.Sprintf("urn:%s:%s:%s", d.Kind, d.Corpus, d.key())
+}
+
+// key returns the volume and number of the text as its identifier gives
+// them, e.g. "2ed2:1234" for IG II² 1234 or "1234" for P.Oxy. 1234
+func (d *DocumentaryText) key() string {
+	switch {
+	case d.Edition != "":
+		return d.Volume + "ed" + d.Edition + ":" + d.Number
+	case d.Volume != "":
+		return d.Volume + ":" + d.Number
+	}
+	return d.Number
+}
+
+// resolveDocumentary resolves references to corpora of inscriptions and
+// papyri, whose abbreviation is followed by a volume, where the corpus needs
+// one, and a number, as "IG II² 1234", "SEG 30.82" or "P.Oxy. 1234", giving
+// the text's identifier as the URN and its external identifier, a PHI
+// number or papyri.info page, where the corpus gives one. recognized is
+// false if ref cites no known corpus.
+func (ur *URNResolver) resolveDocumentary(res *Resolution, ref string) (recognized bool) {
+	words := strings.Fields(ref)
+	// the corpus is the words before the volume and number, as "p. oxy."
+	for i := 1; i < len(words); i++ {
+		key, corpus, exists := ur.Data.DocumentaryCorpus(strings.Join(words[:i], " "))
+		if !exists {
+			continue
+		}
+		match := documentaryNumberRegex.FindStringSubmatch(strings.Join(words[i:], " "))
+		if match == nil {
+			continue
+		}
+		text := &DocumentaryText{Corpus: key, Kind: corpus.Kind, Number: rangeDashReplacer.Replace(strings.ReplaceAll(match[5], " ", ""))}
+		switch {
+		case match[1] != "":
+			volume := romanVolume(match[1])
+			if volume == 0 {
+				continue
+			}
+			text.Volume, text.Edition = strconv.Itoa(volume), match[2]
+		case match[3] != "":
+			text.Volume = match[3]
+		case match[4] != "":
+			text.Volume = match[4]
+		}
+		if text.Volume == "" && corpus.RequiresVolume {
+			ur.warn(res, ReasonUnresolvedPassage, ref, "no volume for %s: %s", corpus.Title, ref)
+			return true
+		}
+		res.Documentary = text
+		res.URN = text.URN()
+		res.ExternalID = corpus.IDs[text.key()]
+		if res.ExternalID == "" {
+			res.ExternalID = documentaryURL(corpus.URL, text)
+		}
+		return true
+	}
+	return false
+}
+
+// documentaryURL fills in the template of a corpus's external identifiers
+// for a text, or returns "" if the text lacks a value the template needs
+func documentaryURL(template string, text *DocumentaryText) string {
+	values := map[string]string{"{volume}": text.Volume, "{edition}": text.Edition, "{number}": text.Number}
+	for placeholder, value := range values {
+		if !strings.Contains(template, placeholder) {
+			continue
+		}
+		if value == "" {
+			return ""
+		}
+		template = strings.ReplaceAll(template, placeholder, value)
+	}
+	return template
+}
+
+// canonicalRomanRegex matches a Roman numeral written the standard way, up
+// to 399: "iv" but not "iiii", "iiv" or "vx"
+var canonicalRomanRegex = regexp.MustCompile(`^c{0,3}(?:xc|xl|l?x{0,3})(?:ix|iv|v?i{0,3})$`)
+
+// romanVolume returns the value of a Roman numeral, which for the volumes of
+// a corpus such as SEG runs past the book numbers romanToArabic reads, or 0
+// if text is not one written the standard way
+func romanVolume(text string) int {
+	if text == "" || !canonicalRomanRegex.MatchString(text) {
+		return 0
+	}
+	values := map[byte]int{'i': 1, 'v': 5, 'x': 10, 'l': 50, 'c': 100}
+	total := 0
+	for i := 0; i < len(text); i++ {
+		value, exists := values[text[i]]
+		if !exists {
+			return 0
+		}
+		if i+1 < len(text) && values[text[i+1]] > value {
+			total -= value
+		} else {
+			total += value
+		}
+	}
+	return total
+}
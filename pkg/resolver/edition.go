@@ -0,0 +1,61 @@
+package resolver
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// a locus followed by a parenthetical without numbers, as in "Soph. OT
+	// 100 (Lloyd-Jones)" or "Eur. Med. 5 (ed. Diggle)"
+	parenthesizedEditionRegex = regexp.MustCompile(`^(.*\d[^()]*?)\s*\(\s*([^()\d]*[^()\d\s.][^()\d]*?)\s*\)[\s.,;]*$`)
+	// a locus followed by capitalized names, as in "Pl. Rep. 327a Burnet",
+	// "Aesch. Ag. 100 ed. Page" or "Il. 1.1 Monro and Allen"
+	trailingEditionRegex = regexp.MustCompile(`^(.*\d[a-z]?\.?)\s+((?:edd?\.\s*)?\p{Lu}[\p{L}'’-]+\.?(?:(?:\s+|\s*,\s*|\s+(?:and|&)\s+)\p{Lu}[\p{L}'’-]+\.?)*(?:\s+edd?\.)?)[\s,;]*$`)
+	// "ed." or "edd." before or after the editors' names
+	editorMarkRegex = regexp.MustCompile(`^edd?\.\s*|\s+edd?\.$`)
+	// separates the names of several editors
+	editorSeparatorRegex = regexp.MustCompile(`\s*,\s*|\s+(?:and|&)\s+`)
+)
+
+// SplitEdition separates the modern editor or edition series a reference
+// names after its locus, as in "Pl. Rep. 327a Burnet", "Soph. OT 100
+// (Lloyd-Jones)" or "Thuc. 1.22 OCT", returning the edition and the locus.
+// Left in place, the name would run into the passage ("327aburnet"). Names
+// count as an edition when marked with "ed." or listed in the data files'
+// editions; anything else, such as the speaker in "Soph. OT 924
+// (Messenger)", comes back unchanged with no edition, as does the editor
+// of a fragment collection ("Archil. fr. 5 West"), which is part of the
+// reference.
+func (ur *URNResolver) SplitEdition(text string) (edition, locus string) {
+	text = strings.TrimSpace(text)
+	if match := editedFragmentRegex.FindStringSubmatch(strings.ToLower(text)); match != nil {
+		if _, _, exists := ur.Data.FragmentCollection(match[5]); exists {
+			return "", text
+		}
+	}
+	for _, re := range []*regexp.Regexp{parenthesizedEditionRegex, trailingEditionRegex} {
+		if match := re.FindStringSubmatch(text); match != nil && ur.isEdition(match[2]) {
+			return strings.TrimSpace(match[2]), strings.TrimSpace(match[1])
+		}
+	}
+	return "", text
+}
+
+// isEdition reports whether names, possibly several and marked with "ed.",
+// name an edition
+func (ur *URNResolver) isEdition(names string) bool {
+	names = strings.TrimSpace(names)
+	if editorMarkRegex.MatchString(names) {
+		return true
+	}
+	if ur.Data.IsEdition(strings.TrimSuffix(names, ".")) {
+		return true
+	}
+	for _, name := range editorSeparatorRegex.Split(names, -1) {
+		if !ur.Data.IsEdition(strings.TrimSuffix(name, ".")) {
+			return false
+		}
+	}
+	return true
+}
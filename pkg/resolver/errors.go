@@ -0,0 +1,95 @@
+package resolver
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FailureReason classifies why a reference could not be resolved
+type FailureReason string
+
+const (
+	ReasonEmptyRef          FailureReason = "empty-ref"          // no reference to resolve
+	ReasonNoAuthor          FailureReason = "no-author"          // no author could be parsed from the reference
+	ReasonUnknownAuthor     FailureReason = "unknown-author"     // the author abbreviation is not in the data
+	ReasonNoAuthorURN       FailureReason = "no-author-urn"      // the author has no URN in the data
+	ReasonAmbiguousAuthor   FailureReason = "ambiguous-author"   // several authors share the name cited, and nothing tells them apart
+	ReasonUnknownWork       FailureReason = "unknown-work"       // the work is not in the author's work table
+	ReasonUnknownLegacyID   FailureReason = "unknown-legacy-id"  // a Perseus text ID missing from the table
+	ReasonUnresolvedPassage FailureReason = "unresolved-passage" // the reference matched but gave no URN
+	ReasonMalformedURN      FailureReason = "malformed-urn"      // the URN built for the reference is not a valid CTS URN
+	ReasonSchemeViolation   FailureReason = "scheme-violation"   // the passage is beyond the last book or line of the work's citation scheme, deeper than it, or a reversed range
+	ReasonSecondary         FailureReason = "secondary"          // the bibl cites modern scholarship, as "Jebb ad loc.", with -secondary
+)
+
+// The categories a failed resolution falls into, matched by errors.Is on a
+// *ResolutionError. Several reasons may fall into one category; reasons in
+// none of them, such as ReasonAmbiguousAuthor, are told apart by Reason.
+var (
+	// ErrAuthorUnknown: the author is not in the data or has no URN
+	// (ReasonUnknownAuthor, ReasonNoAuthorURN)
+	ErrAuthorUnknown = errors.New("author unknown")
+	// ErrWorkUnknown: the author is known but the work is not
+	// (ReasonUnknownWork, ReasonUnknownLegacyID)
+	ErrWorkUnknown = errors.New("work unknown")
+	// ErrMalformedRef: there is no reference, or no author can be parsed
+	// from it (ReasonEmptyRef, ReasonNoAuthor)
+	ErrMalformedRef = errors.New("malformed reference")
+)
+
+// reasonCategories maps each reason to the category errors.Is matches it to
+var reasonCategories = map[FailureReason]error{
+	ReasonUnknownAuthor:   ErrAuthorUnknown,
+	ReasonNoAuthorURN:     ErrAuthorUnknown,
+	ReasonUnknownWork:     ErrWorkUnknown,
+	ReasonUnknownLegacyID: ErrWorkUnknown,
+	ReasonEmptyRef:        ErrMalformedRef,
+	ReasonNoAuthor:        ErrMalformedRef,
+}
+
+// ResolutionError is returned by Resolution.Err and ResolveURN for a
+// reference that did not resolve, so that callers can branch on Reason with
+// errors.As, or on its category with errors.Is
+type ResolutionError struct {
+	Ref      string // the reference, if known (ResolveURN sets it; Resolution.Err cannot)
+	Reason   FailureReason
+	Message  string   // the first warning recorded for the reference
+	Warnings []string // all of them
+}
+
+// ErrResolutionFailed is the former name of ResolutionError
+type ErrResolutionFailed = ResolutionError
+
+func (e *ResolutionError) Error() string {
+	failed := "resolution failed"
+	if e.Ref != "" {
+		failed = fmt.Sprintf("resolution of %q failed", e.Ref)
+	}
+	if e.Message == "" {
+		return fmt.Sprintf("%s: %s", failed, e.Reason)
+	}
+	return fmt.Sprintf("%s (%s): %s", failed, e.Reason, e.Message)
+}
+
+// Is matches the error to the category of its reason: ErrAuthorUnknown,
+// ErrWorkUnknown or ErrMalformedRef
+func (e *ResolutionError) Is(target error) bool {
+	category, exists := reasonCategories[e.Reason]
+	return exists && category == target
+}
+
+// Err returns nil if the reference resolved, and otherwise a
+// *ResolutionError giving the reason
+func (res Resolution) Err() error {
+	if res.URN != "" {
+		return nil
+	}
+	err := &ResolutionError{Reason: res.Reason, Warnings: res.Warnings}
+	if err.Reason == "" {
+		err.Reason = ReasonUnresolvedPassage
+	}
+	if len(res.Warnings) > 0 {
+		err.Message = res.Warnings[0]
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+package resolver
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// fgrh 115 f 30, dk 22 b 30 (cleanRef joins "115 f" into "115f"), with
+	// the author's name or a range of fragments allowed
+	numberedFragmentRegex = regexp.MustCompile(`^(.*?)\s*(\d+)\s*([ftabc])\.?\s*(\d+[a-z]?(?:\s*[-–]\s*\d+[a-z]?)?)$`)
+	// archil. fr. 5 west, soph. f 941 radt, sappho frr. 1-2 l.-p.
+	editedFragmentRegex = regexp.MustCompile(`^(?:(.*?)\s+)?(frr?|frag|fragm|f|t|test)\.?\s*(\d+(?:\s*[-–]\s*\d+)?)([a-z]?)\s*(\S.*)$`)
+)
+
+// FragmentURNPrefix begins the identifiers of fragments. No CTS edition
+// numbers fragments as the standard collections do, so they get identifiers
+// of their own: the collection, the author's number in it or text group, and
+// the fragment, as in "urn:fragment:fgrh:115:F30" or
+// "urn:fragment:ieg:tlg0232:F5".
+const FragmentURNPrefix = "urn:fragment:"
+
+// Fragment is a citation of a fragment or testimonium by its number in a
+// standard collection
+type Fragment struct {
+	Collection string `json:"collection"`     // key in fragment_collections.json, e.g. "fgrh"
+	Author     string `json:"author"`         // the author's number in a numbered collection, else text group, e.g. "tlg0232"
+	Name       string `json:"name,omitempty"` // the author's name in a numbered collection's list, else in the data tables
+	Kind       string `json:"kind"`           // "F" fragment, "T" testimonium, or "A", "B", "C" in Diels-Kranz
+	Number     string `json:"number"`         // e.g. "30", "12a" or "1-2"
+}
+
+// URN returns the fragment's identifier
+func (f *Fragment) URN() string {
+	return fmt.Sprintf("%s%s:%s:%s%s", FragmentURNPrefix, f.Collection, f.Author, f.Kind, f.Number)
+}
+
+// fragmentKind maps the notation of a fragment to its kind
+func fragmentKind(notation string) string {
+	switch notation {
+	case "t", "test":
+		return "T"
+	case "a", "b", "c":
+		return strings.ToUpper(notation)
+	}
+	return "F"
+}
+
+// fragmentNumber normalizes a fragment number or range
+func fragmentNumber(number string) string {
+	return rangeDashReplacer.Replace(strings.ReplaceAll(number, " ", ""))
+}
+
+// resolveFragment resolves references to fragment collections, either by
+// the author's number in a numbered collection ("FGrH 115 F 30") or after the
+// author with the collection's editor ("Archil. fr. 5 West"). recognized is
+// false if ref cites no known collection.
+func (ur *URNResolver) resolveFragment(res *Resolution, ref string) (recognized bool) {
+	if match := numberedFragmentRegex.FindStringSubmatch(ref); match != nil {
+		// the collection ends the text before the author number, after any
+		// author's name
+		words := strings.Fields(match[1])
+		for i := range words {
+			key, collection, exists := ur.Data.FragmentCollection(strings.Join(words[i:], " "))
+			if !exists || !collection.Numbered {
+				continue
+			}
+			// A, B and C are the sections of Diels-Kranz
+			kind := fragmentKind(match[3])
+			if kind != "F" && kind != "T" && key != "dk" {
+				return false
+			}
+			res.Fragment = &Fragment{
+				Collection: key,
+				Author:     match[2],
+				Name:       collection.Authors[match[2]],
+				Kind:       kind,
+				Number:     fragmentNumber(match[4]),
+			}
+			res.URN = res.Fragment.URN()
+			return true
+		}
+	}
+
+	match := editedFragmentRegex.FindStringSubmatch(ref)
+	if match == nil {
+		return false
+	}
+	// cleanRef joins a letter after the number to the editor ("5 w." gives
+	// "5w."), so the letter is tried as both
+	number, editor := match[3]+match[4], match[5]
+	key, collection, exists := ur.Data.FragmentCollection(editor)
+	if !exists && match[4] != "" {
+		number, editor = match[3], match[4]+match[5]
+		key, collection, exists = ur.Data.FragmentCollection(editor)
+	}
+	if !exists || collection.Numbered {
+		return false
+	}
+
+	author := strings.TrimSpace(match[1])
+	if author == "" {
+		ur.warn(res, ReasonNoAuthor, ref, "no author for fragment in %s: %s", collection.Title, ref)
+		return true
+	}
+	resolvedAuthor := ur.resolveAuthor(author, "")
+	if resolvedAuthor == "" {
+		ur.warn(res, ReasonUnknownAuthor, ref, "author not recognized: %s", author)
+		return true
+	}
+	authURN, exists := ur.Data.GetAllAuthURNs()[resolvedAuthor]
+	if !exists {
+		ur.warn(res, ReasonNoAuthorURN, ref, "no URN found for author: %s", resolvedAuthor)
+		return true
+	}
+	res.Fragment = &Fragment{
+		Collection: key,
+		Author:     authURN[strings.LastIndex(authURN, ":")+1:],
+		Name:       resolvedAuthor,
+		Kind:       fragmentKind(match[2]),
+		Number:     fragmentNumber(number),
+	}
+	res.URN = res.Fragment.URN()
+	return true
+}
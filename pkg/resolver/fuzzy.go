@@ -0,0 +1,130 @@
+package resolver
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// minFuzzyLength is the shortest author token matched fuzzily; shorter ones
+// are too close to too many abbreviations
+const minFuzzyLength = 4
+
+// AuthorMatch records an author found by fuzzy matching, for diagnostics
+type AuthorMatch struct {
+	Input    string  `json:"input"`         // the author as written
+	Matched  string  `json:"matched"`       // the author name or abbreviation it was taken for
+	Distance int     `json:"edit_distance"` // Levenshtein distance between the two
+	Score    float64 `json:"score"`         // Jaro-Winkler similarity between the two
+}
+
+// foldName lower-cases a name and strips its diacritics, so "Thukydídes"
+// compares equal to "thukydides"
+func foldName(name string) []rune {
+	var folded []rune
+	for _, r := range norm.NFD.String(strings.ToLower(name)) {
+		if !unicode.Is(unicode.Mn, r) {
+			folded = append(folded, r)
+		}
+	}
+	return folded
+}
+
+// levenshtein returns the number of rune insertions, deletions and
+// substitutions turning a into b
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// jaroWinkler returns the Jaro-Winkler similarity of a and b, from 0 for
+// nothing in common to 1 for equal, favouring a shared prefix as
+// abbreviations and their variants usually have one
+func jaroWinkler(a, b []rune) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		if len(a) == len(b) {
+			return 1
+		}
+		return 0
+	}
+	window := max(len(a), len(b))/2 - 1
+	window = max(window, 0)
+	matchedA := make([]bool, len(a))
+	matchedB := make([]bool, len(b))
+	matches := 0
+	for i := range a {
+		for j := max(0, i-window); j < min(len(b), i+window+1); j++ {
+			if !matchedB[j] && a[i] == b[j] {
+				matchedA[i], matchedB[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+	transpositions, j := 0, 0
+	for i := range a {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if a[i] != b[j] {
+			transpositions++
+		}
+		j++
+	}
+	m := float64(matches)
+	jaro := (m/float64(len(a)) + m/float64(len(b)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < min(4, len(a), len(b)) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+// fuzzyAuthor finds the author name or abbreviation most similar to an
+// unrecognized author, if any is at least ur.FuzzyAuthors similar. Ties go
+// to the smaller edit distance, then alphabetically.
+func (ur *URNResolver) fuzzyAuthor(author string) (AuthorMatch, bool) {
+	input := foldName(author)
+	if ur.FuzzyAuthors <= 0 || len(input) < minFuzzyLength {
+		return AuthorMatch{}, false
+	}
+
+	var best AuthorMatch
+	found := false
+	for _, name := range ur.Data.AuthorNames() {
+		candidate := foldName(name)
+		score := jaroWinkler(input, candidate)
+		if score < ur.FuzzyAuthors {
+			continue
+		}
+		distance := levenshtein(input, candidate)
+		if !found || score > best.Score || (score == best.Score && distance < best.Distance) {
+			best = AuthorMatch{Input: author, Matched: name, Distance: distance, Score: score}
+			found = true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,77 @@
+package resolver
+
+import (
+	"strings"
+
+	"perseus_citation_linker/pkg/loader"
+)
+
+// maxEpithetWords is the most words an epithet runs to, as in "of
+// halicarnassus"
+const maxEpithetWords = 3
+
+// resolveHomonym tells apart the authors sharing the name a reference cites
+// them by, as "Apollonius": by an epithet after the name, as in "Apollonius
+// Rhodius 1.100" or "Dion. Per. 10", or else by the work, if only one of
+// them wrote it, as in "Dionysius Ant. Rom. 1.2". None is taken for granted:
+// if neither tells them apart, ok is false and a warning names the
+// epithets. The author returned is a member's canonical name, with the work
+// and passage read from the rest of the reference.
+func (ur *URNResolver) resolveHomonym(res *Resolution, ref, name, key string, group loader.HomonymGroup) (author, work, passage string, ok bool) {
+	words := strings.Fields(strings.ToLower(strings.TrimSpace(ref)))
+	rest := words[min(len(strings.Fields(name)), len(words)):]
+
+	// the longest epithet wins, "of perga" over "of"
+	for n := min(maxEpithetWords, len(rest)); n > 0; n-- {
+		epithet := strings.Join(rest[:n], " ")
+		for i, member := range group.Members {
+			for _, e := range member.Epithets {
+				if strings.ToLower(e) != epithet {
+					continue
+				}
+				ur.recordHomonym(res, key, i)
+				work, passage = ur.parseWorkPassage(member.Author, rest[n:])
+				return member.Author, work, passage, true
+			}
+		}
+	}
+
+	var wrote []int
+	for i, member := range group.Members {
+		// a number is a book or a numbered work, which tells nothing
+		work, _ := ur.parseWorkPassage(member.Author, rest)
+		if work == "" || ur.isNumeric(work) {
+			continue
+		}
+		if workURN, guessed := ur.getWorkURN(member.Author, work); workURN != "" && !guessed {
+			wrote = append(wrote, i)
+		}
+	}
+	if len(wrote) == 1 {
+		member := group.Members[wrote[0]]
+		ur.recordHomonym(res, key, wrote[0])
+		work, passage = ur.parseWorkPassage(member.Author, rest)
+		return member.Author, work, passage, true
+	}
+
+	var epithets []string
+	for _, member := range group.Members {
+		if len(member.Epithets) > 0 {
+			epithets = append(epithets, name+" "+member.Epithets[0])
+		}
+	}
+	if len(wrote) > 1 {
+		ur.warn(res, ReasonAmbiguousAuthor, ref, "%s is shared by %d authors who all wrote the work cited; cite as %s", name, len(wrote), strings.Join(epithets, ", "))
+	} else {
+		ur.warn(res, ReasonAmbiguousAuthor, ref, "%s is shared by %d authors; cite as %s, or with a work only one of them wrote", name, len(group.Members), strings.Join(epithets, ", "))
+	}
+	return "", "", "", false
+}
+
+// recordHomonym adds the entry of the author of a homonym group the
+// reference was taken for
+func (ur *URNResolver) recordHomonym(res *Resolution, key string, i int) {
+	if ur.RecordProvenance {
+		res.Provenance = append(res.Provenance, loader.HomonymEntry(key, i))
+	}
+}
@@ -0,0 +1,116 @@
+package resolver
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"perseus_citation_linker/pkg/loader"
+)
+
+// quotedIncipitRegex matches an author, and perhaps a work, followed by
+// quoted opening words and perhaps a line, as in "hom. hymn 'eis aphroditen'"
+// or "hor. carm. 'integer vitae' 5". Single quotes run to the last one, so
+// that elided words ("poikilothron' athanat'") stay in the quotation.
+var quotedIncipitRegex = regexp.MustCompile(`^(.+?)\s+(?:"([^"]+)"|'(.+)')\s*(\d+(?:\.\d+)*[a-z]?)?[\s.,;]*$`)
+
+// incipitWords splits normalized text into lower-cased words without
+// punctuation, dropping marks left on their own (NFKC turns the Greek
+// koronis of elision into a space and a combining mark)
+func incipitWords(text string) []string {
+	var words []string
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		word = strings.TrimFunc(word, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if word != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// incipitMatches reports whether the cited words open the incipit or the
+// incipit opens the cited words, as citations quote as few or as many words
+// as they please
+func incipitMatches(cited, incipit []string) bool {
+	if len(cited) == 0 || len(incipit) == 0 {
+		return false
+	}
+	n := min(len(cited), len(incipit))
+	for i := 0; i < n; i++ {
+		if cited[i] != incipit[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveIncipit resolves references to a poem or hymn by its opening words,
+// as in "Hom. Hymn 'Εἰς Ἀφροδίτην'", from the data files' incipits. The
+// quotation is compared in the form GetRef gives it, Greek transliterated,
+// so incipits are written as the edition prints them. A line after the
+// quotation is a line of the poem. Of several poems opening alike the first
+// listed wins, the others becoming candidates. recognized is false if ref
+// quotes nothing, or its author has no incipits.
+func (ur *URNResolver) resolveIncipit(res *Resolution, ref string) (recognized bool) {
+	match := quotedIncipitRegex.FindStringSubmatch(strings.TrimSpace(ref))
+	if match == nil {
+		return false
+	}
+	author, work, _ := ur.parseReference(match[1])
+	resolvedAuthor := ur.resolveAuthor(author, work)
+	incipits := ur.Data.Incipits(resolvedAuthor)
+	if resolvedAuthor == "" || len(incipits) == 0 {
+		return false
+	}
+	authURN, exists := ur.Data.GetAllAuthURNs()[resolvedAuthor]
+	if !exists {
+		return false
+	}
+	cited := incipitWords(match[2] + match[3])
+
+	var urns []string
+	var matched []int
+	for i, poem := range incipits {
+		for _, incipit := range poem.Incipits {
+			if !incipitMatches(cited, incipitWords(ur.NormalizeRef(incipit))) {
+				continue
+			}
+			passage := poem.Passage
+			if line := match[4]; line != "" {
+				passage = strings.TrimPrefix(passage+"."+line, ".")
+			}
+			if urn, err := ur.editionURN(authURN, poem.Work, passage); err == nil {
+				urns = append(urns, urn)
+				matched = append(matched, i)
+			}
+			break
+		}
+	}
+	if len(urns) == 0 {
+		if work != "" {
+			// perhaps a quotation after an ordinary locus
+			return false
+		}
+		ur.warn(res, ReasonUnknownWork, ref, "no incipit of %s matches: %s", resolvedAuthor, match[2]+match[3])
+		return true
+	}
+
+	res.URN = urns[0]
+	ur.recordAuthor(res, author, resolvedAuthor)
+	if ur.RecordProvenance {
+		res.Provenance = append(res.Provenance, loader.IncipitEntry(resolvedAuthor, matched[0]))
+	}
+	ur.applyCitationScheme(res, ref, resolvedAuthor, "")
+	if len(urns) > 1 && res.URN != "" {
+		if len(urns) > maxCandidates {
+			urns = urns[:maxCandidates]
+		}
+		for _, urn := range urns {
+			res.Candidates = append(res.Candidates, Candidate{URN: urn, Score: math.Round(100/float64(len(urns))) / 100})
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package resolver
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"perseus_citation_linker/pkg/cts"
+)
+
+var (
+	// Perseus:abo:tlg,0012,001:1:1
+	aboIDRegex = regexp.MustCompile(`(?i)^perseus:abo:(tlg|phi|stoa),(\d+),(\d+)((?::[^:\s]+)*)$`)
+	// Perseus:text:1999.01.0133 or Perseus:text:1999.01.0133:book=1:line=1
+	perseusTextIDRegex = regexp.MustCompile(`(?i)^perseus:text:(\d{4}\.\d{2}\.\d{4})((?::[^:\s]+)*)$`)
+)
+
+// aboNamespaces maps the canon prefix of an ABO ID to its CTS namespace
+var aboNamespaces = map[string]string{
+	"tlg":  "greekLit",
+	"phi":  "latinLit",
+	"stoa": "latinLit",
+}
+
+// isLegacyID reports whether ref is an identifier in one of the older Perseus
+// schemes rather than a reference
+func isLegacyID(ref string) bool {
+	ref = strings.TrimSpace(ref)
+	return aboIDRegex.MatchString(ref) || perseusTextIDRegex.MatchString(ref)
+}
+
+// ConvertLegacyID converts an older Perseus identifier, as found in the n
+// attributes of legacy XML files, to a CTS URN. ABO IDs such as
+// "Perseus:abo:tlg,0012,001:1:1" carry the TLG or PHI numbers themselves;
+// text IDs such as "Perseus:text:1999.01.0133" are looked up in the data's
+// text ID table. recognized is false if id is in neither scheme, and urn is
+// "" if it is a text ID missing from the table.
+func (ur *URNResolver) ConvertLegacyID(id string) (urn string, recognized bool) {
+	urn, levels, recognized := ur.convertLegacyID(id)
+	if urn == "" {
+		return "", recognized
+	}
+	res := Resolution{URN: urn}
+	ur.applyLocus(&res, levels)
+	return res.URN, true
+}
+
+// convertLegacyID returns the edition-level URN for a legacy ID and the
+// ":"-separated citation levels that follow it, such as ":book=1:line=1"
+func (ur *URNResolver) convertLegacyID(id string) (urn, levels string, recognized bool) {
+	id = strings.TrimSpace(id)
+	var work cts.URN
+	if match := aboIDRegex.FindStringSubmatch(id); match != nil {
+		canon := strings.ToLower(match[1])
+		work = cts.URN{
+			Namespace: aboNamespaces[canon],
+			TextGroup: fmt.Sprintf("%s%04s", canon, match[2]),
+			Work:      fmt.Sprintf("%s%03s", canon, match[3]),
+		}
+		levels = match[4]
+	} else if match := perseusTextIDRegex.FindStringSubmatch(id); match != nil {
+		workURN := ur.Data.PerseusTextWork(match[1])
+		if workURN == "" {
+			return "", "", true
+		}
+		var err error
+		if work, err = cts.Parse(workURN); err != nil {
+			ur.logger().Warn("bad URN for Perseus text ID", "id", match[1], "error", err)
+			return "", "", true
+		}
+		levels = match[2]
+	} else {
+		return "", "", false
+	}
+	work.Version = ur.determineLiteratureSuffix(work.String())
+	if err := work.Validate(); err != nil {
+		return "", "", true
+	}
+	return work.String(), levels, true
+}
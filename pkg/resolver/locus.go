@@ -0,0 +1,109 @@
+package resolver
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"perseus_citation_linker/pkg/cts"
+)
+
+// namedLevelsRegex matches trailing key=value citation levels, as in the
+// ":book=1:line=10" of "urn:cts:greekLit:tlg0012.tlg001:book=1:line=10"
+var namedLevelsRegex = regexp.MustCompile(`(?i)(?::[a-z]+=[^:=\s]+)+$`)
+
+// LocusLevel is one level of a passage given as key=value, as in "book=1"
+type LocusLevel struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// parseLocus splits ":"-separated citation levels. Levels of the form
+// key=value are named; others are positional and have no name.
+func parseLocus(levels string) []LocusLevel {
+	var locus []LocusLevel
+	for _, level := range strings.Split(levels, ":") {
+		name, value, named := strings.Cut(level, "=")
+		if !named {
+			name, value = "", level
+		}
+		if value = strings.TrimSpace(value); value != "" {
+			locus = append(locus, LocusLevel{Name: strings.ToLower(strings.TrimSpace(name)), Value: value})
+		}
+	}
+	return locus
+}
+
+// orderLocus puts named levels in the order of a citation scheme such as
+// "book.line", so that "line=10:book=1" cites 1.10. The levels are left as
+// given unless every one is named in the scheme.
+func orderLocus(locus []LocusLevel, scheme string) {
+	if scheme == "" {
+		return
+	}
+	rank := make(map[string]int)
+	for i, name := range strings.Split(scheme, ".") {
+		rank[name] = i
+	}
+	for _, level := range locus {
+		if _, exists := rank[level.Name]; !exists {
+			return
+		}
+	}
+	sort.SliceStable(locus, func(i, j int) bool { return rank[locus[i].Name] < rank[locus[j].Name] })
+}
+
+// schemeForURN returns the citation scheme recorded for the work of a URN,
+// looking up which author the URN's text group belongs to
+func (ur *URNResolver) schemeForURN(urn cts.URN) string {
+	if urn.Work == "" {
+		return ""
+	}
+	authURN := cts.URN{Namespace: urn.Namespace, TextGroup: urn.TextGroup}.String()
+	authors := make([]string, 0, 1)
+	for author, candidate := range ur.Data.GetAllAuthURNs() {
+		if strings.EqualFold(candidate, authURN) {
+			authors = append(authors, author)
+		}
+	}
+	sort.Strings(authors)
+	for _, author := range authors {
+		if scheme := ur.Data.CitationScheme(author, urn.Work); scheme != "" {
+			return scheme
+		}
+	}
+	return ""
+}
+
+// applyLocus makes ":"-separated citation levels the passage of a
+// resolution's URN. When any level is named, the levels are ordered by the
+// work's citation scheme and kept on the resolution as its locus. Levels that
+// cannot form a passage of the URN are ignored.
+func (ur *URNResolver) applyLocus(res *Resolution, levels string) {
+	locus := parseLocus(levels)
+	if len(locus) == 0 {
+		return
+	}
+	urn, err := cts.Parse(res.URN)
+	if err != nil {
+		return
+	}
+	res.Scheme = ur.schemeForURN(urn)
+	named := false
+	for _, level := range locus {
+		named = named || level.Name != ""
+	}
+	if named {
+		orderLocus(locus, res.Scheme)
+		res.Locus = locus
+	}
+	values := make([]string, len(locus))
+	for i, level := range locus {
+		values[i] = level.Value
+	}
+	urn.Passage = cts.ParsePassage(strings.Join(values, "."))
+	if urn.Validate() != nil {
+		return
+	}
+	res.URN = urn.String()
+}
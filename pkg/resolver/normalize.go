@@ -0,0 +1,156 @@
+package resolver
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// typographyReplacer maps typographic quotes and invisible characters, which
+// NFKC leaves alone, to the ASCII the reference regexes expect
+var typographyReplacer = strings.NewReplacer(
+	"\u2018", "'", "\u2019", "'", "\u201a", "'", "\u201b", "'",
+	"\u201c", `"`, "\u201d", `"`, "\u201e", `"`, "\u201f", `"`,
+	"\u00ab", `"`, "\u00bb", `"`,
+	// soft hyphen, zero-width space, non-joiner, joiner and byte order mark
+	"\u00ad", "", "\u200b", "", "\u200c", "", "\u200d", "", "\ufeff", "",
+)
+
+// NormalizeText folds bibl text to the forms the reference regexes expect:
+// NFKC composition, which also turns non-breaking and thin spaces into
+// plain spaces, followed by ASCII quotes and no invisible characters
+func NormalizeText(text string) string {
+	return typographyReplacer.Replace(norm.NFKC.String(text))
+}
+
+// NormalizeQuote puts quoted text in NFC, so that precomposed and combining
+// diacritics compare equal, and turns non-breaking, thin and other non-ASCII
+// spaces into plain spaces. Unlike NormalizeText it keeps typographic quotes, which belong to
+// the quotation.
+func NormalizeQuote(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII && unicode.IsSpace(r) {
+			return ' '
+		}
+		return r
+	}, norm.NFC.String(text))
+}
+
+// greekLetters transliterates lower-case Greek letters with their diacritics
+// removed. Upsilon is "y" except as the second vowel of a diphthong.
+var greekLetters = map[rune]string{
+	'α': "a", 'β': "b", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "e",
+	'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x",
+	'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y",
+	'φ': "ph", 'χ': "ch", 'ψ': "ps", 'ω': "o", 'ϝ': "w",
+}
+
+// roughBreathing is the combining mark of an initial aspirate, as in Ὅμηρος
+const roughBreathing = '\u0314'
+
+// isGreek reports whether word contains Greek letters
+func isGreek(word string) bool {
+	for _, r := range word {
+		if unicode.Is(unicode.Greek, r) && unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// transliterateGreek writes a Greek word in Latin letters, dropping accents
+// and marking rough breathing with "h", so "Ὅμηρος" becomes "homeros" and
+// "Αἰσχύλος" becomes "aischylos". Other characters pass through.
+func transliterateGreek(word string) string {
+	var b strings.Builder
+	aspirated := false
+	prev := rune(0)
+	for _, r := range norm.NFD.String(strings.ToLower(word)) {
+		if r == roughBreathing {
+			aspirated = aspirated || b.Len() <= 2
+			continue
+		}
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		latin, isLetter := greekLetters[r]
+		switch {
+		case !isLetter:
+			latin = string(r)
+		case r == 'υ' && strings.ContainsRune("αεηο", prev):
+			latin = "u"
+		}
+		b.WriteString(latin)
+		prev = r
+	}
+	out := b.String()
+	// gamma before a velar is nasal, as in Ἄγγελος
+	out = strings.NewReplacer("gg", "ng", "gk", "nk", "gx", "nx", "gch", "nch").Replace(out)
+	if aspirated {
+		if strings.HasPrefix(out, "r") {
+			return "rh" + out[1:]
+		}
+		return "h" + out
+	}
+	return out
+}
+
+// latinReplacer gives a transliteration the spelling of the Latin name, as
+// in "sophokles" to "sophocles" and "aischylos" to "aeschylos"
+var latinReplacer = strings.NewReplacer("ai", "ae", "oi", "oe", "ou", "u", "k", "c")
+
+// greekNameExceptions are names whose Latin form the ending rules in
+// latinNames do not reach
+var greekNameExceptions = map[string]string{
+	"aristoteles": "aristotle",
+}
+
+// latinNames returns the Latin spellings a transliterated Greek name may
+// take in the author tables, most literal first: "homeros" gives "homerus"
+// and "homer", "platon" gives "plato", "menandros" gives "menander"
+func latinNames(transliterated string) []string {
+	if name, exists := greekNameExceptions[transliterated]; exists {
+		return []string{name}
+	}
+	base := latinReplacer.Replace(transliterated)
+	names := []string{base}
+	switch {
+	case strings.HasSuffix(base, "ros"):
+		names = append(names, strings.TrimSuffix(base, "os")+"us", strings.TrimSuffix(base, "os"), strings.TrimSuffix(base, "ros")+"er")
+	case strings.HasSuffix(base, "os"):
+		names = append(names, strings.TrimSuffix(base, "os")+"us", strings.TrimSuffix(base, "os"))
+	case strings.HasSuffix(base, "on"):
+		names = append(names, strings.TrimSuffix(base, "n"))
+	}
+	return names
+}
+
+// NormalizeRef applies NormalizeText to bibl text and writes Greek words in
+// Latin letters. Words naming an author in the tables become that author's
+// name, so "Αἰσχύλος Ag. 1" reads as "aeschylus Ag. 1"; other Greek words,
+// such as titles, are transliterated.
+func (ur *URNResolver) NormalizeRef(text string) string {
+	text = NormalizeText(text)
+	if !isGreek(text) {
+		return text
+	}
+	words := strings.Fields(text)
+	for i, word := range words {
+		if !isGreek(word) {
+			continue
+		}
+		core := strings.TrimFunc(word, unicode.IsPunct)
+		start := strings.Index(word, core)
+		prefix, suffix := word[:start], word[start+len(core):]
+		transliterated := transliterateGreek(core)
+		words[i] = prefix + latinReplacer.Replace(transliterated) + suffix
+		for _, name := range latinNames(transliterated) {
+			if author := ur.resolveAuthor(name, ""); author != "" {
+				words[i] = prefix + name + suffix
+				break
+			}
+		}
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,153 @@
+package resolver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"perseus_citation_linker/pkg/loader"
+)
+
+var (
+	// a Stephanus page and section, perhaps after a book and with a line,
+	// as in "327a", "327a.5" or "9.573b"
+	stephanusRegex = regexp.MustCompile(`^(?:(\d+|[ivxlc]+)\.)?(\d{1,3})([a-e])(?:\.?(\d+))?$`)
+	// the end of a Stephanus range on the same page, as in the "c" of "327a-c"
+	stephanusEndRegex = regexp.MustCompile(`^()([a-e])(?:\.?(\d+))?$`)
+	// a Bekker page and column, perhaps after a book and with a line, as in
+	// "1094b10", "1094b.10" or "7.1331a"
+	bekkerRegex = regexp.MustCompile(`^(?:(\d+|[ivxlc]+)\.)?(\d{1,4})([ab])\.?(\d+)?$`)
+	// the end of a Bekker range in the same column, as in the "15" of
+	// "1094b10-15"
+	bekkerEndRegex = regexp.MustCompile(`^()()(\d+)$`)
+	// a Casaubon page, perhaps after the book and chapter, as in "c213" or
+	// "3.5.c213"
+	casaubonRegex = regexp.MustCompile(`^(?:(\d+)\.(?:(\d+)\.)?)?c\.?(\d+)$`)
+)
+
+// paginate converts a passage cited by the pages of an early printed
+// edition to the passage of the Perseus edition, by the work's pagination
+// in the data files: "327a5" of Plato to "327a", "1094b10" of Aristotle to
+// "1094b.10", and "C213" of Strabo to the book the page falls in. A book
+// cited before a Stephanus or Bekker page is kept, as in "9.573b", in
+// Arabic numerals. ok is false if the work has no pagination or the passage
+// does not cite pages.
+func (ur *URNResolver) paginate(res *Resolution, author, workURN, passage string) (locus string, ok bool) {
+	pagination, exists := ur.Data.Pagination(author, workURN)
+	if !exists {
+		return passage, false
+	}
+	start, end, isRange := strings.Cut(rangeDashReplacer.Replace(passage), "-")
+	switch pagination.System {
+	case loader.Stephanus, loader.Bekker:
+		pageRegex, endRegex := stephanusRegex, stephanusEndRegex
+		if pagination.System == loader.Bekker {
+			pageRegex, endRegex = bekkerRegex, bekkerEndRegex
+		}
+		match := pageRegex.FindStringSubmatch(start)
+		if match == nil {
+			return passage, false
+		}
+		start = ur.pagePassage(res, pagination, match[1], match[2], match[3], match[4])
+		if m := pageRegex.FindStringSubmatch(end); m != nil {
+			end = ur.pagePassage(res, pagination, m[1], m[2], m[3], m[4])
+		} else if m := endRegex.FindStringSubmatch(end); m != nil {
+			// the end's page, and column or section, are the start's
+			if m[2] == "" {
+				m[2] = match[3]
+			}
+			end = ur.pagePassage(res, pagination, match[1], match[2], m[2], m[3])
+		}
+	case loader.Casaubon:
+		match := casaubonRegex.FindStringSubmatch(start)
+		if match == nil {
+			return passage, false
+		}
+		page, _ := strconv.Atoi(match[3])
+		start = ur.pageLevels(res, pagination, page, strings.Trim(match[1]+"."+match[2], "."))
+		if m := casaubonRegex.FindStringSubmatch(end); m != nil {
+			page, _ := strconv.Atoi(m[3])
+			end = ur.pageLevels(res, pagination, page, strings.Trim(m[1]+"."+m[2], "."))
+		}
+		if start == "" || (isRange && end == "") {
+			return "", true
+		}
+	default:
+		return passage, false
+	}
+	if isRange && end != start {
+		return start + "-" + end, true
+	}
+	return start, true
+}
+
+// pagePassage returns the passage of a Stephanus or Bekker page, its
+// section or column, and line, after the book cited or, with the
+// pagination's Pages, the book the page falls in. The line is kept only if
+// the edition numbers lines.
+func (ur *URNResolver) pagePassage(res *Resolution, pagination loader.Pagination, book, page, section, line string) string {
+	if n := romanToArabic(book); n > 0 {
+		book = strconv.Itoa(n)
+	}
+	if len(pagination.Pages) > 0 {
+		n, _ := strconv.Atoi(page)
+		book = ur.pageLevels(res, pagination, n, book)
+	}
+	passage := strings.TrimPrefix(book+"."+page+section, ".")
+	if pagination.Lines && line != "" {
+		passage += "." + line
+	}
+	return passage
+}
+
+// pageLevels returns the passage a page falls in: the deepest passage of
+// the pagination's Pages spanning the page, or what the passages sharing
+// the page at that depth have in common. Levels cited with the page, such
+// as Strabo's book and chapter, are kept when the page does not fall in
+// them.
+func (ur *URNResolver) pageLevels(res *Resolution, pagination loader.Pagination, page int, cited string) string {
+	var spanning []string
+	depth := 0
+	for passage, pages := range pagination.Pages {
+		if page < pages[0] || page > pages[1] {
+			continue
+		}
+		switch d := strings.Count(passage, ".") + 1; {
+		case d > depth:
+			spanning, depth = []string{passage}, d
+		case d == depth:
+			spanning = append(spanning, passage)
+		}
+	}
+	found := commonLevels(spanning)
+
+	switch {
+	case found == "":
+		return cited
+	case cited == "" || strings.HasPrefix(found+".", cited+"."):
+		return found
+	case strings.HasPrefix(cited+".", found+"."):
+		return cited
+	}
+	res.Warnings = append(res.Warnings, fmt.Sprintf("page %d falls in %s, not %s, by %s pagination", page, found, cited, pagination.System))
+	return cited
+}
+
+// commonLevels returns the leading levels all the passages share, "" if
+// there are none
+func commonLevels(passages []string) string {
+	if len(passages) == 0 {
+		return ""
+	}
+	common := strings.Split(passages[0], ".")
+	for _, passage := range passages[1:] {
+		levels := strings.Split(passage, ".")
+		n := 0
+		for n < len(common) && n < len(levels) && common[n] == levels[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	return strings.Join(common, ".")
+}
@@ -0,0 +1,96 @@
+package resolver
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"perseus_citation_linker/pkg/cts"
+)
+
+// PassageFormat is the policy for writing the numbers of passages in URNs,
+// which downstream systems expect as "1.1" or as "001.001"
+type PassageFormat string
+
+const (
+	PassagePreserve PassageFormat = "preserve" // as cited, leading zeros and all
+	PassagePlain    PassageFormat = "plain"    // without leading zeros, as in "1.1"
+	PassagePadded   PassageFormat = "padded"   // zero-padded to PassageWidth digits, as in "001.001"
+)
+
+// DefaultPassageWidth is the digits each level is padded to with
+// PassagePadded when PassageWidth is 0
+const DefaultPassageWidth = 3
+
+// ParsePassageFormat checks a passage format name
+func ParsePassageFormat(name string) (PassageFormat, error) {
+	switch format := PassageFormat(name); format {
+	case PassagePreserve, PassagePlain, PassagePadded:
+		return format, nil
+	case "":
+		return PassagePreserve, nil
+	}
+	return "", fmt.Errorf("unknown passage format %q (want preserve, plain or padded)", name)
+}
+
+// passageLevelRegex matches a level of a passage that is a number, perhaps
+// followed by letters as in Stephanus "338d" or Bekker "1094b"
+var passageLevelRegex = regexp.MustCompile(`^(\d+)([a-z]*)$`)
+
+// formatPassage writes the numbers of each level of a passage, one end of a
+// range, by the resolver's PassageFormat. Levels that are not numbers, such
+// as "pr" or "fr", are left as they are.
+func (ur *URNResolver) formatPassage(passage string) string {
+	if ur.PassageFormat == "" || ur.PassageFormat == PassagePreserve || passage == "" {
+		return passage
+	}
+	width := ur.PassageWidth
+	if width <= 0 {
+		width = DefaultPassageWidth
+	}
+	levels := strings.Split(passage, ".")
+	for i, level := range levels {
+		match := passageLevelRegex.FindStringSubmatch(level)
+		if match == nil {
+			continue
+		}
+		number := strings.TrimLeft(match[1], "0")
+		if number == "" {
+			number = "0"
+		}
+		if ur.PassageFormat == PassagePadded && len(number) < width {
+			number = strings.Repeat("0", width-len(number)) + number
+		}
+		levels[i] = number + match[2]
+	}
+	return strings.Join(levels, ".")
+}
+
+// formatURNPassage writes the passage of a CTS URN by the resolver's
+// PassageFormat, leaving other URNs as they are
+func (ur *URNResolver) formatURNPassage(urn string) string {
+	parsed, err := cts.Parse(urn)
+	if err != nil || parsed.Passage.IsZero() {
+		return urn
+	}
+	parsed.Passage.Start = ur.formatPassage(parsed.Passage.Start)
+	parsed.Passage.End = ur.formatPassage(parsed.Passage.End)
+	return parsed.String()
+}
+
+// applyPassageFormat writes the passages of a resolution's URN, candidates
+// and provisional URN by the resolver's PassageFormat
+func (ur *URNResolver) applyPassageFormat(res *Resolution) {
+	if ur.PassageFormat == "" || ur.PassageFormat == PassagePreserve {
+		return
+	}
+	if res.URN != "" {
+		res.URN = ur.formatURNPassage(res.URN)
+	}
+	for i := range res.Candidates {
+		res.Candidates[i].URN = ur.formatURNPassage(res.Candidates[i].URN)
+	}
+	if res.Provisional != "" {
+		res.Provisional = ur.formatURNPassage(res.Provisional)
+	}
+}
@@ -0,0 +1,42 @@
+package resolver
+
+import "perseus_citation_linker/pkg/loader"
+
+// WorkPrior weighs the works a reference may cite by how often the citing
+// document, or its author's other commentaries, cite them elsewhere. It
+// breaks ties between works sharing an abbreviation, as in "Plat. Hipp.",
+// which the data files alone cannot.
+type WorkPrior interface {
+	// Weight returns the weight of a work-level URN without edition, such
+	// as "urn:cts:greekLit:tlg0059.tlg025", for a reference in filename
+	Weight(filename, workURN string) float64
+}
+
+// preferredWork picks among the best-weighted works a reference may cite the
+// one ur.Prior weighs highest for the citing document. It reports whether the
+// prior decided anything: there must be a tie, the prior must tell the tied
+// works apart, and the work already chosen must be one of them.
+func (ur *URNResolver) preferredWork(works []loader.WorkCandidate, chosen, authURN, filename string) (string, bool) {
+	if ur.Prior == nil || len(works) < 2 || works[0].Weight != works[1].Weight {
+		return "", false
+	}
+	best, bestWeight := "", 0.0
+	distinct, tied := false, false
+	for i, work := range works {
+		if work.Weight != works[0].Weight {
+			break
+		}
+		tied = tied || work.WorkURN == chosen
+		weight := ur.Prior.Weight(filename, authURN+"."+work.WorkURN)
+		if i > 0 && weight != bestWeight {
+			distinct = true
+		}
+		if i == 0 || weight > bestWeight {
+			best, bestWeight = work.WorkURN, weight
+		}
+	}
+	if !distinct || !tied {
+		return "", false
+	}
+	return best, true
+}
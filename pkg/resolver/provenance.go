@@ -0,0 +1,42 @@
+package resolver
+
+import "strings"
+
+// recordAuthor adds the entries mapping an author as cited to the author
+// and the author's URN, with RecordProvenance
+func (ur *URNResolver) recordAuthor(res *Resolution, cited, author string) {
+	if ur.RecordProvenance {
+		res.Provenance = append(res.Provenance, ur.Data.AuthorEntries(strings.ToLower(cited), author)...)
+	}
+}
+
+// recordSingleWork adds the entry making the author a single-work author
+func (ur *URNResolver) recordSingleWork(res *Resolution, author string) {
+	if !ur.RecordProvenance {
+		return
+	}
+	if entry, exists := ur.Data.SingleWorkEntry(author); exists {
+		res.Provenance = append(res.Provenance, entry)
+	}
+}
+
+// recordWork adds the entry mapping a work as cited to its work URN
+// component, or for a range of numbered works, with workURN "", the range
+func (ur *URNResolver) recordWork(res *Resolution, author, key, workURN string) {
+	if !ur.RecordProvenance {
+		return
+	}
+	if entry, exists := ur.Data.WorkEntry(author, key, workURN); exists {
+		res.Provenance = append(res.Provenance, entry)
+	}
+}
+
+// recordTextID adds the entry of a legacy Perseus text ID
+func (ur *URNResolver) recordTextID(res *Resolution, textID string) {
+	if !ur.RecordProvenance {
+		return
+	}
+	if entry, exists := ur.Data.PerseusTextEntry(textID); exists {
+		res.Provenance = append(res.Provenance, entry)
+	}
+}
@@ -0,0 +1,158 @@
+package resolver
+
+import (
+	"strings"
+	"unicode"
+)
+
+// QuoteNormalizer normalizes and tokenizes the quotations of one language.
+// Normalize gives a quotation the form it is output in; Tokenize splits a
+// normalized quotation into the words it is compared by, so that quotations
+// differing only in punctuation, case or spelling conventions match.
+type QuoteNormalizer interface {
+	Normalize(quote string) string
+	Tokenize(quote string) []string
+}
+
+// elisionMarks end Greek words whose final vowel is elided, as in "ἄρχομ᾽"
+const elisionMarks = "'’᾽ʼ"
+
+// GreekQuotes normalizes Greek quotations. Tokens are lower-cased, keep
+// their accents and breathings, and keep an elision mark, written "’".
+type GreekQuotes struct{}
+
+func (GreekQuotes) Normalize(quote string) string {
+	return NormalizeQuote(quote)
+}
+
+func (GreekQuotes) Tokenize(quote string) []string {
+	var tokens []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(quote), isWordBreak(elisionMarks)) {
+		word = strings.TrimLeft(word, elisionMarks)
+		if core := strings.TrimRight(word, elisionMarks); core != word {
+			word = core + "’"
+		}
+		if word != "" && word != "’" {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
+
+// latinSpelling folds the spellings Latin editions differ on, consonantal
+// "v" and "j"
+var latinSpelling = strings.NewReplacer("v", "u", "j", "i")
+
+// LatinQuotes normalizes Latin quotations. Tokens are lower-cased, with "v"
+// written "u" and "j" written "i".
+type LatinQuotes struct{}
+
+func (LatinQuotes) Normalize(quote string) string {
+	return NormalizeQuote(quote)
+}
+
+func (LatinQuotes) Tokenize(quote string) []string {
+	return strings.FieldsFunc(latinSpelling.Replace(strings.ToLower(quote)), isWordBreak(""))
+}
+
+// plainQuotes normalizes quotations in a language and script with no
+// normalizer registered
+type plainQuotes struct{}
+
+func (plainQuotes) Normalize(quote string) string {
+	return NormalizeQuote(quote)
+}
+
+func (plainQuotes) Tokenize(quote string) []string {
+	return strings.FieldsFunc(strings.ToLower(quote), isWordBreak(""))
+}
+
+// isWordBreak returns the FieldsFunc separator of words: anything but
+// letters, combining marks, digits and the characters of keep
+func isWordBreak(keep string) func(rune) bool {
+	return func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsMark(r) && !unicode.IsDigit(r) && !strings.ContainsRune(keep, r)
+	}
+}
+
+// QuoteNormalizers chooses the normalizer of a quotation by its language,
+// as given by xml:lang, or else by its script
+type QuoteNormalizers struct {
+	byLang  map[string]QuoteNormalizer
+	scripts []quoteScript
+}
+
+type quoteScript struct {
+	script     *unicode.RangeTable
+	normalizer QuoteNormalizer
+}
+
+// NewQuoteNormalizers returns the built-in normalizers: GreekQuotes for
+// "grc" and the Greek script, and LatinQuotes for "lat" and the Latin script
+func NewQuoteNormalizers() *QuoteNormalizers {
+	q := &QuoteNormalizers{}
+	q.Register("grc", unicode.Greek, GreekQuotes{})
+	q.Register("lat", unicode.Latin, LatinQuotes{})
+	return q
+}
+
+// Register sets the normalizer of a language, by its xml:lang code such as
+// "san" or "ara", replacing any before it. With a script, such as
+// unicode.Devanagari, the normalizer also takes quotations in that script
+// whose language is not given; a script registered again goes to the later
+// normalizer.
+func (q *QuoteNormalizers) Register(lang string, script *unicode.RangeTable, n QuoteNormalizer) {
+	if q.byLang == nil {
+		q.byLang = make(map[string]QuoteNormalizer)
+	}
+	q.byLang[strings.ToLower(lang)] = n
+	if script == nil {
+		return
+	}
+	for i := range q.scripts {
+		if q.scripts[i].script == script {
+			q.scripts[i].normalizer = n
+			return
+		}
+	}
+	q.scripts = append(q.scripts, quoteScript{script: script, normalizer: n})
+}
+
+// For returns the normalizer of a quotation in lang, an xml:lang code that
+// may carry a region or script ("grc-Latn" is taken as "grc"), or "" if not
+// known. A language with no normalizer falls back to that of the script
+// most of the quotation's letters are in, and a quotation in no registered
+// script to a plain normalizer that only lower-cases its tokens.
+func (q *QuoteNormalizers) For(lang, quote string) QuoteNormalizer {
+	lang = strings.ToLower(lang)
+	if n, exists := q.byLang[lang]; exists {
+		return n
+	}
+	if base, _, found := strings.Cut(lang, "-"); found {
+		if n, exists := q.byLang[base]; exists {
+			return n
+		}
+	}
+	counts := make([]int, len(q.scripts))
+	for _, r := range quote {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		for i, s := range q.scripts {
+			if unicode.Is(s.script, r) {
+				counts[i]++
+				break
+			}
+		}
+	}
+	best := -1
+	for i, count := range counts {
+		if count > 0 && (best < 0 || count > counts[best]) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return plainQuotes{}
+	}
+	return q.scripts[best].normalizer
+}
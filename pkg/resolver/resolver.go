@@ -2,16 +2,160 @@ package resolver
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
+	"perseus_citation_linker/pkg/cts"
 	"perseus_citation_linker/pkg/loader"
 )
 
+// URNResolver resolves references to CTS URNs. Once its fields are set it
+// is safe for concurrent use by multiple goroutines: Resolve and the other
+// lookups only read the resolver and its data. The fields, the Corrections
+// and the data must not be changed while it is in use, and a Prior, Chain
+// steps or Logger given to it must themselves be safe for concurrent use.
 type URNResolver struct {
-	Data *loader.ComprehensiveData
+	Data         *loader.ComprehensiveData
+	Logger       *slog.Logger // defaults to slog.Default() when nil
+	Corrections  *Corrections // pinned resolutions consulted before any heuristics
+	WorkFallback WorkFallback // what to do when the work cannot be found; "" means FallbackGuess
+	// FuzzyAuthors is the Jaro-Winkler similarity, between 0 and 1, an
+	// unrecognized author must reach to be taken for a known author or
+	// abbreviation; 0 disables fuzzy matching
+	FuzzyAuthors float64
+	// Prior breaks ties between works sharing an abbreviation; nil for none
+	Prior WorkPrior
+	// RecordProvenance sets each resolution's Provenance to the data file
+	// entries it used
+	RecordProvenance bool
+	// Chain holds custom resolution steps run before and after the built-in
+	// heuristics; nil for none
+	Chain *ResolverChain
+	// PassageFormat is how the numbers of the passages the built-in
+	// heuristics resolve are written; "" means PassagePreserve. With
+	// PassagePadded each level is padded to PassageWidth digits, or
+	// DefaultPassageWidth if 0.
+	PassageFormat PassageFormat
+	PassageWidth  int
+}
+
+// WorkFallback is the policy for references to a known author whose work
+// cannot be found
+type WorkFallback string
+
+const (
+	FallbackGuess  WorkFallback = "guess"  // assume the author's first work (tlg001, phi001 or eng001)
+	FallbackAuthor WorkFallback = "author" // resolve to the author-level URN, without work or passage
+	FallbackNone   WorkFallback = "none"   // leave the reference unresolved
+)
+
+// ParseWorkFallback checks a fallback policy name
+func ParseWorkFallback(name string) (WorkFallback, error) {
+	switch policy := WorkFallback(name); policy {
+	case FallbackGuess, FallbackAuthor, FallbackNone:
+		return policy, nil
+	case "":
+		return FallbackGuess, nil
+	}
+	return "", fmt.Errorf("unknown work fallback %q (want guess, author or none)", name)
+}
+
+// Resolution is the outcome of resolving a single reference. Warnings explain
+// why a reference could not be resolved, so they can be kept with the citation
+// rather than written to stderr for every miss.
+type Resolution struct {
+	URN        string
+	Warnings   []string
+	Scheme     string        // citation scheme of the work, e.g. "book.line", if known
+	Candidates []Candidate   // ranked alternatives when the reference is ambiguous
+	Corrected  bool          // the URN comes from the corrections table
+	Reason     FailureReason // why resolution failed, if it did
+	Works      []string      // work-level URNs of every work a cross-work range spans, the first being URN
+	Qualifier  string        // "init" or "fin" when the reference cites the beginning or end of the passage
+	Fallback   bool          // the work was not found and URN comes from the WorkFallback policy
+	Locus      []LocusLevel  // the passage's levels when given as key=value, in citation order
+	// Provisional is a placeholder URN for a reference whose author is known
+	// but whose work is not, with the author's text group, the work
+	// ProvisionalWork and the passage
+	Provisional string
+	// FuzzyAuthor is set when the author was not recognized as written and
+	// was taken for the most similar known author (see FuzzyAuthors)
+	FuzzyAuthor *AuthorMatch
+	// Prior is set when the work was chosen by the resolver's Prior among
+	// works the reference fits equally well
+	Prior bool
+	// Fragment is set for references to a fragment collection, whose URN is
+	// the fragment's identifier (see FragmentURNPrefix)
+	Fragment *Fragment
+	// Documentary is set for references to a corpus of inscriptions or
+	// papyri, whose URN is the text's identifier, with ExternalID its
+	// identifier outside this tool, as a papyri.info page, if the corpus
+	// gives one
+	Documentary *DocumentaryText
+	ExternalID  string
+	// Provenance lists the data file entries that mapped the author and work,
+	// with RecordProvenance
+	Provenance []loader.DataEntry
+	// Step names the ResolutionStep of the resolver's Chain that gave the
+	// URN, with its Confidence; "" for the built-in heuristics
+	Step       string
+	Confidence float64
+	// SchemeViolation is the URN the reference would have resolved to but
+	// for a passage the work's citation scheme cannot hold, such as book 30
+	// of the Iliad or a range ending before it starts, when URN is left
+	// empty
+	SchemeViolation string
+}
+
+// ProvisionalWork stands for the work in provisional URNs, as in
+// "urn:cts:greekLit:tlg0012.UNKNOWN:1.1"
+const ProvisionalWork = "UNKNOWN"
+
+// provisionalURN mints the provisional URN of a passage of an unknown work,
+// or returns "" if the passage cannot be part of a URN
+func provisionalURN(authURN, passage string) string {
+	urn, err := cts.Parse(authURN)
+	if err != nil {
+		return ""
+	}
+	urn.Work = ProvisionalWork
+	urn.Passage = cts.ParsePassage(rangeDashReplacer.Replace(passage))
+	if urn.Validate() != nil {
+		return ""
+	}
+	return urn.String()
+}
+
+// Candidate is one possible URN for an ambiguous reference. Scores of the
+// candidates for a reference sum to roughly 1.
+type Candidate struct {
+	URN   string  `json:"urn"`
+	Score float64 `json:"score"`
+}
+
+// maxCandidates limits the alternatives kept for a single reference
+const maxCandidates = 10
+
+func (ur *URNResolver) logger() *slog.Logger {
+	if ur.Logger != nil {
+		return ur.Logger
+	}
+	return slog.Default()
+}
+
+// warn records a warning and the failure reason on the resolution and logs it
+// at debug level
+func (ur *URNResolver) warn(res *Resolution, reason FailureReason, ref, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	res.Warnings = append(res.Warnings, msg)
+	if res.Reason == "" {
+		res.Reason = reason
+	}
+	ur.logger().Debug(msg, "ref", ref)
 }
 
 func NewURNResolver() (*URNResolver, error) {
@@ -26,7 +170,7 @@ func NewURNResolver() (*URNResolver, error) {
 }
 
 // use this function to load data from specified path, rather than default
-// from loader.findDataDir
+// from loader.FindDataDir
 func NewURNResolverFromDir(dataDir string) (*URNResolver, error) {
 	data, err := loader.LoadComprehensiveDataDir(dataDir)
 	if err != nil {
@@ -38,8 +182,29 @@ func NewURNResolverFromDir(dataDir string) (*URNResolver, error) {
 	return resolver, nil
 }
 
+// refPatterns are the shapes of a reference GetRef looks for, best to worst
+var refPatterns = []*regexp.Regexp{
+	// Best: author work number.number
+	regexp.MustCompile(`([a-zA-Z]+\.?\s?[a-zA-Z]*) ([a-zA-Z]+\.?\s?[a-zA-Z]*) \d+(\s|\.|:)\d+`),
+	// Second best: author work number
+	regexp.MustCompile(`([a-zA-Z]+\.?\s?[a-zA-Z]*) ([a-zA-Z]+\.?\s?[a-zA-Z]*) \d+`),
+	// Third best: author number.number
+	regexp.MustCompile(`([a-zA-Z]+\.?) \d+(\s|\.|:)\d+`),
+	// Fourth best: author number
+	regexp.MustCompile(`([a-zA-Z]+\.?) \d+`),
+}
+
 func (ur *URNResolver) GetRef(nAttr, biblContent string) string {
-	// This implements the Python get_ref logic exactly
+	// CITE2 object IDs are case-sensitive, so a CITE2 URN is kept as written
+	for _, text := range []string{nAttr, biblContent} {
+		if urn := cite2Regex.FindString(text); urn != "" {
+			return urn
+		}
+	}
+
+	// This implements the Python get_ref logic exactly, on text with Greek
+	// names transliterated and typography folded to ASCII
+	nAttr, biblContent = ur.NormalizeRef(nAttr), ur.NormalizeRef(biblContent)
 	if nAttr != "" {
 		nAttr = strings.ToLower(strings.TrimSpace(nAttr))
 	}
@@ -48,28 +213,7 @@ func (ur *URNResolver) GetRef(nAttr, biblContent string) string {
 	}
 
 	// Clean both inputs
-	refs := []string{nAttr, biblContent}
-	for i, ref := range refs {
-		if ref != "" {
-			// Normalize all whitespace (including newlines, tabs) to single spaces
-			ref = regexp.MustCompile(`\s+`).ReplaceAllString(ref, " ")
-			ref = strings.TrimSpace(ref)
-
-			// Remove HTML title tags
-			ref = regexp.MustCompile(`<title.*?>`).ReplaceAllString(ref, "")
-			ref = strings.ReplaceAll(ref, "</title>", "")
-			// Remove parentheses
-			ref = regexp.MustCompile(`[\(\)]`).ReplaceAllString(ref, "")
-			// Replace ", " with " "
-			ref = strings.ReplaceAll(ref, ", ", " ")
-			// Deal with section symbols
-			ref = regexp.MustCompile(` *§ *`).ReplaceAllString(ref, ".")
-			// Deal with spacing issues with alphabetic page references
-			ref = regexp.MustCompile(`(\d+) ([A-Za-z])`).ReplaceAllString(ref, "$1$2")
-			refs[i] = ref
-		}
-	}
-	nAttr, biblContent = refs[0], refs[1]
+	nAttr, biblContent = cleanRef(nAttr), cleanRef(biblContent)
 
 	// Early return conditions
 	if biblContent == "" || strings.TrimSpace(biblContent) == "" {
@@ -82,47 +226,37 @@ func (ur *URNResolver) GetRef(nAttr, biblContent string) string {
 		return biblContent
 	}
 
-	// Check if n attribute contains URN
-	if ur.detectURN(nAttr) != "" {
+	// Check if n attribute contains URN or a legacy Perseus ID
+	if _, isURN := ur.detectURN(nAttr); isURN || isLegacyID(nAttr) {
 		return nAttr
 	}
 
-	// Pattern matching logic - best to worst patterns
-	patterns := []string{
-		// Best: author work number.number
-		`([a-zA-Z]+\.?\s?[a-zA-Z]*) ([a-zA-Z]+\.?\s?[a-zA-Z]*) \d+(\s|\.|:)\d+`,
-		// Second best: author work number
-		`([a-zA-Z]+\.?\s?[a-zA-Z]*) ([a-zA-Z]+\.?\s?[a-zA-Z]*) \d+`,
-		// Third best: author number.number
-		`([a-zA-Z]+\.?) \d+(\s|\.|:)\d+`,
-		// Fourth best: author number
-		`([a-zA-Z]+\.?) \d+`,
-	}
-
-	allAuthAbb := ur.Data.GetAllAuthAbb()
-	allAuthors := ur.Data.GetAllAuthors()
-
-	for _, pattern := range patterns {
-		// Try n attribute first
-		if matched, _ := regexp.MatchString(pattern, nAttr); matched {
-			split := strings.Fields(nAttr)
-			if ur.hasRecognizedAuthor(split, allAuthAbb, allAuthors) {
-				return nAttr
+	// An author known only from an abbreviation layer counts only where
+	// neither names one a pack knows, so that loading a layer does not
+	// change which of the two is read
+	for _, recognized := range []func([]string) bool{ur.hasPackAuthor, ur.hasRecognizedAuthor} {
+		for _, pattern := range refPatterns {
+			// Try n attribute first
+			if pattern.MatchString(nAttr) {
+				split := strings.Fields(nAttr)
+				if recognized(split) {
+					return nAttr
+				}
 			}
-		}
 
-		// Try bibl content
-		if matched, _ := regexp.MatchString(pattern, biblContent); matched {
-			split := strings.Fields(biblContent)
-			if ur.hasRecognizedAuthor(split, allAuthAbb, allAuthors) {
-				return biblContent
+			// Try bibl content
+			if pattern.MatchString(biblContent) {
+				split := strings.Fields(biblContent)
+				if recognized(split) {
+					return biblContent
+				}
 			}
 		}
 	}
 
 	// Check for recognized authors without pattern matching
-	nAuthRec := ur.hasRecognizedAuthor(strings.Fields(nAttr), allAuthAbb, allAuthors)
-	biblAuthRec := ur.hasRecognizedAuthor(strings.Fields(biblContent), allAuthAbb, allAuthors)
+	nAuthRec := ur.hasRecognizedAuthor(strings.Fields(nAttr))
+	biblAuthRec := ur.hasRecognizedAuthor(strings.Fields(biblContent))
 
 	if nAuthRec && !biblAuthRec {
 		return nAttr
@@ -133,10 +267,10 @@ func (ur *URNResolver) GetRef(nAttr, biblContent string) string {
 
 	// Both have recognized authors - check for recognized works
 	if nAuthRec && biblAuthRec {
-		if ur.hasRecognizedWork(nAttr, allAuthAbb, allAuthors) {
+		if ur.hasRecognizedWork(nAttr) {
 			return nAttr
 		}
-		if ur.hasRecognizedWork(biblContent, allAuthAbb, allAuthors) {
+		if ur.hasRecognizedWork(biblContent) {
 			return biblContent
 		}
 	}
@@ -144,45 +278,128 @@ func (ur *URNResolver) GetRef(nAttr, biblContent string) string {
 	return ""
 }
 
-func (ur *URNResolver) hasRecognizedAuthor(split []string, authAbb map[string]any, authors map[string]bool) bool {
-	if len(split) == 0 {
-		return false
+// commentatorRegex matches a modern name followed by "on" or "ad" and the
+// rest of the reference, as in "Jebb on Soph. OT 100"
+var commentatorRegex = regexp.MustCompile(`(?i)^\s*([^\d]+?),?\s+(?:on|ad)\s+(.+)$`)
+
+// SplitCommentator separates a modern commentator cited together with an
+// ancient locus, as in "Jebb on Soph. OT 100", returning the commentator and
+// the ancient reference. Text without this form, or where the part before
+// "on" is itself an ancient author, comes back unchanged with no commentator.
+func (ur *URNResolver) SplitCommentator(text string) (commentator, ancient string) {
+	match := commentatorRegex.FindStringSubmatch(text)
+	if match == nil {
+		return "", text
+	}
+	modern, rest := strings.TrimSpace(match[1]), strings.TrimSpace(match[2])
+	if len(strings.Fields(modern)) > 4 {
+		return "", text
 	}
 
-	// Check unigram, bigram, trigram
-	for i := 1; i <= 3 && i <= len(split); i++ {
-		candidate := strings.Join(split[:i], " ")
-		if _, exists := authAbb[candidate]; exists {
-			return true
-		}
-		if authors[candidate] {
+	if ur.hasRecognizedAuthor(strings.Fields(strings.ToLower(modern))) ||
+		!ur.hasRecognizedAuthor(strings.Fields(strings.ToLower(rest))) {
+		return "", text
+	}
+	return modern, rest
+}
+
+// noteRegex matches a locus followed by a parenthetical without numbers, as in
+// "Soph. OT 924 (Messenger)" or "Ar. Ran. 1119 ff. (chorus)"
+var noteRegex = regexp.MustCompile(`^(.*\d[^()]*?)\s*\(\s*([^()\d]*[^()\d\s.][^()\d]*?)\s*\)[\s.,;]*$`)
+
+// SplitNote separates a trailing parenthetical note, usually the speaker of a
+// passage in drama, from the locus before it, returning the note and the
+// locus. Left in place, the note would run into the passage when parentheses
+// are removed ("924 messenger"). Text without such a note, or whose
+// parenthetical holds numbers, comes back unchanged with no note.
+func SplitNote(text string) (note, locus string) {
+	match := noteRegex.FindStringSubmatch(text)
+	if match == nil {
+		return "", text
+	}
+	return match[2], strings.TrimSpace(match[1])
+}
+
+var (
+	whitespaceRegex   = regexp.MustCompile(`\s+`)
+	titleTagRegex     = regexp.MustCompile(`<title.*?>`)
+	parenthesisRegex  = regexp.MustCompile(`[\(\)]`)
+	sectionSignRegex  = regexp.MustCompile(` *§ *`)
+	spacedLetterRegex = regexp.MustCompile(`(\d+) ([A-Za-z])`)
+	// the Stephanus and Bekker page markers in "St. 327a" and "Bekk. 1094a";
+	// "p." is left alone, as it abbreviates works such as the Pythians
+	pageMarkerRegex = regexp.MustCompile(`(^|\s)(?:st|steph|bekk)\.\s*(\d)`)
+)
+
+// cleanRef normalizes a lower-cased n attribute or bibl content for parsing
+func cleanRef(ref string) string {
+	if ref == "" {
+		return ref
+	}
+	// Normalize all whitespace (including newlines, tabs) to single spaces
+	ref = whitespaceRegex.ReplaceAllString(ref, " ")
+	ref = strings.TrimSpace(ref)
+
+	// Remove HTML title tags
+	ref = titleTagRegex.ReplaceAllString(ref, "")
+	ref = strings.ReplaceAll(ref, "</title>", "")
+	// Remove parentheses
+	ref = parenthesisRegex.ReplaceAllString(ref, "")
+	// Replace ", " with " "
+	ref = strings.ReplaceAll(ref, ", ", " ")
+	// Deal with section symbols
+	ref = sectionSignRegex.ReplaceAllString(ref, ".")
+	// Drop page markers, which are not part of the passage
+	ref = pageMarkerRegex.ReplaceAllString(ref, "$1$2")
+	// Deal with spacing issues with alphabetic page references
+	ref = spacedLetterRegex.ReplaceAllString(ref, "$1$2")
+	return ref
+}
+
+// hasRecognizedAuthor reports whether the words start with an author name
+// or abbreviation of up to three words
+func (ur *URNResolver) hasRecognizedAuthor(split []string) bool {
+	var prefixes [3]loader.NamePrefix
+	return len(ur.Data.AuthorPrefixes(prefixes[:0], split, 3)) > 0
+}
+
+// hasPackAuthor is hasRecognizedAuthor with only the authors and
+// abbreviations of the packs, not those of abbreviation layers
+func (ur *URNResolver) hasPackAuthor(split []string) bool {
+	var prefixes [3]loader.NamePrefix
+	for _, prefix := range ur.Data.AuthorPrefixes(prefixes[:0], split, 3) {
+		if !ur.Data.LayerOnly(prefix.Key) {
 			return true
 		}
 	}
 	return false
 }
 
-func (ur *URNResolver) hasRecognizedWork(ref string, authAbb map[string]any, authors map[string]bool) bool {
+var numericTailRegex = regexp.MustCompile(`\d.*`)
+
+func (ur *URNResolver) hasRecognizedWork(ref string) bool {
 	split := strings.Fields(ref)
 	if len(split) < 2 {
 		return false
 	}
 
 	// Find author
+	authAbb := ur.Data.GetAllAuthAbb()
+	authors := ur.Data.GetAllAuthors()
 	var author string
 	var authLen int
-	for i := 1; i <= 3 && i <= len(split); i++ {
-		candidate := strings.Join(split[:i], " ")
-		if val, exists := authAbb[candidate]; exists {
+	var prefixes [3]loader.NamePrefix
+	for _, prefix := range ur.Data.AuthorPrefixes(prefixes[:0], split, 3) {
+		if val, exists := authAbb[prefix.Key]; exists {
 			if str, ok := val.(string); ok {
 				author = str
-				authLen = i
+				authLen = prefix.Words
 				break
 			}
 		}
-		if authors[candidate] {
-			author = candidate
-			authLen = i
+		if authors[prefix.Key] {
+			author = prefix.Key
+			authLen = prefix.Words
 			break
 		}
 	}
@@ -193,21 +410,18 @@ func (ur *URNResolver) hasRecognizedWork(ref string, authAbb map[string]any, aut
 
 	// Check for recognized work
 	allWorkURNs := ur.Data.GetAllWorkURNs()
-	authorWorks, exists := allWorkURNs[author]
-	if !exists {
-		return false
-	}
-
 	workPart := strings.Join(split[authLen:], " ")
 	// Remove any numeric parts
-	workPart = regexp.MustCompile(`\d.*`).ReplaceAllString(workPart, "")
+	workPart = numericTailRegex.ReplaceAllString(workPart, "")
 	workPart = strings.TrimSpace(workPart)
 
 	// Check for work up to trigram
 	workSplit := strings.Fields(workPart)
 	for i := 1; i <= 3 && i <= len(workSplit); i++ {
 		candidate := strings.Join(workSplit[:i], " ")
-		if _, exists := authorWorks[candidate]; exists {
+		// an abbreviation standing for several authors, as "_which_pliny",
+		// stands for the one its disambiguator picks for the work
+		if _, exists := allWorkURNs[ur.Data.AuthorFor(author, candidate)][candidate]; exists {
 			return true
 		}
 	}
@@ -215,9 +429,60 @@ func (ur *URNResolver) hasRecognizedWork(ref string, authAbb map[string]any, aut
 	return false
 }
 
+// qualifierRegex matches a reference ending in a locus followed by "init."
+// or "fin." (beginning or end of the passage), optionally after "ad" or "in"
+// as in "ad fin."
+var qualifierRegex = regexp.MustCompile(`^(.*\d[a-z]?)\s*(?:(?:ad|in)\s*)?(init|fin)\.?$`)
+
+// GetURN resolves a reference to a CTS URN, returning "" if it cannot be resolved
 func (ur *URNResolver) GetURN(ref, context, filename string) string {
+	return ur.Resolve(ref, context, filename).URN
+}
+
+// ResolveURN is GetURN returning why a reference did not resolve, as a
+// *ResolutionError naming the reference
+func (ur *URNResolver) ResolveURN(ref, context, filename string) (string, error) {
+	res := ur.Resolve(ref, context, filename)
+	if err := res.Err(); err != nil {
+		err.(*ResolutionError).Ref = ref
+		return "", err
+	}
+	return res.URN, nil
+}
+
+// Resolve resolves a reference to a CTS URN, recording warnings for failures
+func (ur *URNResolver) Resolve(ref, context, filename string) Resolution {
+	var res Resolution
 	if ref == "" {
-		return ""
+		res.Reason = ReasonEmptyRef
+		return res
+	}
+	if urn, exists := ur.Corrections.ForRef(ref); exists {
+		res.URN, res.Corrected = urn, true
+		return res
+	}
+	ctx := StepContext{Context: context, Filename: filename}
+	if ur.applyChain(&res, BeforeBuiltin, ref, ctx) {
+		return res
+	}
+	res = ur.resolveBuiltin(ref, context, filename)
+	ur.applyPassageFormat(&res)
+	if ur.Chain.Len() > 0 {
+		builtin := res
+		ctx.Builtin = &builtin
+		ur.applyChain(&res, AfterBuiltin, ref, ctx)
+	}
+	return res
+}
+
+// resolveBuiltin resolves a reference by the built-in heuristics
+func (ur *URNResolver) resolveBuiltin(ref, context, filename string) Resolution {
+	var res Resolution
+
+	// Set aside "init." and "fin." qualifiers, which would otherwise run
+	// into the passage ("3.2 init." is cleaned to "3.2init.")
+	if match := qualifierRegex.FindStringSubmatch(ref); match != nil {
+		ref, res.Qualifier = match[1], match[2]
 	}
 
 	// Handle "ff" notation
@@ -233,27 +498,106 @@ func (ur *URNResolver) GetURN(ref, context, filename string) string {
 		}
 	}
 
-	// Detect if ref is already a URN
-	if urnPart := ur.detectURN(ref); urnPart != "" {
-		return ur.formatExistingURN(ref, urnPart)
+	// Convert legacy Perseus ABO and text IDs
+	if urn, levels, recognized := ur.convertLegacyID(ref); recognized {
+		if urn == "" {
+			ur.warn(&res, ReasonUnknownLegacyID, ref, "unknown Perseus text ID: %s", ref)
+			return res
+		}
+		res.URN = urn
+		if match := perseusTextIDRegex.FindStringSubmatch(strings.TrimSpace(ref)); match != nil {
+			ur.recordTextID(&res, match[1])
+		}
+		ur.applyLocus(&res, levels)
+		return res
+	}
+
+	// Detect if ref is already a URN, possibly with key=value levels
+	if _, isURN := ur.detectURN(ref); isURN {
+		levels := ""
+		if loc := namedLevelsRegex.FindStringIndex(ref); loc != nil {
+			ref, levels = ref[:loc[0]], ref[loc[0]:]
+		}
+		urn, _ := ur.detectURN(ref)
+		formatted, err := ur.formatURN(urn)
+		if err != nil {
+			ur.warn(&res, ReasonMalformedURN, ref, "%v", err)
+			return res
+		}
+		res.URN = formatted
+		if urn.Scheme == "cts" && urn.Work == "" && urn.Passage != "" {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("passage %s dropped from text group URN %s", urn.Passage, res.URN))
+		}
+		ur.applyLocus(&res, levels)
+		return res
+	}
+
+	// Fragments cited by a standard collection, such as "FGrH 115 F 30"
+	if ur.resolveFragment(&res, ref) {
+		return res
+	}
+
+	// Inscriptions and papyri cited by corpus, such as "IG II² 1234"
+	if ur.resolveDocumentary(&res, ref) {
+		return res
+	}
+
+	// Poems and hymns cited by their opening words, such as "Hom. Hymn
+	// 'Εἰς Ἀφροδίτην'"
+	if ur.resolveIncipit(&res, ref) {
+		return res
 	}
 
 	// Parse reference
 	author, work, passage := ur.parseReference(ref)
 	if author == "" {
-		log.Printf("No author found in reference: %s", ref)
-		return ""
+		ur.warn(&res, ReasonNoAuthor, ref, "no author found in reference: %s", ref)
+		return res
 	}
 
-	// Resolve author abbreviation
-	resolvedAuthor := ur.resolveAuthor(author, work)
+	// Resolve author abbreviation, telling apart authors who share a name,
+	// as "Apollonius", by epithet or work
+	var resolvedAuthor string
+	if key, group, exists := ur.Data.HomonymGroup(author); exists {
+		var ok bool
+		if resolvedAuthor, work, passage, ok = ur.resolveHomonym(&res, ref, author, key, group); !ok {
+			return res
+		}
+	} else {
+		resolvedAuthor = ur.resolveAuthor(author, work)
+	}
 	if resolvedAuthor == "" {
-		log.Printf("Author not recognized: %s", author)
-		return ""
+		// try a misspelled or variant author ("Thukydides", "Aeschyl.")
+		// before giving up on it
+		if match, found := ur.fuzzyAuthor(author); found {
+			author, work, passage = ur.parseReference(match.Matched + strings.TrimSpace(ref)[len(author):])
+			resolvedAuthor = ur.resolveAuthor(author, work)
+			res.FuzzyAuthor = &match
+			ur.logger().Debug("author matched fuzzily", "ref", ref, "author", match.Input, "matched", match.Matched, "edit_distance", match.Distance, "score", match.Score)
+		}
+	}
+	if resolvedAuthor == "" {
+		ur.warn(&res, ReasonUnknownAuthor, ref, "author not recognized: %s", author)
+		// the "author" may be a work title cited on its own, as in "El. 123"
+		res.Candidates = ur.candidates(ur.Data.WorkCandidatesAnyAuthor(author), passage)
+		return res
 	}
 
+	ur.recordAuthor(&res, author, resolvedAuthor)
+
 	// Handle single work authors
 	if ur.Data.IsSingleWorkAuthor(resolvedAuthor) {
+		// cited by page, as Strabo by Casaubon's in "Strab. C213"
+		if locus, ok := ur.paginate(&res, resolvedAuthor, "", strings.Trim(work+"."+passage, ".")); ok {
+			urn, err := ur.handleSingleWorkAuthor(resolvedAuthor, locus, locus)
+			if err != nil {
+				ur.warn(&res, ReasonMalformedURN, ref, "%v", err)
+				return res
+			}
+			res.URN = urn
+			ur.recordSingleWork(&res, resolvedAuthor)
+			return res
+		}
 		// For single work authors, treat work field as part of passage if it looks like a book/section reference
 		if work != "" && ur.looksLikeBookReference(work) {
 			// Combine work and passage as location
@@ -261,9 +605,26 @@ func (ur *URNResolver) GetURN(ref, context, filename string) string {
 			if passage != "" {
 				fullPassage += "." + passage
 			}
-			return ur.handleSingleWorkAuthor(resolvedAuthor, fullPassage, ref)
+			urn, err := ur.handleSingleWorkAuthor(resolvedAuthor, fullPassage, ref)
+			if err != nil {
+				ur.warn(&res, ReasonMalformedURN, ref, "%v", err)
+				return res
+			}
+			res.URN = urn
+			ur.recordSingleWork(&res, resolvedAuthor)
+			ur.applyCitationScheme(&res, ref, resolvedAuthor, work)
+			return res
 		} else if work == "" {
-			return ur.handleSingleWorkAuthor(resolvedAuthor, passage, ref)
+			urn, err := ur.handleSingleWorkAuthor(resolvedAuthor, passage, ref)
+			if err != nil {
+				ur.warn(&res, ReasonMalformedURN, ref, "%v", err)
+				return res
+			}
+			res.URN = urn
+			ur.recordSingleWork(&res, resolvedAuthor)
+			book, _, _ := strings.Cut(passage, ".")
+			ur.applyCitationScheme(&res, ref, resolvedAuthor, book)
+			return res
 		}
 	}
 
@@ -271,78 +632,276 @@ func (ur *URNResolver) GetURN(ref, context, filename string) string {
 	allAuthURNs := ur.Data.GetAllAuthURNs()
 	authURN, exists := allAuthURNs[resolvedAuthor]
 	if !exists {
-		log.Printf("No URN found for author: %s", resolvedAuthor)
-		return ""
+		ur.warn(&res, ReasonNoAuthorURN, ref, "no URN found for author: %s", resolvedAuthor)
+		return res
+	}
+
+	// Numbered works, such as the orations in "Dem. or. 18" or "Dem. 18–19"
+	if works, title, workPassage, ok := ur.numberedWorks(resolvedAuthor, work, passage); ok {
+		ur.recordWork(&res, resolvedAuthor, title, "")
+		if len(works) > 1 {
+			workPassage = ""
+		}
+		for _, w := range works {
+			urn, err := ur.editionURN(authURN, w, workPassage)
+			if err != nil {
+				ur.warn(&res, ReasonMalformedURN, ref, "%v", err)
+				res.Works = nil
+				return res
+			}
+			res.Works = append(res.Works, urn)
+		}
+		res.URN = res.Works[0]
+		if len(works) == 1 {
+			res.Works = nil
+		}
+		ur.applyCitationScheme(&res, ref, resolvedAuthor, "")
+		return res
 	}
 
 	// Get work URN
-	workURN := ur.getWorkURN(resolvedAuthor, work)
+	workURN, guessed := ur.getWorkURN(resolvedAuthor, work)
 	if workURN == "" {
-		log.Printf("No work URN found for %s: %s", resolvedAuthor, work)
-		return ""
+		ur.warn(&res, ReasonUnknownWork, ref, "no work URN found for %s: %s", resolvedAuthor, work)
+		res.Provisional = provisionalURN(authURN, passage)
+		return res
+	}
+	if guessed {
+		switch ur.WorkFallback {
+		case FallbackNone:
+			ur.warn(&res, ReasonUnknownWork, ref, "no work found for %s: %q, and work fallback is none", resolvedAuthor, work)
+			res.Provisional = provisionalURN(authURN, passage)
+			return res
+		case FallbackAuthor:
+			res.URN, res.Fallback = authURN, true
+			return res
+		}
+		res.Fallback = true
+	}
+	works := ur.Data.WorkCandidates(resolvedAuthor, work)
+	if !guessed {
+		if preferred, ok := ur.preferredWork(works, workURN, authURN, filename); ok {
+			workURN, res.Prior = preferred, true
+		}
+		ur.recordWork(&res, resolvedAuthor, strings.ToLower(work), workURN)
 	}
 
-	// Determine literature type for suffix
-	suffix := ur.determineLiteratureSuffix(authURN)
-
-	// Construct final URN
-	if passage != "" {
-		return fmt.Sprintf("%s.%s.%s:%s", authURN, workURN, suffix, passage)
+	// Construct final URN, with pages converted to the edition's passages
+	if locus, ok := ur.paginate(&res, resolvedAuthor, workURN, passage); ok {
+		passage = locus
+	}
+	urn, err := ur.editionURN(authURN, workURN, passage)
+	if err != nil {
+		ur.warn(&res, ReasonMalformedURN, ref, "%v", err)
+		res.Fallback, res.Prior = false, false
+		return res
+	}
+	res.URN = urn
+	ur.applyCitationScheme(&res, ref, resolvedAuthor, "")
+	if res.URN != "" {
+		res.Candidates = ur.candidates(works, passageOf(res.URN))
 	}
-	return fmt.Sprintf("%s.%s.%s", authURN, workURN, suffix)
+	return res
 }
 
-func (ur *URNResolver) detectURN(ref string) string {
-	patterns := []string{
-		`tlg\d+\.tlg\d+(:\d+.?\d*)?(ff)?`,
-		`phi\d+\.phi\d+(:\d+.?\d*)?(ff)?`,
-		`stoa\d+\.stoa\d+(:\d+.?\d*)?(ff)?`,
+// candidates turns the works a reference may cite into ranked URNs, returning
+// nil unless there is more than one
+func (ur *URNResolver) candidates(works []loader.WorkCandidate, passage string) []Candidate {
+	if len(works) < 2 {
+		return nil
+	}
+	if len(works) > maxCandidates {
+		works = works[:maxCandidates]
+	}
+	total := 0.0
+	for _, work := range works {
+		total += work.Weight
 	}
 
-	for _, pattern := range patterns {
-		re := regexp.MustCompile(pattern)
-		if match := re.FindString(ref); match != "" {
-			return match
+	allAuthURNs := ur.Data.GetAllAuthURNs()
+	var candidates []Candidate
+	for _, work := range works {
+		authURN, exists := allAuthURNs[work.Author]
+		if !exists {
+			continue
 		}
+		urn, err := ur.editionURN(authURN, work.WorkURN, passage)
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, Candidate{
+			URN:   urn,
+			Score: math.Round(work.Weight/total*100) / 100,
+		})
 	}
-	return ""
+	if len(candidates) < 2 {
+		return nil
+	}
+	return candidates
 }
 
-func (ur *URNResolver) formatExistingURN(ref, urnPart string) string {
-	// Extract location after URN
-	index := strings.Index(ref, urnPart)
-	remaining := ref[index+len(urnPart):]
+// passageOf returns the passage component of a URN, or "" if it has none
+func passageOf(urn string) string {
+	parsed, err := cts.Parse(urn)
+	if err != nil {
+		return ""
+	}
+	return parsed.Passage.String()
+}
 
-	locMatch := regexp.MustCompile(`\d+.*`)
-	loc := ""
-	if match := locMatch.FindString(remaining); match != "" {
-		loc = match
+// applyCitationScheme records the work's citation scheme on the resolution and
+// fits a numeric passage to it: a book given as a Roman numeral that was
+// dropped from the passage (as in "thuc. ii. 62", or "thuc. vi. 12-13" for
+// both ends of a range) is restored. A passage the scheme cannot hold is
+// rejected by checkScheme, never cut to fit: 5.610 in a line-numbered play
+// is more likely a list of lines than a deeper passage, and cutting it to
+// line 5 would link the wrong one.
+func (ur *URNResolver) applyCitationScheme(res *Resolution, ref, author, book string) {
+	urn, err := cts.Parse(res.URN)
+	if err != nil || urn.Work == "" {
+		return
+	}
+	scheme, exists := ur.Data.WorkScheme(author, urn.Work)
+	if !exists || scheme.Scheme == "" {
+		return
+	}
+	res.Scheme = scheme.Scheme
+	depth := scheme.Depth()
+	if urn.Passage.IsRange() {
+		// ranges are left as cited but for a dropped book, restored only
+		// where both ends lack it
+		start, end := urn.Passage.Start, urn.Passage.End
+		if numericPassageRegex.MatchString(start) && numericPassageRegex.MatchString(end) &&
+			strings.Count(start, ".")+1 < depth && strings.Count(end, ".")+1 < depth {
+			if n := romanToArabic(book); n > 0 {
+				urn.Passage.Start = strconv.Itoa(n) + "." + start
+				urn.Passage.End = strconv.Itoa(n) + "." + end
+				res.URN = urn.String()
+			}
+		}
+	} else if numericPassageRegex.MatchString(urn.Passage.Start) {
+		// other passages that are not numbers are left as cited
+		levels := strings.Split(urn.Passage.Start, ".")
+		if len(levels) < depth {
+			if n := romanToArabic(book); n > 0 {
+				levels = append([]string{strconv.Itoa(n)}, levels...)
+			}
+		}
+		urn.Passage.Start = strings.Join(levels, ".")
+		res.URN = urn.String()
 	}
+	ur.checkScheme(res, ref, urn, scheme)
+}
 
-	// Determine URN type and format
-	if strings.Contains(urnPart, "tlg") {
-		base := urnPart
-		if !strings.Contains(base, "urn:cts:greekLit") {
-			base = "urn:cts:greekLit:" + base
+// checkScheme rejects a numeric passage the work's scheme cannot hold: one
+// with more levels than the scheme, as "Soph. OC 5.610" of a play cited by
+// line, a range ending before it starts, as "Hom. Il. 1.216-1.211", or one
+// whose top level is beyond the last the scheme records, as "Hom. Il. 30.1"
+// for an Iliad of 24 books. The URN is moved to SchemeViolation and the
+// reference left unresolved, rather than linking a passage that does not
+// exist. The end of a range giving fewer levels than the start continues
+// it, so in "5.265-266" it is a line, and is checked against the top level
+// only when it gives as many.
+func (ur *URNResolver) checkScheme(res *Resolution, ref string, urn cts.URN, scheme loader.WorkScheme) {
+	start, end := urn.Passage.Start, urn.Passage.End
+	if !numericPassageRegex.MatchString(start) || (urn.Passage.IsRange() && !numericPassageRegex.MatchString(end)) {
+		return
+	}
+	work := cts.URN{Namespace: urn.Namespace, TextGroup: urn.TextGroup, Work: urn.Work}
+	levels := strings.Split(start, ".")
+	if depth := scheme.Depth(); len(levels) > depth || strings.Count(end, ".")+1 > depth {
+		ur.rejectPassage(res, ref, "passage %s has more levels than the %s citation scheme of %s", urn.Passage, scheme.Scheme, work)
+		return
+	}
+	ends := []string{start}
+	if urn.Passage.IsRange() {
+		// the end continues the start from the level it gives
+		endLevels := strings.Split(end, ".")
+		if len(endLevels) == len(levels) {
+			ends = append(ends, end)
 		}
-		if loc != "" {
-			return fmt.Sprintf("%s.perseus-grc2:%s", base, loc)
+		if offset := len(levels) - len(endLevels); offset >= 0 && endsBefore(endLevels, levels[offset:]) {
+			ur.rejectPassage(res, ref, "range %s of %s ends before it starts", urn.Passage, work)
+			return
 		}
-		return base + ".perseus-grc2"
-	} else if strings.Contains(urnPart, "phi") {
-		base := urnPart
-		if !strings.Contains(base, "urn:cts:latinLit") {
-			base = "urn:cts:latinLit:" + base
+	}
+	if scheme.Max == 0 {
+		return
+	}
+	level, _, _ := strings.Cut(scheme.Scheme, ".")
+	for _, end := range ends {
+		top, _, _ := strings.Cut(end, ".")
+		n, err := strconv.Atoi(top)
+		if err != nil || n <= scheme.Max {
+			continue
 		}
-		if loc != "" {
-			return fmt.Sprintf("%s.perseus-lat2:%s", base, loc)
+		ur.rejectPassage(res, ref, "passage %s is beyond the citation scheme of %s: %s %d, of %d",
+			urn.Passage, work, level, n, scheme.Max)
+		return
+	}
+}
+
+// rejectPassage leaves a reference unresolved for a scheme violation,
+// keeping the URN it would have had as SchemeViolation
+func (ur *URNResolver) rejectPassage(res *Resolution, ref, format string, args ...any) {
+	ur.warn(res, ReasonSchemeViolation, ref, format, args...)
+	res.SchemeViolation = res.URN
+	res.URN, res.Works, res.Candidates = "", nil, nil
+	res.Fallback, res.Prior = false, false
+}
+
+// endsBefore reports whether the levels of the end of a range come before
+// those of its start, compared as numbers from the top. A level with fewer
+// digits than the start's gives only its last ones, as the 8 of "226-8"
+// for 228, and levels with a letter, as "338d", are compared by their
+// number alone.
+func endsBefore(end, start []string) bool {
+	for i := range end {
+		endLevel, startLevel := strings.TrimRight(end[i], "abcde"), strings.TrimRight(start[i], "abcde")
+		if len(endLevel) < len(startLevel) {
+			endLevel = startLevel[:len(startLevel)-len(endLevel)] + endLevel
+		}
+		e, _ := strconv.Atoi(endLevel)
+		s, _ := strconv.Atoi(startLevel)
+		if e != s {
+			return e < s
 		}
-		return base + ".perseus-lat2"
 	}
+	return false
+}
+
+// numericPassageRegex matches passages such as "62", "2.40" or "338d"
+var numericPassageRegex = regexp.MustCompile(`^\d+[a-e]?(\.\d+[a-e]?)*$`)
+
+// ReferenceParts is how the resolver reads a reference: the author, work and
+// passage as cited, and the author they resolve to ("" if unrecognized)
+type ReferenceParts struct {
+	Author         string
+	Work           string
+	Passage        string
+	ResolvedAuthor string
+}
 
-	return urnPart
+// ParseReference splits a reference as produced by GetRef into its parts, for
+// inspecting why a reference resolves as it does. References that are URNs or
+// legacy Perseus IDs are not parsed by Resolve and have no parts.
+func (ur *URNResolver) ParseReference(ref string) ReferenceParts {
+	if _, isURN := ur.detectURN(ref); isURN || isLegacyID(ref) {
+		return ReferenceParts{}
+	}
+	author, work, passage := ur.parseReference(ref)
+	parts := ReferenceParts{Author: author, Work: work, Passage: passage}
+	if author != "" {
+		parts.ResolvedAuthor = ur.resolveAuthor(author, work)
+	}
+	return parts
 }
 
+var (
+	leadingDigitRegex = regexp.MustCompile(`^\d`)
+	repeatedDotRegex  = regexp.MustCompile(`\.+`)
+)
+
 func (ur *URNResolver) parseReference(ref string) (author, work, passage string) {
 	// Follow Python get_urn parsing logic more closely
 	ref = strings.TrimSpace(ref)
@@ -353,30 +912,28 @@ func (ur *URNResolver) parseReference(ref string) (author, work, passage string)
 	}
 
 	// Try to identify author (can be unigram or bigram)
-	allAuthAbb := ur.Data.GetAllAuthAbb()
-	allAuthors := ur.Data.GetAllAuthors()
-
 	author = split[0]
 	authLen := 1
 
 	// Check for bigram author
-	if len(split) > 1 {
-		bigram := strings.Join(split[:2], " ")
-		if _, exists := allAuthAbb[bigram]; exists {
-			author = bigram
-			authLen = 2
-		} else if allAuthors[bigram] {
-			author = bigram
-			authLen = 2
-		}
+	var buf [2]loader.NamePrefix
+	if prefixes := ur.Data.AuthorPrefixes(buf[:0], split, 2); len(prefixes) > 0 && prefixes[len(prefixes)-1].Words == 2 {
+		author = prefixes[len(prefixes)-1].Key
+		authLen = 2
 	}
 
 	if authLen >= len(split) {
 		return author, "", ""
 	}
+	work, passage = ur.parseWorkPassage(author, split[authLen:])
+	return author, work, passage
+}
 
+// parseWorkPassage splits the words of a reference after the author into
+// the work and the passage
+func (ur *URNResolver) parseWorkPassage(author string, words []string) (work, passage string) {
 	// Process remaining parts for work and passage
-	remaining := strings.Join(split[authLen:], " ")
+	remaining := strings.Join(words, " ")
 
 	// Replace spaces in multi-word titles with underscores
 	remaining = ur.processWorkTitles(author, remaining)
@@ -384,14 +941,14 @@ func (ur *URNResolver) parseReference(ref string) (author, work, passage string)
 	// Handle various formats
 	parts := strings.Fields(remaining)
 	if len(parts) == 0 {
-		return author, "", ""
+		return "", ""
 	}
 
 	// Check for work.passage format
 	if len(parts) == 1 && strings.Contains(parts[0], ".") {
 		workPassage := strings.SplitN(parts[0], ".", 2)
 		if len(workPassage) == 2 {
-			return author, workPassage[0], workPassage[1]
+			return workPassage[0], workPassage[1]
 		}
 	}
 
@@ -399,23 +956,23 @@ func (ur *URNResolver) parseReference(ref string) (author, work, passage string)
 	workParts := []string{}
 	for i, part := range parts {
 		// Check if this part starts with a number or is a Roman numeral
-		if regexp.MustCompile(`^\d`).MatchString(part) || ur.looksLikeRomanNumeral(part) {
+		if leadingDigitRegex.MatchString(part) || ur.looksLikeRomanNumeral(part) {
 			// This part starts with a number or is a Roman numeral - it's the passage
 			work = strings.Join(workParts, " ")
 			passage = strings.Join(parts[i:], " ")
 			// Clean up passage formatting
-			passage = regexp.MustCompile(`\s+`).ReplaceAllString(passage, ".")
+			passage = whitespaceRegex.ReplaceAllString(passage, ".")
 			passage = strings.Trim(passage, ".")
 			// Remove duplicate dots
-			passage = regexp.MustCompile(`\.+`).ReplaceAllString(passage, ".")
-			return author, work, passage
+			passage = repeatedDotRegex.ReplaceAllString(passage, ".")
+			return work, passage
 		}
 		workParts = append(workParts, part)
 	}
 
 	// No numeric part found - everything is work
 	work = strings.Join(parts, " ")
-	return author, work, ""
+	return work, ""
 }
 
 func (ur *URNResolver) processWorkTitles(author, remaining string) string {
@@ -465,27 +1022,24 @@ func (ur *URNResolver) resolveAuthor(author, work string) string {
 		return author
 	}
 
-	// Check abbreviations
+	// Check abbreviations, running the disambiguator of one standing for
+	// several authors (like "_which_pliny") on the work
 	if val, exists := allAuthAbb[author]; exists {
-		if str, ok := val.(string); ok {
-			// If it's a function reference (like "_which_pliny"), use the resolver
-			if str == "_which_pliny" || str == "_which_seneca" {
-				return ur.Data.ResolveLatinAuthorFunction(author, work)
-			}
-			return str
-		}
-		// Handle function cases (Pliny/Seneca disambiguation)
-		return ur.Data.ResolveLatinAuthorFunction(author, work)
+		return ur.Data.AuthorFor(val, work)
 	}
 
 	return ""
 }
 
-func (ur *URNResolver) handleSingleWorkAuthor(author, passage, originalRef string) string {
+var passageSeparatorRegex = regexp.MustCompile(`[\s,.:]`)
+
+// handleSingleWorkAuthor returns the URN of a passage of a single-work
+// author's work, or "" if the author has no URN
+func (ur *URNResolver) handleSingleWorkAuthor(author, passage, originalRef string) (string, error) {
 	allAuthURNs := ur.Data.GetAllAuthURNs()
 	authURN, exists := allAuthURNs[author]
 	if !exists {
-		return ""
+		return "", nil
 	}
 
 	// Determine work prefix based on literature type
@@ -499,26 +1053,22 @@ func (ur *URNResolver) handleSingleWorkAuthor(author, passage, originalRef strin
 	} else {
 		workURN = "tlg001" // default to Greek
 	}
-	suffix := ur.determineLiteratureSuffix(authURN)
 
 	// Extract numeric parts for location
 	numerics := []string{}
-	parts := regexp.MustCompile(`[\s,.:]`).Split(originalRef, -1)
+	parts := passageSeparatorRegex.Split(originalRef, -1)
 	for _, part := range parts {
-		if regexp.MustCompile(`^\d+`).MatchString(part) {
+		if leadingDigitRegex.MatchString(part) {
 			numerics = append(numerics, part)
 		}
 	}
 
-	if len(numerics) > 0 {
-		loc := strings.Join(numerics, ".")
-		return fmt.Sprintf("%s.%s.%s:%s", authURN, workURN, suffix, loc)
-	}
-
-	return fmt.Sprintf("%s.%s.%s", authURN, workURN, suffix)
+	return ur.editionURN(authURN, workURN, strings.Join(numerics, "."))
 }
 
-func (ur *URNResolver) getWorkURN(author, work string) string {
+// getWorkURN finds the work ID for a work as cited, reporting whether it had
+// to be guessed because nothing matched
+func (ur *URNResolver) getWorkURN(author, work string) (workURN string, guessed bool) {
 	allWorkURNs := ur.Data.GetAllWorkURNs()
 	authorWorks, exists := allWorkURNs[author]
 	if !exists {
@@ -527,19 +1077,11 @@ func (ur *URNResolver) getWorkURN(author, work string) string {
 
 		// Handle numeric work IDs
 		if ur.isNumeric(work) {
-			return ur.constructNumericWorkURN(author, work)
+			return ur.constructNumericWorkURN(author, work), false
 		}
 
 		// Final fallback: use primary work based on literature type
-		allAuthURNs := ur.Data.GetAllAuthURNs()
-		if authURN, exists := allAuthURNs[author]; exists {
-			if strings.Contains(authURN, "latinLit") {
-				return "phi001"
-			} else if strings.Contains(authURN, "englishLit") {
-				return "eng001"
-			}
-		}
-		return "tlg001" // default to Greek
+		return ur.primaryWork(author), true
 	}
 
 	work = strings.ToLower(work)
@@ -548,57 +1090,31 @@ func (ur *URNResolver) getWorkURN(author, work string) string {
 	if workURN, exists := authorWorks[work]; exists {
 		// if workURN is not the string zero value, the workURN is a simple string
 		if workURN.Simple != "" {
-			return workURN.Simple
+			return workURN.Simple, false
 		}
 		if workURN.Range != nil {
-			return ur.handleWorkRange(work, workURN.Range)
+			return ur.handleWorkRange(work, workURN.Range), false
 		}
 	}
 
-	// Second priority: try abbreviations but prefer exact matches over generated ones
-	var exactMatches []string
-	var abbreviationMatches []string
-
-	// note that iterating through key-value pairs in maps when resolving URNS
-	// introduces non-deterministic behaviour
-	for title, workURN := range authorWorks {
-		// Check if this title exactly matches the work
-		if title == work {
-			// check if this title exactly matches the work
-			if workURN.Simple != "" {
-				exactMatches = append(exactMatches, workURN.Simple)
-			}
-		} else {
-			// check generated abbreviations
-			abbrevs := loader.GenerateWorkAbbreviations(title)
-			for _, abbrev := range abbrevs {
-				if abbrev == work {
-					if workURN.Simple != "" {
-						abbreviationMatches = append(abbreviationMatches, workURN.Simple)
-					}
-					break
-				}
-			}
-		}
-	}
-
-	// Return first exact match if any
-	if len(exactMatches) > 0 {
-		return exactMatches[0]
-	}
-
-	// Return first abbreviation match if any
-	if len(abbreviationMatches) > 0 {
-		return abbreviationMatches[0]
+	// Second priority: an abbreviation generated from one of the author's
+	// titles or abbreviations
+	if workURN, exists := ur.Data.DerivedWork(author, work); exists {
+		return workURN, false
 	}
 
 	// Handle numeric work IDs
 	if ur.isNumeric(work) {
-		return ur.constructNumericWorkURN(author, work)
+		return ur.constructNumericWorkURN(author, work), false
 	}
 
 	// Final fallback: use primary work based on literature type
 	// This handles cases where work is assumed to be author's main work
+	return ur.primaryWork(author), true
+}
+
+// primaryWork guesses the author's main work from the literature type
+func (ur *URNResolver) primaryWork(author string) string {
 	allAuthURNs := ur.Data.GetAllAuthURNs()
 	if authURN, exists := allAuthURNs[author]; exists {
 		if strings.Contains(authURN, "latinLit") {
@@ -648,6 +1164,8 @@ func (ur *URNResolver) constructNumericWorkURN(author, work string) string {
 	}
 }
 
+var numberRegex = regexp.MustCompile(`\d+`)
+
 func (ur *URNResolver) handleWorkRange(work string, wr *loader.WorkRange) string {
 	if wr == nil {
 		panic("resolver.go: handleWorkRange should not be called with nil for wr")
@@ -657,8 +1175,7 @@ func (ur *URNResolver) handleWorkRange(work string, wr *loader.WorkRange) string
 	end := wr.End
 
 	// Extract number from work
-	re := regexp.MustCompile(`\d+`)
-	matches := re.FindStringSubmatch(work)
+	matches := numberRegex.FindStringSubmatch(work)
 	if len(matches) > 0 {
 		if num, err := strconv.Atoi(matches[0]); err == nil {
 			if num >= start && num <= end {
@@ -670,13 +1187,69 @@ func (ur *URNResolver) handleWorkRange(work string, wr *loader.WorkRange) string
 	return ""
 }
 
+// workRangeRegex matches a passage that is a range of work numbers, as in
+// "18-19" or "18–19"; spaces around the dash have become dots by this point
+var workRangeRegex = regexp.MustCompile(`^(\d+)\.?[-–—]\.?(\d+)$`)
+
+// rangeDashReplacer writes en and em dashes in passage ranges as the hyphen
+// CTS uses
+var rangeDashReplacer = strings.NewReplacer("–", "-", "—", "-")
+
+// numberedWorks resolves references to works the author's data cites by
+// number, like the orations of Demosthenes. With a work such as "or." the
+// passage's first level is the work number ("or. 18.5" is oration 18, section
+// 5); a range of numbers, with or without the work, spans several works. It
+// returns the work IDs and the passage within the work, and false if the
+// reference is not of this kind, with the title of the range used.
+func (ur *URNResolver) numberedWorks(author, work, passage string) (works []string, title, workPassage string, ok bool) {
+	authorWorks := ur.Data.GetAllWorkURNs()[author]
+	var wr *loader.WorkRange
+	if work != "" {
+		title = strings.ToLower(work)
+		wr = authorWorks[title].Range
+	} else if workRangeRegex.MatchString(passage) {
+		// a bare range only spans works if the author has numbered works;
+		// take the first such entry by title so the choice is deterministic
+		titles := make([]string, 0, len(authorWorks))
+		for title, workURN := range authorWorks {
+			if workURN.Range != nil {
+				titles = append(titles, title)
+			}
+		}
+		sort.Strings(titles)
+		if len(titles) > 0 {
+			title, wr = titles[0], authorWorks[titles[0]].Range
+		}
+	}
+	if wr == nil {
+		return nil, "", "", false
+	}
+
+	if match := workRangeRegex.FindStringSubmatch(passage); match != nil {
+		start, _ := strconv.Atoi(match[1])
+		end, _ := strconv.Atoi(match[2])
+		if start >= end || start < wr.Start || end > wr.End {
+			return nil, "", "", false
+		}
+		for num := start; num <= end; num++ {
+			works = append(works, fmt.Sprintf("%s%03d", wr.Prefix, num))
+		}
+		return works, title, "", true
+	}
+
+	first, rest, _ := strings.Cut(passage, ".")
+	num, err := strconv.Atoi(first)
+	if err != nil || num < wr.Start || num > wr.End {
+		return nil, "", "", false
+	}
+	return []string{fmt.Sprintf("%s%03d", wr.Prefix, num)}, title, rangeDashReplacer.Replace(rest), true
+}
+
+var arabicBookRegex = regexp.MustCompile(`^\d+\.?$`)
+
 func (ur *URNResolver) looksLikeBookReference(work string) bool {
 	work = strings.ToLower(strings.TrimSpace(work))
 
-	// Roman numerals (common for book references in ancient texts)
-	romanNumerals := []string{"i", "ii", "iii", "iv", "v", "vi", "vii", "viii", "ix", "x",
-		"xi", "xii", "xiii", "xiv", "xv", "xvi", "xvii", "xviii", "xix", "xx"}
-
 	for _, roman := range romanNumerals {
 		if work == roman || work == roman+"." {
 			return true
@@ -684,7 +1257,7 @@ func (ur *URNResolver) looksLikeBookReference(work string) bool {
 	}
 
 	// Arabic numerals
-	if matched, _ := regexp.MatchString(`^\d+\.?$`, work); matched {
+	if arabicBookRegex.MatchString(work) {
 		return true
 	}
 
@@ -692,28 +1265,39 @@ func (ur *URNResolver) looksLikeBookReference(work string) bool {
 }
 
 func (ur *URNResolver) looksLikeRomanNumeral(text string) bool {
-	text = strings.ToLower(strings.TrimSpace(text))
-	text = strings.TrimSuffix(text, ".")
+	return romanToArabic(text) > 0
+}
 
-	// Roman numerals (common for book references in ancient texts)
-	romanNumerals := []string{"i", "ii", "iii", "iv", "v", "vi", "vii", "viii", "ix", "x",
-		"xi", "xii", "xiii", "xiv", "xv", "xvi", "xvii", "xviii", "xix", "xx"}
-	for _, roman := range romanNumerals {
+// Roman numerals (common for book references in ancient texts)
+var romanNumerals = []string{"i", "ii", "iii", "iv", "v", "vi", "vii", "viii", "ix", "x",
+	"xi", "xii", "xiii", "xiv", "xv", "xvi", "xvii", "xviii", "xix", "xx"}
+
+// romanToArabic returns the value of a Roman numeral up to xx, ignoring case
+// and a trailing period, or 0 if text is not one
+func romanToArabic(text string) int {
+	text = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(text)), ".")
+	for i, roman := range romanNumerals {
 		if text == roman {
-			return true
+			return i + 1
 		}
 	}
-	return false
+	return 0
 }
 
-func (ur *URNResolver) determineLiteratureSuffix(authURN string) string {
-	if strings.Contains(authURN, "greekLit") {
+// determineLiteratureSuffix returns the exemplar to cite for a work or, when
+// the work is unknown, an author: the one the data files prefer for it, else
+// the usual Perseus edition of its namespace
+func (ur *URNResolver) determineLiteratureSuffix(urn string) string {
+	if exemplar := ur.Data.Exemplar(urn); exemplar != "" {
+		return exemplar
+	}
+	if strings.Contains(urn, "greekLit") {
 		return "perseus-grc2"
-	} else if strings.Contains(authURN, "latinLit") {
+	} else if strings.Contains(urn, "latinLit") {
 		return "perseus-lat2"
-	} else if strings.Contains(authURN, "englishLit") {
+	} else if strings.Contains(urn, "englishLit") {
 		return "perseus-eng2"
-	} else if strings.Contains(authURN, "greekSchol") {
+	} else if strings.Contains(urn, "greekSchol") {
 		return "perseus-grc2"
 	}
 	return "perseus-grc2" // default
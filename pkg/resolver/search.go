@@ -0,0 +1,109 @@
+package resolver
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"perseus_citation_linker/pkg/cts"
+)
+
+// minWorkSimilarity is the Jaro-Winkler similarity below which a title that
+// does not contain the query is not a match
+const minWorkSimilarity = 0.85
+
+// WorkMatch is a work found by FindWorks
+type WorkMatch struct {
+	Author  string  `json:"author"`
+	Title   string  `json:"title"`   // the work's title in the data files
+	URN     string  `json:"urn"`     // work-level URN, e.g. "urn:cts:greekLit:tlg0011.tlg004"
+	Matched string  `json:"matched"` // the title or abbreviation the query matched
+	Score   float64 `json:"score"`   // from 1 for an exact match down to 0
+}
+
+// FindWorks searches the titles and generated abbreviations of the works of
+// every author, in all namespaces, for a query typed by hand, as a type-ahead
+// lookup would. A title matches if it equals the query, starts with it,
+// contains each of its words, or failing that is at least minWorkSimilarity
+// similar to it, ignoring case and diacritics; matches score in that order.
+// Each work is returned once, with its best match, best first.
+func (ur *URNResolver) FindWorks(query string) []WorkMatch {
+	folded := string(foldName(strings.TrimSpace(query)))
+	if folded == "" {
+		return nil
+	}
+	words := strings.Fields(folded)
+
+	allAuthURNs := ur.Data.GetAllAuthURNs()
+	best := make(map[string]WorkMatch)
+	for author, keys := range ur.Data.WorkKeys() {
+		authURN, err := cts.Parse(allAuthURNs[author])
+		if err != nil {
+			continue
+		}
+		for key, workURNs := range keys {
+			score := workMatchScore(folded, words, string(foldName(key)))
+			if score == 0 {
+				continue
+			}
+			for _, workURN := range workURNs {
+				urn := authURN
+				urn.Work = workURN
+				match := WorkMatch{
+					Author:  author,
+					Title:   ur.Data.WorkTitle(author, workURN),
+					URN:     urn.String(),
+					Matched: key,
+					Score:   math.Round(score*100) / 100,
+				}
+				// authors listed under several names share a URN
+				current, exists := best[match.URN]
+				if !exists || match.Score > current.Score ||
+					(match.Score == current.Score && match.Author+"\x00"+match.Matched < current.Author+"\x00"+current.Matched) {
+					best[match.URN] = match
+				}
+			}
+		}
+	}
+
+	matches := make([]WorkMatch, 0, len(best))
+	for _, match := range best {
+		matches = append(matches, match)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].URN < matches[j].URN
+	})
+	return matches
+}
+
+// workMatchScore scores a folded title or abbreviation against a folded
+// query, 0 for no match. Within each kind of match, titles the query covers
+// more of score higher.
+func workMatchScore(query string, words []string, key string) float64 {
+	coverage := float64(len(query)) / float64(max(len(key), len(query)))
+	switch {
+	case key == query:
+		return 1
+	case strings.HasPrefix(key, query):
+		return 0.8 + 0.15*coverage
+	case containsAll(key, words):
+		return 0.6 + 0.15*coverage
+	}
+	if similarity := jaroWinkler([]rune(query), []rune(key)); similarity >= minWorkSimilarity {
+		return 0.6 * similarity
+	}
+	return 0
+}
+
+// containsAll reports whether s contains every word
+func containsAll(s string, words []string) bool {
+	for _, word := range words {
+		if !strings.Contains(s, word) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package resolver
+
+import (
+	"regexp"
+	"strings"
+)
+
+// secondaryNamePattern matches the name of a modern scholar as citations give
+// it: a capitalized surname, possibly with initials, a particle or a second
+// surname after a hyphen, as in "Wilamowitz", "M. L. West", "von Fritz" or
+// "Kühner-Gerth", or two such names joined by "and" or "&", or followed by
+// "et al."
+const secondaryNamePattern = `((?:\p{Lu}\.\s*)*(?:(?:von|van|de|der|den|du|di|da|le|la)\s+)*\p{Lu}[\p{Ll}'’]+(?:-\p{Lu}[\p{Ll}'’]+)?` +
+	`(?:\s+(?:and|&)\s+(?:\p{Lu}\.\s*)*\p{Lu}[\p{Ll}'’]+)?(?:\s+et\s+al\.)?)`
+
+var (
+	// the cue a bibl may open with, as "cf." or "see"
+	secondaryCueRegex = regexp.MustCompile(`(?i)^(?:cf|see|vid|v)\.?,?\s+`)
+
+	// Wilamowitz 1889, 23; M. L. West (1966) 145; Wilamowitz, Hermes 1889, p. 23;
+	// Page 1955a, 12-14
+	secondaryYearRegex = regexp.MustCompile(`^` + secondaryNamePattern +
+		`,?(?:\s+(\p{Lu}[\pL.]*(?:\s+\p{Lu}[\pL.]*){0,3}),?)?\s*\(?((?:1[5-9]|20)\d\d)([a-z])?\)?` +
+		`(?:\s*[,:]?\s*(?:pp?\.\s*)?(\d+(?:\s*[-–]\s*\d+)?(?:\s*ff?\.)?))?\s*\.?$`)
+
+	// Jebb ad loc.; Jebb, ad l.; Fraenkel ad h.l.; Barrett on the passage
+	secondaryAdLocRegex = regexp.MustCompile(`^` + secondaryNamePattern +
+		`,?\s+(?:ad\s+(?:loc(?:um)?\.?|l\.|h\.\s*l\.)|on\s+(?:the|this)\s+(?:passage|line|verse)\.?)$`)
+)
+
+// SecondaryReference is a citation of modern scholarship rather than of an
+// ancient text, such as "Jebb ad loc." or "Wilamowitz 1889, 23", which has
+// no CTS URN to resolve to
+type SecondaryReference struct {
+	Author string `json:"author"`          // as cited, e.g. "Wilamowitz" or "M. L. West"
+	Year   string `json:"year,omitempty"`  // of publication, with a letter telling apart works of one year, e.g. "1889" or "1955a"
+	Title  string `json:"title,omitempty"` // abbreviated journal or book cited before the year, e.g. "Hermes"
+	Pages  string `json:"pages,omitempty"` // e.g. "23" or "12-14"
+	AdLoc  bool   `json:"ad_loc,omitempty"`
+}
+
+// SecondaryLiterature returns the modern scholar and publication a bibl
+// cites, by author and year, as "Wilamowitz 1889, 23", or as a commentary on
+// the passage at hand, as "Jebb ad loc.", or nil if it cites neither. A
+// name the data gives an ancient author, as "Plato 1900" would, is not taken
+// for a modern one.
+func (ur *URNResolver) SecondaryLiterature(text string) *SecondaryReference {
+	text = strings.TrimSpace(whitespaceRegex.ReplaceAllString(text, " "))
+	text = secondaryCueRegex.ReplaceAllString(text, "")
+
+	var secondary *SecondaryReference
+	if match := secondaryYearRegex.FindStringSubmatch(text); match != nil {
+		secondary = &SecondaryReference{
+			Author: match[1],
+			Title:  strings.TrimRight(match[2], "."),
+			Year:   match[3] + match[4],
+			Pages:  rangeDashReplacer.Replace(strings.ReplaceAll(match[5], " ", "")),
+		}
+	} else if match := secondaryAdLocRegex.FindStringSubmatch(text); match != nil {
+		secondary = &SecondaryReference{Author: match[1], AdLoc: true}
+	} else {
+		return nil
+	}
+
+	name := strings.Fields(strings.ToLower(secondary.Author))
+	if ur.hasRecognizedAuthor(name) || ur.hasRecognizedAuthor(name[len(name)-1:]) {
+		return nil
+	}
+	return secondary
+}
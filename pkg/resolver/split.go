@@ -0,0 +1,188 @@
+package resolver
+
+import (
+	"regexp"
+	"strings"
+
+	"perseus_citation_linker/pkg/loader"
+)
+
+var (
+	// listSeparatorRegex matches the commas and semicolons between the
+	// references of a list
+	listSeparatorRegex = regexp.MustCompile(`\s*[;,]\s*`)
+	// listCueRegex matches the words that may open a reference of a list,
+	// as in "Il. 1.1; cf. Od. 5.10"
+	listCueRegex = regexp.MustCompile(`(?i)^(?:(?:cf|comp|see|also|and|&)\.?\s+)+`)
+	// listPassageRegex matches a passage given without author or work, its
+	// start in the first group, as in "2.3", "5 ff." or "vv. 100-110"
+	listPassageRegex = regexp.MustCompile(`(?i)^(?:(?:vv?|ll?)\.\s*)?(\d+[a-e]?(?:[.:]\d+[a-e]?)*)(?:\s*[-–]\s*\d+[a-e]?(?:[.:]\d+[a-e]?)*)?(?:\s*(?:ff?|sqq?)\.?)?\.?$`)
+	// listIbidRegex matches a passage of the work cited just before, as in
+	// "ib. 2.3" or "ibid. 5"
+	listIbidRegex = regexp.MustCompile(`(?i)^ib(?:id(?:em)?)?\.?\s+(.+)$`)
+	// listLocusRegex splits a reference in full into the author and work in
+	// the first group and the start of its passage in the second, as in
+	// "Il. 1.1-5 ff."
+	listLocusRegex = regexp.MustCompile(`(?i)^(.*?\p{L}.*?\.?)\s*(\d+[a-e]?(?:[.:]\d+[a-e]?)*)(?:\s*[-–]\s*\d+[a-e]?(?:[.:]\d+[a-e]?)*)?(?:\s*(?:ff?|sqq?|lyr)\.?)*\.?$`)
+	// listLevelRegex matches the separators of the levels of a passage
+	listLevelRegex = regexp.MustCompile(`[.:]`)
+)
+
+// SplitReferences splits text that lists several references, as in "Il.
+// 1.1, 2.3; Od. 5.10", into one reference in full per target. A passage
+// after a comma or semicolon, or after "ib." or "ibid.", is of the work of
+// the reference before it, taking as many of its leading levels as it lacks
+// ("Il. 1.1, 5" lists "Il. 1.1" and "Il. 1.5"), and a work after a
+// semicolon with no author of its own is of the author before it ("Hom.
+// Il. 1.1; Od. 5.10" lists "Hom. Od. 5.10"). Since a comma also separates
+// the levels of some passages, as in "Thuc. 2, 40", a comma only ends a
+// reference whose passage has several levels or whose work is cited by one
+// level alone, as the lines of a play, or one followed by an author or a
+// work of the same author.
+//
+// first, if given, is the first reference in full, as from the n attribute
+// of a bibl whose content lists several, and stands for the first one text
+// lists. Text listing one reference, or anything but references, gives nil.
+func (ur *URNResolver) SplitReferences(text, first string) []string {
+	text = strings.TrimSpace(text)
+	if !strings.ContainsAny(text, ";,") {
+		return nil
+	}
+	separators := listSeparatorRegex.FindAllStringIndex(text, -1)
+	var refs []string
+	current, start := "", 0
+	for i := 0; i <= len(separators); i++ {
+		end := len(text)
+		if i < len(separators) {
+			end = separators[i][0]
+		}
+		segment := text[start:end]
+		if i < len(separators) {
+			start = separators[i][1]
+		}
+		if current == "" {
+			current = segment
+			continue
+		}
+		separator := strings.TrimSpace(text[separators[i-1][0]:separators[i-1][1]])
+		previous := current
+		if len(refs) == 0 && first != "" {
+			previous = first
+		}
+		next, ok := ur.listContinuation(previous, segment, separator == ";")
+		switch {
+		case ok:
+			refs = append(refs, previous)
+			current = next
+		case separator == ";":
+			// whatever follows a semicolon is not part of the reference
+			return nil
+		default:
+			current += text[separators[i-1][0]:separators[i-1][1]] + segment
+		}
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+	return append(refs, current)
+}
+
+// listContinuation returns in full the reference segment gives after the
+// reference previous, and whether segment is one: a passage of the same
+// work, or after a semicolon a reference of its own
+func (ur *URNResolver) listContinuation(previous, segment string, semicolon bool) (string, bool) {
+	segment = strings.TrimSpace(listCueRegex.ReplaceAllString(segment, ""))
+	if !strings.ContainsAny(segment, "0123456789") {
+		return "", false
+	}
+	head, locus, simple := SplitLocus(previous)
+
+	if match := listIbidRegex.FindStringSubmatch(segment); match != nil {
+		segment = match[1]
+		if !listPassageRegex.MatchString(segment) || !simple {
+			return "", false
+		}
+		return head + " " + ContinuePassage(locus, segment), true
+	}
+	if match := listPassageRegex.FindStringSubmatch(segment); match != nil {
+		// "Thuc. 2, 40" is one reference, "Il. 1.1, 2.3" two, and so is
+		// "Soph. OC 5, 610" of a play cited by line alone
+		if !simple || (!semicolon && !listLevelRegex.MatchString(locus) && ur.schemeDepth(previous) != 1) {
+			return "", false
+		}
+		return head + " " + ContinuePassage(locus, segment), true
+	}
+
+	words := strings.Fields(strings.ToLower(ur.NormalizeRef(segment)))
+	if ur.hasRecognizedAuthor(words) {
+		return segment, true
+	}
+	// a work of the author before, as "Od." after "Hom. Il. 1.1"
+	author := ur.ReferenceAuthor(head)
+	if author != "" && ur.hasRecognizedWork(strings.ToLower(ur.NormalizeRef(author+" "+segment))) {
+		return author + " " + segment, true
+	}
+	if semicolon {
+		return segment, true
+	}
+	return "", false
+}
+
+// schemeDepth returns the levels of the citation scheme of the work a
+// reference cites, 0 if none is recorded
+func (ur *URNResolver) schemeDepth(ref string) int {
+	scheme := ur.resolveBuiltin(ur.GetRef("", ref), "", "").Scheme
+	return loader.WorkScheme{Scheme: scheme}.Depth()
+}
+
+// SplitLocus splits a reference in full, as "Soph. El. 626-8", into its
+// author and work and the start of its passage, reporting whether it has
+// that simple form
+func SplitLocus(ref string) (head, locus string, simple bool) {
+	match := listLocusRegex.FindStringSubmatch(strings.TrimSpace(ref))
+	if match == nil {
+		return "", "", false
+	}
+	return strings.TrimSpace(match[1]), match[2], true
+}
+
+// IsPassage reports whether text gives a passage alone, without author or
+// work, as "233" or "vv. 233-5"
+func IsPassage(text string) bool {
+	return listPassageRegex.MatchString(strings.TrimSpace(text))
+}
+
+// ContinuePassage returns the passage a continuation such as "5" or "vv.
+// 5-8" gives after the passage locus, filling in the leading levels it
+// leaves out: "5" after "1.1" is "1.5". The continuation must be a passage.
+func ContinuePassage(locus, continuation string) string {
+	continuation = strings.TrimSpace(continuation)
+	match := listPassageRegex.FindStringSubmatchIndex(continuation)
+	// "vv. 5-8" continues "Soph. OT 1" as "Soph. OT 5-8"
+	continuation = continuation[match[2]:]
+	levels := listLevelRegex.Split(locus, -1)
+	given := len(listLevelRegex.Split(continuation[:match[3]-match[2]], -1))
+	if given >= len(levels) {
+		return continuation
+	}
+	return strings.Join(levels[:len(levels)-given], ".") + "." + continuation
+}
+
+// ReferenceAuthor returns the leading words of the author and work of a
+// reference that name its author, as "Soph." of "Soph. El.", if they name
+// one and a work follows
+func (ur *URNResolver) ReferenceAuthor(head string) string {
+	words := strings.Fields(head)
+	lower := strings.Fields(strings.ToLower(ur.NormalizeRef(head)))
+	if len(words) != len(lower) {
+		return ""
+	}
+	author := ""
+	var prefixes [3]loader.NamePrefix
+	for _, prefix := range ur.Data.AuthorPrefixes(prefixes[:0], lower, 3) {
+		if prefix.Words < len(words) {
+			author = strings.Join(words[:prefix.Words], " ")
+		}
+	}
+	return author
+}
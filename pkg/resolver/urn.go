@@ -0,0 +1,136 @@
+package resolver
+
+import (
+	"regexp"
+	"strings"
+
+	"perseus_citation_linker/pkg/cts"
+)
+
+// urnReference is a URN, or the parts of one, written out in a reference
+type urnReference struct {
+	Scheme    string // "cts" or "cite2"
+	Namespace string // e.g. "greekLit"; for CITE2 the collection's namespace, e.g. "hmt"
+	TextGroup string // e.g. "tlg0011"; for CITE2 the collection, e.g. "vaimg.2017a"
+	Work      string // e.g. "tlg004", "" for a text group alone
+	Exemplar  string // version or exemplar, e.g. "perseus-grc1", "" if not given
+	Passage   string // e.g. "151"; for CITE2 the object. A text group alone has no passage in its URN.
+}
+
+var (
+	// urn:cite2:hmt:vaimg.2017a:VA012RN_0013
+	cite2Regex = regexp.MustCompile(`(?i)\burn:cite2:([^:\s]+):([^:\s]+):(\S*)`)
+	// urn:cts:greekLit:tlg0011.tlg004.perseus-grc1:151, any part after the
+	// text group being optional
+	ctsURNRegex = regexp.MustCompile(`(?i)\burn:cts:([a-z]+):([^.:\s]+)(?:\.([^.:\s]+))?(?:\.([^:\s]+))?(?::(\S*))?`)
+	// tlg0011.tlg004, phi0690.phi003.perseus-lat1, stoa0045.stoa001 or a
+	// commentary's viaf2603144.viaf001
+	workPairRegex = regexp.MustCompile(`\b(tlg|phi|stoa|viaf)(\d+)\.((?:tlg|phi|stoa|viaf)\d+)(?:\.([a-z][\w-]*))?`)
+	// a text group cited alone, as in "tlg0012" or "tlg0012 1.1"
+	textGroupRegex = regexp.MustCompile(`^(tlg|phi|stoa)(\d{4})\b`)
+)
+
+// ctsNamespaces restores the case of the CTS namespaces in lower-cased
+// references; the canon prefixes of aboNamespaces fill in the namespace of
+// URNs written without one
+var ctsNamespaces = map[string]string{
+	"greeklit":   "greekLit",
+	"latinlit":   "latinLit",
+	"englishlit": "englishLit",
+	"greekschol": "greekSchol",
+}
+
+// viafNamespace is the namespace of commentaries identified by VIAF numbers
+// when written without one, as Perseus catalogues its commentaries on Greek
+// texts, with their English exemplar
+const (
+	viafNamespace = "greekLit"
+	viafExemplar  = "perseus-eng1"
+)
+
+// detectURN finds a URN in a reference: a complete CITE2 or CTS URN, with or
+// without exemplar and passage, a text group and work pair such as
+// "tlg0011.tlg004 151", or a text group alone
+func (ur *URNResolver) detectURN(ref string) (urnReference, bool) {
+	if match := cite2Regex.FindStringSubmatch(ref); match != nil {
+		return urnReference{Scheme: "cite2", Namespace: match[1], TextGroup: match[2], Passage: match[3]}, true
+	}
+	if match := ctsURNRegex.FindStringSubmatch(ref); match != nil {
+		namespace := match[1]
+		if canonical, known := ctsNamespaces[strings.ToLower(namespace)]; known {
+			namespace = canonical
+		}
+		return urnReference{
+			Scheme:    "cts",
+			Namespace: namespace,
+			TextGroup: match[2],
+			Work:      match[3],
+			Exemplar:  match[4],
+			Passage:   match[5],
+		}, true
+	}
+	if loc := workPairRegex.FindStringSubmatchIndex(ref); loc != nil {
+		canon := ref[loc[2]:loc[3]]
+		urn := urnReference{
+			Scheme:    "cts",
+			Namespace: aboNamespaces[canon],
+			TextGroup: ref[loc[2]:loc[5]],
+			Work:      ref[loc[6]:loc[7]],
+		}
+		if loc[8] >= 0 {
+			urn.Exemplar = ref[loc[8]:loc[9]]
+		}
+		if canon == "viaf" {
+			urn.Namespace = viafNamespace
+			if urn.Exemplar == "" {
+				urn.Exemplar = viafExemplar
+			}
+		}
+		urn.Passage = locationRegex.FindString(ref[loc[1]:])
+		return urn, true
+	}
+	if loc := textGroupRegex.FindStringSubmatchIndex(strings.TrimSpace(ref)); loc != nil {
+		ref = strings.TrimSpace(ref)
+		canon := ref[loc[2]:loc[3]]
+		return urnReference{Scheme: "cts", Namespace: aboNamespaces[canon], TextGroup: ref[loc[2]:loc[5]], Passage: strings.TrimSpace(ref[loc[1]:])}, true
+	}
+	return urnReference{}, false
+}
+
+var locationRegex = regexp.MustCompile(`\d+.*`)
+
+// formatURN writes out a detected URN, filling in the exemplar of a work
+// cited without one as for resolved references
+func (ur *URNResolver) formatURN(detected urnReference) (string, error) {
+	if detected.Scheme == "cite2" {
+		return "urn:cite2:" + detected.Namespace + ":" + detected.TextGroup + ":" + detected.Passage, nil
+	}
+	urn := cts.URN{Namespace: detected.Namespace, TextGroup: detected.TextGroup, Work: detected.Work}
+	if urn.Work != "" {
+		urn.Version = detected.Exemplar
+		if urn.Version == "" {
+			urn.Version = ur.determineLiteratureSuffix(urn.String())
+		}
+		urn.Passage = cts.ParsePassage(detected.Passage)
+	}
+	if err := urn.Validate(); err != nil {
+		return "", err
+	}
+	return urn.String(), nil
+}
+
+// editionURN builds the URN of a passage of an author's work, given by its
+// work URN component, with the work's exemplar
+func (ur *URNResolver) editionURN(authURN, workURN, passage string) (string, error) {
+	urn, err := cts.Parse(authURN)
+	if err != nil {
+		return "", err
+	}
+	urn.Work = workURN
+	urn.Version = ur.determineLiteratureSuffix(urn.String())
+	urn.Passage = cts.ParsePassage(rangeDashReplacer.Replace(passage))
+	if err := urn.Validate(); err != nil {
+		return "", err
+	}
+	return urn.String(), nil
+}
@@ -0,0 +1,143 @@
+// Package resolvertest runs table-driven resolver regression cases written in
+// YAML, so that new abbreviations can be covered without writing Go code.
+//
+// A fixture file looks like:
+//
+//	data_dir: ../data          # optional, relative to the fixture file
+//	cases:
+//	  - ref: Soph. OT 151      # bibl text as it appears in the document
+//	    urn: urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:151
+//	  - n: Soph. El. 123       # optional n attribute, combined with ref as in extraction
+//	    ref: El. 123
+//	    urn: urn:cts:greekLit:tlg0011.tlg005.perseus-grc2:123
+//	  - ref: Soph. King Oedipus 151
+//	    work_aliases:          # merged into the data for this case only
+//	      sophocles: {king oedipus: tlg004}
+//	    urn: urn:cts:greekLit:tlg0011.tlg004.perseus-grc2:151
+//	  - ref: Xyz. 12
+//	    urn: ""                # expected not to resolve
+package resolvertest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"perseus_citation_linker/pkg/loader"
+	"perseus_citation_linker/pkg/resolver"
+)
+
+// Case is a single reference and the URN it must resolve to
+type Case struct {
+	Name        string                       `yaml:"name"`    // defaults to the ref
+	N           string                       `yaml:"n"`       // n attribute of the bibl, if any
+	Ref         string                       `yaml:"ref"`     // bibl content or reference as cited
+	Context     string                       `yaml:"context"` // context passed to the resolver
+	URN         string                       `yaml:"urn"`     // expected URN, "" if it must not resolve
+	DataDir     string                       `yaml:"data_dir"`
+	WorkAliases map[string]map[string]string `yaml:"work_aliases"`
+}
+
+// Fixture is a file of cases sharing a data directory
+type Fixture struct {
+	Path    string `yaml:"-"`
+	DataDir string `yaml:"data_dir"`
+	Cases   []Case `yaml:"cases"`
+}
+
+// LoadFixture reads a fixture file, making data directories relative to it
+func LoadFixture(path string) (*Fixture, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture %s: %w", path, err)
+	}
+	var fixture Fixture
+	if err := yaml.Unmarshal(content, &fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+	}
+	fixture.Path = path
+
+	base := filepath.Dir(path)
+	if fixture.DataDir != "" && !filepath.IsAbs(fixture.DataDir) {
+		fixture.DataDir = filepath.Join(base, fixture.DataDir)
+	}
+	for i, c := range fixture.Cases {
+		if c.Ref == "" && c.N == "" {
+			return nil, fmt.Errorf("%s: case %d has neither ref nor n", path, i+1)
+		}
+		if c.DataDir != "" && !filepath.IsAbs(c.DataDir) {
+			fixture.Cases[i].DataDir = filepath.Join(base, c.DataDir)
+		}
+	}
+	return &fixture, nil
+}
+
+// RunFixtures loads every fixture file matching pattern and runs its cases as
+// subtests of t
+func RunFixtures(t *testing.T, pattern string) {
+	t.Helper()
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatalf("bad fixture pattern %s: %v", pattern, err)
+	}
+	if len(paths) == 0 {
+		t.Fatalf("no fixture files match %s", pattern)
+	}
+	for _, path := range paths {
+		fixture, err := LoadFixture(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Run(filepath.Base(path), fixture.Run)
+	}
+}
+
+// Run resolves every case and reports mismatches as subtest failures
+func (f *Fixture) Run(t *testing.T) {
+	resolvers := make(map[string]*resolver.URNResolver)
+	for _, c := range f.Cases {
+		c := c
+		name := c.Name
+		if name == "" {
+			name = c.Ref
+		}
+		t.Run(name, func(t *testing.T) {
+			dataDir := c.DataDir
+			if dataDir == "" {
+				dataDir = f.DataDir
+			}
+			if dataDir == "" {
+				dataDir = loader.FindDataDir()
+			}
+
+			var urnResolver *resolver.URNResolver
+			var err error
+			if len(c.WorkAliases) > 0 {
+				// overrides change the data, so they get a resolver of their own
+				urnResolver, err = resolver.NewURNResolverFromDir(dataDir)
+				if err == nil {
+					urnResolver.Data.MergeWorkAliases(c.WorkAliases)
+				}
+			} else if urnResolver = resolvers[dataDir]; urnResolver == nil {
+				urnResolver, err = resolver.NewURNResolverFromDir(dataDir)
+				resolvers[dataDir] = urnResolver
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// editions named after the locus are split off, as in processing runs
+			_, n := urnResolver.SplitEdition(c.N)
+			_, bibl := urnResolver.SplitEdition(c.Ref)
+			ref := urnResolver.GetRef(n, bibl)
+			res := urnResolver.Resolve(ref, c.Context, f.Path)
+			if res.URN != c.URN {
+				t.Errorf("%s: ref %q resolved to %q, expected %q (warnings: %v)",
+					f.Path, ref, res.URN, c.URN, res.Warnings)
+			}
+		})
+	}
+}